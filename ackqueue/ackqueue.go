@@ -0,0 +1,217 @@
+package ackqueue
+
+import (
+	"errors"
+	"slices"
+	"sync"
+	"time"
+)
+
+// ErrUnknown is returned by Ack and Nack for an ID that is not in flight,
+// e.g. one that was already acknowledged.
+var ErrUnknown = errors.New("ackqueue: unknown delivery")
+
+// Delivery is one attempt at handing a message to a consumer.
+type Delivery struct {
+	ID      uint64
+	Payload any
+	Attempt int // 1 for the first delivery
+}
+
+// Stats counts queue activity since creation.
+type Stats struct {
+	Pushed      uint64
+	Delivered   uint64 // including redeliveries
+	Redelivered uint64
+	Acked       uint64
+	Dead        uint64
+}
+
+// Queue gives at-least-once delivery: a pulled message is leased to the
+// consumer and comes back to the queue unless acknowledged before the
+// lease expires. A message that has been delivered max-attempts times
+// without an ack moves to the dead-letter list instead.
+//
+// A late ack still counts, even after the message was redelivered, so
+// consumers must tolerate duplicates but never see a message again once
+// an ack has been accepted.
+type Queue struct {
+	mu          sync.Mutex
+	now         func() time.Time
+	lease       time.Duration
+	maxAttempts int
+
+	nextID   uint64
+	ready    []uint64
+	messages map[uint64]*entry
+	dead     []Delivery
+	stats    Stats
+}
+
+type entry struct {
+	payload  any
+	attempts int
+	deadline time.Time // zero while ready
+}
+
+// Option configures a Queue.
+type Option func(*Queue)
+
+// WithMaxAttempts dead-letters a message after n unacknowledged
+// deliveries. The default, 0, retries forever.
+func WithMaxAttempts(n int) Option {
+	return func(q *Queue) {
+		q.maxAttempts = max(n, 0)
+	}
+}
+
+// New returns an empty queue whose deliveries must be acknowledged
+// within lease.
+func New(lease time.Duration, opts ...Option) *Queue {
+	q := &Queue{
+		now:      time.Now,
+		lease:    lease,
+		messages: make(map[uint64]*entry),
+	}
+	for _, opt := range opts {
+		opt(q)
+	}
+	return q
+}
+
+// Push enqueues payload and returns its ID.
+func (q *Queue) Push(payload any) uint64 {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.nextID++
+	q.messages[q.nextID] = &entry{payload: payload}
+	q.ready = append(q.ready, q.nextID)
+	q.stats.Pushed++
+	return q.nextID
+}
+
+// Pull leases the oldest ready message, first returning expired leases
+// to the queue. It reports false if nothing is ready.
+func (q *Queue) Pull() (Delivery, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	now := q.now()
+	q.expire(now)
+	if len(q.ready) == 0 {
+		return Delivery{}, false
+	}
+
+	id := q.ready[0]
+	q.ready = q.ready[1:]
+	e := q.messages[id]
+	e.attempts++
+	e.deadline = now.Add(q.lease)
+	q.stats.Delivered++
+	if e.attempts > 1 {
+		q.stats.Redelivered++
+	}
+	return Delivery{ID: id, Payload: e.payload, Attempt: e.attempts}, true
+}
+
+// Ack marks the message as processed. It fails with ErrUnknown if the
+// message was already acknowledged or dead-lettered.
+func (q *Queue) Ack(id uint64) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if _, ok := q.messages[id]; !ok {
+		return ErrUnknown
+	}
+	delete(q.messages, id)
+	if i := q.readyIndex(id); i >= 0 {
+		// late ack for a message already waiting for redelivery
+		q.ready = append(q.ready[:i], q.ready[i+1:]...)
+	}
+	q.stats.Acked++
+	return nil
+}
+
+// Nack gives up the lease so the message is redelivered immediately.
+func (q *Queue) Nack(id uint64) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	e, ok := q.messages[id]
+	if !ok || e.deadline.IsZero() {
+		return ErrUnknown
+	}
+	q.requeue(id, e)
+	return nil
+}
+
+// Expire returns expired leases to the queue, or to the dead-letter list,
+// and reports how many there were. Pull calls it implicitly.
+func (q *Queue) Expire() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.expire(q.now())
+}
+
+// expire requeues messages whose lease ended by now, oldest ID first so
+// that the resulting order does not depend on map iteration. Caller
+// holds mu.
+func (q *Queue) expire(now time.Time) int {
+	var expired []uint64
+	for id, e := range q.messages {
+		if !e.deadline.IsZero() && !now.Before(e.deadline) {
+			expired = append(expired, id)
+		}
+	}
+	slices.Sort(expired)
+	for _, id := range expired {
+		q.requeue(id, q.messages[id])
+	}
+	return len(expired)
+}
+
+// requeue ends the lease on id. Caller holds mu.
+func (q *Queue) requeue(id uint64, e *entry) {
+	e.deadline = time.Time{}
+	if q.maxAttempts > 0 && e.attempts >= q.maxAttempts {
+		delete(q.messages, id)
+		q.dead = append(q.dead, Delivery{ID: id, Payload: e.payload, Attempt: e.attempts})
+		q.stats.Dead++
+		return
+	}
+	q.ready = append(q.ready, id)
+}
+
+// readyIndex returns the position of id in the ready list, or -1.
+// Caller holds mu.
+func (q *Queue) readyIndex(id uint64) int {
+	for i, r := range q.ready {
+		if r == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// Len returns the number of messages not yet acknowledged or
+// dead-lettered, whether ready or in flight.
+func (q *Queue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.messages)
+}
+
+// DeadLetters returns the messages that ran out of attempts, oldest first.
+func (q *Queue) DeadLetters() []Delivery {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return append([]Delivery(nil), q.dead...)
+}
+
+// Stats returns activity counters.
+func (q *Queue) Stats() Stats {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.stats
+}
@@ -0,0 +1,103 @@
+package ackqueue
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// fakeClock is a manually advanced clock.
+type fakeClock struct{ t time.Time }
+
+func (c *fakeClock) now() time.Time          { return c.t }
+func (c *fakeClock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func newTestQueue(lease time.Duration, opts ...Option) (*Queue, *fakeClock) {
+	clk := &fakeClock{t: time.Unix(0, 0)}
+	q := New(lease, opts...)
+	q.now = clk.now
+	return q, clk
+}
+
+// TestQueue_AckRemoves tests that an acknowledged message is gone
+func TestQueue_AckRemoves(t *testing.T) {
+	q, _ := newTestQueue(time.Second)
+	id := q.Push("a")
+
+	d, ok := q.Pull()
+	if !ok || d.ID != id || d.Payload != "a" || d.Attempt != 1 {
+		t.Fatalf("Pull() = %+v, %v", d, ok)
+	}
+	if err := q.Ack(id); err != nil {
+		t.Errorf("Ack() = %v, want nil", err)
+	}
+	if err := q.Ack(id); !errors.Is(err, ErrUnknown) {
+		t.Errorf("second Ack() = %v, want ErrUnknown", err)
+	}
+	if q.Len() != 0 {
+		t.Errorf("Len() = %d, want 0", q.Len())
+	}
+}
+
+// TestQueue_Redeliver tests that an expired lease is delivered again
+func TestQueue_Redeliver(t *testing.T) {
+	q, clk := newTestQueue(time.Second)
+	q.Push("a")
+	q.Pull()
+
+	if _, ok := q.Pull(); ok {
+		t.Fatal("Pull() during lease returned a message")
+	}
+	clk.advance(time.Second)
+	d, ok := q.Pull()
+	if !ok || d.Attempt != 2 {
+		t.Fatalf("Pull() after lease = %+v, %v, want attempt 2", d, ok)
+	}
+
+	if err := q.Nack(d.ID); err != nil {
+		t.Errorf("Nack() = %v, want nil", err)
+	}
+	if d, _ := q.Pull(); d.Attempt != 3 {
+		t.Errorf("Pull() after Nack attempt = %d, want 3", d.Attempt)
+	}
+	if st := q.Stats(); st.Delivered != 3 || st.Redelivered != 2 {
+		t.Errorf("Stats() = %+v", st)
+	}
+}
+
+// TestQueue_LateAck tests that an ack after expiry cancels the redelivery
+func TestQueue_LateAck(t *testing.T) {
+	q, clk := newTestQueue(time.Second)
+	id := q.Push("a")
+	q.Pull()
+	clk.advance(2 * time.Second)
+	q.Expire()
+
+	if err := q.Ack(id); err != nil {
+		t.Errorf("late Ack() = %v, want nil", err)
+	}
+	if d, ok := q.Pull(); ok {
+		t.Errorf("Pull() after late ack = %+v, want nothing", d)
+	}
+}
+
+// TestQueue_DeadLetter tests that attempts are bounded by WithMaxAttempts
+func TestQueue_DeadLetter(t *testing.T) {
+	q, clk := newTestQueue(time.Second, WithMaxAttempts(2))
+	id := q.Push("a")
+	for range 2 {
+		q.Pull()
+		clk.advance(time.Second)
+	}
+
+	if _, ok := q.Pull(); ok {
+		t.Error("Pull() returned a message past max attempts")
+	}
+	dead := q.DeadLetters()
+	if len(dead) != 1 || dead[0].ID != id || dead[0].Attempt != 2 {
+		t.Errorf("DeadLetters() = %+v", dead)
+	}
+	if err := q.Ack(id); !errors.Is(err, ErrUnknown) {
+		t.Errorf("Ack() of dead letter = %v, want ErrUnknown", err)
+	}
+}
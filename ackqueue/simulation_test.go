@@ -0,0 +1,179 @@
+package ackqueue
+
+import (
+	"flag"
+	"fmt"
+	"hash/fnv"
+	"testing"
+	"time"
+
+	"github.com/arifmahmudrana/go-snippets/sim"
+)
+
+// seedFlag replays one simulation, e.g. the seed named by a failure:
+//
+//	go test ./ackqueue -run TestSimulation -seed 1234 -v
+var seedFlag = flag.Uint64("seed", 0, "replay a single simulation seed")
+
+const (
+	simMessages    = 50
+	simConsumers   = 3
+	simLease       = time.Second
+	simMaxAttempts = 5
+	simHorizon     = 2 * time.Minute
+)
+
+// simResult is what one seeded run observed.
+type simResult struct {
+	pushed     []uint64
+	deliveries map[uint64]int
+	acks       map[uint64]int
+	violations []string
+	trace      uint64 // hash of every event, to check determinism
+	queue      *Queue
+}
+
+// simulate runs publishers and unreliable consumers against a queue on
+// virtual time. Consumers crash without acking, nack, or ack late
+// enough to race a redelivery, all decided by the seed.
+func simulate(seed uint64) simResult {
+	s := sim.New(seed)
+	rng := s.Rand()
+	q := New(simLease, WithMaxAttempts(simMaxAttempts))
+	q.now = s.Now
+
+	res := simResult{
+		deliveries: make(map[uint64]int),
+		acks:       make(map[uint64]int),
+		queue:      q,
+	}
+	h := fnv.New64a()
+	logf := func(format string, args ...any) {
+		fmt.Fprintf(h, "%v ", s.Now().Sub(sim.Epoch))
+		fmt.Fprintf(h, format+"\n", args...)
+	}
+
+	for i := range simMessages {
+		s.After(time.Duration(rng.Int64N(int64(10*time.Second))), func() {
+			id := q.Push(i)
+			res.pushed = append(res.pushed, id)
+			logf("push %d", id)
+		})
+	}
+
+	deadline := sim.Epoch.Add(simHorizon)
+	for c := range simConsumers {
+		s.Every(100*time.Millisecond, func() bool {
+			d, ok := q.Pull()
+			if ok {
+				logf("c%d got %d attempt %d", c, d.ID, d.Attempt)
+				res.deliveries[d.ID]++
+				if res.acks[d.ID] > 0 {
+					res.violations = append(res.violations,
+						fmt.Sprintf("message %d delivered after it was acked", d.ID))
+				}
+				consume(s, q, &res, d, logf)
+			}
+			return s.Now().Before(deadline)
+		})
+	}
+
+	s.Run()
+	res.trace = h.Sum64()
+	return res
+}
+
+// consume plays one consumer's handling of d.
+func consume(s *sim.Sim, q *Queue, res *simResult, d Delivery, logf func(string, ...any)) {
+	rng := s.Rand()
+	switch r := rng.Float64(); {
+	case r < 0.1:
+		logf("crash on %d", d.ID) // never acks; the lease must expire
+	case r < 0.2:
+		s.After(time.Duration(rng.Int64N(int64(100*time.Millisecond))), func() {
+			logf("nack %d: %v", d.ID, q.Nack(d.ID))
+		})
+	default:
+		// up to 1.5 leases, so some acks race a redelivery
+		s.After(time.Duration(rng.Int64N(int64(simLease*3/2))), func() {
+			err := q.Ack(d.ID)
+			if err == nil {
+				res.acks[d.ID]++
+			}
+			logf("ack %d: %v", d.ID, err)
+		})
+	}
+}
+
+// simSeeds returns the seeds to run: the -seed flag alone, or a sweep.
+func simSeeds() []uint64 {
+	if *seedFlag != 0 {
+		return []uint64{*seedFlag}
+	}
+	n := uint64(200)
+	if testing.Short() {
+		n = 20
+	}
+	seeds := make([]uint64, n)
+	for i := range seeds {
+		seeds[i] = uint64(i + 1)
+	}
+	return seeds
+}
+
+// TestSimulation_AtLeastOnce tests delivery-count invariants over many
+// seeded interleavings
+func TestSimulation_AtLeastOnce(t *testing.T) {
+	var redelivered, dead uint64
+	for _, seed := range simSeeds() {
+		res := simulate(seed)
+		for _, v := range res.violations {
+			t.Errorf("seed %d: %s", seed, v)
+		}
+
+		deadIDs := make(map[uint64]bool)
+		for _, d := range res.queue.DeadLetters() {
+			deadIDs[d.ID] = true
+			if d.Attempt != simMaxAttempts {
+				t.Errorf("seed %d: dead letter %d after %d attempts, want %d", seed, d.ID, d.Attempt, simMaxAttempts)
+			}
+		}
+
+		for _, id := range res.pushed {
+			n := res.deliveries[id]
+			if n < 1 || n > simMaxAttempts {
+				t.Errorf("seed %d: message %d delivered %d times, want 1..%d", seed, id, n, simMaxAttempts)
+			}
+			if acks := res.acks[id]; acks > 1 {
+				t.Errorf("seed %d: message %d acked %d times", seed, id, acks)
+			}
+			if (res.acks[id] == 1) == deadIDs[id] {
+				t.Errorf("seed %d: message %d acked=%d dead=%v, want exactly one", seed, id, res.acks[id], deadIDs[id])
+			}
+		}
+
+		st := res.queue.Stats()
+		if st.Pushed != simMessages || st.Acked+st.Dead != st.Pushed || res.queue.Len() != 0 {
+			t.Errorf("seed %d: Stats() = %+v, Len() = %d", seed, st, res.queue.Len())
+		}
+		redelivered += st.Redelivered
+		dead += st.Dead
+	}
+
+	// the schedules must actually exercise the redelivery paths
+	if len(simSeeds()) > 1 && (redelivered == 0 || dead == 0) {
+		t.Errorf("sweep saw %d redeliveries and %d dead letters, want both > 0", redelivered, dead)
+	}
+}
+
+// TestSimulation_Deterministic tests that a seed replays the same run
+func TestSimulation_Deterministic(t *testing.T) {
+	for _, seed := range []uint64{1, 42, 99} {
+		if a, b := simulate(seed).trace, simulate(seed).trace; a != b {
+			t.Errorf("seed %d: traces %x and %x differ", seed, a, b)
+		}
+	}
+	if simulate(1).trace == simulate(2).trace {
+		t.Error("seeds 1 and 2 produced the same trace")
+	}
+}
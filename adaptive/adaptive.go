@@ -0,0 +1,245 @@
+package adaptive
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync"
+	"time"
+)
+
+// ErrLimited is returned by Do when the limit is reached and the caller
+// chose not to wait.
+var ErrLimited = errors.New("adaptive: concurrency limit reached")
+
+// Outcome is how a call ended, as far as the limiter is concerned.
+type Outcome int
+
+const (
+	// Success is a call whose latency says something about the load.
+	Success Outcome = iota
+	// Dropped is a call that timed out or was rejected for overload:
+	// the limit backs off at once.
+	Dropped
+	// Ignored is a call that failed for reasons unrelated to load, such
+	// as bad input; its latency is not sampled.
+	Ignored
+)
+
+// Limiter limits how many calls run at once, adjusting the limit to
+// the latency it observes, in the manner of TCP Vegas: it remembers the
+// lowest latency seen, taken as the no-load latency, and from how much
+// slower calls are than that estimates how many are queued at the
+// backend. While that queue is short the limit grows, fast when very
+// short; once it is long the limit shrinks. The limit thereby settles
+// just above what the backend can run at once, without configuring it.
+//
+// Like Vegas, it adjusts at most once per round trip, on the samples
+// of calls started since the last adjustment, so that it sees the
+// effect of one change before making the next.
+type Limiter struct {
+	min, max   int
+	probeEvery int
+	now        func() time.Time
+
+	mu       sync.Mutex
+	limit    float64
+	inflight int
+	minRTT   time.Duration // 0 until the first sample
+	samples  int           // since minRTT was last reset
+	changed  chan struct{} // closed when inflight or limit changes
+
+	// the samples since the last adjustment
+	window struct {
+		end      time.Time // adjust at the first sample after this
+		rtt      time.Duration
+		n        int
+		inflight int // the most calls any of them started with
+	}
+}
+
+// Option configures a Limiter.
+type Option func(*Limiter)
+
+// WithInitial sets the starting limit (default 20).
+func WithInitial(n int) Option {
+	return func(l *Limiter) {
+		l.limit = float64(max(n, 1))
+	}
+}
+
+// WithBounds keeps the limit within [min, max] (default [1, 1000]).
+func WithBounds(min, max int) Option {
+	return func(l *Limiter) {
+		l.min, l.max = min, max
+	}
+}
+
+// WithProbe forgets the no-load latency every n samples, so the limiter
+// adapts when the backend gets slower for good, e.g. after a deploy,
+// rather than taking it for overload. The first samples after that
+// come from a loaded backend, so the limit swings up for a while; n
+// should be large. By default the no-load latency is never forgotten.
+func WithProbe(n int) Option {
+	return func(l *Limiter) {
+		l.probeEvery = n
+	}
+}
+
+// New returns a Limiter.
+func New(opts ...Option) *Limiter {
+	l := &Limiter{
+		min:     1,
+		max:     1000,
+		now:     time.Now,
+		limit:   20,
+		changed: make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(l)
+	}
+	l.limit = clamp(l.limit, l.min, l.max)
+	return l
+}
+
+// TryAcquire starts a call if the limit allows it. The caller must call
+// release with the outcome when the call ends.
+func (l *Limiter) TryAcquire() (release func(Outcome), ok bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.inflight >= int(l.limit) {
+		return nil, false
+	}
+	return l.start(), true
+}
+
+// Acquire is like TryAcquire but waits for the limit to allow the call,
+// or for ctx to be done.
+func (l *Limiter) Acquire(ctx context.Context) (release func(Outcome), err error) {
+	for {
+		l.mu.Lock()
+		if l.inflight < int(l.limit) {
+			defer l.mu.Unlock()
+			return l.start(), nil
+		}
+		changed := l.changed
+		l.mu.Unlock()
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-changed:
+		}
+	}
+}
+
+// start counts a call in and returns its release. Caller holds mu.
+func (l *Limiter) start() func(Outcome) {
+	l.inflight++
+	inflight := l.inflight
+	began := l.now()
+	var once sync.Once
+	return func(o Outcome) {
+		once.Do(func() {
+			rtt := l.now().Sub(began)
+			l.mu.Lock()
+			defer l.mu.Unlock()
+			l.inflight--
+			l.update(o, rtt, inflight, l.now())
+			close(l.changed)
+			l.changed = make(chan struct{})
+		})
+	}
+}
+
+// update records one sample, and adjusts the limit if a round trip has
+// passed since the last adjustment. inflight is how many calls were
+// running when this one started. Caller holds mu.
+func (l *Limiter) update(o Outcome, rtt time.Duration, inflight int, now time.Time) {
+	switch o {
+	case Ignored:
+		return
+	case Dropped:
+		l.limit = clamp(l.limit*0.9, l.min, l.max)
+		return
+	}
+	if l.samples++; l.probeEvery > 0 && l.samples > l.probeEvery {
+		l.minRTT, l.samples = 0, 0
+	}
+	if l.minRTT == 0 || rtt < l.minRTT {
+		l.minRTT = rtt
+	}
+	w := &l.window
+	w.rtt += rtt
+	w.n++
+	w.inflight = max(w.inflight, inflight)
+	if now.Before(w.end) {
+		return
+	}
+	rtt, inflight = w.rtt/time.Duration(w.n), w.inflight
+	w.end, w.rtt, w.n, w.inflight = now.Add(rtt), 0, 0, 0
+	if float64(inflight)*2 < l.limit {
+		return // too little load to learn from
+	}
+
+	// by Little's law, the calls beyond what would run at no-load
+	// latency are queued
+	queue := math.Ceil(float64(inflight) * (1 - float64(l.minRTT)/float64(max(rtt, 1))))
+	step := max(math.Log10(l.limit), 1)
+	switch {
+	case queue <= step:
+		l.limit += 6 * step
+	case queue < 3*step:
+		l.limit += step
+	case queue > 6*step:
+		// far over, as after a backend slowdown: close half the gap
+		l.limit -= max(step, (queue-6*step)/2)
+	}
+	l.limit = clamp(l.limit, l.min, l.max)
+}
+
+func clamp(v float64, lo, hi int) float64 {
+	return min(max(v, float64(lo)), float64(hi))
+}
+
+// Do runs fn within the limit, waiting for it if wait is set and
+// failing with ErrLimited otherwise. fn's error decides the outcome:
+// nil is a Success, context.DeadlineExceeded (a timeout) is Dropped,
+// and anything else is Ignored.
+func (l *Limiter) Do(ctx context.Context, wait bool, fn func(ctx context.Context) error) error {
+	var release func(Outcome)
+	if wait {
+		var err error
+		if release, err = l.Acquire(ctx); err != nil {
+			return err
+		}
+	} else {
+		var ok bool
+		if release, ok = l.TryAcquire(); !ok {
+			return ErrLimited
+		}
+	}
+	err := fn(ctx)
+	switch {
+	case err == nil:
+		release(Success)
+	case errors.Is(err, context.DeadlineExceeded):
+		release(Dropped)
+	default:
+		release(Ignored)
+	}
+	return err
+}
+
+// Limit returns the current limit.
+func (l *Limiter) Limit() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return int(l.limit)
+}
+
+// InFlight returns how many calls are running.
+func (l *Limiter) InFlight() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.inflight
+}
@@ -0,0 +1,115 @@
+package adaptive
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/arifmahmudrana/go-snippets/sim"
+)
+
+// backend is a simulated server that runs capacity calls at base
+// latency and slows down in proportion beyond that.
+type backend struct {
+	capacity int
+	base     time.Duration
+	inflight int
+}
+
+// simulate offers the limiter one call every 20µs, more than the
+// backend can take, and returns the limit after each virtual second.
+func simulate(l *Limiter, s *sim.Sim, b *backend, seconds int) []int {
+	l.now = s.Now
+	s.Every(20*time.Microsecond, func() bool {
+		release, ok := l.TryAcquire()
+		if !ok {
+			return true // rejected: the caller sheds or retries elsewhere
+		}
+		b.inflight++
+		rtt := b.base * time.Duration(max(b.inflight, b.capacity)) / time.Duration(b.capacity)
+		s.After(rtt, func() {
+			b.inflight--
+			release(Success)
+		})
+		return true
+	})
+	var limits []int
+	for range seconds {
+		s.RunFor(time.Second)
+		limits = append(limits, l.Limit())
+	}
+	return limits
+}
+
+// TestLimiter_Converges simulates overload and checks that the limit
+// settles just above the backend's capacity from either side
+func TestLimiter_Converges(t *testing.T) {
+	tests := []struct {
+		name     string
+		capacity int
+		initial  int
+	}{
+		{"grows", 50, 5},
+		{"shrinks", 50, 500},
+		{"large backend", 300, 20},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := New(WithInitial(tt.initial))
+			limits := simulate(l, sim.New(1), &backend{capacity: tt.capacity, base: 10 * time.Millisecond}, 4)
+			for _, got := range limits[2:] {
+				if got < tt.capacity || got > tt.capacity*3/2 {
+					t.Fatalf("limits per second = %v, want the last ones in [%d, %d]", limits, tt.capacity, tt.capacity*3/2)
+				}
+			}
+		})
+	}
+}
+
+// TestLimiter_Follows tests that the limit follows the backend's
+// capacity when it drops and recovers
+func TestLimiter_Follows(t *testing.T) {
+	s := sim.New(1)
+	b := &backend{capacity: 100, base: 10 * time.Millisecond}
+	l := New()
+	simulate(l, s, b, 2)
+	for _, capacity := range []int{30, 100} {
+		b.capacity = capacity
+		for range 2 {
+			s.RunFor(time.Second)
+		}
+		if got := l.Limit(); got < capacity || got > capacity*3/2 {
+			t.Errorf("limit = %d after capacity became %d", got, capacity)
+		}
+	}
+}
+
+// TestLimiter_Do tests waiting, rejection and backing off on timeouts
+func TestLimiter_Do(t *testing.T) {
+	l := New(WithInitial(1))
+	ctx := context.Background()
+
+	release, _ := l.Acquire(ctx)
+	if err := l.Do(ctx, false, func(ctx context.Context) error { return nil }); !errors.Is(err, ErrLimited) {
+		t.Errorf("Do() at the limit = %v, want %v", err, ErrLimited)
+	}
+	short, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if err := l.Do(short, true, func(ctx context.Context) error { return nil }); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("waiting Do() = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	done := make(chan error)
+	go func() { done <- l.Do(ctx, true, func(ctx context.Context) error { return nil }) }()
+	release(Success)
+	if err := <-done; err != nil {
+		t.Errorf("Do() after release = %v", err)
+	}
+
+	l = New(WithInitial(100))
+	l.Do(ctx, false, func(ctx context.Context) error { return context.DeadlineExceeded })
+	if got := l.Limit(); got != 90 {
+		t.Errorf("limit after a timeout = %d, want 90", got)
+	}
+}
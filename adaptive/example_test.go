@@ -0,0 +1,35 @@
+package adaptive_test
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/arifmahmudrana/go-snippets/adaptive"
+	"github.com/arifmahmudrana/go-snippets/workerpool"
+)
+
+// ExampleLimiter_Do caps how many of a pool's workers call a backend at
+// once, below the pool's own size, by the limit the backend's latency
+// allows.
+func ExampleLimiter_Do() {
+	l := adaptive.New(adaptive.WithInitial(4))
+	pool := workerpool.New(context.Background(), 16)
+
+	var calls atomic.Int32
+	for range 32 {
+		pool.Submit(context.Background(), func(ctx context.Context) {
+			ctx, cancel := context.WithTimeout(ctx, time.Second)
+			defer cancel()
+			l.Do(ctx, true, func(ctx context.Context) error {
+				calls.Add(1)
+				time.Sleep(time.Millisecond) // the backend call
+				return nil
+			})
+		})
+	}
+	pool.Close()
+	fmt.Println(calls.Load(), "calls,", l.InFlight(), "in flight")
+	// Output: 32 calls, 0 in flight
+}
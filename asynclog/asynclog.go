@@ -0,0 +1,207 @@
+package asynclog
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+)
+
+// Stats counts what happened to records handed to the handler.
+type Stats struct {
+	Enqueued uint64
+	Written  uint64
+	Dropped  uint64 // lost because the queue was full or closed
+	Errors   uint64 // returned by the wrapped handler
+}
+
+type entry struct {
+	h slog.Handler
+	r slog.Record
+}
+
+// queue is the ring buffer and writer goroutine shared by a Handler and
+// every handler derived from it with WithAttrs or WithGroup.
+type queue struct {
+	dropOldest bool
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	buf     []entry
+	head, n int
+	busy    bool // the writer is handling a batch
+	closed  bool
+	done    chan struct{}
+
+	enqueued, written, dropped, errors atomic.Uint64
+}
+
+// Handler is a slog.Handler that never blocks the caller on I/O: records
+// go into a fixed-size ring buffer and a single goroutine passes them to
+// the wrapped handler. When the buffer is full, records are dropped and
+// counted rather than slowing the application down — the trade-off a
+// logger should make, and the one a broker must make explicitly.
+type Handler struct {
+	next slog.Handler
+	q    *queue
+}
+
+// Option configures a Handler.
+type Option func(*queue)
+
+// WithDropOldest makes a full queue discard its oldest record to make
+// room, keeping the most recent context. By default the new record is
+// dropped.
+func WithDropOldest() Option {
+	return func(q *queue) {
+		q.dropOldest = true
+	}
+}
+
+// New wraps next with a queue of size records (at least 1) and starts
+// the writer goroutine. Call Close before exiting to flush the queue.
+func New(next slog.Handler, size int, opts ...Option) *Handler {
+	q := &queue{buf: make([]entry, max(size, 1)), done: make(chan struct{})}
+	q.cond = sync.NewCond(&q.mu)
+	for _, opt := range opts {
+		opt(q)
+	}
+	go q.run()
+	return &Handler{next: next, q: q}
+}
+
+// Enabled reports whether the wrapped handler handles level.
+func (h *Handler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+// Handle queues r and returns immediately. It never returns an error;
+// see Stats for drops and downstream failures.
+func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
+	q := h.q
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed {
+		q.dropped.Add(1)
+		return nil
+	}
+	if q.n == len(q.buf) {
+		q.dropped.Add(1)
+		if !q.dropOldest {
+			return nil
+		}
+		q.buf[q.head] = entry{}
+		q.head = (q.head + 1) % len(q.buf)
+		q.n--
+	}
+
+	// Clone: the caller may reuse r's attribute storage after we return
+	q.buf[(q.head+q.n)%len(q.buf)] = entry{h: h.next, r: r.Clone()}
+	q.n++
+	q.enqueued.Add(1)
+	q.cond.Broadcast()
+	return nil
+}
+
+// WithAttrs returns a handler that adds attrs and shares this queue.
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &Handler{next: h.next.WithAttrs(attrs), q: h.q}
+}
+
+// WithGroup returns a handler that opens a group and shares this queue.
+func (h *Handler) WithGroup(name string) slog.Handler {
+	return &Handler{next: h.next.WithGroup(name), q: h.q}
+}
+
+func (q *queue) run() {
+	defer close(q.done)
+
+	var batch []entry
+	for {
+		q.mu.Lock()
+		q.busy = false
+		q.cond.Broadcast() // wake Flush
+		for q.n == 0 && !q.closed {
+			q.cond.Wait()
+		}
+		if q.n == 0 {
+			q.mu.Unlock()
+			return
+		}
+
+		// take everything queued so writes don't hold the lock
+		batch = batch[:0]
+		for q.n > 0 {
+			batch = append(batch, q.buf[q.head])
+			q.buf[q.head] = entry{}
+			q.head = (q.head + 1) % len(q.buf)
+			q.n--
+		}
+		q.busy = true
+		q.mu.Unlock()
+
+		for _, e := range batch {
+			if err := e.h.Handle(context.Background(), e.r); err != nil {
+				q.errors.Add(1)
+			}
+			q.written.Add(1)
+		}
+	}
+}
+
+// Stats returns the handler's counters. They are shared with derived
+// handlers.
+func (h *Handler) Stats() Stats {
+	return Stats{
+		Enqueued: h.q.enqueued.Load(),
+		Written:  h.q.written.Load(),
+		Dropped:  h.q.dropped.Load(),
+		Errors:   h.q.errors.Load(),
+	}
+}
+
+// Flush waits until every record queued so far has been written, or
+// until ctx is done.
+func (h *Handler) Flush(ctx context.Context) error {
+	q := h.q
+	drained := make(chan struct{})
+	go func() {
+		q.mu.Lock()
+		for (q.n > 0 || q.busy) && !q.closed {
+			q.cond.Wait()
+		}
+		q.mu.Unlock()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+		return nil
+	case <-q.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close stops accepting records and waits for the queue to drain, or
+// until ctx is done; records still queued then are counted as dropped.
+func (h *Handler) Close(ctx context.Context) error {
+	q := h.q
+	q.mu.Lock()
+	q.closed = true
+	q.cond.Broadcast()
+	q.mu.Unlock()
+
+	select {
+	case <-q.done:
+		return nil
+	case <-ctx.Done():
+		q.mu.Lock()
+		q.dropped.Add(uint64(q.n))
+		q.n = 0
+		q.mu.Unlock()
+		return ctx.Err()
+	}
+}
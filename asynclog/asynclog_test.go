@@ -0,0 +1,123 @@
+package asynclog
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// gatedWriter blocks every Write until the gate is opened.
+type gatedWriter struct {
+	gate chan struct{}
+	mu   sync.Mutex
+	buf  bytes.Buffer
+}
+
+func (w *gatedWriter) Write(p []byte) (int, error) {
+	<-w.gate
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.buf.Write(p)
+}
+
+func (w *gatedWriter) String() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.buf.String()
+}
+
+// TestHandler_WritesAndCloseFlushes tests that every record is written
+// by the time Close returns, with derived handlers sharing the queue
+func TestHandler_WritesAndCloseFlushes(t *testing.T) {
+	w := &gatedWriter{gate: make(chan struct{})}
+	close(w.gate)
+	h := New(slog.NewTextHandler(w, nil), 100)
+	log := slog.New(h)
+
+	log.Info("first", "n", 1)
+	log.With("req", "abc").WithGroup("g").Info("second", "n", 2)
+	if err := h.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	out := w.String()
+	if !strings.Contains(out, "msg=first n=1") || !strings.Contains(out, "req=abc g.n=2") {
+		t.Errorf("output = %q", out)
+	}
+	if s := h.Stats(); s.Enqueued != 2 || s.Written != 2 || s.Dropped != 0 {
+		t.Errorf("Stats() = %+v", s)
+	}
+
+	log.Info("after close")
+	if s := h.Stats(); s.Dropped != 1 {
+		t.Errorf("Dropped after Close = %d, want 1", s.Dropped)
+	}
+}
+
+// TestHandler_NeverBlocks tests that a stuck writer causes drops, not
+// stalls, under both drop policies
+func TestHandler_NeverBlocks(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    []Option
+		keep    string
+		dropped string
+	}{
+		{"drop newest", nil, "msg=m1\n", "msg=m9\n"},
+		{"drop oldest", []Option{WithDropOldest()}, "msg=m9\n", "msg=m2\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := &gatedWriter{gate: make(chan struct{})}
+			h := New(slog.NewTextHandler(w, nil), 3, tt.opts...)
+			log := slog.New(h)
+
+			log.Info("m0") // taken by the writer, which then blocks
+			time.Sleep(20 * time.Millisecond)
+
+			start := time.Now()
+			for _, m := range []string{"m1", "m2", "m3", "m4", "m5", "m6", "m7", "m8", "m9"} {
+				log.Info(m)
+			}
+			if d := time.Since(start); d > 100*time.Millisecond {
+				t.Errorf("logging took %v with a stuck writer", d)
+			}
+
+			close(w.gate)
+			h.Close(context.Background())
+			out := w.String()
+			if !strings.Contains(out, tt.keep) || strings.Contains(out, tt.dropped) {
+				t.Errorf("output = %q, want %q kept and %q dropped", out, tt.keep, tt.dropped)
+			}
+			if s := h.Stats(); s.Dropped != 6 || s.Written != 4 {
+				t.Errorf("Stats() = %+v, want 6 dropped, 4 written", s)
+			}
+		})
+	}
+}
+
+// TestHandler_Flush tests waiting for the queue and giving up on ctx
+func TestHandler_Flush(t *testing.T) {
+	w := &gatedWriter{gate: make(chan struct{})}
+	h := New(slog.NewTextHandler(w, nil), 10)
+	slog.New(h).Info("pending")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := h.Flush(ctx); err != context.DeadlineExceeded {
+		t.Errorf("Flush() with stuck writer = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	close(w.gate)
+	if err := h.Flush(context.Background()); err != nil {
+		t.Errorf("Flush() = %v, want nil", err)
+	}
+	if !strings.Contains(w.String(), "pending") {
+		t.Error("record not written after Flush")
+	}
+	h.Close(context.Background())
+}
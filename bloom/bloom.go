@@ -0,0 +1,99 @@
+package bloom
+
+import (
+	"hash/fnv"
+	"math"
+	"sync/atomic"
+)
+
+// Filter is a Bloom filter: a compact set that may report false
+// positives but never false negatives. It is safe for concurrent use;
+// bits are set with atomic compare-and-swap.
+type Filter struct {
+	bits []atomic.Uint64
+	m    uint64 // number of bits
+	k    uint64 // number of hash functions
+}
+
+// New sizes a filter for n expected items at false-positive rate p.
+func New(n int, p float64) *Filter {
+	n = max(n, 1)
+	if p <= 0 || p >= 1 {
+		p = 0.01
+	}
+
+	// standard sizing: m = -n ln p / (ln 2)^2, k = m/n ln 2
+	m := uint64(math.Ceil(-float64(n) * math.Log(p) / (math.Ln2 * math.Ln2)))
+	k := uint64(math.Max(1, math.Round(float64(m)/float64(n)*math.Ln2)))
+	m = (m + 63) &^ 63 // round up to whole words
+
+	return &Filter{
+		bits: make([]atomic.Uint64, m/64),
+		m:    m,
+		k:    k,
+	}
+}
+
+// hashes returns two independent 64-bit hashes of data; the k probe
+// positions are derived from them (Kirsch–Mitzenmacher double hashing).
+func hashes(data []byte) (uint64, uint64) {
+	h := fnv.New64a()
+	h.Write(data)
+	h1 := h.Sum64()
+
+	h.Reset()
+	h.Write([]byte{0x9e})
+	h.Write(data)
+	h2 := h.Sum64() | 1 // odd, so probes cycle through all bits
+	return h1, h2
+}
+
+// Add inserts data into the filter.
+func (f *Filter) Add(data []byte) {
+	f.TestAndAdd(data)
+}
+
+// Test reports whether data may be in the filter.
+func (f *Filter) Test(data []byte) bool {
+	h1, h2 := hashes(data)
+	for i := range f.k {
+		bit := (h1 + i*h2) % f.m
+		if f.bits[bit/64].Load()&(1<<(bit%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// TestAndAdd inserts data and reports whether it may have been present
+// before. Concurrent calls for the same item may both report false, so
+// exact "visit once" deduplication needs callers to serialize on the item.
+func (f *Filter) TestAndAdd(data []byte) bool {
+	h1, h2 := hashes(data)
+	present := true
+	for i := range f.k {
+		bit := (h1 + i*h2) % f.m
+		word, mask := &f.bits[bit/64], uint64(1)<<(bit%64)
+		for {
+			old := word.Load()
+			if old&mask != 0 {
+				break
+			}
+			if word.CompareAndSwap(old, old|mask) {
+				present = false
+				break
+			}
+		}
+	}
+	return present
+}
+
+// Cap returns the number of bits in the filter.
+func (f *Filter) Cap() uint64 {
+	return f.m
+}
+
+// K returns the number of hash functions used per item.
+func (f *Filter) K() uint64 {
+	return f.k
+}
@@ -0,0 +1,73 @@
+package bloom
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// TestFilter_NoFalseNegatives tests that every added item is reported present
+func TestFilter_NoFalseNegatives(t *testing.T) {
+	f := New(1000, 0.01)
+	for i := range 1000 {
+		f.Add([]byte(strconv.Itoa(i)))
+	}
+	for i := range 1000 {
+		if !f.Test([]byte(strconv.Itoa(i))) {
+			t.Fatalf("item %d missing", i)
+		}
+	}
+}
+
+// TestFilter_FalsePositiveRate tests that the observed rate is near the target
+func TestFilter_FalsePositiveRate(t *testing.T) {
+	const n = 10_000
+	f := New(n, 0.01)
+	for i := range n {
+		f.Add([]byte("in-" + strconv.Itoa(i)))
+	}
+
+	fp := 0
+	for i := range n {
+		if f.Test([]byte("out-" + strconv.Itoa(i))) {
+			fp++
+		}
+	}
+	if rate := float64(fp) / n; rate > 0.03 {
+		t.Errorf("false positive rate = %.4f, want <= 0.03", rate)
+	}
+}
+
+// TestFilter_ConcurrentAdd tests that concurrent writers lose no bits
+func TestFilter_ConcurrentAdd(t *testing.T) {
+	f := New(8000, 0.01)
+
+	var wg sync.WaitGroup
+	for g := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range 1000 {
+				f.Add([]byte(strconv.Itoa(g*1000 + i)))
+			}
+		}()
+	}
+	wg.Wait()
+
+	for i := range 8000 {
+		if !f.Test([]byte(strconv.Itoa(i))) {
+			t.Fatalf("item %d missing", i)
+		}
+	}
+}
+
+// TestFilter_TestAndAdd tests that the first insert reports absence
+func TestFilter_TestAndAdd(t *testing.T) {
+	f := New(100, 0.001)
+	if f.TestAndAdd([]byte("x")) {
+		t.Error("first TestAndAdd() = true, want false")
+	}
+	if !f.TestAndAdd([]byte("x")) {
+		t.Error("second TestAndAdd() = false, want true")
+	}
+}
@@ -0,0 +1,162 @@
+// bounded_memory/bounded_memory.go
+package main
+
+import (
+	"context"
+	"hash/crc32"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/arifmahmudrana/go-snippets/semaphore"
+)
+
+// Config describes a producer/consumer run.
+type Config struct {
+	Producers    int
+	Consumers    int
+	Items        int           // total payloads to produce
+	MinSize      int           // smallest payload in bytes
+	MaxSize      int           // largest payload in bytes
+	Budget       int64         // max bytes in flight (0 = no byte limit)
+	QueueLen     int           // channel capacity, in items
+	ConsumeDelay time.Duration // simulated work per payload
+	Seed         int64
+}
+
+// Stats summarises a run.
+type Stats struct {
+	Produced, Consumed int
+	Bytes              int64
+	PeakInFlight       int64 // max bytes allocated but not yet consumed
+	Checksum           uint32
+}
+
+// payload is one unit of work; its bytes count against the budget from
+// the moment they are allocated until a consumer releases them.
+type payload struct {
+	data   []byte
+	credit int64
+}
+
+// tracker records bytes in flight and their high-water mark.
+type tracker struct {
+	cur, peak atomic.Int64
+}
+
+func (t *tracker) add(n int64) {
+	cur := t.cur.Add(n)
+	for {
+		p := t.peak.Load()
+		if cur <= p || t.peak.CompareAndSwap(p, cur) {
+			return
+		}
+	}
+}
+
+// run executes the pipeline. With a Budget, producers must acquire
+// credit for a payload's size *before* allocating it, and consumers give
+// the credit back once they are done — so memory is bounded in bytes, not
+// just in number of queued items.
+func run(ctx context.Context, cfg Config) Stats {
+	var sem *semaphore.Weighted
+	if cfg.Budget > 0 {
+		sem = semaphore.NewWeighted(cfg.Budget)
+	}
+
+	queue := make(chan payload, cfg.QueueLen)
+	var inflight tracker
+	var produced atomic.Int64
+	next := make(chan int) // hands out item numbers to producers
+
+	go func() {
+		defer close(next)
+		for i := range cfg.Items {
+			select {
+			case <-ctx.Done():
+				return
+			case next <- i:
+			}
+		}
+	}()
+
+	// producers
+	var pwg sync.WaitGroup
+	for range cfg.Producers {
+		pwg.Add(1)
+		go func() {
+			defer pwg.Done()
+
+			for i := range next {
+				// seeding per item keeps payloads identical across runs,
+				// whichever producer happens to build them
+				rng := rand.New(rand.NewSource(cfg.Seed + int64(i)))
+				size := cfg.MinSize + rng.Intn(cfg.MaxSize-cfg.MinSize+1)
+
+				// payloads bigger than the budget are charged the whole
+				// budget, so they still flow, just one at a time
+				credit := int64(size)
+				if sem != nil {
+					credit = min(credit, cfg.Budget)
+					if err := sem.Acquire(ctx, credit); err != nil {
+						return
+					}
+				}
+
+				data := make([]byte, size)
+				rng.Read(data)
+				inflight.add(int64(size))
+
+				select {
+				case <-ctx.Done():
+					inflight.add(-int64(size))
+					if sem != nil {
+						sem.Release(credit)
+					}
+					return
+				case queue <- payload{data: data, credit: credit}:
+					produced.Add(1)
+				}
+			}
+		}()
+	}
+	go func() {
+		pwg.Wait()
+		close(queue)
+	}()
+
+	// consumers
+	var mu sync.Mutex
+	var stats Stats
+	var cwg sync.WaitGroup
+	for range cfg.Consumers {
+		cwg.Add(1)
+		go func() {
+			defer cwg.Done()
+			for p := range queue {
+				sum := crc32.ChecksumIEEE(p.data)
+				time.Sleep(cfg.ConsumeDelay)
+
+				mu.Lock()
+				stats.Consumed++
+				stats.Bytes += int64(len(p.data))
+				stats.Checksum ^= sum
+				mu.Unlock()
+
+				// drop our reference, then return the credit
+				n := int64(len(p.data))
+				p.data = nil
+				inflight.add(-n)
+				if sem != nil {
+					sem.Release(p.credit)
+				}
+			}
+		}()
+	}
+	cwg.Wait()
+
+	stats.Produced = int(produced.Load())
+	stats.PeakInFlight = inflight.peak.Load()
+	return stats
+}
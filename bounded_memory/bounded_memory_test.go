@@ -0,0 +1,84 @@
+// bounded_memory/bounded_memory_test.go
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func testConfig() Config {
+	return Config{
+		Producers: 4,
+		Consumers: 2,
+		Items:     100,
+		MinSize:   1 << 10,
+		MaxSize:   64 << 10,
+		QueueLen:  32,
+		Seed:      7,
+	}
+}
+
+// TestRun_BudgetRespected tests that bytes in flight never exceed the budget
+func TestRun_BudgetRespected(t *testing.T) {
+	cfg := testConfig()
+	cfg.Budget = 128 << 10
+	cfg.ConsumeDelay = 100 * time.Microsecond
+
+	s := run(context.Background(), cfg)
+
+	if s.Consumed != cfg.Items || s.Produced != cfg.Items {
+		t.Errorf("produced %d, consumed %d, want %d", s.Produced, s.Consumed, cfg.Items)
+	}
+	if s.PeakInFlight > cfg.Budget {
+		t.Errorf("peak in flight = %d, want <= %d", s.PeakInFlight, cfg.Budget)
+	}
+}
+
+// TestRun_SameOutputWithAndWithoutBudget tests that bounding memory changes no results
+func TestRun_SameOutputWithAndWithoutBudget(t *testing.T) {
+	free := run(context.Background(), testConfig())
+
+	cfg := testConfig()
+	cfg.Budget = 100 << 10
+	bounded := run(context.Background(), cfg)
+
+	if free.Checksum != bounded.Checksum || free.Bytes != bounded.Bytes {
+		t.Errorf("unbounded = %+v, bounded = %+v", free, bounded)
+	}
+}
+
+// TestRun_OversizedPayload tests that a payload larger than the budget still flows
+func TestRun_OversizedPayload(t *testing.T) {
+	cfg := testConfig()
+	cfg.Items = 5
+	cfg.MinSize, cfg.MaxSize = 10<<10, 10<<10
+	cfg.Budget = 4 << 10
+
+	done := make(chan Stats)
+	go func() { done <- run(context.Background(), cfg) }()
+
+	select {
+	case s := <-done:
+		if s.Consumed != cfg.Items {
+			t.Errorf("consumed %d, want %d", s.Consumed, cfg.Items)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("run deadlocked on oversized payloads")
+	}
+}
+
+// TestRun_Cancelled tests that cancellation stops producers blocked on credit
+func TestRun_Cancelled(t *testing.T) {
+	cfg := testConfig()
+	cfg.Budget = 64 << 10
+	cfg.ConsumeDelay = 10 * time.Millisecond
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	s := run(ctx, cfg)
+	if s.Consumed >= cfg.Items {
+		t.Errorf("consumed %d items despite cancellation", s.Consumed)
+	}
+}
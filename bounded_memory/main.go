@@ -0,0 +1,51 @@
+// bounded_memory/main.go
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"time"
+)
+
+func main() {
+	items := flag.Int("items", 200, "payloads to produce")
+	minKB := flag.Int("min", 64, "smallest payload in KiB")
+	maxKB := flag.Int("max", 4096, "largest payload in KiB")
+	budgetMB := flag.Int64("budget", 16, "max MiB in flight")
+	flag.Parse()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	base := Config{
+		Producers:    4,
+		Consumers:    2,
+		Items:        *items,
+		MinSize:      *minKB << 10,
+		MaxSize:      *maxKB << 10,
+		QueueLen:     32,
+		ConsumeDelay: 2 * time.Millisecond,
+		Seed:         42,
+	}
+
+	// 1. the usual approach: a buffered channel bounds *items*, not bytes
+	countOnly := base
+	start := time.Now()
+	s1 := run(ctx, countOnly)
+	fmt.Printf("[COUNT] queue=%d items, budget=none   peak in flight=%7.1f MiB, took %v\n",
+		base.QueueLen, float64(s1.PeakInFlight)/(1<<20), time.Since(start).Round(time.Millisecond))
+
+	// 2. byte-weighted credit: producers acquire size before allocating
+	byteBounded := base
+	byteBounded.Budget = *budgetMB << 20
+	start = time.Now()
+	s2 := run(ctx, byteBounded)
+	fmt.Printf("[BYTES] queue=%d items, budget=%d MiB peak in flight=%7.1f MiB, took %v\n",
+		base.QueueLen, *budgetMB, float64(s2.PeakInFlight)/(1<<20), time.Since(start).Round(time.Millisecond))
+
+	if s1.Checksum == s2.Checksum && s1.Consumed == s2.Consumed {
+		fmt.Printf("[MAIN] Both runs consumed %d payloads (%.1f MiB) with identical checksums.\n",
+			s2.Consumed, float64(s2.Bytes)/(1<<20))
+	}
+}
@@ -0,0 +1,125 @@
+package chanrecord
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"time"
+)
+
+// Entry is one recorded value. Offset is measured from the start of the
+// recording, so a replay does not depend on the wall clock.
+type Entry[T any] struct {
+	Offset time.Duration `json:"offset"`
+	Time   time.Time     `json:"time"`
+	Value  T             `json:"value"`
+}
+
+// Tee forwards every value from in to the returned channel and records
+// it, with a timestamp, as one JSON line in w. The output channel is
+// closed when in is closed.
+//
+// Recording never blocks or breaks the consumer: if writing fails, values
+// keep flowing and the first write error is returned by wait, which
+// blocks until in is closed and drained.
+func Tee[T any](in <-chan T, w io.Writer) (out <-chan T, wait func() error) {
+	ch := make(chan T)
+	done := make(chan struct{})
+	var werr error
+
+	go func() {
+		defer close(done)
+		defer close(ch)
+
+		bw := bufio.NewWriter(w)
+		enc := json.NewEncoder(bw)
+		start := time.Now()
+
+		for v := range in {
+			if werr == nil {
+				now := time.Now()
+				werr = enc.Encode(Entry[T]{Offset: now.Sub(start), Time: now, Value: v})
+				if werr == nil {
+					// flush per entry so a crash loses at most one value
+					werr = bw.Flush()
+				}
+			}
+			ch <- v
+		}
+	}()
+
+	return ch, func() error {
+		<-done
+		return werr
+	}
+}
+
+// ReadAll decodes a whole recording.
+func ReadAll[T any](r io.Reader) ([]Entry[T], error) {
+	var entries []Entry[T]
+	dec := json.NewDecoder(r)
+	for {
+		var e Entry[T]
+		if err := dec.Decode(&e); err == io.EOF {
+			return entries, nil
+		} else if err != nil {
+			return entries, err
+		}
+		entries = append(entries, e)
+	}
+}
+
+// Replay emits the values recorded in r on the returned channel,
+// reproducing the original gaps between them divided by speed: 1 is real
+// time, 10 is ten times faster, and 0 (or less) sends as fast as the
+// receiver reads. The channel is closed at the end of the recording or
+// when ctx is done; wait then returns any decode error or ctx.Err().
+func Replay[T any](ctx context.Context, r io.Reader, speed float64) (values <-chan T, wait func() error) {
+	ch := make(chan T)
+	done := make(chan struct{})
+	var rerr error
+
+	go func() {
+		defer close(done)
+		defer close(ch)
+
+		dec := json.NewDecoder(r)
+		start := time.Now()
+		for {
+			var e Entry[T]
+			if err := dec.Decode(&e); err == io.EOF {
+				return
+			} else if err != nil {
+				rerr = err
+				return
+			}
+
+			if speed > 0 {
+				due := start.Add(time.Duration(float64(e.Offset) / speed))
+				if d := time.Until(due); d > 0 {
+					timer := time.NewTimer(d)
+					select {
+					case <-ctx.Done():
+						timer.Stop()
+						rerr = ctx.Err()
+						return
+					case <-timer.C:
+					}
+				}
+			}
+
+			select {
+			case <-ctx.Done():
+				rerr = ctx.Err()
+				return
+			case ch <- e.Value:
+			}
+		}
+	}()
+
+	return ch, func() error {
+		<-done
+		return rerr
+	}
+}
@@ -0,0 +1,142 @@
+package chanrecord
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type event struct {
+	Topic string `json:"topic"`
+	N     int    `json:"n"`
+}
+
+// record sends values through Tee with the given gap and returns the log.
+func record(t *testing.T, values []event, gap time.Duration) *bytes.Buffer {
+	t.Helper()
+
+	var buf bytes.Buffer
+	in := make(chan event)
+	out, wait := Tee(in, &buf)
+
+	go func() {
+		defer close(in)
+		for _, v := range values {
+			in <- v
+			time.Sleep(gap)
+		}
+	}()
+
+	var got []event
+	for v := range out {
+		got = append(got, v)
+	}
+	if err := wait(); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, values) {
+		t.Fatalf("Tee forwarded %v, want %v", got, values)
+	}
+	return &buf
+}
+
+var sample = []event{{"news", 1}, {"sports", 2}, {"news", 3}}
+
+// TestTee_RecordsEveryValue tests that the log holds all values with increasing offsets
+func TestTee_RecordsEveryValue(t *testing.T) {
+	buf := record(t, sample, time.Millisecond)
+
+	entries, err := ReadAll[event](buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != len(sample) {
+		t.Fatalf("recorded %d entries, want %d", len(entries), len(sample))
+	}
+	for i, e := range entries {
+		if e.Value != sample[i] {
+			t.Errorf("entry %d = %v, want %v", i, e.Value, sample[i])
+		}
+		if i > 0 && e.Offset <= entries[i-1].Offset {
+			t.Errorf("entry %d offset %v not after %v", i, e.Offset, entries[i-1].Offset)
+		}
+	}
+}
+
+// errWriter fails every write.
+type errWriter struct{}
+
+func (errWriter) Write(p []byte) (int, error) { return 0, errors.New("disk full") }
+
+// TestTee_WriteErrorDoesNotBlock tests that a broken sink never stalls the consumer
+func TestTee_WriteErrorDoesNotBlock(t *testing.T) {
+	in := make(chan int, 3)
+	in <- 1
+	in <- 2
+	in <- 3
+	close(in)
+
+	out, wait := Tee(in, errWriter{})
+	n := 0
+	for range out {
+		n++
+	}
+	if n != 3 {
+		t.Errorf("forwarded %d values, want 3", n)
+	}
+	if err := wait(); err == nil {
+		t.Error("wait() = nil, want the write error")
+	}
+}
+
+// TestReplay_Speeds tests replay timing at real, accelerated and unlimited speed
+func TestReplay_Speeds(t *testing.T) {
+	buf := record(t, sample, 30*time.Millisecond) // ~60ms between first and last
+	data := buf.Bytes()
+
+	replay := func(speed float64) ([]event, time.Duration) {
+		start := time.Now()
+		ch, wait := Replay[event](context.Background(), bytes.NewReader(data), speed)
+		var got []event
+		for v := range ch {
+			got = append(got, v)
+		}
+		if err := wait(); err != nil {
+			t.Fatal(err)
+		}
+		return got, time.Since(start)
+	}
+
+	got, realTime := replay(1)
+	if !reflect.DeepEqual(got, sample) {
+		t.Errorf("replay = %v, want %v", got, sample)
+	}
+	if realTime < 50*time.Millisecond {
+		t.Errorf("real-time replay took %v, want >= 50ms", realTime)
+	}
+
+	if _, fast := replay(0); fast > realTime/2 {
+		t.Errorf("unthrottled replay took %v, real time %v", fast, realTime)
+	}
+	if _, accel := replay(10); accel > realTime/2 {
+		t.Errorf("10x replay took %v, real time %v", accel, realTime)
+	}
+}
+
+// TestReplay_Cancelled tests that replay stops with the context
+func TestReplay_Cancelled(t *testing.T) {
+	buf := record(t, sample, 50*time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	ch, wait := Replay[event](ctx, buf, 1)
+	for range ch {
+	}
+	if err := wait(); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("wait() = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
@@ -0,0 +1,42 @@
+package chanrecord_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	"github.com/arifmahmudrana/go-snippets/chanrecord"
+	"github.com/arifmahmudrana/go-snippets/pubsub"
+)
+
+// Record what a broker subscriber receives, then replay it later without
+// the broker — e.g. to reproduce a consumer bug deterministically.
+func Example() {
+	broker := pubsub.NewBroker()
+	defer broker.Stop()
+
+	sub := broker.Subscribe("news")
+	var log bytes.Buffer
+	recorded, wait := chanrecord.Tee(sub.C, &log)
+
+	for _, headline := range []string{"first", "second"} {
+		broker.Publish("news", headline)
+		msg := <-recorded
+		fmt.Println("live:", msg.Payload)
+	}
+	broker.Unsubscribe("news", sub)
+	for range recorded {
+	}
+	wait()
+
+	replayed, _ := chanrecord.Replay[pubsub.Message](context.Background(), &log, 0)
+	for msg := range replayed {
+		fmt.Println("replay:", msg.Topic, msg.Payload)
+	}
+
+	// Output:
+	// live: first
+	// live: second
+	// replay: news first
+	// replay: news second
+}
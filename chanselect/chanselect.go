@@ -0,0 +1,93 @@
+package chanselect
+
+import (
+	"context"
+	"errors"
+	"reflect"
+)
+
+// ErrAllClosed is returned by FirstOf when every channel is closed.
+var ErrAllClosed = errors.New("chanselect: all channels closed")
+
+// Select waits on a dynamic set of channels, like a select statement
+// whose cases are only known at run time. It returns the index of the
+// channel that fired and the value received; ok is false if that channel
+// was closed. If ctx is done first, Select returns ctx.Err().
+//
+// Nil channels are never selected, matching the select statement.
+func Select[T any](ctx context.Context, chans []<-chan T) (index int, value T, ok bool, err error) {
+	cases := make([]reflect.SelectCase, len(chans)+1)
+	cases[0] = reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ctx.Done())}
+	for i, ch := range chans {
+		cases[i+1] = reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ch)}
+	}
+
+	chosen, recv, recvOK := reflect.Select(cases)
+	if chosen == 0 {
+		return -1, value, false, ctx.Err()
+	}
+	if recvOK {
+		value = recv.Interface().(T)
+	}
+	return chosen - 1, value, recvOK, nil
+}
+
+// FirstOf returns the first value received from any of chans along with
+// the index of the channel it came from. Closed channels are skipped; if
+// all of them are closed, FirstOf returns ErrAllClosed.
+func FirstOf[T any](ctx context.Context, chans ...<-chan T) (T, int, error) {
+	chans = append([]<-chan T(nil), chans...)
+	open := len(chans)
+	for {
+		var zero T
+		if open == 0 {
+			return zero, -1, ErrAllClosed
+		}
+
+		i, v, ok, err := Select(ctx, chans)
+		if err != nil {
+			return zero, -1, err
+		}
+		if ok {
+			return v, i, nil
+		}
+		chans[i] = nil // closed: never pick it again
+		open--
+	}
+}
+
+// Merge fans values from chans into a single channel, which is closed
+// once every input is closed or ctx is done. Unlike one goroutine per
+// input, Merge uses a single goroutine however many inputs there are.
+func Merge[T any](ctx context.Context, chans ...<-chan T) <-chan T {
+	out := make(chan T)
+	chans = append([]<-chan T(nil), chans...)
+
+	go func() {
+		defer close(out)
+		open := len(chans)
+		for _, ch := range chans {
+			if ch == nil {
+				open--
+			}
+		}
+
+		for open > 0 {
+			i, v, ok, err := Select(ctx, chans)
+			if err != nil {
+				return
+			}
+			if !ok {
+				chans[i] = nil
+				open--
+				continue
+			}
+			select {
+			case out <- v:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
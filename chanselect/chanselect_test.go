@@ -0,0 +1,97 @@
+package chanselect
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+// TestSelect tests value, closed and context cases
+func TestSelect(t *testing.T) {
+	a, b := make(chan int, 1), make(chan int)
+	b2 := make(chan int)
+	close(b2)
+
+	a <- 7
+	i, v, ok, err := Select(context.Background(), []<-chan int{b, a})
+	if i != 1 || v != 7 || !ok || err != nil {
+		t.Errorf("Select() = %d, %d, %v, %v, want 1, 7, true, nil", i, v, ok, err)
+	}
+
+	i, _, ok, err = Select(context.Background(), []<-chan int{b, nil, b2})
+	if i != 2 || ok || err != nil {
+		t.Errorf("Select(closed) = %d, %v, %v, want 2, false, nil", i, ok, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, _, _, err = Select(ctx, []<-chan int{b})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Select(timeout) error = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+// TestFirstOf tests that closed channels are skipped
+func TestFirstOf(t *testing.T) {
+	closed := make(chan string)
+	close(closed)
+	ready := make(chan string, 1)
+	ready <- "hello"
+
+	v, i, err := FirstOf(context.Background(), closed, ready)
+	if v != "hello" || i != 1 || err != nil {
+		t.Errorf("FirstOf() = %q, %d, %v, want %q, 1, nil", v, i, err, "hello")
+	}
+
+	_, _, err = FirstOf(context.Background(), closed, closed)
+	if !errors.Is(err, ErrAllClosed) {
+		t.Errorf("FirstOf(all closed) error = %v, want %v", err, ErrAllClosed)
+	}
+}
+
+// TestMerge tests that every value arrives and the output closes
+func TestMerge(t *testing.T) {
+	var ins []<-chan int
+	for c := range 5 {
+		ch := make(chan int)
+		ins = append(ins, ch)
+		go func() {
+			defer close(ch)
+			for j := range 10 {
+				ch <- c*10 + j
+			}
+		}()
+	}
+
+	var got []int
+	for v := range Merge(context.Background(), ins...) {
+		got = append(got, v)
+	}
+	slices.Sort(got)
+	for i, v := range got {
+		if v != i {
+			t.Fatalf("Merge() values = %v, want 0..49", got)
+		}
+	}
+	if len(got) != 50 {
+		t.Errorf("Merge() got %d values, want 50", len(got))
+	}
+}
+
+// TestMerge_Cancel tests that cancelling ctx closes the output
+func TestMerge_Cancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	out := Merge(ctx, make(chan int))
+	cancel()
+
+	select {
+	case _, ok := <-out:
+		if ok {
+			t.Error("received a value, want closed channel")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Merge() output not closed after cancel")
+	}
+}
@@ -0,0 +1,33 @@
+package chanselect_test
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/arifmahmudrana/go-snippets/chanselect"
+	"github.com/arifmahmudrana/go-snippets/pubsub"
+)
+
+// ExampleFirstOf waits for the first message on any of several topics.
+func ExampleFirstOf() {
+	broker := pubsub.NewBroker()
+	sports := broker.Subscribe("sports")
+	news := broker.Subscribe("news")
+
+	broker.Publish("news", "election results")
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	msg, _, err := chanselect.FirstOf(ctx, sports.C, news.C)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Println(msg.Topic, msg.Payload)
+
+	broker.Unsubscribe("sports", sports)
+	broker.Unsubscribe("news", news)
+	broker.Stop()
+	// Output: news election results
+}
@@ -0,0 +1,154 @@
+package chaos
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// ErrInjected is the error returned for injected failures. Code under
+// test should treat it like any other I/O error.
+var ErrInjected = errors.New("chaos: injected failure")
+
+// Plan describes which faults to inject and how often. Rates are
+// probabilities in [0, 1] applied independently to every call.
+type Plan struct {
+	Seed int64 // same seed and call order give the same faults
+
+	ErrorRate float64 // fail the call with ErrInjected
+
+	LatencyRate float64       // delay the call...
+	MaxLatency  time.Duration // ...by a uniform random duration up to this
+
+	PartialWriteRate float64 // write only a prefix of the data, then fail
+}
+
+// Stats counts the faults injected so far.
+type Stats struct {
+	Calls, Errors, Delays, PartialWrites int
+}
+
+// Injector decides, call by call, which faults to inject according to a
+// Plan. It is safe for concurrent use; with concurrent callers the fault
+// sequence is still drawn from one seeded source, but which call gets
+// which fault depends on scheduling.
+type Injector struct {
+	plan Plan
+
+	mu    sync.Mutex
+	rng   *rand.Rand
+	stats Stats
+}
+
+// New creates an Injector for plan.
+func New(plan Plan) *Injector {
+	return &Injector{plan: plan, rng: rand.New(rand.NewSource(plan.Seed))}
+}
+
+// Stats returns the injected-fault counters.
+func (in *Injector) Stats() Stats {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	return in.stats
+}
+
+// decision is the set of faults chosen for one call.
+type decision struct {
+	delay   time.Duration
+	fail    bool
+	partial float64 // fraction of data to write, or 0 for none
+}
+
+func (in *Injector) decide(write bool) decision {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	in.stats.Calls++
+
+	var d decision
+	if in.plan.MaxLatency > 0 && in.rng.Float64() < in.plan.LatencyRate {
+		d.delay = time.Duration(in.rng.Int63n(int64(in.plan.MaxLatency)) + 1)
+		in.stats.Delays++
+	}
+	if write && in.rng.Float64() < in.plan.PartialWriteRate {
+		d.partial = in.rng.Float64()
+		in.stats.PartialWrites++
+		return d
+	}
+	if in.rng.Float64() < in.plan.ErrorRate {
+		d.fail = true
+		in.stats.Errors++
+	}
+	return d
+}
+
+// sleep waits out d.delay unless ctx is done first.
+func (d decision) sleep(ctx context.Context) error {
+	if d.delay == 0 {
+		return nil
+	}
+	t := time.NewTimer(d.delay)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Fault applies the plan to one call named op: it may sleep and may
+// return an error wrapping ErrInjected. Use it to instrument code paths
+// that have no wrapper here.
+func (in *Injector) Fault(ctx context.Context, op string) error {
+	d := in.decide(false)
+	if err := d.sleep(ctx); err != nil {
+		return err
+	}
+	if d.fail {
+		return fmt.Errorf("%s: %w", op, ErrInjected)
+	}
+	return nil
+}
+
+// Handler wraps fn so that each call may be delayed or fail before fn
+// runs. It fits eventbus handlers and any func(ctx, T) error.
+func Handler[T any](in *Injector, fn func(ctx context.Context, v T) error) func(ctx context.Context, v T) error {
+	return func(ctx context.Context, v T) error {
+		if err := in.Fault(ctx, "handler"); err != nil {
+			return err
+		}
+		return fn(ctx, v)
+	}
+}
+
+// Writer wraps w for sinks and bridges. A partial write writes a prefix
+// of p to w and then reports ErrInjected, like a connection dropping
+// mid-message.
+func (in *Injector) Writer(w io.Writer) io.Writer {
+	return &writer{in: in, w: w}
+}
+
+type writer struct {
+	in *Injector
+	w  io.Writer
+}
+
+func (cw *writer) Write(p []byte) (int, error) {
+	d := cw.in.decide(true)
+	d.sleep(context.Background())
+	switch {
+	case d.partial > 0:
+		n, err := cw.w.Write(p[:int(d.partial*float64(len(p)))])
+		if err != nil {
+			return n, err
+		}
+		return n, fmt.Errorf("write: %w", ErrInjected)
+	case d.fail:
+		return 0, fmt.Errorf("write: %w", ErrInjected)
+	}
+	return cw.w.Write(p)
+}
@@ -0,0 +1,93 @@
+package chaos
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/arifmahmudrana/go-snippets/store"
+)
+
+// TestInjector_Deterministic tests that a seed reproduces the same faults
+func TestInjector_Deterministic(t *testing.T) {
+	run := func() []bool {
+		in := New(Plan{Seed: 7, ErrorRate: 0.3})
+		var out []bool
+		for range 50 {
+			out = append(out, in.Fault(context.Background(), "op") != nil)
+		}
+		return out
+	}
+	a, b := run(), run()
+	for i := range a {
+		if a[i] != b[i] {
+			t.Fatalf("call %d differs between runs with the same seed", i)
+		}
+	}
+}
+
+// TestInjector_Rates tests that rates of 0 and 1 behave as absolute
+func TestInjector_Rates(t *testing.T) {
+	in := New(Plan{ErrorRate: 1})
+	if err := in.Fault(context.Background(), "get"); !errors.Is(err, ErrInjected) {
+		t.Errorf("Fault() = %v, want %v", err, ErrInjected)
+	}
+
+	in = New(Plan{LatencyRate: 1, MaxLatency: 5 * time.Millisecond})
+	if err := in.Fault(context.Background(), "get"); err != nil {
+		t.Errorf("Fault() = %v, want nil", err)
+	}
+	if s := in.Stats(); s.Calls != 1 || s.Delays != 1 || s.Errors != 0 {
+		t.Errorf("Stats() = %+v", s)
+	}
+}
+
+// TestInjector_Writer tests partial writes
+func TestInjector_Writer(t *testing.T) {
+	var buf bytes.Buffer
+	w := New(Plan{PartialWriteRate: 1}).Writer(&buf)
+
+	n, err := w.Write([]byte("hello, world"))
+	if !errors.Is(err, ErrInjected) || n >= 12 || buf.Len() != n {
+		t.Errorf("Write() = %d, %v with %q buffered", n, err, buf.String())
+	}
+}
+
+// TestStore_AcknowledgedWritesSurvive tests the invariant a caller can
+// rely on under chaos: every Put that returned nil is readable intact
+func TestStore_AcknowledgedWritesSurvive(t *testing.T) {
+	ctx := context.Background()
+	backend := store.NewMemory()
+	in := New(Plan{Seed: 1, ErrorRate: 0.2, PartialWriteRate: 0.2, LatencyRate: 0.1, MaxLatency: time.Millisecond})
+	s := in.Store(backend)
+
+	acked := map[string]string{}
+	for i := range 200 {
+		key, value := fmt.Sprintf("k%d", i%20), fmt.Sprintf("value-%d", i)
+		if s.Put(ctx, key, []byte(value)) == nil {
+			acked[key] = value
+		} else {
+			delete(acked, key) // a failed overwrite may have torn the old value
+		}
+	}
+
+	st := in.Stats()
+	if st.Errors == 0 || st.PartialWrites == 0 {
+		t.Fatalf("Stats() = %+v, want some errors and partial writes", st)
+	}
+	Assert(t, Invariant{
+		Name: "acknowledged writes are intact",
+		Check: func() error {
+			for key, want := range acked {
+				got, err := backend.Get(ctx, key)
+				if err != nil || string(got) != want {
+					return fmt.Errorf("%s = %q, %v, want %q", key, got, err, want)
+				}
+			}
+			return nil
+		},
+	})
+}
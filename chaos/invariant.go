@@ -0,0 +1,45 @@
+package chaos
+
+import (
+	"testing"
+	"time"
+)
+
+// Invariant is a named property that must hold after a chaotic run.
+type Invariant struct {
+	Name  string
+	Check func() error
+}
+
+// Assert reports every failing invariant on t, so one run shows all the
+// damage instead of stopping at the first.
+func Assert(t testing.TB, invariants ...Invariant) {
+	t.Helper()
+	for _, inv := range invariants {
+		if err := inv.Check(); err != nil {
+			t.Errorf("invariant %q violated: %v", inv.Name, err)
+		}
+	}
+}
+
+// Eventually retries the invariants until all hold or timeout passes,
+// for systems that converge asynchronously (retries, redelivery), and
+// then reports the ones still failing.
+func Eventually(t testing.TB, timeout time.Duration, invariants ...Invariant) {
+	t.Helper()
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		ok := true
+		for _, inv := range invariants {
+			if inv.Check() != nil {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	Assert(t, invariants...)
+}
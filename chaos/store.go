@@ -0,0 +1,59 @@
+package chaos
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/arifmahmudrana/go-snippets/store"
+)
+
+// Store wraps s so every operation may be delayed or fail. A partial
+// write stores a truncated value and then reports failure, modelling a
+// backend without atomic writes; callers must not trust a value whose
+// Put returned an error.
+func (in *Injector) Store(s store.Store) store.Store {
+	return &chaosStore{in: in, s: s}
+}
+
+type chaosStore struct {
+	in *Injector
+	s  store.Store
+}
+
+func (c *chaosStore) Get(ctx context.Context, key string) ([]byte, error) {
+	if err := c.in.Fault(ctx, "get"); err != nil {
+		return nil, err
+	}
+	return c.s.Get(ctx, key)
+}
+
+func (c *chaosStore) Put(ctx context.Context, key string, value []byte) error {
+	d := c.in.decide(true)
+	if err := d.sleep(ctx); err != nil {
+		return err
+	}
+	switch {
+	case d.partial > 0:
+		if err := c.s.Put(ctx, key, value[:int(d.partial*float64(len(value)))]); err != nil {
+			return err
+		}
+		return fmt.Errorf("put: %w", ErrInjected)
+	case d.fail:
+		return fmt.Errorf("put: %w", ErrInjected)
+	}
+	return c.s.Put(ctx, key, value)
+}
+
+func (c *chaosStore) Delete(ctx context.Context, key string) error {
+	if err := c.in.Fault(ctx, "delete"); err != nil {
+		return err
+	}
+	return c.s.Delete(ctx, key)
+}
+
+func (c *chaosStore) List(ctx context.Context, prefix string) ([]string, error) {
+	if err := c.in.Fault(ctx, "list"); err != nil {
+		return nil, err
+	}
+	return c.s.List(ctx, prefix)
+}
@@ -0,0 +1,170 @@
+// chat/chat_test.go
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/arifmahmudrana/go-snippets/pubsub"
+)
+
+// testClient is a minimal WebSocket client used to drive the server.
+type testClient struct {
+	conn net.Conn
+	br   *bufio.Reader
+}
+
+func dial(t *testing.T, srv *httptest.Server, room, name string) *testClient {
+	t.Helper()
+
+	conn, err := net.Dial("tcp", strings.TrimPrefix(srv.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	key := "dGhlIHNhbXBsZSBub25jZQ=="
+	fmt.Fprintf(conn, "GET /ws?room=%s&name=%s HTTP/1.1\r\nHost: x\r\n"+
+		"Upgrade: websocket\r\nConnection: Upgrade\r\n"+
+		"Sec-WebSocket-Key: %s\r\nSec-WebSocket-Version: 13\r\n\r\n", room, name, key)
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("handshake status = %d, want 101", resp.StatusCode)
+	}
+	if got, want := resp.Header.Get("Sec-WebSocket-Accept"), acceptKey(key); got != want {
+		t.Fatalf("Sec-WebSocket-Accept = %q, want %q", got, want)
+	}
+	return &testClient{conn: conn, br: br}
+}
+
+// send writes a masked text frame, as browsers do.
+func (c *testClient) send(t *testing.T, text string) {
+	t.Helper()
+	mask := [4]byte{1, 2, 3, 4}
+	frame := []byte{0x81, 0x80 | byte(len(text))}
+	frame = append(frame, mask[:]...)
+	for i := range len(text) {
+		frame = append(frame, text[i]^mask[i%4])
+	}
+	if _, err := c.conn.Write(frame); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// next reads events until one of type typ arrives.
+func (c *testClient) next(t *testing.T, typ string) event {
+	t.Helper()
+	c.conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for {
+		var hdr [2]byte
+		if _, err := io.ReadFull(c.br, hdr[:]); err != nil {
+			t.Fatalf("waiting for %q: %v", typ, err)
+		}
+		n := int(hdr[1] & 0x7F)
+		if n == 126 {
+			var ext [2]byte
+			io.ReadFull(c.br, ext[:])
+			n = int(binary.BigEndian.Uint16(ext[:]))
+		}
+		payload := make([]byte, n)
+		if _, err := io.ReadFull(c.br, payload); err != nil {
+			t.Fatal(err)
+		}
+
+		var ev event
+		if err := json.Unmarshal(payload, &ev); err != nil {
+			t.Fatal(err)
+		}
+		if ev.Type == typ {
+			return ev
+		}
+	}
+}
+
+// TestChat_EndToEnd tests presence, broadcast and history together
+func TestChat_EndToEnd(t *testing.T) {
+	// Cleanups run in reverse: clients disconnect, handlers unsubscribe,
+	// and only then is the broker stopped.
+	broker := pubsub.NewBroker()
+	t.Cleanup(broker.Stop)
+	chat := newServer(broker, 10)
+	t.Cleanup(chat.wait)
+	srv := httptest.NewServer(chat.routes())
+	t.Cleanup(srv.Close)
+
+	alice := dial(t, srv, "go", "alice")
+	if ev := alice.next(t, "join"); !reflect.DeepEqual(ev.Users, []string{"alice"}) {
+		t.Errorf("users after alice joined = %v", ev.Users)
+	}
+
+	bob := dial(t, srv, "go", "bob")
+	if ev := alice.next(t, "join"); !reflect.DeepEqual(ev.Users, []string{"alice", "bob"}) {
+		t.Errorf("users after bob joined = %v", ev.Users)
+	}
+
+	alice.send(t, "hello gophers")
+	for _, c := range []*testClient{alice, bob} {
+		if ev := c.next(t, "message"); ev.From != "alice" || ev.Text != "hello gophers" {
+			t.Errorf("got message %+v", ev)
+		}
+	}
+
+	// a late joiner sees the history first
+	carol := dial(t, srv, "go", "carol")
+	if ev := carol.next(t, "message"); ev.Text != "hello gophers" {
+		t.Errorf("history replay = %+v", ev)
+	}
+	// and is in the room by then
+	resp, err := http.Get(srv.URL + "/users?room=go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var users []string
+	json.NewDecoder(resp.Body).Decode(&users)
+	resp.Body.Close()
+	if want := []string{"alice", "bob", "carol"}; !reflect.DeepEqual(users, want) {
+		t.Errorf("users after carol's history replay = %v, want %v", users, want)
+	}
+	if ev := alice.next(t, "join"); ev.From != "carol" {
+		t.Errorf("join event = %+v, want carol", ev)
+	}
+
+	// rooms are isolated
+	dave := dial(t, srv, "rust", "dave")
+	if ev := dave.next(t, "join"); !reflect.DeepEqual(ev.Users, []string{"dave"}) {
+		t.Errorf("users in other room = %v", ev.Users)
+	}
+
+	bob.conn.Close()
+	if ev := alice.next(t, "leave"); ev.From != "bob" || !reflect.DeepEqual(ev.Users, []string{"alice", "carol"}) {
+		t.Errorf("leave event = %+v", ev)
+	}
+}
+
+// TestHistory_Eviction tests that only the last N messages are retained
+func TestHistory_Eviction(t *testing.T) {
+	h := newHistory(2)
+	for _, text := range []string{"a", "b", "c"} {
+		h.Add(event{Room: "r", Text: text})
+	}
+
+	got := h.Recent("r")
+	if len(got) != 2 || got[0].Text != "b" || got[1].Text != "c" {
+		t.Errorf("Recent() = %+v, want [b c]", got)
+	}
+}
@@ -0,0 +1,39 @@
+// chat/history.go
+package main
+
+import "sync"
+
+// history keeps the last few chat messages of every room so that new
+// joiners see recent context.
+type history struct {
+	mu    sync.Mutex
+	size  int
+	rooms map[string][]event
+}
+
+func newHistory(size int) *history {
+	return &history{size: size, rooms: make(map[string][]event)}
+}
+
+// Add appends ev to its room, evicting the oldest entry when full.
+func (h *history) Add(ev event) {
+	if h.size <= 0 {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	msgs := append(h.rooms[ev.Room], ev)
+	if len(msgs) > h.size {
+		msgs = msgs[len(msgs)-h.size:]
+	}
+	h.rooms[ev.Room] = msgs
+}
+
+// Recent returns a copy of the retained messages for room, oldest first.
+func (h *history) Recent(room string) []event {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]event(nil), h.rooms[room]...)
+}
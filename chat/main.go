@@ -0,0 +1,29 @@
+// chat/main.go
+package main
+
+import (
+	"embed"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/arifmahmudrana/go-snippets/pubsub"
+)
+
+//go:embed index.html
+var static embed.FS
+
+func main() {
+	addr := flag.String("addr", ":8080", "HTTP listen address")
+	historySize := flag.Int("history", 20, "messages kept per room for new joiners")
+	flag.Parse()
+
+	broker := pubsub.NewBroker()
+	defer broker.Stop()
+
+	srv := newServer(broker, *historySize)
+
+	fmt.Printf("[CHAT] Listening on http://localhost%s\n", *addr)
+	log.Fatal(http.ListenAndServe(*addr, srv.routes()))
+}
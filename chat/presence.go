@@ -0,0 +1,62 @@
+// chat/presence.go
+package main
+
+import (
+	"slices"
+	"sync"
+)
+
+// presence tracks who is connected to each room. A user may hold several
+// connections (e.g. two browser tabs), so connections are reference counted.
+type presence struct {
+	mu    sync.Mutex
+	rooms map[string]map[string]int
+}
+
+func newPresence() *presence {
+	return &presence{rooms: make(map[string]map[string]int)}
+}
+
+// Join records a connection for name in room and returns the room's users.
+func (p *presence) Join(room, name string) []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.rooms[room] == nil {
+		p.rooms[room] = make(map[string]int)
+	}
+	p.rooms[room][name]++
+	return p.usersLocked(room)
+}
+
+// Leave drops one connection for name in room and returns the room's users.
+func (p *presence) Leave(room, name string) []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if users, ok := p.rooms[room]; ok {
+		if users[name]--; users[name] <= 0 {
+			delete(users, name)
+		}
+		if len(users) == 0 {
+			delete(p.rooms, room)
+		}
+	}
+	return p.usersLocked(room)
+}
+
+// Users returns the sorted list of users currently in room.
+func (p *presence) Users(room string) []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.usersLocked(room)
+}
+
+func (p *presence) usersLocked(room string) []string {
+	users := make([]string, 0, len(p.rooms[room]))
+	for name := range p.rooms[room] {
+		users = append(users, name)
+	}
+	slices.Sort(users)
+	return users
+}
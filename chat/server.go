@@ -0,0 +1,154 @@
+// chat/server.go
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/arifmahmudrana/go-snippets/pubsub"
+)
+
+// event is what travels over the broker and down every WebSocket.
+type event struct {
+	Type  string    `json:"type"` // "message", "join" or "leave"
+	Room  string    `json:"room"`
+	From  string    `json:"from"`
+	Text  string    `json:"text,omitempty"`
+	Users []string  `json:"users,omitempty"`
+	Time  time.Time `json:"time"`
+}
+
+// server wires WebSocket clients to per-room broker topics.
+type server struct {
+	broker   *pubsub.Broker
+	presence *presence
+	history  *history
+
+	// conns tracks live WebSocket handlers so shutdown can wait for them
+	// to unsubscribe before the broker is stopped.
+	conns sync.WaitGroup
+}
+
+func newServer(broker *pubsub.Broker, historySize int) *server {
+	return &server{
+		broker:   broker,
+		presence: newPresence(),
+		history:  newHistory(historySize),
+	}
+}
+
+// roomTopic maps a chat room to its broker topic.
+func roomTopic(room string) string {
+	return "chat." + room
+}
+
+func (s *server) publish(ev event) {
+	ev.Time = time.Now()
+	s.broker.Publish(roomTopic(ev.Room), ev)
+}
+
+// handleWS serves /ws?room=<room>&name=<name>.
+func (s *server) handleWS(w http.ResponseWriter, r *http.Request) {
+	room := r.URL.Query().Get("room")
+	if room == "" {
+		room = "lobby"
+	}
+	name := strings.TrimSpace(r.URL.Query().Get("name"))
+	if name == "" {
+		http.Error(w, "name is required", http.StatusBadRequest)
+		return
+	}
+
+	conn, err := upgrade(w, r)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	s.conns.Add(1)
+	defer s.conns.Done()
+
+	// Subscribe before replaying history so nothing published in between
+	// is lost; at worst a message is shown twice. Join first too, so a
+	// client that has seen the history is already in the room; the join
+	// event waits in sub until the replay is done.
+	topic := roomTopic(room)
+	sub := s.broker.Subscribe(topic)
+	users := s.presence.Join(room, name)
+	s.publish(event{Type: "join", Room: room, From: name, Users: users})
+	defer func() {
+		users := s.presence.Leave(room, name)
+		s.publish(event{Type: "leave", Room: room, From: name, Users: users})
+	}()
+
+	for _, ev := range s.history.Recent(room) {
+		b, _ := json.Marshal(ev)
+		if err := conn.WriteText(b); err != nil {
+			s.broker.Unsubscribe(topic, sub)
+			return
+		}
+	}
+
+	// writer: forward broker messages to the client
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		broken := false
+		for msg := range sub.C {
+			if broken {
+				continue // keep draining until unsubscribed
+			}
+			b, _ := json.Marshal(msg.Payload)
+			if err := conn.WriteText(b); err != nil {
+				broken = true
+				conn.Close() // unblocks the reader below
+			}
+		}
+	}()
+
+	// reader: publish whatever the client types
+	for {
+		data, err := conn.ReadMessage()
+		if err != nil {
+			break
+		}
+		text := strings.TrimSpace(string(data))
+		if text == "" {
+			continue
+		}
+
+		ev := event{Type: "message", Room: room, From: name, Text: text, Time: time.Now()}
+		s.history.Add(ev)
+		s.broker.Publish(topic, ev)
+	}
+
+	s.broker.Unsubscribe(topic, sub)
+	<-done
+}
+
+// handleUsers serves /users?room=<room> as a JSON array.
+func (s *server) handleUsers(w http.ResponseWriter, r *http.Request) {
+	room := r.URL.Query().Get("room")
+	if room == "" {
+		room = "lobby"
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.presence.Users(room))
+}
+
+// wait blocks until every WebSocket handler has returned.
+func (s *server) wait() {
+	s.conns.Wait()
+}
+
+// routes returns the HTTP handler for the chat application.
+func (s *server) routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/", http.FileServer(http.FS(static)))
+	mux.HandleFunc("/ws", s.handleWS)
+	mux.HandleFunc("/users", s.handleUsers)
+	return mux
+}
@@ -0,0 +1,186 @@
+package clock
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// Lamport is a Lamport logical clock, safe for concurrent use. Its
+// timestamps respect causality: if event a happened before b, then
+// a's timestamp is smaller. The converse does not hold; use Vector when
+// concurrent events must be told apart. pubsub.WithClock stamps a
+// broker's messages with one.
+type Lamport struct {
+	t atomic.Uint64
+}
+
+// Now returns the current time without advancing it.
+func (l *Lamport) Now() uint64 {
+	return l.t.Load()
+}
+
+// Tick advances the clock for a local event or a send and returns the
+// new time.
+func (l *Lamport) Tick() uint64 {
+	return l.t.Add(1)
+}
+
+// Witness merges a timestamp received from another process and returns
+// the new local time: max(local, remote) + 1.
+func (l *Lamport) Witness(remote uint64) uint64 {
+	for {
+		cur := l.t.Load()
+		next := max(cur, remote) + 1
+		if l.t.CompareAndSwap(cur, next) {
+			return next
+		}
+	}
+}
+
+// Order is the result of comparing two vector clocks.
+type Order int
+
+const (
+	Equal      Order = iota
+	Before           // a happened before b
+	After            // a happened after b
+	Concurrent       // neither saw the other: a conflict
+)
+
+func (o Order) String() string {
+	switch o {
+	case Equal:
+		return "equal"
+	case Before:
+		return "before"
+	case After:
+		return "after"
+	}
+	return "concurrent"
+}
+
+// Vector is a vector clock: one counter per node. The zero value is an
+// empty clock ready to use. Vectors are values; methods that change them
+// return a new Vector and never modify the receiver, so a clock attached
+// to a message can be shared safely.
+type Vector map[string]uint64
+
+// Tick returns a copy of v with node's counter incremented.
+func (v Vector) Tick(node string) Vector {
+	out := v.Copy()
+	out[node]++
+	return out
+}
+
+// Merge returns the element-wise maximum of v and o: a clock that has
+// seen everything either has.
+func (v Vector) Merge(o Vector) Vector {
+	out := v.Copy()
+	for node, c := range o {
+		out[node] = max(out[node], c)
+	}
+	return out
+}
+
+// Copy returns an independent copy of v.
+func (v Vector) Copy() Vector {
+	out := make(Vector, len(v))
+	for node, c := range v {
+		out[node] = c
+	}
+	return out
+}
+
+// Compare reports how v is ordered relative to o. Missing entries count
+// as zero.
+func (v Vector) Compare(o Vector) Order {
+	less, greater := false, false
+	for node, c := range v {
+		switch oc := o[node]; {
+		case c < oc:
+			less = true
+		case c > oc:
+			greater = true
+		}
+	}
+	for node, oc := range o {
+		if _, ok := v[node]; !ok && oc > 0 {
+			less = true
+		}
+	}
+
+	switch {
+	case less && greater:
+		return Concurrent
+	case less:
+		return Before
+	case greater:
+		return After
+	}
+	return Equal
+}
+
+// Descends reports whether v has seen everything o has (v >= o).
+func (v Vector) Descends(o Vector) bool {
+	ord := v.Compare(o)
+	return ord == After || ord == Equal
+}
+
+// String formats v as {a:1 b:2} with nodes sorted, for logs and tests.
+func (v Vector) String() string {
+	nodes := make([]string, 0, len(v))
+	for node := range v {
+		nodes = append(nodes, node)
+	}
+	sort.Strings(nodes)
+
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, node := range nodes {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(node + ":" + strconv.FormatUint(v[node], 10))
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+// Stamped pairs a value with the vector clock of the write that produced
+// it. Publishing Stamped values as broker payloads carries causality
+// information alongside the data.
+type Stamped[T any] struct {
+	Clock Vector `json:"clock"`
+	Value T      `json:"value"`
+}
+
+// Latest drops every version that another version descends from and
+// returns what is left, in input order. One result means the history is
+// linear; more than one means concurrent writes that the caller must
+// reconcile (siblings). Duplicates of the same clock are kept once.
+func Latest[T any](versions []Stamped[T]) []Stamped[T] {
+	var out []Stamped[T]
+	for i, v := range versions {
+		superseded := false
+		for j, o := range versions {
+			if i == j {
+				continue
+			}
+			switch v.Clock.Compare(o.Clock) {
+			case Before:
+				superseded = true
+			case Equal:
+				superseded = j < i // keep the first of equal clocks
+			}
+			if superseded {
+				break
+			}
+		}
+		if !superseded {
+			out = append(out, v)
+		}
+	}
+	return out
+}
@@ -0,0 +1,97 @@
+package clock
+
+import (
+	"sync"
+	"testing"
+)
+
+// TestLamport tests ticking and witnessing remote timestamps
+func TestLamport(t *testing.T) {
+	var l Lamport
+	if got := l.Tick(); got != 1 {
+		t.Errorf("Tick() = %d, want 1", got)
+	}
+	if got := l.Witness(10); got != 11 {
+		t.Errorf("Witness(10) = %d, want 11", got)
+	}
+	if got := l.Witness(3); got != 12 {
+		t.Errorf("Witness(3) = %d, want 12", got)
+	}
+
+	var wg sync.WaitGroup
+	for range 100 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			l.Tick()
+		}()
+	}
+	wg.Wait()
+	if got := l.Now(); got != 112 {
+		t.Errorf("Now() = %d, want 112", got)
+	}
+}
+
+// TestVector_Compare tests all four orderings
+func TestVector_Compare(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b Vector
+		want Order
+	}{
+		{"both empty", nil, Vector{}, Equal},
+		{"equal", Vector{"a": 1, "b": 2}, Vector{"a": 1, "b": 2}, Equal},
+		{"zero entry", Vector{"a": 1, "b": 0}, Vector{"a": 1}, Equal},
+		{"before", Vector{"a": 1}, Vector{"a": 1, "b": 1}, Before},
+		{"after", Vector{"a": 2, "b": 1}, Vector{"a": 1, "b": 1}, After},
+		{"concurrent", Vector{"a": 2}, Vector{"a": 1, "b": 1}, Concurrent},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.a.Compare(tt.b); got != tt.want {
+				t.Errorf("Compare() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestVector_TickMerge tests that Tick and Merge do not modify the receiver
+func TestVector_TickMerge(t *testing.T) {
+	a := Vector{"a": 1}
+	b := a.Tick("b")
+	if a.String() != "{a:1}" || b.String() != "{a:1 b:1}" {
+		t.Errorf("a = %v, b = %v", a, b)
+	}
+
+	m := Vector{"a": 3}.Merge(Vector{"a": 1, "c": 2})
+	if got := m.String(); got != "{a:3 c:2}" {
+		t.Errorf("Merge() = %s, want {a:3 c:2}", got)
+	}
+	if !m.Descends(Vector{"c": 2}) || m.Descends(Vector{"d": 1}) {
+		t.Error("Descends() wrong")
+	}
+}
+
+// TestLatest tests sibling detection for concurrent writes
+func TestLatest(t *testing.T) {
+	base := Vector{}.Tick("a")
+	fromA := base.Tick("a")
+	fromB := base.Tick("b")
+
+	versions := []Stamped[string]{
+		{Clock: base, Value: "v1"},
+		{Clock: fromA, Value: "a's edit"},
+		{Clock: fromB, Value: "b's edit"},
+		{Clock: fromB.Copy(), Value: "b's edit, redelivered"},
+	}
+	got := Latest(versions)
+	if len(got) != 2 || got[0].Value != "a's edit" || got[1].Value != "b's edit" {
+		t.Errorf("Latest() = %v, want a's and b's edits", got)
+	}
+
+	resolved := Stamped[string]{Clock: fromA.Merge(fromB).Tick("a"), Value: "merged"}
+	got = Latest(append(versions, resolved))
+	if len(got) != 1 || got[0].Value != "merged" {
+		t.Errorf("Latest() after merge = %v, want [merged]", got)
+	}
+}
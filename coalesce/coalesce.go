@@ -0,0 +1,188 @@
+package coalesce
+
+import (
+	"sync"
+	"time"
+
+	"github.com/arifmahmudrana/go-snippets/pubsub"
+)
+
+// Handler receives one batch of distinct keys, in first-seen order.
+type Handler func(keys []string) error
+
+// Coalescer collects keys for a short window and hands them to a Handler
+// in one call. Repeated keys within a window are passed once, so a storm
+// of invalidations for the same few keys costs one downstream call per
+// window instead of one per event.
+//
+// The handler is never called concurrently with itself.
+type Coalescer struct {
+	window   time.Duration
+	maxBatch int
+	handler  Handler
+	onError  func(keys []string, err error)
+
+	in      chan string
+	flushCh chan chan struct{}
+	quit    chan struct{}
+	done    chan struct{}
+	once    sync.Once
+
+	// set by FromTopic
+	broker *pubsub.Broker
+	topic  string
+	sub    *pubsub.Subscriber
+	reader sync.WaitGroup
+}
+
+// Option configures a Coalescer.
+type Option func(*Coalescer)
+
+// WithMaxBatch flushes as soon as n distinct keys are pending instead of
+// waiting for the window to end. By default batches are unbounded.
+func WithMaxBatch(n int) Option {
+	return func(c *Coalescer) {
+		c.maxBatch = n
+	}
+}
+
+// WithErrorHandler sets a callback for batches whose handler failed. By
+// default such errors are discarded.
+func WithErrorHandler(fn func(keys []string, err error)) Option {
+	return func(c *Coalescer) {
+		c.onError = fn
+	}
+}
+
+// New starts a Coalescer. A window opens when the first key arrives and
+// the batch is flushed when it closes.
+func New(window time.Duration, handler Handler, opts ...Option) *Coalescer {
+	c := &Coalescer{
+		window:  window,
+		handler: handler,
+		in:      make(chan string),
+		flushCh: make(chan chan struct{}),
+		quit:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	go c.run()
+	return c
+}
+
+// FromTopic starts a Coalescer fed by a broker topic. Payloads may be a
+// string key or a []string of keys; anything else is ignored. Close
+// unsubscribes from the topic.
+func FromTopic(broker *pubsub.Broker, topic string, window time.Duration, handler Handler, opts ...Option) *Coalescer {
+	c := New(window, handler, opts...)
+	c.broker, c.topic = broker, topic
+	c.sub = broker.Subscribe(topic)
+
+	c.reader.Add(1)
+	go func() {
+		defer c.reader.Done()
+		for msg := range c.sub.C {
+			switch p := msg.Payload.(type) {
+			case string:
+				c.Add(p)
+			case []string:
+				for _, k := range p {
+					c.Add(k)
+				}
+			}
+		}
+	}()
+	return c
+}
+
+// Add queues key for the current window. Keys added after Close are
+// dropped.
+func (c *Coalescer) Add(key string) {
+	select {
+	case c.in <- key:
+	case <-c.quit:
+	}
+}
+
+// Flush hands any pending keys to the handler now and waits for it.
+func (c *Coalescer) Flush() {
+	reply := make(chan struct{})
+	select {
+	case c.flushCh <- reply:
+		<-reply
+	case <-c.done:
+	}
+}
+
+// Close flushes pending keys, stops the coalescer and waits for the last
+// handler call to return. For a coalescer from FromTopic it first
+// unsubscribes, so keys already delivered by the broker are not lost.
+func (c *Coalescer) Close() {
+	c.once.Do(func() {
+		if c.broker != nil {
+			c.broker.Unsubscribe(c.topic, c.sub)
+			c.reader.Wait()
+		}
+		close(c.quit)
+	})
+	<-c.done
+}
+
+func (c *Coalescer) run() {
+	defer close(c.done)
+
+	var (
+		pending = make(map[string]struct{})
+		order   []string
+		timer   *time.Timer
+		expired <-chan time.Time
+	)
+
+	flush := func() {
+		if timer != nil {
+			timer.Stop()
+			timer, expired = nil, nil
+		}
+		if len(order) == 0 {
+			return
+		}
+		keys := order
+		order = nil
+		clear(pending)
+		if err := c.handler(keys); err != nil && c.onError != nil {
+			c.onError(keys, err)
+		}
+	}
+
+	for {
+		select {
+		case key := <-c.in:
+			if _, dup := pending[key]; dup {
+				continue
+			}
+			pending[key] = struct{}{}
+			order = append(order, key)
+			if timer == nil {
+				timer = time.NewTimer(c.window)
+				expired = timer.C
+			}
+			if c.maxBatch > 0 && len(order) >= c.maxBatch {
+				flush()
+			}
+
+		case <-expired:
+			timer, expired = nil, nil
+			flush()
+
+		case reply := <-c.flushCh:
+			flush()
+			close(reply)
+
+		case <-c.quit:
+			flush()
+			return
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package coalesce
+
+import (
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/arifmahmudrana/go-snippets/pubsub"
+)
+
+// recorder is a Handler that remembers every batch.
+type recorder struct {
+	mu      sync.Mutex
+	batches [][]string
+	err     error
+}
+
+func (r *recorder) handle(keys []string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.batches = append(r.batches, keys)
+	return r.err
+}
+
+func (r *recorder) get() [][]string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return slices.Clone(r.batches)
+}
+
+// TestCoalescer_Window tests that keys in one window form one deduplicated batch
+func TestCoalescer_Window(t *testing.T) {
+	var r recorder
+	c := New(30*time.Millisecond, r.handle)
+	defer c.Close()
+
+	for range 100 {
+		c.Add("user:1")
+		c.Add("user:2")
+	}
+	c.Add("user:1")
+
+	time.Sleep(100 * time.Millisecond)
+	got := r.get()
+	if len(got) != 1 || !slices.Equal(got[0], []string{"user:1", "user:2"}) {
+		t.Errorf("batches = %v, want [[user:1 user:2]]", got)
+	}
+
+	c.Add("user:3")
+	c.Flush()
+	if got := r.get(); len(got) != 2 || !slices.Equal(got[1], []string{"user:3"}) {
+		t.Errorf("batches after Flush = %v, want second batch [user:3]", got)
+	}
+}
+
+// TestCoalescer_MaxBatch tests early flushing at the size limit
+func TestCoalescer_MaxBatch(t *testing.T) {
+	var r recorder
+	c := New(time.Hour, r.handle, WithMaxBatch(2))
+
+	for _, k := range []string{"a", "b", "c"} {
+		c.Add(k)
+	}
+	c.Close()
+
+	want := [][]string{{"a", "b"}, {"c"}}
+	got := r.get()
+	if len(got) != len(want) {
+		t.Fatalf("batches = %v, want %v", got, want)
+	}
+	for i := range want {
+		if !slices.Equal(got[i], want[i]) {
+			t.Errorf("batch %d = %v, want %v", i, got[i], want[i])
+		}
+	}
+}
+
+// TestCoalescer_ErrorHandler tests that handler errors are reported
+func TestCoalescer_ErrorHandler(t *testing.T) {
+	errDB := errors.New("db down")
+	r := recorder{err: errDB}
+
+	var gotErr error
+	c := New(time.Hour, r.handle, WithErrorHandler(func(keys []string, err error) {
+		gotErr = err
+	}))
+	c.Add("k")
+	c.Close()
+
+	if !errors.Is(gotErr, errDB) {
+		t.Errorf("reported error = %v, want %v", gotErr, errDB)
+	}
+}
+
+// TestFromTopic tests coalescing keys published on a broker topic
+func TestFromTopic(t *testing.T) {
+	broker := pubsub.NewBroker()
+	defer broker.Stop()
+
+	var r recorder
+	c := FromTopic(broker, "cache.invalidate", time.Hour, r.handle)
+
+	broker.Publish("cache.invalidate", "a")
+	broker.Publish("cache.invalidate", []string{"b", "a"})
+	broker.Publish("cache.invalidate", 42) // ignored
+	time.Sleep(50 * time.Millisecond)
+	c.Close()
+
+	got := r.get()
+	if len(got) != 1 {
+		t.Fatalf("batches = %v, want one batch", got)
+	}
+	keys := slices.Clone(got[0])
+	slices.Sort(keys)
+	if !slices.Equal(keys, []string{"a", "b"}) {
+		t.Errorf("keys = %v, want [a b]", keys)
+	}
+}
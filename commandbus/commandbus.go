@@ -0,0 +1,162 @@
+package commandbus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+var (
+	// ErrNoHandler is returned by Dispatch for a command type nothing
+	// handles.
+	ErrNoHandler = errors.New("commandbus: no handler")
+	// ErrDuplicateHandler is returned by Handle when the command type
+	// already has a handler.
+	ErrDuplicateHandler = errors.New("commandbus: handler already registered")
+	// ErrInvalid wraps the error of a command failing validation.
+	ErrInvalid = errors.New("commandbus: invalid command")
+	// ErrUnauthorized wraps the error of a command failing authorization.
+	ErrUnauthorized = errors.New("commandbus: unauthorized")
+)
+
+// HandlerFunc carries out one command of type C.
+type HandlerFunc[C any] func(ctx context.Context, cmd C) error
+
+// Next runs the rest of the middleware chain and the handler.
+type Next func(ctx context.Context, cmd any) error
+
+// Middleware wraps the dispatch of every command, e.g. to check it or run
+// it in a transaction.
+type Middleware func(next Next) Next
+
+// Bus dispatches commands to their handlers. Unlike eventbus.Bus, which
+// broadcasts an event to any number of handlers in the background, a
+// command has exactly one handler, runs on the caller's goroutine and
+// returns its error to the caller.
+type Bus struct {
+	mu         sync.RWMutex
+	handlers   map[reflect.Type]Next
+	middleware []Middleware
+}
+
+// New returns a bus running every command through middleware, the first
+// outermost.
+func New(middleware ...Middleware) *Bus {
+	return &Bus{handlers: make(map[reflect.Type]Next), middleware: middleware}
+}
+
+// Use appends middleware to the chain. It affects later dispatches.
+func (b *Bus) Use(middleware ...Middleware) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.middleware = append(b.middleware, middleware...)
+}
+
+// Handle registers fn as the handler of commands of type C. It fails
+// with ErrDuplicateHandler if C has one. It is a package-level function
+// because Go methods cannot have type parameters.
+func Handle[C any](b *Bus, fn HandlerFunc[C]) error {
+	t := reflect.TypeOf((*C)(nil)).Elem()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.handlers[t]; ok {
+		return fmt.Errorf("%w: %v", ErrDuplicateHandler, t)
+	}
+	b.handlers[t] = func(ctx context.Context, cmd any) error {
+		return fn(ctx, cmd.(C))
+	}
+	return nil
+}
+
+// Dispatch runs cmd through the middleware and its handler, by its
+// dynamic type, and returns the first error.
+func (b *Bus) Dispatch(ctx context.Context, cmd any) error {
+	b.mu.RLock()
+	h, ok := b.handlers[reflect.TypeOf(cmd)]
+	middleware := b.middleware
+	b.mu.RUnlock()
+	if !ok {
+		return fmt.Errorf("%w for %T", ErrNoHandler, cmd)
+	}
+	for i := len(middleware) - 1; i >= 0; i-- {
+		h = middleware[i](h)
+	}
+	return h(ctx, cmd)
+}
+
+// Validator is implemented by commands that can check themselves.
+type Validator interface {
+	Validate() error
+}
+
+// Validate rejects commands whose Validate method fails, wrapping the
+// error with ErrInvalid. Commands that are not Validators pass.
+func Validate() Middleware {
+	return func(next Next) Next {
+		return func(ctx context.Context, cmd any) error {
+			if v, ok := cmd.(Validator); ok {
+				if err := v.Validate(); err != nil {
+					return fmt.Errorf("%w: %w", ErrInvalid, err)
+				}
+			}
+			return next(ctx, cmd)
+		}
+	}
+}
+
+// Authorize rejects commands allow fails for, e.g. because the user in
+// ctx may not issue them, wrapping the error with ErrUnauthorized.
+func Authorize(allow func(ctx context.Context, cmd any) error) Middleware {
+	return func(next Next) Next {
+		return func(ctx context.Context, cmd any) error {
+			if err := allow(ctx, cmd); err != nil {
+				return fmt.Errorf("%w: %w", ErrUnauthorized, err)
+			}
+			return next(ctx, cmd)
+		}
+	}
+}
+
+// Tx is a transaction, such as a *sql.Tx.
+type Tx interface {
+	Commit() error
+	Rollback() error
+}
+
+type txKey struct{}
+
+// TxFrom returns the transaction the Transaction middleware started for
+// the command being handled.
+func TxFrom(ctx context.Context) (Tx, bool) {
+	tx, ok := ctx.Value(txKey{}).(Tx)
+	return tx, ok
+}
+
+// Transaction runs each command in a transaction from begin, available
+// to the handler through TxFrom. It commits if the rest of the chain
+// succeeds and rolls back if it fails or panics.
+func Transaction(begin func(ctx context.Context) (Tx, error)) Middleware {
+	return func(next Next) Next {
+		return func(ctx context.Context, cmd any) error {
+			tx, err := begin(ctx)
+			if err != nil {
+				return err
+			}
+			defer func() {
+				if p := recover(); p != nil {
+					tx.Rollback()
+					panic(p)
+				}
+			}()
+			if err := next(context.WithValue(ctx, txKey{}, tx), cmd); err != nil {
+				if rerr := tx.Rollback(); rerr != nil {
+					return errors.Join(err, rerr)
+				}
+				return err
+			}
+			return tx.Commit()
+		}
+	}
+}
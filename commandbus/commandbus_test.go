@@ -0,0 +1,152 @@
+package commandbus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+type PlaceOrder struct {
+	Item string
+	Qty  int
+}
+
+func (c PlaceOrder) Validate() error {
+	if c.Qty <= 0 {
+		return errors.New("quantity must be positive")
+	}
+	return nil
+}
+
+type CancelOrder struct {
+	ID string
+}
+
+type userKey struct{}
+
+// fakeTx records how it ended.
+type fakeTx struct {
+	ended string
+}
+
+func (tx *fakeTx) Commit() error   { tx.ended = "commit"; return nil }
+func (tx *fakeTx) Rollback() error { tx.ended = "rollback"; return nil }
+
+// TestBus_Handle tests routing by type and the one-handler rule
+func TestBus_Handle(t *testing.T) {
+	b := New()
+	var got []string
+	if err := Handle(b, func(ctx context.Context, c PlaceOrder) error {
+		got = append(got, "place "+c.Item)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	err := Handle(b, func(ctx context.Context, c PlaceOrder) error { return nil })
+	if !errors.Is(err, ErrDuplicateHandler) {
+		t.Errorf("second Handle() = %v, want %v", err, ErrDuplicateHandler)
+	}
+
+	ctx := context.Background()
+	if err := b.Dispatch(ctx, PlaceOrder{Item: "tea", Qty: 1}); err != nil {
+		t.Errorf("Dispatch() = %v", err)
+	}
+	if err := b.Dispatch(ctx, CancelOrder{ID: "1"}); !errors.Is(err, ErrNoHandler) {
+		t.Errorf("Dispatch() without a handler = %v, want %v", err, ErrNoHandler)
+	}
+	if err := b.Dispatch(ctx, &PlaceOrder{}); !errors.Is(err, ErrNoHandler) {
+		t.Errorf("Dispatch() of a pointer = %v, want %v", err, ErrNoHandler)
+	}
+	if fmt.Sprint(got) != "[place tea]" {
+		t.Errorf("handled %v", got)
+	}
+}
+
+// TestBus_Middleware tests validation, authorization and transactions,
+// and the order they run in
+func TestBus_Middleware(t *testing.T) {
+	var tx *fakeTx
+	var trace []string
+	b := New(
+		func(next Next) Next {
+			return func(ctx context.Context, cmd any) error {
+				trace = append(trace, "log")
+				return next(ctx, cmd)
+			}
+		},
+		Validate(),
+		Authorize(func(ctx context.Context, cmd any) error {
+			trace = append(trace, "auth")
+			if ctx.Value(userKey{}) == nil {
+				return errors.New("not logged in")
+			}
+			return nil
+		}),
+	)
+	b.Use(Transaction(func(ctx context.Context) (Tx, error) {
+		trace = append(trace, "begin")
+		tx = &fakeTx{}
+		return tx, nil
+	}))
+	Handle(b, func(ctx context.Context, c PlaceOrder) error {
+		trace = append(trace, "handle")
+		if got, _ := TxFrom(ctx); got != tx {
+			t.Errorf("TxFrom() = %v, want the transaction", got)
+		}
+		if c.Item == "" {
+			return errors.New("out of stock")
+		}
+		return nil
+	})
+
+	user := context.WithValue(context.Background(), userKey{}, "ann")
+	tests := []struct {
+		name      string
+		ctx       context.Context
+		cmd       PlaceOrder
+		wantErr   error
+		wantTrace string
+		wantTx    string
+	}{
+		{"ok", user, PlaceOrder{"tea", 1}, nil, "log auth begin handle", "commit"},
+		{"invalid", user, PlaceOrder{"tea", 0}, ErrInvalid, "log", ""},
+		{"unauthorized", context.Background(), PlaceOrder{"tea", 1}, ErrUnauthorized, "log auth", ""},
+		{"handler fails", user, PlaceOrder{"", 1}, nil, "log auth begin handle", "rollback"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			trace, tx = nil, nil
+			err := b.Dispatch(tt.ctx, tt.cmd)
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) || tt.wantTx == "rollback" && err == nil {
+				t.Errorf("Dispatch() = %v, want %v", err, tt.wantErr)
+			}
+			if got := strings.Join(trace, " "); got != tt.wantTrace {
+				t.Errorf("ran %q, want %q", got, tt.wantTrace)
+			}
+			if tt.wantTx != "" && (tx == nil || tx.ended != tt.wantTx) {
+				t.Errorf("transaction ended with %+v, want %s", tx, tt.wantTx)
+			}
+		})
+	}
+}
+
+// TestTransaction_Panic tests that a panicking handler rolls back
+func TestTransaction_Panic(t *testing.T) {
+	tx := &fakeTx{}
+	b := New(Transaction(func(ctx context.Context) (Tx, error) { return tx, nil }))
+	Handle(b, func(ctx context.Context, c CancelOrder) error { panic("boom") })
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("panic was swallowed")
+			}
+		}()
+		b.Dispatch(context.Background(), CancelOrder{})
+	}()
+	if tx.ended != "rollback" {
+		t.Errorf("transaction ended with %q, want rollback", tx.ended)
+	}
+}
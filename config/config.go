@@ -0,0 +1,149 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Duration is a time.Duration that reads as "1s", "250ms" etc. in both
+// JSON and YAML.
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("duration must be a string like \"1s\": %w", err)
+	}
+	return d.parse(s)
+}
+
+func (d Duration) MarshalYAML() (any, error) {
+	return time.Duration(d).String(), nil
+}
+
+func (d *Duration) UnmarshalYAML(node *yaml.Node) error {
+	return d.parse(node.Value)
+}
+
+func (d *Duration) parse(s string) error {
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// BrokerConfig holds broker-wide settings.
+type BrokerConfig struct {
+	SubscriberBuffer int      `json:"subscriber_buffer" yaml:"subscriber_buffer"`
+	DeliveryTimeout  Duration `json:"delivery_timeout" yaml:"delivery_timeout"`
+}
+
+// TopicConfig holds per-topic settings.
+type TopicConfig struct {
+	Retain  int  `json:"retain" yaml:"retain"`
+	Persist bool `json:"persist" yaml:"persist"`
+	MaxSubs int  `json:"max_subscribers" yaml:"max_subscribers"`
+}
+
+// Config is the application configuration shared by the snippets.
+type Config struct {
+	Broker  BrokerConfig           `json:"broker" yaml:"broker"`
+	Topics  map[string]TopicConfig `json:"topics" yaml:"topics"`
+	Workers map[string]int         `json:"workers" yaml:"workers"`
+}
+
+// Default returns the configuration used when a field is left unset.
+func Default() *Config {
+	return &Config{
+		Broker: BrokerConfig{
+			SubscriberBuffer: 10,
+			DeliveryTimeout:  Duration(time.Second),
+		},
+		Topics:  map[string]TopicConfig{},
+		Workers: map[string]int{},
+	}
+}
+
+// Validate reports the first invalid setting.
+func (c *Config) Validate() error {
+	if c.Broker.SubscriberBuffer < 0 {
+		return fmt.Errorf("broker.subscriber_buffer must be >= 0, got %d", c.Broker.SubscriberBuffer)
+	}
+	if c.Broker.DeliveryTimeout < 0 {
+		return fmt.Errorf("broker.delivery_timeout must be >= 0, got %v", time.Duration(c.Broker.DeliveryTimeout))
+	}
+	for name, t := range c.Topics {
+		if t.Retain < 0 || t.MaxSubs < 0 {
+			return fmt.Errorf("topics.%s: retain and max_subscribers must be >= 0", name)
+		}
+	}
+	for name, n := range c.Workers {
+		if n < 1 {
+			return fmt.Errorf("workers.%s must be >= 1, got %d", name, n)
+		}
+	}
+	return nil
+}
+
+// WorkerCount returns the configured workers for name, or def if unset.
+func (c *Config) WorkerCount(name string, def int) int {
+	if n, ok := c.Workers[name]; ok {
+		return n
+	}
+	return def
+}
+
+// Parse decodes data as YAML or JSON according to format ("yaml", "yml"
+// or "json"), on top of the defaults, and validates the result. Unknown
+// fields are rejected so typos do not go unnoticed.
+func Parse(data []byte, format string) (*Config, error) {
+	cfg := Default()
+
+	switch strings.ToLower(format) {
+	case "yaml", "yml":
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		// an empty document is fine: it means "all defaults"
+		if err := dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("config: %w", err)
+		}
+	case "json":
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(cfg); err != nil {
+			return nil, fmt.Errorf("config: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("config: unsupported format %q", format)
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("config: %w", err)
+	}
+	return cfg, nil
+}
+
+// Load reads and parses the file at path; the format comes from its
+// extension.
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data, strings.TrimPrefix(filepath.Ext(path), "."))
+}
@@ -0,0 +1,187 @@
+package config
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/arifmahmudrana/go-snippets/pubsub"
+)
+
+const sampleYAML = `
+broker:
+  subscriber_buffer: 64
+  delivery_timeout: 250ms
+topics:
+  orders:
+    retain: 100
+    persist: true
+workers:
+  digits: 8
+`
+
+const sampleJSON = `{
+  "broker": {"subscriber_buffer": 64, "delivery_timeout": "250ms"},
+  "topics": {"orders": {"retain": 100, "persist": true}},
+  "workers": {"digits": 8}
+}`
+
+// TestParse_Formats tests that YAML and JSON decode to the same config
+func TestParse_Formats(t *testing.T) {
+	for _, tt := range []struct{ format, data string }{
+		{"yaml", sampleYAML},
+		{"json", sampleJSON},
+	} {
+		cfg, err := Parse([]byte(tt.data), tt.format)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.format, err)
+		}
+		if cfg.Broker.SubscriberBuffer != 64 || time.Duration(cfg.Broker.DeliveryTimeout) != 250*time.Millisecond {
+			t.Errorf("%s: broker = %+v", tt.format, cfg.Broker)
+		}
+		if o := cfg.Topics["orders"]; o.Retain != 100 || !o.Persist {
+			t.Errorf("%s: orders = %+v", tt.format, o)
+		}
+		if got := cfg.WorkerCount("digits", 1); got != 8 {
+			t.Errorf("%s: WorkerCount(digits) = %d, want 8", tt.format, got)
+		}
+		if got := cfg.WorkerCount("missing", 3); got != 3 {
+			t.Errorf("%s: WorkerCount(missing) = %d, want 3", tt.format, got)
+		}
+	}
+}
+
+// TestParse_Defaults tests that an empty document yields the defaults
+func TestParse_Defaults(t *testing.T) {
+	cfg, err := Parse(nil, "yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Broker.SubscriberBuffer != Default().Broker.SubscriberBuffer {
+		t.Errorf("SubscriberBuffer = %d, want default", cfg.Broker.SubscriberBuffer)
+	}
+}
+
+// TestParse_Invalid tests unknown fields, bad values and unknown formats
+func TestParse_Invalid(t *testing.T) {
+	tests := []struct{ name, format, data, want string }{
+		{"typo", "yaml", "brokr: {}", "brokr"},
+		{"negative workers", "json", `{"workers": {"x": 0}}`, "workers.x"},
+		{"bad duration", "yaml", "broker: {delivery_timeout: soon}", "soon"},
+		{"format", "toml", "", "unsupported"},
+	}
+	for _, tt := range tests {
+		_, err := Parse([]byte(tt.data), tt.format)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: err = %v, want it to mention %q", tt.name, err, tt.want)
+		}
+	}
+}
+
+// awaitChange waits for a Change on the broker.
+func awaitChange(t *testing.T, sub *pubsub.Subscriber) Change {
+	t.Helper()
+	select {
+	case msg := <-sub.C:
+		return msg.Payload.(Change)
+	case <-time.After(2 * time.Second):
+		t.Fatal("no config change published")
+		return Change{}
+	}
+}
+
+// writeFile replaces path's content in one step, so the watcher never
+// sees the empty file os.WriteFile briefly leaves behind.
+func writeFile(t *testing.T, path, data string) {
+	t.Helper()
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestWatch_FileChange tests reload on edit and that invalid edits are skipped
+func TestWatch_FileChange(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.yaml")
+	os.WriteFile(path, []byte(sampleYAML), 0o644)
+
+	broker := pubsub.NewBroker()
+	defer broker.Stop()
+	sub := broker.Subscribe(ChangedTopic)
+	defer broker.Unsubscribe(ChangedTopic, sub)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errs := make(chan error, 10)
+	w, err := Watch(ctx, path, broker, WithInterval(10*time.Millisecond),
+		WithErrorHandler(func(err error) { errs <- err }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		cancel()
+		<-w.Done()
+	}()
+
+	writeFile(t, path, strings.Replace(sampleYAML, "digits: 8", "digits: 16", 1))
+	ch := awaitChange(t, sub)
+	if ch.Old.WorkerCount("digits", 0) != 8 || ch.New.WorkerCount("digits", 0) != 16 || ch.Source != "file" {
+		t.Errorf("change = old %v new %v source %q", ch.Old.Workers, ch.New.Workers, ch.Source)
+	}
+	if w.Current().WorkerCount("digits", 0) != 16 {
+		t.Error("Current() not updated")
+	}
+
+	// an invalid edit is reported and the last good config stays active
+	writeFile(t, path, "workers: {digits: -1}")
+	select {
+	case <-errs:
+	case <-time.After(2 * time.Second):
+		t.Fatal("invalid config not reported")
+	}
+	if w.Current().WorkerCount("digits", 0) != 16 {
+		t.Error("invalid config replaced the current one")
+	}
+}
+
+// TestWatch_SIGHUP tests reload triggered by a signal
+func TestWatch_SIGHUP(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("SIGHUP is not deliverable on Windows")
+	}
+
+	path := filepath.Join(t.TempDir(), "app.json")
+	os.WriteFile(path, []byte(sampleJSON), 0o644)
+
+	broker := pubsub.NewBroker()
+	defer broker.Stop()
+	sub := broker.Subscribe(ChangedTopic)
+	defer broker.Unsubscribe(ChangedTopic, sub)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	w, err := Watch(ctx, path, broker, WithInterval(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		cancel()
+		<-w.Done()
+	}()
+
+	writeFile(t, path, strings.Replace(sampleJSON, `"digits": 8`, `"digits": 2`, 1))
+	p, _ := os.FindProcess(os.Getpid())
+	if err := p.Signal(syscall.SIGHUP); err != nil {
+		t.Fatal(err)
+	}
+
+	if ch := awaitChange(t, sub); ch.Source != "signal" || ch.New.WorkerCount("digits", 0) != 2 {
+		t.Errorf("change source %q workers %v", ch.Source, ch.New.Workers)
+	}
+}
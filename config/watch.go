@@ -0,0 +1,151 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/arifmahmudrana/go-snippets/pubsub"
+)
+
+// ChangedTopic is where a Watcher publishes a Change after every
+// successful reload that altered the configuration.
+const ChangedTopic = "config.changed"
+
+// Change is the payload published on ChangedTopic.
+type Change struct {
+	Old, New *Config
+	Source   string // "file" or "signal"
+}
+
+// Watcher keeps a configuration file loaded and reloads it when the file
+// changes or the process receives SIGHUP.
+type Watcher struct {
+	path     string
+	broker   *pubsub.Broker
+	interval time.Duration
+	onError  func(error)
+
+	mu      sync.RWMutex
+	current *Config
+	raw     []byte
+
+	done chan struct{}
+}
+
+// WatchOption configures a Watcher.
+type WatchOption func(*Watcher)
+
+// WithInterval sets how often the file is polled for changes (default 1s).
+func WithInterval(d time.Duration) WatchOption {
+	return func(w *Watcher) {
+		w.interval = d
+	}
+}
+
+// WithErrorHandler is called when a reload fails; the previous
+// configuration stays in effect. By default errors are ignored.
+func WithErrorHandler(fn func(error)) WatchOption {
+	return func(w *Watcher) {
+		w.onError = fn
+	}
+}
+
+// Watch loads path and keeps watching it until ctx is done. The initial
+// load must succeed; later invalid edits are reported and skipped.
+func Watch(ctx context.Context, path string, broker *pubsub.Broker, opts ...WatchOption) (*Watcher, error) {
+	w := &Watcher{
+		path:     path,
+		broker:   broker,
+		interval: time.Second,
+		done:     make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(w)
+	}
+
+	raw, cfg, err := w.read()
+	if err != nil {
+		return nil, err
+	}
+	w.raw, w.current = raw, cfg
+
+	// register before returning so an early SIGHUP cannot kill the process
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+
+	go w.loop(ctx, hup)
+	return w, nil
+}
+
+// Current returns the active configuration. Callers must not modify it.
+func (w *Watcher) Current() *Config {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.current
+}
+
+// Done is closed when the watcher has stopped.
+func (w *Watcher) Done() <-chan struct{} {
+	return w.done
+}
+
+// read loads the file, keeping the raw bytes for change detection.
+func (w *Watcher) read() ([]byte, *Config, error) {
+	raw, err := os.ReadFile(w.path)
+	if err != nil {
+		return nil, nil, err
+	}
+	cfg, err := Parse(raw, strings.TrimPrefix(filepath.Ext(w.path), "."))
+	return raw, cfg, err
+}
+
+// Reload re-reads the file now. If the content changed and is valid, the
+// new configuration becomes current and a Change is published.
+func (w *Watcher) Reload(source string) error {
+	raw, cfg, err := w.read()
+	if err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	if bytes.Equal(raw, w.raw) {
+		w.mu.Unlock()
+		return nil
+	}
+	old := w.current
+	w.raw, w.current = raw, cfg
+	w.mu.Unlock()
+
+	w.broker.Publish(ChangedTopic, Change{Old: old, New: cfg, Source: source})
+	return nil
+}
+
+func (w *Watcher) loop(ctx context.Context, hup chan os.Signal) {
+	defer close(w.done)
+	defer signal.Stop(hup)
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		source := "file"
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			source = "signal"
+		case <-ticker.C:
+		}
+
+		if err := w.Reload(source); err != nil && w.onError != nil {
+			w.onError(err)
+		}
+	}
+}
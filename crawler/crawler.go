@@ -0,0 +1,221 @@
+// crawler/crawler.go
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/arifmahmudrana/go-snippets/bloom"
+	"github.com/arifmahmudrana/go-snippets/pubsub"
+	"github.com/arifmahmudrana/go-snippets/ratelimit"
+	"github.com/arifmahmudrana/go-snippets/workerpool"
+)
+
+// ResultsTopic is where the crawler publishes one Result per fetched page.
+const ResultsTopic = "crawler.results"
+
+// maxBodySize caps how much of each page is read for link extraction.
+const maxBodySize = 1 << 20
+
+// hrefRe is a deliberately simple link extractor; good enough for a demo.
+var hrefRe = regexp.MustCompile(`(?i)<a\s[^>]*href\s*=\s*["']([^"'#]+)`)
+
+// Config controls crawl scope and politeness.
+type Config struct {
+	Workers   int     // concurrent fetches
+	MaxDepth  int     // link hops from a seed (0 = seeds only)
+	MaxPages  int     // stop after this many fetches (0 = unlimited)
+	HostRate  float64 // requests per second per host
+	HostBurst int     // burst per host
+	SameHost  bool    // only follow links to seed hosts
+	UserAgent string
+	Client    *http.Client
+}
+
+// Result describes one fetched page.
+type Result struct {
+	URL      string
+	Depth    int
+	Status   int
+	Links    int
+	Duration time.Duration
+	Err      error
+}
+
+// Crawler fetches pages breadth-first from a seed list.
+type Crawler struct {
+	cfg     Config
+	broker  *pubsub.Broker
+	limiter *ratelimit.Keyed
+	seen    *bloom.Filter
+	hosts   map[string]bool
+}
+
+// page is a unit of work in the frontier.
+type page struct {
+	url   *url.URL
+	depth int
+}
+
+// fetched is what a worker hands back to the coordinator.
+type fetched struct {
+	result Result
+	page   page
+	links  []*url.URL
+}
+
+// New returns a crawler that publishes results on broker.
+func New(broker *pubsub.Broker, cfg Config) *Crawler {
+	cfg.Workers = max(cfg.Workers, 1)
+	if cfg.HostRate <= 0 {
+		cfg.HostRate = 2
+	}
+	if cfg.Client == nil {
+		cfg.Client = &http.Client{Timeout: 10 * time.Second}
+	}
+	if cfg.UserAgent == "" {
+		cfg.UserAgent = "go-snippets-crawler/1.0"
+	}
+
+	return &Crawler{
+		cfg:     cfg,
+		broker:  broker,
+		limiter: ratelimit.NewKeyed(cfg.HostRate, cfg.HostBurst, time.Minute),
+		seen:    bloom.New(100_000, 0.001),
+		hosts:   make(map[string]bool),
+	}
+}
+
+// Run crawls from seeds until the frontier is exhausted, MaxPages is
+// reached, or ctx is cancelled. It returns the number of pages fetched.
+//
+// A single coordinator goroutine owns the frontier and the seen-set, and
+// at most Workers fetches are in flight, so workers never block handing
+// results back.
+func (c *Crawler) Run(ctx context.Context, seeds []string) (int, error) {
+	var frontier []page
+	for _, s := range seeds {
+		u, err := url.Parse(s)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			continue
+		}
+		u.Fragment = ""
+		c.hosts[u.Host] = true
+		if !c.seen.TestAndAdd([]byte(u.String())) {
+			frontier = append(frontier, page{url: u})
+		}
+	}
+
+	pool := workerpool.New(ctx, c.cfg.Workers)
+	defer pool.Stop()
+
+	found := make(chan fetched, c.cfg.Workers)
+	inflight, visited := 0, 0
+
+	for {
+		for len(frontier) > 0 && inflight < c.cfg.Workers &&
+			(c.cfg.MaxPages == 0 || visited < c.cfg.MaxPages) {
+			p := frontier[0]
+			frontier = frontier[1:]
+
+			err := pool.Submit(ctx, func(ctx context.Context) {
+				found <- c.fetch(ctx, p)
+			})
+			if err != nil {
+				return visited, ctx.Err()
+			}
+			inflight++
+			visited++
+		}
+
+		if inflight == 0 {
+			return visited, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return visited, ctx.Err()
+		case f := <-found:
+			inflight--
+			c.broker.Publish(ResultsTopic, f.result)
+
+			if f.page.depth >= c.cfg.MaxDepth {
+				continue
+			}
+			for _, link := range f.links {
+				if c.cfg.SameHost && !c.hosts[link.Host] {
+					continue
+				}
+				if !c.seen.TestAndAdd([]byte(link.String())) {
+					frontier = append(frontier, page{url: link, depth: f.page.depth + 1})
+				}
+			}
+		}
+	}
+}
+
+// fetch downloads one page, respecting the per-host rate limit, and
+// extracts its links.
+func (c *Crawler) fetch(ctx context.Context, p page) (f fetched) {
+	f = fetched{page: p, result: Result{URL: p.url.String(), Depth: p.depth}}
+	start := time.Now()
+	defer func() { f.result.Duration = time.Since(start) }()
+
+	if err := c.limiter.Wait(ctx, p.url.Host); err != nil {
+		f.result.Err = err
+		return f
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.url.String(), nil)
+	if err != nil {
+		f.result.Err = err
+		return f
+	}
+	req.Header.Set("User-Agent", c.cfg.UserAgent)
+
+	resp, err := c.cfg.Client.Do(req)
+	if err != nil {
+		f.result.Err = err
+		return f
+	}
+	defer resp.Body.Close()
+	f.result.Status = resp.StatusCode
+
+	if resp.StatusCode != http.StatusOK ||
+		!strings.HasPrefix(resp.Header.Get("Content-Type"), "text/html") {
+		return f
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxBodySize))
+	if err != nil {
+		f.result.Err = err
+		return f
+	}
+
+	f.links = extractLinks(p.url, body)
+	f.result.Links = len(f.links)
+	return f
+}
+
+// extractLinks returns the absolute http(s) links found in body.
+func extractLinks(base *url.URL, body []byte) []*url.URL {
+	var links []*url.URL
+	for _, m := range hrefRe.FindAllSubmatch(body, -1) {
+		ref, err := url.Parse(strings.TrimSpace(string(m[1])))
+		if err != nil {
+			continue
+		}
+		u := base.ResolveReference(ref)
+		if u.Scheme != "http" && u.Scheme != "https" {
+			continue
+		}
+		u.Fragment = ""
+		links = append(links, u)
+	}
+	return links
+}
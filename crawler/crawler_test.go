@@ -0,0 +1,155 @@
+// crawler/crawler_test.go
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/arifmahmudrana/go-snippets/pubsub"
+)
+
+// site serves a tiny link graph: / -> a, b; a -> b, c; c -> / ; b -> x (404)
+func site() *httptest.Server {
+	pages := map[string]string{
+		"/":  `<a href="/a">a</a> <a href='/b#top'>b</a> <a href="mailto:x@y">mail</a>`,
+		"/a": `<a href="/b">b</a> <A HREF="c">c</A>`,
+		"/b": `<a href="/x">missing</a>`,
+		"/c": `<a href="/">home</a> <a href="http://other.invalid/">other</a>`,
+	}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, ok := pages[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, body)
+	}))
+}
+
+// crawl runs a crawl and collects the results published on the broker.
+func crawl(t *testing.T, cfg Config, seed string) (int, []Result) {
+	t.Helper()
+
+	broker := pubsub.NewBroker()
+	defer broker.Stop()
+
+	sub := broker.Subscribe(ResultsTopic)
+	defer broker.Unsubscribe(ResultsTopic, sub)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	n, err := New(broker, cfg).Run(ctx, []string{seed})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// exactly one result is published per fetched page
+	results := make([]Result, 0, n)
+	for range n {
+		select {
+		case msg := <-sub.C:
+			results = append(results, msg.Payload.(Result))
+		case <-time.After(time.Second):
+			t.Fatalf("got %d results, want %d", len(results), n)
+		}
+	}
+	return n, results
+}
+
+func paths(results []Result) []string {
+	var out []string
+	for _, r := range results {
+		u, _ := url.Parse(r.URL)
+		out = append(out, u.Path)
+	}
+	slices.Sort(out)
+	return out
+}
+
+// TestCrawler_VisitsEachPageOnce tests deduplication and same-host scoping
+func TestCrawler_VisitsEachPageOnce(t *testing.T) {
+	srv := site()
+	defer srv.Close()
+
+	n, results := crawl(t, Config{Workers: 3, MaxDepth: 5, HostRate: 1000, HostBurst: 10, SameHost: true}, srv.URL+"/")
+
+	want := []string{"/", "/a", "/b", "/c", "/x"}
+	if got := paths(results); !slices.Equal(got, want) {
+		t.Errorf("visited %v, want %v", got, want)
+	}
+	if n != len(want) {
+		t.Errorf("Run() = %d, want %d", n, len(want))
+	}
+	for _, r := range results {
+		if r.Err != nil {
+			t.Errorf("%s: %v", r.URL, r.Err)
+		}
+	}
+}
+
+// TestCrawler_MaxDepth tests that links beyond the depth limit are not followed
+func TestCrawler_MaxDepth(t *testing.T) {
+	srv := site()
+	defer srv.Close()
+
+	_, results := crawl(t, Config{Workers: 2, MaxDepth: 1, HostRate: 1000, HostBurst: 10}, srv.URL+"/")
+
+	if got, want := paths(results), []string{"/", "/a", "/b"}; !slices.Equal(got, want) {
+		t.Errorf("visited %v, want %v", got, want)
+	}
+}
+
+// TestCrawler_MaxPages tests the page budget
+func TestCrawler_MaxPages(t *testing.T) {
+	srv := site()
+	defer srv.Close()
+
+	n, _ := crawl(t, Config{Workers: 1, MaxDepth: 5, MaxPages: 2, HostRate: 1000, HostBurst: 10}, srv.URL+"/")
+	if n != 2 {
+		t.Errorf("Run() = %d, want 2", n)
+	}
+}
+
+// TestCrawler_Cancelled tests that a cancelled crawl returns promptly
+func TestCrawler_Cancelled(t *testing.T) {
+	srv := site()
+	defer srv.Close()
+
+	broker := pubsub.NewBroker()
+	defer broker.Stop()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := New(broker, Config{HostRate: 0.001}).Run(ctx, []string{srv.URL}); err == nil {
+		t.Error("Run() with cancelled context returned nil error")
+	}
+}
+
+// TestCrawler_Duration tests that each result records how long its fetch
+// took
+func TestCrawler_Duration(t *testing.T) {
+	const delay = 20 * time.Millisecond
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(delay)
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, `<a href="/next">next</a>`)
+	}))
+	defer srv.Close()
+
+	_, results := crawl(t, Config{Workers: 2, MaxDepth: 1, HostRate: 1000, HostBurst: 10}, srv.URL+"/")
+	if len(results) != 2 {
+		t.Fatalf("got %d results, want 2", len(results))
+	}
+	for _, r := range results {
+		if r.Duration < delay {
+			t.Errorf("%s: Duration = %v, want at least %v", r.URL, r.Duration, delay)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+// crawler/main.go
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/arifmahmudrana/go-snippets/pubsub"
+)
+
+func main() {
+	seeds := flag.String("seeds", "https://go.dev/", "comma-separated seed URLs")
+	workers := flag.Int("workers", 8, "concurrent fetches")
+	depth := flag.Int("depth", 1, "maximum link depth from a seed")
+	maxPages := flag.Int("max", 50, "maximum pages to fetch (0 = unlimited)")
+	rate := flag.Float64("rate", 2, "requests per second per host")
+	sameHost := flag.Bool("same-host", true, "only follow links to seed hosts")
+	timeout := flag.Duration("timeout", time.Minute, "overall crawl timeout")
+	flag.Parse()
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+	ctx, cancel = context.WithTimeout(ctx, *timeout)
+	defer cancel()
+
+	broker := pubsub.NewBroker()
+	defer broker.Stop()
+
+	// print results as they are published
+	results := broker.Subscribe(ResultsTopic)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for msg := range results.C {
+			r := msg.Payload.(Result)
+			if r.Err != nil {
+				fmt.Printf("[ERR] d=%d %s: %v\n", r.Depth, r.URL, r.Err)
+				continue
+			}
+			fmt.Printf("[%d] d=%d links=%-3d %6s %s\n", r.Status, r.Depth, r.Links, r.Duration.Round(time.Millisecond), r.URL)
+		}
+	}()
+
+	c := New(broker, Config{
+		Workers:   *workers,
+		MaxDepth:  *depth,
+		MaxPages:  *maxPages,
+		HostRate:  *rate,
+		HostBurst: 1,
+		SameHost:  *sameHost,
+	})
+
+	start := time.Now()
+	n, err := c.Run(ctx, strings.Split(*seeds, ","))
+
+	broker.Unsubscribe(ResultsTopic, results)
+	wg.Wait()
+
+	fmt.Printf("[MAIN] Fetched %d pages in %v", n, time.Since(start).Round(time.Millisecond))
+	if err != nil {
+		fmt.Printf(" (stopped: %v)", err)
+	}
+	fmt.Println()
+}
@@ -0,0 +1,260 @@
+package deadlock
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"runtime"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Kind classifies a Report.
+type Kind string
+
+const (
+	// LockOrder means two mutexes were taken in both orders, which can
+	// deadlock even if this run did not.
+	LockOrder Kind = "lock-order"
+	// LongWait means a lock, channel operation or guarded call blocked
+	// for longer than the detector's timeout.
+	LongWait Kind = "long-wait"
+)
+
+// Report describes one detected problem.
+type Report struct {
+	Kind    Kind
+	Message string
+	Stacks  string // all goroutine stacks at the time of the report
+}
+
+func (r Report) String() string {
+	return fmt.Sprintf("deadlock: %s: %s\n%s", r.Kind, r.Message, r.Stacks)
+}
+
+// Detector tracks instrumented mutexes and blocking operations and calls
+// its reporter when something looks stuck. It is meant for tests: the
+// bookkeeping costs a stack walk per lock.
+type Detector struct {
+	timeout  time.Duration
+	onReport func(Report)
+
+	mu    sync.Mutex
+	order map[*Mutex]map[*Mutex]bool // order[a][b]: b was locked while holding a
+	held  map[int64][]*Mutex         // goroutine id -> mutexes it holds
+}
+
+// Option configures a Detector.
+type Option func(*Detector)
+
+// WithTimeout sets how long an operation may block before it is reported
+// as a long wait. The default is 5s.
+func WithTimeout(d time.Duration) Option {
+	return func(det *Detector) {
+		det.timeout = d
+	}
+}
+
+// WithReporter sets the function called for each report. By default
+// reports are printed to stderr.
+func WithReporter(fn func(Report)) Option {
+	return func(det *Detector) {
+		det.onReport = fn
+	}
+}
+
+// New creates a Detector.
+func New(opts ...Option) *Detector {
+	d := &Detector{
+		timeout:  5 * time.Second,
+		onReport: func(r Report) { fmt.Fprintln(os.Stderr, r) },
+		order:    make(map[*Mutex]map[*Mutex]bool),
+		held:     make(map[int64][]*Mutex),
+	}
+	for _, opt := range opts {
+		opt(d)
+	}
+	return d
+}
+
+// ForTest creates a Detector that fails t on every report.
+func ForTest(t testing.TB, opts ...Option) *Detector {
+	return New(append([]Option{WithReporter(func(r Report) {
+		t.Errorf("%s", r)
+	})}, opts...)...)
+}
+
+func (d *Detector) report(kind Kind, format string, args ...any) {
+	d.onReport(Report{Kind: kind, Message: fmt.Sprintf(format, args...), Stacks: stacks()})
+}
+
+// watch reports a long wait for what unless the returned stop function
+// is called within the timeout.
+func (d *Detector) watch(what string) (stop func()) {
+	t := time.AfterFunc(d.timeout, func() {
+		d.report(LongWait, "%s blocked for more than %v", what, d.timeout)
+	})
+	return func() { t.Stop() }
+}
+
+// Within runs fn and reports a long wait if it has not returned within
+// the timeout. It returns false in that case without waiting further,
+// so a deadlocked test fails with stacks instead of hanging until the
+// test binary's global timeout.
+func (d *Detector) Within(name string, fn func()) bool {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		fn()
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-time.After(d.timeout):
+		d.report(LongWait, "%s did not finish within %v", name, d.timeout)
+		return false
+	}
+}
+
+// Send sends v on ch and reports whether it did. If the send blocks for
+// longer than the timeout, it reports a long wait and gives up, like
+// Within, returning false.
+func Send[T any](d *Detector, ch chan<- T, v T) bool {
+	t := time.NewTimer(d.timeout)
+	defer t.Stop()
+	select {
+	case ch <- v:
+		return true
+	case <-t.C:
+		d.report(LongWait, "send blocked for more than %v", d.timeout)
+		return false
+	}
+}
+
+// Recv receives from ch, returning the value and whether it came from a
+// send rather than ch being closed. If the receive blocks for longer than
+// the timeout, it reports a long wait and gives up, like Within,
+// returning the zero value and false.
+func Recv[T any](d *Detector, ch <-chan T) (T, bool) {
+	t := time.NewTimer(d.timeout)
+	defer t.Stop()
+	select {
+	case v, ok := <-ch:
+		return v, ok
+	case <-t.C:
+		d.report(LongWait, "receive blocked for more than %v", d.timeout)
+		var zero T
+		return zero, false
+	}
+}
+
+// Mutex is a sync.Mutex whose lock order and wait times are checked by
+// its Detector.
+type Mutex struct {
+	d    *Detector
+	name string
+	mu   sync.Mutex
+}
+
+// NewMutex returns an instrumented mutex. name appears in reports.
+func (d *Detector) NewMutex(name string) *Mutex {
+	return &Mutex{d: d, name: name}
+}
+
+// Lock locks m, first checking that no other goroutine has taken m and a
+// mutex this goroutine holds in the opposite order.
+func (m *Mutex) Lock() {
+	gid := goid()
+	m.d.beforeLock(gid, m)
+
+	stop := m.d.watch("Lock(" + m.name + ")")
+	m.mu.Lock()
+	stop()
+
+	m.d.mu.Lock()
+	m.d.held[gid] = append(m.d.held[gid], m)
+	m.d.mu.Unlock()
+}
+
+// Unlock unlocks m.
+func (m *Mutex) Unlock() {
+	m.d.release(goid(), m)
+	m.mu.Unlock()
+}
+
+func (d *Detector) beforeLock(gid int64, m *Mutex) {
+	d.mu.Lock()
+	var inverted []string
+	for _, h := range d.held[gid] {
+		if d.order[m][h] {
+			inverted = append(inverted, h.name)
+		}
+		if d.order[h] == nil {
+			d.order[h] = make(map[*Mutex]bool)
+		}
+		d.order[h][m] = true
+	}
+	d.mu.Unlock()
+
+	for _, h := range inverted {
+		d.report(LockOrder, "locking %s while holding %s, but %s was previously locked while holding %s", m.name, h, h, m.name)
+	}
+}
+
+// release forgets that m is held. sync.Mutex may be unlocked by another
+// goroutine, so the owner is searched for if gid does not hold it.
+func (d *Detector) release(gid int64, m *Mutex) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if remove(d.held, gid, m) {
+		return
+	}
+	for g := range d.held {
+		if remove(d.held, g, m) {
+			return
+		}
+	}
+}
+
+func remove(held map[int64][]*Mutex, gid int64, m *Mutex) bool {
+	list := held[gid]
+	for i := len(list) - 1; i >= 0; i-- {
+		if list[i] == m {
+			list = append(list[:i], list[i+1:]...)
+			if len(list) == 0 {
+				delete(held, gid)
+			} else {
+				held[gid] = list
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// goid returns the current goroutine's id, parsed from its stack header.
+func goid() int64 {
+	var buf [64]byte
+	b := buf[:runtime.Stack(buf[:], false)]
+	b = bytes.TrimPrefix(b, []byte("goroutine "))
+	if i := bytes.IndexByte(b, ' '); i > 0 {
+		b = b[:i]
+	}
+	id, _ := strconv.ParseInt(string(b), 10, 64)
+	return id
+}
+
+// stacks returns the stacks of all goroutines.
+func stacks() string {
+	buf := make([]byte, 1<<16)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			return string(buf[:n])
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+}
@@ -0,0 +1,146 @@
+package deadlock
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// collector gathers reports for inspection.
+type collector struct {
+	mu      sync.Mutex
+	reports []Report
+}
+
+func (c *collector) add(r Report) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.reports = append(c.reports, r)
+}
+
+func (c *collector) kinds() []Kind {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var ks []Kind
+	for _, r := range c.reports {
+		ks = append(ks, r.Kind)
+	}
+	return ks
+}
+
+// TestMutex_LockOrder tests that an A→B then B→A ordering is reported
+// even though the two goroutines never actually deadlock
+func TestMutex_LockOrder(t *testing.T) {
+	var c collector
+	d := New(WithReporter(c.add))
+	a, b := d.NewMutex("a"), d.NewMutex("b")
+
+	a.Lock()
+	b.Lock()
+	b.Unlock()
+	a.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		b.Lock()
+		a.Lock()
+		a.Unlock()
+		b.Unlock()
+	}()
+	<-done
+
+	got := c.kinds()
+	if len(got) != 1 || got[0] != LockOrder {
+		t.Fatalf("reports = %v, want [%s]", got, LockOrder)
+	}
+	if msg := c.reports[0].Message; !strings.Contains(msg, "locking a while holding b") {
+		t.Errorf("Message = %q", msg)
+	}
+}
+
+// TestMutex_ConsistentOrder tests that a consistent order is not reported
+func TestMutex_ConsistentOrder(t *testing.T) {
+	d := ForTest(t)
+	a, b := d.NewMutex("a"), d.NewMutex("b")
+	for range 3 {
+		a.Lock()
+		b.Lock()
+		b.Unlock()
+		a.Unlock()
+	}
+}
+
+// TestMutex_LongWait tests that a lock held past the timeout is reported
+// with stacks
+func TestMutex_LongWait(t *testing.T) {
+	var c collector
+	d := New(WithReporter(c.add), WithTimeout(20*time.Millisecond))
+	m := d.NewMutex("m")
+
+	m.Lock()
+	go func() {
+		time.Sleep(60 * time.Millisecond)
+		m.Unlock()
+	}()
+	m.Lock()
+	m.Unlock()
+
+	got := c.kinds()
+	if len(got) != 1 || got[0] != LongWait {
+		t.Fatalf("reports = %v, want [%s]", got, LongWait)
+	}
+	if !strings.Contains(c.reports[0].Stacks, "goroutine ") {
+		t.Error("report has no goroutine stacks")
+	}
+}
+
+// TestWithin tests that a stuck function is reported without hanging
+func TestWithin(t *testing.T) {
+	var c collector
+	d := New(WithReporter(c.add), WithTimeout(20*time.Millisecond))
+
+	if !d.Within("quick", func() {}) {
+		t.Error("Within(quick) = false, want true")
+	}
+
+	block := make(chan struct{})
+	defer close(block)
+	if d.Within("stuck", func() { <-block }) {
+		t.Error("Within(stuck) = true, want false")
+	}
+	if got := c.kinds(); len(got) != 1 || got[0] != LongWait {
+		t.Errorf("reports = %v, want [%s]", got, LongWait)
+	}
+}
+
+// TestSendRecv tests channel wrappers in the non-blocking case
+func TestSendRecv(t *testing.T) {
+	d := ForTest(t)
+	ch := make(chan int, 1)
+	if !Send(d, ch, 3) {
+		t.Error("Send() = false, want true")
+	}
+	if v, ok := Recv(d, ch); v != 3 || !ok {
+		t.Errorf("Recv() = %d, %v, want 3, true", v, ok)
+	}
+}
+
+// TestSendRecv_Stuck tests that blocked channel wrappers are reported and
+// give up instead of hanging
+func TestSendRecv_Stuck(t *testing.T) {
+	var c collector
+	d := New(WithReporter(c.add), WithTimeout(20*time.Millisecond))
+	ch := make(chan int)
+
+	if Send(d, ch, 3) {
+		t.Error("Send() on a channel nobody receives from = true, want false")
+	}
+	if v, ok := Recv(d, ch); v != 0 || ok {
+		t.Errorf("Recv() on a channel nobody sends on = %d, %v, want 0, false", v, ok)
+	}
+	if got := c.kinds(); len(got) != 2 || got[0] != LongWait || got[1] != LongWait {
+		t.Errorf("reports = %v, want [%s %s]", got, LongWait, LongWait)
+	}
+}
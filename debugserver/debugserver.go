@@ -0,0 +1,277 @@
+package debugserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"expvar"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"sort"
+	"sync"
+
+	"github.com/arifmahmudrana/go-snippets/health"
+	"github.com/arifmahmudrana/go-snippets/jobs"
+	"github.com/arifmahmudrana/go-snippets/pubsub"
+)
+
+// StatsFunc returns a JSON-encodable snapshot of some component's state.
+type StatsFunc func() any
+
+// Server serves debugging endpoints on their own port, away from any
+// application traffic:
+//
+//	/debug/pprof/   CPU, heap, goroutine, ... profiles
+//	/debug/goroutines  goroutines by stack and pprof labels; ?label=k=v filters
+//	/debug/vars     expvar
+//	/debug/stats    every registered StatsFunc, by name
+//	/debug/pubsub/trace  the broker's recent events, with WithBroker
+//	/debug/pubsub/limits the broker's Limits; PUT JSON to change them
+//	/metrics             the broker's Stats for Prometheus, with WithBroker
+//	/debug/jobs          jobs and their attempts, with WithJobs; ?state= filters
+//	/debug/jobs/{id}     one job; POST .../retry or .../cancel to act on it
+//	/livez /readyz  health checks
+type Server struct {
+	srv *http.Server
+	ln  net.Listener
+
+	mu     sync.RWMutex
+	stats  map[string]StatsFunc
+	health *health.Registry
+	broker *pubsub.Broker
+	jobs   *jobs.Queue
+}
+
+// Option configures a Server.
+type Option func(*Server)
+
+// WithHealth serves the given registry's checks instead of an empty one.
+func WithHealth(r *health.Registry) Option {
+	return func(s *Server) {
+		s.health = r
+	}
+}
+
+// WithStats publishes fn under /debug/stats/{name}.
+func WithStats(name string, fn StatsFunc) Option {
+	return func(s *Server) {
+		s.stats[name] = fn
+	}
+}
+
+// WithBroker publishes the broker's per-topic publish rates as the
+// "broker" stats, its publish queue depths as "broker_queues", its
+// Stats as "broker_totals" and its subscriptions as
+// "broker_subscribers" (for pubsub top), serves its trace (if created with
+// pubsub.WithTrace) and metrics, and adds a liveness check that pings
+// its run loop.
+func WithBroker(b *pubsub.Broker) Option {
+	return func(s *Server) {
+		s.broker = b
+	}
+}
+
+// WithJobs serves the queue's jobs under /debug/jobs, with endpoints
+// to retry or cancel them, and publishes the number of jobs in each
+// state as the "jobs" stats.
+func WithJobs(q *jobs.Queue) Option {
+	return func(s *Server) {
+		s.jobs = q
+	}
+}
+
+// Start listens on addr (":0" picks a free port) and serves in the
+// background until Close.
+func Start(addr string, opts ...Option) (*Server, error) {
+	s := &Server{stats: make(map[string]StatsFunc)}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if s.health == nil {
+		s.health = health.New()
+	}
+	if b := s.broker; b != nil {
+		s.stats["broker"] = func() any { return b.PublishRates() }
+		s.stats["broker_queues"] = func() any { return b.QueueDepths() }
+		s.stats["broker_totals"] = func() any { return b.Stats() }
+		s.stats["broker_subscribers"] = func() any { return b.Subscribers() }
+		s.health.RegisterLiveness("broker", b.Ping)
+	}
+	if q := s.jobs; q != nil {
+		s.stats["jobs"] = func() any { return q.Depths() }
+	}
+
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	s.ln = ln
+	s.srv = &http.Server{Handler: s.routes()}
+	go s.srv.Serve(ln)
+	return s, nil
+}
+
+func (s *Server) routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.HandleFunc("/debug/goroutines", s.handleGoroutines)
+	mux.Handle("/debug/vars", expvar.Handler())
+	mux.HandleFunc("/debug/stats", s.handleStatsIndex)
+	mux.HandleFunc("/debug/stats/{name}", s.handleStats)
+	if s.broker != nil {
+		mux.HandleFunc("/debug/pubsub/trace", s.handleTrace)
+		mux.HandleFunc("GET /metrics", s.handleMetrics)
+		mux.HandleFunc("GET /debug/pubsub/limits", s.handleLimits)
+		mux.HandleFunc("PUT /debug/pubsub/limits", s.handleSetLimits)
+	}
+	if s.jobs != nil {
+		mux.HandleFunc("GET /debug/jobs", s.handleJobs)
+		mux.HandleFunc("GET /debug/jobs/{id}", s.handleJob)
+		mux.HandleFunc("POST /debug/jobs/{id}/retry", s.handleRetryJob)
+		mux.HandleFunc("POST /debug/jobs/{id}/cancel", s.handleCancelJob)
+	}
+	s.health.Mount(mux)
+	return mux
+}
+
+// Addr returns the address the server is listening on.
+func (s *Server) Addr() string {
+	return s.ln.Addr().String()
+}
+
+// Health returns the registry behind /livez and /readyz so components
+// can add checks after Start.
+func (s *Server) Health() *health.Registry {
+	return s.health
+}
+
+// AddStats publishes fn under /debug/stats/{name}, replacing any
+// previous function with that name.
+func (s *Server) AddStats(name string, fn StatsFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stats[name] = fn
+}
+
+func (s *Server) handleStatsIndex(w http.ResponseWriter, r *http.Request) {
+	s.mu.RLock()
+	names := make([]string, 0, len(s.stats))
+	for name := range s.stats {
+		names = append(names, name)
+	}
+	s.mu.RUnlock()
+	sort.Strings(names)
+	writeJSON(w, names)
+}
+
+func (s *Server) handleStats(w http.ResponseWriter, r *http.Request) {
+	s.mu.RLock()
+	fn, ok := s.stats[r.PathValue("name")]
+	s.mu.RUnlock()
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	writeJSON(w, fn())
+}
+
+func (s *Server) handleTrace(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	if err := s.broker.DumpTrace(w); errors.Is(err, pubsub.ErrTraceDisabled) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+	}
+}
+
+func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	s.broker.WriteMetrics(w)
+}
+
+func (s *Server) handleLimits(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, s.broker.Limits())
+}
+
+// handleSetLimits replaces the limits with the request body; fields it
+// leaves out keep their current value.
+func (s *Server) handleSetLimits(w http.ResponseWriter, r *http.Request) {
+	l := s.broker.Limits()
+	if err := json.NewDecoder(r.Body).Decode(&l); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := s.broker.SetLimits(l); err != nil {
+		code := http.StatusBadRequest
+		if errors.Is(err, pubsub.ErrBrokerClosed) {
+			code = http.StatusServiceUnavailable
+		}
+		http.Error(w, err.Error(), code)
+		return
+	}
+	writeJSON(w, l)
+}
+
+func (s *Server) handleJobs(w http.ResponseWriter, r *http.Request) {
+	list := s.jobs.Jobs(jobs.State(r.URL.Query().Get("state")))
+	if list == nil {
+		list = []jobs.Job{} // [] rather than null
+	}
+	writeJSON(w, list)
+}
+
+func (s *Server) handleJob(w http.ResponseWriter, r *http.Request) {
+	h, err := s.jobs.Get(r.PathValue("id"))
+	if err != nil {
+		jobError(w, err)
+		return
+	}
+	writeJSON(w, h.Job())
+}
+
+func (s *Server) handleRetryJob(w http.ResponseWriter, r *http.Request) {
+	h, err := s.jobs.Retry(r.PathValue("id"))
+	if err != nil {
+		jobError(w, err)
+		return
+	}
+	writeJSON(w, h.Job())
+}
+
+func (s *Server) handleCancelJob(w http.ResponseWriter, r *http.Request) {
+	if err := s.jobs.Cancel(r.PathValue("id")); err != nil {
+		jobError(w, err)
+		return
+	}
+	s.handleJob(w, r)
+}
+
+// jobError maps an error from the jobs queue to an HTTP status.
+func jobError(w http.ResponseWriter, err error) {
+	code := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, jobs.ErrNotFound):
+		code = http.StatusNotFound
+	case errors.Is(err, jobs.ErrState):
+		code = http.StatusConflict
+	case errors.Is(err, jobs.ErrClosed):
+		code = http.StatusServiceUnavailable
+	}
+	http.Error(w, err.Error(), code)
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
+
+// Close shuts the server down, waiting for in-flight requests until ctx
+// is done.
+func (s *Server) Close(ctx context.Context) error {
+	return s.srv.Shutdown(ctx)
+}
@@ -0,0 +1,256 @@
+package debugserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/arifmahmudrana/go-snippets/jobs"
+	"github.com/arifmahmudrana/go-snippets/pubsub"
+	"github.com/arifmahmudrana/go-snippets/workerpool"
+)
+
+func get(t *testing.T, url string) (int, string) {
+	t.Helper()
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatalf("GET %s: %v", url, err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(body)
+}
+
+// TestServer_Endpoints tests that every endpoint is mounted
+func TestServer_Endpoints(t *testing.T) {
+	broker := pubsub.NewBroker(pubsub.WithTrace(10))
+	defer broker.Stop()
+	broker.Publish("news", "hello")
+
+	s, err := Start("127.0.0.1:0",
+		WithBroker(broker),
+		WithStats("answer", func() any { return map[string]int{"value": 42} }),
+	)
+	if err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	defer s.Close(context.Background())
+	base := "http://" + s.Addr()
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/debug/pprof/", "goroutine"},
+		{"/debug/vars", "memstats"},
+		{"/debug/stats", `"answer"`},
+		{"/debug/stats/answer", `"value": 42`},
+		{"/debug/stats/broker", `"news"`},
+		{"/debug/stats/broker_queues", "{}"},
+		{"/debug/stats/broker_totals", `"Published": 1`},
+		{"/debug/stats/broker_subscribers", "null"},
+		{"/metrics", "pubsub_published_total 1\n"},
+		{"/livez", `"broker"`},
+		{"/readyz", `"ok"`},
+		{"/debug/pubsub/trace", "publish     news #1"},
+	}
+	for _, tt := range tests {
+		code, body := get(t, base+tt.path)
+		if code != http.StatusOK || !strings.Contains(body, tt.want) {
+			t.Errorf("GET %s = %d %q, want 200 containing %q", tt.path, code, body, tt.want)
+		}
+	}
+
+	if code, _ := get(t, base+"/debug/stats/missing"); code != http.StatusNotFound {
+		t.Errorf("GET /debug/stats/missing = %d, want 404", code)
+	}
+}
+
+// TestServer_AddStats tests registering stats after Start
+func TestServer_AddStats(t *testing.T) {
+	s, err := Start("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close(context.Background())
+
+	s.AddStats("late", func() any { return []int{1, 2} })
+	_, body := get(t, "http://"+s.Addr()+"/debug/stats/late")
+	var got []int
+	if err := json.Unmarshal([]byte(body), &got); err != nil || len(got) != 2 {
+		t.Errorf("late stats = %q, want [1, 2]", body)
+	}
+}
+
+// TestServer_Limits tests reading and changing the broker's limits
+func TestServer_Limits(t *testing.T) {
+	broker := pubsub.NewBroker(pubsub.WithLimits(pubsub.Limits{MaxTopics: 5}))
+	defer broker.Stop()
+	s, err := Start("127.0.0.1:0", WithBroker(broker))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close(context.Background())
+	url := "http://" + s.Addr() + "/debug/pubsub/limits"
+
+	put := func(body string) int {
+		req, _ := http.NewRequest(http.MethodPut, url, strings.NewReader(body))
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("PUT %s: %v", url, err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	if code := put(`{"max_subscribers": 2}`); code != http.StatusOK {
+		t.Errorf("PUT limits = %d, want 200", code)
+	}
+	if got, want := broker.Limits(), (pubsub.Limits{MaxSubscribers: 2, MaxTopics: 5}); got != want {
+		t.Errorf("Limits() = %+v, want %+v", got, want)
+	}
+	if _, body := get(t, url); !strings.Contains(body, `"max_subscribers": 2`) {
+		t.Errorf("GET limits = %q, want max_subscribers 2", body)
+	}
+	if code := put(`{"max_topics": -1}`); code != http.StatusBadRequest {
+		t.Errorf("PUT negative limit = %d, want 400", code)
+	}
+}
+
+// TestServer_Jobs tests inspecting, retrying and canceling jobs
+func TestServer_Jobs(t *testing.T) {
+	var fail atomic.Bool
+	fail.Store(true)
+	release := make(chan struct{})
+	q := jobs.New(func(ctx context.Context, payload []byte) ([]byte, error) {
+		if string(payload) == "block" {
+			<-release
+			return nil, nil
+		}
+		if fail.Load() {
+			return nil, errors.New("smtp timeout")
+		}
+		return []byte("sent"), nil
+	}, jobs.WithMaxAttempts(1), jobs.WithWorkers(1))
+	defer q.Close()
+	defer close(release)
+
+	failed, _ := q.Enqueue([]byte("mail"))
+	failed.Wait(context.Background())
+	q.Enqueue([]byte("block"))
+	queued, _ := q.Enqueue([]byte("mail 2"))
+
+	s, err := Start("127.0.0.1:0", WithJobs(q))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close(context.Background())
+	base := "http://" + s.Addr()
+
+	post := func(path string) (int, string) {
+		resp, err := http.Post(base+path, "", nil)
+		if err != nil {
+			t.Fatalf("POST %s: %v", path, err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(body)
+	}
+
+	gets := []struct {
+		path string
+		code int
+		want string
+	}{
+		{"/debug/jobs?state=failed", http.StatusOK, `"error": "smtp timeout"`},
+		{"/debug/jobs?state=canceled", http.StatusOK, "[]"},
+		{"/debug/jobs/1", http.StatusOK, `"state": "failed"`},
+		{"/debug/jobs/9", http.StatusNotFound, "not found"},
+		{"/debug/stats/jobs", http.StatusOK, `"queued": 1`},
+	}
+	for _, tt := range gets {
+		if code, body := get(t, base+tt.path); code != tt.code || !strings.Contains(body, tt.want) {
+			t.Errorf("GET %s = %d %q, want %d containing %q", tt.path, code, body, tt.code, tt.want)
+		}
+	}
+
+	posts := []struct {
+		path string
+		code int
+		want string
+	}{
+		{"/debug/jobs/" + queued.ID() + "/cancel", http.StatusOK, `"state": "canceled"`},
+		{"/debug/jobs/" + queued.ID() + "/cancel", http.StatusConflict, "state"},
+		{"/debug/jobs/" + failed.ID() + "/retry", http.StatusOK, `"state": "queued"`},
+		{"/debug/jobs/9/retry", http.StatusNotFound, "not found"},
+	}
+	fail.Store(false)
+	for _, tt := range posts {
+		if code, body := post(tt.path); code != tt.code || !strings.Contains(body, tt.want) {
+			t.Errorf("POST %s = %d %q, want %d containing %q", tt.path, code, body, tt.code, tt.want)
+		}
+	}
+}
+
+// TestServer_Goroutines tests finding labeled goroutines by label
+func TestServer_Goroutines(t *testing.T) {
+	broker := pubsub.NewBroker()
+	defer broker.Stop()
+	release := make(chan struct{})
+	started := make(chan struct{})
+	unsubscribe := broker.SubscribeFunc("orders", func(pubsub.Message) {
+		close(started)
+		<-release
+	})
+	defer func() {
+		close(release)
+		unsubscribe()
+	}()
+	broker.Publish("orders", 1)
+	<-started
+
+	pool := workerpool.New(context.Background(), 2)
+	defer pool.Stop()
+
+	s, err := Start("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close(context.Background())
+	base := "http://" + s.Addr() + "/debug/goroutines"
+
+	tests := []struct {
+		query   string
+		want    []string
+		notWant []string
+	}{
+		{"", []string{`"component":"pubsub.broker"`, `"component":"workerpool"`}, nil},
+		{"?label=topic=orders", []string{`"component":"pubsub.subscriber"`, "1 matching"}, []string{"pubsub.broker"}},
+		{"?label=component=workerpool&label=worker=1", []string{`"worker":"1"`, "1 matching"}, []string{`"worker":"0"`}},
+		{"?label=topic=missing", []string{"0 matching"}, []string{"# labels"}},
+	}
+	for _, tt := range tests {
+		code, body := get(t, base+tt.query)
+		if code != http.StatusOK {
+			t.Errorf("GET %s = %d, want 200", tt.query, code)
+		}
+		for _, want := range tt.want {
+			if !strings.Contains(body, want) {
+				t.Errorf("GET %s = %q, want it to contain %q", tt.query, body, want)
+			}
+		}
+		for _, notWant := range tt.notWant {
+			if strings.Contains(body, notWant) {
+				t.Errorf("GET %s = %q, want it not to contain %q", tt.query, body, notWant)
+			}
+		}
+	}
+
+	if code, _ := get(t, base+"?label=topic"); code != http.StatusBadRequest {
+		t.Errorf("GET ?label=topic = %d, want 400", code)
+	}
+}
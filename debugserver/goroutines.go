@@ -0,0 +1,86 @@
+package debugserver
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"runtime/pprof"
+	"strconv"
+	"strings"
+)
+
+// WriteGoroutines writes the goroutine profile in its text form, one
+// entry per distinct stack with its count and pprof labels, keeping only
+// the goroutines whose labels include every key/value in match. The
+// broker, worker pools and pipelines label theirs with "component" and,
+// where it applies, "topic", "stage" and "worker", so
+//
+//	WriteGoroutines(w, map[string]string{"topic": "orders"})
+//
+// shows everything working on the orders topic.
+func WriteGoroutines(w io.Writer, match map[string]string) error {
+	var buf bytes.Buffer
+	if err := pprof.Lookup("goroutine").WriteTo(&buf, 1); err != nil {
+		return err
+	}
+	entries := strings.Split(strings.TrimSpace(buf.String()), "\n\n")
+	header, entries := entries[0], entries[1:] // "goroutine profile: total N" and the first entry
+	if i := strings.IndexByte(header, '\n'); i >= 0 {
+		header, entries = header[:i], append([]string{header[i+1:]}, entries...)
+	}
+
+	var kept []string
+	n := 0
+	for _, e := range entries {
+		if !hasLabels(e, match) {
+			continue
+		}
+		kept = append(kept, e)
+		count, _, _ := strings.Cut(e, " ")
+		c, _ := strconv.Atoi(count)
+		n += c
+	}
+	if len(match) > 0 {
+		header += fmt.Sprintf(", %d matching", n)
+	}
+	_, err := fmt.Fprintf(w, "%s\n\n%s\n", header, strings.Join(kept, "\n\n"))
+	return err
+}
+
+// hasLabels reports whether a profile entry's labels line has every
+// key/value in match.
+func hasLabels(entry string, match map[string]string) bool {
+	if len(match) == 0 {
+		return true
+	}
+	var labels string
+	for _, line := range strings.Split(entry, "\n") {
+		if l, ok := strings.CutPrefix(line, "# labels: "); ok {
+			labels = l
+			break
+		}
+	}
+	for k, v := range match {
+		if !strings.Contains(labels, strconv.Quote(k)+":"+strconv.Quote(v)) {
+			return false
+		}
+	}
+	return true
+}
+
+// handleGoroutines serves WriteGoroutines, filtered by any number of
+// label=key=value parameters.
+func (s *Server) handleGoroutines(w http.ResponseWriter, r *http.Request) {
+	match := make(map[string]string)
+	for _, l := range r.URL.Query()["label"] {
+		k, v, ok := strings.Cut(l, "=")
+		if !ok {
+			http.Error(w, fmt.Sprintf("label %q: want key=value", l), http.StatusBadRequest)
+			return
+		}
+		match[k] = v
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	WriteGoroutines(w, match)
+}
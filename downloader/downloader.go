@@ -0,0 +1,323 @@
+// downloader/downloader.go
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/arifmahmudrana/go-snippets/hedge"
+	"github.com/arifmahmudrana/go-snippets/pubsub"
+	"github.com/arifmahmudrana/go-snippets/store"
+	"github.com/arifmahmudrana/go-snippets/workerpool"
+)
+
+// ProgressTopic receives a Progress update whenever a chunk completes.
+const ProgressTopic = "downloader.progress"
+
+// Progress reports how far a download has come.
+type Progress struct {
+	URL         string
+	Done, Total int64 // bytes
+	ChunksDone  int
+	ChunksTotal int
+}
+
+// Options controls a download.
+type Options struct {
+	Workers   int
+	ChunkSize int64
+	Retries   int           // extra attempts per chunk
+	Backoff   time.Duration // first retry delay, doubled each time
+	Hedge     time.Duration // send a second request for a chunk still unfinished after this; 0 never does
+	Client    *http.Client
+}
+
+// state is the resumable part of a download, persisted in the Store.
+type state struct {
+	URL       string `json:"url"`
+	Size      int64  `json:"size"`
+	ETag      string `json:"etag"`
+	ChunkSize int64  `json:"chunk_size"`
+	Done      []bool `json:"done"`
+}
+
+// Downloader fetches files in parallel byte ranges.
+type Downloader struct {
+	opts   Options
+	broker *pubsub.Broker
+	store  store.Store
+}
+
+// New returns a downloader that reports progress on broker and keeps
+// resume state in st.
+func New(broker *pubsub.Broker, st store.Store, opts Options) *Downloader {
+	opts.Workers = max(opts.Workers, 1)
+	if opts.ChunkSize <= 0 {
+		opts.ChunkSize = 1 << 20
+	}
+	if opts.Backoff <= 0 {
+		opts.Backoff = 100 * time.Millisecond
+	}
+	if opts.Client == nil {
+		opts.Client = http.DefaultClient
+	}
+	return &Downloader{opts: opts, broker: broker, store: st}
+}
+
+// stateKey is the Store key holding resume state for a url/output pair.
+func stateKey(url, dst string) string {
+	return "download:" + url + "\x00" + dst
+}
+
+// probe asks the server for the file size, ETag and range support.
+func (d *Downloader) probe(ctx context.Context, url string) (size int64, etag string, ranges bool, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
+	if err != nil {
+		return 0, "", false, err
+	}
+	resp, err := d.opts.Client.Do(req)
+	if err != nil {
+		return 0, "", false, err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, "", false, fmt.Errorf("HEAD %s: %s", url, resp.Status)
+	}
+	return resp.ContentLength, resp.Header.Get("ETag"), resp.Header.Get("Accept-Ranges") == "bytes", nil
+}
+
+// loadState returns saved state if it still matches the remote file,
+// otherwise a fresh state.
+func (d *Downloader) loadState(ctx context.Context, key, url string, size int64, etag string) *state {
+	fresh := &state{
+		URL:       url,
+		Size:      size,
+		ETag:      etag,
+		ChunkSize: d.opts.ChunkSize,
+		Done:      make([]bool, (size+d.opts.ChunkSize-1)/d.opts.ChunkSize),
+	}
+
+	b, err := d.store.Get(ctx, key)
+	if err != nil {
+		return fresh
+	}
+	var saved state
+	if json.Unmarshal(b, &saved) != nil ||
+		saved.Size != size || saved.ETag != etag || saved.ChunkSize <= 0 ||
+		int64(len(saved.Done)) != (size+saved.ChunkSize-1)/saved.ChunkSize {
+		return fresh
+	}
+	return &saved
+}
+
+// Download fetches url into dst. If an earlier attempt was interrupted,
+// chunks already on disk are skipped. It returns the number of bytes
+// actually transferred by this call.
+func (d *Downloader) Download(ctx context.Context, url, dst string) (int64, error) {
+	size, etag, ranges, err := d.probe(ctx, url)
+	if err != nil {
+		return 0, err
+	}
+	if !ranges || size <= 0 {
+		return d.downloadWhole(ctx, url, dst)
+	}
+
+	key := stateKey(url, dst)
+	st := d.loadState(ctx, key, url, size, etag)
+
+	f, err := os.OpenFile(dst, os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	if err := f.Truncate(size); err != nil {
+		return 0, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		mu          sync.Mutex // guards st, transferred, firstErr
+		transferred int64
+		firstErr    error
+	)
+	save := func() {
+		b, _ := json.Marshal(st)
+		d.store.Put(ctx, key, b)
+	}
+	report := func() {
+		p := Progress{URL: url, Total: size, ChunksTotal: len(st.Done)}
+		for i, done := range st.Done {
+			if done {
+				p.ChunksDone++
+				p.Done += min(st.ChunkSize, size-int64(i)*st.ChunkSize)
+			}
+		}
+		d.broker.Publish(ProgressTopic, p)
+	}
+
+	mu.Lock()
+	save()
+	report()
+	mu.Unlock()
+
+	pool := workerpool.New(ctx, d.opts.Workers)
+	for i, done := range st.Done {
+		if done {
+			continue
+		}
+		start := int64(i) * st.ChunkSize
+		end := min(start+st.ChunkSize, size) - 1
+
+		err := pool.Submit(ctx, func(ctx context.Context) {
+			n, err := d.fetchChunk(ctx, url, etag, f, start, end)
+			if err == nil {
+				// the chunk must be on disk before the state says so, or
+				// a crash could leave a resume skipping a chunk never
+				// written
+				err = f.Sync()
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			transferred += n
+			if err != nil {
+				if firstErr == nil {
+					firstErr = err
+					cancel() // stop the other chunks
+				}
+				return
+			}
+			st.Done[i] = true
+			save()
+			report()
+		})
+		if err != nil {
+			break
+		}
+	}
+	pool.Close()
+
+	if firstErr == nil {
+		firstErr = ctx.Err()
+	}
+	if firstErr != nil {
+		return transferred, firstErr
+	}
+	if err := f.Sync(); err != nil {
+		return transferred, err
+	}
+	return transferred, d.store.Delete(context.WithoutCancel(ctx), key)
+}
+
+// fetchChunk downloads bytes [start, end] into f, retrying with
+// exponential backoff. It returns the bytes written by the final attempt.
+func (d *Downloader) fetchChunk(ctx context.Context, url, etag string, f *os.File, start, end int64) (int64, error) {
+	backoff := d.opts.Backoff
+	var err error
+	for attempt := 0; ; attempt++ {
+		var n int64
+		n, err = d.fetchRange(ctx, url, etag, f, start, end)
+		if err == nil || attempt >= d.opts.Retries || ctx.Err() != nil {
+			return n, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// fetchRange performs one ranged GET, hedged if Options.Hedge is set.
+// A hedged chunk is buffered in memory, so the losing request never
+// writes to f.
+func (d *Downloader) fetchRange(ctx context.Context, url, etag string, f *os.File, start, end int64) (int64, error) {
+	if d.opts.Hedge <= 0 {
+		return d.copyRange(ctx, url, etag, io.NewOffsetWriter(f, start), start, end)
+	}
+	data, _, err := hedge.Do(ctx, func(ctx context.Context) ([]byte, error) {
+		var buf bytes.Buffer
+		_, err := d.copyRange(ctx, url, etag, &buf, start, end)
+		return buf.Bytes(), err
+	}, d.opts.Hedge, 1)
+	if err != nil {
+		return 0, err
+	}
+	n, err := f.WriteAt(data, start)
+	return int64(n), err
+}
+
+// copyRange performs one ranged GET, copying the body to w.
+func (d *Downloader) copyRange(ctx context.Context, url, etag string, w io.Writer, start, end int64) (int64, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Range", "bytes="+strconv.FormatInt(start, 10)+"-"+strconv.FormatInt(end, 10))
+	if etag != "" {
+		// if the file changed, the server sends 200 with the full body
+		req.Header.Set("If-Range", etag)
+	}
+
+	resp, err := d.opts.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusPartialContent {
+		return 0, fmt.Errorf("GET %s range %d-%d: %s", url, start, end, resp.Status)
+	}
+
+	want := end - start + 1
+	n, err := io.Copy(w, io.LimitReader(resp.Body, want))
+	if err != nil {
+		return n, err
+	}
+	if n != want {
+		return n, fmt.Errorf("range %d-%d: got %d bytes, want %d: %w", start, end, n, want, io.ErrUnexpectedEOF)
+	}
+	return n, nil
+}
+
+// downloadWhole is the fallback for servers without range support.
+func (d *Downloader) downloadWhole(ctx context.Context, url, dst string) (int64, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := d.opts.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+
+	f, err := os.Create(dst)
+	if err != nil {
+		return 0, err
+	}
+	n, err := io.Copy(f, resp.Body)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		d.broker.Publish(ProgressTopic, Progress{URL: url, Done: n, Total: n, ChunksDone: 1, ChunksTotal: 1})
+	}
+	return n, err
+}
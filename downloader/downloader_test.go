@@ -0,0 +1,230 @@
+// downloader/downloader_test.go
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/arifmahmudrana/go-snippets/pubsub"
+	"github.com/arifmahmudrana/go-snippets/store"
+)
+
+// fileServer serves content with range support. fail decides whether a
+// given Range request should get a 500 instead, and stall whether it
+// should hang until the client gives up.
+type fileServer struct {
+	content []byte
+	ranges  bool
+
+	mu       sync.Mutex
+	requests []string
+	fail     func(rng string, attempt int) bool
+	stall    func(rng string, attempt int) bool
+	attempts map[string]int
+}
+
+func (s *fileServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !s.ranges {
+		w.Write(s.content)
+		return
+	}
+
+	rng := r.Header.Get("Range")
+	s.mu.Lock()
+	if rng != "" {
+		s.requests = append(s.requests, rng)
+	}
+	if s.attempts == nil {
+		s.attempts = make(map[string]int)
+	}
+	s.attempts[rng]++
+	fail := s.fail != nil && rng != "" && s.fail(rng, s.attempts[rng])
+	stall := s.stall != nil && rng != "" && s.stall(rng, s.attempts[rng])
+	s.mu.Unlock()
+
+	if stall {
+		<-r.Context().Done()
+		return
+	}
+	if fail {
+		http.Error(w, "boom", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("ETag", `"v1"`)
+	http.ServeContent(w, r, "file.bin", time.Time{}, bytes.NewReader(s.content))
+}
+
+func randomContent(t *testing.T, n int) []byte {
+	t.Helper()
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// waitComplete returns once a Progress with Done == Total is received.
+func waitComplete(t *testing.T, sub *pubsub.Subscriber) Progress {
+	t.Helper()
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case msg := <-sub.C:
+			if p := msg.Payload.(Progress); p.Done == p.Total && p.ChunksDone == p.ChunksTotal {
+				return p
+			}
+		case <-timeout:
+			t.Fatal("download never reported completion")
+		}
+	}
+}
+
+// TestDownload_ParallelWithRetries tests chunked download with transient failures
+func TestDownload_ParallelWithRetries(t *testing.T) {
+	content := randomContent(t, 300_000)
+	fs := &fileServer{content: content, ranges: true, fail: func(rng string, attempt int) bool {
+		return attempt == 1 // every chunk fails once
+	}}
+	srv := httptest.NewServer(fs)
+	defer srv.Close()
+
+	broker := pubsub.NewBroker()
+	defer broker.Stop()
+	sub := broker.Subscribe(ProgressTopic)
+	defer broker.Unsubscribe(ProgressTopic, sub)
+
+	st := store.NewMemory()
+	dst := filepath.Join(t.TempDir(), "out.bin")
+	d := New(broker, st, Options{Workers: 4, ChunkSize: 64 << 10, Retries: 2, Backoff: time.Millisecond})
+
+	n, err := d.Download(context.Background(), srv.URL, dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(len(content)) {
+		t.Errorf("transferred %d bytes, want %d", n, len(content))
+	}
+
+	got, _ := os.ReadFile(dst)
+	if !bytes.Equal(got, content) {
+		t.Error("downloaded content differs")
+	}
+	// updates are buffered by the subscriber, so they can be read afterwards
+	if p := waitComplete(t, sub); p.ChunksTotal != 5 {
+		t.Errorf("ChunksTotal = %d, want 5", p.ChunksTotal)
+	}
+
+	// completed downloads leave no resume state behind
+	if keys, _ := st.List(context.Background(), "download:"); len(keys) != 0 {
+		t.Errorf("state left behind: %v", keys)
+	}
+}
+
+// TestDownload_Resume tests that a second run only fetches missing chunks
+func TestDownload_Resume(t *testing.T) {
+	content := randomContent(t, 100_000)
+	const broken = "bytes=40000-59999"
+	fs := &fileServer{content: content, ranges: true, fail: func(rng string, attempt int) bool {
+		return rng == broken
+	}}
+	srv := httptest.NewServer(fs)
+	defer srv.Close()
+
+	broker := pubsub.NewBroker()
+	defer broker.Stop()
+
+	st := store.NewMemory()
+	dst := filepath.Join(t.TempDir(), "out.bin")
+	opts := Options{Workers: 1, ChunkSize: 20_000, Retries: 0}
+
+	// first run: one chunk keeps failing
+	if _, err := New(broker, st, opts).Download(context.Background(), srv.URL, dst); err == nil {
+		t.Fatal("first run should fail")
+	}
+
+	// second run: server is healthy again
+	fs.mu.Lock()
+	fs.fail = nil
+	fs.requests = nil
+	fs.mu.Unlock()
+
+	n, err := New(broker, st, opts).Download(context.Background(), srv.URL, dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	for _, rng := range fs.requests {
+		if rng == "bytes=0-19999" || rng == "bytes=20000-39999" {
+			t.Errorf("completed chunk %s fetched again", rng)
+		}
+	}
+	if n >= int64(len(content)) {
+		t.Errorf("resumed run transferred %d bytes, want < %d", n, len(content))
+	}
+	if got, _ := os.ReadFile(dst); !bytes.Equal(got, content) {
+		t.Error("downloaded content differs after resume")
+	}
+}
+
+// TestDownload_Hedge tests that a stalled chunk request is overtaken by
+// a hedged one
+func TestDownload_Hedge(t *testing.T) {
+	content := randomContent(t, 100_000)
+	fs := &fileServer{content: content, ranges: true, stall: func(rng string, attempt int) bool {
+		return rng == "bytes=20000-39999" && attempt == 1
+	}}
+	srv := httptest.NewServer(fs)
+	defer srv.Close()
+
+	broker := pubsub.NewBroker()
+	defer broker.Stop()
+
+	dst := filepath.Join(t.TempDir(), "out.bin")
+	d := New(broker, store.NewMemory(), Options{Workers: 2, ChunkSize: 20_000, Hedge: 20 * time.Millisecond})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := d.Download(ctx, srv.URL, dst); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := os.ReadFile(dst); !bytes.Equal(got, content) {
+		t.Error("downloaded content differs")
+	}
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	if got := fs.attempts["bytes=20000-39999"]; got != 2 {
+		t.Errorf("stalled chunk requested %d times, want 2", got)
+	}
+}
+
+// TestDownload_NoRangeSupport tests the single-stream fallback
+func TestDownload_NoRangeSupport(t *testing.T) {
+	content := []byte(strings.Repeat("gopher", 1000))
+	srv := httptest.NewServer(&fileServer{content: content})
+	defer srv.Close()
+
+	broker := pubsub.NewBroker()
+	defer broker.Stop()
+
+	dst := filepath.Join(t.TempDir(), "out.bin")
+	n, err := New(broker, store.NewMemory(), Options{}).Download(context.Background(), srv.URL, dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(len(content)) {
+		t.Errorf("transferred %d bytes, want %d", n, len(content))
+	}
+	if got, _ := os.ReadFile(dst); !bytes.Equal(got, content) {
+		t.Error("downloaded content differs")
+	}
+}
@@ -0,0 +1,75 @@
+// downloader/main.go
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/arifmahmudrana/go-snippets/pubsub"
+	"github.com/arifmahmudrana/go-snippets/store"
+)
+
+func main() {
+	url := flag.String("url", "", "file to download")
+	out := flag.String("out", "", "output file (default: last URL path element)")
+	workers := flag.Int("workers", 4, "parallel ranged requests")
+	chunkKB := flag.Int64("chunk", 1024, "chunk size in KiB")
+	retries := flag.Int("retries", 3, "retries per chunk")
+	hedgeAfter := flag.Duration("hedge", 0, "send a second request for a chunk still unfinished after this (0 = never)")
+	stateDir := flag.String("state", ".downloads", "directory for resume state")
+	flag.Parse()
+
+	if *url == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if *out == "" {
+		*out = path.Base(*url)
+	}
+
+	st, err := store.NewFile(*stateDir)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "state:", err)
+		os.Exit(1)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	broker := pubsub.NewBroker()
+	defer broker.Stop()
+
+	// render progress updates from the broker
+	progress := broker.Subscribe(ProgressTopic)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for msg := range progress.C {
+			p := msg.Payload.(Progress)
+			fmt.Printf("\r[PROGRESS] %6.2f%%  %d/%d chunks  %d/%d bytes",
+				float64(p.Done)*100/float64(max(p.Total, 1)), p.ChunksDone, p.ChunksTotal, p.Done, p.Total)
+		}
+		fmt.Println()
+	}()
+
+	d := New(broker, st, Options{Workers: *workers, ChunkSize: *chunkKB << 10, Retries: *retries, Hedge: *hedgeAfter})
+
+	start := time.Now()
+	n, err := d.Download(ctx, *url, *out)
+
+	broker.Unsubscribe(ProgressTopic, progress)
+	wg.Wait()
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[ERR] %v (transferred %d bytes; rerun to resume)\n", err, n)
+		os.Exit(1)
+	}
+	fmt.Printf("[MAIN] Saved %s, transferred %d bytes in %v\n", *out, n, time.Since(start).Round(time.Millisecond))
+}
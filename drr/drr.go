@@ -0,0 +1,248 @@
+package drr
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/arifmahmudrana/go-snippets/ratetrack"
+	"github.com/arifmahmudrana/go-snippets/workerpool"
+)
+
+var (
+	// ErrClosed is returned by Submit after the scheduler has been closed.
+	ErrClosed = errors.New("drr: scheduler closed")
+	// ErrQueueFull is returned by Submit when the tenant's queue is at
+	// its limit. Rejecting instead of blocking keeps one tenant's flood
+	// from stalling producers shared with other tenants.
+	ErrQueueFull = errors.New("drr: tenant queue full")
+)
+
+// TenantStats describes one tenant's traffic.
+type TenantStats struct {
+	Weight    int
+	Pending   int
+	Submitted int64
+	Completed int64
+	Cost      int64              // total cost of completed tasks
+	Rate      ratetrack.Snapshot // completed tasks over time
+}
+
+type job struct {
+	cost int
+	task workerpool.Task
+}
+
+type tenant struct {
+	name    string
+	weight  int
+	queue   []job
+	deficit int
+	turn    bool // deficit already topped up for the current visit
+	active  bool
+
+	submitted, completed, cost int64
+}
+
+// Scheduler runs tasks from many tenants on a fixed set of workers using
+// deficit round-robin: tenants are visited in turn, each visit adds
+// quantum×weight to the tenant's deficit, and the tenant may dispatch
+// tasks while their cost fits in the deficit. Over time every backlogged
+// tenant gets a share of the workers proportional to its weight,
+// regardless of how much it submits.
+type Scheduler struct {
+	ctx     context.Context
+	cancel  context.CancelFunc
+	quantum int
+	limit   int
+	wg      sync.WaitGroup
+	rates   *ratetrack.Set
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	tenants map[string]*tenant
+	active  []*tenant // backlogged tenants in visiting order
+	cur     int
+	closed  bool
+}
+
+// Option configures a Scheduler.
+type Option func(*Scheduler)
+
+// WithQuantum sets the deficit added per visit for weight 1. Costs are
+// in the same unit. The default is 1.
+func WithQuantum(n int) Option {
+	return func(s *Scheduler) {
+		s.quantum = max(n, 1)
+	}
+}
+
+// WithQueueLimit bounds each tenant's pending tasks. The default is
+// unbounded.
+func WithQueueLimit(n int) Option {
+	return func(s *Scheduler) {
+		s.limit = n
+	}
+}
+
+// New starts a scheduler with the given number of workers (at least 1).
+// Cancelling ctx stops it like Stop does.
+func New(ctx context.Context, workers int, opts ...Option) *Scheduler {
+	ctx, cancel := context.WithCancel(ctx)
+	s := &Scheduler{
+		ctx:     ctx,
+		cancel:  cancel,
+		quantum: 1,
+		rates:   ratetrack.NewSet(10*time.Second, time.Minute),
+		tenants: make(map[string]*tenant),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.cond = sync.NewCond(&s.mu)
+	context.AfterFunc(ctx, func() {
+		s.mu.Lock()
+		s.cond.Broadcast()
+		s.mu.Unlock()
+	})
+
+	workers = max(workers, 1)
+	s.wg.Add(workers)
+	for range workers {
+		go s.worker()
+	}
+	return s
+}
+
+func (s *Scheduler) tenant(name string) *tenant {
+	t, ok := s.tenants[name]
+	if !ok {
+		t = &tenant{name: name, weight: 1}
+		s.tenants[name] = t
+	}
+	return t
+}
+
+// SetWeight gives tenant a share proportional to weight (default 1).
+func (s *Scheduler) SetWeight(name string, weight int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tenant(name).weight = max(weight, 1)
+}
+
+// Submit queues task for tenant. cost is the task's size in quantum
+// units (at least 1); use 1 for all tasks to balance task counts.
+func (s *Scheduler) Submit(name string, cost int, task workerpool.Task) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed || s.ctx.Err() != nil {
+		return ErrClosed
+	}
+
+	t := s.tenant(name)
+	if s.limit > 0 && len(t.queue) >= s.limit {
+		return ErrQueueFull
+	}
+	t.queue = append(t.queue, job{cost: max(cost, 1), task: task})
+	t.submitted++
+	if !t.active {
+		t.active = true
+		s.active = append(s.active, t)
+	}
+	s.cond.Signal()
+	return nil
+}
+
+// next picks the next job by deficit round-robin. Caller holds mu and
+// there is at least one active tenant.
+func (s *Scheduler) next() (*tenant, job) {
+	for {
+		if s.cur >= len(s.active) {
+			s.cur = 0
+		}
+		t := s.active[s.cur]
+		if !t.turn {
+			t.deficit += s.quantum * t.weight
+			t.turn = true
+		}
+
+		head := t.queue[0]
+		if head.cost > t.deficit {
+			// can't afford it this round: keep the deficit, move on
+			t.turn = false
+			s.cur++
+			continue
+		}
+
+		t.queue = t.queue[1:]
+		t.deficit -= head.cost
+		if len(t.queue) == 0 {
+			// idle tenants don't bank credit
+			t.deficit, t.turn, t.active = 0, false, false
+			s.active = append(s.active[:s.cur], s.active[s.cur+1:]...)
+		}
+		return t, head
+	}
+}
+
+func (s *Scheduler) worker() {
+	defer s.wg.Done()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for {
+		for len(s.active) == 0 && !s.closed && s.ctx.Err() == nil {
+			s.cond.Wait()
+		}
+		if len(s.active) == 0 || s.ctx.Err() != nil {
+			return
+		}
+
+		t, j := s.next()
+		s.mu.Unlock()
+		j.task(s.ctx)
+		s.rates.Add(t.name, 1)
+		s.mu.Lock()
+		t.completed++
+		t.cost += int64(j.cost)
+	}
+}
+
+// Stats returns per-tenant statistics.
+func (s *Scheduler) Stats() map[string]TenantStats {
+	rates := s.rates.Snapshot()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	out := make(map[string]TenantStats, len(s.tenants))
+	for name, t := range s.tenants {
+		out[name] = TenantStats{
+			Weight:    t.weight,
+			Pending:   len(t.queue),
+			Submitted: t.submitted,
+			Completed: t.completed,
+			Cost:      t.cost,
+			Rate:      rates[name],
+		}
+	}
+	return out
+}
+
+// Close stops accepting tasks and waits for queued ones to finish.
+func (s *Scheduler) Close() {
+	s.mu.Lock()
+	s.closed = true
+	s.cond.Broadcast()
+	s.mu.Unlock()
+
+	s.wg.Wait()
+	s.cancel()
+}
+
+// Stop cancels running tasks, discards queued ones, and waits for the
+// workers to exit.
+func (s *Scheduler) Stop() {
+	s.cancel()
+	s.Close()
+}
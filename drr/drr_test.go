@@ -0,0 +1,108 @@
+package drr
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+)
+
+// record returns a task that appends name to a shared log.
+func record(mu *sync.Mutex, log *[]string, name string) func(context.Context) {
+	return func(context.Context) {
+		mu.Lock()
+		*log = append(*log, name)
+		mu.Unlock()
+	}
+}
+
+// TestScheduler_Fairness tests that a flooding tenant does not starve a
+// small one: with one worker, dispatch alternates between them
+func TestScheduler_Fairness(t *testing.T) {
+	s := New(context.Background(), 1)
+
+	var mu sync.Mutex
+	var log []string
+	// hold the worker so everything below is queued before dispatch starts
+	gate := make(chan struct{})
+	s.Submit("gate", 1, func(context.Context) { <-gate })
+	for range 100 {
+		s.Submit("flood", 1, record(&mu, &log, "flood"))
+	}
+	for range 5 {
+		s.Submit("small", 1, record(&mu, &log, "small"))
+	}
+	close(gate)
+	s.Close()
+
+	// all of small's tasks run within the first 10 dispatches
+	small := 0
+	for _, name := range log[:10] {
+		if name == "small" {
+			small++
+		}
+	}
+	if small != 5 {
+		t.Errorf("small ran %d of its 5 tasks in the first 10 slots: %v", small, log[:10])
+	}
+}
+
+// TestScheduler_WeightsAndCost tests shares proportional to weight and
+// inversely to cost
+func TestScheduler_WeightsAndCost(t *testing.T) {
+	s := New(context.Background(), 1, WithQuantum(2))
+	s.SetWeight("gold", 3)
+
+	var mu sync.Mutex
+	var log []string
+	gate := make(chan struct{})
+	s.Submit("gate", 1, func(context.Context) { <-gate })
+	for range 60 {
+		s.Submit("gold", 1, record(&mu, &log, "gold"))
+		s.Submit("bronze", 1, record(&mu, &log, "bronze"))
+		s.Submit("heavy", 2, record(&mu, &log, "heavy"))
+	}
+	close(gate)
+	s.Close()
+
+	// per round: gold 6 units → 6 tasks, bronze 2 → 2 tasks, heavy 2 → 1 task
+	counts := map[string]int{}
+	for _, name := range log[:45] {
+		counts[name]++
+	}
+	if counts["gold"] != 30 || counts["bronze"] != 10 || counts["heavy"] != 5 {
+		t.Errorf("first 5 rounds = %v, want gold 30, bronze 10, heavy 5", counts)
+	}
+
+	st := s.Stats()
+	if st["heavy"].Completed != 60 || st["heavy"].Cost != 120 || st["gold"].Weight != 3 {
+		t.Errorf("Stats() = %+v", st)
+	}
+}
+
+// TestScheduler_Limits tests queue limits and closed errors
+func TestScheduler_Limits(t *testing.T) {
+	s := New(context.Background(), 1, WithQueueLimit(1))
+	block := make(chan struct{})
+	s.Submit("a", 1, func(context.Context) { <-block })
+	s.Submit("a", 1, func(context.Context) {}) // might be dispatched already
+
+	var err error
+	for range 3 {
+		if err = s.Submit("a", 1, func(context.Context) {}); err != nil {
+			break
+		}
+	}
+	if !errors.Is(err, ErrQueueFull) {
+		t.Errorf("Submit() over limit = %v, want %v", err, ErrQueueFull)
+	}
+	if err := s.Submit("b", 1, func(context.Context) {}); err != nil {
+		t.Errorf("Submit() for other tenant = %v, want nil", err)
+	}
+
+	close(block)
+	s.Close()
+	if err := s.Submit("a", 1, func(context.Context) {}); !errors.Is(err, ErrClosed) {
+		t.Errorf("Submit() after Close = %v, want %v", err, ErrClosed)
+	}
+}
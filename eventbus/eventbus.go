@@ -0,0 +1,274 @@
+package eventbus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/arifmahmudrana/go-snippets/pubsub"
+)
+
+var (
+	// ErrNilEvent is returned by Publish for a nil event, which has no
+	// type to route it by.
+	ErrNilEvent = errors.New("eventbus: nil event")
+	// ErrDropped is reported to the error handler when the broker drops
+	// events for a handler whose policy allows it (see WithPolicy).
+	ErrDropped = errors.New("eventbus: events dropped")
+)
+
+// HandlerFunc handles a single domain event of type E.
+type HandlerFunc[E any] func(ctx context.Context, event E) error
+
+// Bus is a typed facade over a pubsub.Broker. Events are routed by their
+// Go type, so a handler registered for OrderCreated only ever receives
+// OrderCreated values.
+type Bus struct {
+	broker  *pubsub.Broker
+	onError func(event any, err error)
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu       sync.Mutex
+	handlers []*registration
+	closed   bool
+}
+
+// registration tracks one handler's subscription and in-flight calls.
+type registration struct {
+	topic string
+	sub   *pubsub.Subscriber
+	wg    sync.WaitGroup
+}
+
+// Option configures a Bus.
+type Option func(*Bus)
+
+// WithErrorHandler sets a callback invoked when a handler still fails
+// after all retries, and with ErrDropped and a nil event when events
+// were dropped before reaching a handler. By default such errors are
+// discarded.
+func WithErrorHandler(fn func(event any, err error)) Option {
+	return func(b *Bus) {
+		b.onError = fn
+	}
+}
+
+// handlerConfig holds per-handler settings.
+type handlerConfig struct {
+	concurrency int
+	retries     int
+	backoff     time.Duration
+	policy      pubsub.Policy
+}
+
+// HandlerOption configures a single handler registration.
+type HandlerOption func(*handlerConfig)
+
+// WithConcurrency limits how many events a handler processes at once.
+// The default is 1, which keeps events in publish order.
+func WithConcurrency(n int) HandlerOption {
+	return func(c *handlerConfig) {
+		c.concurrency = max(n, 1)
+	}
+}
+
+// WithRetries retries a failing handler up to n more times, waiting
+// backoff before the first retry and doubling it after each attempt.
+func WithRetries(n int, backoff time.Duration) HandlerOption {
+	return func(c *handlerConfig) {
+		c.retries = max(n, 0)
+		c.backoff = backoff
+	}
+}
+
+// WithPolicy sets what the broker does when the handler falls behind,
+// with its concurrency used up and its buffer full. The default,
+// pubsub.Block, loses no event but holds up the broker, and so every
+// publisher, until the handler catches up. The policies that drop
+// events instead report each drop to the error handler as ErrDropped.
+func WithPolicy(p pubsub.Policy) HandlerOption {
+	return func(c *handlerConfig) {
+		c.policy = p
+	}
+}
+
+// New creates a Bus on top of an existing broker. The bus does not own
+// the broker: Close releases the bus's subscriptions but leaves the
+// broker running.
+func New(broker *pubsub.Broker, opts ...Option) *Bus {
+	ctx, cancel := context.WithCancel(context.Background())
+	b := &Bus{
+		broker: broker,
+		ctx:    ctx,
+		cancel: cancel,
+	}
+	for _, opt := range opts {
+		opt(b)
+	}
+	return b
+}
+
+// topics numbers the event types seen so far, for topicFor. Types are
+// keyed by identity: two can share a name, such as []x.T for two
+// packages named x, or types declared inside functions.
+var topics struct {
+	sync.Mutex
+	m map[reflect.Type]string
+}
+
+// topicFor returns the broker topic used for events of type t. It is the
+// same for every Bus, so buses sharing a broker deliver to each other's
+// handlers.
+func topicFor(t reflect.Type) string {
+	topics.Lock()
+	defer topics.Unlock()
+	topic, ok := topics.m[t]
+	if !ok {
+		if topics.m == nil {
+			topics.m = make(map[reflect.Type]string)
+		}
+		topic = "eventbus." + strconv.Itoa(len(topics.m)+1)
+		topics.m[t] = topic
+	}
+	return topic
+}
+
+// Publish sends event to every handler registered for its type. It
+// fails with ErrNilEvent if event is nil.
+func (b *Bus) Publish(event any) error {
+	if event == nil {
+		return ErrNilEvent
+	}
+	b.broker.Publish(topicFor(reflect.TypeOf(event)), event)
+	return nil
+}
+
+// Handle registers fn for events of type E and returns a function that
+// removes the registration. It is a package-level function because Go
+// methods cannot have type parameters.
+func Handle[E any](b *Bus, fn HandlerFunc[E], opts ...HandlerOption) (unregister func()) {
+	cfg := handlerConfig{concurrency: 1, policy: pubsub.Block}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return func() {}
+	}
+
+	reg := &registration{topic: topicFor(reflect.TypeOf((*E)(nil)).Elem())}
+	reg.sub = b.broker.Subscribe(reg.topic, pubsub.WithPolicy(cfg.policy))
+	b.handlers = append(b.handlers, reg)
+
+	reg.wg.Add(1)
+	go b.dispatch(reg, cfg, func(ctx context.Context, event any) error {
+		return fn(ctx, event.(E))
+	})
+
+	var once sync.Once
+	return func() {
+		once.Do(func() { b.remove(reg) })
+	}
+}
+
+// dispatch reads events for one handler and runs them with at most
+// cfg.concurrency calls in flight.
+func (b *Bus) dispatch(reg *registration, cfg handlerConfig, call func(context.Context, any) error) {
+	defer reg.wg.Done()
+
+	slots := make(chan struct{}, cfg.concurrency)
+	var dropped uint64
+	reportDrops := func() {
+		if n := reg.sub.Dropped(); n > dropped && b.onError != nil {
+			b.onError(nil, fmt.Errorf("%w: %d for %s", ErrDropped, n-dropped, reg.topic))
+			dropped = n
+		}
+	}
+	defer reportDrops()
+	for msg := range reg.sub.C {
+		reportDrops()
+		select {
+		case <-b.ctx.Done():
+			return
+		case slots <- struct{}{}:
+		}
+
+		reg.wg.Add(1)
+		go func(event any) {
+			defer func() {
+				<-slots
+				reg.wg.Done()
+			}()
+
+			if err := b.callWithRetry(cfg, call, event); err != nil && b.onError != nil {
+				b.onError(event, err)
+			}
+		}(msg.Payload)
+	}
+}
+
+// callWithRetry invokes call, retrying with exponential backoff until it
+// succeeds, retries are exhausted, or the bus is closed.
+func (b *Bus) callWithRetry(cfg handlerConfig, call func(context.Context, any) error, event any) error {
+	backoff := cfg.backoff
+	var err error
+	for attempt := 0; attempt <= cfg.retries; attempt++ {
+		if err = call(b.ctx, event); err == nil {
+			return nil
+		}
+		if attempt == cfg.retries {
+			break
+		}
+
+		select {
+		case <-b.ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+	return err
+}
+
+// remove unsubscribes reg and waits for its in-flight calls to finish.
+func (b *Bus) remove(reg *registration) {
+	b.mu.Lock()
+	for i, r := range b.handlers {
+		if r == reg {
+			b.handlers = append(b.handlers[:i], b.handlers[i+1:]...)
+			break
+		}
+	}
+	b.mu.Unlock()
+
+	b.broker.Unsubscribe(reg.topic, reg.sub)
+	reg.wg.Wait()
+}
+
+// Close unregisters all handlers, cancels the context passed to running
+// handlers, and waits for them to return.
+func (b *Bus) Close() {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return
+	}
+	b.closed = true
+	handlers := b.handlers
+	b.handlers = nil
+	b.mu.Unlock()
+
+	b.cancel()
+	for _, reg := range handlers {
+		b.broker.Unsubscribe(reg.topic, reg.sub)
+		reg.wg.Wait()
+	}
+}
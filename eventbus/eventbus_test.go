@@ -0,0 +1,324 @@
+package eventbus
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/arifmahmudrana/go-snippets/chaos"
+	"github.com/arifmahmudrana/go-snippets/pubsub"
+)
+
+type OrderCreated struct {
+	ID string
+}
+
+type OrderShipped struct {
+	ID string
+}
+
+// TestHandle_DispatchByType tests that handlers only see their own event type
+func TestHandle_DispatchByType(t *testing.T) {
+	broker := pubsub.NewBroker()
+	defer broker.Stop()
+	bus := New(broker)
+	defer bus.Close()
+
+	created := make(chan OrderCreated, 1)
+	shipped := make(chan OrderShipped, 1)
+	Handle(bus, func(ctx context.Context, e OrderCreated) error {
+		created <- e
+		return nil
+	})
+	Handle(bus, func(ctx context.Context, e OrderShipped) error {
+		shipped <- e
+		return nil
+	})
+
+	bus.Publish(OrderCreated{ID: "1"})
+	bus.Publish(OrderShipped{ID: "2"})
+
+	select {
+	case e := <-created:
+		if e.ID != "1" {
+			t.Errorf("OrderCreated.ID = %q, want %q", e.ID, "1")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("OrderCreated handler not called")
+	}
+
+	select {
+	case e := <-shipped:
+		if e.ID != "2" {
+			t.Errorf("OrderShipped.ID = %q, want %q", e.ID, "2")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("OrderShipped handler not called")
+	}
+}
+
+// TestHandle_Retries tests that failing handlers are retried and errors reported
+func TestHandle_Retries(t *testing.T) {
+	broker := pubsub.NewBroker()
+	defer broker.Stop()
+
+	var mu sync.Mutex
+	var reported error
+	done := make(chan struct{})
+	bus := New(broker, WithErrorHandler(func(event any, err error) {
+		mu.Lock()
+		reported = err
+		mu.Unlock()
+		close(done)
+	}))
+	defer bus.Close()
+
+	var calls atomic.Int32
+	errBoom := errors.New("boom")
+	Handle(bus, func(ctx context.Context, e OrderCreated) error {
+		calls.Add(1)
+		return errBoom
+	}, WithRetries(2, time.Millisecond))
+
+	bus.Publish(OrderCreated{ID: "1"})
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("error handler not called")
+	}
+
+	if got := calls.Load(); got != 3 {
+		t.Errorf("handler called %d times, want 3", got)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if !errors.Is(reported, errBoom) {
+		t.Errorf("reported error = %v, want %v", reported, errBoom)
+	}
+}
+
+// TestHandle_Concurrency tests that the concurrency limit is respected
+func TestHandle_Concurrency(t *testing.T) {
+	broker := pubsub.NewBroker()
+	defer broker.Stop()
+	bus := New(broker)
+	defer bus.Close()
+
+	const limit = 2
+	var inFlight, peak atomic.Int32
+	var wg sync.WaitGroup
+	wg.Add(6)
+	Handle(bus, func(ctx context.Context, e OrderCreated) error {
+		defer wg.Done()
+		n := inFlight.Add(1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		inFlight.Add(-1)
+		return nil
+	}, WithConcurrency(limit))
+
+	for range 6 {
+		bus.Publish(OrderCreated{})
+	}
+	wg.Wait()
+
+	if got := peak.Load(); got > limit {
+		t.Errorf("peak concurrency = %d, want <= %d", got, limit)
+	}
+}
+
+// TestHandle_Unregister tests that an unregistered handler receives nothing
+func TestHandle_Unregister(t *testing.T) {
+	broker := pubsub.NewBroker()
+	defer broker.Stop()
+	bus := New(broker)
+	defer bus.Close()
+
+	var calls atomic.Int32
+	unregister := Handle(bus, func(ctx context.Context, e OrderCreated) error {
+		calls.Add(1)
+		return nil
+	})
+	unregister()
+
+	bus.Publish(OrderCreated{ID: "1"})
+	time.Sleep(50 * time.Millisecond)
+
+	if got := calls.Load(); got != 0 {
+		t.Errorf("handler called %d times after unregister, want 0", got)
+	}
+}
+
+// TestHandle_ChaosRetries tests that retries absorb injected handler
+// failures: every event is handled exactly once successfully
+func TestHandle_ChaosRetries(t *testing.T) {
+	broker := pubsub.NewBroker()
+	defer broker.Stop()
+	bus := New(broker, WithErrorHandler(func(event any, err error) {
+		t.Errorf("event %v failed after retries: %v", event, err)
+	}))
+	defer bus.Close()
+
+	in := chaos.New(chaos.Plan{Seed: 3, ErrorRate: 0.3})
+	var mu sync.Mutex
+	handled := map[string]int{}
+	Handle(bus, chaos.Handler(in, func(ctx context.Context, e OrderCreated) error {
+		mu.Lock()
+		handled[e.ID]++
+		mu.Unlock()
+		return nil
+	}), WithRetries(10, time.Millisecond))
+
+	const n = 20
+	for i := range n {
+		bus.Publish(OrderCreated{ID: string(rune('a' + i))})
+	}
+
+	chaos.Eventually(t, 2*time.Second, chaos.Invariant{
+		Name: "every event handled once",
+		Check: func() error {
+			mu.Lock()
+			defer mu.Unlock()
+			if len(handled) != n {
+				return errors.New("missing events")
+			}
+			for id, c := range handled {
+				if c != 1 {
+					return errors.New("event " + id + " handled more than once")
+				}
+			}
+			return nil
+		},
+	})
+	if in.Stats().Errors == 0 {
+		t.Error("no faults injected")
+	}
+}
+
+// TestBus_PublishNil tests that a nil event is refused rather than
+// panicking
+func TestBus_PublishNil(t *testing.T) {
+	broker := pubsub.NewBroker()
+	defer broker.Stop()
+	bus := New(broker)
+	defer bus.Close()
+
+	if err := bus.Publish(nil); !errors.Is(err, ErrNilEvent) {
+		t.Errorf("Publish(nil) error = %v, want ErrNilEvent", err)
+	}
+	var e *OrderCreated // typed, so it still has a topic
+	if err := bus.Publish(e); err != nil {
+		t.Errorf("Publish((*OrderCreated)(nil)) error = %v, want nil", err)
+	}
+}
+
+// TestHandle_SlowHandler tests that by default a handler that falls
+// behind gets every event, and that with a dropping policy the drops are
+// reported
+func TestHandle_SlowHandler(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []HandlerOption
+		drop bool
+	}{
+		{"block", nil, false},
+		{"drop-newest", []HandlerOption{WithPolicy(pubsub.DropNewest)}, true},
+	}
+	for _, tt := range tests {
+		broker := pubsub.NewBroker()
+		var dropped atomic.Int32
+		bus := New(broker, WithErrorHandler(func(event any, err error) {
+			if errors.Is(err, ErrDropped) {
+				dropped.Add(1)
+			}
+		}))
+
+		gate := make(chan struct{})
+		var handled atomic.Int32
+		Handle(bus, func(ctx context.Context, e OrderCreated) error {
+			<-gate
+			handled.Add(1)
+			return nil
+		}, tt.opts...)
+
+		const n = 50
+		go func() {
+			for range n {
+				bus.Publish(OrderCreated{})
+			}
+		}()
+		if tt.drop {
+			for deadline := time.Now().Add(2 * time.Second); broker.Stats().Dropped == 0 && time.Now().Before(deadline); {
+				time.Sleep(time.Millisecond)
+			}
+		}
+		close(gate)
+		if !tt.drop {
+			for deadline := time.Now().Add(2 * time.Second); handled.Load() < n && time.Now().Before(deadline); {
+				time.Sleep(time.Millisecond)
+			}
+			if got := handled.Load(); got != n {
+				t.Errorf("%s: handled %d events, want %d", tt.name, got, n)
+			}
+		}
+		bus.Close()
+		broker.Stop()
+		if got := dropped.Load() > 0; got != tt.drop {
+			t.Errorf("%s: drops reported = %v, want %v", tt.name, got, tt.drop)
+		}
+	}
+}
+
+// TestHandle_SameName tests that distinct types with the same name are
+// routed apart
+func TestHandle_SameName(t *testing.T) {
+	broker := pubsub.NewBroker()
+	defer broker.Stop()
+	bus := New(broker)
+	defer bus.Close()
+
+	type T struct{ A int }
+	gotA := make(chan []T, 2)
+	Handle(bus, func(ctx context.Context, e []T) error {
+		gotA <- e
+		return nil
+	})
+	gotB := make(chan string, 2)
+	func() {
+		type T struct{ B string } // also []eventbus.T
+		Handle(bus, func(ctx context.Context, e []T) error {
+			gotB <- e[0].B
+			return nil
+		})
+		bus.Publish([]T{{B: "b"}})
+	}()
+	bus.Publish([]T{{A: 1}})
+
+	for range 2 {
+		select {
+		case e := <-gotA:
+			if len(e) != 1 || e[0].A != 1 {
+				t.Errorf("[]T{A} handler got %v", e)
+			}
+		case b := <-gotB:
+			if b != "b" {
+				t.Errorf("[]T{B} handler got %q", b)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("handler not called")
+		}
+	}
+	time.Sleep(10 * time.Millisecond)
+	if len(gotA)+len(gotB) != 0 {
+		t.Errorf("%d events delivered to the handler of the other type", len(gotA)+len(gotB))
+	}
+}
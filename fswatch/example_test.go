@@ -0,0 +1,41 @@
+package fswatch_test
+
+import (
+	"context"
+	"log"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"time"
+
+	"github.com/arifmahmudrana/go-snippets/fswatch"
+	"github.com/arifmahmudrana/go-snippets/pubsub"
+)
+
+// Rebuild on change: every Go source change under the current directory
+// triggers a build. The debounce turns a save, or a checkout touching
+// many files, into few events, and SubscribeFunc runs one build at a
+// time.
+func ExampleWatch() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	broker := pubsub.NewBroker()
+	defer broker.Stop()
+	unsubscribe := broker.SubscribeFunc("src", func(m pubsub.Message) {
+		e := m.Payload.(fswatch.Event)
+		log.Printf("%s %s: rebuilding", e.Op, e.Path)
+		if out, err := exec.CommandContext(ctx, "go", "build", "./...").CombinedOutput(); err != nil {
+			log.Printf("build failed: %v\n%s", err, out)
+		}
+	})
+	defer unsubscribe()
+
+	err := fswatch.Watch(ctx, broker, "src", []string{"."},
+		fswatch.WithRecursive(),
+		fswatch.WithDebounce(300*time.Millisecond),
+		fswatch.WithFilter(func(path string) bool { return filepath.Ext(path) == ".go" }),
+	)
+	log.Println(err)
+}
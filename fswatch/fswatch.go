@@ -0,0 +1,224 @@
+package fswatch
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/arifmahmudrana/go-snippets/pubsub"
+	"github.com/fsnotify/fsnotify"
+)
+
+// Op is what happened to a path.
+type Op string
+
+const (
+	Create Op = "create"
+	Modify Op = "modify"
+	Delete Op = "delete" // removed, or renamed away
+)
+
+// Event is the payload Watch publishes.
+type Event struct {
+	Path string `json:"path"`
+	Op   Op     `json:"op"`
+}
+
+// publishTimeout is how long Watch waits for a full publish queue before
+// reporting the event as lost.
+const publishTimeout = time.Second
+
+type config struct {
+	debounce  time.Duration
+	recursive bool
+	filter    func(path string) bool
+	topic     func(topic string, e Event) string
+	onError   func(error)
+}
+
+// Option configures Watch.
+type Option func(*config)
+
+// WithDebounce sets how long a path must stay quiet before its event is
+// published (default 100ms). Events for one path within that time are
+// merged: an editor's save, often a burst of writes, becomes one Modify,
+// and a file created and deleted again in between is not reported.
+func WithDebounce(d time.Duration) Option {
+	return func(c *config) {
+		c.debounce = d
+	}
+}
+
+// WithRecursive watches the directories under the given ones too,
+// including those created while watching.
+func WithRecursive() Option {
+	return func(c *config) {
+		c.recursive = true
+	}
+}
+
+// WithFilter reports only paths for which keep returns true, e.g. to
+// skip editor swap files or build output.
+func WithFilter(keep func(path string) bool) Option {
+	return func(c *config) {
+		c.filter = keep
+	}
+}
+
+// WithOpTopics publishes each event on topic.{op}, e.g. "src.modify",
+// instead of on topic itself.
+func WithOpTopics() Option {
+	return func(c *config) {
+		c.topic = func(topic string, e Event) string { return topic + "." + string(e.Op) }
+	}
+}
+
+// WithErrorHandler sets a callback for errors that do not stop Watch:
+// the watcher's own, such as a kernel queue overflow, and events that
+// could not be published in time. By default they are discarded.
+func WithErrorHandler(fn func(error)) Option {
+	return func(c *config) {
+		c.onError = fn
+	}
+}
+
+// pending is an event waiting out the debounce delay.
+type pending struct {
+	op  Op
+	due time.Time
+}
+
+// Watch publishes an Event on topic for every file created, modified or
+// deleted in paths, which may be files or directories, until ctx is done
+// or the broker stops. It returns ctx.Err() or ErrBrokerClosed; a path
+// that cannot be watched fails it at once.
+func Watch(ctx context.Context, b *pubsub.Broker, topic string, paths []string, opts ...Option) error {
+	cfg := config{
+		debounce: 100 * time.Millisecond,
+		topic:    func(topic string, e Event) string { return topic },
+		onError:  func(error) {},
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	for _, p := range paths {
+		if err := add(w, p, cfg.recursive); err != nil {
+			return err
+		}
+	}
+
+	events := make(map[string]pending)
+	timer := time.NewTimer(time.Hour)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case err := <-w.Errors:
+			cfg.onError(err)
+		case ev := <-w.Events:
+			op, ok := opOf(ev.Op)
+			if op == Create && cfg.recursive {
+				// watch new directories even if the filter hides them
+				if fi, err := os.Stat(ev.Name); err == nil && fi.IsDir() {
+					if err := add(w, ev.Name, true); err != nil {
+						cfg.onError(err)
+					}
+				}
+			}
+			if !ok || (cfg.filter != nil && !cfg.filter(ev.Name)) {
+				continue
+			}
+			prev, seen := events[ev.Name]
+			if op, ok = merge(prev.op, seen, op); !ok {
+				delete(events, ev.Name)
+			} else {
+				events[ev.Name] = pending{op: op, due: time.Now().Add(cfg.debounce)}
+			}
+		case <-timer.C:
+		}
+
+		// publish what is due and sleep until the next is
+		now, next := time.Now(), time.Time{}
+		for path, p := range events {
+			if p.due.After(now) {
+				if next.IsZero() || p.due.Before(next) {
+					next = p.due
+				}
+				continue
+			}
+			delete(events, path)
+			e := Event{Path: path, Op: p.op}
+			err := b.PublishTimeout(cfg.topic(topic, e), e, publishTimeout)
+			if errors.Is(err, pubsub.ErrBrokerClosed) {
+				return err
+			} else if err != nil {
+				cfg.onError(err)
+			}
+		}
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		if !next.IsZero() {
+			timer.Reset(next.Sub(now))
+		}
+	}
+}
+
+// add watches path, and with recursive every directory under it.
+func add(w *fsnotify.Watcher, path string, recursive bool) error {
+	if !recursive {
+		return w.Add(path)
+	}
+	return filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || p == path {
+			return w.Add(p)
+		}
+		return nil
+	})
+}
+
+// opOf maps an fsnotify operation to an Op. Permission changes are not
+// reported.
+func opOf(op fsnotify.Op) (Op, bool) {
+	switch {
+	case op.Has(fsnotify.Remove), op.Has(fsnotify.Rename):
+		return Delete, true
+	case op.Has(fsnotify.Create):
+		return Create, true
+	case op.Has(fsnotify.Write):
+		return Modify, true
+	}
+	return "", false
+}
+
+// merge combines a pending event, if seen, with the next one for the
+// same path. It reports false when they cancel out.
+func merge(prev Op, seen bool, next Op) (Op, bool) {
+	switch {
+	case !seen:
+		return next, true
+	case prev == Create && next == Modify:
+		return Create, true
+	case prev == Create && next == Delete:
+		return "", false // came and went
+	case prev == Delete && next == Create:
+		return Modify, true // replaced, as by a save through a temporary file
+	}
+	return next, true
+}
@@ -0,0 +1,135 @@
+package fswatch
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/arifmahmudrana/go-snippets/pubsub"
+)
+
+// watch starts Watch on dir and returns a subscriber to its topic.
+func watch(t *testing.T, dir string, opts ...Option) *pubsub.Subscriber {
+	t.Helper()
+	b := pubsub.NewBroker()
+	t.Cleanup(b.Stop)
+	sub := b.Subscribe("fs")
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- Watch(ctx, b, "fs", []string{dir}, append([]Option{WithDebounce(20 * time.Millisecond)}, opts...)...)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		if err := <-done; !errors.Is(err, context.Canceled) {
+			t.Errorf("Watch() = %v, want %v", err, context.Canceled)
+		}
+	})
+	time.Sleep(50 * time.Millisecond) // let it start watching
+	return sub
+}
+
+// expect reads the next event and checks it.
+func expect(t *testing.T, sub *pubsub.Subscriber, path string, op Op) {
+	t.Helper()
+	select {
+	case m := <-sub.C:
+		if got, want := m.Payload.(Event), (Event{Path: path, Op: op}); got != want {
+			t.Errorf("event = %+v, want %+v", got, want)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatalf("no event, want %s %s", op, path)
+	}
+}
+
+// expectNone checks that no event arrives for a while.
+func expectNone(t *testing.T, sub *pubsub.Subscriber) {
+	t.Helper()
+	select {
+	case m := <-sub.C:
+		t.Errorf("event = %+v, want none", m.Payload)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+// TestWatch tests that bursts of changes to a file are debounced into
+// one event each
+func TestWatch(t *testing.T) {
+	dir := t.TempDir()
+	sub := watch(t, dir)
+	path := filepath.Join(dir, "main.go")
+
+	f, _ := os.Create(path)
+	f.WriteString("package main\n")
+	f.WriteString("func main() {}\n")
+	f.Close()
+	expect(t, sub, path, Create)
+	expectNone(t, sub)
+
+	os.WriteFile(path, []byte("package main\n"), 0o644)
+	os.WriteFile(path, []byte("package main // edited\n"), 0o644)
+	expect(t, sub, path, Modify)
+
+	os.Remove(path)
+	expect(t, sub, path, Delete)
+
+	tmp := filepath.Join(dir, "main.go~")
+	os.WriteFile(tmp, nil, 0o644)
+	os.Remove(tmp)
+	expectNone(t, sub)
+}
+
+// TestWatch_Options tests recursive watching, filtering and per-op
+// topics
+func TestWatch_Options(t *testing.T) {
+	dir := t.TempDir()
+	b := pubsub.NewBroker()
+	defer b.Stop()
+	created := b.Subscribe("fs.create")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go Watch(ctx, b, "fs", []string{dir},
+		WithDebounce(20*time.Millisecond),
+		WithRecursive(),
+		WithOpTopics(),
+		WithFilter(func(path string) bool { return filepath.Ext(path) != ".swp" }),
+	)
+	time.Sleep(50 * time.Millisecond)
+
+	sub := filepath.Join(dir, "pkg")
+	os.Mkdir(sub, 0o755)
+	expect(t, created, sub, Create)
+	time.Sleep(50 * time.Millisecond) // let it watch the new directory
+
+	os.WriteFile(filepath.Join(sub, ".util.go.swp"), nil, 0o644)
+	os.WriteFile(filepath.Join(sub, "util.go"), nil, 0o644)
+	expect(t, created, filepath.Join(sub, "util.go"), Create)
+	expectNone(t, created)
+}
+
+// TestMerge tests how events for one path combine
+func TestMerge(t *testing.T) {
+	tests := []struct {
+		prev   Op
+		seen   bool
+		next   Op
+		want   Op
+		wantOK bool
+	}{
+		{"", false, Modify, Modify, true},
+		{Create, true, Modify, Create, true},
+		{Create, true, Delete, "", false},
+		{Delete, true, Create, Modify, true},
+		{Modify, true, Delete, Delete, true},
+		{Modify, true, Modify, Modify, true},
+	}
+	for _, tt := range tests {
+		got, ok := merge(tt.prev, tt.seen, tt.next)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("merge(%q, %v, %q) = %q, %v, want %q, %v", tt.prev, tt.seen, tt.next, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
@@ -2,10 +2,15 @@
 package main
 
 import (
+	"flag"
 	"fmt"
+	"log"
 	"math/rand"
 	"runtime"
 	"time"
+
+	"github.com/arifmahmudrana/go-snippets/profiling"
+	"github.com/arifmahmudrana/go-snippets/rtmetrics"
 )
 
 func generateData(n int) []int {
@@ -52,15 +57,29 @@ func sumSquaresConcurrent(data []int, workers int) int {
 }
 
 func main() {
+	prof := profiling.Register(nil)
+	flag.Parse()
+	stop, err := prof.Start()
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer func() {
+		if err := stop(); err != nil {
+			log.Print(err)
+		}
+	}()
+
 	runtime.GOMAXPROCS(1) // force single-CPU execution
 
 	data := generateData(5_000_000)
 
-	start := time.Now()
+	// The runtime's activity during each run is printed along, so a
+	// difference in time can be told apart from one in GC or scheduling.
+	before, start := rtmetrics.Take(), time.Now()
 	s1 := sumSquaresSequential(data)
-	fmt.Printf("Sequential: sum=%d, took=%v\n", s1, time.Since(start))
+	fmt.Printf("Sequential: sum=%d, took=%v\n            %v\n", s1, time.Since(start), rtmetrics.Take().Since(before))
 
-	start = time.Now()
+	before, start = rtmetrics.Take(), time.Now()
 	s2 := sumSquaresConcurrent(data, 8)
-	fmt.Printf("Concurrent: sum=%d, took=%v\n", s2, time.Since(start))
+	fmt.Printf("Concurrent: sum=%d, took=%v\n            %v\n", s2, time.Since(start), rtmetrics.Take().Since(before))
 }
@@ -1,3 +1,11 @@
 module github.com/arifmahmudrana/go-snippets
 
 go 1.22.2
+
+require (
+	github.com/fsnotify/fsnotify v1.8.0
+	github.com/klauspost/compress v1.17.11
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require golang.org/x/sys v0.13.0 // indirect
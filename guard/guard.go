@@ -0,0 +1,109 @@
+package guard
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrTimeout is returned when a guarded call does not finish in time. It
+// wraps context.DeadlineExceeded, so errors.Is works with either.
+var ErrTimeout = fmt.Errorf("guard: call timed out: %w", context.DeadlineExceeded)
+
+// Func is the shape of every guarded call.
+type Func[T any] func(ctx context.Context) (T, error)
+
+// Middleware decorates a Func. Anything with this shape — retries,
+// circuit breakers, metrics — composes with the wrappers in this package.
+type Middleware[T any] func(Func[T]) Func[T]
+
+// Chain applies middlewares to fn so that the first one listed is the
+// outermost: Chain(fn, a, b) == a(b(fn)).
+func Chain[T any](fn Func[T], mws ...Middleware[T]) Func[T] {
+	for i := len(mws) - 1; i >= 0; i-- {
+		fn = mws[i](fn)
+	}
+	return fn
+}
+
+// WithDeadline runs fn with a context that expires at deadline. If fn
+// has not returned by then, the call fails with ErrTimeout immediately,
+// even if fn ignores its context; fn keeps running in the background
+// until it notices and its result is discarded.
+func WithDeadline[T any](fn Func[T], deadline time.Time) Func[T] {
+	return func(ctx context.Context) (T, error) {
+		ctx, cancel := context.WithDeadline(ctx, deadline)
+		defer cancel()
+		return run(ctx, fn)
+	}
+}
+
+// WithTimeout is WithDeadline relative to the start of each call.
+func WithTimeout[T any](fn Func[T], d time.Duration) Func[T] {
+	return func(ctx context.Context) (T, error) {
+		ctx, cancel := context.WithTimeout(ctx, d)
+		defer cancel()
+		return run(ctx, fn)
+	}
+}
+
+// run calls fn and waits for it or for ctx, whichever comes first.
+func run[T any](ctx context.Context, fn Func[T]) (T, error) {
+	type result struct {
+		val T
+		err error
+	}
+	ch := make(chan result, 1) // buffered: a late fn must not leak blocked
+
+	go func() {
+		v, err := fn(ctx)
+		ch <- result{v, err}
+	}()
+
+	select {
+	case r := <-ch:
+		return r.val, r.err
+	case <-ctx.Done():
+		var zero T
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return zero, ErrTimeout
+		}
+		return zero, ctx.Err()
+	}
+}
+
+// WithFallback calls primary and, if it fails, fallback. If the caller's
+// context is already done the fallback is skipped. When both fail the
+// returned error wraps both.
+func WithFallback[T any](primary, fallback Func[T]) Func[T] {
+	return func(ctx context.Context) (T, error) {
+		v, err := primary(ctx)
+		if err == nil {
+			return v, nil
+		}
+		if ctx.Err() != nil {
+			return v, err
+		}
+
+		fv, ferr := fallback(ctx)
+		if ferr != nil {
+			return fv, errors.Join(err, ferr)
+		}
+		return fv, nil
+	}
+}
+
+// Timeout returns WithTimeout as a Middleware for use with Chain.
+func Timeout[T any](d time.Duration) Middleware[T] {
+	return func(fn Func[T]) Func[T] {
+		return WithTimeout(fn, d)
+	}
+}
+
+// Fallback returns WithFallback as a Middleware for use with Chain.
+func Fallback[T any](fallback Func[T]) Middleware[T] {
+	return func(fn Func[T]) Func[T] {
+		return WithFallback(fn, fallback)
+	}
+}
@@ -0,0 +1,102 @@
+package guard
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func slow(d time.Duration, v string) Func[string] {
+	return func(ctx context.Context) (string, error) {
+		time.Sleep(d) // ignores ctx on purpose
+		return v, nil
+	}
+}
+
+func failing(err error) Func[string] {
+	return func(ctx context.Context) (string, error) {
+		return "", err
+	}
+}
+
+// TestWithTimeout tests fast calls, slow calls and error wrapping
+func TestWithTimeout(t *testing.T) {
+	fast := WithTimeout(slow(0, "ok"), 50*time.Millisecond)
+	if v, err := fast(context.Background()); err != nil || v != "ok" {
+		t.Errorf("fast call = %q, %v", v, err)
+	}
+
+	start := time.Now()
+	_, err := WithTimeout(slow(time.Second, "late"), 20*time.Millisecond)(context.Background())
+	if !errors.Is(err, ErrTimeout) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("slow call err = %v, want ErrTimeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("timeout took %v; guard waited for a ctx-ignoring call", elapsed)
+	}
+}
+
+// TestWithDeadline tests an absolute deadline
+func TestWithDeadline(t *testing.T) {
+	fn := WithDeadline(slow(time.Second, "late"), time.Now().Add(20*time.Millisecond))
+	if _, err := fn(context.Background()); !errors.Is(err, ErrTimeout) {
+		t.Errorf("err = %v, want ErrTimeout", err)
+	}
+}
+
+// TestWithTimeout_ParentCancelled tests that caller cancellation is not reported as a timeout
+func TestWithTimeout_ParentCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := WithTimeout(slow(time.Second, "x"), time.Minute)(ctx)
+	if !errors.Is(err, context.Canceled) || errors.Is(err, ErrTimeout) {
+		t.Errorf("err = %v, want context.Canceled", err)
+	}
+}
+
+// TestWithFallback tests fallback activation and error joining
+func TestWithFallback(t *testing.T) {
+	errPrimary, errFallback := errors.New("primary"), errors.New("fallback")
+
+	if v, _ := WithFallback(slow(0, "p"), slow(0, "f"))(context.Background()); v != "p" {
+		t.Errorf("healthy primary = %q, want p", v)
+	}
+	if v, err := WithFallback(failing(errPrimary), slow(0, "f"))(context.Background()); err != nil || v != "f" {
+		t.Errorf("failing primary = %q, %v, want f", v, err)
+	}
+
+	_, err := WithFallback(failing(errPrimary), failing(errFallback))(context.Background())
+	if !errors.Is(err, errPrimary) || !errors.Is(err, errFallback) {
+		t.Errorf("both failing err = %v, want both wrapped", err)
+	}
+}
+
+// TestChain tests that a timeout inside a fallback composes as expected
+func TestChain(t *testing.T) {
+	// primary is too slow, so the cached value is served instead
+	cached := func(ctx context.Context) (string, error) { return "cached", nil }
+	fn := Chain(slow(time.Second, "fresh"),
+		Fallback(cached),
+		Timeout[string](20*time.Millisecond),
+	)
+
+	if v, err := fn(context.Background()); err != nil || v != "cached" {
+		t.Errorf("Chain() = %q, %v, want cached", v, err)
+	}
+
+	// order matters: Chain(fn, a, b) == a(b(fn))
+	var order []string
+	mark := func(name string) Middleware[string] {
+		return func(next Func[string]) Func[string] {
+			return func(ctx context.Context) (string, error) {
+				order = append(order, name)
+				return next(ctx)
+			}
+		}
+	}
+	Chain(slow(0, ""), mark("outer"), mark("inner"))(context.Background())
+	if len(order) != 2 || order[0] != "outer" {
+		t.Errorf("order = %v, want [outer inner]", order)
+	}
+}
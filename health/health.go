@@ -0,0 +1,201 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Check probes one subsystem. It returns nil when healthy and should
+// honour ctx, which carries the per-check timeout.
+type Check func(ctx context.Context) error
+
+// Kind distinguishes the two standard probe types.
+type Kind int
+
+const (
+	// Liveness checks fail when the process should be restarted.
+	Liveness Kind = iota
+	// Readiness checks fail when the process should not receive traffic.
+	Readiness
+)
+
+// Status values used in reports.
+const (
+	StatusOK   = "ok"
+	StatusFail = "fail"
+)
+
+// Result is the outcome of one check.
+type Result struct {
+	Name      string  `json:"name"`
+	Status    string  `json:"status"`
+	Error     string  `json:"error,omitempty"`
+	LatencyMS float64 `json:"latency_ms"`
+}
+
+// Report aggregates the results of every check of one kind.
+type Report struct {
+	Status string   `json:"status"`
+	Checks []Result `json:"checks"`
+}
+
+type namedCheck struct {
+	name  string
+	check Check
+}
+
+// Registry holds the checks registered by subsystems.
+type Registry struct {
+	timeout time.Duration
+
+	mu     sync.RWMutex
+	checks map[Kind][]namedCheck
+}
+
+// Option configures a Registry.
+type Option func(*Registry)
+
+// WithTimeout bounds how long each check may take (default 2s).
+func WithTimeout(d time.Duration) Option {
+	return func(r *Registry) {
+		r.timeout = d
+	}
+}
+
+// New returns an empty registry.
+func New(opts ...Option) *Registry {
+	r := &Registry{
+		timeout: 2 * time.Second,
+		checks:  make(map[Kind][]namedCheck),
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// Register adds a named check of the given kind. A name registered
+// again replaces the earlier check.
+func (r *Registry) Register(kind Kind, name string, check Check) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	checks := r.checks[kind]
+	for i, c := range checks {
+		if c.name == name {
+			checks[i].check = check
+			return
+		}
+	}
+	r.checks[kind] = append(checks, namedCheck{name: name, check: check})
+}
+
+// RegisterLiveness is shorthand for Register(Liveness, ...).
+func (r *Registry) RegisterLiveness(name string, check Check) {
+	r.Register(Liveness, name, check)
+}
+
+// RegisterReadiness is shorthand for Register(Readiness, ...).
+func (r *Registry) RegisterReadiness(name string, check Check) {
+	r.Register(Readiness, name, check)
+}
+
+// Run executes every check of kind concurrently, each under the
+// registry's timeout, and reports in registration order. The overall
+// status is ok only if every check passed.
+func (r *Registry) Run(ctx context.Context, kind Kind) Report {
+	r.mu.RLock()
+	checks := append([]namedCheck(nil), r.checks[kind]...)
+	r.mu.RUnlock()
+
+	report := Report{Status: StatusOK, Checks: make([]Result, len(checks))}
+
+	var wg sync.WaitGroup
+	for i, c := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			report.Checks[i] = r.runOne(ctx, c)
+		}()
+	}
+	wg.Wait()
+
+	for _, res := range report.Checks {
+		if res.Status != StatusOK {
+			report.Status = StatusFail
+		}
+	}
+	return report
+}
+
+// runOne executes a single check. A check that ignores its context is
+// abandoned at the timeout rather than blocking the whole report.
+func (r *Registry) runOne(ctx context.Context, c namedCheck) Result {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	start := time.Now()
+	errCh := make(chan error, 1)
+	go func() {
+		defer func() {
+			if p := recover(); p != nil {
+				errCh <- panicError{p}
+			}
+		}()
+		errCh <- c.check(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-errCh:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	res := Result{
+		Name:      c.name,
+		Status:    StatusOK,
+		LatencyMS: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if err != nil {
+		res.Status, res.Error = StatusFail, err.Error()
+	}
+	return res
+}
+
+// panicError reports a check that panicked.
+type panicError struct {
+	value any
+}
+
+func (p panicError) Error() string {
+	b, _ := json.Marshal(p.value)
+	return "check panicked: " + string(b)
+}
+
+// Handler serves the report for kind as JSON: 200 when healthy, 503
+// otherwise. Add ?verbose=0 to omit the per-check list.
+func (r *Registry) Handler(kind Kind) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		report := r.Run(req.Context(), kind)
+		if req.URL.Query().Get("verbose") == "0" {
+			report.Checks = nil
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		if report.Status != StatusOK {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(w).Encode(report)
+	})
+}
+
+// Mount registers /livez and /readyz on mux.
+func (r *Registry) Mount(mux *http.ServeMux) {
+	mux.Handle("/livez", r.Handler(Liveness))
+	mux.Handle("/readyz", r.Handler(Readiness))
+}
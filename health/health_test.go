@@ -0,0 +1,95 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/arifmahmudrana/go-snippets/pubsub"
+)
+
+// TestRegistry_Run tests aggregation, ordering and failure reporting
+func TestRegistry_Run(t *testing.T) {
+	r := New()
+	r.RegisterReadiness("db", func(ctx context.Context) error { return nil })
+	r.RegisterReadiness("cache", func(ctx context.Context) error { return errors.New("connection refused") })
+	r.RegisterLiveness("loop", func(ctx context.Context) error { return nil })
+
+	ready := r.Run(context.Background(), Readiness)
+	if ready.Status != StatusFail {
+		t.Errorf("readiness status = %q, want %q", ready.Status, StatusFail)
+	}
+	if len(ready.Checks) != 2 || ready.Checks[0].Name != "db" || ready.Checks[1].Name != "cache" {
+		t.Fatalf("checks = %+v", ready.Checks)
+	}
+	if ready.Checks[1].Error != "connection refused" {
+		t.Errorf("cache error = %q", ready.Checks[1].Error)
+	}
+
+	if live := r.Run(context.Background(), Liveness); live.Status != StatusOK {
+		t.Errorf("liveness status = %q, want %q", live.Status, StatusOK)
+	}
+}
+
+// TestRegistry_Timeout tests that a hung check fails without hanging the report
+func TestRegistry_Timeout(t *testing.T) {
+	r := New(WithTimeout(20 * time.Millisecond))
+	block := make(chan struct{})
+	defer close(block)
+	r.RegisterLiveness("stuck", func(ctx context.Context) error {
+		<-block // ignores ctx on purpose
+		return nil
+	})
+
+	start := time.Now()
+	report := r.Run(context.Background(), Liveness)
+	if time.Since(start) > time.Second {
+		t.Fatal("Run waited for a check that ignores its context")
+	}
+	if report.Checks[0].Status != StatusFail {
+		t.Errorf("stuck check status = %q, want %q", report.Checks[0].Status, StatusFail)
+	}
+}
+
+// TestRegistry_Panic tests that a panicking check is reported, not fatal
+func TestRegistry_Panic(t *testing.T) {
+	r := New()
+	r.RegisterLiveness("bad", func(ctx context.Context) error { panic("oops") })
+
+	if res := r.Run(context.Background(), Liveness).Checks[0]; res.Status != StatusFail {
+		t.Errorf("panicking check = %+v", res)
+	}
+}
+
+// TestHandler tests HTTP status codes and the JSON body
+func TestHandler(t *testing.T) {
+	broker := pubsub.NewBroker()
+
+	r := New(WithTimeout(100 * time.Millisecond))
+	r.RegisterLiveness("broker", broker.Ping)
+	mux := http.NewServeMux()
+	r.Mount(mux)
+
+	get := func() (int, Report) {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/livez", nil))
+		var rep Report
+		if err := json.NewDecoder(rec.Body).Decode(&rep); err != nil {
+			t.Fatal(err)
+		}
+		return rec.Code, rep
+	}
+
+	if code, rep := get(); code != http.StatusOK || rep.Status != StatusOK {
+		t.Errorf("healthy broker: %d %+v", code, rep)
+	}
+
+	broker.Stop()
+	if code, rep := get(); code != http.StatusServiceUnavailable || rep.Checks[0].Error == "" {
+		t.Errorf("stopped broker: %d %+v", code, rep)
+	}
+}
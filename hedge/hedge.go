@@ -0,0 +1,91 @@
+package hedge
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Stats describes one hedged call.
+type Stats struct {
+	Attempts int           // attempts started, the first one included
+	Winner   int           // which attempt's result was returned, from 0; -1 if none succeeded
+	Latency  time.Duration // until Do returned
+}
+
+// Do calls fn and, if it has not succeeded after delay, calls it again
+// in parallel, up to maxHedges extra times, delay apart; an attempt that
+// fails starts the next one at once. The first success wins: the other
+// attempts' contexts are cancelled and their results discarded, without
+// waiting for them to return. If every attempt fails, Do returns their
+// errors joined. A negative maxHedges is taken as 0.
+//
+// Hedging trades extra load for lower tail latency, so fn should be
+// safe to run more than once at a time, and delay is typically the
+// call's 95th percentile latency.
+func Do[T any](ctx context.Context, fn func(ctx context.Context) (T, error), delay time.Duration, maxHedges int) (T, Stats, error) {
+	start := time.Now()
+	maxHedges = max(maxHedges, 0)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type result struct {
+		attempt int
+		v       T
+		err     error
+	}
+	results := make(chan result, maxHedges+1) // losers never block
+	st := Stats{Winner: -1}
+	launch := func() {
+		attempt := st.Attempts
+		st.Attempts++
+		go func() {
+			v, err := fn(ctx)
+			results <- result{attempt, v, err}
+		}()
+	}
+
+	launch()
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	var errs []error
+	for running := 1; running > 0; {
+		var hedge <-chan time.Time
+		if st.Attempts <= maxHedges {
+			hedge = timer.C
+		}
+		select {
+		case <-ctx.Done():
+			st.Latency = time.Since(start)
+			var zero T
+			return zero, st, ctx.Err()
+		case <-hedge:
+			launch()
+			running++
+			timer.Reset(delay)
+		case r := <-results:
+			running--
+			if r.err == nil {
+				st.Winner, st.Latency = r.attempt, time.Since(start)
+				return r.v, st, nil
+			}
+			errs = append(errs, r.err)
+			if st.Attempts <= maxHedges {
+				launch()
+				running++
+				// a tick that fired meanwhile must not start
+				// another attempt straight away
+				if !timer.Stop() {
+					select {
+					case <-timer.C:
+					default:
+					}
+				}
+				timer.Reset(delay)
+			}
+		}
+	}
+	st.Latency = time.Since(start)
+	var zero T
+	return zero, st, errors.Join(errs...)
+}
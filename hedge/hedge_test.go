@@ -0,0 +1,93 @@
+package hedge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestDo tests which attempt wins and how many are started
+func TestDo(t *testing.T) {
+	slow := 200 * time.Millisecond
+	tests := []struct {
+		name         string
+		latency      []time.Duration // per attempt; negative fails after -d
+		maxHedges    int
+		want         string
+		wantAttempts int
+		wantWinner   int
+	}{
+		{"fast", []time.Duration{0}, 2, "0", 1, 0},
+		{"slow first", []time.Duration{slow, 0}, 2, "1", 2, 1},
+		{"all slow", []time.Duration{slow, slow, slow}, 2, "0", 3, 0},
+		{"no hedges", []time.Duration{slow}, 0, "0", 1, 0},
+		{"negative hedges", []time.Duration{slow}, -5, "0", 1, 0},
+		{"first fails", []time.Duration{-time.Millisecond, 0}, 2, "1", 2, 1},
+		{"all fail", []time.Duration{-time.Millisecond, -time.Millisecond}, 1, "", 2, -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var next atomic.Int32
+			got, st, err := Do(context.Background(), func(ctx context.Context) (string, error) {
+				i := int(next.Add(1)) - 1
+				d := tt.latency[i]
+				fail := d < 0
+				if fail {
+					d = -d
+				}
+				select {
+				case <-time.After(d):
+				case <-ctx.Done():
+					return "", ctx.Err()
+				}
+				if fail {
+					return "", fmt.Errorf("attempt %d failed", i)
+				}
+				return fmt.Sprint(i), nil
+			}, 20*time.Millisecond, tt.maxHedges)
+
+			if got != tt.want || st.Attempts != tt.wantAttempts || st.Winner != tt.wantWinner {
+				t.Errorf("Do() = %q, %+v, %v, want %q after %d attempts won by %d", got, st, err, tt.want, tt.wantAttempts, tt.wantWinner)
+			}
+			if (err != nil) != (tt.wantWinner < 0) {
+				t.Errorf("Do() error = %v", err)
+			}
+		})
+	}
+}
+
+// TestDo_Cancel tests that losers are cancelled and that Do gives up
+// with its context
+func TestDo_Cancel(t *testing.T) {
+	canceled := make(chan struct{}, 2)
+	var calls atomic.Int32
+	_, st, err := Do(context.Background(), func(ctx context.Context) (int, error) {
+		if calls.Add(1) == 2 {
+			return 2, nil
+		}
+		<-ctx.Done()
+		canceled <- struct{}{}
+		return 0, ctx.Err()
+	}, time.Millisecond, 1)
+	if err != nil || st.Winner != 1 {
+		t.Fatalf("Do() = %+v, %v, want a win by the hedge", st, err)
+	}
+	select {
+	case <-canceled:
+	case <-time.After(time.Second):
+		t.Error("the first attempt was not cancelled")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, _, err = Do(ctx, func(ctx context.Context) (int, error) {
+		<-ctx.Done()
+		return 0, ctx.Err()
+	}, time.Millisecond, 3)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Do() = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
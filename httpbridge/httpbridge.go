@@ -0,0 +1,216 @@
+package httpbridge
+
+import (
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/arifmahmudrana/go-snippets/ingest"
+	"github.com/arifmahmudrana/go-snippets/pubsub"
+)
+
+// Bridge exposes a broker to browsers, e.g. to prototype a frontend
+// against a local message bus:
+//
+//	POST /publish/{topic}   publishes the JSON body, as ingest.Handler
+//	GET  /subscribe/{topic} streams the topic's messages as Server-Sent
+//	                        Events, one JSON object per event, as
+//	                        pubsub.JSONLines encodes them
+//
+// The topic may be a pattern, such as "orders.*", to stream several
+// topics at once. On an exact topic, each event's ID is the message's
+// resume token, so when EventSource reconnects with a Last-Event-ID
+// header, a broker with retention replays what the client missed.
+type Bridge struct {
+	broker    *pubsub.Broker
+	publish   *ingest.Handler
+	ingest    []ingest.Option
+	auth      func(r *http.Request, topic string) bool
+	origin    string
+	heartbeat time.Duration
+	subOpts   []pubsub.SubscribeOption
+
+	streams atomic.Int64
+}
+
+// Option configures a Bridge.
+type Option func(*Bridge)
+
+// WithAuth admits only requests, to publish or subscribe, for which
+// allow returns true; see ingest.BearerToken. EventSource cannot send
+// headers, so a subscriber must be allowed some other way, e.g. by a
+// cookie or a query parameter.
+func WithAuth(allow func(r *http.Request, topic string) bool) Option {
+	return func(br *Bridge) {
+		br.auth = allow
+	}
+}
+
+// WithAllowOrigin lets pages served from origin, e.g.
+// "http://localhost:5173" or "*", call the bridge.
+func WithAllowOrigin(origin string) Option {
+	return func(br *Bridge) {
+		br.origin = origin
+	}
+}
+
+// WithHeartbeat sets how often an idle stream gets a comment line, which
+// keeps proxies from closing it (default 15s).
+func WithHeartbeat(d time.Duration) Option {
+	return func(br *Bridge) {
+		br.heartbeat = d
+	}
+}
+
+// WithPublishOptions configures the handler behind /publish, e.g. with
+// ingest.WithValidator or ingest.WithMaxBody.
+func WithPublishOptions(opts ...ingest.Option) Option {
+	return func(br *Bridge) {
+		br.ingest = append(br.ingest, opts...)
+	}
+}
+
+// WithSubscribeOptions configures the subscription of streams that do
+// not resume, e.g. with pubsub.WithBuffer for clients on slow
+// connections.
+func WithSubscribeOptions(opts ...pubsub.SubscribeOption) Option {
+	return func(br *Bridge) {
+		br.subOpts = append(br.subOpts, opts...)
+	}
+}
+
+// New returns a Bridge to b.
+func New(b *pubsub.Broker, opts ...Option) *Bridge {
+	br := &Bridge{broker: b, heartbeat: 15 * time.Second}
+	for _, opt := range opts {
+		opt(br)
+	}
+	ingestOpts := br.ingest
+	if br.auth != nil {
+		ingestOpts = append([]ingest.Option{ingest.WithAuth(br.auth)}, ingestOpts...)
+	}
+	br.publish = ingest.New(b, ingestOpts...)
+	return br
+}
+
+// Mount registers the bridge's endpoints on mux.
+func (br *Bridge) Mount(mux *http.ServeMux) {
+	mux.Handle("POST /publish/{topic}", br.cors(br.publish))
+	mux.Handle("OPTIONS /publish/{topic}", br.cors(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})))
+	mux.Handle("GET /subscribe/{topic}", br.cors(http.HandlerFunc(br.subscribe)))
+}
+
+// cors adds the headers WithAllowOrigin calls for.
+func (br *Bridge) cors(h http.Handler) http.Handler {
+	if br.origin == "" {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Access-Control-Allow-Origin", br.origin)
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST")
+		w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type, Last-Event-ID")
+		h.ServeHTTP(w, r)
+	})
+}
+
+// subscribe streams a topic until the client goes away or the broker
+// stops.
+func (br *Bridge) subscribe(w http.ResponseWriter, r *http.Request) {
+	topic := r.PathValue("topic")
+	if topic == "" {
+		http.Error(w, "missing topic", http.StatusBadRequest)
+		return
+	}
+	if br.auth != nil && !br.auth(r, topic) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	c, cancel, err := br.open(r, topic)
+	var limit *pubsub.LimitError
+	switch {
+	case errors.Is(err, pubsub.ErrInvalidToken):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case errors.As(err, &limit):
+		http.Error(w, err.Error(), http.StatusTooManyRequests)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	defer cancel()
+	br.streams.Add(1)
+	defer br.streams.Add(-1)
+
+	rc := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	rc.Flush()
+
+	exact := !pubsub.IsPattern(topic)
+	heartbeat := time.NewTicker(br.heartbeat)
+	defer heartbeat.Stop()
+	var buf []byte
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			buf = append(buf[:0], ": ping\n\n"...)
+		case m, ok := <-c:
+			if !ok {
+				return // the broker stopped
+			}
+			data, err := pubsub.JSONLines(m)
+			if err != nil {
+				continue // not JSON-encodable
+			}
+			buf = buf[:0]
+			if exact {
+				buf = append(append(append(buf, "id: "...), m.Token()...), '\n')
+			}
+			buf = append(append(append(buf, "data: "...), data...), '\n') // data ends in '\n' too
+		}
+		if _, err := w.Write(buf); err != nil {
+			return
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// open subscribes to topic, resuming after the Last-Event-ID header's
+// token if there is one and the broker retains topic. A client so far
+// behind that the messages after its token were evicted gets what is
+// retained.
+func (br *Bridge) open(r *http.Request, topic string) (<-chan pubsub.Message, func(), error) {
+	if token := r.Header.Get("Last-Event-ID"); token != "" && !pubsub.IsPattern(topic) {
+		c, cancel, err := br.broker.Resume(topic, token)
+		if errors.Is(err, pubsub.ErrTokenExpired) {
+			c, cancel, err = br.broker.Resume(topic, "")
+		}
+		if !errors.Is(err, pubsub.ErrNoRetention) {
+			return c, cancel, err
+		}
+	}
+	sub, err := br.broker.SubscribeCtx(r.Context(), topic, br.subOpts...)
+	if err != nil {
+		return nil, nil, err
+	}
+	return sub.C, func() { br.broker.Unsubscribe(topic, sub) }, nil
+}
+
+// Streams returns the number of clients streaming right now.
+func (br *Bridge) Streams() int {
+	return int(br.streams.Load())
+}
+
+// PublishStats returns the counters of the handler behind /publish.
+func (br *Bridge) PublishStats() ingest.Stats {
+	return br.publish.Stats()
+}
@@ -0,0 +1,162 @@
+package httpbridge
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/arifmahmudrana/go-snippets/pubsub"
+)
+
+// stream is one open GET /subscribe request.
+type stream struct {
+	resp *http.Response
+	r    *bufio.Reader
+}
+
+func open(t *testing.T, url, lastEventID string) *stream {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodGet, url, nil)
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("GET %s = %d %s, want an event stream", url, resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return &stream{resp: resp, r: bufio.NewReader(resp.Body)}
+}
+
+// event reads the next event's id and the payload of its data.
+func (s *stream) event(t *testing.T) (id string, payload string) {
+	t.Helper()
+	type result struct{ id, data string }
+	done := make(chan result, 1)
+	go func() {
+		var res result
+		for {
+			line, err := s.r.ReadString('\n')
+			if err != nil || line == "\n" && res.data != "" {
+				done <- res
+				return
+			}
+			if v, ok := strings.CutPrefix(line, "id: "); ok {
+				res.id = strings.TrimSpace(v)
+			}
+			if v, ok := strings.CutPrefix(line, "data: "); ok {
+				res.data = v
+			}
+		}
+	}()
+	select {
+	case res := <-done:
+		var m struct {
+			Payload json.RawMessage `json:"payload"`
+		}
+		if err := json.Unmarshal([]byte(res.data), &m); err != nil {
+			t.Fatalf("event data %q: %v", res.data, err)
+		}
+		return res.id, string(m.Payload)
+	case <-time.After(3 * time.Second):
+		t.Fatal("no event")
+		return "", ""
+	}
+}
+
+func publish(t *testing.T, base, topic, body string) {
+	t.Helper()
+	resp, err := http.Post(base+"/publish/"+topic, "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("POST /publish/%s = %d, want 202", topic, resp.StatusCode)
+	}
+}
+
+// TestBridge tests publishing over HTTP and streaming, by pattern and
+// by topic, resuming after a reconnect
+func TestBridge(t *testing.T) {
+	b := pubsub.NewBroker(pubsub.WithRetention(pubsub.NewMemoryRetention(100)))
+	defer b.Stop()
+	br := New(b)
+	mux := http.NewServeMux()
+	br.Mount(mux)
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close) // after the streams' cleanups close them
+
+	orders := open(t, srv.URL+"/subscribe/orders", "")
+	all := open(t, srv.URL+"/subscribe/*", "")
+	if n := br.Streams(); n != 2 {
+		t.Errorf("Streams() = %d, want 2", n)
+	}
+	publish(t, srv.URL, "orders", `{"id":1}`)
+	if id, p := orders.event(t); id != "1" || p != `{"id":1}` {
+		t.Errorf("orders event %s %s, want 1 {\"id\":1}", id, p)
+	}
+	if id, p := all.event(t); id != "" || p != `{"id":1}` {
+		t.Errorf("pattern event %q %s, want no id", id, p)
+	}
+
+	// the client reconnects after missing two messages
+	orders.resp.Body.Close()
+	publish(t, srv.URL, "orders", `{"id":2}`)
+	publish(t, srv.URL, "orders", `{"id":3}`)
+	orders = open(t, srv.URL+"/subscribe/orders", "1")
+	for _, want := range []string{"2", "3"} {
+		if id, _ := orders.event(t); id != want {
+			t.Errorf("resumed event %s, want %s", id, want)
+		}
+	}
+	if st := br.PublishStats(); st.Published != 3 {
+		t.Errorf("PublishStats() = %+v, want 3 published", st)
+	}
+}
+
+// TestBridge_Refused tests the errors a stream can be refused with, and
+// CORS preflight
+func TestBridge_Refused(t *testing.T) {
+	b := pubsub.NewBroker(pubsub.WithRetention(pubsub.NewMemoryRetention(10)))
+	br := New(b, WithAllowOrigin("*"), WithAuth(func(r *http.Request, topic string) bool {
+		return topic != "secret"
+	}))
+	mux := http.NewServeMux()
+	br.Mount(mux)
+
+	tests := []struct {
+		method, path, lastEventID string
+		want                      int
+	}{
+		{http.MethodGet, "/subscribe/secret", "", http.StatusUnauthorized},
+		{http.MethodPost, "/publish/secret", "", http.StatusUnauthorized},
+		{http.MethodGet, "/subscribe/orders", "x", http.StatusBadRequest},
+		{http.MethodOptions, "/publish/orders", "", http.StatusNoContent},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.path, nil)
+		if tt.lastEventID != "" {
+			req.Header.Set("Last-Event-ID", tt.lastEventID)
+		}
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		if rec.Code != tt.want || rec.Header().Get("Access-Control-Allow-Origin") != "*" {
+			t.Errorf("%s %s = %d, want %d with CORS headers", tt.method, tt.path, rec.Code, tt.want)
+		}
+	}
+
+	b.Stop()
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/subscribe/orders", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("after Stop: %d, want 503", rec.Code)
+	}
+}
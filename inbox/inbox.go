@@ -0,0 +1,256 @@
+package inbox
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/arifmahmudrana/go-snippets/outbox"
+	"github.com/arifmahmudrana/go-snippets/pubsub"
+	"github.com/arifmahmudrana/go-snippets/store"
+)
+
+var (
+	// ErrDuplicate is returned by Handle for a message processed before.
+	ErrDuplicate = errors.New("inbox: duplicate message")
+	// ErrNoID is returned by Handle for a message its IDFunc finds no ID
+	// in.
+	ErrNoID = errors.New("inbox: message has no ID")
+)
+
+// Store records the IDs of processed messages.
+type Store interface {
+	// Processed reports whether id was recorded.
+	Processed(ctx context.Context, id string) (bool, error)
+	// Record records id.
+	Record(ctx context.Context, id string) error
+}
+
+// IDFunc returns the ID a message is deduplicated by, or "" if it has
+// none.
+type IDFunc func(m pubsub.Message) string
+
+// DefaultID uses the ID of an outbox.Event payload. Other messages have
+// no ID of their own: give the inbox an IDFunc that finds one in them,
+// or see SeqID.
+func DefaultID(m pubsub.Message) string {
+	if e, ok := m.Payload.(outbox.Event); ok {
+		return e.ID
+	}
+	return ""
+}
+
+// SeqID identifies a message by its topic and Seq, which is what
+// pubsub.Broker.Resume redelivers it with. Use it only with a broker
+// whose retention persists, such as pubsub.StoreRetention: without,
+// Seq starts again at 1 when the broker restarts, and a persistent
+// inbox would skip every new message as a duplicate of an old one.
+func SeqID(m pubsub.Message) string {
+	return m.Topic + "#" + strconv.FormatUint(m.Seq, 10)
+}
+
+// Inbox makes a consumer idempotent: it runs a handler once per message
+// ID, however many times the message is delivered.
+type Inbox struct {
+	store Store
+	id    IDFunc
+
+	mu       sync.Mutex
+	inflight map[string]chan struct{} // closed when the handler returns
+
+	processed, duplicates, failed atomic.Uint64
+}
+
+// Option configures an Inbox.
+type Option func(*Inbox)
+
+// WithID sets how messages are identified (default DefaultID).
+func WithID(fn IDFunc) Option {
+	return func(in *Inbox) {
+		in.id = fn
+	}
+}
+
+// New returns an inbox recording processed IDs in s.
+func New(s Store, opts ...Option) *Inbox {
+	in := &Inbox{store: s, id: DefaultID, inflight: make(map[string]chan struct{})}
+	for _, opt := range opts {
+		opt(in)
+	}
+	return in
+}
+
+// Handle runs fn for m and records m's ID if fn succeeds. If the ID was
+// recorded before, it skips fn and returns ErrDuplicate; if fn fails,
+// the ID is not recorded, so a redelivery runs fn again. While fn runs,
+// a concurrent Handle of the same ID waits for it. A message without an
+// ID fails with ErrNoID, as it could not be deduplicated.
+func (in *Inbox) Handle(ctx context.Context, m pubsub.Message, fn func(ctx context.Context, m pubsub.Message) error) error {
+	id := in.id(m)
+	if id == "" {
+		return ErrNoID
+	}
+	for {
+		in.mu.Lock()
+		wait, busy := in.inflight[id]
+		if !busy {
+			in.inflight[id] = make(chan struct{})
+		}
+		in.mu.Unlock()
+		if !busy {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-wait:
+		}
+	}
+	defer func() {
+		in.mu.Lock()
+		close(in.inflight[id])
+		delete(in.inflight, id)
+		in.mu.Unlock()
+	}()
+
+	seen, err := in.store.Processed(ctx, id)
+	if err != nil {
+		return err
+	}
+	if seen {
+		in.duplicates.Add(1)
+		return ErrDuplicate
+	}
+	if err := fn(ctx, m); err != nil {
+		in.failed.Add(1)
+		return err
+	}
+	in.processed.Add(1)
+	return in.store.Record(ctx, id)
+}
+
+// Consume handles the messages from c, typically a Subscriber's C or
+// the channel from Resume, until it is closed or ctx is done. Duplicates
+// are skipped. Handler failures are counted in Stats and the message is
+// left unrecorded; a store failure, or a message without an ID, ends
+// Consume with its error.
+func (in *Inbox) Consume(ctx context.Context, c <-chan pubsub.Message, fn func(ctx context.Context, m pubsub.Message) error) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case m, ok := <-c:
+			if !ok {
+				return nil
+			}
+			var handlerErr bool
+			err := in.Handle(ctx, m, func(ctx context.Context, m pubsub.Message) error {
+				err := fn(ctx, m)
+				handlerErr = err != nil
+				return err
+			})
+			if err != nil && !handlerErr && !errors.Is(err, ErrDuplicate) {
+				return err
+			}
+		}
+	}
+}
+
+// Stats counts what an inbox has seen.
+type Stats struct {
+	Processed  uint64 // handler succeeded
+	Duplicates uint64 // skipped
+	Failed     uint64 // handler failed
+}
+
+// Stats returns the inbox's counters.
+func (in *Inbox) Stats() Stats {
+	return Stats{
+		Processed:  in.processed.Load(),
+		Duplicates: in.duplicates.Load(),
+		Failed:     in.failed.Load(),
+	}
+}
+
+// Memory is an in-memory Store, for tests and single-process use.
+type Memory struct {
+	mu  sync.Mutex
+	ids map[string]bool
+}
+
+// NewMemory returns an empty Memory.
+func NewMemory() *Memory {
+	return &Memory{ids: make(map[string]bool)}
+}
+
+func (m *Memory) Processed(ctx context.Context, id string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.ids[id], nil
+}
+
+func (m *Memory) Record(ctx context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.ids[id] = true
+	return nil
+}
+
+// KV is a Store in a store.Store, one key per ID holding the time it
+// was recorded, so it survives restarts when the store does.
+type KV struct {
+	s store.Store
+}
+
+const kvPrefix = "inbox/"
+
+// NewKV returns a Store backed by s.
+func NewKV(s store.Store) *KV {
+	return &KV{s: s}
+}
+
+func (kv *KV) Processed(ctx context.Context, id string) (bool, error) {
+	_, err := kv.s.Get(ctx, kvPrefix+id)
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		return false, nil
+	case err != nil:
+		return false, err
+	}
+	return true, nil
+}
+
+func (kv *KV) Record(ctx context.Context, id string) error {
+	return kv.s.Put(ctx, kvPrefix+id, time.Now().AppendFormat(nil, time.RFC3339Nano))
+}
+
+// Prune forgets the IDs recorded before t, once redeliveries that old
+// can no longer happen, and returns how many it removed.
+func (kv *KV) Prune(ctx context.Context, t time.Time) (int, error) {
+	keys, err := kv.s.List(ctx, kvPrefix)
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, k := range keys {
+		data, err := kv.s.Get(ctx, k)
+		if errors.Is(err, store.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return n, err
+		}
+		at, err := time.Parse(time.RFC3339Nano, string(data))
+		if err != nil || !at.Before(t) {
+			continue
+		}
+		if err := kv.s.Delete(ctx, k); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
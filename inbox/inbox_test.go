@@ -0,0 +1,169 @@
+package inbox
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/arifmahmudrana/go-snippets/outbox"
+	"github.com/arifmahmudrana/go-snippets/pubsub"
+	"github.com/arifmahmudrana/go-snippets/store"
+)
+
+// TestInbox_Consume tests that redelivered messages are handled once and
+// failed ones again
+func TestInbox_Consume(t *testing.T) {
+	for _, s := range []struct {
+		name  string
+		store Store
+	}{
+		{"memory", NewMemory()},
+		{"kv", NewKV(store.NewMemory())},
+	} {
+		t.Run(s.name, func(t *testing.T) {
+			in := New(s.store, WithID(func(m pubsub.Message) string {
+				return cmp.Or(DefaultID(m), SeqID(m))
+			}))
+			c := make(chan pubsub.Message, 10)
+			for _, m := range []pubsub.Message{
+				{Topic: "orders", Seq: 1},
+				{Topic: "orders", Seq: 2, Payload: "fail"},
+				{Topic: "orders", Seq: 1}, // redelivered
+				{Topic: "orders", Seq: 2}, // retried, works now
+				{Topic: "orders", Payload: outbox.Event{ID: "e1"}},
+				{Topic: "other", Seq: 9, Payload: outbox.Event{ID: "e1"}}, // same event
+			} {
+				c <- m
+			}
+			close(c)
+
+			var handled []string
+			err := in.Consume(context.Background(), c, func(ctx context.Context, m pubsub.Message) error {
+				if m.Payload == "fail" {
+					return errors.New("boom")
+				}
+				handled = append(handled, m.Topic)
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("Consume() = %v", err)
+			}
+			if got, want := len(handled), 3; got != want {
+				t.Errorf("handled %v, want %d messages", handled, want)
+			}
+			if got, want := in.Stats(), (Stats{Processed: 3, Duplicates: 2, Failed: 1}); got != want {
+				t.Errorf("Stats() = %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+// TestInbox_Concurrent tests that concurrent deliveries of one message
+// run the handler once
+func TestInbox_Concurrent(t *testing.T) {
+	in := New(NewMemory(), WithID(SeqID))
+	var calls atomic.Int32
+	var wg sync.WaitGroup
+	var dups atomic.Int32
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := in.Handle(context.Background(), pubsub.Message{Topic: "t", Seq: 1}, func(ctx context.Context, m pubsub.Message) error {
+				calls.Add(1)
+				time.Sleep(time.Millisecond)
+				return nil
+			})
+			if errors.Is(err, ErrDuplicate) {
+				dups.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	if calls.Load() != 1 || dups.Load() != 9 {
+		t.Errorf("handler ran %d times with %d duplicates, want 1 and 9", calls.Load(), dups.Load())
+	}
+}
+
+// TestKV_Prune tests forgetting old IDs
+func TestKV_Prune(t *testing.T) {
+	ctx := context.Background()
+	kv := NewKV(store.NewMemory())
+	kv.Record(ctx, "old")
+	cutoff := time.Now()
+	time.Sleep(time.Millisecond)
+	kv.Record(ctx, "new")
+
+	if n, err := kv.Prune(ctx, cutoff); n != 1 || err != nil {
+		t.Errorf("Prune() = %d, %v, want 1", n, err)
+	}
+	for id, want := range map[string]bool{"old": false, "new": true} {
+		if got, _ := kv.Processed(ctx, id); got != want {
+			t.Errorf("Processed(%q) = %v, want %v", id, got, want)
+		}
+	}
+}
+
+// TestInbox_NoID tests that a message without an ID is refused rather
+// than deduplicated by a made-up one
+func TestInbox_NoID(t *testing.T) {
+	in := New(NewMemory())
+	c := make(chan pubsub.Message, 1)
+	c <- pubsub.Message{Topic: "orders", Seq: 1, Payload: "no ID"}
+	err := in.Consume(context.Background(), c, func(ctx context.Context, m pubsub.Message) error {
+		t.Errorf("handled %v, want it refused", m)
+		return nil
+	})
+	if !errors.Is(err, ErrNoID) {
+		t.Errorf("Consume() = %v, want %v", err, ErrNoID)
+	}
+}
+
+// TestInbox_SeqIDRestart tests deduplicating by Seq across a broker
+// restart: with persistent retention, a replay is skipped and new
+// messages are handled
+func TestInbox_SeqIDRestart(t *testing.T) {
+	s := store.NewMemory() // the broker's and the inbox's, kept across the restart
+	in := New(NewKV(s), WithID(SeqID))
+	var handled []any
+	consume := func(b *pubsub.Broker, n int) {
+		t.Helper()
+		c, cancel, err := b.Resume("orders", "") // replays everything
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer cancel()
+		ctx, stop := context.WithCancel(context.Background())
+		defer stop()
+		in.Consume(ctx, c, func(ctx context.Context, m pubsub.Message) error {
+			handled = append(handled, m.Payload)
+			if n--; n == 0 {
+				stop()
+			}
+			return nil
+		})
+	}
+
+	b := pubsub.NewBroker(pubsub.WithRetention(pubsub.NewStoreRetention(s)))
+	b.Publish("orders", "a")
+	b.Publish("orders", "b")
+	consume(b, 2)
+	b.Stop()
+
+	b = pubsub.NewBroker(pubsub.WithRetention(pubsub.NewStoreRetention(s)))
+	defer b.Stop()
+	b.Publish("orders", "c")
+	consume(b, 1)
+
+	if want := []any{"a", "b", "c"}; !slices.Equal(handled, want) {
+		t.Errorf("handled %v, want %v", handled, want)
+	}
+	if got, want := in.Stats(), (Stats{Processed: 3, Duplicates: 2}); got != want {
+		t.Errorf("Stats() = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,169 @@
+package ingest
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/arifmahmudrana/go-snippets/pubsub"
+)
+
+// Handler publishes the JSON body of POST /topics/{topic} on topic. The
+// payload reaches subscribers as json.RawMessage, as from a remote
+// transport client. Responses:
+//
+//	202 published
+//	400 invalid topic or JSON
+//	401 refused by WithAuth
+//	413 body over the WithMaxBody limit
+//	415 not application/json
+//	422 refused by WithValidator
+//	429 the topic's publish queue stayed full; retry after Retry-After
+//	503 the broker is stopped
+type Handler struct {
+	broker   *pubsub.Broker
+	maxBody  int64
+	timeout  time.Duration
+	auth     func(r *http.Request, topic string) bool
+	validate func(topic string, payload json.RawMessage) error
+
+	published, throttled, rejected atomic.Uint64
+}
+
+// Option configures a Handler.
+type Option func(*Handler)
+
+// WithAuth admits only requests for which allow returns true.
+func WithAuth(allow func(r *http.Request, topic string) bool) Option {
+	return func(h *Handler) {
+		h.auth = allow
+	}
+}
+
+// BearerToken is a WithAuth function admitting requests with an
+// "Authorization: Bearer token" header, for any topic.
+func BearerToken(token string) func(r *http.Request, topic string) bool {
+	return func(r *http.Request, topic string) bool {
+		got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		return ok && subtle.ConstantTimeCompare([]byte(got), []byte(token)) == 1
+	}
+}
+
+// WithValidator checks every payload before it is published; an error
+// is sent back to the client with 422.
+func WithValidator(fn func(topic string, payload json.RawMessage) error) Option {
+	return func(h *Handler) {
+		h.validate = fn
+	}
+}
+
+// WithMaxBody sets the largest body accepted, in bytes (default 1 MiB).
+func WithMaxBody(n int64) Option {
+	return func(h *Handler) {
+		h.maxBody = n
+	}
+}
+
+// WithPublishTimeout sets how long a request waits for room in its
+// topic's publish queue before being turned away with 429 (default
+// 100ms). Keeping it short pushes back on clients instead of piling up
+// requests in the server.
+func WithPublishTimeout(d time.Duration) Option {
+	return func(h *Handler) {
+		h.timeout = d
+	}
+}
+
+// New returns a Handler publishing into b.
+func New(b *pubsub.Broker, opts ...Option) *Handler {
+	h := &Handler{
+		broker:  b,
+		maxBody: 1 << 20,
+		timeout: 100 * time.Millisecond,
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+// Mount registers the handler on mux as POST /topics/{topic}. To serve
+// it under another path, register it with a pattern naming a {topic}
+// wildcard.
+func (h *Handler) Mount(mux *http.ServeMux) {
+	mux.Handle("POST /topics/{topic}", h)
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	topic := r.PathValue("topic")
+	if topic == "" || pubsub.IsPattern(topic) || strings.HasPrefix(topic, "$") {
+		h.reject(w, "invalid topic "+strconv.Quote(topic), http.StatusBadRequest)
+		return
+	}
+	if h.auth != nil && !h.auth(r, topic) {
+		h.reject(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if mt, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mt != "application/json" {
+		h.reject(w, "Content-Type must be application/json", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, h.maxBody))
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.As(err, &tooLarge):
+		h.reject(w, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	case err != nil:
+		h.reject(w, err.Error(), http.StatusBadRequest)
+		return
+	case !json.Valid(body):
+		h.reject(w, "body is not valid JSON", http.StatusBadRequest)
+		return
+	}
+	payload := json.RawMessage(body)
+	if h.validate != nil {
+		if err := h.validate(topic, payload); err != nil {
+			h.reject(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+	}
+
+	switch err := h.broker.PublishTimeout(topic, payload, h.timeout); {
+	case err == nil:
+		h.published.Add(1)
+		w.WriteHeader(http.StatusAccepted)
+	case errors.Is(err, pubsub.ErrPublishTimeout):
+		h.throttled.Add(1)
+		w.Header().Set("Retry-After", "1")
+		http.Error(w, err.Error(), http.StatusTooManyRequests)
+	default:
+		h.reject(w, err.Error(), http.StatusServiceUnavailable)
+	}
+}
+
+// reject counts a refused request and answers it.
+func (h *Handler) reject(w http.ResponseWriter, msg string, code int) {
+	h.rejected.Add(1)
+	http.Error(w, msg, code)
+}
+
+// Stats counts a Handler's requests.
+type Stats struct {
+	Published uint64
+	Throttled uint64 // answered 429
+	Rejected  uint64 // any other error
+}
+
+// Stats returns the handler's counters.
+func (h *Handler) Stats() Stats {
+	return Stats{Published: h.published.Load(), Throttled: h.throttled.Load(), Rejected: h.rejected.Load()}
+}
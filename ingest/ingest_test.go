@@ -0,0 +1,103 @@
+package ingest
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/arifmahmudrana/go-snippets/pubsub"
+)
+
+// post sends body to the handler's topic path and returns the status.
+func post(mux *http.ServeMux, topic, contentType, token, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/topics/"+topic, strings.NewReader(body))
+	req.Header.Set("Content-Type", contentType)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	return rec
+}
+
+// TestHandler tests validating, authenticating and publishing requests
+func TestHandler(t *testing.T) {
+	b := pubsub.NewBroker()
+	defer b.Stop()
+	sub := b.Subscribe("orders")
+	h := New(b,
+		WithAuth(BearerToken("t0ken")),
+		WithMaxBody(64),
+		WithValidator(func(topic string, payload json.RawMessage) error {
+			if payload[0] != '{' {
+				return errors.New("want an object")
+			}
+			return nil
+		}),
+	)
+	mux := http.NewServeMux()
+	h.Mount(mux)
+
+	const ct = "application/json; charset=utf-8"
+	tests := []struct {
+		name, topic, contentType, token, body string
+		want                                  int
+	}{
+		{"published", "orders", ct, "t0ken", `{"id":1}`, http.StatusAccepted},
+		{"no token", "orders", ct, "", `{"id":1}`, http.StatusUnauthorized},
+		{"wrong token", "orders", ct, "guess", `{"id":1}`, http.StatusUnauthorized},
+		{"pattern", "orders.*", ct, "t0ken", `{"id":1}`, http.StatusBadRequest},
+		{"reserved", "$broker.limit", ct, "t0ken", `{"id":1}`, http.StatusBadRequest},
+		{"not JSON", "orders", ct, "t0ken", `{"id":`, http.StatusBadRequest},
+		{"form", "orders", "application/x-www-form-urlencoded", "t0ken", `id=1`, http.StatusUnsupportedMediaType},
+		{"too large", "orders", ct, "t0ken", `{"pad":"` + strings.Repeat("x", 64) + `"}`, http.StatusRequestEntityTooLarge},
+		{"invalid", "orders", ct, "t0ken", `[1]`, http.StatusUnprocessableEntity},
+	}
+	for _, tt := range tests {
+		if got := post(mux, tt.topic, tt.contentType, tt.token, tt.body).Code; got != tt.want {
+			t.Errorf("%s: status %d, want %d", tt.name, got, tt.want)
+		}
+	}
+
+	m := <-sub.C
+	if raw, ok := m.Payload.(json.RawMessage); !ok || string(raw) != `{"id":1}` {
+		t.Errorf("published %#v, want the raw JSON body", m.Payload)
+	}
+	if st := h.Stats(); st != (Stats{Published: 1, Rejected: 8}) {
+		t.Errorf("Stats() = %+v, want 1 published, 8 rejected", st)
+	}
+}
+
+// TestHandler_Backpressure tests answering 429 while the topic's publish
+// queue is full, and 503 once the broker is stopped
+func TestHandler_Backpressure(t *testing.T) {
+	b := pubsub.NewBroker(pubsub.WithPublishQueue(1))
+	h := New(b, WithPublishTimeout(10*time.Millisecond))
+	mux := http.NewServeMux()
+	h.Mount(mux)
+
+	// a subscriber that never reads holds up the broker
+	stuck := b.Subscribe("orders", pubsub.WithPolicy(pubsub.Block), pubsub.WithBuffer(0))
+	var codes []int
+	for range 3 {
+		codes = append(codes, post(mux, "orders", "application/json", "", `1`).Code)
+	}
+	rec := post(mux, "orders", "application/json", "", `1`)
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") == "" {
+		t.Errorf("full queue: status %d, Retry-After %q, want 429 with Retry-After (earlier: %v)",
+			rec.Code, rec.Header().Get("Retry-After"), codes)
+	}
+	if st := h.Stats(); st.Throttled == 0 {
+		t.Errorf("Stats() = %+v, want some throttled", st)
+	}
+
+	b.Unsubscribe("orders", stuck)
+	b.Stop()
+	if got := post(mux, "orders", "application/json", "", `1`).Code; got != http.StatusServiceUnavailable {
+		t.Errorf("stopped broker: status %d, want 503", got)
+	}
+}
@@ -0,0 +1,32 @@
+package jobs_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/arifmahmudrana/go-snippets/jobs"
+)
+
+// A message delivered twice becomes one job: the second Enqueue finds
+// the first by its idempotency key.
+func ExampleWithKey() {
+	q := jobs.New(func(ctx context.Context, payload []byte) ([]byte, error) {
+		fmt.Println("sending", string(payload))
+		return []byte(strings.ToUpper(string(payload))), nil
+	})
+	defer q.Close()
+
+	for range 2 {
+		h, err := q.Enqueue([]byte("welcome email"), jobs.WithKey("user-42/welcome"))
+		if err != nil {
+			panic(err)
+		}
+		result, _ := h.Wait(context.Background())
+		fmt.Println(h.ID(), h.Duplicate, string(result))
+	}
+	// Output:
+	// sending welcome email
+	// 1 false WELCOME EMAIL
+	// 1 true WELCOME EMAIL
+}
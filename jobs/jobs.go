@@ -0,0 +1,543 @@
+package jobs
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"errors"
+	"runtime"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/arifmahmudrana/go-snippets/store"
+)
+
+var (
+	// ErrClosed is returned by Enqueue after the queue is closed.
+	ErrClosed = errors.New("jobs: queue closed")
+	// ErrNotFound is returned for a job that does not exist, or finished
+	// longer ago than the queue's retention.
+	ErrNotFound = errors.New("jobs: job not found")
+	// ErrKeyReused is returned by Enqueue when an idempotency key that is
+	// still remembered comes with a different payload.
+	ErrKeyReused = errors.New("jobs: idempotency key reused with a different payload")
+	// ErrCanceled is returned by Wait for a canceled job.
+	ErrCanceled = errors.New("jobs: job canceled")
+	// ErrState is returned by Cancel and Retry for a job in a state they
+	// do not apply to.
+	ErrState = errors.New("jobs: operation not allowed in the job's state")
+)
+
+// State is where a job is in its life.
+type State string
+
+const (
+	Queued    State = "queued"
+	Running   State = "running"
+	Succeeded State = "succeeded"
+	Failed    State = "failed" // every attempt failed
+	Canceled  State = "canceled"
+)
+
+// Handler runs a job and returns its result. Returning an error makes
+// the job eligible for another attempt.
+type Handler func(ctx context.Context, payload []byte) ([]byte, error)
+
+// Job is a snapshot of a job.
+type Job struct {
+	ID       string    `json:"id"`
+	Key      string    `json:"key,omitempty"` // idempotency key, if any
+	Payload  []byte    `json:"payload"`
+	State    State     `json:"state"`
+	Attempts int       `json:"attempts"`
+	Result   []byte    `json:"result,omitempty"`
+	Err      string    `json:"error,omitempty"` // the last attempt's error
+	History  []Attempt `json:"history"`
+	Created  time.Time `json:"created"`
+	Lease    time.Time `json:"lease"`    // while Running: when the job counts as lost
+	Finished time.Time `json:"finished"` // zero until Done
+}
+
+// Attempt is one run of a job.
+type Attempt struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`             // zero while running
+	Err   string    `json:"error,omitempty"` // why it failed, if it did
+}
+
+// Done reports whether the job has finished: succeeded, failed or been
+// canceled.
+func (j Job) Done() bool {
+	return j.State == Succeeded || j.State == Failed || j.State == Canceled
+}
+
+// JobError is returned by Wait for a job that failed every attempt.
+type JobError struct {
+	ID  string
+	Err string // the last attempt's error
+}
+
+func (e *JobError) Error() string {
+	return "jobs: job " + e.ID + " failed: " + e.Err
+}
+
+// job is a Job plus what waiters need. Fields of Job are guarded by
+// Queue.mu.
+type job struct {
+	Job
+	done     chan struct{}      // closed when the job finishes
+	cancel   context.CancelFunc // cancels the running attempt
+	canceled bool               // Cancel was called while running
+	queued   time.Time          // when it last joined the ready list
+}
+
+// seq returns the job's ID as a number.
+func (j *job) seq() uint64 {
+	n, _ := strconv.ParseUint(j.ID, 10, 64)
+	return n
+}
+
+// snapshot returns a copy of the job that later updates do not touch.
+// Caller holds Queue.mu.
+func (j *job) snapshot() Job {
+	c := j.Job
+	c.History = slices.Clone(j.History)
+	return c
+}
+
+// Queue runs jobs on a fixed number of workers, retrying failures.
+//
+// Jobs may carry an idempotency key. Enqueuing a key the queue still
+// remembers does not run anything: it returns the handle of the job
+// first enqueued with it, so a trigger delivered twice (as at-least-once
+// pubsub delivery will do) yields one run and one result. Keys are
+// remembered while their job is pending and for the retention period
+// after it finishes.
+type Queue struct {
+	handler     Handler
+	workers     int
+	maxAttempts int
+	retention   time.Duration
+	visibility  time.Duration
+	now         func() time.Time
+	store       store.Store  // nil unless opened with Open
+	closeStore  func() error // set by OpenWAL, which owns its store
+
+	mu       sync.Mutex
+	cond     *sync.Cond // signalled when ready grows or the queue closes
+	nextID   uint64
+	jobs     map[string]*job
+	keys     map[string]*job
+	ready    []*job
+	finished []*job        // in finishing order, for expiry
+	timers   []*time.Timer // requeueing jobs recovered while Running
+	closed   bool
+
+	ctx         context.Context // cancelled by Stop
+	cancel      context.CancelFunc
+	wg          sync.WaitGroup
+	storeErrors atomic.Uint64
+}
+
+// Option configures a Queue.
+type Option func(*Queue)
+
+// WithWorkers sets how many jobs run at once (default GOMAXPROCS).
+func WithWorkers(n int) Option {
+	return func(q *Queue) {
+		q.workers = max(n, 1)
+	}
+}
+
+// WithMaxAttempts fails a job after n failed attempts (default 3).
+func WithMaxAttempts(n int) Option {
+	return func(q *Queue) {
+		q.maxAttempts = max(n, 1)
+	}
+}
+
+// WithRetention sets how long finished jobs, and their idempotency keys,
+// are remembered (default one hour).
+func WithRetention(d time.Duration) Option {
+	return func(q *Queue) {
+		q.retention = d
+	}
+}
+
+// New starts a queue running h, keeping jobs in memory only.
+func New(h Handler, opts ...Option) *Queue {
+	q := newQueue(h, opts)
+	q.start()
+	return q
+}
+
+func newQueue(h Handler, opts []Option) *Queue {
+	q := &Queue{
+		handler:     h,
+		workers:     runtime.GOMAXPROCS(0),
+		maxAttempts: 3,
+		retention:   time.Hour,
+		visibility:  5 * time.Minute,
+		now:         time.Now,
+		jobs:        make(map[string]*job),
+		keys:        make(map[string]*job),
+	}
+	for _, opt := range opts {
+		opt(q)
+	}
+	q.cond = sync.NewCond(&q.mu)
+	q.ctx, q.cancel = context.WithCancel(context.Background())
+	return q
+}
+
+// start starts the workers.
+func (q *Queue) start() {
+	q.wg.Add(q.workers)
+	for range q.workers {
+		go q.worker()
+	}
+}
+
+// EnqueueOption configures one Enqueue call.
+type EnqueueOption func(*Job)
+
+// WithKey sets the job's idempotency key.
+func WithKey(key string) EnqueueOption {
+	return func(j *Job) {
+		j.Key = key
+	}
+}
+
+// Handle refers to an enqueued job.
+type Handle struct {
+	q *Queue
+	j *job
+
+	// Duplicate is set when Enqueue found the job by its idempotency key
+	// instead of creating it.
+	Duplicate bool
+}
+
+// ID returns the job's ID.
+func (h *Handle) ID() string {
+	return h.j.ID
+}
+
+// Job returns a snapshot of the job.
+func (h *Handle) Job() Job {
+	h.q.mu.Lock()
+	defer h.q.mu.Unlock()
+	return h.j.snapshot()
+}
+
+// Wait blocks until the job finishes and returns its result, a
+// *JobError if it failed, or ErrCanceled. It fails with ctx.Err() if
+// ctx is done first.
+func (h *Handle) Wait(ctx context.Context) ([]byte, error) {
+	h.q.mu.Lock()
+	done := h.j.done // replaced by Retry
+	h.q.mu.Unlock()
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-done:
+	}
+	j := h.Job()
+	switch j.State {
+	case Failed:
+		return nil, &JobError{ID: j.ID, Err: j.Err}
+	case Canceled:
+		return nil, ErrCanceled
+	}
+	return j.Result, nil
+}
+
+// Enqueue queues a job with payload. With WithKey, a key the queue still
+// remembers returns the original job's handle, marked Duplicate, or
+// ErrKeyReused if the payloads differ.
+func (q *Queue) Enqueue(payload []byte, opts ...EnqueueOption) (*Handle, error) {
+	var spec Job
+	for _, opt := range opts {
+		opt(&spec)
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return nil, ErrClosed
+	}
+	q.expire()
+
+	if spec.Key != "" {
+		if j, ok := q.keys[spec.Key]; ok {
+			if !bytes.Equal(j.Payload, payload) {
+				return nil, ErrKeyReused
+			}
+			return &Handle{q: q, j: j, Duplicate: true}, nil
+		}
+	}
+
+	q.nextID++
+	j := &job{
+		Job: Job{
+			ID:      strconv.FormatUint(q.nextID, 10),
+			Key:     spec.Key,
+			Payload: bytes.Clone(payload),
+			State:   Queued,
+			Created: q.now(),
+		},
+		done: make(chan struct{}),
+	}
+	if err := q.save(j); err != nil {
+		q.nextID--
+		return nil, err
+	}
+	q.jobs[j.ID] = j
+	if j.Key != "" {
+		q.keys[j.Key] = j
+	}
+	q.push(j)
+	q.cond.Signal()
+	return &Handle{q: q, j: j}, nil
+}
+
+// Get returns a handle for the job with id, or ErrNotFound.
+func (q *Queue) Get(id string) (*Handle, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.expire()
+	j, ok := q.jobs[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &Handle{q: q, j: j}, nil
+}
+
+// expire forgets jobs, and their keys, that finished longer ago than
+// the retention. Caller holds mu.
+func (q *Queue) expire() {
+	cutoff := q.now().Add(-q.retention)
+	n := 0
+	for _, j := range q.finished {
+		if j.Finished.After(cutoff) {
+			break
+		}
+		delete(q.jobs, j.ID)
+		if j.Key != "" && q.keys[j.Key] == j {
+			delete(q.keys, j.Key)
+		}
+		q.forget(j)
+		n++
+	}
+	q.finished = q.finished[n:]
+}
+
+// push appends j to the ready list. Caller holds mu.
+func (q *Queue) push(j *job) {
+	j.queued = q.now()
+	q.ready = append(q.ready, j)
+}
+
+type queuedKey struct{}
+
+// QueuedAt returns when the job whose attempt ctx belongs to was last
+// queued, to tell how long it waited for a worker.
+func QueuedAt(ctx context.Context) (time.Time, bool) {
+	t, ok := ctx.Value(queuedKey{}).(time.Time)
+	return t, ok
+}
+
+// worker runs jobs until the queue is closed and empty, or stopped.
+func (q *Queue) worker() {
+	defer q.wg.Done()
+	for {
+		q.mu.Lock()
+		for len(q.ready) == 0 && !q.closed {
+			q.cond.Wait()
+		}
+		if len(q.ready) == 0 || q.ctx.Err() != nil {
+			q.mu.Unlock()
+			return
+		}
+		j := q.ready[0]
+		q.ready = q.ready[1:]
+		ctx, cancel := context.WithCancel(context.WithValue(q.ctx, queuedKey{}, j.queued))
+		j.State, j.cancel = Running, cancel
+		j.Attempts++
+		j.Lease = q.now().Add(q.visibility)
+		j.History = append(j.History, Attempt{Start: q.now()})
+		q.saveOrCount(j)
+		q.mu.Unlock()
+
+		result, err := q.handler(ctx, j.Payload)
+		cancel()
+		q.settle(j, result, err)
+	}
+}
+
+// settle records the outcome of an attempt at j.
+func (q *Queue) settle(j *job, result []byte, err error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	defer q.saveOrCount(j)
+
+	j.Lease, j.cancel = time.Time{}, nil
+	last := &j.History[len(j.History)-1]
+	last.End = q.now()
+	if err != nil {
+		last.Err = err.Error()
+	}
+	switch {
+	case j.canceled:
+		j.State, j.canceled = Canceled, false
+	case err == nil:
+		j.State, j.Result, j.Err = Succeeded, result, ""
+	case q.ctx.Err() != nil:
+		// stopped: the attempt does not count against the job
+		j.State = Queued
+		j.Attempts--
+		j.History = j.History[:len(j.History)-1]
+		q.push(j)
+		return
+	case j.Attempts < q.maxAttempts:
+		j.State, j.Err = Queued, err.Error()
+		q.push(j)
+		q.cond.Signal()
+		return
+	default:
+		j.State, j.Err = Failed, err.Error()
+	}
+	q.finish(j)
+}
+
+// finish marks j finished. Caller holds mu and has set j's state.
+func (q *Queue) finish(j *job) {
+	j.Finished = q.now()
+	q.finished = append(q.finished, j)
+	close(j.done)
+}
+
+// Cancel cancels the job with id. A queued job is canceled at once; a
+// running one has its context canceled and counts as canceled whatever
+// its handler then returns. It fails with ErrState for a finished job.
+func (q *Queue) Cancel(id string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	j, ok := q.jobs[id]
+	if !ok {
+		return ErrNotFound
+	}
+	switch {
+	case j.Done():
+		return ErrState
+	case j.cancel != nil:
+		j.canceled = true
+		j.cancel()
+		return nil
+	case j.State == Queued:
+		if i := slices.Index(q.ready, j); i >= 0 {
+			q.ready = slices.Delete(q.ready, i, i+1)
+		}
+	}
+	// queued, or recovered while running and waiting out its lease
+	j.State, j.Lease = Canceled, time.Time{}
+	q.finish(j)
+	q.saveOrCount(j)
+	return nil
+}
+
+// Retry queues a failed or canceled job again, with a fresh set of
+// attempts; its history is kept. It fails with ErrState for a job in any
+// other state, or ErrClosed after Close.
+func (q *Queue) Retry(id string) (*Handle, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return nil, ErrClosed
+	}
+	j, ok := q.jobs[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if j.State != Failed && j.State != Canceled {
+		return nil, ErrState
+	}
+	if i := slices.Index(q.finished, j); i >= 0 {
+		q.finished = slices.Delete(q.finished, i, i+1)
+	}
+	j.State, j.Attempts, j.Err, j.Finished = Queued, 0, "", time.Time{}
+	j.done = make(chan struct{})
+	q.push(j)
+	q.cond.Signal()
+	q.saveOrCount(j)
+	return &Handle{q: q, j: j}, nil
+}
+
+// Jobs returns the jobs in state, or all of them if state is "", in ID
+// order.
+func (q *Queue) Jobs(state State) []Job {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.expire()
+	var out []*job
+	for _, j := range q.jobs {
+		if state == "" || j.State == state {
+			out = append(out, j)
+		}
+	}
+	slices.SortFunc(out, func(a, b *job) int {
+		return cmp.Compare(a.seq(), b.seq())
+	})
+	jobs := make([]Job, len(out))
+	for i, j := range out {
+		jobs[i] = j.snapshot()
+	}
+	return jobs
+}
+
+// Depths returns how many jobs are in each state.
+func (q *Queue) Depths() map[State]int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.expire()
+	depths := map[State]int{Queued: 0, Running: 0, Succeeded: 0, Failed: 0, Canceled: 0}
+	for _, j := range q.jobs {
+		depths[j.State]++
+	}
+	return depths
+}
+
+// Len returns the number of jobs queued or running.
+func (q *Queue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.jobs) - len(q.finished)
+}
+
+// Close stops accepting jobs and waits until the queued ones finish.
+// Recovered jobs still waiting out their visibility timeout are left for
+// the next Open.
+func (q *Queue) Close() {
+	q.mu.Lock()
+	q.closed = true
+	for _, t := range q.timers {
+		t.Stop()
+	}
+	q.cond.Broadcast()
+	q.mu.Unlock()
+	q.wg.Wait()
+	q.cancel()
+	if q.closeStore != nil {
+		q.closeStore()
+	}
+}
+
+// Stop stops accepting jobs, cancels the running ones and waits for
+// their handlers to return. Queued jobs are left unfinished.
+func (q *Queue) Stop() {
+	q.cancel()
+	q.Close()
+}
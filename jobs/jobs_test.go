@@ -0,0 +1,336 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// echo returns its payload.
+func echo(ctx context.Context, payload []byte) ([]byte, error) {
+	return payload, nil
+}
+
+// wait waits for h with a timeout.
+func wait(t *testing.T, h *Handle) ([]byte, error) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return h.Wait(ctx)
+}
+
+// TestQueue_RunsJobs tests that every job runs and reports its result
+func TestQueue_RunsJobs(t *testing.T) {
+	q := New(echo, WithWorkers(4))
+	defer q.Close()
+
+	var handles []*Handle
+	for _, p := range []string{"a", "b", "c"} {
+		h, err := q.Enqueue([]byte(p))
+		if err != nil {
+			t.Fatal(err)
+		}
+		handles = append(handles, h)
+	}
+	for i, h := range handles {
+		got, err := wait(t, h)
+		if want := []string{"a", "b", "c"}[i]; err != nil || string(got) != want {
+			t.Errorf("job %s: Wait() = %q, %v, want %q", h.ID(), got, err, want)
+		}
+		if j := h.Job(); j.State != Succeeded || j.Attempts != 1 {
+			t.Errorf("job %s: state %s after %d attempts, want succeeded after 1", j.ID, j.State, j.Attempts)
+		}
+	}
+}
+
+// TestQueue_Retries tests that failures are retried up to the limit
+func TestQueue_Retries(t *testing.T) {
+	tests := []struct {
+		name         string
+		failures     int
+		wantState    State
+		wantAttempts int
+	}{
+		{"recovers", 2, Succeeded, 3},
+		{"gives up", 5, Failed, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls atomic.Int32
+			q := New(func(ctx context.Context, payload []byte) ([]byte, error) {
+				if int(calls.Add(1)) <= tt.failures {
+					return nil, errors.New("boom")
+				}
+				return []byte("ok"), nil
+			}, WithWorkers(1), WithMaxAttempts(3))
+			defer q.Close()
+
+			h, err := q.Enqueue(nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = wait(t, h)
+			if j := h.Job(); j.State != tt.wantState || j.Attempts != tt.wantAttempts {
+				t.Errorf("state %s after %d attempts, want %s after %d", j.State, j.Attempts, tt.wantState, tt.wantAttempts)
+			}
+			var jerr *JobError
+			if failed := errors.As(err, &jerr); failed != (tt.wantState == Failed) {
+				t.Errorf("Wait() = %v", err)
+			}
+		})
+	}
+}
+
+// TestQueue_IdempotencyKey tests that concurrent submissions with one key
+// run the job once and all see its result
+func TestQueue_IdempotencyKey(t *testing.T) {
+	var calls atomic.Int32
+	release := make(chan struct{})
+	q := New(func(ctx context.Context, payload []byte) ([]byte, error) {
+		calls.Add(1)
+		<-release
+		return []byte("charged"), nil
+	})
+	defer q.Close()
+
+	const n = 10
+	handles := make([]*Handle, n)
+	var wg sync.WaitGroup
+	for i := range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			h, err := q.Enqueue([]byte("order-7"), WithKey("order-7"))
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			handles[i] = h
+		}()
+	}
+	wg.Wait()
+	close(release)
+
+	dups := 0
+	for _, h := range handles {
+		if h == nil {
+			t.FailNow()
+		}
+		if h.ID() != handles[0].ID() {
+			t.Errorf("job ID = %s, want %s", h.ID(), handles[0].ID())
+		}
+		if h.Duplicate {
+			dups++
+		}
+		if got, err := wait(t, h); err != nil || string(got) != "charged" {
+			t.Errorf("Wait() = %q, %v, want charged", got, err)
+		}
+	}
+	if dups != n-1 {
+		t.Errorf("%d duplicates, want %d", dups, n-1)
+	}
+
+	// a redelivery after the job finished still gets the original result
+	h, err := q.Enqueue([]byte("order-7"), WithKey("order-7"))
+	if err != nil || !h.Duplicate || h.ID() != handles[0].ID() {
+		t.Errorf("late Enqueue() = %+v, %v, want duplicate of %s", h, err, handles[0].ID())
+	}
+	if got := calls.Load(); got != 1 {
+		t.Errorf("handler ran %d times, want 1", got)
+	}
+}
+
+// TestQueue_KeyReused tests that a key cannot be reused for another payload
+func TestQueue_KeyReused(t *testing.T) {
+	q := New(echo)
+	defer q.Close()
+
+	if _, err := q.Enqueue([]byte("a"), WithKey("k")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := q.Enqueue([]byte("b"), WithKey("k")); !errors.Is(err, ErrKeyReused) {
+		t.Errorf("Enqueue() = %v, want %v", err, ErrKeyReused)
+	}
+}
+
+// TestQueue_Retention tests that finished jobs and their keys are
+// forgotten after the retention period
+func TestQueue_Retention(t *testing.T) {
+	var calls atomic.Int32
+	q := New(func(ctx context.Context, payload []byte) ([]byte, error) {
+		calls.Add(1)
+		return nil, nil
+	}, WithRetention(time.Minute))
+	defer q.Close()
+
+	var mu sync.Mutex
+	now := time.Unix(0, 0)
+	q.mu.Lock()
+	q.now = func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return now
+	}
+	q.mu.Unlock()
+	advance := func(d time.Duration) {
+		mu.Lock()
+		now = now.Add(d)
+		mu.Unlock()
+	}
+
+	first, _ := q.Enqueue(nil, WithKey("k"))
+	wait(t, first)
+
+	advance(59 * time.Second)
+	if h, _ := q.Enqueue(nil, WithKey("k")); !h.Duplicate {
+		t.Errorf("Enqueue() within retention ran job %s again", h.ID())
+	}
+
+	advance(time.Second)
+	if _, err := q.Get(first.ID()); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get() after retention = %v, want %v", err, ErrNotFound)
+	}
+	h, _ := q.Enqueue(nil, WithKey("k"))
+	if h.Duplicate {
+		t.Errorf("Enqueue() after retention returned the old job")
+	}
+	wait(t, h)
+	if got := calls.Load(); got != 2 {
+		t.Errorf("handler ran %d times, want 2", got)
+	}
+}
+
+// TestQueue_Stop tests that Stop cancels running jobs and leaves them
+// unfinished
+func TestQueue_Stop(t *testing.T) {
+	started := make(chan struct{})
+	q := New(func(ctx context.Context, payload []byte) ([]byte, error) {
+		close(started)
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}, WithWorkers(1))
+
+	h, _ := q.Enqueue(nil)
+	<-started
+	q.Stop()
+
+	if j := h.Job(); j.State != Queued || j.Attempts != 0 {
+		t.Errorf("state %s after %d attempts, want queued after 0", j.State, j.Attempts)
+	}
+	if _, err := q.Enqueue(nil); !errors.Is(err, ErrClosed) {
+		t.Errorf("Enqueue() after Stop = %v, want %v", err, ErrClosed)
+	}
+}
+
+// TestQueue_Cancel tests canceling queued and running jobs
+func TestQueue_Cancel(t *testing.T) {
+	started := make(chan struct{})
+	q := New(func(ctx context.Context, payload []byte) ([]byte, error) {
+		close(started)
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}, WithWorkers(1))
+	defer q.Close()
+
+	running, _ := q.Enqueue([]byte("running"))
+	queued, _ := q.Enqueue([]byte("queued"))
+	<-started
+
+	for _, h := range []*Handle{queued, running} {
+		if err := q.Cancel(h.ID()); err != nil {
+			t.Fatalf("Cancel(%s) = %v", h.ID(), err)
+		}
+		if _, err := wait(t, h); !errors.Is(err, ErrCanceled) {
+			t.Errorf("Wait() = %v, want %v", err, ErrCanceled)
+		}
+	}
+	if j := queued.Job(); j.Attempts != 0 {
+		t.Errorf("canceled queued job ran %d times", j.Attempts)
+	}
+	if err := q.Cancel(running.ID()); !errors.Is(err, ErrState) {
+		t.Errorf("Cancel() of a canceled job = %v, want %v", err, ErrState)
+	}
+	if err := q.Cancel("99"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Cancel() of a missing job = %v, want %v", err, ErrNotFound)
+	}
+	if got := q.Depths(); got[Canceled] != 2 || got[Queued]+got[Running] != 0 {
+		t.Errorf("Depths() = %v, want 2 canceled", got)
+	}
+}
+
+// TestQueue_Retry tests that a failed job can be run again, keeping its
+// attempt history
+func TestQueue_Retry(t *testing.T) {
+	var calls atomic.Int32
+	q := New(func(ctx context.Context, payload []byte) ([]byte, error) {
+		if calls.Add(1) <= 2 {
+			return nil, errors.New("db down")
+		}
+		return []byte("ok"), nil
+	}, WithMaxAttempts(2))
+	defer q.Close()
+
+	h, _ := q.Enqueue(nil)
+	if _, err := wait(t, h); err == nil {
+		t.Fatal("Wait() = nil, want the job to fail")
+	}
+	if _, err := q.Retry(h.ID()); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := wait(t, h); err != nil || string(got) != "ok" {
+		t.Fatalf("Wait() after Retry = %q, %v, want ok", got, err)
+	}
+
+	j := h.Job()
+	var errs []string
+	for _, a := range j.History {
+		errs = append(errs, a.Err)
+	}
+	if got, want := fmt.Sprintf("%q", errs), `["db down" "db down" ""]`; got != want || j.Attempts != 1 {
+		t.Errorf("History errors = %s, Attempts = %d, want %s and 1", got, j.Attempts, want)
+	}
+	if _, err := q.Retry(h.ID()); !errors.Is(err, ErrState) {
+		t.Errorf("Retry() of a succeeded job = %v, want %v", err, ErrState)
+	}
+}
+
+// TestQueue_Jobs tests listing jobs by state
+func TestQueue_Jobs(t *testing.T) {
+	q := New(func(ctx context.Context, payload []byte) ([]byte, error) {
+		if string(payload) == "bad" {
+			return nil, errors.New("bad payload")
+		}
+		return nil, nil
+	}, WithMaxAttempts(1), WithWorkers(1))
+	defer q.Close()
+
+	for _, p := range []string{"a", "bad", "b", "c", "d", "e", "f", "g", "h", "i", "bad"} {
+		h, _ := q.Enqueue([]byte(p))
+		wait(t, h)
+	}
+
+	tests := []struct {
+		state State
+		want  []string
+	}{
+		{"", []string{"1", "2", "3", "4", "5", "6", "7", "8", "9", "10", "11"}},
+		{Failed, []string{"2", "11"}},
+		{Running, nil},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, j := range q.Jobs(tt.state) {
+			got = append(got, j.ID)
+		}
+		if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("Jobs(%q) = %v, want %v", tt.state, got, tt.want)
+		}
+	}
+	if got := q.Jobs(Failed)[0].Err; got != "bad payload" {
+		t.Errorf("failure reason = %q, want bad payload", got)
+	}
+}
@@ -0,0 +1,145 @@
+package jobs
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/arifmahmudrana/go-snippets/store"
+)
+
+const claimPrefix = "jobs/claim/"
+
+// claimsKept is how many intervals a tick's claim is kept for. A process
+// never claims a tick more than one interval late, so one whose claim
+// was deleted cannot be claimed again unless clocks differ by more.
+const claimsKept = 10
+
+// Schedule enqueues a recurring job. See Every.
+type Schedule struct {
+	q        *Queue
+	name     string
+	interval time.Duration
+	payload  []byte
+	leads    func() bool
+	claims   store.Creator
+
+	stop chan struct{}
+	done chan struct{}
+	once sync.Once
+}
+
+// ScheduleOption configures a Schedule.
+type ScheduleOption func(*Schedule)
+
+// WithClaims makes each tick run at most once across every process
+// sharing s: before enqueueing a tick, a process claims it with
+// s.Create, and only the one whose claim succeeds enqueues it. Ticks a
+// process cannot claim, because another did or s failed, are skipped,
+// as are ticks it comes to more than an interval late, e.g. after a
+// pause.
+func WithClaims(s store.Creator) ScheduleOption {
+	return func(sc *Schedule) {
+		sc.claims = s
+	}
+}
+
+// Every enqueues payload on q once per interval, on the boundaries
+// time.Truncate(interval) gives, for as long as leads, if not nil,
+// reports true when a tick comes. The boundaries are the same in every
+// process, so several may run the same schedule, each with its own
+// queue, and the first tick after a process takes over follows on from
+// the last one before. Ticks missed in between are not made up.
+//
+// leads alone is best-effort: with leads reporting whether the process
+// is the leader (e.g. membership.Node.IsLeader), usually only one
+// process enqueues each tick, but two may while their views of the
+// cluster differ, such as before one has joined or across a partition.
+// For a guarantee that each tick runs on only one process, share a
+// store between them with WithClaims; leads then just spares the others
+// the attempt.
+//
+// Each tick's job has the idempotency key name@tick, so a tick is never
+// run twice by one queue, even if leadership flaps.
+func Every(q *Queue, name string, interval time.Duration, payload []byte, leads func() bool, opts ...ScheduleOption) *Schedule {
+	s := &Schedule{
+		q:        q,
+		name:     name,
+		interval: interval,
+		payload:  payload,
+		leads:    leads,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	go s.run()
+	return s
+}
+
+// TickKey returns the idempotency key of the job a schedule named name
+// enqueues for tick.
+func TickKey(name string, tick time.Time) string {
+	return name + "@" + strconv.FormatInt(tick.UnixNano(), 10)
+}
+
+func (s *Schedule) run() {
+	defer close(s.done)
+	for {
+		next := time.Now().Truncate(s.interval).Add(s.interval)
+		t := time.NewTimer(time.Until(next))
+		select {
+		case <-s.stop:
+			t.Stop()
+			return
+		case <-t.C:
+		}
+		if s.leads != nil && !s.leads() || !s.claim(next) {
+			continue
+		}
+		// ErrClosed ends the schedule; other errors lose one tick
+		if _, err := s.q.Enqueue(s.payload, WithKey(TickKey(s.name, next))); err == ErrClosed {
+			return
+		}
+	}
+}
+
+// claim reports whether this process may enqueue tick: always without
+// WithClaims, otherwise only if it is not late and wins the tick's claim.
+func (s *Schedule) claim(tick time.Time) bool {
+	if s.claims == nil {
+		return true
+	}
+	if time.Since(tick) > s.interval {
+		return false
+	}
+	ctx := context.Background()
+	key := claimPrefix + TickKey(s.name, tick)
+	if err := s.claims.Create(ctx, key, nil); err != nil {
+		return false
+	}
+
+	// forget the claims of ticks too old to be claimed again
+	prefix := claimPrefix + s.name + "@"
+	keys, err := s.claims.List(ctx, prefix)
+	if err != nil {
+		return true
+	}
+	cutoff := tick.Add(-claimsKept * s.interval).UnixNano()
+	for _, k := range keys {
+		ns, err := strconv.ParseInt(strings.TrimPrefix(k, prefix), 10, 64)
+		if err == nil && ns < cutoff {
+			s.claims.Delete(ctx, k)
+		}
+	}
+	return true
+}
+
+// Stop stops the schedule. Jobs already enqueued are not affected.
+func (s *Schedule) Stop() {
+	s.once.Do(func() { close(s.stop) })
+	<-s.done
+}
@@ -0,0 +1,111 @@
+package jobs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/arifmahmudrana/go-snippets/membership"
+	"github.com/arifmahmudrana/go-snippets/store"
+)
+
+// keys returns the idempotency keys of q's finished jobs.
+func keys(q *Queue) map[string]bool {
+	out := make(map[string]bool)
+	for _, j := range q.Jobs(Succeeded) {
+		out[j.Key] = true
+	}
+	return out
+}
+
+// eventually polls cond until it holds or the deadline passes.
+func eventually(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// TestEvery_Singleton tests a schedule run by two processes: only the
+// leader enqueues ticks, and the other takes over when the leader dies
+func TestEvery_Singleton(t *testing.T) {
+	claims := store.NewMemory() // shared, as a database would be
+	type process struct {
+		node  *membership.Node
+		queue *Queue
+		sched *Schedule
+	}
+	start := func(name string) *process {
+		n, err := membership.Start(membership.Config{
+			Name:           name,
+			Addr:           "127.0.0.1:0",
+			ProbeInterval:  40 * time.Millisecond,
+			ProbeTimeout:   15 * time.Millisecond,
+			SuspectTimeout: 120 * time.Millisecond,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		p := &process{node: n, queue: New(echo)}
+		t.Cleanup(func() {
+			if p.sched != nil {
+				p.sched.Stop()
+			}
+			p.queue.Close()
+			p.node.Close()
+		})
+		return p
+	}
+
+	a, b := start("a"), start("b")
+	if err := b.node.Join(a.node.Addr()); err != nil {
+		t.Fatal(err)
+	}
+	// alone, b would lead itself
+	eventually(t, "b to see a", func() bool { return b.node.Leader() == "a" })
+	for _, p := range []*process{a, b} {
+		p.sched = Every(p.queue, "report", 50*time.Millisecond, nil, p.node.IsLeader, WithClaims(claims))
+	}
+
+	eventually(t, "a to run 3 ticks", func() bool { return len(keys(a.queue)) >= 3 })
+	if n := len(keys(b.queue)); n != 0 {
+		t.Errorf("b ran %d ticks while a led", n)
+	}
+
+	// a dies without telling anyone
+	a.sched.Stop()
+	a.node.Close()
+	eventually(t, "b to take over and run 3 ticks", func() bool { return len(keys(b.queue)) >= 3 })
+
+	for k := range keys(b.queue) {
+		if keys(a.queue)[k] {
+			t.Errorf("tick %s ran on both a and b", k)
+		}
+	}
+}
+
+// TestEvery_SplitBrain tests that with claims, two processes that both
+// believe they lead, as across a partition, still run each tick once
+func TestEvery_SplitBrain(t *testing.T) {
+	claims := store.NewMemory()
+	leads := func() bool { return true }
+	queues := []*Queue{New(echo), New(echo)}
+	for _, q := range queues {
+		s := Every(q, "report", 20*time.Millisecond, nil, leads, WithClaims(claims))
+		t.Cleanup(func() {
+			s.Stop()
+			q.Close()
+		})
+	}
+
+	eventually(t, "6 ticks to run", func() bool { return len(keys(queues[0]))+len(keys(queues[1])) >= 6 })
+	a, b := keys(queues[0]), keys(queues[1])
+	for k := range b {
+		if a[k] {
+			t.Errorf("tick %s ran on both processes", k)
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/arifmahmudrana/go-snippets/store"
+	"github.com/arifmahmudrana/go-snippets/wal"
+)
+
+const (
+	jobPrefix = "jobs/job/"
+	nextKey   = "jobs/next" // the last ID handed out
+)
+
+// WithVisibilityTimeout sets how long a running job may go without
+// finishing before a queue opened on the same store considers it lost
+// and queues it again (default five minutes). It only matters after a
+// crash: a job whose process died mid-run is retried once its timeout
+// runs out, counting the lost run as an attempt. Set it well above the
+// longest run, or a job still running elsewhere may run twice.
+func WithVisibilityTimeout(d time.Duration) Option {
+	return func(q *Queue) {
+		q.visibility = d
+	}
+}
+
+// Open starts a queue running h that keeps its jobs in s, so enqueued
+// and running jobs survive a restart. Every change to a job is written
+// to s as it happens, and Enqueue returns only once the job is stored.
+// Open picks up where the previous queue on s
+// stopped, whether it was closed or its process died: queued jobs run
+// again, running ones once their visibility timeout expires, and
+// finished ones (with their idempotency keys) are remembered for the
+// rest of their retention.
+//
+// Only one queue at a time may use s.
+func Open(s store.Store, h Handler, opts ...Option) (*Queue, error) {
+	q := newQueue(h, opts)
+	q.store = s
+	if err := q.load(context.Background()); err != nil {
+		return nil, err
+	}
+	q.start()
+	return q, nil
+}
+
+// OpenWAL is Open with a wal.Store in dir, which the queue closes when
+// it is closed. Each change to a job is appended to the write-ahead log
+// and synced before the queue goes on, rather than rewriting a file per
+// job as a store.File would.
+func OpenWAL(dir string, h Handler, opts ...Option) (*Queue, error) {
+	s, err := wal.OpenStore(dir)
+	if err != nil {
+		return nil, err
+	}
+	q, err := Open(s, h, opts...)
+	if err != nil {
+		s.Close()
+		return nil, err
+	}
+	q.closeStore = s.Close
+	return q, nil
+}
+
+func jobKey(id uint64) string {
+	return fmt.Sprintf("%s%020d", jobPrefix, id) // zero-padded keys sort by ID
+}
+
+// load restores the jobs in q.store.
+func (q *Queue) load(ctx context.Context) error {
+	next, err := q.store.Get(ctx, nextKey)
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+	case err != nil:
+		return err
+	default:
+		if q.nextID, err = strconv.ParseUint(string(next), 10, 64); err != nil {
+			return fmt.Errorf("jobs: decoding %s: %w", nextKey, err)
+		}
+	}
+
+	keys, err := q.store.List(ctx, jobPrefix)
+	if err != nil {
+		return err
+	}
+	now := q.now()
+	for _, k := range keys {
+		data, err := q.store.Get(ctx, k)
+		if err != nil {
+			return err
+		}
+		j := &job{done: make(chan struct{})}
+		if err := json.Unmarshal(data, &j.Job); err != nil {
+			return fmt.Errorf("jobs: decoding %s: %w", k, err)
+		}
+		id, err := strconv.ParseUint(j.ID, 10, 64)
+		if err != nil || jobKey(id) != k {
+			return fmt.Errorf("jobs: %s holds job %q", k, j.ID)
+		}
+		q.nextID = max(q.nextID, id)
+
+		q.jobs[j.ID] = j
+		if j.Key != "" {
+			q.keys[j.Key] = j
+		}
+		switch j.State {
+		case Queued:
+			q.push(j)
+		case Running:
+			q.timers = append(q.timers, time.AfterFunc(j.Lease.Sub(now), func() { q.lost(j) }))
+		default:
+			close(j.done)
+			q.finished = append(q.finished, j)
+		}
+	}
+	slices.SortStableFunc(q.finished, func(a, b *job) int {
+		return a.Finished.Compare(b.Finished)
+	})
+	q.expire()
+	return nil
+}
+
+// lost queues again a job that was running when the queue's previous
+// process died, or fails it if that was its last attempt.
+func (q *Queue) lost(j *job) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed || j.State != Running {
+		return
+	}
+	j.Lease = time.Time{}
+	j.Err = "lost: visibility timeout expired"
+	if n := len(j.History); n > 0 && j.History[n-1].End.IsZero() {
+		j.History[n-1].End, j.History[n-1].Err = q.now(), j.Err
+	}
+	if j.Attempts >= q.maxAttempts {
+		j.State = Failed
+		q.finish(j)
+	} else {
+		j.State = Queued
+		q.push(j)
+		q.cond.Signal()
+	}
+	q.saveOrCount(j)
+}
+
+// save writes j through to the store, if any. Caller holds mu, which
+// keeps the writes of one job in order.
+func (q *Queue) save(j *job) error {
+	if q.store == nil {
+		return nil
+	}
+	data, err := json.Marshal(j.Job)
+	if err != nil {
+		return err
+	}
+	return q.store.Put(context.Background(), jobKey(j.seq()), data)
+}
+
+// saveOrCount is save for the background, where failures can only be
+// counted. Caller holds mu.
+func (q *Queue) saveOrCount(j *job) {
+	if err := q.save(j); err != nil {
+		q.storeErrors.Add(1)
+	}
+}
+
+// forget deletes an expired job from the store, first recording the last
+// ID so that it is never handed out again. Caller holds mu.
+func (q *Queue) forget(j *job) {
+	if q.store == nil {
+		return
+	}
+	ctx := context.Background()
+	if err := q.store.Put(ctx, nextKey, []byte(strconv.FormatUint(q.nextID, 10))); err != nil {
+		q.storeErrors.Add(1)
+		return // keep the job, so its ID stays taken
+	}
+	if err := q.store.Delete(ctx, jobKey(j.seq())); err != nil && !errors.Is(err, store.ErrNotFound) {
+		q.storeErrors.Add(1)
+	}
+}
+
+// StoreErrors returns how many job updates could not be written to the
+// store since the queue started. The jobs involved carry on in memory,
+// but a restart would find them as they were last stored.
+func (q *Queue) StoreErrors() uint64 {
+	return q.storeErrors.Load()
+}
@@ -0,0 +1,238 @@
+package jobs
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/arifmahmudrana/go-snippets/store"
+)
+
+// recorder is a handler that upper-cases payloads and remembers them.
+type recorder struct {
+	mu   sync.Mutex
+	runs []string
+}
+
+func (r *recorder) handle(ctx context.Context, payload []byte) ([]byte, error) {
+	r.mu.Lock()
+	r.runs = append(r.runs, string(payload))
+	r.mu.Unlock()
+	return bytes.ToUpper(payload), nil
+}
+
+func (r *recorder) ran() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.runs...)
+}
+
+// TestOpen_Restart tests that a queue reopened on the same store runs the
+// jobs its predecessor left queued and remembers the ones it finished
+func TestOpen_Restart(t *testing.T) {
+	s := store.NewMemory()
+
+	started := make(chan struct{})
+	q, err := Open(s, func(ctx context.Context, payload []byte) ([]byte, error) {
+		if string(payload) == "slow" {
+			close(started)
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}
+		return payload, nil
+	}, WithWorkers(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	done, _ := q.Enqueue([]byte("a"), WithKey("a"))
+	wait(t, done)
+	q.Enqueue([]byte("slow"))
+	q.Enqueue([]byte("b"))
+	<-started
+	q.Stop()
+
+	var r recorder
+	q, err = Open(s, r.handle, WithWorkers(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+
+	h, err := q.Enqueue([]byte("a"), WithKey("a"))
+	if err != nil || !h.Duplicate || h.ID() != done.ID() {
+		t.Fatalf("Enqueue() after restart = %+v, %v, want duplicate of job %s", h, err, done.ID())
+	}
+	if got, err := wait(t, h); err != nil || string(got) != "a" {
+		t.Errorf("Wait() = %q, %v, want the first run's result", got, err)
+	}
+	h, _ = q.Enqueue([]byte("c"))
+	wait(t, h)
+	if h.ID() != "4" {
+		t.Errorf("new job ID = %s, want 4", h.ID())
+	}
+	if got, want := fmt.Sprint(r.ran()), "[slow b c]"; got != want {
+		t.Errorf("ran %s after restart, want %s", got, want)
+	}
+}
+
+// TestOpen_ExpiredIDs tests that IDs are not reused after the jobs that
+// had them expire
+func TestOpen_ExpiredIDs(t *testing.T) {
+	s := store.NewMemory()
+	q, _ := Open(s, echo, WithRetention(0))
+	for range 3 {
+		h, _ := q.Enqueue(nil)
+		wait(t, h)
+	}
+	q.Get("1") // expires everything
+	q.Close()
+
+	q, _ = Open(s, echo)
+	defer q.Close()
+	if keys, _ := s.List(context.Background(), jobPrefix); len(keys) != 0 {
+		t.Errorf("store holds %v, want expired jobs deleted", keys)
+	}
+	if h, _ := q.Enqueue(nil); h.ID() != "4" {
+		t.Errorf("new job ID = %s, want 4", h.ID())
+	}
+}
+
+// TestOpen_StoreErrors tests that Enqueue fails if the job cannot be
+// stored
+func TestOpen_StoreErrors(t *testing.T) {
+	q, _ := Open(failingStore{store.NewMemory()}, echo)
+	defer q.Close()
+	if _, err := q.Enqueue(nil); !errors.Is(err, errDiskFull) {
+		t.Errorf("Enqueue() = %v, want %v", err, errDiskFull)
+	}
+	if n := q.Len(); n != 0 {
+		t.Errorf("Len() = %d, want 0", n)
+	}
+}
+
+var errDiskFull = errors.New("disk full")
+
+type failingStore struct{ store.Store }
+
+func (failingStore) Put(ctx context.Context, key string, value []byte) error {
+	return errDiskFull
+}
+
+// TestOpen_KillAndRestart tests recovery from a process killed while a
+// job was running: the finished job is not run again, the queued one
+// runs, and the interrupted one runs once its visibility timeout expires
+func TestOpen_KillAndRestart(t *testing.T) {
+	if testing.Short() {
+		t.Skip("starts a subprocess")
+	}
+	for _, kind := range []string{"file", "wal"} {
+		t.Run(kind, func(t *testing.T) {
+			killAndRestart(t, kind)
+		})
+	}
+}
+
+// openKind opens a queue in dir, on a store.File or with OpenWAL.
+func openKind(kind, dir string, h Handler, opts ...Option) (*Queue, error) {
+	if kind == "wal" {
+		return OpenWAL(dir, h, opts...)
+	}
+	s, err := store.NewFile(dir)
+	if err != nil {
+		return nil, err
+	}
+	return Open(s, h, opts...)
+}
+
+func killAndRestart(t *testing.T, kind string) {
+	dir := t.TempDir()
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestHelperProcess$")
+	cmd.Env = append(os.Environ(), "JOBS_HELPER_DIR="+dir, "JOBS_HELPER_STORE="+kind)
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	// kill it as soon as the hanging job is running
+	sc := bufio.NewScanner(out)
+	for sc.Scan() && sc.Text() != "running" {
+	}
+	cmd.Process.Kill()
+	cmd.Wait()
+
+	var r recorder
+	start := time.Now()
+	q, err := openKind(kind, dir, r.handle, WithWorkers(1), WithVisibilityTimeout(helperVisibility))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+
+	want := []struct {
+		id       string
+		state    State
+		attempts int
+		result   string
+	}{
+		{"1", Succeeded, 1, "A"},
+		{"2", Succeeded, 2, "HANG"},
+		{"3", Succeeded, 1, "B"},
+	}
+	for _, w := range want {
+		h, err := q.Get(w.id)
+		if err != nil {
+			t.Fatalf("Get(%s) = %v", w.id, err)
+		}
+		got, err := wait(t, h)
+		if j := h.Job(); j.State != w.state || j.Attempts != w.attempts || string(got) != w.result {
+			t.Errorf("job %s: %s after %d attempts with %q (%v), want %s after %d with %q",
+				w.id, j.State, j.Attempts, got, err, w.state, w.attempts, w.result)
+		}
+	}
+	if got, want := fmt.Sprint(r.ran()), "[b hang]"; got != want {
+		t.Errorf("ran %s after restart, want %s", got, want)
+	}
+	if d := time.Since(start); d < helperVisibility/2 {
+		t.Errorf("interrupted job ran again after %v, before its visibility timeout", d)
+	}
+}
+
+const helperVisibility = 500 * time.Millisecond
+
+// TestHelperProcess is the process TestOpen_KillAndRestart kills. It
+// only runs when started by that test.
+func TestHelperProcess(t *testing.T) {
+	dir := os.Getenv("JOBS_HELPER_DIR")
+	if dir == "" {
+		t.Skip("helper process")
+	}
+	enqueued := make(chan struct{})
+	q, err := openKind(os.Getenv("JOBS_HELPER_STORE"), dir, func(ctx context.Context, payload []byte) ([]byte, error) {
+		if string(payload) == "hang" {
+			<-enqueued
+			fmt.Println("running")
+			time.Sleep(time.Minute) // until killed
+		}
+		return bytes.ToUpper(payload), nil
+	}, WithWorkers(1), WithVisibilityTimeout(helperVisibility))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []string{"a", "hang", "b"} {
+		if _, err := q.Enqueue([]byte(p)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	close(enqueued)
+	time.Sleep(time.Minute)
+}
@@ -0,0 +1,150 @@
+package lazy
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Value is a lazily initialised value, safe for concurrent use.
+//
+// Unlike sync.Once, a failed initialisation is not final: by default the
+// next Get tries again. Concurrent callers share a single in-flight
+// initialisation instead of each running their own (see Get).
+type Value[T any] struct {
+	init       func(ctx context.Context) (T, error)
+	retryDelay time.Duration
+	now        func() time.Time
+
+	val atomic.Pointer[T] // set once initialised; the lock-free fast path
+
+	mu       sync.Mutex
+	err      error
+	failedAt time.Time
+	inflight *call[T] // the running init, if any
+}
+
+// Option configures a Value.
+type Option func(*options)
+
+type options struct {
+	retryDelay time.Duration
+}
+
+// WithRetryDelay caches an initialisation error for d: Gets within d of
+// the failure return the same error without calling init. A negative d
+// caches the error forever, like sync.OnceValues.
+func WithRetryDelay(d time.Duration) Option {
+	return func(o *options) {
+		o.retryDelay = d
+	}
+}
+
+// New returns a Value that calls init on first use.
+func New[T any](init func(ctx context.Context) (T, error), opts ...Option) *Value[T] {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return &Value[T]{init: init, retryDelay: o.retryDelay, now: time.Now}
+}
+
+// Get returns the value, initialising it if needed. Concurrent callers
+// share one initialisation, and each waits for it only until its own
+// ctx is done.
+//
+// init runs in a goroutine of its own, with ctx's values but not its
+// cancellation: a caller giving up neither stops it nor fails the
+// other callers, and its ctx.Err() is never cached. A panic in init is
+// recovered and reported to the callers as an error.
+func (v *Value[T]) Get(ctx context.Context) (T, error) {
+	if p := v.val.Load(); p != nil {
+		return *p, nil
+	}
+
+	v.mu.Lock()
+	if p := v.val.Load(); p != nil {
+		v.mu.Unlock()
+		return *p, nil
+	}
+
+	if v.err != nil && (v.retryDelay < 0 || v.now().Sub(v.failedAt) < v.retryDelay) {
+		err := v.err
+		v.mu.Unlock()
+		var zero T
+		return zero, err
+	}
+
+	// share the running initialisation, or start one
+	c := v.inflight
+	if c == nil {
+		c = &call[T]{done: make(chan struct{})}
+		v.inflight = c
+		go v.run(context.WithoutCancel(ctx), c)
+	}
+	v.mu.Unlock()
+
+	select {
+	case <-ctx.Done():
+		var zero T
+		return zero, ctx.Err()
+	case <-c.done:
+		return c.val, c.err
+	}
+}
+
+// run calls init and records its result in c and v. The deferred
+// cleanup releases the waiters even if init panics.
+func (v *Value[T]) run(ctx context.Context, c *call[T]) {
+	defer func() {
+		if p := recover(); p != nil {
+			c.err = panicError{p}
+		}
+		v.mu.Lock()
+		defer v.mu.Unlock()
+		// Reset removes c, so a result computed before it is not stored
+		// after it
+		if v.inflight == c {
+			v.inflight = nil
+			if c.err == nil {
+				v.err = nil
+				v.val.Store(&c.val)
+			} else {
+				v.err, v.failedAt = c.err, v.now()
+			}
+		}
+		close(c.done)
+	}()
+	c.val, c.err = v.init(ctx)
+}
+
+// call is a running initialisation.
+type call[T any] struct {
+	done chan struct{} // closed once val and err are set
+	val  T
+	err  error
+}
+
+// panicError reports an init that panicked.
+type panicError struct {
+	value any
+}
+
+func (p panicError) Error() string {
+	return fmt.Sprintf("lazy: init panicked: %v", p.value)
+}
+
+// Reset forgets the value (or cached error) so the next Get initialises
+// again. A running initialisation is not interrupted: it still answers
+// the callers waiting on it, but its result is not kept, and the next
+// Get starts another.
+func (v *Value[T]) Reset() {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	v.inflight = nil
+	v.err = nil
+	v.val.Store(nil)
+}
@@ -0,0 +1,218 @@
+package lazy
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestValue_InitOnce tests that concurrent callers share one initialisation
+func TestValue_InitOnce(t *testing.T) {
+	var calls atomic.Int32
+	v := New(func(ctx context.Context) (string, error) {
+		calls.Add(1)
+		time.Sleep(10 * time.Millisecond)
+		return "schema", nil
+	})
+
+	var wg sync.WaitGroup
+	for range 16 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			got, err := v.Get(context.Background())
+			if err != nil || got != "schema" {
+				t.Errorf("Get() = %q, %v", got, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n := calls.Load(); n != 1 {
+		t.Errorf("init called %d times, want 1", n)
+	}
+}
+
+// TestValue_RetriesAfterError tests the default retry-on-next-Get policy
+func TestValue_RetriesAfterError(t *testing.T) {
+	var calls atomic.Int32
+	v := New(func(ctx context.Context) (int, error) {
+		if calls.Add(1) == 1 {
+			return 0, errors.New("registry down")
+		}
+		return 42, nil
+	})
+
+	if _, err := v.Get(context.Background()); err == nil {
+		t.Fatal("first Get() should fail")
+	}
+	if got, err := v.Get(context.Background()); err != nil || got != 42 {
+		t.Errorf("second Get() = %d, %v, want 42, nil", got, err)
+	}
+}
+
+// TestValue_RetryDelay tests that errors are cached for the retry delay
+func TestValue_RetryDelay(t *testing.T) {
+	now := time.Now()
+	var calls atomic.Int32
+	v := New(func(ctx context.Context) (int, error) {
+		calls.Add(1)
+		return 0, errors.New("boom")
+	}, WithRetryDelay(time.Second))
+	v.now = func() time.Time { return now }
+
+	v.Get(context.Background())
+	v.Get(context.Background())
+	if n := calls.Load(); n != 1 {
+		t.Errorf("init called %d times within delay, want 1", n)
+	}
+
+	now = now.Add(2 * time.Second)
+	v.Get(context.Background())
+	if n := calls.Load(); n != 2 {
+		t.Errorf("init called %d times after delay, want 2", n)
+	}
+}
+
+// TestValue_NeverRetry tests that a negative delay caches the error forever
+func TestValue_NeverRetry(t *testing.T) {
+	var calls atomic.Int32
+	v := New(func(ctx context.Context) (int, error) {
+		calls.Add(1)
+		return 0, errors.New("boom")
+	}, WithRetryDelay(-1))
+
+	for range 3 {
+		v.Get(context.Background())
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("init called %d times, want 1", n)
+	}
+}
+
+// TestValue_WaiterCancelled tests that a waiter can give up on a slow init
+func TestValue_WaiterCancelled(t *testing.T) {
+	release := make(chan struct{})
+	v := New(func(ctx context.Context) (int, error) {
+		<-release
+		return 1, nil
+	})
+	go v.Get(context.Background())
+	time.Sleep(5 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+	defer cancel()
+	if _, err := v.Get(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Get() = %v, want %v", err, context.DeadlineExceeded)
+	}
+	close(release)
+}
+
+// TestValue_Reset tests re-initialisation after Reset
+func TestValue_Reset(t *testing.T) {
+	var calls atomic.Int32
+	v := New(func(ctx context.Context) (int32, error) {
+		return calls.Add(1), nil
+	})
+
+	v.Get(context.Background())
+	v.Reset()
+	if got, _ := v.Get(context.Background()); got != 2 {
+		t.Errorf("Get() after Reset = %d, want 2", got)
+	}
+}
+
+// TestValue_ResetDuringInit tests that an initialisation running when
+// Reset is called does not store its result afterwards
+func TestValue_ResetDuringInit(t *testing.T) {
+	var calls atomic.Int32
+	release := make(chan struct{})
+	v := New(func(ctx context.Context) (int32, error) {
+		n := calls.Add(1)
+		if n == 1 {
+			<-release
+		}
+		return n, nil
+	})
+
+	first := make(chan int32)
+	go func() {
+		got, _ := v.Get(context.Background())
+		first <- got
+	}()
+	for calls.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	v.Reset()
+	close(release)
+	if got := <-first; got != 1 {
+		t.Errorf("Get() waiting on the reset init = %d, want 1", got)
+	}
+	if got, _ := v.Get(context.Background()); got != 2 {
+		t.Errorf("Get() after Reset = %d, want 2", got)
+	}
+}
+
+// TestValue_CancelledCallerNotCached tests that the first caller giving
+// up neither fails the initialisation nor poisons later Gets, even when
+// errors are cached forever
+func TestValue_CancelledCallerNotCached(t *testing.T) {
+	var calls atomic.Int32
+	v := New(func(ctx context.Context) (int, error) {
+		calls.Add(1)
+		select {
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		case <-time.After(10 * time.Millisecond):
+			return 1, nil
+		}
+	}, WithRetryDelay(-1))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := v.Get(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("Get(cancelled) = %v, want %v", err, context.Canceled)
+	}
+	if got, err := v.Get(context.Background()); err != nil || got != 1 {
+		t.Errorf("Get() after a cancelled Get = %d, %v, want 1, nil", got, err)
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("init called %d times, want 1", n)
+	}
+}
+
+// TestValue_Panic tests that an init that panics releases its waiters
+// with an error, and is retried by the next Get
+func TestValue_Panic(t *testing.T) {
+	var calls atomic.Int32
+	v := New(func(ctx context.Context) (int, error) {
+		if calls.Add(1) == 1 {
+			time.Sleep(5 * time.Millisecond) // let the waiters queue up
+			panic("registry gone")
+		}
+		return 1, nil
+	})
+
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+			if _, err := v.Get(ctx); errors.Is(err, context.DeadlineExceeded) {
+				t.Error("Get() still waiting on an init that panicked")
+			}
+		}()
+	}
+	wg.Wait()
+	if _, err := New(func(context.Context) (int, error) { panic("boom") }).Get(context.Background()); err == nil {
+		t.Error("Get() of an init that panics = nil error, want the panic")
+	}
+	if got, err := v.Get(context.Background()); err != nil || got != 1 {
+		t.Errorf("Get() after the panic = %d, %v, want 1, nil", got, err)
+	}
+}
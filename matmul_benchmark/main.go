@@ -0,0 +1,42 @@
+// matmul_benchmark/main.go
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"runtime"
+	"time"
+
+	"github.com/arifmahmudrana/go-snippets/rtmetrics"
+)
+
+func main() {
+	n := flag.Int("n", 512, "matrix size")
+	tile := flag.Int("tile", 64, "tile size for blocked versions")
+	flag.Parse()
+
+	a, b := randomMatrix(*n, 1), randomMatrix(*n, 2)
+	fmt.Printf("n=%d tile=%d GOMAXPROCS=%d\n", *n, *tile, runtime.GOMAXPROCS(0))
+
+	before, start := rtmetrics.Take(), time.Now()
+	want := mulNaive(a, b)
+	fmt.Printf("Naive:    took=%v\n          %v\n", time.Since(start), rtmetrics.Take().Since(before))
+
+	for _, v := range []struct {
+		name string
+		mul  func() *matrix
+	}{
+		{"Tiled", func() *matrix { return mulTiled(a, b, *tile) }},
+		{"Parallel", func() *matrix { return mulParallel(a, b, *tile) }},
+	} {
+		before, start := rtmetrics.Take(), time.Now()
+		got := v.mul()
+		took, rt := time.Since(start), rtmetrics.Take().Since(before)
+		if !equal(got, want, 1e-9) {
+			fmt.Fprintf(os.Stderr, "%s: result differs from naive\n", v.name)
+			os.Exit(1)
+		}
+		fmt.Printf("%-9s took=%v\n          %v\n", v.name+":", took, rt)
+	}
+}
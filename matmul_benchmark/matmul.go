@@ -0,0 +1,112 @@
+// matmul_benchmark/matmul.go
+package main
+
+import (
+	"math"
+	"math/rand"
+	"sync"
+)
+
+// matrix is a square, row-major matrix of float64.
+type matrix struct {
+	n    int
+	data []float64
+}
+
+func newMatrix(n int) *matrix {
+	return &matrix{n: n, data: make([]float64, n*n)}
+}
+
+// randomMatrix fills an n×n matrix with small integers so that every
+// summation order produces exactly the same result.
+func randomMatrix(n int, seed int64) *matrix {
+	rng := rand.New(rand.NewSource(seed))
+	m := newMatrix(n)
+	for i := range m.data {
+		m.data[i] = float64(rng.Intn(10))
+	}
+	return m
+}
+
+func (m *matrix) at(i, j int) float64 { return m.data[i*m.n+j] }
+
+// equal reports whether a and b match within tol per element.
+func equal(a, b *matrix, tol float64) bool {
+	if a.n != b.n {
+		return false
+	}
+	for i := range a.data {
+		if math.Abs(a.data[i]-b.data[i]) > tol {
+			return false
+		}
+	}
+	return true
+}
+
+// mulNaive is the textbook i-j-k triple loop and the reference result.
+// The inner loop walks b down a column, touching a new cache line on
+// every step once n is large.
+func mulNaive(a, b *matrix) *matrix {
+	n := a.n
+	c := newMatrix(n)
+	for i := 0; i < n; i++ {
+		for j := 0; j < n; j++ {
+			sum := 0.0
+			for k := 0; k < n; k++ {
+				sum += a.data[i*n+k] * b.data[k*n+j]
+			}
+			c.data[i*n+j] = sum
+		}
+	}
+	return c
+}
+
+// mulTiled computes c in tile×tile blocks so the working set of a, b and
+// c stays in cache while it is reused.
+func mulTiled(a, b *matrix, tile int) *matrix {
+	c := newMatrix(a.n)
+	for i0 := 0; i0 < a.n; i0 += tile {
+		for j0 := 0; j0 < a.n; j0 += tile {
+			mulBlock(a, b, c, i0, j0, tile)
+		}
+	}
+	return c
+}
+
+// mulParallel starts one goroutine per tile×tile block of c. Blocks write
+// disjoint parts of c, so no locking is needed.
+func mulParallel(a, b *matrix, tile int) *matrix {
+	c := newMatrix(a.n)
+	var wg sync.WaitGroup
+	for i0 := 0; i0 < a.n; i0 += tile {
+		for j0 := 0; j0 < a.n; j0 += tile {
+			wg.Add(1)
+			go func(i0, j0 int) {
+				defer wg.Done()
+				mulBlock(a, b, c, i0, j0, tile)
+			}(i0, j0)
+		}
+	}
+	wg.Wait()
+	return c
+}
+
+// mulBlock accumulates the c block at (i0, j0) over all k tiles. The
+// i-k-j order keeps the innermost loop on contiguous rows of b and c.
+func mulBlock(a, b, c *matrix, i0, j0, tile int) {
+	n := a.n
+	iEnd, jEnd := min(i0+tile, n), min(j0+tile, n)
+	for k0 := 0; k0 < n; k0 += tile {
+		kEnd := min(k0+tile, n)
+		for i := i0; i < iEnd; i++ {
+			crow := c.data[i*n : i*n+n]
+			for k := k0; k < kEnd; k++ {
+				aik := a.data[i*n+k]
+				brow := b.data[k*n : k*n+n]
+				for j := j0; j < jEnd; j++ {
+					crow[j] += aik * brow[j]
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,59 @@
+// matmul_benchmark/matmul_test.go
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+// TestMul tests the blocked versions against the naive reference,
+// including sizes that are not a multiple of the tile
+func TestMul(t *testing.T) {
+	for _, n := range []int{1, 7, 64, 100} {
+		a, b := randomMatrix(n, 1), randomMatrix(n, 2)
+		want := mulNaive(a, b)
+		for _, tile := range []int{1, 16, 32, 128} {
+			if got := mulTiled(a, b, tile); !equal(got, want, 1e-9) {
+				t.Errorf("mulTiled(n=%d, tile=%d) differs from mulNaive", n, tile)
+			}
+			if got := mulParallel(a, b, tile); !equal(got, want, 1e-9) {
+				t.Errorf("mulParallel(n=%d, tile=%d) differs from mulNaive", n, tile)
+			}
+		}
+	}
+}
+
+// TestMulNaive_Identity tests the reference against a known product
+func TestMulNaive_Identity(t *testing.T) {
+	a := randomMatrix(5, 3)
+	id := newMatrix(5)
+	for i := range 5 {
+		id.data[i*5+i] = 1
+	}
+	if got := mulNaive(a, id); !equal(got, a, 0) {
+		t.Errorf("a × I = %v, want %v", got.data, a.data)
+	}
+}
+
+func BenchmarkMul(b *testing.B) {
+	for _, n := range []int{128, 512} {
+		x, y := randomMatrix(n, 1), randomMatrix(n, 2)
+		b.Run(fmt.Sprintf("Naive/%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				mulNaive(x, y)
+			}
+		})
+		for _, tile := range []int{32, 64} {
+			b.Run(fmt.Sprintf("Tiled/%d/tile=%d", n, tile), func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					mulTiled(x, y, tile)
+				}
+			})
+			b.Run(fmt.Sprintf("Parallel/%d/tile=%d", n, tile), func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					mulParallel(x, y, tile)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,491 @@
+package membership
+
+import (
+	"encoding/json"
+	"errors"
+	"math/rand/v2"
+	"net"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrNoSeeds is returned by Join when none of the seed addresses answer.
+var ErrNoSeeds = errors.New("membership: no seed answered")
+
+// State is a member's status as seen by this node.
+type State int
+
+const (
+	Alive State = iota
+	Suspect
+	Dead
+	Left
+)
+
+func (s State) String() string {
+	switch s {
+	case Alive:
+		return "alive"
+	case Suspect:
+		return "suspect"
+	case Dead:
+		return "dead"
+	case Left:
+		return "left"
+	}
+	return "unknown"
+}
+
+// Member is one node of the cluster.
+//
+// Incarnation orders claims about a member: a higher incarnation always
+// wins, and within one incarnation Left beats Dead beats Suspect beats
+// Alive. Only the member itself raises its incarnation, which is how it
+// refutes a suspicion.
+type Member struct {
+	Name        string
+	Addr        string
+	State       State
+	Incarnation uint64
+}
+
+// Config configures a Node. Zero durations get sensible defaults.
+type Config struct {
+	Name string
+	Addr string // UDP address to listen on, e.g. "127.0.0.1:0"
+
+	ProbeInterval  time.Duration // how often one member is probed (default 1s)
+	ProbeTimeout   time.Duration // how long to wait for a direct ack (default 200ms)
+	SuspectTimeout time.Duration // how long a suspect has to refute (default 5s)
+	IndirectChecks int           // helpers asked to probe on our behalf (default 3)
+
+	// OnChange, if set, is called whenever a member is added or its state
+	// changes. It runs on the node's goroutines and must not block.
+	OnChange func(Member)
+}
+
+// message is the wire format. Every message carries the sender's view of
+// the cluster, which is how updates spread (piggybacking).
+type message struct {
+	Type    string   `json:"type"` // ping, ack, ping-req
+	Seq     uint64   `json:"seq"`
+	Target  string   `json:"target,omitempty"` // ping-req: address to probe
+	Members []Member `json:"members"`
+}
+
+type member struct {
+	Member
+	suspectAt time.Time
+}
+
+// Node is one participant in SWIM-style membership: it periodically
+// probes a random peer, asks others to probe indirectly when the peer
+// does not answer, marks it suspect and finally dead, and gossips every
+// change on the messages it already sends.
+type Node struct {
+	cfg  Config
+	conn net.PacketConn
+
+	mu      sync.Mutex
+	self    Member
+	members map[string]*member
+	seq     uint64
+	acks    map[uint64]func() // pending acks by sequence number
+	order   []string          // probe order, reshuffled every round
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+	once sync.Once
+}
+
+// Start listens on cfg.Addr and starts probing. The node knows only
+// itself until Join is called or another node joins through it.
+func Start(cfg Config) (*Node, error) {
+	if cfg.ProbeInterval <= 0 {
+		cfg.ProbeInterval = time.Second
+	}
+	if cfg.ProbeTimeout <= 0 {
+		cfg.ProbeTimeout = 200 * time.Millisecond
+	}
+	if cfg.SuspectTimeout <= 0 {
+		cfg.SuspectTimeout = 5 * time.Second
+	}
+	if cfg.IndirectChecks <= 0 {
+		cfg.IndirectChecks = 3
+	}
+
+	conn, err := net.ListenPacket("udp", cfg.Addr)
+	if err != nil {
+		return nil, err
+	}
+
+	n := &Node{
+		cfg:  cfg,
+		conn: conn,
+		// start from the clock so a restarted node outranks its old self
+		self:    Member{Name: cfg.Name, Addr: conn.LocalAddr().String(), State: Alive, Incarnation: uint64(time.Now().UnixNano())},
+		members: make(map[string]*member),
+		acks:    make(map[uint64]func()),
+		stop:    make(chan struct{}),
+	}
+	n.wg.Add(2)
+	go n.receive()
+	go n.probeLoop()
+	return n, nil
+}
+
+// Addr returns the address the node listens on.
+func (n *Node) Addr() string {
+	return n.self.Addr
+}
+
+// Members returns every known member including this node, sorted by name.
+func (n *Node) Members() []Member {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.snapshot()
+}
+
+func (n *Node) snapshot() []Member {
+	out := []Member{n.self}
+	for _, m := range n.members {
+		out = append(out, m.Member)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// Leader returns the member this node considers the leader: the one
+// with the lowest name among those not dead or gone. Suspects keep the
+// role, so a slow leader is not deposed before it can refute. Nodes
+// with the same view of the cluster agree on the leader, and when it
+// fails the next one takes over as soon as the failure is confirmed.
+// It returns "" if this node has left.
+//
+// This is best-effort leader election, not a lease: views differ while
+// gossip spreads, so a node that has not joined yet leads itself, and
+// each side of a partition elects its own leader. Work that must not
+// run twice needs an atomic claim on top, such as jobs.WithClaims.
+func (n *Node) Leader() string {
+	for _, m := range n.Members() {
+		if m.State == Alive || m.State == Suspect {
+			return m.Name
+		}
+	}
+	return ""
+}
+
+// IsLeader reports whether this node is the leader. A closed node never
+// is.
+func (n *Node) IsLeader() bool {
+	select {
+	case <-n.stop:
+		return false
+	default:
+	}
+	return n.Leader() == n.cfg.Name
+}
+
+// Join contacts the seed addresses and waits for at least one to answer,
+// after which the rest of the cluster is learned by gossip.
+func (n *Node) Join(seeds ...string) error {
+	acked := make(chan struct{}, len(seeds))
+	for _, addr := range seeds {
+		n.sendPing(addr, func() { acked <- struct{}{} })
+	}
+
+	select {
+	case <-acked:
+		return nil
+	case <-time.After(n.cfg.ProbeInterval + n.cfg.ProbeTimeout):
+		return ErrNoSeeds
+	}
+}
+
+// Leave tells the alive members that this node is leaving, so they drop
+// it at once instead of waiting for the suspicion timeout, then closes.
+func (n *Node) Leave() error {
+	n.mu.Lock()
+	n.self.State = Left
+	n.self.Incarnation++
+	var peers []string
+	for _, m := range n.members {
+		if m.State == Alive || m.State == Suspect {
+			peers = append(peers, m.Addr)
+		}
+	}
+	n.mu.Unlock()
+
+	acked := make(chan struct{}, len(peers))
+	for _, addr := range peers {
+		n.sendPing(addr, func() { acked <- struct{}{} })
+	}
+	timeout := time.After(n.cfg.ProbeTimeout)
+	for range peers {
+		select {
+		case <-acked:
+		case <-timeout:
+			return n.Close()
+		}
+	}
+	return n.Close()
+}
+
+// Close stops the node without telling anyone; peers will detect the
+// failure by probing.
+func (n *Node) Close() error {
+	var err error
+	n.once.Do(func() {
+		close(n.stop)
+		err = n.conn.Close()
+		n.wg.Wait()
+	})
+	return err
+}
+
+// send encodes and sends msg with the current cluster view attached.
+func (n *Node) send(addr string, msg message) {
+	n.mu.Lock()
+	msg.Members = n.snapshot()
+	n.mu.Unlock()
+
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return
+	}
+	udp, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return
+	}
+	n.conn.WriteTo(data, udp)
+}
+
+// sendPing pings addr and calls onAck when the ack arrives.
+func (n *Node) sendPing(addr string, onAck func()) uint64 {
+	n.mu.Lock()
+	n.seq++
+	seq := n.seq
+	n.acks[seq] = onAck
+	n.mu.Unlock()
+
+	n.send(addr, message{Type: "ping", Seq: seq})
+	return seq
+}
+
+func (n *Node) forgetAck(seq uint64) {
+	n.mu.Lock()
+	delete(n.acks, seq)
+	n.mu.Unlock()
+}
+
+func (n *Node) receive() {
+	defer n.wg.Done()
+	buf := make([]byte, 64<<10)
+	for {
+		size, from, err := n.conn.ReadFrom(buf)
+		if err != nil {
+			return // closed
+		}
+		var msg message
+		if json.Unmarshal(buf[:size], &msg) != nil {
+			continue
+		}
+		n.merge(msg.Members)
+
+		switch msg.Type {
+		case "ping":
+			n.send(from.String(), message{Type: "ack", Seq: msg.Seq})
+
+		case "ack":
+			n.mu.Lock()
+			onAck := n.acks[msg.Seq]
+			delete(n.acks, msg.Seq)
+			n.mu.Unlock()
+			if onAck != nil {
+				onAck()
+			}
+
+		case "ping-req":
+			// probe the target for the requester and relay the ack
+			requester, seq := from.String(), msg.Seq
+			relay := n.sendPing(msg.Target, func() {
+				n.send(requester, message{Type: "ack", Seq: seq})
+			})
+			time.AfterFunc(n.cfg.ProbeTimeout, func() { n.forgetAck(relay) })
+		}
+	}
+}
+
+func rank(s State) int { return int(s) }
+
+// merge applies gossiped member states and fires OnChange for changes.
+func (n *Node) merge(ms []Member) {
+	var changed []Member
+	now := time.Now()
+
+	n.mu.Lock()
+	for _, m := range ms {
+		if m.Name == n.self.Name {
+			// someone thinks we are failing: refute with a newer incarnation
+			if n.self.State == Alive && m.State != Alive && m.Incarnation >= n.self.Incarnation {
+				n.self.Incarnation = m.Incarnation + 1
+			}
+			continue
+		}
+
+		cur, ok := n.members[m.Name]
+		if ok && !(m.Incarnation > cur.Incarnation ||
+			(m.Incarnation == cur.Incarnation && rank(m.State) > rank(cur.State))) {
+			continue
+		}
+		if !ok {
+			cur = &member{}
+			n.members[m.Name] = cur
+		}
+		stateChanged := !ok || cur.State != m.State
+		cur.Member = m
+		if m.State == Suspect && stateChanged {
+			cur.suspectAt = now
+		}
+		if stateChanged {
+			changed = append(changed, m)
+		}
+	}
+	n.mu.Unlock()
+
+	n.notify(changed)
+}
+
+func (n *Node) notify(changed []Member) {
+	if n.cfg.OnChange == nil {
+		return
+	}
+	for _, m := range changed {
+		n.cfg.OnChange(m)
+	}
+}
+
+// setState changes a member's state at its current incarnation.
+func (n *Node) setState(name string, s State) {
+	n.mu.Lock()
+	m, ok := n.members[name]
+	if !ok || rank(m.State) >= rank(s) {
+		n.mu.Unlock()
+		return
+	}
+	m.State = s
+	if s == Suspect {
+		m.suspectAt = time.Now()
+	}
+	changed := m.Member
+	n.mu.Unlock()
+
+	n.notify([]Member{changed})
+}
+
+// nextTarget returns the next member to probe, walking a shuffled order
+// so every member is probed once per round.
+func (n *Node) nextTarget() (Member, bool) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for range 2 {
+		for len(n.order) > 0 {
+			name := n.order[0]
+			n.order = n.order[1:]
+			if m, ok := n.members[name]; ok && (m.State == Alive || m.State == Suspect) {
+				return m.Member, true
+			}
+		}
+		for name := range n.members {
+			n.order = append(n.order, name)
+		}
+		rand.Shuffle(len(n.order), func(i, j int) { n.order[i], n.order[j] = n.order[j], n.order[i] })
+	}
+	return Member{}, false
+}
+
+// helpers returns up to k alive members other than target.
+func (n *Node) helpers(target string, k int) []string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	var addrs []string
+	for name, m := range n.members {
+		if name != target && m.State == Alive {
+			addrs = append(addrs, m.Addr)
+		}
+	}
+	rand.Shuffle(len(addrs), func(i, j int) { addrs[i], addrs[j] = addrs[j], addrs[i] })
+	return addrs[:min(k, len(addrs))]
+}
+
+func (n *Node) probeLoop() {
+	defer n.wg.Done()
+	ticker := time.NewTicker(n.cfg.ProbeInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-n.stop:
+			return
+		case <-ticker.C:
+		}
+		n.expireSuspects()
+		if target, ok := n.nextTarget(); ok {
+			n.probe(target)
+		}
+	}
+}
+
+// probe pings target directly, then indirectly through helpers, and
+// marks it suspect if nobody got an answer within the probe interval.
+func (n *Node) probe(target Member) {
+	acked := make(chan struct{}, 1+n.cfg.IndirectChecks)
+	onAck := func() {
+		select {
+		case acked <- struct{}{}:
+		default:
+		}
+	}
+
+	seq := n.sendPing(target.Addr, onAck)
+	defer n.forgetAck(seq)
+	select {
+	case <-acked:
+		return
+	case <-n.stop:
+		return
+	case <-time.After(n.cfg.ProbeTimeout):
+	}
+
+	n.mu.Lock()
+	n.acks[seq] = onAck // keep listening: a relayed ack reuses seq
+	n.mu.Unlock()
+	for _, addr := range n.helpers(target.Name, n.cfg.IndirectChecks) {
+		n.send(addr, message{Type: "ping-req", Seq: seq, Target: target.Addr})
+	}
+
+	select {
+	case <-acked:
+	case <-n.stop:
+	case <-time.After(n.cfg.ProbeInterval - n.cfg.ProbeTimeout):
+		n.setState(target.Name, Suspect)
+	}
+}
+
+// expireSuspects declares dead every suspect that failed to refute in time.
+func (n *Node) expireSuspects() {
+	now := time.Now()
+	n.mu.Lock()
+	var expired []string
+	for name, m := range n.members {
+		if m.State == Suspect && now.Sub(m.suspectAt) >= n.cfg.SuspectTimeout {
+			expired = append(expired, name)
+		}
+	}
+	n.mu.Unlock()
+
+	for _, name := range expired {
+		n.setState(name, Dead)
+	}
+}
@@ -0,0 +1,152 @@
+package membership
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func startNode(t *testing.T, name string) *Node {
+	t.Helper()
+	n, err := Start(Config{
+		Name:           name,
+		Addr:           "127.0.0.1:0",
+		ProbeInterval:  40 * time.Millisecond,
+		ProbeTimeout:   15 * time.Millisecond,
+		SuspectTimeout: 120 * time.Millisecond,
+		IndirectChecks: 2,
+	})
+	if err != nil {
+		t.Fatalf("Start(%s) error = %v", name, err)
+	}
+	t.Cleanup(func() { n.Close() })
+	return n
+}
+
+// stateOf returns name's state as seen by n.
+func stateOf(n *Node, name string) (State, bool) {
+	for _, m := range n.Members() {
+		if m.Name == name {
+			return m.State, true
+		}
+	}
+	return 0, false
+}
+
+// eventually polls cond until it holds or the deadline passes.
+func eventually(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// cluster starts n nodes joined through the first one and waits until
+// everyone sees everyone alive.
+func cluster(t *testing.T, names ...string) []*Node {
+	t.Helper()
+	nodes := make([]*Node, len(names))
+	for i, name := range names {
+		nodes[i] = startNode(t, name)
+		if i > 0 {
+			if err := nodes[i].Join(nodes[0].Addr()); err != nil {
+				t.Fatalf("Join() error = %v", err)
+			}
+		}
+	}
+
+	eventually(t, "full membership", func() bool {
+		for _, n := range nodes {
+			for _, name := range names {
+				if s, ok := stateOf(n, name); !ok || s != Alive {
+					return false
+				}
+			}
+		}
+		return true
+	})
+	return nodes
+}
+
+// TestNode_JoinSpreadsByGossip tests that nodes joined through one seed
+// discover each other
+func TestNode_JoinSpreadsByGossip(t *testing.T) {
+	cluster(t, "a", "b", "c")
+}
+
+// TestNode_DetectsFailure tests that a silently stopped node is suspected
+// and then declared dead
+func TestNode_DetectsFailure(t *testing.T) {
+	nodes := cluster(t, "a", "b", "c")
+	nodes[2].Close()
+
+	for _, n := range nodes[:2] {
+		eventually(t, "c declared dead", func() bool {
+			s, _ := stateOf(n, "c")
+			return s == Dead
+		})
+	}
+}
+
+// TestNode_Leave tests that a graceful leave is seen without waiting for
+// failure detection
+func TestNode_Leave(t *testing.T) {
+	nodes := cluster(t, "a", "b")
+
+	if err := nodes[1].Leave(); err != nil {
+		t.Fatalf("Leave() error = %v", err)
+	}
+	eventually(t, "b marked left", func() bool {
+		s, _ := stateOf(nodes[0], "b")
+		return s == Left
+	})
+}
+
+// TestNode_Refute tests that a live member overrides a suspicion about it
+func TestNode_Refute(t *testing.T) {
+	nodes := cluster(t, "a", "b")
+
+	nodes[0].setState("b", Suspect)
+	eventually(t, "b refutes", func() bool {
+		s, _ := stateOf(nodes[0], "b")
+		return s == Alive
+	})
+}
+
+// TestNode_JoinNoSeeds tests that Join fails when nobody answers
+func TestNode_JoinNoSeeds(t *testing.T) {
+	n := startNode(t, "lonely")
+	dead := startNode(t, "dead")
+	addr := dead.Addr()
+	dead.Close()
+
+	if err := n.Join(addr); !errors.Is(err, ErrNoSeeds) {
+		t.Errorf("Join() = %v, want %v", err, ErrNoSeeds)
+	}
+}
+
+// TestNode_Leader tests that all nodes agree on the leader and that the
+// next one takes over when it fails
+func TestNode_Leader(t *testing.T) {
+	nodes := cluster(t, "a", "b", "c")
+	for _, n := range nodes {
+		if got := n.Leader(); got != "a" {
+			t.Errorf("%s: Leader() = %q, want a", n.cfg.Name, got)
+		}
+	}
+	if !nodes[0].IsLeader() || nodes[1].IsLeader() {
+		t.Errorf("IsLeader() = %v, %v, want true, false", nodes[0].IsLeader(), nodes[1].IsLeader())
+	}
+
+	nodes[0].Close()
+	if nodes[0].IsLeader() {
+		t.Errorf("closed node still leads")
+	}
+	for _, n := range nodes[1:] {
+		eventually(t, "b takes over", func() bool { return n.Leader() == "b" })
+	}
+}
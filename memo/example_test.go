@@ -0,0 +1,54 @@
+package memo_test
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/arifmahmudrana/go-snippets/lazy"
+	"github.com/arifmahmudrana/go-snippets/memo"
+)
+
+// Schema describes the payload shape of a broker topic.
+type Schema struct {
+	Topic  string
+	Fields []string
+}
+
+// lookupSchema stands in for a slow call to a schema registry.
+func lookupSchema(ctx context.Context, topic string) (Schema, error) {
+	time.Sleep(10 * time.Millisecond)
+	return Schema{Topic: topic, Fields: []string{"id", "ts"}}, nil
+}
+
+// Schema lookups happen on every publish, so they are memoized per topic
+// with a TTL, while the registry client itself is created lazily once.
+func Example_topicSchemas() {
+	registry := lazy.New(func(ctx context.Context) (string, error) {
+		fmt.Println("connecting to registry")
+		return "registry:8081", nil
+	})
+
+	schemas := memo.New(func(ctx context.Context, topic string) (Schema, error) {
+		if _, err := registry.Get(ctx); err != nil {
+			return Schema{}, err
+		}
+		fmt.Println("lookup", topic)
+		return lookupSchema(ctx, topic)
+	}, memo.WithTTL(time.Minute), memo.WithMaxSize(1000))
+
+	ctx := context.Background()
+	for _, topic := range []string{"orders", "orders", "payments", "orders"} {
+		s, _ := schemas.Get(ctx, topic)
+		fmt.Println(s.Topic, s.Fields)
+	}
+
+	// Output:
+	// connecting to registry
+	// lookup orders
+	// orders [id ts]
+	// orders [id ts]
+	// lookup payments
+	// payments [id ts]
+	// orders [id ts]
+}
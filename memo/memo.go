@@ -0,0 +1,182 @@
+package memo
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Func memoizes an expensive function of one key. It is safe for
+// concurrent use: concurrent calls for the same missing key share one
+// underlying call, and errors are never cached.
+type Func[K comparable, V any] struct {
+	fn      func(ctx context.Context, key K) (V, error)
+	ttl     time.Duration
+	maxSize int
+	now     func() time.Time
+
+	mu       sync.Mutex
+	entries  map[K]*list.Element // of *entry[K, V]
+	lru      *list.List          // front = most recently used
+	inflight map[K]*call[V]
+}
+
+type entry[K comparable, V any] struct {
+	key     K
+	val     V
+	expires time.Time // zero means never
+}
+
+type call[V any] struct {
+	done chan struct{} // closed once val and err are set
+	val  V
+	err  error
+}
+
+// Option configures a Func.
+type Option func(*options)
+
+type options struct {
+	ttl     time.Duration
+	maxSize int
+}
+
+// WithTTL expires cached results d after they were computed.
+func WithTTL(d time.Duration) Option {
+	return func(o *options) {
+		o.ttl = d
+	}
+}
+
+// WithMaxSize keeps at most n results, evicting the least recently used.
+func WithMaxSize(n int) Option {
+	return func(o *options) {
+		o.maxSize = n
+	}
+}
+
+// New memoizes fn. Without options results are kept forever.
+func New[K comparable, V any](fn func(ctx context.Context, key K) (V, error), opts ...Option) *Func[K, V] {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return &Func[K, V]{
+		fn:       fn,
+		ttl:      o.ttl,
+		maxSize:  o.maxSize,
+		now:      time.Now,
+		entries:  make(map[K]*list.Element),
+		lru:      list.New(),
+		inflight: make(map[K]*call[V]),
+	}
+}
+
+// Get returns the cached result for key, computing it if missing or
+// expired. Concurrent callers for the same key share one call of fn,
+// and each waits for it only until its own ctx is done.
+//
+// fn runs in a goroutine of its own, with ctx's values but not its
+// cancellation: a caller giving up neither stops the call nor fails the
+// others waiting on it. A panic in fn is recovered and reported to the
+// callers as an error.
+func (f *Func[K, V]) Get(ctx context.Context, key K) (V, error) {
+	f.mu.Lock()
+	if el, ok := f.entries[key]; ok {
+		e := el.Value.(*entry[K, V])
+		if e.expires.IsZero() || f.now().Before(e.expires) {
+			f.lru.MoveToFront(el)
+			f.mu.Unlock()
+			return e.val, nil
+		}
+		f.removeLocked(el)
+	}
+
+	c, ok := f.inflight[key]
+	if !ok {
+		c = &call[V]{done: make(chan struct{})}
+		f.inflight[key] = c
+		go f.run(context.WithoutCancel(ctx), key, c)
+	}
+	f.mu.Unlock()
+
+	select {
+	case <-ctx.Done():
+		var zero V
+		return zero, ctx.Err()
+	case <-c.done:
+		return c.val, c.err
+	}
+}
+
+// run calls fn for key and records its result in c, and in the cache
+// unless the key was forgotten meanwhile. The deferred cleanup releases
+// the waiters even if fn panics.
+func (f *Func[K, V]) run(ctx context.Context, key K, c *call[V]) {
+	defer func() {
+		if p := recover(); p != nil {
+			c.err = panicError{p}
+		}
+		f.mu.Lock()
+		// Forget removes c, so a result computed before it is not
+		// stored after it
+		if f.inflight[key] == c {
+			delete(f.inflight, key)
+			if c.err == nil {
+				f.storeLocked(key, c.val)
+			}
+		}
+		f.mu.Unlock()
+		close(c.done)
+	}()
+	c.val, c.err = f.fn(ctx, key)
+}
+
+// panicError reports a call of fn that panicked.
+type panicError struct {
+	value any
+}
+
+func (p panicError) Error() string {
+	return fmt.Sprintf("memo: fn panicked: %v", p.value)
+}
+
+// storeLocked inserts a result and enforces the size limit. Caller holds mu.
+func (f *Func[K, V]) storeLocked(key K, val V) {
+	e := &entry[K, V]{key: key, val: val}
+	if f.ttl > 0 {
+		e.expires = f.now().Add(f.ttl)
+	}
+	f.entries[key] = f.lru.PushFront(e)
+
+	for f.maxSize > 0 && f.lru.Len() > f.maxSize {
+		f.removeLocked(f.lru.Back())
+	}
+}
+
+func (f *Func[K, V]) removeLocked(el *list.Element) {
+	f.lru.Remove(el)
+	delete(f.entries, el.Value.(*entry[K, V]).key)
+}
+
+// Forget drops the cached result for key, if any. A call of fn already
+// running for key still answers the callers waiting on it, but its
+// result is not cached, and the next Get calls fn again.
+func (f *Func[K, V]) Forget(key K) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.inflight, key)
+	if el, ok := f.entries[key]; ok {
+		f.removeLocked(el)
+	}
+}
+
+// Len returns the number of cached results, including expired ones not
+// yet evicted.
+func (f *Func[K, V]) Len() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.lru.Len()
+}
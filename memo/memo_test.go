@@ -0,0 +1,227 @@
+package memo
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestFunc_Caches tests that repeated calls hit the cache
+func TestFunc_Caches(t *testing.T) {
+	var calls atomic.Int32
+	f := New(func(ctx context.Context, k int) (int, error) {
+		calls.Add(1)
+		return k * k, nil
+	})
+
+	for range 3 {
+		if got, _ := f.Get(context.Background(), 4); got != 16 {
+			t.Errorf("Get(4) = %d, want 16", got)
+		}
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("fn called %d times, want 1", n)
+	}
+}
+
+// TestFunc_SingleFlight tests that concurrent misses share one call
+func TestFunc_SingleFlight(t *testing.T) {
+	var calls atomic.Int32
+	f := New(func(ctx context.Context, k string) (string, error) {
+		calls.Add(1)
+		time.Sleep(10 * time.Millisecond)
+		return "schema:" + k, nil
+	})
+
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			f.Get(context.Background(), "orders")
+		}()
+	}
+	wg.Wait()
+
+	if n := calls.Load(); n != 1 {
+		t.Errorf("fn called %d times, want 1", n)
+	}
+}
+
+// TestFunc_ErrorsNotCached tests that a failure is retried on the next call
+func TestFunc_ErrorsNotCached(t *testing.T) {
+	var calls atomic.Int32
+	f := New(func(ctx context.Context, k int) (int, error) {
+		if calls.Add(1) == 1 {
+			return 0, errors.New("boom")
+		}
+		return 1, nil
+	})
+
+	if _, err := f.Get(context.Background(), 1); err == nil {
+		t.Fatal("first Get() should fail")
+	}
+	if got, err := f.Get(context.Background(), 1); err != nil || got != 1 {
+		t.Errorf("second Get() = %d, %v", got, err)
+	}
+}
+
+// TestFunc_TTL tests that entries expire
+func TestFunc_TTL(t *testing.T) {
+	now := time.Now()
+	var calls atomic.Int32
+	f := New(func(ctx context.Context, k int) (int32, error) {
+		return calls.Add(1), nil
+	}, WithTTL(time.Minute))
+	f.now = func() time.Time { return now }
+
+	f.Get(context.Background(), 1)
+	now = now.Add(30 * time.Second)
+	if got, _ := f.Get(context.Background(), 1); got != 1 {
+		t.Errorf("Get() before expiry = %d, want 1", got)
+	}
+	now = now.Add(time.Minute)
+	if got, _ := f.Get(context.Background(), 1); got != 2 {
+		t.Errorf("Get() after expiry = %d, want 2", got)
+	}
+}
+
+// TestFunc_MaxSize tests least-recently-used eviction
+func TestFunc_MaxSize(t *testing.T) {
+	var calls atomic.Int32
+	f := New(func(ctx context.Context, k string) (string, error) {
+		calls.Add(1)
+		return k, nil
+	}, WithMaxSize(2))
+
+	ctx := context.Background()
+	f.Get(ctx, "a")
+	f.Get(ctx, "b")
+	f.Get(ctx, "a") // a is now most recent
+	f.Get(ctx, "c") // evicts b
+
+	if n := f.Len(); n != 2 {
+		t.Errorf("Len() = %d, want 2", n)
+	}
+
+	before := calls.Load()
+	f.Get(ctx, "a")
+	if calls.Load() != before {
+		t.Error("a was evicted, want b evicted")
+	}
+	f.Get(ctx, "b")
+	if calls.Load() != before+1 {
+		t.Error("b was still cached, want it evicted")
+	}
+}
+
+// TestFunc_CancelledCaller tests that the caller that started a call
+// giving up does not fail the others waiting on it
+func TestFunc_CancelledCaller(t *testing.T) {
+	release := make(chan struct{})
+	f := New(func(ctx context.Context, k int) (int, error) {
+		select {
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		case <-release:
+			return k, nil
+		}
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	first := make(chan error)
+	go func() {
+		_, err := f.Get(ctx, 7)
+		first <- err
+	}()
+	for f.inflightLen() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	waiter := make(chan int)
+	go func() {
+		got, _ := f.Get(context.Background(), 7)
+		waiter <- got
+	}()
+	cancel()
+	if err := <-first; !errors.Is(err, context.Canceled) {
+		t.Errorf("Get(cancelled) = %v, want %v", err, context.Canceled)
+	}
+	close(release)
+	if got := <-waiter; got != 7 {
+		t.Errorf("waiter Get() = %d, want 7", got)
+	}
+}
+
+// TestFunc_Panic tests that a panicking fn releases the callers waiting
+// on it with an error, and is called again for the next Get
+func TestFunc_Panic(t *testing.T) {
+	var calls atomic.Int32
+	f := New(func(ctx context.Context, k int) (int, error) {
+		if calls.Add(1) == 1 {
+			time.Sleep(5 * time.Millisecond) // let the waiters queue up
+			panic("registry gone")
+		}
+		return k, nil
+	})
+
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+			if _, err := f.Get(ctx, 1); errors.Is(err, context.DeadlineExceeded) {
+				t.Error("Get() still waiting on a call that panicked")
+			}
+		}()
+	}
+	wg.Wait()
+	if _, err := New(func(context.Context, int) (int, error) { panic("boom") }).Get(context.Background(), 1); err == nil {
+		t.Error("Get() of a fn that panics = nil error, want the panic")
+	}
+	if got, err := f.Get(context.Background(), 1); err != nil || got != 1 {
+		t.Errorf("Get() after the panic = %d, %v, want 1, nil", got, err)
+	}
+}
+
+// TestFunc_ForgetInFlight tests that Forget during a call keeps its
+// result out of the cache
+func TestFunc_ForgetInFlight(t *testing.T) {
+	var calls atomic.Int32
+	release := make(chan struct{})
+	f := New(func(ctx context.Context, k string) (int32, error) {
+		n := calls.Add(1)
+		if n == 1 {
+			<-release
+		}
+		return n, nil
+	})
+
+	done := make(chan int32)
+	go func() {
+		got, _ := f.Get(context.Background(), "orders")
+		done <- got
+	}()
+	for f.inflightLen() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	f.Forget("orders") // e.g. the schema changed
+	close(release)
+	if got := <-done; got != 1 {
+		t.Errorf("Get() during Forget = %d, want 1", got)
+	}
+	if got, _ := f.Get(context.Background(), "orders"); got != 2 {
+		t.Errorf("Get() after Forget = %d, want 2 (not the stale result)", got)
+	}
+}
+
+// inflightLen returns how many calls are running.
+func (f *Func[K, V]) inflightLen() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.inflight)
+}
@@ -0,0 +1,117 @@
+// metrics_pipeline/aggregator.go
+package main
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"github.com/arifmahmudrana/go-snippets/preduce"
+	"github.com/arifmahmudrana/go-snippets/pubsub"
+)
+
+const (
+	samplesTopic = "metrics.samples"
+	rollupsTopic = "metrics.rollups"
+)
+
+// Sample is a single metric observation published by a source.
+type Sample struct {
+	Name  string
+	Value float64
+	Time  time.Time
+}
+
+// Rollup summarises all samples of one metric within a window.
+type Rollup struct {
+	Count         int
+	Sum, Min, Max float64
+}
+
+// Mean returns the average value, or 0 for an empty rollup.
+func (r Rollup) Mean() float64 {
+	if r.Count == 0 {
+		return 0
+	}
+	return r.Sum / float64(r.Count)
+}
+
+// merge combines two rollups of the same metric.
+func (r Rollup) merge(o Rollup) Rollup {
+	if r.Count == 0 {
+		return o
+	}
+	if o.Count == 0 {
+		return r
+	}
+	return Rollup{
+		Count: r.Count + o.Count,
+		Sum:   r.Sum + o.Sum,
+		Min:   math.Min(r.Min, o.Min),
+		Max:   math.Max(r.Max, o.Max),
+	}
+}
+
+// Summary is published on rollupsTopic once per window.
+type Summary struct {
+	Start, End time.Time
+	Rollups    map[string]Rollup
+}
+
+// aggregate reduces samples into per-metric rollups using the parallel
+// reduction library.
+func aggregate(ctx context.Context, samples []Sample, workers int) (map[string]Rollup, error) {
+	return preduce.Reduce(ctx, samples, workers,
+		func() map[string]Rollup { return make(map[string]Rollup) },
+		func(acc map[string]Rollup, s Sample) map[string]Rollup {
+			acc[s.Name] = acc[s.Name].merge(Rollup{Count: 1, Sum: s.Value, Min: s.Value, Max: s.Value})
+			return acc
+		},
+		func(a, b map[string]Rollup) map[string]Rollup {
+			for name, r := range b {
+				a[name] = a[name].merge(r)
+			}
+			return a
+		},
+	)
+}
+
+// runAggregator consumes samplesTopic and publishes a Summary to
+// rollupsTopic at the end of every tumbling window. Windows are based on
+// arrival (processing) time. It returns when ctx is cancelled or the
+// broker is stopped.
+func runAggregator(ctx context.Context, broker *pubsub.Broker, window time.Duration, workers int) {
+	sub := broker.Subscribe(samplesTopic)
+
+	ticker := time.NewTicker(window)
+	defer ticker.Stop()
+
+	start := time.Now()
+	var buf []Sample
+
+	flush := func(end time.Time) {
+		rollups, err := aggregate(ctx, buf, workers)
+		if err != nil {
+			return
+		}
+		broker.Publish(rollupsTopic, Summary{Start: start, End: end, Rollups: rollups})
+		start, buf = end, nil
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			broker.Unsubscribe(samplesTopic, sub)
+			return
+		case msg, ok := <-sub.C:
+			if !ok {
+				return // broker stopped
+			}
+			if s, ok := msg.Payload.(Sample); ok {
+				buf = append(buf, s)
+			}
+		case now := <-ticker.C:
+			flush(now)
+		}
+	}
+}
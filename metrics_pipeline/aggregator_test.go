@@ -0,0 +1,80 @@
+// metrics_pipeline/aggregator_test.go
+package main
+
+import (
+	"context"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/arifmahmudrana/go-snippets/pubsub"
+)
+
+// TestAggregate tests per-metric rollups across worker counts
+func TestAggregate(t *testing.T) {
+	samples := []Sample{
+		{Name: "a", Value: 1}, {Name: "b", Value: 10},
+		{Name: "a", Value: 3}, {Name: "b", Value: 30},
+		{Name: "a", Value: 2},
+	}
+	want := map[string]Rollup{
+		"a": {Count: 3, Sum: 6, Min: 1, Max: 3},
+		"b": {Count: 2, Sum: 40, Min: 10, Max: 30},
+	}
+
+	for _, workers := range []int{1, 2, 5, runtime.NumCPU()} {
+		got, err := aggregate(context.Background(), samples, workers)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for name, w := range want {
+			if got[name] != w {
+				t.Errorf("workers=%d: %s = %+v, want %+v", workers, name, got[name], w)
+			}
+		}
+		if got["a"].Mean() != 2 {
+			t.Errorf("workers=%d: mean(a) = %v, want 2", workers, got["a"].Mean())
+		}
+	}
+}
+
+// TestRunAggregator tests the broker-to-broker flow end to end
+func TestRunAggregator(t *testing.T) {
+	broker := pubsub.NewBroker()
+	defer broker.Stop()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	rollups := broker.Subscribe(rollupsTopic)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		runAggregator(ctx, broker, 50*time.Millisecond, 2)
+	}()
+	defer func() {
+		cancel()
+		<-done
+		broker.Unsubscribe(rollupsTopic, rollups)
+	}()
+
+	// give the aggregator time to subscribe
+	time.Sleep(10 * time.Millisecond)
+	for _, v := range []float64{1, 2, 3} {
+		broker.Publish(samplesTopic, Sample{Name: "x", Value: v})
+	}
+
+	deadline := time.After(time.Second)
+	for {
+		select {
+		case msg := <-rollups.C:
+			s := msg.Payload.(Summary)
+			if r, ok := s.Rollups["x"]; ok {
+				if r.Count != 3 || r.Sum != 6 {
+					t.Errorf("rollup = %+v, want count 3 sum 6", r)
+				}
+				return
+			}
+		case <-deadline:
+			t.Fatal("no rollup received")
+		}
+	}
+}
@@ -0,0 +1,76 @@
+// metrics_pipeline/main.go
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"runtime"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/arifmahmudrana/go-snippets/pubsub"
+)
+
+// produce publishes noisy samples for one metric until ctx is done.
+func produce(ctx context.Context, broker *pubsub.Broker, name string, base float64, every time.Duration) {
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			broker.Publish(samplesTopic, Sample{Name: name, Value: base + rand.NormFloat64()*base/10, Time: now})
+		}
+	}
+}
+
+// printSummary prints rollups sorted by metric name.
+func printSummary(s Summary) {
+	names := make([]string, 0, len(s.Rollups))
+	for name := range s.Rollups {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	fmt.Printf("[ROLLUP] %s - %s\n", s.Start.Format("15:04:05.000"), s.End.Format("15:04:05.000"))
+	for _, name := range names {
+		r := s.Rollups[name]
+		fmt.Printf("  %-12s count=%-4d mean=%8.2f min=%8.2f max=%8.2f\n", name, r.Count, r.Mean(), r.Min, r.Max)
+	}
+}
+
+func main() {
+	broker := pubsub.NewBroker()
+	defer broker.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	rollups := broker.Subscribe(rollupsTopic)
+
+	var wg sync.WaitGroup
+	wg.Add(4)
+	go func() {
+		defer wg.Done()
+		runAggregator(ctx, broker, time.Second, max(runtime.GOMAXPROCS(0), 1))
+	}()
+	go func() { defer wg.Done(); produce(ctx, broker, "cpu.percent", 40, 5*time.Millisecond) }()
+	go func() { defer wg.Done(); produce(ctx, broker, "mem.mb", 512, 10*time.Millisecond) }()
+	go func() { defer wg.Done(); produce(ctx, broker, "req.latency", 120, 2*time.Millisecond) }()
+
+	for {
+		select {
+		case msg := <-rollups.C:
+			printSummary(msg.Payload.(Summary))
+		case <-ctx.Done():
+			wg.Wait()
+			broker.Unsubscribe(rollupsTopic, rollups)
+			fmt.Println("[MAIN] Application finished.")
+			return
+		}
+	}
+}
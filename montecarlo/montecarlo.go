@@ -0,0 +1,242 @@
+package montecarlo
+
+import (
+	"context"
+	"math"
+	"math/rand/v2"
+	"runtime"
+	"sync"
+)
+
+// Trial runs one simulation and returns its outcome. It must draw all of
+// its randomness from rng so that runs are reproducible.
+type Trial func(rng *rand.Rand) float64
+
+// Result summarises a run.
+type Result struct {
+	Trials    int
+	Mean      float64
+	StdDev    float64
+	Low, High float64 // confidence interval for the mean
+	Converged bool    // stopped early because the interval was tight enough
+}
+
+// HalfWidth returns half the width of the confidence interval.
+func (r Result) HalfWidth() float64 {
+	return (r.High - r.Low) / 2
+}
+
+type config struct {
+	workers   int
+	seed      uint64
+	batchSize int
+	maxTrials int
+	z         float64
+	tolerance float64
+}
+
+// Option configures Run.
+type Option func(*config)
+
+// WithWorkers sets how many goroutines run trials. The default is
+// GOMAXPROCS.
+func WithWorkers(n int) Option {
+	return func(c *config) {
+		c.workers = max(n, 1)
+	}
+}
+
+// WithSeed sets the master seed. Runs with the same seed and batch size
+// produce the same Result regardless of the number of workers.
+func WithSeed(seed uint64) Option {
+	return func(c *config) {
+		c.seed = seed
+	}
+}
+
+// WithBatchSize sets how many trials share one PRNG stream and how often
+// the stopping rule is checked. The default is 1000.
+func WithBatchSize(n int) Option {
+	return func(c *config) {
+		c.batchSize = max(n, 1)
+	}
+}
+
+// WithMaxTrials caps the total number of trials. The default is 1e6.
+func WithMaxTrials(n int) Option {
+	return func(c *config) {
+		c.maxTrials = max(n, 1)
+	}
+}
+
+// WithConfidence sets the confidence level of the interval, e.g. 0.95
+// (the default) or 0.99.
+func WithConfidence(level float64) Option {
+	return func(c *config) {
+		c.z = math.Sqrt2 * math.Erfinv(level)
+	}
+}
+
+// WithTolerance stops the run once the interval's half-width is at most
+// tol. At least two batches are always run. By default all MaxTrials run.
+func WithTolerance(tol float64) Option {
+	return func(c *config) {
+		c.tolerance = tol
+	}
+}
+
+// NewStream returns the PRNG for stream id under a master seed. Streams
+// with different ids are independent, which lets any number of workers
+// draw numbers without sharing a generator.
+func NewStream(seed, id uint64) *rand.Rand {
+	return rand.New(rand.NewPCG(seed, splitmix64(id)))
+}
+
+// splitmix64 scrambles consecutive ids into well-spread seeds.
+func splitmix64(x uint64) uint64 {
+	x += 0x9e3779b97f4a7c15
+	x = (x ^ (x >> 30)) * 0xbf58476d1ce4e5b9
+	x = (x ^ (x >> 27)) * 0x94d049bb133111eb
+	return x ^ (x >> 31)
+}
+
+// stats are running moments in Welford form.
+type stats struct {
+	n    int
+	mean float64
+	m2   float64
+}
+
+func (s *stats) add(x float64) {
+	s.n++
+	d := x - s.mean
+	s.mean += d / float64(s.n)
+	s.m2 += d * (x - s.mean)
+}
+
+// merge combines two sets of moments (Chan et al.).
+func (s stats) merge(o stats) stats {
+	if s.n == 0 {
+		return o
+	}
+	if o.n == 0 {
+		return s
+	}
+	n := s.n + o.n
+	d := o.mean - s.mean
+	return stats{
+		n:    n,
+		mean: s.mean + d*float64(o.n)/float64(n),
+		m2:   s.m2 + o.m2 + d*d*float64(s.n)*float64(o.n)/float64(n),
+	}
+}
+
+func (s stats) result(z float64) Result {
+	r := Result{Trials: s.n, Mean: s.mean, Low: s.mean, High: s.mean}
+	if s.n > 1 {
+		r.StdDev = math.Sqrt(s.m2 / float64(s.n-1))
+		hw := z * r.StdDev / math.Sqrt(float64(s.n))
+		r.Low, r.High = s.mean-hw, s.mean+hw
+	}
+	return r
+}
+
+type batch struct {
+	index int
+	stats stats
+}
+
+// Run executes trial up to MaxTrials times across workers and returns the
+// mean with its confidence interval.
+//
+// Trials are grouped into batches, and each batch draws from its own
+// stream (see NewStream). Batches are merged in index order and the
+// stopping rule is checked after each one. As a result, the outcome does
+// not depend on how batches were scheduled.
+//
+// If ctx is cancelled first, Run returns ctx.Err().
+func Run(ctx context.Context, trial Trial, opts ...Option) (Result, error) {
+	cfg := config{
+		workers:   runtime.GOMAXPROCS(0),
+		seed:      1,
+		batchSize: 1000,
+		maxTrials: 1_000_000,
+		z:         math.Sqrt2 * math.Erfinv(0.95),
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	batches := (cfg.maxTrials + cfg.batchSize - 1) / cfg.batchSize
+
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	jobs := make(chan int)
+	go func() {
+		defer close(jobs)
+		for b := range batches {
+			select {
+			case jobs <- b:
+			case <-runCtx.Done():
+				return
+			}
+		}
+	}()
+
+	results := make(chan batch, cfg.workers)
+	var wg sync.WaitGroup
+	for range cfg.workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for b := range jobs {
+				rng := NewStream(cfg.seed, uint64(b))
+				n := min(cfg.batchSize, cfg.maxTrials-b*cfg.batchSize)
+				var s stats
+				for range n {
+					s.add(trial(rng))
+				}
+				select {
+				case results <- batch{index: b, stats: s}:
+				case <-runCtx.Done():
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	// merge in index order; out-of-order batches wait in pending
+	pending := make(map[int]stats)
+	var total stats
+	next := 0
+	converged := false
+	for b := range results {
+		if converged {
+			continue // draining
+		}
+		pending[b.index] = b.stats
+		for s, ok := pending[next]; ok; s, ok = pending[next] {
+			delete(pending, next)
+			total = total.merge(s)
+			next++
+			if cfg.tolerance > 0 && next >= 2 && total.result(cfg.z).HalfWidth() <= cfg.tolerance {
+				converged = true
+				cancel()
+				break
+			}
+		}
+	}
+
+	if !converged {
+		if err := ctx.Err(); err != nil {
+			return Result{}, err
+		}
+	}
+	r := total.result(cfg.z)
+	r.Converged = converged
+	return r, nil
+}
@@ -0,0 +1,111 @@
+package montecarlo
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand/v2"
+	"testing"
+)
+
+// piTrial returns 4 when a random point lands inside the unit quarter
+// circle, so its mean estimates π.
+func piTrial(rng *rand.Rand) float64 {
+	x, y := rng.Float64(), rng.Float64()
+	if x*x+y*y <= 1 {
+		return 4
+	}
+	return 0
+}
+
+// TestRun_Pi tests that the interval covers the true value
+func TestRun_Pi(t *testing.T) {
+	r, err := Run(context.Background(), piTrial, WithMaxTrials(200_000), WithConfidence(0.999))
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if r.Trials != 200_000 {
+		t.Errorf("Trials = %d, want %d", r.Trials, 200_000)
+	}
+	if math.Pi < r.Low || math.Pi > r.High {
+		t.Errorf("interval [%v, %v] does not contain π", r.Low, r.High)
+	}
+	if r.Converged {
+		t.Error("Converged = true without a tolerance")
+	}
+}
+
+// TestRun_Deterministic tests that the worker count does not change the result
+func TestRun_Deterministic(t *testing.T) {
+	var results []Result
+	for _, workers := range []int{1, 3, 8} {
+		r, err := Run(context.Background(), piTrial,
+			WithWorkers(workers), WithSeed(42), WithBatchSize(100),
+			WithMaxTrials(50_000), WithTolerance(0.02))
+		if err != nil {
+			t.Fatalf("Run(workers=%d) error = %v", workers, err)
+		}
+		results = append(results, r)
+	}
+	for i, r := range results[1:] {
+		if r != results[0] {
+			t.Errorf("result %d = %+v, want %+v", i+1, r, results[0])
+		}
+	}
+}
+
+// TestRun_EarlyStop tests that a loose tolerance stops before MaxTrials
+func TestRun_EarlyStop(t *testing.T) {
+	r, err := Run(context.Background(), piTrial, WithMaxTrials(1_000_000), WithTolerance(0.05))
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if !r.Converged {
+		t.Error("Converged = false, want true")
+	}
+	if r.Trials >= 1_000_000 {
+		t.Errorf("Trials = %d, want fewer than max", r.Trials)
+	}
+	if hw := r.HalfWidth(); hw > 0.05 {
+		t.Errorf("HalfWidth() = %v, want <= 0.05", hw)
+	}
+}
+
+// TestRun_Cancelled tests that cancellation is reported
+func TestRun_Cancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := Run(ctx, piTrial)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Run() error = %v, want %v", err, context.Canceled)
+	}
+}
+
+// TestStats_Merge tests that merged moments equal moments of the whole
+func TestStats_Merge(t *testing.T) {
+	xs := []float64{1, 2, 3, 4, 5, 6, 7, 10}
+	var whole, a, b stats
+	for i, x := range xs {
+		whole.add(x)
+		if i < 3 {
+			a.add(x)
+		} else {
+			b.add(x)
+		}
+	}
+	got := a.merge(b)
+	if got.n != whole.n || math.Abs(got.mean-whole.mean) > 1e-12 || math.Abs(got.m2-whole.m2) > 1e-9 {
+		t.Errorf("merge() = %+v, want %+v", got, whole)
+	}
+}
+
+// TestNewStream tests that streams are reproducible and distinct
+func TestNewStream(t *testing.T) {
+	if NewStream(1, 0).Uint64() != NewStream(1, 0).Uint64() {
+		t.Error("same seed and id gave different streams")
+	}
+	if NewStream(1, 0).Uint64() == NewStream(1, 1).Uint64() {
+		t.Error("different ids gave the same first value")
+	}
+}
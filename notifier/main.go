@@ -0,0 +1,65 @@
+// notifier/main.go
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/arifmahmudrana/go-snippets/inbox"
+	"github.com/arifmahmudrana/go-snippets/pubsub"
+	"github.com/arifmahmudrana/go-snippets/ratelimit"
+)
+
+func main() {
+	rate := flag.Float64("rate", 1.0/60, "notifications per second per recipient, once the burst is used")
+	burst := flag.Int("burst", 2, "notifications a recipient may get at once")
+	flag.Parse()
+
+	broker := pubsub.NewBroker()
+	alerts := broker.Subscribe(AlertsTopic, pubsub.WithBuffer(100))
+
+	dispatcher := NewDispatcher(
+		[]Provider{Email(os.Stdout), SMS(os.Stdout)},
+		map[Severity][]string{
+			Info:     {"email"},
+			Warning:  {"email"},
+			Critical: {"sms", "email"},
+		},
+	)
+	handler := Chain(dispatcher.Handle,
+		Validate(),
+		Dedup(inbox.New(inbox.NewMemory(), inbox.WithID(AlertID))),
+		RateLimit(ratelimit.NewKeyed(*rate, *burst, time.Hour)),
+		Timeout(5*time.Second),
+	)
+
+	done := make(chan Stats)
+	go func() {
+		done <- Consume(context.Background(), alerts.C, handler, func(m pubsub.Message, err error) {
+			fmt.Fprintf(os.Stderr, "[ERROR] %s #%d: %v\n", m.Topic, m.Seq, err)
+		})
+	}()
+
+	// a noisy monitor
+	ana := Recipient{Name: "ana", Email: "ana@example.com", Phone: "+15550100"}
+	bo := Recipient{Name: "bo", Email: "bo@example.com"}
+	for _, a := range []Alert{
+		{ID: "disk-1", Severity: Warning, To: ana, Subject: "disk 80% full on db1"},
+		{ID: "disk-1", Severity: Warning, To: ana, Subject: "disk 80% full on db1"}, // re-sent
+		{ID: "cpu-1", Severity: Info, To: bo, Subject: "cpu spike on web3"},
+		{ID: "disk-2", Severity: Warning, To: ana, Subject: "disk 85% full on db1"},
+		{ID: "disk-3", Severity: Warning, To: ana, Subject: "disk 90% full on db1"}, // ana has had enough
+		{ID: "disk-4", Severity: Critical, To: ana, Subject: "disk full on db1"},    // but not of this
+		{ID: "mem-1", Severity: Warning, To: Recipient{Name: "nobody"}, Subject: "no address"},
+	} {
+		broker.Publish(AlertsTopic, a)
+	}
+	broker.Stop()
+
+	st := <-done
+	fmt.Printf("\nhandled %d, duplicates %d, rate limited %d, invalid %d, failed %d\n",
+		st.Handled, st.Duplicates, st.RateLimited, st.Invalid, st.Failed)
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/arifmahmudrana/go-snippets/inbox"
+	"github.com/arifmahmudrana/go-snippets/pubsub"
+	"github.com/arifmahmudrana/go-snippets/ratelimit"
+)
+
+// AlertsTopic is where monitors publish alerts.
+const AlertsTopic = "alerts"
+
+// Severity orders alerts; it decides which channels are used.
+type Severity string
+
+const (
+	Info     Severity = "info"
+	Warning  Severity = "warning"
+	Critical Severity = "critical"
+)
+
+// Recipient is who an alert is for and how to reach them.
+type Recipient struct {
+	Name  string `json:"name"`
+	Email string `json:"email,omitempty"`
+	Phone string `json:"phone,omitempty"`
+}
+
+// Alert is the payload published on AlertsTopic. Monitors that re-send
+// an alert, e.g. after a restart, keep its ID, so it is notified once.
+type Alert struct {
+	ID       string    `json:"id"`
+	Severity Severity  `json:"severity"`
+	To       Recipient `json:"to"`
+	Subject  string    `json:"subject"`
+	Body     string    `json:"body,omitempty"`
+}
+
+// alertOf returns the Alert in m, which a local monitor publishes as is
+// and a remote one as JSON.
+func alertOf(m pubsub.Message) (Alert, error) {
+	switch p := m.Payload.(type) {
+	case Alert:
+		return p, nil
+	case json.RawMessage:
+		var a Alert
+		err := json.Unmarshal(p, &a)
+		return a, err
+	}
+	return Alert{}, fmt.Errorf("unexpected payload %T", m.Payload)
+}
+
+// Handler consumes one message.
+type Handler func(ctx context.Context, m pubsub.Message) error
+
+// Middleware wraps a Handler with one concern, so the consumer is built
+// as a stack: Chain(h, a, b) runs a, then b, then h.
+type Middleware func(Handler) Handler
+
+// Chain applies mws to h, the first listed outermost.
+func Chain(h Handler, mws ...Middleware) Handler {
+	for i := len(mws) - 1; i >= 0; i-- {
+		h = mws[i](h)
+	}
+	return h
+}
+
+// Errors the stack skips a message with. They are expected, so Consume
+// counts them instead of reporting them.
+var (
+	ErrRateLimited = errors.New("notifier: recipient rate limited")
+	ErrInvalid     = errors.New("notifier: invalid alert")
+)
+
+// Validate rejects messages that are not a well-formed Alert.
+func Validate() Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, m pubsub.Message) error {
+			a, err := alertOf(m)
+			if err != nil {
+				return fmt.Errorf("%w: %v", ErrInvalid, err)
+			}
+			if a.ID == "" || a.To.Name == "" || (a.To.Email == "" && a.To.Phone == "") {
+				return fmt.Errorf("%w: %s needs an ID and a recipient with an address", ErrInvalid, a.Subject)
+			}
+			return next(ctx, m)
+		}
+	}
+}
+
+// Dedup runs the rest of the stack once per alert ID; a failed send is
+// not recorded, so a redelivery tries again.
+func Dedup(in *inbox.Inbox) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, m pubsub.Message) error {
+			return in.Handle(ctx, m, func(ctx context.Context, m pubsub.Message) error {
+				return next(ctx, m)
+			})
+		}
+	}
+}
+
+// AlertID identifies a message by its alert's ID, for Dedup's inbox.
+func AlertID(m pubsub.Message) string {
+	a, _ := alertOf(m)
+	return a.ID
+}
+
+// RateLimit lets each recipient be notified at most as often as l
+// allows; critical alerts are always let through, and count against the
+// limit like the others.
+func RateLimit(l *ratelimit.Keyed) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, m pubsub.Message) error {
+			a, _ := alertOf(m)
+			if !l.Allow(a.To.Name) && a.Severity != Critical {
+				return fmt.Errorf("%w: %s", ErrRateLimited, a.To.Name)
+			}
+			return next(ctx, m)
+		}
+	}
+}
+
+// Timeout bounds the time the rest of the stack may take per message.
+func Timeout(d time.Duration) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, m pubsub.Message) error {
+			ctx, cancel := context.WithTimeout(ctx, d)
+			defer cancel()
+			return next(ctx, m)
+		}
+	}
+}
+
+// Notification is what a provider sends.
+type Notification struct {
+	To      string // an email address or phone number
+	Subject string
+	Body    string
+}
+
+// Provider delivers notifications over one channel.
+type Provider interface {
+	// Name is the channel, e.g. "email" or "sms".
+	Name() string
+	// Address returns where to send to r, or "" if r cannot be
+	// reached this way.
+	Address(r Recipient) string
+	Send(ctx context.Context, n Notification) error
+}
+
+// Dispatcher is the bottom of the stack: it sends each alert through
+// the providers its severity calls for.
+type Dispatcher struct {
+	providers map[string]Provider
+	routes    map[Severity][]string // provider names per severity
+	sent      atomic.Uint64
+}
+
+// NewDispatcher routes alerts by severity to the named providers.
+func NewDispatcher(providers []Provider, routes map[Severity][]string) *Dispatcher {
+	d := &Dispatcher{providers: make(map[string]Provider), routes: routes}
+	for _, p := range providers {
+		d.providers[p.Name()] = p
+	}
+	return d
+}
+
+// Handle sends m's alert through every provider its severity is routed
+// to and that can reach the recipient.
+func (d *Dispatcher) Handle(ctx context.Context, m pubsub.Message) error {
+	a, err := alertOf(m)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, name := range d.routes[a.Severity] {
+		p, ok := d.providers[name]
+		if !ok {
+			continue
+		}
+		addr := p.Address(a.To)
+		if addr == "" {
+			continue
+		}
+		n := Notification{To: addr, Subject: fmt.Sprintf("[%s] %s", strings.ToUpper(string(a.Severity)), a.Subject), Body: a.Body}
+		if err := p.Send(ctx, n); err != nil {
+			errs = append(errs, fmt.Errorf("%s to %s: %w", name, addr, err))
+			continue
+		}
+		d.sent.Add(1)
+	}
+	return errors.Join(errs...)
+}
+
+// Stats counts what Consume did with the messages it read.
+type Stats struct {
+	Handled     uint64
+	Duplicates  uint64
+	RateLimited uint64
+	Invalid     uint64
+	Failed      uint64
+}
+
+// Consume runs h for every message from c until it is closed or ctx is
+// done, one at a time, reporting failures other than skipped messages
+// to onErr.
+func Consume(ctx context.Context, c <-chan pubsub.Message, h Handler, onErr func(pubsub.Message, error)) Stats {
+	var st Stats
+	for {
+		select {
+		case <-ctx.Done():
+			return st
+		case m, ok := <-c:
+			if !ok {
+				return st
+			}
+			switch err := h(ctx, m); {
+			case err == nil:
+				st.Handled++
+			case errors.Is(err, inbox.ErrDuplicate):
+				st.Duplicates++
+			case errors.Is(err, ErrRateLimited):
+				st.RateLimited++
+			case errors.Is(err, ErrInvalid):
+				st.Invalid++
+			default:
+				st.Failed++
+				onErr(m, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/arifmahmudrana/go-snippets/inbox"
+	"github.com/arifmahmudrana/go-snippets/pubsub"
+	"github.com/arifmahmudrana/go-snippets/ratelimit"
+)
+
+// recorder is a Provider remembering what it sent, failing while fail
+// is set.
+type recorder struct {
+	name string
+	mu   sync.Mutex
+	sent []string
+	fail bool
+}
+
+func (r *recorder) Name() string { return r.name }
+
+func (r *recorder) Address(to Recipient) string {
+	if r.name == "sms" {
+		return to.Phone
+	}
+	return to.Email
+}
+
+func (r *recorder) Send(ctx context.Context, n Notification) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.fail {
+		return errors.New("gateway down")
+	}
+	r.sent = append(r.sent, r.name+" "+n.To+" "+n.Subject)
+	return nil
+}
+
+// TestConsume tests the middleware stack end to end: validation,
+// deduplication, per-recipient rate limiting and routing by severity
+func TestConsume(t *testing.T) {
+	email, sms := &recorder{name: "email"}, &recorder{name: "sms"}
+	d := NewDispatcher([]Provider{email, sms}, map[Severity][]string{
+		Warning:  {"email"},
+		Critical: {"sms", "email"},
+	})
+	h := Chain(d.Handle,
+		Validate(),
+		Dedup(inbox.New(inbox.NewMemory(), inbox.WithID(AlertID))),
+		RateLimit(ratelimit.NewKeyed(0.001, 1, time.Hour)),
+	)
+
+	b := pubsub.NewBroker()
+	sub := b.Subscribe(AlertsTopic, pubsub.WithBuffer(10))
+	ana := Recipient{Name: "ana", Email: "ana@example.com", Phone: "555"}
+	b.Publish(AlertsTopic, Alert{ID: "1", Severity: Warning, To: ana, Subject: "a"})
+	b.Publish(AlertsTopic, json.RawMessage(`{"id":"1","severity":"warning","to":{"name":"ana","email":"ana@example.com"},"subject":"a"}`))
+	b.Publish(AlertsTopic, Alert{ID: "2", Severity: Warning, To: ana, Subject: "b"})
+	b.Publish(AlertsTopic, Alert{ID: "3", Severity: Critical, To: ana, Subject: "c"})
+	b.Publish(AlertsTopic, Alert{ID: "4", Severity: Warning, To: Recipient{Name: "bo"}, Subject: "d"})
+	b.Publish(AlertsTopic, "not an alert")
+	b.Stop()
+
+	st := Consume(context.Background(), sub.C, h, func(m pubsub.Message, err error) {
+		t.Errorf("message %d failed: %v", m.Seq, err)
+	})
+	if want := (Stats{Handled: 2, Duplicates: 1, RateLimited: 1, Invalid: 2}); st != want {
+		t.Errorf("Consume() = %+v, want %+v", st, want)
+	}
+	want := []string{"email ana@example.com [WARNING] a", "email ana@example.com [CRITICAL] c"}
+	if !slices.Equal(email.sent, want) {
+		t.Errorf("emails %q, want %q", email.sent, want)
+	}
+	if want := []string{"sms 555 [CRITICAL] c"}; !slices.Equal(sms.sent, want) {
+		t.Errorf("texts %q, want %q", sms.sent, want)
+	}
+}
+
+// TestConsume_Failure tests that a failed send is reported and retried
+// on redelivery instead of being deduplicated away
+func TestConsume_Failure(t *testing.T) {
+	email := &recorder{name: "email", fail: true}
+	d := NewDispatcher([]Provider{email}, map[Severity][]string{Info: {"email"}})
+	h := Chain(d.Handle, Dedup(inbox.New(inbox.NewMemory(), inbox.WithID(AlertID))))
+
+	c := make(chan pubsub.Message, 2)
+	a := pubsub.Message{Topic: AlertsTopic, Payload: Alert{ID: "1", Severity: Info, To: Recipient{Name: "ana", Email: "ana@example.com"}, Subject: "a"}}
+	c <- a
+	close(c)
+	var failed int
+	if st := Consume(context.Background(), c, h, func(pubsub.Message, error) { failed++ }); st.Failed != 1 || failed != 1 {
+		t.Errorf("Consume() = %+v, %d errors reported, want 1 failed", st, failed)
+	}
+
+	email.fail = false
+	c = make(chan pubsub.Message, 1)
+	c <- a
+	close(c)
+	if st := Consume(context.Background(), c, h, nil); st.Handled != 1 || len(email.sent) != 1 {
+		t.Errorf("redelivery: Consume() = %+v, sent %q, want it handled", st, email.sent)
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// Console is a Provider that writes notifications to w instead of
+// sending them, standing in for an email or SMS gateway.
+type Console struct {
+	name    string
+	address func(Recipient) string
+
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// Email writes email notifications to w.
+func Email(w io.Writer) *Console {
+	return &Console{name: "email", address: func(r Recipient) string { return r.Email }, w: w}
+}
+
+// SMS writes text messages to w.
+func SMS(w io.Writer) *Console {
+	return &Console{name: "sms", address: func(r Recipient) string { return r.Phone }, w: w}
+}
+
+func (c *Console) Name() string {
+	return c.name
+}
+
+func (c *Console) Address(r Recipient) string {
+	return c.address(r)
+}
+
+func (c *Console) Send(ctx context.Context, n Notification) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, err := fmt.Fprintf(c.w, "[%-5s] %-18s %s\n", c.name, n.To, n.Subject)
+	return err
+}
@@ -0,0 +1,171 @@
+package ordered
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/arifmahmudrana/go-snippets/workerpool"
+)
+
+// ErrClosed is returned by Submit after the executor has been closed.
+var ErrClosed = errors.New("ordered: executor closed")
+
+// Executor runs tasks on a fixed number of workers such that tasks with
+// the same key run one at a time, in submission order, while tasks with
+// different keys run in parallel. Per-topic delivery and per-entity job
+// processing both need exactly this.
+//
+// A worker runs one task for a key and then puts the key at the back of
+// the ready queue, so a busy key cannot monopolise a worker.
+type Executor[K comparable] struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	slots  chan struct{} // one per pending task: bounds memory, gives backpressure
+	wg     sync.WaitGroup
+
+	mu     sync.Mutex
+	cond   *sync.Cond
+	queues map[K]*queue // keys with pending or running tasks
+	ready  []K          // keys with pending tasks and nothing running
+	closed bool
+}
+
+type queue struct {
+	tasks []workerpool.Task
+}
+
+// Option configures an Executor.
+type Option func(*config)
+
+type config struct {
+	maxPending int
+}
+
+// WithMaxPending bounds how many tasks may be queued across all keys
+// before Submit blocks. The default is 1024.
+func WithMaxPending(n int) Option {
+	return func(c *config) {
+		c.maxPending = max(n, 1)
+	}
+}
+
+// New starts an executor with the given number of workers (at least 1).
+// Cancelling ctx stops it like Stop does.
+func New[K comparable](ctx context.Context, workers int, opts ...Option) *Executor[K] {
+	cfg := config{maxPending: 1024}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	e := &Executor[K]{
+		ctx:    ctx,
+		cancel: cancel,
+		slots:  make(chan struct{}, cfg.maxPending),
+		queues: make(map[K]*queue),
+	}
+	e.cond = sync.NewCond(&e.mu)
+	context.AfterFunc(ctx, func() {
+		e.mu.Lock()
+		e.cond.Broadcast()
+		e.mu.Unlock()
+	})
+
+	workers = max(workers, 1)
+	e.wg.Add(workers)
+	for range workers {
+		go e.worker()
+	}
+	return e
+}
+
+// Submit queues t behind any earlier tasks with the same key. It blocks
+// while the executor is full and fails with ctx.Err() if ctx is done
+// first, or ErrClosed if the executor is closed or stopped.
+func (e *Executor[K]) Submit(ctx context.Context, key K, t workerpool.Task) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-e.ctx.Done():
+		return ErrClosed
+	case e.slots <- struct{}{}:
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.closed || e.ctx.Err() != nil {
+		<-e.slots
+		return ErrClosed
+	}
+
+	q, ok := e.queues[key]
+	if !ok {
+		q = &queue{}
+		e.queues[key] = q
+		e.ready = append(e.ready, key)
+		e.cond.Signal()
+	}
+	q.tasks = append(q.tasks, t)
+	return nil
+}
+
+func (e *Executor[K]) worker() {
+	defer e.wg.Done()
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for {
+		for len(e.ready) == 0 && !e.closed && e.ctx.Err() == nil {
+			e.cond.Wait()
+		}
+		if len(e.ready) == 0 || e.ctx.Err() != nil {
+			return
+		}
+
+		key := e.ready[0]
+		e.ready = e.ready[1:]
+		q := e.queues[key]
+		t := q.tasks[0]
+		q.tasks = q.tasks[1:]
+
+		// the key is in queues but not ready while t runs, so no other
+		// worker can pick it up
+		e.mu.Unlock()
+		t(e.ctx)
+		<-e.slots
+		e.mu.Lock()
+
+		if len(q.tasks) > 0 {
+			e.ready = append(e.ready, key)
+			e.cond.Signal()
+		} else {
+			delete(e.queues, key)
+		}
+	}
+}
+
+// Len returns the number of keys with pending or running tasks.
+func (e *Executor[K]) Len() int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return len(e.queues)
+}
+
+// Close stops accepting tasks and waits for queued ones to finish.
+func (e *Executor[K]) Close() {
+	e.mu.Lock()
+	e.closed = true
+	e.cond.Broadcast()
+	e.mu.Unlock()
+
+	e.wg.Wait()
+	e.cancel()
+}
+
+// Stop cancels running tasks, discards queued ones, and waits for the
+// workers to exit.
+func (e *Executor[K]) Stop() {
+	e.cancel()
+	e.Close()
+}
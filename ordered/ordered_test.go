@@ -0,0 +1,99 @@
+package ordered
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestExecutor_PerKeyOrder tests that each key's tasks run in submission
+// order and never overlap
+func TestExecutor_PerKeyOrder(t *testing.T) {
+	e := New[int](context.Background(), 8)
+
+	var mu sync.Mutex
+	got := map[int][]int{}
+	var running [4]atomic.Int32
+	for i := range 400 {
+		key := i % 4
+		err := e.Submit(context.Background(), key, func(ctx context.Context) {
+			if running[key].Add(1) != 1 {
+				t.Errorf("key %d ran concurrently", key)
+			}
+			time.Sleep(50 * time.Microsecond)
+			mu.Lock()
+			got[key] = append(got[key], i)
+			mu.Unlock()
+			running[key].Add(-1)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	e.Close()
+
+	for key, seq := range got {
+		if !slices.IsSorted(seq) || len(seq) != 100 {
+			t.Errorf("key %d order = %v, want 100 increasing values", key, seq)
+		}
+	}
+	if n := e.Len(); n != 0 {
+		t.Errorf("Len() = %d after Close, want 0", n)
+	}
+}
+
+// TestExecutor_KeysRunInParallel tests that a blocked key does not hold
+// back others
+func TestExecutor_KeysRunInParallel(t *testing.T) {
+	e := New[string](context.Background(), 2)
+	defer e.Stop()
+
+	block := make(chan struct{})
+	e.Submit(context.Background(), "slow", func(ctx context.Context) { <-block })
+	e.Submit(context.Background(), "slow", func(ctx context.Context) {})
+
+	done := make(chan struct{})
+	e.Submit(context.Background(), "fast", func(ctx context.Context) { close(done) })
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("fast key blocked behind slow key")
+	}
+	close(block)
+}
+
+// TestExecutor_Backpressure tests that Submit blocks at MaxPending
+func TestExecutor_Backpressure(t *testing.T) {
+	e := New[int](context.Background(), 1, WithMaxPending(2))
+	defer e.Stop()
+
+	block := func(ctx context.Context) { <-ctx.Done() }
+	e.Submit(context.Background(), 1, block)
+	e.Submit(context.Background(), 1, block)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := e.Submit(ctx, 2, block); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Submit() on full executor = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+// TestExecutor_Closed tests that Submit fails after Close and Stop
+func TestExecutor_Closed(t *testing.T) {
+	e := New[int](context.Background(), 1)
+	e.Close()
+	if err := e.Submit(context.Background(), 1, func(context.Context) {}); !errors.Is(err, ErrClosed) {
+		t.Errorf("Submit() after Close = %v, want %v", err, ErrClosed)
+	}
+
+	e = New[int](context.Background(), 1)
+	e.Stop()
+	if err := e.Submit(context.Background(), 1, func(context.Context) {}); !errors.Is(err, ErrClosed) {
+		t.Errorf("Submit() after Stop = %v, want %v", err, ErrClosed)
+	}
+}
@@ -0,0 +1,191 @@
+package outbox
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/arifmahmudrana/go-snippets/pubsub"
+)
+
+// Event is a message waiting in the outbox. It is published to Topic
+// as is, so consumers can deduplicate by ID (see the inbox package).
+type Event struct {
+	ID      string
+	Topic   string
+	Payload []byte
+	Created time.Time
+}
+
+// Store holds the events an application wrote alongside its own
+// changes, in the same transaction, until they are published.
+type Store interface {
+	// Pending returns up to n unpublished events, oldest first.
+	Pending(ctx context.Context, n int) ([]Event, error)
+	// MarkPublished records that the events with the given IDs were
+	// published, so Pending stops returning them.
+	MarkPublished(ctx context.Context, ids []string) error
+}
+
+// Relay moves events from a Store to a broker. Each event is published
+// at least once; it is published twice only if the relay crashes
+// between publishing it and marking it, so consumers that deduplicate
+// by ID see it once.
+type Relay struct {
+	broker   *pubsub.Broker
+	store    Store
+	interval time.Duration
+	batch    int
+	wake     chan struct{}
+
+	mu       sync.Mutex
+	unmarked map[string]bool // published, MarkPublished not yet succeeded
+
+	published atomic.Uint64
+	errors    atomic.Uint64
+}
+
+// Option configures a Relay.
+type Option func(*Relay)
+
+// WithInterval sets how often the relay polls the store (default 1s).
+func WithInterval(d time.Duration) Option {
+	return func(r *Relay) {
+		r.interval = d
+	}
+}
+
+// WithBatch sets how many events the relay reads per poll (default 100).
+func WithBatch(n int) Option {
+	return func(r *Relay) {
+		r.batch = max(n, 1)
+	}
+}
+
+// New returns a relay from s to b. Call Run to start it.
+func New(b *pubsub.Broker, s Store, opts ...Option) *Relay {
+	r := &Relay{
+		broker:   b,
+		store:    s,
+		interval: time.Second,
+		batch:    100,
+		wake:     make(chan struct{}, 1),
+		unmarked: make(map[string]bool),
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// Notify makes the relay poll now rather than at the next interval.
+// Applications call it after committing a transaction that added
+// events, to publish them without waiting.
+func (r *Relay) Notify() {
+	select {
+	case r.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run polls the store until ctx is done, then returns ctx.Err(). Store
+// and broker errors are counted in Errors and retried at the next poll.
+func (r *Relay) Run(ctx context.Context) error {
+	t := time.NewTicker(r.interval)
+	defer t.Stop()
+	for {
+		for r.poll(ctx) {
+			// a full batch: there may be more
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.C:
+		case <-r.wake:
+		}
+	}
+}
+
+// poll publishes and marks one batch, reporting whether it was full
+// and went through.
+func (r *Relay) poll(ctx context.Context) bool {
+	events, err := r.store.Pending(ctx, r.batch)
+	if err != nil {
+		r.errors.Add(1)
+		return false
+	}
+	ids := make([]string, 0, len(events))
+	for _, e := range events {
+		r.mu.Lock()
+		dup := r.unmarked[e.ID]
+		r.mu.Unlock()
+		if !dup {
+			if err := r.broker.PublishTimeout(e.Topic, e, r.interval); err != nil {
+				r.errors.Add(1)
+				break // keep the order: the rest wait for the next poll
+			}
+			r.published.Add(1)
+			r.mu.Lock()
+			r.unmarked[e.ID] = true
+			r.mu.Unlock()
+		}
+		ids = append(ids, e.ID)
+	}
+	if len(ids) == 0 {
+		return false
+	}
+	if err := r.store.MarkPublished(ctx, ids); err != nil {
+		r.errors.Add(1)
+		return false
+	}
+	r.mu.Lock()
+	for _, id := range ids {
+		delete(r.unmarked, id)
+	}
+	r.mu.Unlock()
+	return len(ids) == r.batch
+}
+
+// Published returns how many events the relay has published.
+func (r *Relay) Published() uint64 {
+	return r.published.Load()
+}
+
+// Errors returns how many store reads, publishes and store updates have
+// failed.
+func (r *Relay) Errors() uint64 {
+	return r.errors.Load()
+}
+
+// MemoryStore is an in-memory Store, for tests and demos.
+type MemoryStore struct {
+	mu     sync.Mutex
+	events []Event
+}
+
+// NewMemoryStore returns an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{}
+}
+
+// Add appends e to the outbox.
+func (m *MemoryStore) Add(e Event) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.events = append(m.events, e)
+}
+
+func (m *MemoryStore) Pending(ctx context.Context, n int) ([]Event, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return slices.Clone(m.events[:min(n, len(m.events))]), nil
+}
+
+func (m *MemoryStore) MarkPublished(ctx context.Context, ids []string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.events = slices.DeleteFunc(m.events, func(e Event) bool { return slices.Contains(ids, e.ID) })
+	return nil
+}
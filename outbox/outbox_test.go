@@ -0,0 +1,116 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/arifmahmudrana/go-snippets/pubsub"
+)
+
+// flakyStore fails the first few MarkPublished calls.
+type flakyStore struct {
+	*MemoryStore
+	failures atomic.Int32
+}
+
+func (s *flakyStore) MarkPublished(ctx context.Context, ids []string) error {
+	if s.failures.Add(-1) >= 0 {
+		return errors.New("db down")
+	}
+	return s.MemoryStore.MarkPublished(ctx, ids)
+}
+
+// receive reads n event IDs from sub.
+func receive(t *testing.T, sub *pubsub.Subscriber, n int) []string {
+	t.Helper()
+	var ids []string
+	for range n {
+		select {
+		case m := <-sub.C:
+			ids = append(ids, m.Payload.(Event).ID)
+		case <-time.After(3 * time.Second):
+			t.Fatalf("got %v, want %d events", ids, n)
+		}
+	}
+	return ids
+}
+
+// TestRelay tests publishing in order, exactly once, even when marking
+// fails
+func TestRelay(t *testing.T) {
+	tests := []struct {
+		name       string
+		failures   int32
+		wantErrors uint64
+	}{
+		{"ok", 0, 0},
+		{"mark fails", 2, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := pubsub.NewBroker()
+			defer b.Stop()
+			sub := b.Subscribe("orders")
+
+			s := &flakyStore{MemoryStore: NewMemoryStore()}
+			s.failures.Store(tt.failures)
+			for i := 1; i <= 5; i++ {
+				s.Add(Event{ID: fmt.Sprint(i), Topic: "orders"})
+			}
+			r := New(b, s, WithBatch(2), WithInterval(5*time.Millisecond))
+			ctx, cancel := context.WithCancel(context.Background())
+			done := make(chan error)
+			go func() { done <- r.Run(ctx) }()
+
+			if got := receive(t, sub, 5); fmt.Sprint(got) != "[1 2 3 4 5]" {
+				t.Errorf("published %v, want [1 2 3 4 5]", got)
+			}
+			deadline := time.Now().Add(3 * time.Second)
+			for {
+				if pending, _ := s.Pending(ctx, 10); len(pending) == 0 {
+					break
+				}
+				if time.Now().After(deadline) {
+					t.Fatal("events never marked published")
+				}
+				time.Sleep(time.Millisecond)
+			}
+			cancel()
+			if err := <-done; !errors.Is(err, context.Canceled) {
+				t.Errorf("Run() = %v, want %v", err, context.Canceled)
+			}
+
+			select {
+			case m := <-sub.C:
+				t.Errorf("published %v again", m.Payload.(Event).ID)
+			default:
+			}
+			if r.Published() != 5 || r.Errors() != tt.wantErrors {
+				t.Errorf("Published() = %d, Errors() = %d, want 5 and %d", r.Published(), r.Errors(), tt.wantErrors)
+			}
+		})
+	}
+}
+
+// TestRelay_Notify tests that Notify publishes without waiting for the
+// next poll
+func TestRelay_Notify(t *testing.T) {
+	b := pubsub.NewBroker()
+	defer b.Stop()
+	sub := b.Subscribe("orders")
+
+	s := NewMemoryStore()
+	r := New(b, s, WithInterval(time.Hour))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go r.Run(ctx)
+
+	time.Sleep(10 * time.Millisecond) // let the first poll find nothing
+	s.Add(Event{ID: "1", Topic: "orders"})
+	r.Notify()
+	receive(t, sub, 1)
+}
@@ -0,0 +1,110 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// SQLStore is a Store in a database table, created with
+//
+//	CREATE TABLE outbox (
+//		id        TEXT PRIMARY KEY,
+//		topic     TEXT NOT NULL,
+//		payload   BLOB NOT NULL,
+//		created   TIMESTAMP NOT NULL,
+//		published TIMESTAMP NULL
+//	);
+//	CREATE INDEX outbox_pending ON outbox (published, created);
+//
+// (BYTEA instead of BLOB on PostgreSQL). Published rows are kept, with
+// their publish time, until the application deletes them.
+type SQLStore struct {
+	db          *sql.DB
+	table       string
+	placeholder func(n int) string
+}
+
+// SQLOption configures an SQLStore.
+type SQLOption func(*SQLStore)
+
+// WithTable sets the table name (default "outbox").
+func WithTable(name string) SQLOption {
+	return func(s *SQLStore) {
+		s.table = name
+	}
+}
+
+// WithPlaceholder sets how the n-th query parameter (from 1) is written:
+// "?" by default, as MySQL and SQLite want. PostgreSQL wants
+//
+//	func(n int) string { return fmt.Sprintf("$%d", n) }
+func WithPlaceholder(fn func(n int) string) SQLOption {
+	return func(s *SQLStore) {
+		s.placeholder = fn
+	}
+}
+
+// NewSQLStore returns a store using db.
+func NewSQLStore(db *sql.DB, opts ...SQLOption) *SQLStore {
+	s := &SQLStore{
+		db:          db,
+		table:       "outbox",
+		placeholder: func(int) string { return "?" },
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Add inserts e into the outbox as part of tx, the transaction making
+// the change e announces, so that either both happen or neither does.
+// A zero Created is set to now.
+func (s *SQLStore) Add(ctx context.Context, tx *sql.Tx, e Event) error {
+	if e.Created.IsZero() {
+		e.Created = time.Now()
+	}
+	q := fmt.Sprintf("INSERT INTO %s (id, topic, payload, created) VALUES (%s, %s, %s, %s)",
+		s.table, s.placeholder(1), s.placeholder(2), s.placeholder(3), s.placeholder(4))
+	_, err := tx.ExecContext(ctx, q, e.ID, e.Topic, e.Payload, e.Created)
+	return err
+}
+
+func (s *SQLStore) Pending(ctx context.Context, n int) ([]Event, error) {
+	q := fmt.Sprintf("SELECT id, topic, payload, created FROM %s WHERE published IS NULL ORDER BY created, id LIMIT %d",
+		s.table, n)
+	rows, err := s.db.QueryContext(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []Event
+	for rows.Next() {
+		var e Event
+		if err := rows.Scan(&e.ID, &e.Topic, &e.Payload, &e.Created); err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
+
+func (s *SQLStore) MarkPublished(ctx context.Context, ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	args := []any{time.Now()}
+	marks := make([]string, len(ids))
+	for i, id := range ids {
+		marks[i] = s.placeholder(i + 2)
+		args = append(args, id)
+	}
+	q := fmt.Sprintf("UPDATE %s SET published = %s WHERE id IN (%s)",
+		s.table, s.placeholder(1), strings.Join(marks, ", "))
+	_, err := s.db.ExecContext(ctx, q, args...)
+	return err
+}
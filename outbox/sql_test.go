@@ -0,0 +1,295 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/arifmahmudrana/go-snippets/pubsub"
+)
+
+// fakeDB is a database/sql driver that records the statements it is
+// given. It keeps an outbox table just well enough for SQLStore: INSERT
+// adds a row, UPDATE marks the rows whose IDs it is given published,
+// and SELECT returns the rows not yet published. err, if set, fails
+// every statement.
+type fakeDB struct {
+	mu    sync.Mutex
+	execs []fakeExec
+	rows  []Event
+	sent  map[string]bool
+	err   error
+}
+
+// fakeExec is one statement run against a fakeDB.
+type fakeExec struct {
+	query string
+	args  []driver.Value
+}
+
+func newFakeDB(t *testing.T) (*fakeDB, *sql.DB) {
+	f := &fakeDB{sent: make(map[string]bool)}
+	db := sql.OpenDB(f)
+	t.Cleanup(func() { db.Close() })
+	return f, db
+}
+
+// statements returns the statements run so far.
+func (f *fakeDB) statements() []fakeExec {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]fakeExec(nil), f.execs...)
+}
+
+func (f *fakeDB) Connect(context.Context) (driver.Conn, error) { return fakeConn{f}, nil }
+func (f *fakeDB) Driver() driver.Driver                        { return f }
+func (f *fakeDB) Open(string) (driver.Conn, error)             { return fakeConn{f}, nil }
+
+// fakeConn runs statements directly, without preparing them.
+type fakeConn struct{ f *fakeDB }
+
+func (c fakeConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("fake: not supported") }
+func (c fakeConn) Close() error                        { return nil }
+func (c fakeConn) Begin() (driver.Tx, error)           { return fakeTx{}, nil }
+
+func (c fakeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	f := c.f
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.execs = append(f.execs, fakeExec{query, values(args)})
+	if f.err != nil {
+		return nil, f.err
+	}
+	switch {
+	case strings.HasPrefix(query, "INSERT"):
+		f.rows = append(f.rows, Event{
+			ID:      args[0].Value.(string),
+			Topic:   args[1].Value.(string),
+			Payload: args[2].Value.([]byte),
+			Created: args[3].Value.(time.Time),
+		})
+	case strings.HasPrefix(query, "UPDATE"):
+		for _, a := range args[1:] {
+			f.sent[a.Value.(string)] = true
+		}
+	}
+	return driver.RowsAffected(len(args)), nil
+}
+
+func (c fakeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	f := c.f
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.execs = append(f.execs, fakeExec{query, values(args)})
+	if f.err != nil {
+		return nil, f.err
+	}
+	var rows fakeRows
+	for _, e := range f.rows {
+		if !f.sent[e.ID] {
+			rows = append(rows, e)
+		}
+	}
+	return &rows, nil
+}
+
+func values(args []driver.NamedValue) []driver.Value {
+	vs := make([]driver.Value, len(args))
+	for i, a := range args {
+		vs[i] = a.Value
+	}
+	return vs
+}
+
+type fakeTx struct{}
+
+func (fakeTx) Commit() error   { return nil }
+func (fakeTx) Rollback() error { return nil }
+
+// fakeRows returns events as rows of id, topic, payload and created.
+type fakeRows []Event
+
+func (r *fakeRows) Columns() []string { return []string{"id", "topic", "payload", "created"} }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(*r) == 0 {
+		return io.EOF
+	}
+	e := (*r)[0]
+	*r = (*r)[1:]
+	dest[0], dest[1], dest[2], dest[3] = e.ID, e.Topic, e.Payload, e.Created
+	return nil
+}
+
+// TestSQLStore_Queries tests the statements the store runs, with the
+// default placeholders and with numbered ones
+func TestSQLStore_Queries(t *testing.T) {
+	dollar := func(n int) string { return fmt.Sprintf("$%d", n) }
+	tests := []struct {
+		name       string
+		opts       []SQLOption
+		wantInsert string
+		wantSelect string
+		wantUpdate string
+	}{
+		{
+			name:       "default",
+			wantInsert: "INSERT INTO outbox (id, topic, payload, created) VALUES (?, ?, ?, ?)",
+			wantSelect: "SELECT id, topic, payload, created FROM outbox WHERE published IS NULL ORDER BY created, id LIMIT 10",
+			wantUpdate: "UPDATE outbox SET published = ? WHERE id IN (?, ?)",
+		},
+		{
+			name:       "postgres",
+			opts:       []SQLOption{WithTable("events"), WithPlaceholder(dollar)},
+			wantInsert: "INSERT INTO events (id, topic, payload, created) VALUES ($1, $2, $3, $4)",
+			wantSelect: "SELECT id, topic, payload, created FROM events WHERE published IS NULL ORDER BY created, id LIMIT 10",
+			wantUpdate: "UPDATE events SET published = $1 WHERE id IN ($2, $3)",
+		},
+	}
+	for _, tt := range tests {
+		f, db := newFakeDB(t)
+		s := NewSQLStore(db, tt.opts...)
+		ctx := context.Background()
+
+		tx, err := db.Begin()
+		if err != nil {
+			t.Fatal(err)
+		}
+		created := time.Unix(1000, 0)
+		for _, id := range []string{"a", "b"} {
+			if err := s.Add(ctx, tx, Event{ID: id, Topic: "orders", Payload: []byte(id), Created: created}); err != nil {
+				t.Fatalf("%s: Add(%s) error = %v", tt.name, id, err)
+			}
+		}
+		tx.Commit()
+
+		pending, err := s.Pending(ctx, 10)
+		if err != nil {
+			t.Fatalf("%s: Pending() error = %v", tt.name, err)
+		}
+		want := []Event{
+			{ID: "a", Topic: "orders", Payload: []byte("a"), Created: created},
+			{ID: "b", Topic: "orders", Payload: []byte("b"), Created: created},
+		}
+		if !reflect.DeepEqual(pending, want) {
+			t.Errorf("%s: Pending() = %v, want %v", tt.name, pending, want)
+		}
+		if err := s.MarkPublished(ctx, nil); err != nil {
+			t.Errorf("%s: MarkPublished(nil) error = %v", tt.name, err)
+		}
+		if err := s.MarkPublished(ctx, []string{"a", "b"}); err != nil {
+			t.Fatalf("%s: MarkPublished() error = %v", tt.name, err)
+		}
+
+		execs := f.statements()
+		wantQueries := []string{tt.wantInsert, tt.wantInsert, tt.wantSelect, tt.wantUpdate}
+		if len(execs) != len(wantQueries) {
+			t.Fatalf("%s: ran %d statements, want %d: %v", tt.name, len(execs), len(wantQueries), execs)
+		}
+		for i, q := range wantQueries {
+			if execs[i].query != q {
+				t.Errorf("%s: statement %d = %q, want %q", tt.name, i, execs[i].query, q)
+			}
+		}
+		if got := execs[0].args; !reflect.DeepEqual(got, []driver.Value{"a", "orders", []byte("a"), created}) {
+			t.Errorf("%s: INSERT args = %v", tt.name, got)
+		}
+		update := execs[3].args
+		if _, ok := update[0].(time.Time); !ok || !reflect.DeepEqual(update[1:], []driver.Value{"a", "b"}) {
+			t.Errorf("%s: UPDATE args = %v, want a time, then a and b", tt.name, update)
+		}
+	}
+}
+
+// TestSQLStore_AddCreated tests that an event without a creation time is
+// given the current one
+func TestSQLStore_AddCreated(t *testing.T) {
+	f, db := newFakeDB(t)
+	s := NewSQLStore(db)
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := time.Now()
+	if err := s.Add(context.Background(), tx, Event{ID: "a", Topic: "t", Payload: []byte("{}")}); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+	tx.Commit()
+	if got := f.statements()[0].args[3].(time.Time); got.Before(before) {
+		t.Errorf("created = %v, want now", got)
+	}
+}
+
+// TestSQLStore_Errors tests that database errors are returned
+func TestSQLStore_Errors(t *testing.T) {
+	f, db := newFakeDB(t)
+	s := NewSQLStore(db)
+	ctx := context.Background()
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+
+	errDown := errors.New("db down")
+	f.err = errDown
+	if err := s.Add(ctx, tx, Event{ID: "a"}); !errors.Is(err, errDown) {
+		t.Errorf("Add() error = %v, want %v", err, errDown)
+	}
+	if _, err := s.Pending(ctx, 10); !errors.Is(err, errDown) {
+		t.Errorf("Pending() error = %v, want %v", err, errDown)
+	}
+	if err := s.MarkPublished(ctx, []string{"a"}); !errors.Is(err, errDown) {
+		t.Errorf("MarkPublished() error = %v, want %v", err, errDown)
+	}
+}
+
+// TestSQLStore_Relay tests that a relay publishes the rows of an
+// SQLStore and marks them sent
+func TestSQLStore_Relay(t *testing.T) {
+	f, db := newFakeDB(t)
+	s := NewSQLStore(db)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= 3; i++ {
+		s.Add(ctx, tx, Event{ID: fmt.Sprint(i), Topic: "orders", Payload: []byte("{}"), Created: time.Unix(int64(i), 0)})
+	}
+	tx.Commit()
+
+	b := pubsub.NewBroker()
+	defer b.Stop()
+	sub := b.Subscribe("orders")
+	r := New(b, s, WithInterval(5*time.Millisecond))
+	go r.Run(ctx)
+
+	if got := receive(t, sub, 3); fmt.Sprint(got) != "[1 2 3]" {
+		t.Errorf("published %v, want [1 2 3]", got)
+	}
+	for deadline := time.Now().Add(3 * time.Second); ; time.Sleep(time.Millisecond) {
+		if pending, _ := s.Pending(ctx, 10); len(pending) == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("events never marked published")
+		}
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.sent) != 3 {
+		t.Errorf("%d rows marked published, want 3", len(f.sent))
+	}
+}
@@ -3,26 +3,29 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
+	"log"
 	"runtime"
 	"slices"
 	"strings"
 	"time"
+
+	"github.com/arifmahmudrana/go-snippets/profiling"
+	"github.com/arifmahmudrana/go-snippets/scope"
 )
 
 // worker processes words from tasks channel and sends digit counts to results.
-// It respects context cancellation and signals completion via done channel.
-func worker(ctx context.Context, tasks <-chan string, results chan<- map[rune]int, done chan<- struct{}) {
-	defer func() { done <- struct{}{} }() // signal when this worker exits
-
+// It respects context cancellation; its scope knows when it has exited.
+func worker(ctx context.Context, tasks <-chan string, results chan<- map[rune]int) error {
 	for {
 		select {
 		case <-ctx.Done():
-			return
+			return nil
 		case w, ok := <-tasks:
 			if !ok {
 				// tasks closed -> normal exit
-				return
+				return nil
 			}
 			// process word: count digits
 			m := make(map[rune]int)
@@ -34,7 +37,7 @@ func worker(ctx context.Context, tasks <-chan string, results chan<- map[rune]in
 			// non-blocking send: respect ctx cancellation
 			select {
 			case <-ctx.Done():
-				return
+				return nil
 			case results <- m:
 			}
 		}
@@ -69,38 +72,45 @@ func mergeResults(ctx context.Context, results <-chan map[rune]int) map[rune]int
 // - results channel: matches worker count for optimal throughput
 // - words are streamed, not all loaded into channel at once
 func countDigitsParallel(ctx context.Context, words []string, numWorkers int) map[rune]int {
-	// Small buffers: memory-efficient, stream-based processing
-	tasks := make(chan string, numWorkers)         // only buffer what workers can handle
-	results := make(chan map[rune]int, numWorkers) // one slot per worker
-	done := make(chan struct{}, numWorkers)        // buffered, workers never block
-
-	// start workers
-	for range numWorkers {
-		go worker(ctx, tasks, results, done)
-	}
-
-	// producer: stream tasks (non-blocking with context)
-	go func() {
-		defer close(tasks) // signal no more work when done
-		for _, w := range words {
-			select {
-			case <-ctx.Done():
-				return // stop producing if cancelled
-			case tasks <- w:
-				// send task (blocks if buffer full, that's OK - backpressure)
+	var final map[rune]int
+	// the scope guarantees no goroutine outlives this call, even on cancellation
+	scope.Run(ctx, func(s *scope.Scope) error {
+		// Small buffers: memory-efficient, stream-based processing
+		tasks := make(chan string, numWorkers)         // only buffer what workers can handle
+		results := make(chan map[rune]int, numWorkers) // one slot per worker
+
+		// producer: stream tasks (non-blocking with context)
+		s.Go(func(ctx context.Context) error {
+			defer close(tasks) // signal no more work when done
+			for _, w := range words {
+				select {
+				case <-ctx.Done():
+					return nil // stop producing if cancelled
+				case tasks <- w:
+					// send task (blocks if buffer full, that's OK - backpressure)
+				}
 			}
-		}
-	}()
-
-	// coordinator: wait for all workers, then close results
-	go func() {
-		for range numWorkers {
-			<-done
-		}
-		close(results) // signal merger that no more results coming
-	}()
-
-	return mergeResults(ctx, results)
+			return nil
+		})
+
+		// workers in a nested scope: once it returns they have all exited,
+		// so results can be closed
+		s.Go(func(ctx context.Context) error {
+			defer close(results) // signal merger that no more results coming
+			return scope.Run(ctx, func(ws *scope.Scope) error {
+				for range numWorkers {
+					ws.Go(func(ctx context.Context) error {
+						return worker(ctx, tasks, results)
+					})
+				}
+				return nil
+			})
+		})
+
+		final = mergeResults(s.Context(), results)
+		return nil
+	})
+	return final
 }
 
 // printSortedCounts prints digit counts in sorted order (0-9) for consistent output.
@@ -120,6 +130,18 @@ func printSortedCounts(counts map[rune]int) {
 }
 
 func main() {
+	prof := profiling.Register(nil)
+	flag.Parse()
+	stop, err := prof.Start()
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer func() {
+		if err := stop(); err != nil {
+			log.Print(err)
+		}
+	}()
+
 	text := "1I12 1l0v3 Y!!07 something 123 45 67 890"
 	words := strings.Fields(text)
 
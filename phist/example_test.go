@@ -0,0 +1,18 @@
+package phist_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/arifmahmudrana/go-snippets/phist"
+)
+
+// ExampleBuild counts words by length, the kind of tally the text tools
+// in this repo compute.
+func ExampleBuild() {
+	words := strings.Fields("the quick brown fox jumps over the lazy dog")
+	h, _ := phist.Build(context.Background(), words, 4, func(w string) int { return len(w) })
+	fmt.Println(h[3], h[4], h[5])
+	// Output: 4 2 3
+}
@@ -0,0 +1,60 @@
+package phist
+
+import (
+	"context"
+	"math"
+
+	"github.com/arifmahmudrana/go-snippets/preduce"
+)
+
+// Build counts how many items map to each key. Every worker builds a
+// private histogram for its chunk and the partial histograms are merged
+// at the end, so workers never contend on a shared map.
+//
+// If ctx is cancelled before counting finishes, Build returns ctx.Err().
+func Build[T any, K comparable](ctx context.Context, items []T, workers int, key func(T) K) (map[K]int, error) {
+	return preduce.Reduce(ctx, items, workers,
+		func() map[K]int { return make(map[K]int) },
+		func(h map[K]int, item T) map[K]int {
+			h[key(item)]++
+			return h
+		},
+		merge[K],
+	)
+}
+
+// merge adds the counts in b to a and returns a. The larger map is kept
+// as the destination to keep the copying small.
+func merge[K comparable](a, b map[K]int) map[K]int {
+	if len(b) > len(a) {
+		a, b = b, a
+	}
+	for k, n := range b {
+		a[k] += n
+	}
+	return a
+}
+
+// Bins counts xs into n equal-width bins covering [lo, hi). Values below
+// lo land in the first bin and values at or above hi in the last.
+func Bins(ctx context.Context, xs []float64, workers, n int, lo, hi float64) ([]int, error) {
+	n = max(n, 1)
+	width := (hi - lo) / float64(n)
+	return preduce.Reduce(ctx, xs, workers,
+		func() []int { return make([]int, n) },
+		func(h []int, x float64) []int {
+			i := 0
+			if width > 0 {
+				i = int(math.Floor((x - lo) / width))
+			}
+			h[min(max(i, 0), n-1)]++
+			return h
+		},
+		func(a, b []int) []int {
+			for i := range b {
+				a[i] += b[i]
+			}
+			return a
+		},
+	)
+}
@@ -0,0 +1,53 @@
+package phist
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"slices"
+	"testing"
+)
+
+// TestBuild tests that counts match a sequential count for any worker count
+func TestBuild(t *testing.T) {
+	items := make([]int, 10_000)
+	want := map[int]int{}
+	for i := range items {
+		items[i] = (i * 31) % 17
+		want[items[i]%5]++
+	}
+
+	for _, workers := range []int{1, 2, 7, 100} {
+		got, err := Build(context.Background(), items, workers, func(x int) int { return x % 5 })
+		if err != nil {
+			t.Fatalf("Build() error = %v", err)
+		}
+		if !maps.Equal(got, want) {
+			t.Errorf("Build(workers=%d) = %v, want %v", workers, got, want)
+		}
+	}
+}
+
+// TestBuild_Cancelled tests that a cancelled context is reported
+func TestBuild_Cancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := Build(ctx, make([]string, 5000), 4, func(s string) string { return s })
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Build() error = %v, want %v", err, context.Canceled)
+	}
+}
+
+// TestBins tests bin placement including out-of-range values
+func TestBins(t *testing.T) {
+	xs := []float64{-1, 0, 0.5, 2.5, 9.99, 10, 42}
+	got, err := Bins(context.Background(), xs, 3, 5, 0, 10)
+	if err != nil {
+		t.Fatalf("Bins() error = %v", err)
+	}
+	want := []int{3, 1, 0, 0, 3}
+	if !slices.Equal(got, want) {
+		t.Errorf("Bins() = %v, want %v", got, want)
+	}
+}
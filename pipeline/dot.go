@@ -0,0 +1,44 @@
+package pipeline
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// ExportDOT writes the stage graph in Graphviz DOT format, e.g. for
+// `dot -Tsvg`. Each stage shows its workers and how full its queue is
+// right now; the bottleneck, if known, is drawn in red.
+func (p *Pipeline) ExportDOT(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "digraph pipeline {")
+	fmt.Fprintln(bw, "\trankdir=LR;")
+	fmt.Fprintln(bw, "\tnode [shape=box];")
+	fmt.Fprintln(bw, "\tin [shape=circle];")
+	fmt.Fprintln(bw, "\tout [shape=doublecircle];")
+
+	bottleneck := p.Bottleneck()
+	for _, s := range p.stages {
+		st := s.Stats(0)
+		label := fmt.Sprintf("%s\n%d workers\nqueue %d/%d", s.name, st.Workers, st.Queued, s.buffer)
+		attrs := "label=" + strconv.Quote(label)
+		if s.name == bottleneck {
+			attrs += ", color=red"
+		}
+		fmt.Fprintf(bw, "\t%s [%s];\n", strconv.Quote(s.name), attrs)
+	}
+	for i, s := range p.stages {
+		if i == 0 {
+			fmt.Fprintf(bw, "\tin -> %s;\n", strconv.Quote(s.name))
+		}
+		for _, down := range s.to {
+			fmt.Fprintf(bw, "\t%s -> %s;\n", strconv.Quote(s.name), strconv.Quote(down.name))
+		}
+		if len(s.to) == 0 {
+			fmt.Fprintf(bw, "\t%s -> out;\n", strconv.Quote(s.name))
+		}
+	}
+	fmt.Fprintln(bw, "}")
+	return bw.Flush()
+}
@@ -0,0 +1,60 @@
+package pipeline
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+// TestPipeline_ExportDOT tests the graph of a fan-out/fan-in pipeline,
+// including live queue depths
+func TestPipeline_ExportDOT(t *testing.T) {
+	gate := make(chan struct{})
+	p := New()
+	fetch := p.Add("fetch", add(0), Buffer(4))
+	a := p.Add("resize", add(0), From(fetch), Workers(3))
+	b := p.Add("exif", add(0), From(fetch))
+	p.Add("store", func(ctx context.Context, v any) (any, error) {
+		<-gate
+		return v, nil
+	}, From(a, b), Buffer(8))
+
+	out, _ := p.Run(context.Background(), source(3))
+	// store holds one item and has the other five queued
+	eventually(t, "store's queue to fill", func() bool { return p.Stats()[3].Queued == 5 })
+
+	var sb strings.Builder
+	if err := p.ExportDOT(&sb); err != nil {
+		t.Fatal(err)
+	}
+	want := `digraph pipeline {
+	rankdir=LR;
+	node [shape=box];
+	in [shape=circle];
+	out [shape=doublecircle];
+	"fetch" [label="fetch\n1 workers\nqueue 0/4"];
+	"resize" [label="resize\n3 workers\nqueue 0/16"];
+	"exif" [label="exif\n1 workers\nqueue 0/16"];
+	"store" [label="store\n1 workers\nqueue 5/8"];
+	in -> "fetch";
+	"fetch" -> "resize";
+	"fetch" -> "exif";
+	"resize" -> "store";
+	"exif" -> "store";
+	"store" -> out;
+}
+`
+	// which instant stage looks slowest so far is down to noise
+	if got := strings.Replace(sb.String(), ", color=red", "", 1); got != want {
+		t.Errorf("ExportDOT() =\n%s\nwant\n%s", got, want)
+	}
+
+	// once store has processed items, it is clearly the bottleneck
+	close(gate)
+	collect(out)
+	sb.Reset()
+	p.ExportDOT(&sb)
+	if !strings.Contains(sb.String(), `"store" [label="store\n1 workers\nqueue 0/8", color=red]`) {
+		t.Errorf("bottleneck store not highlighted:\n%s", sb.String())
+	}
+}
@@ -0,0 +1,354 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/pprof"
+	"runtime/trace"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrStarted is returned by Run when the pipeline is already running.
+var ErrStarted = errors.New("pipeline: already started")
+
+// Func processes one item. Returning an error drops the item; it is
+// counted in the stage's Errors.
+type Func func(ctx context.Context, v any) (any, error)
+
+// Pipeline is a graph of stages, each running its Func on a number of
+// workers and feeding every item it produces to each of its downstream
+// stages. Stages are connected through buffered queues, so a slow stage
+// fills its queue and then holds up the ones before it.
+type Pipeline struct {
+	stages  []*Stage
+	started atomic.Bool
+	start   time.Time
+	out     chan any
+	sinks   atomic.Int32 // sink stages still running
+
+	traced bool        // see WithTraceRegions
+	task   *trace.Task // the run's trace task, if a trace was running
+}
+
+// envelope is an item waiting in a stage's queue.
+type envelope struct {
+	v        any
+	enqueued time.Time
+}
+
+// Stage is one step of a pipeline.
+type Stage struct {
+	p       *Pipeline
+	name    string
+	fn      Func
+	workers int
+	buffer  int
+	from    []*Stage
+	to      []*Stage
+
+	in       chan envelope
+	emitting string       // the name of the trace region of emit
+	upstream atomic.Int32 // upstream stages still running
+	ctx      context.Context
+	auto     *autoscale
+
+	mu      sync.Mutex
+	running int           // live workers
+	spawned int           // workers ever started, to number them
+	wake    chan struct{} // closed to make idle workers check whether to retire
+	done    bool          // every worker has exited for good
+
+	stats struct {
+		in, out, errors atomic.Uint64
+		wait, busy      atomic.Int64 // nanoseconds, summed over items
+	}
+}
+
+// StageOption configures a stage.
+type StageOption func(*Stage)
+
+// Workers sets how many items the stage processes at once (default 1).
+// SetWorkers and AutoScale change it while the pipeline runs.
+func Workers(n int) StageOption {
+	return func(s *Stage) {
+		s.workers = max(n, 1)
+	}
+}
+
+// Buffer sets the size of the stage's input queue (default 16).
+func Buffer(n int) StageOption {
+	return func(s *Stage) {
+		s.buffer = max(n, 0)
+	}
+}
+
+// From makes the stage read the output of the given stages instead of
+// the previously added one. Several stages reading from one fan its
+// items out; one stage reading from several fans them in.
+func From(stages ...*Stage) StageOption {
+	return func(s *Stage) {
+		s.from = stages
+	}
+}
+
+// Option configures a Pipeline.
+type Option func(*Pipeline)
+
+// WithTraceRegions marks the pipeline's work in execution traces, for
+// `go tool trace`. A run is a trace task named "pipeline", within the
+// task of Run's context if any, and each stage's work on an item a
+// region named after the stage, in which its Func can nest regions of
+// its own. Time a worker spends waiting for room downstream is a region
+// named after the stage with ".emit" appended. Nothing is recorded
+// unless a trace is running (see runtime/trace.Start).
+func WithTraceRegions() Option {
+	return func(p *Pipeline) {
+		p.traced = true
+	}
+}
+
+// New returns an empty pipeline.
+func New(opts ...Option) *Pipeline {
+	p := &Pipeline{}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+// Add adds a stage running fn. Unless From says otherwise it reads from
+// the previously added stage; the first stage reads the pipeline's
+// input. Stages must all be added before Run.
+func (p *Pipeline) Add(name string, fn Func, opts ...StageOption) *Stage {
+	s := &Stage{p: p, name: name, fn: fn, workers: 1, buffer: 16}
+	if n := len(p.stages); n > 0 {
+		s.from = []*Stage{p.stages[n-1]}
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	for _, up := range s.from {
+		up.to = append(up.to, s)
+	}
+	p.stages = append(p.stages, s)
+	return s
+}
+
+// Name returns the stage's name.
+func (s *Stage) Name() string {
+	return s.name
+}
+
+// Run starts the pipeline on the items from in and returns the channel
+// the final stages (those nothing reads from) send their items on. It is
+// closed once in is closed and every item has been through, or soon
+// after ctx is done. A pipeline runs once.
+func (p *Pipeline) Run(ctx context.Context, in <-chan any) (<-chan any, error) {
+	if len(p.stages) == 0 {
+		return nil, errors.New("pipeline: no stages")
+	}
+	for _, s := range p.stages[1:] {
+		if len(s.from) == 0 {
+			return nil, fmt.Errorf("pipeline: stage %q has no input", s.name)
+		}
+	}
+	if !p.started.CompareAndSwap(false, true) {
+		return nil, ErrStarted
+	}
+	p.start = time.Now()
+	p.out = make(chan any)
+	if p.traced && trace.IsEnabled() {
+		ctx, p.task = trace.NewTask(ctx, "pipeline")
+	}
+
+	for _, s := range p.stages {
+		s.in = make(chan envelope, s.buffer)
+		s.emitting = s.name + ".emit"
+		s.upstream.Store(int32(len(s.from)))
+		if len(s.to) == 0 {
+			p.sinks.Add(1)
+		}
+	}
+	for _, s := range p.stages {
+		s.mu.Lock()
+		s.ctx = ctx
+		s.wake = make(chan struct{})
+		for range s.workers {
+			s.spawn()
+		}
+		s.mu.Unlock()
+		if s.auto != nil {
+			go pprof.Do(ctx, pprof.Labels("component", "pipeline.autoscale", "stage", s.name), func(context.Context) {
+				s.autoscale()
+			})
+		}
+	}
+
+	first := p.stages[0]
+	go func() {
+		defer close(first.in)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case v, ok := <-in:
+				if !ok {
+					return
+				}
+				if !first.enqueue(ctx, v) {
+					return
+				}
+			}
+		}
+	}()
+	return p.out, nil
+}
+
+// enqueue puts v on the stage's queue, reporting false if ctx ended
+// first.
+func (s *Stage) enqueue(ctx context.Context, v any) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case s.in <- envelope{v: v, enqueued: time.Now()}:
+		return true
+	}
+}
+
+// spawn starts a worker, labeled with the stage and a worker number
+// for goroutine profiles. Caller holds mu.
+func (s *Stage) spawn() {
+	s.running++
+	s.spawned++
+	labels := pprof.Labels("component", "pipeline", "stage", s.name, "worker", strconv.Itoa(s.spawned))
+	go pprof.Do(s.ctx, labels, s.work)
+}
+
+// SetWorkers changes how many workers the stage runs. Extra workers
+// start at once; surplus ones retire as soon as they finish the item
+// they are on, so nothing in flight is lost. n is at least 1.
+func (s *Stage) SetWorkers(n int) {
+	n = max(n, 1)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.workers = n
+	if s.ctx == nil || s.done {
+		return // not running
+	}
+	for s.running < n {
+		s.spawn()
+	}
+	if s.running > n {
+		close(s.wake)
+		s.wake = make(chan struct{})
+	}
+}
+
+// retire reports whether the calling worker is surplus, and if so
+// counts it out. It never retires the last worker.
+func (s *Stage) retire() (bool, <-chan struct{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.running > s.workers {
+		s.running--
+		return true, nil
+	}
+	return false, s.wake
+}
+
+// work processes items until the queue is closed and drained, or the
+// worker is no longer needed.
+func (s *Stage) work(ctx context.Context) {
+	for {
+		retire, wake := s.retire()
+		if retire {
+			return
+		}
+		var e envelope
+		var ok bool
+		select {
+		case <-wake:
+			continue
+		case e, ok = <-s.in:
+		}
+		if !ok || ctx.Err() != nil {
+			s.exit()
+			return
+		}
+		if !s.process(ctx, e) {
+			s.exit()
+			return
+		}
+	}
+}
+
+// process runs the stage's Func on one item and passes the result on,
+// reporting false if ctx ended first.
+func (s *Stage) process(ctx context.Context, e envelope) bool {
+	start := time.Now()
+	s.stats.in.Add(1)
+	s.stats.wait.Add(int64(start.Sub(e.enqueued)))
+	var v any
+	var err error
+	if s.p.traced && trace.IsEnabled() {
+		trace.WithRegion(ctx, s.name, func() { v, err = s.fn(ctx, e.v) })
+	} else {
+		v, err = s.fn(ctx, e.v)
+	}
+	s.stats.busy.Add(int64(time.Since(start)))
+	if err != nil {
+		s.stats.errors.Add(1)
+		return true
+	}
+	s.stats.out.Add(1)
+	if s.p.traced && trace.IsEnabled() {
+		defer trace.StartRegion(ctx, s.emitting).End()
+	}
+	return s.emit(ctx, v)
+}
+
+// emit passes v downstream, reporting false if ctx ended first.
+func (s *Stage) emit(ctx context.Context, v any) bool {
+	if len(s.to) == 0 {
+		select {
+		case <-ctx.Done():
+			return false
+		case s.p.out <- v:
+			return true
+		}
+	}
+	for _, down := range s.to {
+		if !down.enqueue(ctx, v) {
+			return false
+		}
+	}
+	return true
+}
+
+// exit retires a worker. The last one out tells the downstream stages
+// that this one is done.
+func (s *Stage) exit() {
+	s.mu.Lock()
+	s.running--
+	last := s.running == 0
+	s.done = s.done || last
+	s.mu.Unlock()
+	if !last {
+		return
+	}
+	if len(s.to) == 0 && s.p.sinks.Add(-1) == 0 {
+		if s.p.task != nil {
+			s.p.task.End()
+		}
+		close(s.p.out)
+	}
+	for _, down := range s.to {
+		if down.upstream.Add(-1) == 0 {
+			close(down.in)
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"runtime/trace"
+	"slices"
+	"testing"
+	"time"
+)
+
+// source returns a closed channel holding 1..n.
+func source(n int) <-chan any {
+	in := make(chan any, n)
+	for i := 1; i <= n; i++ {
+		in <- i
+	}
+	close(in)
+	return in
+}
+
+// collect returns the sorted ints received from out.
+func collect(out <-chan any) []int {
+	var got []int
+	for v := range out {
+		got = append(got, v.(int))
+	}
+	slices.Sort(got)
+	return got
+}
+
+func add(n int) Func {
+	return func(ctx context.Context, v any) (any, error) {
+		return v.(int) + n, nil
+	}
+}
+
+// TestPipeline_Topologies tests linear, fan-out and fan-in graphs
+func TestPipeline_Topologies(t *testing.T) {
+	tests := []struct {
+		name  string
+		build func(p *Pipeline)
+		want  []int
+	}{
+		{"linear", func(p *Pipeline) {
+			p.Add("add10", add(10), Workers(3))
+			p.Add("add100", add(100))
+		}, []int{111, 112, 113}},
+		{"fan-out", func(p *Pipeline) {
+			src := p.Add("src", add(0))
+			p.Add("add10", add(10), From(src))
+			p.Add("add20", add(20), From(src))
+		}, []int{11, 12, 13, 21, 22, 23}},
+		{"fan-in", func(p *Pipeline) {
+			src := p.Add("src", add(0))
+			a := p.Add("add10", add(10), From(src))
+			b := p.Add("add20", add(20), From(src))
+			p.Add("add100", add(100), From(a, b), Workers(2))
+		}, []int{111, 112, 113, 121, 122, 123}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := New()
+			tt.build(p)
+			out, err := p.Run(context.Background(), source(3))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := collect(out); fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("output = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestPipeline_Errors tests that failed items are dropped and counted
+func TestPipeline_Errors(t *testing.T) {
+	p := New()
+	p.Add("odd", func(ctx context.Context, v any) (any, error) {
+		if v.(int)%2 == 0 {
+			return nil, errors.New("even")
+		}
+		return v, nil
+	})
+	out, _ := p.Run(context.Background(), source(5))
+	if got := collect(out); fmt.Sprint(got) != "[1 3 5]" {
+		t.Errorf("output = %v, want [1 3 5]", got)
+	}
+	if st := p.Stats()[0]; st.In != 5 || st.Out != 3 || st.Errors != 2 {
+		t.Errorf("stats = %+v, want 5 in, 3 out, 2 errors", st)
+	}
+}
+
+// TestPipeline_Cancel tests that cancelling ctx stops the pipeline
+func TestPipeline_Cancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	p := New()
+	p.Add("block", func(ctx context.Context, v any) (any, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}, Workers(2))
+	out, _ := p.Run(ctx, make(chan any)) // never closed
+	cancel()
+
+	select {
+	case _, ok := <-out:
+		if ok {
+			t.Error("received an item after cancel")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("output not closed after cancel")
+	}
+}
+
+// TestPipeline_Run tests Run's checks
+func TestPipeline_Run(t *testing.T) {
+	if _, err := New().Run(context.Background(), source(0)); err == nil {
+		t.Error("Run() of an empty pipeline succeeded")
+	}
+
+	p := New()
+	p.Add("a", add(1))
+	p.Add("orphan", add(1), From())
+	if _, err := p.Run(context.Background(), source(0)); err == nil {
+		t.Error("Run() with a stage without input succeeded")
+	}
+
+	p = New()
+	p.Add("a", add(1))
+	out, _ := p.Run(context.Background(), source(0))
+	collect(out)
+	if _, err := p.Run(context.Background(), source(0)); !errors.Is(err, ErrStarted) {
+		t.Errorf("second Run() = %v, want %v", err, ErrStarted)
+	}
+}
+
+// TestPipeline_WithTraceRegions tests that a run is traced as a task
+// with a region per stage, only when asked for. The task's name is in
+// the trace anyway, as part of the package path, so it is not checked
+func TestPipeline_WithTraceRegions(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []Option
+		want bool
+	}{
+		{"on", []Option{WithTraceRegions()}, true},
+		{"off", nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := trace.Start(&buf); err != nil {
+				t.Skip("a trace is already running:", err)
+			}
+			p := New(tt.opts...)
+			p.Add("decode", add(1))
+			p.Add("resize", add(10), Workers(2))
+			out, err := p.Run(context.Background(), source(3))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, want := collect(out), []int{12, 13, 14}; !slices.Equal(got, want) {
+				t.Errorf("Run() gave %v, want %v", got, want)
+			}
+			trace.Stop()
+
+			for _, s := range []string{"decode", "resize", "decode.emit"} {
+				if found := bytes.Contains(buf.Bytes(), []byte(s)); found != tt.want {
+					t.Errorf("trace contains %q = %v, want %v", s, found, tt.want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package pipeline
+
+import "time"
+
+type autoscale struct {
+	min, max int
+	every    time.Duration
+}
+
+// AutoScale lets the stage pick its own worker count between min and
+// max while the pipeline runs. Every interval it looks at its queue:
+// more than half full adds a worker, empty twice in a row removes one.
+// It needs a buffered queue (see Buffer) and starts from Workers,
+// clamped to [min, max].
+func AutoScale(min, max int, every time.Duration) StageOption {
+	return func(s *Stage) {
+		s.auto = &autoscale{min: min, max: max, every: every}
+	}
+}
+
+// autoscale adjusts the worker count until the stage is done.
+func (s *Stage) autoscale() {
+	a := s.auto
+	s.SetWorkers(min(max(s.Workers(), a.min), a.max))
+
+	t := time.NewTicker(a.every)
+	defer t.Stop()
+	idle := 0
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-t.C:
+		}
+		s.mu.Lock()
+		done, n := s.done, s.workers
+		s.mu.Unlock()
+		if done {
+			return
+		}
+
+		switch depth := len(s.in); {
+		case depth > cap(s.in)/2 && n < a.max:
+			s.SetWorkers(n + 1)
+			idle = 0
+		case depth == 0:
+			if idle++; idle >= 2 && n > a.min {
+				s.SetWorkers(n - 1)
+				idle = 0
+			}
+		default:
+			idle = 0
+		}
+	}
+}
+
+// Workers returns how many workers the stage is set to run.
+func (s *Stage) Workers() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.workers
+}
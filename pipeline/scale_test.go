@@ -0,0 +1,121 @@
+package pipeline
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// gauge tracks how many calls are running at once.
+type gauge struct {
+	now, peak atomic.Int32
+}
+
+func (g *gauge) enter() {
+	n := g.now.Add(1)
+	for {
+		p := g.peak.Load()
+		if n <= p || g.peak.CompareAndSwap(p, n) {
+			return
+		}
+	}
+}
+
+func (g *gauge) leave() { g.now.Add(-1) }
+
+// eventually polls cond until it holds or the deadline passes.
+func eventually(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// TestStage_SetWorkers tests scaling a running stage up and down without
+// losing items
+func TestStage_SetWorkers(t *testing.T) {
+	var g gauge
+	gate := make(chan struct{})
+	p := New()
+	s := p.Add("work", func(ctx context.Context, v any) (any, error) {
+		g.enter()
+		defer g.leave()
+		<-gate
+		return v, nil
+	})
+
+	in := make(chan any)
+	out, _ := p.Run(context.Background(), in)
+	go func() {
+		for i := range 100 {
+			in <- i
+		}
+		close(in)
+	}()
+	received := make(chan int)
+	go func() {
+		n := 0
+		for range out {
+			n++
+		}
+		received <- n
+	}()
+
+	eventually(t, "one busy worker", func() bool { return g.now.Load() == 1 })
+	s.SetWorkers(4)
+	eventually(t, "four busy workers", func() bool { return g.now.Load() == 4 })
+
+	s.SetWorkers(1)
+	for range 10 {
+		gate <- struct{}{}
+	}
+	eventually(t, "surplus workers to retire", func() bool { return g.now.Load() == 1 })
+	g.peak.Store(0)
+	close(gate)
+
+	if n := <-received; n != 100 {
+		t.Errorf("received %d items, want 100", n)
+	}
+	if peak := g.peak.Load(); peak > 1 {
+		t.Errorf("%d workers ran at once after scaling down to 1", peak)
+	}
+	if st := p.Stats()[0]; st.In != 100 || st.Workers != 1 {
+		t.Errorf("stats = %+v, want 100 in with 1 worker", st)
+	}
+}
+
+// TestStage_AutoScale tests that a stage with a backlog adds workers up
+// to its maximum and sheds them once idle
+func TestStage_AutoScale(t *testing.T) {
+	var g gauge
+	p := New()
+	s := p.Add("slow", func(ctx context.Context, v any) (any, error) {
+		g.enter()
+		defer g.leave()
+		time.Sleep(5 * time.Millisecond)
+		return v, nil
+	}, Buffer(8), AutoScale(1, 4, 5*time.Millisecond))
+
+	in := make(chan any)
+	out, _ := p.Run(context.Background(), in)
+	go func() {
+		for range out {
+		}
+	}()
+	for i := range 200 {
+		in <- i
+		if g.peak.Load() == 4 {
+			break
+		}
+	}
+	if peak := g.peak.Load(); peak != 4 {
+		t.Errorf("peak workers = %d, want 4", peak)
+	}
+	eventually(t, "idle stage to shrink to 1", func() bool { return s.Workers() == 1 })
+	close(in)
+}
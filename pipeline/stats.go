@@ -0,0 +1,82 @@
+package pipeline
+
+import (
+	"time"
+)
+
+// StageStats describes how a stage has been doing since Run.
+type StageStats struct {
+	Name    string `json:"name"`
+	Workers int    `json:"workers"`
+	Queued  int    `json:"queued"` // items waiting in its queue now
+
+	In     uint64 `json:"in"`     // items taken from the queue
+	Out    uint64 `json:"out"`    // items passed on
+	Errors uint64 `json:"errors"` // items dropped because Func failed
+
+	// Throughput is Out per second since Run.
+	Throughput float64 `json:"throughput"`
+	// Wait is the mean time an item spent queued before a worker took it.
+	Wait time.Duration `json:"wait"`
+	// Busy is the mean time Func took per item.
+	Busy time.Duration `json:"busy"`
+}
+
+// Capacity returns how many items per second the stage could handle if
+// it never had to wait for input: its workers divided by the mean
+// processing time. It is 0 before the stage has processed anything.
+func (s StageStats) Capacity() float64 {
+	if s.Busy <= 0 {
+		return 0
+	}
+	return float64(s.Workers) / s.Busy.Seconds()
+}
+
+// Stats returns every stage's stats, in the order they were added.
+func (p *Pipeline) Stats() []StageStats {
+	var elapsed time.Duration
+	if p.started.Load() {
+		elapsed = time.Since(p.start)
+	}
+	out := make([]StageStats, len(p.stages))
+	for i, s := range p.stages {
+		out[i] = s.Stats(elapsed)
+	}
+	return out
+}
+
+// Stats returns the stage's stats, with throughput taken over elapsed.
+func (s *Stage) Stats(elapsed time.Duration) StageStats {
+	st := StageStats{
+		Name:    s.name,
+		Workers: s.Workers(),
+		Queued:  len(s.in),
+		In:      s.stats.in.Load(),
+		Out:     s.stats.out.Load(),
+		Errors:  s.stats.errors.Load(),
+	}
+	if st.In > 0 {
+		st.Wait = time.Duration(s.stats.wait.Load() / int64(st.In))
+		st.Busy = time.Duration(s.stats.busy.Load() / int64(st.In))
+	}
+	if elapsed > 0 {
+		st.Throughput = float64(st.Out) / elapsed.Seconds()
+	}
+	return st
+}
+
+// Bottleneck names the stage limiting the pipeline's throughput: the
+// one with the lowest capacity, i.e. the most processing time per
+// worker. Queue waits tell the same story from the outside, since items
+// pile up in front of the bottleneck and the stages after it wait for
+// input. It returns "" until some stage has processed an item.
+func (p *Pipeline) Bottleneck() string {
+	name, lowest := "", 0.0
+	for _, st := range p.Stats() {
+		c := st.Capacity()
+		if c > 0 && (name == "" || c < lowest) {
+			name, lowest = st.Name, c
+		}
+	}
+	return name
+}
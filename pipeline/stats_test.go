@@ -0,0 +1,50 @@
+package pipeline
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func sleep(d time.Duration) Func {
+	return func(ctx context.Context, v any) (any, error) {
+		time.Sleep(d)
+		return v, nil
+	}
+}
+
+// TestPipeline_Bottleneck tests that the stage with the least capacity
+// is named, even when another stage is individually slower but has more
+// workers
+func TestPipeline_Bottleneck(t *testing.T) {
+	p := New()
+	if got := p.Bottleneck(); got != "" {
+		t.Errorf("Bottleneck() before Run = %q, want none", got)
+	}
+	p.Add("parse", sleep(0))
+	p.Add("resize", sleep(8*time.Millisecond), Workers(8)) // 1000/s
+	p.Add("upload", sleep(4*time.Millisecond))             // 250/s
+	p.Add("index", sleep(0))
+
+	out, _ := p.Run(context.Background(), source(40))
+	collect(out)
+
+	if got := p.Bottleneck(); got != "upload" {
+		t.Errorf("Bottleneck() = %q, want upload; stats %+v", got, p.Stats())
+	}
+
+	stats := p.Stats()
+	upload, index := stats[2], stats[3]
+	if upload.Busy < 4*time.Millisecond {
+		t.Errorf("upload Busy = %v, want at least 4ms", upload.Busy)
+	}
+	// items queue up in front of the bottleneck, not behind it
+	if upload.Wait <= index.Wait {
+		t.Errorf("upload Wait = %v, index Wait = %v, want more queueing before upload", upload.Wait, index.Wait)
+	}
+	for _, st := range stats {
+		if st.In != 40 || st.Out != 40 || st.Throughput <= 0 {
+			t.Errorf("%s: %+v, want 40 in and out", st.Name, st)
+		}
+	}
+}
@@ -0,0 +1,409 @@
+package pool
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrClosed is returned by Get after Close.
+var ErrClosed = errors.New("pool: closed")
+
+// Stats is a snapshot of an Object pool's counters.
+type Stats struct {
+	InUse, Idle        int
+	Created, Destroyed int64
+	Hits, Misses       int64 // Get served from idle vs. newly created
+	ValidationFailures int64 // idle values that failed, on Get or a health check
+	CreateErrors       int64 // values newFn failed to make
+	WaitCount          int64 // Gets that had to wait for a free slot
+	WaitDuration       time.Duration
+}
+
+// Object is a bounded pool of expensive values such as connections or
+// large scratch buffers. Unlike sync.Pool it never holds more than
+// MaxSize values, checks values before handing them out, retires them
+// after a maximum lifetime or idle time, and keeps counters. With
+// WithMinSize or WithHealthCheck it also keeps its idle values healthy
+// and topped up in the background, until Close.
+type Object[T any] struct {
+	newFn       func(ctx context.Context) (T, error)
+	validate    func(T) error
+	destroy     func(T)
+	maxIdle     int
+	minSize     int
+	checkEvery  time.Duration
+	maxLifetime time.Duration
+	maxIdleTime time.Duration
+	now         func() time.Time
+
+	// background maintenance; nil unless minSize or checkEvery is set
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	slots chan struct{} // one token per checked-out value; nil if unbounded
+
+	mu     sync.Mutex
+	idle   []*Item[T] // LIFO: the most recently used value is the warmest
+	stats  Stats
+	closed bool
+}
+
+// Item is a value checked out of an Object pool. Return it with Release,
+// or with Discard if it turned out to be broken.
+type Item[T any] struct {
+	Value T
+
+	pool     *Object[T]
+	created  time.Time
+	lastUsed time.Time
+	done     bool
+}
+
+// Option configures an Object pool.
+type Option[T any] func(*Object[T])
+
+// WithMaxSize bounds how many values can be checked out at once; Get
+// waits for a Release beyond that. The default is unbounded.
+func WithMaxSize[T any](n int) Option[T] {
+	return func(p *Object[T]) {
+		if n > 0 {
+			p.slots = make(chan struct{}, n)
+		}
+	}
+}
+
+// WithMaxIdle bounds how many released values are kept. The default is 2.
+func WithMaxIdle[T any](n int) Option[T] {
+	return func(p *Object[T]) {
+		p.maxIdle = max(n, 0)
+	}
+}
+
+// WithMaxLifetime retires values older than d.
+func WithMaxLifetime[T any](d time.Duration) Option[T] {
+	return func(p *Object[T]) {
+		p.maxLifetime = d
+	}
+}
+
+// WithMaxIdleTime retires values that sat unused for longer than d.
+func WithMaxIdleTime[T any](d time.Duration) Option[T] {
+	return func(p *Object[T]) {
+		p.maxIdleTime = d
+	}
+}
+
+// WithValidate sets a health check run on idle values before Get returns
+// them. Values that fail are destroyed and another one is tried.
+func WithValidate[T any](fn func(T) error) Option[T] {
+	return func(p *Object[T]) {
+		p.validate = fn
+	}
+}
+
+// WithMinSize keeps at least n values, idle or checked out, creating
+// them in the background so that Gets after a quiet spell find one
+// ready. Values are checked again and the pool topped up every health
+// check interval (see WithHealthCheck), every 30 seconds by default.
+func WithMinSize[T any](n int) Option[T] {
+	return func(p *Object[T]) {
+		p.minSize = max(n, 0)
+	}
+}
+
+// WithHealthCheck runs the WithValidate check on idle values every d in
+// the background, and retires expired ones, so that dead connections
+// are noticed before a Get picks them up.
+func WithHealthCheck[T any](d time.Duration) Option[T] {
+	return func(p *Object[T]) {
+		p.checkEvery = d
+	}
+}
+
+// WithDestroy sets a function called on every value the pool drops, e.g.
+// to close a connection.
+func WithDestroy[T any](fn func(T)) Option[T] {
+	return func(p *Object[T]) {
+		p.destroy = fn
+	}
+}
+
+// New creates a pool that makes values with newFn.
+func New[T any](newFn func(ctx context.Context) (T, error), opts ...Option[T]) *Object[T] {
+	p := &Object[T]{
+		newFn:   newFn,
+		maxIdle: 2,
+		now:     time.Now,
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	if p.minSize > 0 || p.checkEvery > 0 {
+		p.maxIdle = max(p.maxIdle, p.minSize)
+		if p.checkEvery <= 0 {
+			p.checkEvery = 30 * time.Second
+		}
+		var ctx context.Context
+		ctx, p.cancel = context.WithCancel(context.Background())
+		p.done = make(chan struct{})
+		go p.maintain(ctx)
+	}
+	return p
+}
+
+// maintain runs check every health check interval until Close.
+func (p *Object[T]) maintain(ctx context.Context) {
+	defer close(p.done)
+	t := time.NewTicker(p.checkEvery)
+	defer t.Stop()
+	for {
+		p.check(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// check retires expired and unhealthy idle values, then creates values
+// until the pool holds its minimum size. The idle values are taken out
+// while they are checked, so no Get receives one mid-check.
+func (p *Object[T]) check(ctx context.Context) {
+	p.mu.Lock()
+	idle := p.idle
+	p.idle = nil
+	p.mu.Unlock()
+
+	var kept []*Item[T]
+	for _, it := range idle {
+		if p.expired(it) {
+			p.drop(it)
+			continue
+		}
+		if p.validate != nil && p.validate(it.Value) != nil {
+			p.mu.Lock()
+			p.stats.ValidationFailures++
+			p.mu.Unlock()
+			p.drop(it)
+			continue
+		}
+		kept = append(kept, it)
+	}
+
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		for _, it := range kept {
+			p.drop(it)
+		}
+		return
+	}
+	// values released meanwhile are warmer: they stay on top
+	p.idle = append(kept, p.idle...)
+	missing := p.minSize - len(p.idle) - p.stats.InUse
+	p.mu.Unlock()
+
+	for range missing {
+		v, err := p.newFn(ctx)
+		if err != nil {
+			p.mu.Lock()
+			p.stats.CreateErrors++
+			p.mu.Unlock()
+			return // try again next round
+		}
+		now := p.now()
+		it := &Item[T]{Value: v, pool: p, created: now, lastUsed: now, done: true}
+		p.mu.Lock()
+		p.stats.Created++
+		keep := !p.closed
+		if keep {
+			p.idle = append([]*Item[T]{it}, p.idle...)
+		}
+		p.mu.Unlock()
+		if !keep {
+			p.drop(it)
+			return
+		}
+	}
+}
+
+// Get returns an idle value that passes validation, or a new one. If
+// MaxSize values are checked out it waits for one to be returned or for
+// ctx to be done.
+func (p *Object[T]) Get(ctx context.Context) (*Item[T], error) {
+	if err := p.acquire(ctx); err != nil {
+		return nil, err
+	}
+
+	for {
+		p.mu.Lock()
+		if p.closed {
+			p.mu.Unlock()
+			p.releaseSlot()
+			return nil, ErrClosed
+		}
+		var it *Item[T]
+		if n := len(p.idle); n > 0 {
+			it = p.idle[n-1]
+			p.idle = p.idle[:n-1]
+		}
+		p.mu.Unlock()
+
+		if it == nil {
+			break
+		}
+		if p.expired(it) {
+			p.drop(it)
+			continue
+		}
+		if p.validate != nil && p.validate(it.Value) != nil {
+			p.mu.Lock()
+			p.stats.ValidationFailures++
+			p.mu.Unlock()
+			p.drop(it)
+			continue
+		}
+
+		it.done = false
+		p.mu.Lock()
+		p.stats.Hits++
+		p.stats.InUse++
+		p.mu.Unlock()
+		return it, nil
+	}
+
+	v, err := p.newFn(ctx)
+	if err != nil {
+		p.mu.Lock()
+		p.stats.CreateErrors++
+		p.mu.Unlock()
+		p.releaseSlot()
+		return nil, err
+	}
+	now := p.now()
+	p.mu.Lock()
+	p.stats.Misses++
+	p.stats.Created++
+	p.stats.InUse++
+	p.mu.Unlock()
+	return &Item[T]{Value: v, pool: p, created: now, lastUsed: now}, nil
+}
+
+// acquire takes a checkout slot, recording the wait if there is one.
+func (p *Object[T]) acquire(ctx context.Context) error {
+	if p.slots == nil {
+		return ctx.Err()
+	}
+	select {
+	case p.slots <- struct{}{}:
+		return nil
+	default:
+	}
+
+	start := p.now()
+	defer func() {
+		p.mu.Lock()
+		p.stats.WaitCount++
+		p.stats.WaitDuration += p.now().Sub(start)
+		p.mu.Unlock()
+	}()
+	select {
+	case p.slots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (p *Object[T]) releaseSlot() {
+	if p.slots != nil {
+		<-p.slots
+	}
+}
+
+func (p *Object[T]) expired(it *Item[T]) bool {
+	now := p.now()
+	return (p.maxLifetime > 0 && now.Sub(it.created) > p.maxLifetime) ||
+		(p.maxIdleTime > 0 && now.Sub(it.lastUsed) > p.maxIdleTime)
+}
+
+// drop destroys a value that is no longer counted as in use.
+func (p *Object[T]) drop(it *Item[T]) {
+	p.mu.Lock()
+	p.stats.Destroyed++
+	p.mu.Unlock()
+	if p.destroy != nil {
+		p.destroy(it.Value)
+	}
+}
+
+// Release returns the value to the pool. Calling it twice, or after
+// Discard, has no effect.
+func (it *Item[T]) Release() {
+	if it.done {
+		return
+	}
+	it.done = true
+	p := it.pool
+	it.lastUsed = p.now()
+
+	p.mu.Lock()
+	p.stats.InUse--
+	keep := !p.closed && len(p.idle) < p.maxIdle && !p.expired(it)
+	if keep {
+		p.idle = append(p.idle, it)
+	}
+	p.mu.Unlock()
+
+	if !keep {
+		p.drop(it)
+	}
+	p.releaseSlot()
+}
+
+// Discard destroys the value instead of returning it, e.g. after an I/O
+// error on a connection.
+func (it *Item[T]) Discard() {
+	if it.done {
+		return
+	}
+	it.done = true
+	p := it.pool
+
+	p.mu.Lock()
+	p.stats.InUse--
+	p.mu.Unlock()
+	p.drop(it)
+	p.releaseSlot()
+}
+
+// Stats returns a snapshot of the pool's counters.
+func (p *Object[T]) Stats() Stats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	s := p.stats
+	s.Idle = len(p.idle)
+	return s
+}
+
+// Close destroys idle values, stops any background maintenance and makes
+// further Gets fail. Values still checked out are destroyed when
+// released.
+func (p *Object[T]) Close() {
+	if p.cancel != nil {
+		p.cancel()
+		<-p.done
+	}
+	p.mu.Lock()
+	p.closed = true
+	idle := p.idle
+	p.idle = nil
+	p.mu.Unlock()
+
+	for _, it := range idle {
+		p.drop(it)
+	}
+}
@@ -0,0 +1,216 @@
+package pool
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// counter returns a constructor that hands out 1, 2, 3, ...
+func counter() (func(context.Context) (int, error), *atomic.Int32) {
+	var n atomic.Int32
+	return func(context.Context) (int, error) { return int(n.Add(1)), nil }, &n
+}
+
+// TestObject_Reuse tests that released values are handed out again
+func TestObject_Reuse(t *testing.T) {
+	newFn, made := counter()
+	p := New(newFn)
+
+	a, _ := p.Get(context.Background())
+	a.Release()
+	b, _ := p.Get(context.Background())
+	if b.Value != a.Value {
+		t.Errorf("Get() = %d, want reused %d", b.Value, a.Value)
+	}
+	b.Release()
+
+	if got := made.Load(); got != 1 {
+		t.Errorf("created %d values, want 1", got)
+	}
+	s := p.Stats()
+	if s.Hits != 1 || s.Misses != 1 || s.Idle != 1 || s.InUse != 0 {
+		t.Errorf("Stats() = %+v", s)
+	}
+}
+
+// TestObject_MaxSize tests that Get waits for a slot and honours ctx
+func TestObject_MaxSize(t *testing.T) {
+	newFn, _ := counter()
+	p := New(newFn, WithMaxSize[int](1))
+
+	a, _ := p.Get(context.Background())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := p.Get(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Get() on full pool = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		a.Release()
+	}()
+	b, err := p.Get(context.Background())
+	if err != nil || b.Value != a.Value {
+		t.Errorf("Get() = %v, %v, want reused value", b, err)
+	}
+	if s := p.Stats(); s.WaitCount != 2 {
+		t.Errorf("WaitCount = %d, want 2", s.WaitCount)
+	}
+}
+
+// TestObject_Validate tests that failing idle values are replaced
+func TestObject_Validate(t *testing.T) {
+	newFn, _ := counter()
+	var destroyed []int
+	p := New(newFn,
+		WithValidate(func(v int) error {
+			if v == 1 {
+				return errors.New("stale")
+			}
+			return nil
+		}),
+		WithDestroy(func(v int) { destroyed = append(destroyed, v) }),
+	)
+
+	a, _ := p.Get(context.Background())
+	a.Release()
+	b, _ := p.Get(context.Background())
+	if b.Value != 2 {
+		t.Errorf("Get() = %d, want fresh value 2", b.Value)
+	}
+	if len(destroyed) != 1 || destroyed[0] != 1 {
+		t.Errorf("destroyed = %v, want [1]", destroyed)
+	}
+	if s := p.Stats(); s.ValidationFailures != 1 {
+		t.Errorf("ValidationFailures = %d, want 1", s.ValidationFailures)
+	}
+}
+
+// TestObject_Expiry tests max lifetime and max idle time
+func TestObject_Expiry(t *testing.T) {
+	tests := []struct {
+		name string
+		opt  Option[int]
+	}{
+		{"lifetime", WithMaxLifetime[int](time.Minute)},
+		{"idle time", WithMaxIdleTime[int](time.Minute)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			newFn, _ := counter()
+			p := New(newFn, tt.opt)
+			now := time.Unix(0, 0)
+			p.now = func() time.Time { return now }
+
+			a, _ := p.Get(context.Background())
+			a.Release()
+			now = now.Add(2 * time.Minute)
+
+			b, _ := p.Get(context.Background())
+			if b.Value == a.Value {
+				t.Errorf("Get() reused expired value %d", a.Value)
+			}
+		})
+	}
+}
+
+// TestObject_MaxIdleAndClose tests idle trimming, Discard and Close
+func TestObject_MaxIdleAndClose(t *testing.T) {
+	newFn, _ := counter()
+	var destroyed atomic.Int32
+	p := New(newFn, WithMaxIdle[int](1), WithDestroy(func(int) { destroyed.Add(1) }))
+
+	a, _ := p.Get(context.Background())
+	b, _ := p.Get(context.Background())
+	c, _ := p.Get(context.Background())
+	a.Release()
+	b.Release() // over MaxIdle
+	c.Discard()
+	c.Release() // no-op
+
+	if got := destroyed.Load(); got != 2 {
+		t.Errorf("destroyed %d, want 2", got)
+	}
+
+	p.Close()
+	if got := destroyed.Load(); got != 3 {
+		t.Errorf("destroyed %d after Close, want 3", got)
+	}
+	if _, err := p.Get(context.Background()); !errors.Is(err, ErrClosed) {
+		t.Errorf("Get() after Close = %v, want %v", err, ErrClosed)
+	}
+}
+
+// TestObject_MinSize tests that the pool is filled up to its minimum in
+// the background, counting values in use
+func TestObject_MinSize(t *testing.T) {
+	newFn, made := counter()
+	p := New(newFn, WithMinSize[int](3), WithHealthCheck[int](time.Millisecond))
+	defer p.Close()
+
+	waitFor := func(what string, ok func(Stats) bool) {
+		t.Helper()
+		deadline := time.Now().Add(3 * time.Second)
+		for !ok(p.Stats()) {
+			if time.Now().After(deadline) {
+				t.Fatalf("%s: Stats() = %+v", what, p.Stats())
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+	waitFor("filling", func(s Stats) bool { return s.Idle == 3 })
+
+	a, _ := p.Get(context.Background())
+	a.Discard()
+	waitFor("refilling", func(s Stats) bool { return s.Idle == 3 && s.Created == 4 })
+	if got := made.Load(); got != 4 {
+		t.Errorf("created %d values, want 4", got)
+	}
+}
+
+// TestObject_HealthCheck tests that a check retires unhealthy idle
+// values and counts failures to replace them
+func TestObject_HealthCheck(t *testing.T) {
+	var healthy, failing atomic.Bool
+	healthy.Store(true)
+	newFn, _ := counter()
+	p := New(func(ctx context.Context) (int, error) {
+		if failing.Load() {
+			return 0, errors.New("connection refused")
+		}
+		return newFn(ctx)
+	},
+		WithHealthCheck[int](time.Hour),
+		WithValidate(func(int) error {
+			if !healthy.Load() {
+				return errors.New("connection reset")
+			}
+			return nil
+		}),
+	)
+	defer p.Close()
+
+	a, _ := p.Get(context.Background())
+	b, _ := p.Get(context.Background())
+	a.Release()
+	b.Release()
+	healthy.Store(false)
+	p.check(context.Background())
+
+	s := p.Stats()
+	if s.Idle != 0 || s.Destroyed != 2 || s.ValidationFailures != 2 {
+		t.Errorf("Stats() after check = %+v, want 0 idle, 2 destroyed and failed", s)
+	}
+
+	failing.Store(true)
+	if _, err := p.Get(context.Background()); err == nil {
+		t.Error("Get() succeeded, want the constructor's error")
+	}
+	if got := p.Stats().CreateErrors; got != 1 {
+		t.Errorf("CreateErrors = %d, want 1", got)
+	}
+}
@@ -0,0 +1,84 @@
+package preduce
+
+import (
+	"context"
+	"sync"
+)
+
+// Range is a half-open index range [Start, End) of a slice.
+type Range struct {
+	Start, End int
+}
+
+// Ranges splits n items into at most parts contiguous, near-equal ranges.
+// Empty ranges are never returned, so len(result) <= parts.
+func Ranges(n, parts int) []Range {
+	parts = max(min(parts, n), 1)
+	if n == 0 {
+		return nil
+	}
+
+	ranges := make([]Range, 0, parts)
+	size, rem := n/parts, n%parts
+	start := 0
+	for i := range parts {
+		end := start + size
+		if i < rem {
+			end++ // spread the remainder over the first ranges
+		}
+		ranges = append(ranges, Range{Start: start, End: end})
+		start = end
+	}
+	return ranges
+}
+
+// Reduce folds items in parallel and returns the combined result.
+//
+// Items are split into contiguous chunks, one per worker. Each worker
+// starts from identity() and folds its chunk with fold; the partial
+// results are then merged left to right with combine. combine must be
+// associative and identity() must be its identity element, but combine
+// need not be commutative: chunk order is preserved.
+//
+// If ctx is cancelled before all chunks finish, Reduce returns ctx.Err().
+func Reduce[T, R any](
+	ctx context.Context,
+	items []T,
+	workers int,
+	identity func() R,
+	fold func(acc R, item T) R,
+	combine func(a, b R) R,
+) (R, error) {
+	ranges := Ranges(len(items), workers)
+	partials := make([]R, len(ranges))
+
+	var wg sync.WaitGroup
+	for i, r := range ranges {
+		wg.Add(1)
+		go func(i int, chunk []T) {
+			defer wg.Done()
+
+			acc := identity()
+			for j, item := range chunk {
+				// check for cancellation periodically, not per item
+				if j%1024 == 0 && ctx.Err() != nil {
+					return
+				}
+				acc = fold(acc, item)
+			}
+			partials[i] = acc
+		}(i, items[r.Start:r.End])
+	}
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		var zero R
+		return zero, err
+	}
+
+	result := identity()
+	for _, p := range partials {
+		result = combine(result, p)
+	}
+	return result, nil
+}
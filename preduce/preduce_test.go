@@ -0,0 +1,84 @@
+package preduce
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestRanges tests that ranges cover the input exactly once
+func TestRanges(t *testing.T) {
+	tests := []struct {
+		n, parts int
+		want     []Range
+	}{
+		{n: 0, parts: 4, want: nil},
+		{n: 3, parts: 8, want: []Range{{0, 1}, {1, 2}, {2, 3}}},
+		{n: 10, parts: 3, want: []Range{{0, 4}, {4, 7}, {7, 10}}},
+		{n: 5, parts: 0, want: []Range{{0, 5}}},
+	}
+
+	for _, tt := range tests {
+		if got := Ranges(tt.n, tt.parts); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Ranges(%d, %d) = %v, want %v", tt.n, tt.parts, got, tt.want)
+		}
+	}
+}
+
+// TestReduce_Sum tests a commutative reduction across worker counts
+func TestReduce_Sum(t *testing.T) {
+	items := make([]int, 10_000)
+	want := 0
+	for i := range items {
+		items[i] = i
+		want += i
+	}
+
+	for _, workers := range []int{1, 2, 3, 8, 64} {
+		got, err := Reduce(context.Background(), items, workers,
+			func() int { return 0 },
+			func(acc, v int) int { return acc + v },
+			func(a, b int) int { return a + b },
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("workers=%d: got %d, want %d", workers, got, want)
+		}
+	}
+}
+
+// TestReduce_PreservesOrder tests that a non-commutative combine sees chunks in order
+func TestReduce_PreservesOrder(t *testing.T) {
+	items := strings.Split("the quick brown fox jumps over the lazy dog", "")
+
+	got, err := Reduce(context.Background(), items, 4,
+		func() string { return "" },
+		func(acc, s string) string { return acc + s },
+		func(a, b string) string { return a + b },
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := strings.Join(items, ""); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+// TestReduce_Cancelled tests that a cancelled context is reported
+func TestReduce_Cancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := Reduce(ctx, make([]int, 100), 4,
+		func() int { return 0 },
+		func(acc, v int) int { return acc + v },
+		func(a, b int) int { return a + b },
+	)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("err = %v, want %v", err, context.Canceled)
+	}
+}
@@ -0,0 +1,140 @@
+package preduce
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"testing/quick"
+)
+
+// input is a random Reduce call: items, a worker count that may be
+// zero, negative or more than there are items, and after how many
+// folds to cancel the context, if at all (CancelAt > len(Items)).
+type input struct {
+	Items    []int
+	Workers  int
+	CancelAt int
+}
+
+// Generate makes inputs long enough to span several cancellation
+// checks, which Reduce makes every 1024 items.
+func (input) Generate(r *rand.Rand, size int) reflect.Value {
+	in := input{Items: make([]int, r.Intn(5000)), Workers: r.Intn(70) - 2}
+	for i := range in.Items {
+		in.Items[i] = r.Int()
+	}
+	in.CancelAt = r.Intn(2*len(in.Items) + 1)
+	return reflect.ValueOf(in)
+}
+
+// matrix is a 2x2 matrix over the integers modulo a prime: under
+// multiplication, a monoid that is not commutative, so it catches
+// chunks combined out of order, and whose identity is not the zero
+// value, so it catches a worker starting from the wrong element.
+type matrix [4]uint64
+
+const prime = 1_000_000_007
+
+func identity() matrix {
+	return matrix{1, 0, 0, 1}
+}
+
+func mul(a, b matrix) matrix {
+	return matrix{
+		(a[0]*b[0] + a[1]*b[2]) % prime, (a[0]*b[1] + a[1]*b[3]) % prime,
+		(a[2]*b[0] + a[3]*b[2]) % prime, (a[2]*b[1] + a[3]*b[3]) % prime,
+	}
+}
+
+// toMatrix maps an item to an invertible matrix.
+func toMatrix(v int) matrix {
+	return matrix{uint64(v) % prime, 1, 1, 0}
+}
+
+// sequential folds items one by one, as Reduce must appear to.
+func sequential[T, R any](items []T, identity func() R, fold func(R, T) R) R {
+	acc := identity()
+	for _, item := range items {
+		acc = fold(acc, item)
+	}
+	return acc
+}
+
+var quickConfig = &quick.Config{MaxCount: 200}
+
+// TestRanges_Property tests that ranges tile [0, n) in order, in at most
+// parts non-empty ranges whose sizes differ by at most one
+func TestRanges_Property(t *testing.T) {
+	f := func(n uint16, parts int8) bool {
+		ranges := Ranges(int(n), int(parts))
+		if len(ranges) > max(int(parts), 1) {
+			return false
+		}
+		next, smallest, largest := 0, int(n), 0
+		for _, r := range ranges {
+			if r.Start != next || r.End <= r.Start {
+				return false
+			}
+			next = r.End
+			smallest, largest = min(smallest, r.End-r.Start), max(largest, r.End-r.Start)
+		}
+		return next == int(n) && (n == 0 || largest-smallest <= 1)
+	}
+	if err := quick.Check(f, quickConfig); err != nil {
+		t.Error(err)
+	}
+}
+
+// TestReduce_Property tests that Reduce equals a sequential fold, for a
+// commutative and a non-commutative monoid, whatever the items and the
+// number of workers
+func TestReduce_Property(t *testing.T) {
+	sum := func(in input) bool {
+		got, err := Reduce(context.Background(), in.Items, in.Workers,
+			func() int { return 0 },
+			func(acc, v int) int { return acc + v },
+			func(a, b int) int { return a + b },
+		)
+		want := sequential(in.Items, func() int { return 0 }, func(acc, v int) int { return acc + v })
+		return err == nil && got == want
+	}
+	product := func(in input) bool {
+		fold := func(acc matrix, v int) matrix { return mul(acc, toMatrix(v)) }
+		got, err := Reduce(context.Background(), in.Items, in.Workers, identity, fold, mul)
+		return err == nil && got == sequential(in.Items, identity, fold)
+	}
+	for name, f := range map[string]func(input) bool{"sum": sum, "product": product} {
+		if err := quick.Check(f, quickConfig); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+}
+
+// TestReduce_CancelProperty tests that a Reduce cancelled while it runs
+// fails, whatever item it is cancelled at, rather than return a partial
+// result, and that one not cancelled is unaffected
+func TestReduce_CancelProperty(t *testing.T) {
+	f := func(in input) bool {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		var folds atomic.Int64
+		fold := func(acc matrix, v int) matrix {
+			if folds.Add(1) == int64(in.CancelAt) {
+				cancel()
+			}
+			return mul(acc, toMatrix(v))
+		}
+		got, err := Reduce(ctx, in.Items, in.Workers, identity, fold, mul)
+		if in.CancelAt >= 1 && in.CancelAt <= len(in.Items) {
+			return errors.Is(err, context.Canceled)
+		}
+		folds.Store(-1 << 62) // never reach CancelAt again
+		return err == nil && got == sequential(in.Items, identity, fold)
+	}
+	if err := quick.Check(f, quickConfig); err != nil {
+		t.Error(err)
+	}
+}
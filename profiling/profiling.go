@@ -0,0 +1,105 @@
+package profiling
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/pprof"
+	"runtime/trace"
+)
+
+// Flags holds the profiling flags of a command, so a demo can be
+// profiled without editing it:
+//
+//	-cpuprofile file   write a CPU profile
+//	-memprofile file   write a heap profile on exit
+//	-trace file        write an execution trace, for go tool trace
+//
+// Inspect the profiles with go tool pprof.
+type Flags struct {
+	CPUProfile string
+	MemProfile string
+	Trace      string
+}
+
+// Register defines the flags on fs, or on flag.CommandLine if fs is
+// nil, to be parsed with the command's other flags.
+func Register(fs *flag.FlagSet) *Flags {
+	if fs == nil {
+		fs = flag.CommandLine
+	}
+	f := &Flags{}
+	fs.StringVar(&f.CPUProfile, "cpuprofile", "", "write a CPU profile to `file`")
+	fs.StringVar(&f.MemProfile, "memprofile", "", "write a heap profile to `file` on exit")
+	fs.StringVar(&f.Trace, "trace", "", "write an execution trace to `file`")
+	return f
+}
+
+// Start starts the CPU profile and the trace the flags ask for. The
+// returned function stops them and writes the heap profile; call it
+// when the command is done, before the program exits, as os.Exit and
+// log.Fatal skip deferred calls.
+func (f *Flags) Start() (stop func() error, err error) {
+	var stops []func() error
+	stop = func() error {
+		var errs []error
+		for i := len(stops) - 1; i >= 0; i-- {
+			errs = append(errs, stops[i]())
+		}
+		return errors.Join(errs...)
+	}
+
+	if f.CPUProfile != "" {
+		file, err := os.Create(f.CPUProfile)
+		if err != nil {
+			return nil, err
+		}
+		if err := pprof.StartCPUProfile(file); err != nil {
+			file.Close()
+			return nil, fmt.Errorf("cpuprofile: %w", err)
+		}
+		stops = append(stops, func() error {
+			pprof.StopCPUProfile()
+			return file.Close()
+		})
+	}
+	if f.Trace != "" {
+		file, err := os.Create(f.Trace)
+		if err != nil {
+			stop()
+			return nil, err
+		}
+		if err := trace.Start(file); err != nil {
+			file.Close()
+			stop()
+			return nil, fmt.Errorf("trace: %w", err)
+		}
+		stops = append(stops, func() error {
+			trace.Stop()
+			return file.Close()
+		})
+	}
+	if f.MemProfile != "" {
+		stops = append(stops, func() error {
+			return writeHeap(f.MemProfile)
+		})
+	}
+	return stop, nil
+}
+
+// writeHeap writes a heap profile, up to date as of a fresh garbage
+// collection, to path.
+func writeHeap(path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	runtime.GC()
+	if err := pprof.WriteHeapProfile(file); err != nil {
+		file.Close()
+		return fmt.Errorf("memprofile: %w", err)
+	}
+	return file.Close()
+}
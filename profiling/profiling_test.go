@@ -0,0 +1,68 @@
+package profiling
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var sink [][]byte
+
+// TestFlags tests that the flags parsed from a command line produce
+// every profile once stopped
+func TestFlags(t *testing.T) {
+	dir := t.TempDir()
+	fs := flag.NewFlagSet("demo", flag.ContinueOnError)
+	f := Register(fs)
+	paths := map[string]string{}
+	var args []string
+	for _, name := range []string{"cpuprofile", "memprofile", "trace"} {
+		paths[name] = filepath.Join(dir, name+".out")
+		args = append(args, "-"+name, paths[name])
+	}
+	if err := fs.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+
+	stop, err := f.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range 1000 {
+		sink = append(sink, make([]byte, 1024))
+	}
+	if err := stop(); err != nil {
+		t.Fatal(err)
+	}
+	for name, path := range paths {
+		if fi, err := os.Stat(path); err != nil || fi.Size() == 0 {
+			t.Errorf("-%s wrote nothing to %s: %v", name, path, err)
+		}
+	}
+}
+
+// TestFlags_None tests that no flags means no profiling
+func TestFlags_None(t *testing.T) {
+	stop, err := Register(flag.NewFlagSet("demo", flag.ContinueOnError)).Start()
+	if err != nil || stop() != nil {
+		t.Errorf("Start() with no flags = %v", err)
+	}
+}
+
+// TestFlags_BadPath tests that a profile that cannot be created fails
+// Start, stopping those already started
+func TestFlags_BadPath(t *testing.T) {
+	dir := t.TempDir()
+	f := &Flags{CPUProfile: filepath.Join(dir, "cpu.out"), Trace: filepath.Join(dir, "missing", "trace.out")}
+	if _, err := f.Start(); err == nil {
+		t.Fatal("Start() succeeded")
+	}
+	// the CPU profile was stopped, so it can be started again
+	f.Trace = ""
+	stop, err := f.Start()
+	if err != nil {
+		t.Fatalf("Start() after a failure = %v", err)
+	}
+	stop()
+}
@@ -0,0 +1,94 @@
+package pscan
+
+import (
+	"context"
+	"sync"
+
+	"github.com/arifmahmudrana/go-snippets/preduce"
+)
+
+// Number is any integer or floating-point type.
+type Number interface {
+	~int | ~int8 | ~int16 | ~int32 | ~int64 |
+		~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 | ~uintptr |
+		~float32 | ~float64
+}
+
+// Sum returns the inclusive prefix sums of xs: out[i] = xs[0] + ... + xs[i].
+//
+// The work runs in three phases: every worker totals its own chunk, the
+// chunk totals are scanned sequentially into offsets, and then every
+// worker writes its chunk's prefix sums starting from its offset.
+//
+// If ctx is cancelled before the scan completes, Sum returns ctx.Err().
+func Sum[T Number](ctx context.Context, xs []T, workers int) ([]T, error) {
+	return scan(ctx, xs, workers, true)
+}
+
+// Exclusive returns the exclusive prefix sums of xs: out[0] = 0 and
+// out[i] = xs[0] + ... + xs[i-1]. It is the usual form for turning
+// per-item counts into write offsets.
+func Exclusive[T Number](ctx context.Context, xs []T, workers int) ([]T, error) {
+	return scan(ctx, xs, workers, false)
+}
+
+func scan[T Number](ctx context.Context, xs []T, workers int, inclusive bool) ([]T, error) {
+	ranges := preduce.Ranges(len(xs), workers)
+	out := make([]T, len(xs))
+
+	// phase 1: local totals
+	totals := make([]T, len(ranges))
+	each(ranges, func(i int, r preduce.Range) {
+		var sum T
+		for j, x := range xs[r.Start:r.End] {
+			if j%1024 == 0 && ctx.Err() != nil {
+				return
+			}
+			sum += x
+		}
+		totals[i] = sum
+	})
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	// phase 2: offsets of each chunk, sequential over len(ranges) values
+	var running T
+	for i, t := range totals {
+		totals[i], running = running, running+t
+	}
+
+	// phase 3: local scans seeded with the chunk offset
+	each(ranges, func(i int, r preduce.Range) {
+		acc := totals[i]
+		for j := r.Start; j < r.End; j++ {
+			if (j-r.Start)%1024 == 0 && ctx.Err() != nil {
+				return
+			}
+			if inclusive {
+				acc += xs[j]
+				out[j] = acc
+			} else {
+				out[j] = acc
+				acc += xs[j]
+			}
+		}
+	})
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// each runs fn for every range in its own goroutine and waits.
+func each(ranges []preduce.Range, fn func(i int, r preduce.Range)) {
+	var wg sync.WaitGroup
+	for i, r := range ranges {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			fn(i, r)
+		}()
+	}
+	wg.Wait()
+}
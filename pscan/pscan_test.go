@@ -0,0 +1,48 @@
+package pscan
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+)
+
+// TestSum tests inclusive and exclusive scans against a sequential loop
+func TestSum(t *testing.T) {
+	for _, n := range []int{0, 1, 5, 1000, 10_007} {
+		xs := make([]int, n)
+		for i := range xs {
+			xs[i] = i%7 - 3
+		}
+		inc := make([]int, n)
+		exc := make([]int, n)
+		sum := 0
+		for i, x := range xs {
+			exc[i] = sum
+			sum += x
+			inc[i] = sum
+		}
+
+		for _, workers := range []int{1, 3, 8, 64} {
+			got, err := Sum(context.Background(), xs, workers)
+			if err != nil || !slices.Equal(got, inc) {
+				t.Errorf("Sum(n=%d, workers=%d) mismatch, err = %v", n, workers, err)
+			}
+			got, err = Exclusive(context.Background(), xs, workers)
+			if err != nil || !slices.Equal(got, exc) {
+				t.Errorf("Exclusive(n=%d, workers=%d) mismatch, err = %v", n, workers, err)
+			}
+		}
+	}
+}
+
+// TestSum_Cancelled tests that a cancelled context is reported
+func TestSum_Cancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := Sum(ctx, make([]float64, 10_000), 4)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Sum() error = %v, want %v", err, context.Canceled)
+	}
+}
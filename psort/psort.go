@@ -0,0 +1,183 @@
+package psort
+
+import (
+	"cmp"
+	"math/bits"
+	"math/rand"
+	"runtime"
+	"slices"
+	"sync"
+)
+
+// Cutoff is the slice length below which sorting is done sequentially.
+// Spawning goroutines for smaller pieces costs more than it saves.
+const Cutoff = 1 << 13
+
+// workers returns the parallelism to aim for.
+func workers() int {
+	return max(runtime.GOMAXPROCS(0), 1)
+}
+
+// MergeSort sorts s in ascending order using a parallel merge sort.
+// It allocates a buffer the size of s.
+func MergeSort[S ~[]E, E cmp.Ordered](s S) {
+	buf := make([]E, len(s))
+	mergeSort(s, buf, cmp.Compare[E], forkDepth(), func(x []E) { slices.Sort(x) })
+}
+
+// MergeSortFunc sorts s with cmp using a parallel merge sort. Unlike
+// slices.SortFunc it is stable: equal elements keep their order.
+func MergeSortFunc[S ~[]E, E any](s S, cmp func(a, b E) int) {
+	buf := make([]E, len(s))
+	mergeSort(s, buf, cmp, forkDepth(), func(x []E) { slices.SortStableFunc(x, cmp) })
+}
+
+// forkDepth returns how many levels of the recursion may fork: enough to
+// give every worker a couple of leaves. Cutoff still bounds leaf size.
+func forkDepth() int {
+	return bits.Len(uint(workers())) + 1
+}
+
+// mergeSort sorts s, using buf (same length) as scratch space.
+func mergeSort[E any](s, buf []E, cmp func(a, b E) int, depth int, leaf func([]E)) {
+	if len(s) <= Cutoff || depth == 0 {
+		leaf(s)
+		return
+	}
+
+	mid := len(s) / 2
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		mergeSort(s[:mid], buf[:mid], cmp, depth-1, leaf)
+	}()
+	mergeSort(s[mid:], buf[mid:], cmp, depth-1, leaf)
+	wg.Wait()
+
+	// already in order: nothing to merge
+	if cmp(s[mid-1], s[mid]) <= 0 {
+		return
+	}
+	merge(s[:mid], s[mid:], buf, cmp)
+	copy(s, buf)
+}
+
+// merge writes the stable merge of sorted a and b into dst.
+func merge[E any](a, b, dst []E, cmp func(a, b E) int) {
+	i, j, k := 0, 0, 0
+	for i < len(a) && j < len(b) {
+		if cmp(b[j], a[i]) < 0 {
+			dst[k] = b[j]
+			j++
+		} else {
+			dst[k] = a[i] // ties take from a: stable
+			i++
+		}
+		k++
+	}
+	k += copy(dst[k:], a[i:])
+	copy(dst[k:], b[j:])
+}
+
+// oversample is how many samples are drawn per bucket to pick splitters.
+const oversample = 32
+
+// SampleSort sorts s in ascending order using a parallel sample sort:
+// elements are partitioned into one bucket per worker by sampled
+// splitters, then the buckets are sorted independently. It allocates a
+// buffer the size of s.
+func SampleSort[S ~[]E, E cmp.Ordered](s S) {
+	p := workers()
+	if len(s) <= Cutoff || p == 1 {
+		slices.Sort(s)
+		return
+	}
+
+	// pick p-1 splitters from a sorted random sample
+	rng := rand.New(rand.NewSource(int64(len(s))))
+	sample := make([]E, p*oversample)
+	for i := range sample {
+		sample[i] = s[rng.Intn(len(s))]
+	}
+	slices.Sort(sample)
+	splitters := make([]E, p-1)
+	for i := range splitters {
+		splitters[i] = sample[(i+1)*oversample]
+	}
+
+	// bucketOf returns the index of the first splitter greater than e
+	bucketOf := func(e E) int {
+		lo, hi := 0, len(splitters)
+		for lo < hi {
+			m := int(uint(lo+hi) >> 1)
+			if cmp.Compare(splitters[m], e) <= 0 {
+				lo = m + 1
+			} else {
+				hi = m
+			}
+		}
+		return lo
+	}
+
+	// phase 1: each worker counts how many of its elements go to each bucket
+	chunk := (len(s) + p - 1) / p
+	counts := make([][]int, p)
+	var wg sync.WaitGroup
+	for w := range p {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c := make([]int, p)
+			for _, e := range s[min(w*chunk, len(s)):min((w+1)*chunk, len(s))] {
+				c[bucketOf(e)]++
+			}
+			counts[w] = c
+		}()
+	}
+	wg.Wait()
+
+	// prefix sums: where each worker writes within each bucket
+	offsets := make([][]int, p)
+	bounds := make([]int, p+1)
+	pos := 0
+	for b := range p {
+		bounds[b] = pos
+		for w := range p {
+			if offsets[w] == nil {
+				offsets[w] = make([]int, p)
+			}
+			offsets[w][b] = pos
+			pos += counts[w][b]
+		}
+	}
+	bounds[p] = pos
+
+	// phase 2: scatter into buf without any locking
+	buf := make([]E, len(s))
+	for w := range p {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			off := offsets[w]
+			for _, e := range s[min(w*chunk, len(s)):min((w+1)*chunk, len(s))] {
+				b := bucketOf(e)
+				buf[off[b]] = e
+				off[b]++
+			}
+		}()
+	}
+	wg.Wait()
+
+	// phase 3: sort every bucket in place, then copy back
+	for b := range p {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			bucket := buf[bounds[b]:bounds[b+1]]
+			slices.Sort(bucket)
+			copy(s[bounds[b]:bounds[b+1]], bucket)
+		}()
+	}
+	wg.Wait()
+}
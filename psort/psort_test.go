@@ -0,0 +1,130 @@
+package psort
+
+import (
+	"cmp"
+	"math/rand"
+	"runtime"
+	"slices"
+	"strconv"
+	"testing"
+)
+
+// inputs returns a variety of int slices of length n.
+func inputs(n int) map[string][]int {
+	rng := rand.New(rand.NewSource(int64(n)))
+	random := make([]int, n)
+	dups := make([]int, n)
+	for i := range random {
+		random[i] = rng.Int()
+		dups[i] = rng.Intn(4)
+	}
+	sorted := slices.Clone(random)
+	slices.Sort(sorted)
+	reversed := slices.Clone(sorted)
+	slices.Reverse(reversed)
+
+	return map[string][]int{
+		"random":   random,
+		"dups":     dups,
+		"sorted":   sorted,
+		"reversed": reversed,
+	}
+}
+
+var sizes = []int{0, 1, 7, Cutoff - 1, Cutoff + 1, 3*Cutoff + 17, 200_000}
+
+// TestSorts tests both algorithms against slices.Sort
+func TestSorts(t *testing.T) {
+	algos := map[string]func([]int){
+		"MergeSort":  MergeSort[[]int],
+		"SampleSort": SampleSort[[]int],
+		"MergeSortFunc": func(s []int) {
+			MergeSortFunc(s, cmp.Compare[int])
+		},
+	}
+
+	for _, procs := range []int{1, 4} {
+		prev := runtime.GOMAXPROCS(procs)
+		for _, n := range sizes {
+			for kind, in := range inputs(n) {
+				want := slices.Clone(in)
+				slices.Sort(want)
+				for name, sort := range algos {
+					got := slices.Clone(in)
+					sort(got)
+					if !slices.Equal(got, want) {
+						t.Errorf("%s procs=%d n=%d %s: not sorted", name, procs, n, kind)
+					}
+				}
+			}
+		}
+		runtime.GOMAXPROCS(prev)
+	}
+}
+
+// TestMergeSortFunc_Stable tests that equal keys keep their input order
+func TestMergeSortFunc_Stable(t *testing.T) {
+	type item struct{ key, seq int }
+	rng := rand.New(rand.NewSource(1))
+	items := make([]item, 5*Cutoff)
+	for i := range items {
+		items[i] = item{key: rng.Intn(10), seq: i}
+	}
+
+	MergeSortFunc(items, func(a, b item) int { return cmp.Compare(a.key, b.key) })
+
+	for i := 1; i < len(items); i++ {
+		a, b := items[i-1], items[i]
+		if a.key > b.key || (a.key == b.key && a.seq > b.seq) {
+			t.Fatalf("not stable at %d: %+v before %+v", i, a, b)
+		}
+	}
+}
+
+// TestSampleSort_Strings tests a non-integer ordered type
+func TestSampleSort_Strings(t *testing.T) {
+	s := make([]string, 50_000)
+	for i := range s {
+		s[i] = strconv.Itoa((i * 7919) % 50_000)
+	}
+	SampleSort(s)
+	if !slices.IsSorted(s) {
+		t.Error("strings not sorted")
+	}
+}
+
+// benchSizes covers "too small to bother" through "worth parallelising".
+// Compare core counts with: go test -run x -bench . -cpu 1,2,4,8 ./psort
+// On a single core both parallel sorts only add overhead over slices.Sort.
+var benchSizes = []int{1_000, 100_000, 1_000_000}
+
+func benchmarkSort(b *testing.B, sort func([]int)) {
+	for _, n := range benchSizes {
+		b.Run(strconv.Itoa(n), func(b *testing.B) {
+			in := inputs(n)["random"]
+			s := make([]int, n)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				copy(s, in)
+				b.StartTimer()
+				sort(s)
+			}
+		})
+	}
+}
+
+// BenchmarkStdlib benchmarks the sequential baseline
+func BenchmarkStdlib(b *testing.B) {
+	benchmarkSort(b, slices.Sort[[]int])
+}
+
+// BenchmarkMergeSort benchmarks the parallel merge sort
+func BenchmarkMergeSort(b *testing.B) {
+	benchmarkSort(b, MergeSort[[]int])
+}
+
+// BenchmarkSampleSort benchmarks the parallel sample sort
+func BenchmarkSampleSort(b *testing.B) {
+	benchmarkSort(b, SampleSort[[]int])
+}
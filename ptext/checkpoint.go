@@ -0,0 +1,218 @@
+package ptext
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/arifmahmudrana/go-snippets/store"
+)
+
+// Option configures RunFile and RunFiles.
+type Option func(*options)
+
+type options struct {
+	store store.Store
+	name  string
+	every time.Duration
+	now   func() time.Time
+}
+
+// WithCheckpoints saves each file's progress to s at most once per
+// every (and when the file is done), so a run that is interrupted can
+// be repeated and pick up where it stopped instead of starting over.
+// name identifies the run, e.g. the tool and its arguments: checkpoints
+// are only reused by runs with the same name, for files whose size and
+// modification time are unchanged. They are deleted once the run
+// succeeds. The tool's results must round-trip through encoding/json.
+func WithCheckpoints(s store.Store, name string, every time.Duration) Option {
+	return func(o *options) {
+		o.store, o.name, o.every = s, name, every
+	}
+}
+
+// checkpoint is what is stored for one file.
+type checkpoint struct {
+	Size    int64           `json:"size"`
+	ModTime time.Time       `json:"mod_time"`
+	Offset  int64           `json:"offset"` // decompressed bytes processed
+	Line    int             `json:"line"`
+	Done    bool            `json:"done"`
+	Result  json.RawMessage `json:"result"`
+}
+
+func (o *options) key(path string) string {
+	return "ptext/" + url.PathEscape(o.name) + "/" + url.PathEscape(path)
+}
+
+// load returns path's checkpoint, or a fresh one if there is none or the
+// file changed since it was taken.
+func (o *options) load(ctx context.Context, path string, info os.FileInfo) (checkpoint, error) {
+	fresh := checkpoint{Size: info.Size(), ModTime: info.ModTime()}
+	data, err := o.store.Get(ctx, o.key(path))
+	if errors.Is(err, store.ErrNotFound) {
+		return fresh, nil
+	}
+	if err != nil {
+		return fresh, err
+	}
+	var cp checkpoint
+	if err := json.Unmarshal(data, &cp); err != nil {
+		return fresh, nil // unreadable: start over
+	}
+	if cp.Size != fresh.Size || !cp.ModTime.Equal(fresh.ModTime) {
+		return fresh, nil
+	}
+	return cp, nil
+}
+
+func (o *options) save(ctx context.Context, path string, cp checkpoint, result any) error {
+	var err error
+	if cp.Result, err = json.Marshal(result); err != nil {
+		return err
+	}
+	data, err := json.Marshal(cp)
+	if err != nil {
+		return err
+	}
+	return o.store.Put(ctx, o.key(path), data)
+}
+
+// forget deletes the checkpoints of paths.
+func (o *options) forget(ctx context.Context, paths []string) error {
+	for _, path := range paths {
+		if err := o.store.Delete(ctx, o.key(path)); err != nil && !errors.Is(err, store.ErrNotFound) {
+			return err
+		}
+	}
+	return nil
+}
+
+// RunFile is Run on the file at path, decompressing it if needed (see
+// Open). Read errors are prefixed with path.
+func RunFile[R any](ctx context.Context, path string, workers int, t Tool[R], opts ...Option) (R, error) {
+	o := newOptions(opts)
+	r, err := runFile(ctx, path, workers, t, o)
+	if err == nil && o.store != nil {
+		err = o.forget(ctx, []string{path})
+	}
+	return r, err
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{now: time.Now}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// runFile processes path, resuming from and updating its checkpoint if
+// o has a store.
+func runFile[R any](ctx context.Context, path string, workers int, t Tool[R], o *options) (R, error) {
+	var zero R
+	if o.store == nil {
+		f, err := Open(path)
+		if err != nil {
+			return zero, err
+		}
+		defer f.Close()
+		r, err := run(ctx, f, workers, t, position{}, t.New(), nil)
+		return r, pathErr(ctx, path, err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return zero, err
+	}
+	cp, err := o.load(ctx, path, info)
+	if err != nil {
+		return zero, err
+	}
+	acc := t.New()
+	if cp.Result != nil {
+		if err := json.Unmarshal(cp.Result, &acc); err != nil {
+			acc, cp.Offset, cp.Line = t.New(), 0, 0 // wrong shape: start over
+		}
+	}
+	if cp.Done {
+		return acc, nil
+	}
+
+	f, err := openAt(path, cp.Offset)
+	if err != nil {
+		return zero, err
+	}
+	defer f.Close()
+
+	var saveErr error
+	last := o.now()
+	progress := func(pos position, result R) {
+		if saveErr != nil || o.now().Sub(last) < o.every {
+			return
+		}
+		last = o.now()
+		cp.Offset, cp.Line = pos.Offset, pos.Line
+		saveErr = o.save(ctx, path, cp, result)
+	}
+	result, err := run(ctx, f, workers, t, position{cp.Offset, cp.Line}, acc, progress)
+	if err != nil {
+		return zero, pathErr(ctx, path, err)
+	}
+	if saveErr != nil {
+		return zero, fmt.Errorf("ptext: saving checkpoint for %s: %w", path, saveErr)
+	}
+	cp.Done = true
+	if err := o.save(ctx, path, cp, result); err != nil {
+		return zero, fmt.Errorf("ptext: saving checkpoint for %s: %w", path, err)
+	}
+	return result, nil
+}
+
+// pathErr prefixes a read error with path, leaving cancellation alone.
+func pathErr(ctx context.Context, path string, err error) error {
+	if err != nil && ctx.Err() == nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return err
+}
+
+// openAt opens path like Open, positioned offset bytes into its
+// (decompressed) content. Plain files seek; compressed ones have to be
+// decompressed up to offset.
+func openAt(path string, offset int64) (io.ReadCloser, error) {
+	if offset == 0 {
+		return Open(path)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	head := make([]byte, len(zstdMagic))
+	n, _ := f.ReadAt(head, 0)
+	head = head[:n]
+	if !bytes.HasPrefix(head, gzipMagic) && !bytes.HasPrefix(head, zstdMagic) {
+		if _, err := f.Seek(offset, io.SeekStart); err != nil {
+			f.Close()
+			return nil, err
+		}
+		return f, nil
+	}
+	f.Close()
+
+	r, err := Open(path)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.CopyN(io.Discard, r, offset); err != nil {
+		r.Close()
+		return nil, fmt.Errorf("%s: skipping to checkpoint: %w", path, err)
+	}
+	return r, nil
+}
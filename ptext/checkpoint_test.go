@@ -0,0 +1,140 @@
+package ptext
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/arifmahmudrana/go-snippets/store"
+)
+
+// interrupted wraps t to count the lines it sees and to call stop at
+// line stopAt.
+func interrupted[R any](t Tool[R], seen *atomic.Int64, stopAt int, stop func()) Tool[R] {
+	line := t.Line
+	t.Line = func(acc R, n int, s string) R {
+		seen.Add(1)
+		if n == stopAt {
+			stop()
+		}
+		return line(acc, n, s)
+	}
+	return t
+}
+
+// TestRunFile_Resume tests that an interrupted run resumes from its
+// checkpoint and still gets the full result
+func TestRunFile_Resume(t *testing.T) {
+	dir := t.TempDir()
+	lines := 20000 // sample's line count
+	files := map[string][]byte{
+		"plain.txt": []byte(sample),
+		"data.gz":   gzipped(t, sample),
+	}
+	for name, data := range files {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(dir, name)
+			if err := os.WriteFile(path, data, 0o644); err != nil {
+				t.Fatal(err)
+			}
+			cps := store.NewMemory()
+			opt := WithCheckpoints(cps, "wc", 0) // save after every batch
+
+			ctx, cancel := context.WithCancel(context.Background())
+			var seen atomic.Int64
+			_, err := RunFile(ctx, path, 2, interrupted(WordCount(nil), &seen, lines/2, cancel), opt)
+			if !errors.Is(err, context.Canceled) {
+				t.Fatalf("interrupted RunFile() error = %v, want context.Canceled", err)
+			}
+
+			seen.Store(0)
+			got, err := RunFile(context.Background(), path, 2, interrupted(WordCount(nil), &seen, -1, nil), opt)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := (Counts{Lines: lines, Words: 10 * lines, Bytes: len(sample)}); got != want {
+				t.Errorf("resumed RunFile() = %+v, want %+v", got, want)
+			}
+			// batches in flight at the interruption are read again, but
+			// not the whole file
+			if n := seen.Load(); n >= int64(lines) {
+				t.Errorf("resumed run read %d lines, want fewer than %d", n, lines)
+			}
+			if keys, _ := cps.List(context.Background(), ""); len(keys) != 0 {
+				t.Errorf("checkpoints left after success: %v", keys)
+			}
+		})
+	}
+}
+
+// TestRunFiles_Resume tests resuming a multi-file run, with grep line
+// numbers carried across the restart
+func TestRunFiles_Resume(t *testing.T) {
+	dir := t.TempDir()
+	var b strings.Builder
+	for i := 1; i <= 20000; i++ {
+		if i%1000 == 0 {
+			fmt.Fprintf(&b, "needle %d\n", i)
+		} else {
+			b.WriteString("hay\n")
+		}
+	}
+	small, big := filepath.Join(dir, "small.txt"), filepath.Join(dir, "big.txt")
+	os.WriteFile(small, []byte("needle\n"), 0o644)
+	os.WriteFile(big, []byte(b.String()), 0o644)
+	paths := []string{small, big}
+
+	opt := WithCheckpoints(store.NewMemory(), "grep", 0)
+	grep := Grep(regexp.MustCompile("needle"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var seen atomic.Int64
+	if _, _, err := RunFiles(ctx, paths, 2, interrupted(grep, &seen, 15000, cancel), opt); err == nil {
+		t.Fatal("interrupted RunFiles() succeeded")
+	}
+
+	seen.Store(0)
+	total, perFile, err := RunFiles(context.Background(), paths, 2, interrupted(grep, &seen, -1, nil), opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(total); n != 21 {
+		t.Errorf("total matches = %d, want 21", n)
+	}
+	for i, m := range perFile[big] {
+		if want := 1000 * (i + 1); m.Line != want || m.Text != fmt.Sprintf("needle %d", want) {
+			t.Errorf("match %d = %+v, want line %d", i, m, want)
+		}
+	}
+	if n := seen.Load(); n >= 20000 {
+		t.Errorf("resumed run read %d lines, want fewer than 20000", n)
+	}
+}
+
+// TestRunFile_ChangedFile tests that a checkpoint for an older version
+// of a file is ignored
+func TestRunFile_ChangedFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "f.txt")
+	os.WriteFile(path, []byte(sample), 0o644)
+	cps := store.NewMemory()
+	opt := WithCheckpoints(cps, "wc", 0)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var seen atomic.Int64
+	RunFile(ctx, path, 1, interrupted(WordCount(nil), &seen, 5000, cancel), opt)
+
+	os.WriteFile(path, []byte("just one line\n"), 0o644)
+	got, err := RunFile(context.Background(), path, 1, WordCount(nil), opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Lines != 1 {
+		t.Errorf("Lines = %d, want 1 from the new content", got.Lines)
+	}
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"regexp"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/arifmahmudrana/go-snippets/ptext"
+	"github.com/arifmahmudrana/go-snippets/store"
+)
+
+// usage describes the subcommands.
+const usage = `usage:
+  ptext digits [flags] <file or dir>...   count the digits 0-9
+  ptext wc [flags] <file or dir>...       count lines, words and bytes
+  ptext grep [flags] <regexp> <file or dir>...
+
+flags:
+  -workers n     worker goroutines, shared by the files (default GOMAXPROCS)
+  -sort metric   order files by a metric, largest first (default: by name)
+                 digits: total or 0-9; wc: lines, words or bytes;
+                 grep: matches
+  -tokens spec   how wc splits words: whitespace (default), words, csv,
+                 tsv or regexp:<expr>
+  -checkpoint d  save progress in directory d every -every (default 10s);
+                 rerunning the same command after an interruption resumes
+
+Directories are walked recursively and files are processed
+concurrently. Files ending in .gz or .zst (or starting with their magic
+bytes) are decompressed on the fly.
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	cmd, args := os.Args[1], os.Args[2:]
+	if cmd == "help" || cmd == "-h" || cmd == "-help" || cmd == "--help" {
+		fmt.Print(usage)
+		return
+	}
+
+	fs := flag.NewFlagSet(cmd, flag.ExitOnError)
+	workers := fs.Int("workers", runtime.GOMAXPROCS(0), "worker goroutines, shared by the files")
+	sortBy := fs.String("sort", "", "order files by this metric, largest first")
+	tokens := fs.String("tokens", "whitespace", "how wc splits words: whitespace, words, csv, tsv or regexp:<expr>")
+	checkpoints := fs.String("checkpoint", "", "save progress in this directory so an interrupted run can resume")
+	every := fs.Duration("every", 10*time.Second, "how often to save progress with -checkpoint")
+	fs.Parse(args)
+	args = fs.Args()
+
+	var opts []ptext.Option
+	if *checkpoints != "" {
+		s, err := store.NewFile(*checkpoints)
+		if err != nil {
+			log.Fatal(err)
+		}
+		// the run's name covers whatever changes the results
+		name := cmd
+		switch {
+		case cmd == "wc":
+			name += " " + *tokens
+		case cmd == "grep" && len(args) > 0:
+			name += " " + args[0]
+		}
+		opts = append(opts, ptext.WithCheckpoints(s, name, *every))
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	var err error
+	switch cmd {
+	case "digits":
+		err = run(ctx, args, *workers, *sortBy, digits(), opts...)
+	case "wc":
+		var tok ptext.Tokenizer
+		if tok, err = ptext.ParseTokenizer(*tokens); err == nil {
+			err = run(ctx, args, *workers, *sortBy, wc(tok), opts...)
+		}
+	case "grep":
+		if len(args) < 1 {
+			log.Fatal("grep: missing pattern")
+		}
+		var re *regexp.Regexp
+		if re, err = regexp.Compile(args[0]); err == nil {
+			err = run(ctx, args[1:], *workers, *sortBy, grep(re), opts...)
+		}
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// report says how to compute, rank and print one tool's results.
+type report[R any] struct {
+	tool    ptext.Tool[R]
+	metrics map[string]func(R) int
+	print   func(name string, r R)
+	total   bool // print the aggregate after the files
+}
+
+// run processes files concurrently and prints each file's result, then
+// the total.
+func run[R any](ctx context.Context, roots []string, workers int, sortBy string, rep report[R], opts ...ptext.Option) error {
+	metric, ok := rep.metrics[sortBy]
+	if sortBy != "" && !ok {
+		return fmt.Errorf("unknown sort metric %q, want one of %s", sortBy, strings.Join(sortedKeys(rep.metrics), ", "))
+	}
+	files, err := ptext.Files(roots...)
+	if err != nil {
+		return err
+	}
+
+	total, perFile, err := ptext.RunFiles(ctx, files, workers, rep.tool, opts...)
+	if err != nil {
+		return err
+	}
+	if metric != nil {
+		// Files sorted by name, so ties stay in name order
+		slices.SortStableFunc(files, func(a, b string) int {
+			return metric(perFile[b]) - metric(perFile[a])
+		})
+	}
+	for _, path := range files {
+		rep.print(path, perFile[path])
+	}
+	if rep.total && len(files) > 1 {
+		rep.print("total", total)
+	}
+	return nil
+}
+
+func sortedKeys[K cmp.Ordered, V any](m map[K]V) []K {
+	out := make([]K, 0, len(m))
+	for k := range m {
+		out = append(out, k)
+	}
+	slices.Sort(out)
+	return out
+}
+
+func digits() report[map[rune]int] {
+	metrics := map[string]func(map[rune]int) int{
+		"total": func(m map[rune]int) int {
+			n := 0
+			for _, v := range m {
+				n += v
+			}
+			return n
+		},
+	}
+	for d := '0'; d <= '9'; d++ {
+		metrics[string(d)] = func(m map[rune]int) int { return m[d] }
+	}
+	return report[map[rune]int]{
+		tool:    ptext.Digits(),
+		metrics: metrics,
+		total:   true,
+		print: func(name string, counts map[rune]int) {
+			fmt.Printf("%s:", name)
+			for _, k := range sortedKeys(counts) {
+				fmt.Printf(" %c=%d", k, counts[k])
+			}
+			fmt.Println()
+		},
+	}
+}
+
+func wc(tok ptext.Tokenizer) report[ptext.Counts] {
+	return report[ptext.Counts]{
+		tool: ptext.WordCount(tok),
+		metrics: map[string]func(ptext.Counts) int{
+			"lines": func(c ptext.Counts) int { return c.Lines },
+			"words": func(c ptext.Counts) int { return c.Words },
+			"bytes": func(c ptext.Counts) int { return c.Bytes },
+		},
+		total: true,
+		print: func(name string, c ptext.Counts) {
+			fmt.Printf("%8d %8d %8d %s\n", c.Lines, c.Words, c.Bytes, name)
+		},
+	}
+}
+
+func grep(re *regexp.Regexp) report[[]ptext.Match] {
+	return report[[]ptext.Match]{
+		tool: ptext.Grep(re),
+		metrics: map[string]func([]ptext.Match) int{
+			"matches": func(m []ptext.Match) int { return len(m) },
+		},
+		print: func(name string, matches []ptext.Match) {
+			for _, m := range matches {
+				fmt.Printf("%s:%d:%s\n", name, m.Line, m.Text)
+			}
+		},
+	}
+}
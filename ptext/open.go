@@ -0,0 +1,168 @@
+package ptext
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// blockSize is how much decompressed data is handed over at a time, and
+// readAhead how many blocks the decompressor may get ahead of the reader.
+const (
+	blockSize = 64 << 10
+	readAhead = 4
+)
+
+// Open opens the file at path for reading. Gzip and zstd files (.gz,
+// .zst) are recognised by their magic bytes and decompressed on the fly
+// by a dedicated goroutine, so decompression overlaps with whatever
+// consumes the data. Close releases the file and stops the decompressor.
+func Open(path string) (io.ReadCloser, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	r, err := Decompress(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &fileReader{ReadCloser: r, f: f}, nil
+}
+
+// fileReader closes the file underneath a decompressor.
+type fileReader struct {
+	io.ReadCloser
+	f *os.File
+}
+
+func (r *fileReader) Close() error {
+	r.ReadCloser.Close()
+	return r.f.Close()
+}
+
+// Decompress returns r's data, decompressing it in a separate goroutine
+// if it starts with the gzip or zstd magic bytes. Plain input is passed
+// through. Closing the result does not close r.
+func Decompress(r io.Reader) (io.ReadCloser, error) {
+	br := bufio.NewReaderSize(r, blockSize)
+	head, _ := br.Peek(len(zstdMagic)) // short input cannot be compressed
+
+	var dec io.Reader
+	var release func()
+	switch {
+	case bytes.HasPrefix(head, gzipMagic):
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		dec, release = zr, func() { zr.Close() }
+	case bytes.HasPrefix(head, zstdMagic):
+		// one decoder goroutine: the block pipeline below already
+		// overlaps decompression with processing
+		zr, err := zstd.NewReader(br, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, err
+		}
+		dec, release = zr, zr.Close
+	default:
+		return io.NopCloser(br), nil
+	}
+	return newAsyncReader(dec, release), nil
+}
+
+// fill reads into buf until it is full or src fails. Unlike io.ReadFull
+// it passes src's error through, so a truncated stream's
+// io.ErrUnexpectedEOF is not mistaken for a short last block.
+func fill(src io.Reader, buf []byte) (int, error) {
+	n := 0
+	for n < len(buf) {
+		m, err := src.Read(buf[n:])
+		n += m
+		if err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// asyncReader reads ahead from src in its own goroutine.
+type asyncReader struct {
+	blocks  chan []byte
+	errc    chan error // the error that ended src, once blocks is closed
+	quit    chan struct{}
+	cur     []byte
+	err     error
+	stopped bool
+}
+
+func newAsyncReader(src io.Reader, release func()) *asyncReader {
+	r := &asyncReader{
+		blocks: make(chan []byte, readAhead),
+		errc:   make(chan error, 1),
+		quit:   make(chan struct{}),
+	}
+	go func() {
+		defer release()
+		defer close(r.blocks)
+		for {
+			buf := make([]byte, blockSize)
+			n, err := fill(src, buf)
+			if n > 0 {
+				select {
+				case r.blocks <- buf[:n]:
+				case <-r.quit:
+					return
+				}
+			}
+			if err == io.EOF {
+				return
+			}
+			if err != nil {
+				r.errc <- err
+				return
+			}
+		}
+	}()
+	return r
+}
+
+func (r *asyncReader) Read(p []byte) (int, error) {
+	for len(r.cur) == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+		b, ok := <-r.blocks
+		if !ok {
+			r.err = io.EOF
+			select {
+			case err := <-r.errc:
+				r.err = err
+			default:
+			}
+			continue
+		}
+		r.cur = b
+	}
+	n := copy(p, r.cur)
+	r.cur = r.cur[n:]
+	return n, nil
+}
+
+// Close stops the decompressor.
+func (r *asyncReader) Close() error {
+	if !r.stopped {
+		r.stopped = true
+		close(r.quit)
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package ptext
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// sample is several read-ahead blocks of text
+var sample = strings.Repeat("the 42 quick brown foxes jumped over 7 lazy dogs\n", 20000)
+
+func gzipped(t *testing.T, s string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	io.WriteString(w, s)
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func zstded(t *testing.T, s string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := zstd.NewWriter(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.WriteString(w, s)
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// TestOpen_Formats tests reading plain, gzip and zstd files
+func TestOpen_Formats(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"plain.txt", []byte(sample)},
+		{"data.gz", gzipped(t, sample)},
+		{"data.zst", zstded(t, sample)},
+		{"no-extension", gzipped(t, sample)}, // found by magic bytes
+		{"empty.txt", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, tt.name)
+			if err := os.WriteFile(path, tt.data, 0o644); err != nil {
+				t.Fatal(err)
+			}
+			f, err := Open(path)
+			if err != nil {
+				t.Fatalf("Open() error = %v", err)
+			}
+			defer f.Close()
+			got, err := io.ReadAll(f)
+			if err != nil {
+				t.Fatalf("ReadAll() error = %v", err)
+			}
+			want := sample
+			if tt.data == nil {
+				want = ""
+			}
+			if string(got) != want {
+				t.Errorf("read %d bytes, want %d", len(got), len(want))
+			}
+		})
+	}
+}
+
+// TestDecompress_Truncated tests that a cut-off stream is an error, not
+// a short read
+func TestDecompress_Truncated(t *testing.T) {
+	data := gzipped(t, sample)
+	r, err := Decompress(bytes.NewReader(data[:len(data)/2]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if _, err := io.ReadAll(r); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("ReadAll() error = %v, want io.ErrUnexpectedEOF", err)
+	}
+}
+
+// TestOpen_CloseEarly tests closing before reading everything
+func TestOpen_CloseEarly(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data.zst")
+	if err := os.WriteFile(path, zstded(t, sample), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	f, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.ReadFull(f, make([]byte, 10))
+	if err := f.Close(); err != nil {
+		t.Errorf("Close() error = %v", err)
+	}
+}
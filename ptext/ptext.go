@@ -0,0 +1,304 @@
+package ptext
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sync"
+)
+
+// Tool describes a computation over lines of text that can be split
+// across workers: each worker folds a batch of lines into a fresh New()
+// with Line, and the batch results are combined with Merge in input
+// order. Merge must be associative with New() as its identity; it may
+// modify and return a.
+type Tool[R any] struct {
+	New   func() R
+	Line  func(acc R, n int, line string) R // n counts lines from 1
+	Merge func(a, b R) R
+}
+
+// batchLines is how many lines a worker gets at a time: enough to make
+// the channel traffic negligible next to the work.
+const batchLines = 1024
+
+// maxLine is the longest line Run accepts.
+const maxLine = 16 << 20
+
+type batch struct {
+	seq   int
+	first int // line number of lines[0]
+	lines []string
+	end   position
+}
+
+// position is how much of an input has been consumed.
+type position struct {
+	Offset int64 // bytes
+	Line   int   // lines
+}
+
+type partial[R any] struct {
+	seq int
+	r   R
+	end position
+}
+
+// Run applies t to every line of r using workers goroutines (at least 1)
+// and returns the merged result. Reading, processing and merging
+// overlap, and at most a few batches per worker are held in memory. It
+// fails with r's error, or ctx.Err() if ctx is done first.
+func Run[R any](ctx context.Context, r io.Reader, workers int, t Tool[R]) (R, error) {
+	return run(ctx, r, workers, t, position{}, t.New(), nil)
+}
+
+// run is Run on input that starts at from, with acc holding the result
+// for everything before it. progress, if not nil, is called after each
+// batch is merged with the position reached and the result so far.
+func run[R any](ctx context.Context, r io.Reader, workers int, t Tool[R], from position, acc R, progress func(position, R)) (R, error) {
+	workers = max(workers, 1)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	batches := make(chan batch, workers)
+	partials := make(chan partial[R], workers)
+	readErr := make(chan error, 1)
+
+	// reader: split the input into numbered batches
+	go func() {
+		defer close(batches)
+		sc := bufio.NewScanner(r)
+		sc.Buffer(make([]byte, 64<<10), maxLine)
+		pos := from
+		sc.Split(func(data []byte, atEOF bool) (int, []byte, error) {
+			advance, token, err := bufio.ScanLines(data, atEOF)
+			pos.Offset += int64(advance)
+			return advance, token, err
+		})
+		b := batch{first: from.Line + 1}
+		send := func() bool {
+			b.end = pos
+			select {
+			case batches <- b:
+			case <-ctx.Done():
+				return false
+			}
+			b = batch{seq: b.seq + 1, first: b.first + len(b.lines)}
+			return true
+		}
+		for sc.Scan() {
+			b.lines = append(b.lines, sc.Text())
+			pos.Line++
+			if len(b.lines) == batchLines && !send() {
+				return
+			}
+		}
+		if len(b.lines) > 0 && !send() {
+			return
+		}
+		readErr <- sc.Err()
+	}()
+
+	// workers: fold each batch on its own
+	done := make(chan struct{}, workers)
+	for range workers {
+		go func() {
+			defer func() { done <- struct{}{} }()
+			for b := range batches {
+				acc := t.New()
+				for i, line := range b.lines {
+					acc = t.Line(acc, b.first+i, line)
+				}
+				select {
+				case partials <- partial[R]{b.seq, acc, b.end}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		for range workers {
+			<-done
+		}
+		close(partials)
+	}()
+
+	// merger: combine partials in input order as they become ready
+	result := acc
+	pending := make(map[int]partial[R])
+	next := 0
+	for p := range partials {
+		pending[p.seq] = p
+		for p, ok := pending[next]; ok; p, ok = pending[next] {
+			delete(pending, next)
+			result = t.Merge(result, p.r)
+			next++
+			if progress != nil {
+				progress(p.end, result)
+			}
+		}
+	}
+
+	var zero R
+	if err := ctx.Err(); err != nil {
+		return zero, err
+	}
+	if err := <-readErr; err != nil {
+		return zero, err
+	}
+	return result, nil
+}
+
+// Digits counts the decimal digits '0'-'9'.
+func Digits() Tool[map[rune]int] {
+	return Tool[map[rune]int]{
+		New: func() map[rune]int { return make(map[rune]int) },
+		Line: func(acc map[rune]int, _ int, line string) map[rune]int {
+			for _, r := range line {
+				if r >= '0' && r <= '9' {
+					acc[r]++
+				}
+			}
+			return acc
+		},
+		Merge: func(a, b map[rune]int) map[rune]int {
+			for k, v := range b {
+				a[k] += v
+			}
+			return a
+		},
+	}
+}
+
+// Counts is the result of WordCount.
+type Counts struct {
+	Lines int
+	Words int // tokens
+	Bytes int // including one newline per line
+}
+
+// WordCount counts lines, words and bytes like wc, with words as split
+// by tok; nil means Whitespace.
+func WordCount(tok Tokenizer) Tool[Counts] {
+	if tok == nil {
+		tok = Whitespace
+	}
+	return Tool[Counts]{
+		New: func() Counts { return Counts{} },
+		Line: func(acc Counts, _ int, line string) Counts {
+			acc.Lines++
+			acc.Words += len(tok.Tokens(line))
+			acc.Bytes += len(line) + 1
+			return acc
+		},
+		Merge: func(a, b Counts) Counts {
+			return Counts{a.Lines + b.Lines, a.Words + b.Words, a.Bytes + b.Bytes}
+		},
+	}
+}
+
+// Match is a line found by Grep.
+type Match struct {
+	Line int
+	Text string
+}
+
+// Grep collects the lines matching re, in input order.
+func Grep(re *regexp.Regexp) Tool[[]Match] {
+	return Tool[[]Match]{
+		New: func() []Match { return nil },
+		Line: func(acc []Match, n int, line string) []Match {
+			if re.MatchString(line) {
+				acc = append(acc, Match{n, line})
+			}
+			return acc
+		},
+		Merge: func(a, b []Match) []Match { return append(a, b...) },
+	}
+}
+
+// RunFiles applies t to every file in paths concurrently and returns
+// the result for each path along with their merge, in paths order. Up
+// to workers files are processed at once, and the workers are shared
+// out among them. The first error cancels the rest.
+func RunFiles[R any](ctx context.Context, paths []string, workers int, t Tool[R], opts ...Option) (total R, perFile map[string]R, err error) {
+	o := newOptions(opts)
+	workers = max(workers, 1)
+	perWorker := max(workers/max(len(paths), 1), 1)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make([]R, len(paths))
+	errs := make([]error, len(paths))
+	sem := make(chan struct{}, workers)
+	var wg sync.WaitGroup
+	for i, path := range paths {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-ctx.Done():
+				errs[i] = ctx.Err()
+				return
+			}
+			if results[i], errs[i] = runFile(ctx, path, perWorker, t, o); errs[i] != nil {
+				cancel()
+			}
+		}()
+	}
+	wg.Wait()
+
+	// report the error that caused the others, not the cancellations
+	for _, e := range errs {
+		if e != nil && (err == nil || errors.Is(err, context.Canceled)) {
+			err = e
+		}
+	}
+	if err != nil {
+		return total, nil, err
+	}
+
+	if o.store != nil {
+		if err := o.forget(ctx, paths); err != nil {
+			return total, nil, err
+		}
+	}
+
+	total = t.New()
+	perFile = make(map[string]R, len(paths))
+	for i, path := range paths {
+		perFile[path] = results[i]
+		total = t.Merge(total, results[i])
+	}
+	return total, perFile, nil
+}
+
+// Files expands roots into the regular files they name, walking
+// directories recursively, sorted and without duplicates.
+func Files(roots ...string) ([]string, error) {
+	var files []string
+	for _, root := range roots {
+		err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.Type().IsRegular() {
+				files = append(files, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	slices.Sort(files)
+	return slices.Compact(files), nil
+}
@@ -0,0 +1,154 @@
+package ptext
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// TestRun_Tools tests each tool against a sequential count
+func TestRun_Tools(t *testing.T) {
+	ctx := context.Background()
+	lines := strings.Count(sample, "\n")
+
+	for _, workers := range []int{1, 4} {
+		digits, err := Run(ctx, strings.NewReader(sample), workers, Digits())
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := map[rune]int{'4': lines, '2': lines, '7': lines}
+		if !reflect.DeepEqual(digits, want) {
+			t.Errorf("Digits() with %d workers = %v, want %v", workers, digits, want)
+		}
+
+		counts, err := Run(ctx, strings.NewReader(sample), workers, WordCount(nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := (Counts{Lines: lines, Words: 10 * lines, Bytes: len(sample)}); counts != want {
+			t.Errorf("WordCount(nil) with %d workers = %+v, want %+v", workers, counts, want)
+		}
+	}
+}
+
+// TestRun_GrepOrder tests that matches keep input order and line numbers
+func TestRun_GrepOrder(t *testing.T) {
+	var b strings.Builder
+	for i := range 5000 {
+		if i%1000 == 999 {
+			b.WriteString("needle\n")
+		} else {
+			b.WriteString("hay\n")
+		}
+	}
+	matches, err := Run(context.Background(), strings.NewReader(b.String()), 4, Grep(regexp.MustCompile("needle")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []int
+	for _, m := range matches {
+		got = append(got, m.Line)
+	}
+	if want := []int{1000, 2000, 3000, 4000, 5000}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Grep() lines = %v, want %v", got, want)
+	}
+}
+
+// TestRunFile_Compressed tests that compressed and plain files agree
+func TestRunFile_Compressed(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string][]byte{
+		"a.txt": []byte(sample),
+		"a.gz":  gzipped(t, sample),
+		"a.zst": zstded(t, sample),
+	}
+	var results []Counts
+	for name, data := range files {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		c, err := RunFile(context.Background(), path, 2, WordCount(nil))
+		if err != nil {
+			t.Fatalf("RunFile(%s) error = %v", name, err)
+		}
+		results = append(results, c)
+	}
+	if results[0] != results[1] || results[1] != results[2] {
+		t.Errorf("RunFile() results differ: %+v", results)
+	}
+
+	if _, err := RunFile(context.Background(), filepath.Join(dir, "missing"), 2, WordCount(nil)); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("RunFile(missing) error = %v, want os.ErrNotExist", err)
+	}
+}
+
+// TestRun_Cancel tests that a cancelled context stops the run
+func TestRun_Cancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := Run(ctx, strings.NewReader(sample), 2, WordCount(nil)); !errors.Is(err, context.Canceled) {
+		t.Errorf("Run() error = %v, want context.Canceled", err)
+	}
+}
+
+// TestRunFiles_Aggregate tests per-file results and their total
+func TestRunFiles_Aggregate(t *testing.T) {
+	dir := t.TempDir()
+	contents := map[string]string{
+		"a.txt":         "one two\nthree\n",
+		"sub/b.txt":     "four\n",
+		"sub/deep/c.gz": string(gzipped(t, "five six seven\n")),
+	}
+	for name, data := range contents {
+		path := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(path), 0o755)
+		if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	files, err := Files(dir, filepath.Join(dir, "a.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 3 {
+		t.Fatalf("Files() = %v, want 3 files", files)
+	}
+
+	total, perFile, err := RunFiles(context.Background(), files, 2, WordCount(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (Counts{Lines: 4, Words: 7, Bytes: 34}); total != want {
+		t.Errorf("total = %+v, want %+v", total, want)
+	}
+	if got := perFile[filepath.Join(dir, "sub/deep/c.gz")]; got.Words != 3 {
+		t.Errorf("c.gz words = %d, want 3", got.Words)
+	}
+	if got := perFile[filepath.Join(dir, "a.txt")]; got.Lines != 2 {
+		t.Errorf("a.txt lines = %d, want 2", got.Lines)
+	}
+}
+
+// TestRunFiles_Error tests that one bad file fails the run with its own
+// error
+func TestRunFiles_Error(t *testing.T) {
+	dir := t.TempDir()
+	good := filepath.Join(dir, "good.txt")
+	os.WriteFile(good, []byte(sample), 0o644)
+	bad := filepath.Join(dir, "bad.gz")
+	data := gzipped(t, sample)
+	os.WriteFile(bad, data[:len(data)/2], 0o644)
+
+	_, _, err := RunFiles(context.Background(), []string{good, bad, good}, 3, WordCount(nil))
+	if !errors.Is(err, io.ErrUnexpectedEOF) || !strings.Contains(err.Error(), "bad.gz") {
+		t.Errorf("RunFiles() error = %v, want io.ErrUnexpectedEOF naming bad.gz", err)
+	}
+}
@@ -0,0 +1,104 @@
+package ptext
+
+import (
+	"encoding/csv"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// Tokenizer splits a line of text into tokens. Implementations must be
+// safe for concurrent use, since every worker calls the same one.
+type Tokenizer interface {
+	Tokens(line string) []string
+}
+
+// TokenizerFunc adapts a function to Tokenizer.
+type TokenizerFunc func(line string) []string
+
+func (f TokenizerFunc) Tokens(line string) []string {
+	return f(line)
+}
+
+// Whitespace splits on runs of Unicode white space, like strings.Fields.
+var Whitespace Tokenizer = TokenizerFunc(strings.Fields)
+
+// Words returns runs of letters and digits, so punctuation never sticks
+// to a word: "don't stop-me" gives "don't", "stop", "me". An apostrophe
+// or underscore between two word characters stays inside the word.
+// This follows the spirit of Unicode word boundaries (UAX #29) without
+// its special cases for scripts written without spaces.
+var Words Tokenizer = TokenizerFunc(words)
+
+func words(line string) []string {
+	isWord := func(r rune) bool {
+		return unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.Is(unicode.Mn, r)
+	}
+	var out []string
+	start := -1
+	runes := []rune(line)
+	for i, r := range runes {
+		inside := isWord(r) ||
+			(start >= 0 && (r == '\'' || r == '’' || r == '_') && i+1 < len(runes) && isWord(runes[i+1]))
+		switch {
+		case inside && start < 0:
+			start = i
+		case !inside && start >= 0:
+			out = append(out, string(runes[start:i]))
+			start = -1
+		}
+	}
+	if start >= 0 {
+		out = append(out, string(runes[start:]))
+	}
+	return out
+}
+
+// Regexp returns the tokenizer whose tokens are the matches of re, such
+// as `\d+` for numbers or `[#@]\w+` for hashtags and mentions.
+func Regexp(re *regexp.Regexp) Tokenizer {
+	return TokenizerFunc(func(line string) []string {
+		return re.FindAllString(line, -1)
+	})
+}
+
+// CSV returns the tokenizer whose tokens are the fields of a line of
+// comma-separated (or sep-separated) values, with quoting as in RFC
+// 4180. Quoted fields may not span lines, since the engine splits its
+// input into lines first; a malformed line yields no tokens.
+func CSV(sep rune) Tokenizer {
+	return TokenizerFunc(func(line string) []string {
+		r := csv.NewReader(strings.NewReader(line))
+		r.Comma = sep
+		r.FieldsPerRecord = -1
+		r.LazyQuotes = true
+		fields, err := r.Read()
+		if err != nil {
+			return nil
+		}
+		return fields
+	})
+}
+
+// ParseTokenizer returns the tokenizer named by spec: "whitespace",
+// "words", "csv", "tsv", or "regexp:<expr>".
+func ParseTokenizer(spec string) (Tokenizer, error) {
+	switch {
+	case spec == "whitespace":
+		return Whitespace, nil
+	case spec == "words":
+		return Words, nil
+	case spec == "csv":
+		return CSV(','), nil
+	case spec == "tsv":
+		return CSV('\t'), nil
+	case strings.HasPrefix(spec, "regexp:"):
+		re, err := regexp.Compile(strings.TrimPrefix(spec, "regexp:"))
+		if err != nil {
+			return nil, err
+		}
+		return Regexp(re), nil
+	}
+	return nil, fmt.Errorf("ptext: unknown tokenizer %q", spec)
+}
@@ -0,0 +1,61 @@
+package ptext
+
+import (
+	"context"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// TestTokenizers tests each tokenizer on the same kinds of input
+func TestTokenizers(t *testing.T) {
+	tests := []struct {
+		name string
+		tok  Tokenizer
+		line string
+		want []string
+	}{
+		{"whitespace", Whitespace, "  don't stop-me now ", []string{"don't", "stop-me", "now"}},
+		{"words", Words, "don't stop-me, now!", []string{"don't", "stop", "me", "now"}},
+		{"words unicode", Words, "naïve café—über 42", []string{"naïve", "café", "über", "42"}},
+		{"words trailing quote", Words, "'quoted'", []string{"quoted"}},
+		{"regexp", Regexp(regexp.MustCompile(`#\w+`)), "go #golang and #concurrency", []string{"#golang", "#concurrency"}},
+		{"csv", CSV(','), `1,"Smith, Jane",,x`, []string{"1", "Smith, Jane", "", "x"}},
+		{"tsv", CSV('\t'), "a\tb c\td", []string{"a", "b c", "d"}},
+		{"empty", Words, "", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.tok.Tokens(tt.line); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Tokens(%q) = %q, want %q", tt.line, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestParseTokenizer tests tokenizer specs
+func TestParseTokenizer(t *testing.T) {
+	for _, spec := range []string{"whitespace", "words", "csv", "tsv", `regexp:\d+`} {
+		if _, err := ParseTokenizer(spec); err != nil {
+			t.Errorf("ParseTokenizer(%q) error = %v", spec, err)
+		}
+	}
+	for _, spec := range []string{"", "lines", "regexp:("} {
+		if _, err := ParseTokenizer(spec); err == nil {
+			t.Errorf("ParseTokenizer(%q) succeeded, want an error", spec)
+		}
+	}
+}
+
+// TestWordCount_Tokenizer tests counting CSV fields with the engine
+func TestWordCount_Tokenizer(t *testing.T) {
+	input := strings.Repeat("id,\"name, full\",age\n", 3000)
+	c, err := Run(context.Background(), strings.NewReader(input), 4, WordCount(CSV(',')))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Lines != 3000 || c.Words != 9000 {
+		t.Errorf("WordCount(CSV) = %+v, want 3000 lines and 9000 fields", c)
+	}
+}
@@ -0,0 +1,329 @@
+package pubsub
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// BenchmarkPublish_FanOut measures one topic with many subscribers, each
+// draining its channel. An op is one publish delivered to every
+// subscriber, or dropped if it could not keep up.
+//
+//	go test ./pubsub -run '^$' -bench FanOut -benchmem
+func BenchmarkPublish_FanOut(b *testing.B) {
+	for _, subs := range []int{1, 100, 10_000} {
+		b.Run(fmt.Sprintf("subs=%d", subs), func(b *testing.B) {
+			broker := NewBroker()
+			defer broker.Stop()
+
+			var received atomic.Int64
+			all := make([]*Subscriber, subs)
+			for i := range all {
+				all[i] = broker.Subscribe("wide")
+				go func(sub *Subscriber) {
+					for range sub.C {
+						received.Add(1)
+					}
+				}(all[i])
+			}
+			settled := func() int64 {
+				n := received.Load()
+				for _, sub := range all {
+					n += int64(sub.Dropped())
+				}
+				return n
+			}
+
+			b.ResetTimer()
+			for i := range b.N {
+				broker.Publish("wide", i)
+			}
+			for want := int64(b.N) * int64(subs); settled() < want; {
+				time.Sleep(100 * time.Microsecond)
+			}
+			b.StopTimer()
+
+			var dropped uint64
+			for _, sub := range all {
+				dropped += sub.Dropped()
+			}
+			b.ReportMetric(float64(dropped)/float64(b.N), "drops/op")
+		})
+	}
+}
+
+// BenchmarkBroadcast compares the broker with the two simplest ways to
+// broadcast in-process: a channel per subscriber, sent to in a loop, and
+// a log guarded by a sync.Cond that subscribers read at their own pace.
+// An op is one value delivered to every subscriber; none is dropped (the
+// broker's subscribers use Block). The difference per op is what topics,
+// patterns, sequence numbers and slow-subscriber policies cost.
+//
+//	go test ./pubsub -run '^$' -bench Broadcast -benchmem
+func BenchmarkBroadcast(b *testing.B) {
+	impls := []struct {
+		name string
+		run  func(b *testing.B, subs int)
+	}{
+		{"broker", broadcastBroker},
+		{"channels", broadcastChannels},
+		{"cond", broadcastCond},
+	}
+	for _, subs := range []int{1, 10, 100, 1000} {
+		for _, impl := range impls {
+			b.Run(fmt.Sprintf("subs=%d/%s", subs, impl.name), func(b *testing.B) {
+				impl.run(b, subs)
+			})
+		}
+	}
+}
+
+// broadcastBroker publishes b.N values to subs broker subscribers.
+func broadcastBroker(b *testing.B, subs int) {
+	broker := NewBroker(WithDefaultPolicy(Block))
+	defer broker.Stop()
+	var wg sync.WaitGroup
+	for range subs {
+		sub := broker.Subscribe("bench")
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for n := 0; n < b.N; n++ {
+				<-sub.C
+			}
+		}()
+	}
+
+	b.ResetTimer()
+	for i := range b.N {
+		broker.Publish("bench", i)
+	}
+	wg.Wait()
+}
+
+// broadcastChannels sends b.N values on each of subs channels, with the
+// same buffer as a broker subscriber.
+func broadcastChannels(b *testing.B, subs int) {
+	chans := make([]chan int, subs)
+	var wg sync.WaitGroup
+	for i := range chans {
+		chans[i] = make(chan int, defaultBuffer)
+		wg.Add(1)
+		go func(c <-chan int) {
+			defer wg.Done()
+			for range c {
+			}
+		}(chans[i])
+	}
+
+	b.ResetTimer()
+	for i := range b.N {
+		for _, c := range chans {
+			c <- i
+		}
+	}
+	for _, c := range chans {
+		close(c)
+	}
+	wg.Wait()
+}
+
+// broadcastCond appends b.N values to a shared log and wakes subs
+// readers, each of which follows the log with its own cursor.
+func broadcastCond(b *testing.B, subs int) {
+	var (
+		mu   sync.Mutex
+		cond = sync.NewCond(&mu)
+		log  = make([]int, 0, b.N)
+	)
+	var wg sync.WaitGroup
+	for range subs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for next := 0; next < b.N; {
+				mu.Lock()
+				for len(log) == next {
+					cond.Wait()
+				}
+				next = len(log) // take everything appended so far
+				mu.Unlock()
+			}
+		}()
+	}
+
+	b.ResetTimer()
+	for i := range b.N {
+		mu.Lock()
+		log = append(log, i)
+		mu.Unlock()
+		cond.Broadcast()
+	}
+	wg.Wait()
+}
+
+// publishAllocs sets up a broker whose subscribers to "orders.eu", exact
+// and by pattern, keep up, and returns it with a function to stop it.
+func publishAllocs(patterns bool) (*Broker, func()) {
+	broker := NewBroker()
+	topics := []string{"orders.eu"}
+	if patterns {
+		topics = append(topics, "orders.*", "orders.>", "#")
+	}
+	for _, topic := range topics {
+		sub := broker.Subscribe(topic, WithBuffer(1024))
+		go func() {
+			for range sub.C {
+			}
+		}()
+	}
+	broker.Publish("orders.eu", nil) // create the topic's queue
+	return broker, broker.Stop
+}
+
+// BenchmarkPublish_Allocs measures the publish path from Publish to the
+// subscribers' channels. With a payload that fits an interface without
+// boxing, such as a pointer, it allocates nothing; see TestPublish_Allocs.
+//
+//	go test ./pubsub -run '^$' -bench Publish_Allocs -benchmem
+func BenchmarkPublish_Allocs(b *testing.B) {
+	payload := &order{ID: 1}
+	for _, patterns := range []bool{false, true} {
+		b.Run(fmt.Sprintf("patterns=%v", patterns), func(b *testing.B) {
+			broker, stop := publishAllocs(patterns)
+			defer stop()
+			b.ReportAllocs()
+			b.ResetTimer()
+			for range b.N {
+				broker.Publish("orders.eu", payload)
+			}
+		})
+	}
+	b.Run("topic", func(b *testing.B) {
+		broker, stop := publishAllocs(false)
+		defer stop()
+		orders := NewTopic[*order](broker, "orders.eu")
+		b.ReportAllocs()
+		b.ResetTimer()
+		for range b.N {
+			orders.PublishCtx(context.Background(), payload)
+		}
+	})
+}
+
+// reading is a small payload, as from a sensor.
+type reading struct {
+	Sensor string  `json:"sensor"`
+	Value  float64 `json:"value"`
+}
+
+// BenchmarkRetention_GC measures a garbage collection with a million
+// small messages retained, in each kind of in-memory retention. An op is
+// one forced collection; pause-ns/op is the part of it that stopped the
+// program, and heap-MiB what the retention holds on to. Run it with
+// GOEXPERIMENT=arenas to measure chunks allocated in arenas.
+//
+//	go test ./pubsub -run '^$' -bench Retention_GC
+func BenchmarkRetention_GC(b *testing.B) {
+	const topics, perTopic = 16, 1 << 16
+	retentions := []struct {
+		name string
+		new  func() Retention
+	}{
+		{"memory", func() Retention { return NewMemoryRetention(perTopic) }},
+		{"chunked", func() Retention { return NewChunkedRetention(perTopic, 1<<20) }},
+	}
+	for _, tt := range retentions {
+		b.Run(tt.name, func(b *testing.B) {
+			r := tt.new()
+			runtime.GC()
+			var before runtime.MemStats
+			runtime.ReadMemStats(&before)
+			start := time.Now()
+			for seq := uint64(1); seq <= perTopic; seq++ {
+				for i := range topics {
+					name := fmt.Sprintf("sensors.%d", i)
+					r.Append(Message{Topic: name, Payload: reading{name, float64(seq)}, Seq: seq, Time: start})
+				}
+			}
+			runtime.GC()
+			var after runtime.MemStats
+			runtime.ReadMemStats(&after)
+
+			var gc debug.GCStats
+			debug.ReadGCStats(&gc)
+			paused := gc.PauseTotal
+			b.ResetTimer()
+			for range b.N {
+				runtime.GC()
+			}
+			b.StopTimer()
+			debug.ReadGCStats(&gc)
+			b.ReportMetric(float64(gc.PauseTotal-paused)/float64(b.N), "pause-ns/op")
+			b.ReportMetric(float64(after.HeapAlloc-before.HeapAlloc)/(1<<20), "heap-MiB")
+			runtime.KeepAlive(r)
+		})
+	}
+}
+
+// BenchmarkPublish_SlowSubscribers measures one topic whose subscribers
+// all read more slowly than it is published to, so every delivery waits
+// for room, with a pool of one dispatcher, the default pool, and one too
+// large to fill (a goroutine per subscriber falling behind). An op is one
+// publish delivered to every subscriber; none is dropped. goroutines is
+// the most the broker ran at once, besides the subscribers' own.
+//
+//	go test ./pubsub -run '^$' -bench SlowSubscribers -benchmem
+func BenchmarkPublish_SlowSubscribers(b *testing.B) {
+	const subs = 1000
+	for _, n := range []int{1, 4 * runtime.GOMAXPROCS(0), 1 << 20} {
+		b.Run(fmt.Sprintf("dispatchers=%d", n), func(b *testing.B) {
+			before := runtime.NumGoroutine()
+			broker := NewBroker(WithDispatchers(n), WithDeliveryTimeout(time.Minute))
+			defer broker.Stop()
+
+			var wg sync.WaitGroup
+			for range subs {
+				sub := broker.Subscribe("slow", WithPolicy(DropAfterTimeout), WithBuffer(1))
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for range b.N {
+						<-sub.C
+						time.Sleep(10 * time.Microsecond)
+					}
+				}()
+			}
+			var peak atomic.Int64
+			done := make(chan struct{})
+			go func() {
+				for {
+					select {
+					case <-done:
+						return
+					case <-time.After(100 * time.Microsecond):
+					}
+					if g := int64(runtime.NumGoroutine() - before - subs - 1); g > peak.Load() {
+						peak.Store(g)
+					}
+				}
+			}()
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := range b.N {
+				broker.Publish("slow", i)
+			}
+			wg.Wait()
+			b.StopTimer()
+			close(done)
+			b.ReportMetric(float64(peak.Load()), "goroutines")
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package pubsub
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestChunkedRetention tests keeping the last messages of a topic across
+// chunks, and releasing the chunks of evicted ones
+func TestChunkedRetention(t *testing.T) {
+	r := NewChunkedRetention(3, 16)
+	big := strings.Repeat("x", 40) // larger than a chunk
+	payloads := []any{"one", "two", 3, big, map[string]int{"five": 5}, "six"}
+	start := time.Now()
+	for i, p := range payloads {
+		m := Message{Topic: "t", Payload: p, Seq: uint64(i + 1), Time: start.Add(time.Duration(i) * time.Second)}
+		if err := r.Append(m); err != nil {
+			t.Fatalf("Append(%v) = %v", p, err)
+		}
+	}
+	if err := r.Append(Message{Topic: "t", Payload: func() {}, Seq: 7}); err == nil {
+		t.Error("Append(func) succeeded")
+	}
+
+	msgs, _ := r.Since("t", 0)
+	checkSeqs(t, msgs, 4, 5, 6)
+	want := []string{`"` + big + `"`, `{"five":5}`, `"six"`}
+	for i, m := range msgs {
+		if raw, ok := m.Payload.(json.RawMessage); !ok || string(raw) != want[i] {
+			t.Errorf("message %d has payload %#v, want %s", m.Seq, m.Payload, want[i])
+		}
+		if !m.Time.Equal(start.Add(time.Duration(m.Seq-1) * time.Second)) {
+			t.Errorf("message %d has time %v", m.Seq, m.Time)
+		}
+	}
+	msgs, _ = r.Since("t", 5)
+	checkSeqs(t, msgs, 6)
+	if last, _ := r.Last("t"); last != 6 {
+		t.Errorf("Last() = %d, want 6", last)
+	}
+	if n := r.topics["t"].chunks.len(); n > 3 {
+		t.Errorf("%d chunks left for 3 messages", n)
+	}
+}
+
+// TestChunkedRetention_Resume tests resuming from a ChunkedRetention
+func TestChunkedRetention_Resume(t *testing.T) {
+	b := NewBroker(WithRetention(NewChunkedRetention(100, 0)))
+	defer b.Stop()
+	for i := range 5 {
+		b.Publish("t", i)
+	}
+	c, cancel, err := b.Resume("t", "2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cancel()
+	b.Publish("t", 5)
+	msgs := recv(t, c, 4)
+	checkSeqs(t, msgs, 3, 4, 5, 6)
+	if raw, ok := msgs[0].Payload.(json.RawMessage); !ok || string(raw) != "2" {
+		t.Errorf("retained payload %#v, want raw JSON 2", msgs[0].Payload)
+	}
+}
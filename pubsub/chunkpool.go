@@ -0,0 +1,31 @@
+//go:build !goexperiment.arenas
+
+package pubsub
+
+// maxFreeChunks bounds the chunks a chunkPool keeps for reuse.
+const maxFreeChunks = 8
+
+// chunkPool allocates the chunks of a ChunkedRetention, reusing evicted
+// ones. Its owner serializes calls.
+type chunkPool struct {
+	size int
+	free [][]byte
+}
+
+// get returns an empty chunk with room for at least n bytes.
+func (p *chunkPool) get(n int) []byte {
+	if n <= p.size && len(p.free) > 0 {
+		c := p.free[len(p.free)-1]
+		p.free[len(p.free)-1] = nil
+		p.free = p.free[:len(p.free)-1]
+		return c[:0]
+	}
+	return make([]byte, 0, max(n, p.size))
+}
+
+// put gives back a chunk no message is in any more.
+func (p *chunkPool) put(c []byte) {
+	if cap(c) == p.size && len(p.free) < maxFreeChunks {
+		p.free = append(p.free, c)
+	}
+}
@@ -0,0 +1,40 @@
+//go:build goexperiment.arenas
+
+package pubsub
+
+import (
+	"arena"
+	"unsafe"
+)
+
+// chunkPool allocates the chunks of a ChunkedRetention in arenas, one
+// per chunk, so an evicted chunk is freed at once instead of by the
+// garbage collector. Its owner serializes calls.
+type chunkPool struct {
+	size   int
+	arenas map[*byte]*arena.Arena // by the chunk's first byte
+}
+
+// get returns an empty chunk with room for at least n bytes. One larger
+// than the pool's size is allocated on the heap.
+func (p *chunkPool) get(n int) []byte {
+	if n > p.size {
+		return make([]byte, 0, n)
+	}
+	if p.arenas == nil {
+		p.arenas = make(map[*byte]*arena.Arena)
+	}
+	a := arena.NewArena()
+	c := arena.MakeSlice[byte](a, 0, p.size)
+	p.arenas[unsafe.SliceData(c)] = a
+	return c
+}
+
+// put frees a chunk no message is in any more. Nothing may use it
+// afterwards.
+func (p *chunkPool) put(c []byte) {
+	if a, ok := p.arenas[unsafe.SliceData(c)]; ok {
+		delete(p.arenas, unsafe.SliceData(c))
+		a.Free()
+	}
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/arifmahmudrana/go-snippets/profiling"
+	"github.com/arifmahmudrana/go-snippets/rtmetrics"
+	"github.com/arifmahmudrana/go-snippets/transport"
+)
+
+// benchPayload is what the bench publishers send; subscribers measure
+// latency from Sent.
+type benchPayload struct {
+	Sent int64 `json:"sent"` // unix nanoseconds
+}
+
+// bench drives a running daemon with publishers and subscribers, each on
+// its own connection, and reports throughput and end-to-end latency.
+// Publishers and subscribers share this machine's clock, so run it on
+// the same host as the daemon or expect skew in the latencies.
+func bench(args []string) error {
+	fs := flag.NewFlagSet("bench", flag.ExitOnError)
+	addr := fs.String("addr", "localhost:7070", "broker daemon address, host:port or unix:/path/to.sock")
+	topic := fs.String("topic", "bench", "topic to publish to")
+	publishers := fs.Int("publishers", 4, "publisher connections")
+	subscribers := fs.Int("subscribers", 16, "subscriber connections")
+	rate := fs.Int("rate", 10000, "total messages per second across publishers")
+	duration := fs.Duration("duration", 10*time.Second, "how long to publish")
+	prof := profiling.Register(fs)
+	fs.Parse(args)
+	stop, err := startProfiles(prof)
+	if err != nil {
+		return err
+	}
+	defer stop()
+
+	var recvWG sync.WaitGroup
+	results := make([][]time.Duration, *subscribers)
+	subs := make([]*transport.Subscription, *subscribers)
+	for i := range subs {
+		c, err := transport.Dial(transport.ParseAddr(*addr))
+		if err != nil {
+			return err
+		}
+		defer c.Close()
+		if subs[i], err = c.Subscribe(*topic); err != nil {
+			return err
+		}
+
+		recvWG.Add(1)
+		go func(i int) {
+			defer recvWG.Done()
+			for m := range subs[i].C {
+				var p benchPayload
+				if json.Unmarshal(m.Payload.(json.RawMessage), &p) == nil {
+					results[i] = append(results[i], time.Since(time.Unix(0, p.Sent)))
+				}
+			}
+		}(i)
+	}
+
+	fmt.Printf("[BENCH] %d publishers, %d subscribers, %d msg/s for %v on %q\n",
+		*publishers, *subscribers, *rate, *duration, *topic)
+
+	// Each publisher sends its share in small batches every tick, which
+	// keeps the pace even without a timer per message.
+	const tick = 10 * time.Millisecond
+	perTick := float64(*rate) / float64(max(*publishers, 1)) / float64(time.Second/tick)
+	var sent atomic.Int64
+	var pubWG sync.WaitGroup
+	before, start := rtmetrics.Take(), time.Now()
+	deadline := start.Add(*duration)
+	for range *publishers {
+		c, err := transport.Dial(transport.ParseAddr(*addr))
+		if err != nil {
+			return err
+		}
+		defer c.Close()
+
+		pubWG.Add(1)
+		go func() {
+			defer pubWG.Done()
+			t := time.NewTicker(tick)
+			defer t.Stop()
+
+			owed := 0.0
+			for now := range t.C {
+				if now.After(deadline) {
+					return
+				}
+				for owed += perTick; owed >= 1; owed-- {
+					if c.Publish(*topic, benchPayload{Sent: time.Now().UnixNano()}) != nil {
+						return
+					}
+					sent.Add(1)
+				}
+			}
+		}()
+	}
+	pubWG.Wait()
+	elapsed, rt := time.Since(start), rtmetrics.Take().Since(before)
+
+	// give in-flight messages a moment, then end the subscriptions
+	time.Sleep(time.Second)
+	for _, s := range subs {
+		s.Close()
+	}
+	recvWG.Wait()
+
+	var all []time.Duration
+	for _, r := range results {
+		all = append(all, r...)
+	}
+	report(sent.Load(), int64(*subscribers), all, elapsed)
+	// this process's runtime, not the daemon's: time spent on GC or
+	// waiting to be scheduled here inflates the latencies
+	fmt.Printf("client      %v\n", rt)
+	return nil
+}
+
+// report prints throughput, loss and latency percentiles.
+func report(sent, subscribers int64, latencies []time.Duration, elapsed time.Duration) {
+	want := sent * subscribers
+	received := int64(len(latencies))
+	fmt.Printf("published   %d (%.0f msg/s)\n", sent, float64(sent)/elapsed.Seconds())
+	fmt.Printf("delivered   %d of %d (%.0f msg/s)\n", received, want, float64(received)/elapsed.Seconds())
+	if want > 0 {
+		fmt.Printf("lost        %.2f%%\n", 100*float64(want-received)/float64(want))
+	}
+	if received == 0 {
+		return
+	}
+
+	slices.Sort(latencies)
+	pct := func(p float64) time.Duration {
+		return latencies[min(int(p*float64(len(latencies))), len(latencies)-1)]
+	}
+	fmt.Printf("latency     p50 %v  p90 %v  p99 %v  max %v\n",
+		pct(0.50), pct(0.90), pct(0.99), latencies[len(latencies)-1])
+}
@@ -1,55 +1,124 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
-	"sync"
+	"log"
+	"os"
+	"strings"
 	"time"
 
+	"github.com/arifmahmudrana/go-snippets/debugserver"
+	"github.com/arifmahmudrana/go-snippets/profiling"
 	"github.com/arifmahmudrana/go-snippets/pubsub"
 )
 
+// usage describes the subcommands.
+const usage = `usage:
+  pubsub [-debug addr]                       run the in-process demo
+  pubsub serve [-addr :7070] [-retain n] [-data dir]
+                                             run a broker daemon
+  pubsub tail [-addr host:port] [-since seq] <topic>
+  pubsub pub [-addr host:port] <topic> <json>
+  pubsub bench [-addr host:port] [-topic t] [-publishers n] [-subscribers n]
+               [-rate msg/s] [-duration d]
+  pubsub top [-debug host:port] [-interval d] [-rows n] [-once]
+
+Addresses are host:port for TCP or unix:/path/to.sock for a Unix socket.
+top reads the daemon's debug server, so run serve with -debug for it.
+Every command also takes -cpuprofile, -memprofile and -trace file to
+profile itself. The broker of the demo and of serve marks its work as
+regions in the execution trace.
+`
+
 func main() {
-	// Create a new broker
-	broker := pubsub.NewBroker()
-
-	// Stop the broker after 10 seconds
-	go func() {
-		time.Sleep(10 * time.Second)
-		fmt.Println("[BROKER] Stopping broker...")
-		broker.Stop()
-	}()
-
-	var wg sync.WaitGroup
-	wg.Add(2)
-
-	// Start Subscriber 1 (listens to "news")
-	go func() {
-		defer wg.Done()
-		sub := broker.Subscribe("news")
-		fmt.Println("[SUB 1] Subscribed to 'news'")
-
-		for msg := range sub {
-			fmt.Printf("[SUB 1] Received: %s\n", msg.Payload)
+	if len(os.Args) > 1 {
+		var err error
+		switch cmd, args := os.Args[1], os.Args[2:]; cmd {
+		case "serve":
+			err = serve(args)
+		case "tail":
+			err = tail(args)
+		case "pub":
+			err = pub(args)
+		case "bench":
+			err = bench(args)
+		case "top":
+			err = top(args)
+		case "help", "-h", "-help", "--help":
+			fmt.Print(usage)
+			return
+		default:
+			if !strings.HasPrefix(cmd, "-") {
+				fmt.Fprint(os.Stderr, usage)
+				os.Exit(2)
+			}
+			demo()
+			return
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	demo()
+}
+
+// startProfiles starts the profiles prof asks for. The returned function
+// stops them, logging any failure to write them.
+func startProfiles(prof *profiling.Flags) (stop func(), err error) {
+	stopProf, err := prof.Start()
+	if err != nil {
+		return nil, err
+	}
+	return func() {
+		if err := stopProf(); err != nil {
+			log.Print(err)
 		}
+	}, nil
+}
 
-		fmt.Println("[SUB 1] Unsubscribed (channel closed)")
-	}()
+// demo runs a publisher and two subscribers against an in-process broker.
+func demo() {
+	debugAddr := flag.String("debug", "", "serve pprof, expvar, stats and health on this address (e.g. :6060)")
+	prof := profiling.Register(nil)
+	flag.Parse()
+	stop, err := startProfiles(prof)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer stop()
 
-	// Start Subscriber 2 (listens to "sports")
-	go func() {
-		defer wg.Done()
-		sub := broker.Subscribe("sports")
-		fmt.Println("[SUB 2] Subscribed to 'sports'")
+	// Create a new broker, marking its work in the execution trace if
+	// one is recorded
+	var opts []pubsub.BrokerOption
+	if prof.Trace != "" {
+		opts = append(opts, pubsub.WithTraceRegions())
+	}
+	broker := pubsub.NewBroker(opts...)
 
-		for msg := range sub {
-			fmt.Printf("[SUB 2] Received: %s\n", msg.Payload)
+	if *debugAddr != "" {
+		dbg, err := debugserver.Start(*debugAddr, debugserver.WithBroker(broker))
+		if err != nil {
+			log.Fatal(err)
 		}
+		defer dbg.Close(context.Background())
+		fmt.Printf("[DEBUG] Serving on http://%s/debug/pprof/\n", dbg.Addr())
+	}
 
-		fmt.Println("[SUB 2] Unsubscribed (channel closed)")
-	}()
+	// Subscriber 1 listens to "news". The broker runs the callback on its
+	// own goroutine, one message at a time.
+	unsubNews := broker.SubscribeFunc("news", func(msg pubsub.Message) {
+		fmt.Printf("[SUB 1] Received: %s\n", msg.Payload)
+	})
+	fmt.Println("[SUB 1] Subscribed to 'news'")
 
-	// Give subscribers a moment to start up
-	time.Sleep(100 * time.Millisecond)
+	// Subscriber 2 listens to "sports"
+	unsubSports := broker.SubscribeFunc("sports", func(msg pubsub.Message) {
+		fmt.Printf("[SUB 2] Received: %s\n", msg.Payload)
+	})
+	fmt.Println("[SUB 2] Subscribed to 'sports'")
 
 	// Start Publisher
 	fmt.Println("[PUB] Publishing messages...")
@@ -57,7 +126,15 @@ func main() {
 	broker.Publish("sports", "Local team wins championship!")
 	broker.Publish("news", "New technology announced")
 
-	// Wait for subscribers to finish (which happens when broker stops)
-	wg.Wait()
+	// Give the deliveries a moment, then shut down. Each unsubscribe
+	// returns only after its callback has finished.
+	time.Sleep(time.Second)
+	unsubNews()
+	fmt.Println("[SUB 1] Unsubscribed")
+	unsubSports()
+	fmt.Println("[SUB 2] Unsubscribed")
+
+	fmt.Println("[BROKER] Stopping broker...")
+	broker.Stop()
 	fmt.Println("[MAIN] Application finished.")
 }
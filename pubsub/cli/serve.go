@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"expvar"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/arifmahmudrana/go-snippets/debugserver"
+	"github.com/arifmahmudrana/go-snippets/httpbridge"
+	"github.com/arifmahmudrana/go-snippets/ingest"
+	"github.com/arifmahmudrana/go-snippets/profiling"
+	"github.com/arifmahmudrana/go-snippets/pubsub"
+	"github.com/arifmahmudrana/go-snippets/transport"
+	"github.com/arifmahmudrana/go-snippets/wal"
+)
+
+// serve runs a broker that remote clients reach over TCP or a Unix
+// socket, until interrupted.
+func serve(args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := fs.String("addr", ":7070", "listen address, host:port or unix:/path/to.sock")
+	retain := fs.Int("retain", 1000, "messages retained per topic for tail -since (0 disables)")
+	dataDir := fs.String("data", "", "keep retained messages in a write-ahead log in this directory, so they and the topics' sequence numbers survive a restart")
+	events := fs.Int("events", 0, "keep the last n broker events for /debug/pubsub/trace (0 disables)")
+	debugAddr := fs.String("debug", "", "serve pprof, expvar, stats, trace, limits and health on this address")
+	maxSubs := fs.Int("max-subs", 0, "most subscribers per topic (0 for no limit)")
+	maxTopics := fs.Int("max-topics", 0, "most topics with subscribers (0 for no limit)")
+	httpAddr := fs.String("http", "", "accept messages as POST /topics/{topic} or /publish/{topic} with a JSON body, and stream them from GET /subscribe/{topic}, on this address")
+	deadLetter := fs.String("dead-letter", "", "publish dropped messages, and those sent to topics without subscribers, to this topic")
+	origin := fs.String("allow-origin", "", "let pages from this origin (or *) use the -http endpoints")
+	policy := fs.String("policy", "drop-after-timeout", "what to do when a subscriber falls behind: drop-after-timeout, block, drop-oldest or drop-newest")
+	prof := profiling.Register(fs)
+	fs.Parse(args)
+	stop, err := startProfiles(prof)
+	if err != nil {
+		return err
+	}
+	defer stop()
+
+	p, err := pubsub.ParsePolicy(*policy)
+	if err != nil {
+		return err
+	}
+	opts := []pubsub.BrokerOption{
+		pubsub.WithLimits(pubsub.Limits{MaxSubscribers: *maxSubs, MaxTopics: *maxTopics}),
+		pubsub.WithDefaultPolicy(p),
+	}
+	switch {
+	case *retain > 0 && *dataDir != "":
+		s, err := wal.OpenStore(*dataDir, wal.WithSyncPolicy(wal.SyncInterval))
+		if err != nil {
+			return err
+		}
+		defer s.Close()
+		opts = append(opts, pubsub.WithRetention(pubsub.NewStoreRetention(s, pubsub.WithStoreLimit(*retain))))
+	case *retain > 0:
+		opts = append(opts, pubsub.WithRetention(pubsub.NewMemoryRetention(*retain)))
+	}
+	if *events > 0 {
+		opts = append(opts, pubsub.WithTrace(*events))
+	}
+	if *deadLetter != "" {
+		opts = append(opts, pubsub.WithDeadLetter(*deadLetter))
+	}
+	if prof.Trace != "" {
+		opts = append(opts, pubsub.WithTraceRegions())
+	}
+	broker := pubsub.NewBroker(opts...)
+	defer broker.Stop()
+	expvar.Publish("pubsub", broker.Var())
+
+	if *debugAddr != "" {
+		dbg, err := debugserver.Start(*debugAddr, debugserver.WithBroker(broker))
+		if err != nil {
+			return err
+		}
+		defer dbg.Close(context.Background())
+		fmt.Printf("[DEBUG] Serving on http://%s/debug/pprof/\n", dbg.Addr())
+	}
+
+	network, address := transport.ParseAddr(*addr)
+	srv, err := transport.Listen(network, address, broker)
+	if err != nil {
+		return err
+	}
+	defer srv.Close()
+	fmt.Printf("[BROKER] Listening on %s\n", srv.Addr())
+
+	if *httpAddr != "" {
+		ln, err := net.Listen("tcp", *httpAddr)
+		if err != nil {
+			return err
+		}
+		mux := http.NewServeMux()
+		ingest.New(broker).Mount(mux)
+		httpbridge.New(broker, httpbridge.WithAllowOrigin(*origin)).Mount(mux)
+		hs := &http.Server{Handler: mux}
+		go hs.Serve(ln)
+		defer hs.Close()
+		fmt.Printf("[HTTP] Accepting POST http://%s/topics/{topic}, streaming GET /subscribe/{topic}\n", ln.Addr())
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	<-ctx.Done()
+	fmt.Println("[BROKER] Shutting down...")
+	return nil
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/arifmahmudrana/go-snippets/profiling"
+	"github.com/arifmahmudrana/go-snippets/pubsub"
+	"github.com/arifmahmudrana/go-snippets/transport"
+)
+
+// parseArgs parses flags that may appear before or after the positional
+// arguments, so both "tail -since 5 orders" and "tail orders -since 5"
+// work.
+func parseArgs(fs *flag.FlagSet, args []string) []string {
+	var positional []string
+	for {
+		fs.Parse(args)
+		if fs.NArg() == 0 {
+			return positional
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+}
+
+// tail prints every message on a topic of a running daemon.
+func tail(args []string) error {
+	fs := flag.NewFlagSet("tail", flag.ExitOnError)
+	addr := fs.String("addr", "localhost:7070", "broker daemon address, host:port or unix:/path/to.sock")
+	since := fs.String("since", "", "replay retained messages after this seq (0 for all) before live ones")
+	prof := profiling.Register(fs)
+	pos := parseArgs(fs, args)
+	if len(pos) != 1 {
+		return errors.New("usage: pubsub tail [-addr host:port] [-since seq] <topic>")
+	}
+	stop, err := startProfiles(prof)
+	if err != nil {
+		return err
+	}
+	defer stop()
+
+	c, err := transport.Dial(transport.ParseAddr(*addr))
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	var opts []transport.SubscribeOption
+	if *since != "" {
+		opts = append(opts, transport.Since(*since))
+	}
+	sub, err := c.Subscribe(pos[0], opts...)
+	if err != nil {
+		return err
+	}
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	for {
+		select {
+		case m, ok := <-sub.C:
+			if !ok {
+				return c.Err()
+			}
+			printMessage(os.Stdout, m)
+		case <-interrupt:
+			return nil
+		}
+	}
+}
+
+// printMessage writes a header line and the indented JSON payload.
+func printMessage(w io.Writer, m pubsub.Message) {
+	fmt.Fprintf(w, "%s  %s  seq=%d\n", m.Time.Local().Format(time.RFC3339Nano), m.Topic, m.Seq)
+
+	raw, _ := m.Payload.(json.RawMessage)
+	var buf bytes.Buffer
+	if json.Indent(&buf, raw, "  ", "  ") != nil {
+		buf.Reset()
+		buf.Write(raw)
+	}
+	fmt.Fprintf(w, "  %s\n\n", buf.Bytes())
+}
+
+// pub publishes one JSON payload to a running daemon.
+func pub(args []string) error {
+	fs := flag.NewFlagSet("pub", flag.ExitOnError)
+	addr := fs.String("addr", "localhost:7070", "broker daemon address, host:port or unix:/path/to.sock")
+	prof := profiling.Register(fs)
+	pos := parseArgs(fs, args)
+	if len(pos) != 2 {
+		return errors.New("usage: pubsub pub [-addr host:port] <topic> <json>")
+	}
+	stop, err := startProfiles(prof)
+	if err != nil {
+		return err
+	}
+	defer stop()
+	if !json.Valid([]byte(pos[1])) {
+		return fmt.Errorf("payload is not valid JSON: %s", pos[1])
+	}
+
+	c, err := transport.Dial(transport.ParseAddr(*addr))
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	return c.Publish(pos[0], json.RawMessage(pos[1]))
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"slices"
+	"text/tabwriter"
+	"time"
+
+	"github.com/arifmahmudrana/go-snippets/profiling"
+	"github.com/arifmahmudrana/go-snippets/pubsub"
+	"github.com/arifmahmudrana/go-snippets/ratetrack"
+)
+
+// clearScreen moves the cursor home and clears the terminal.
+const clearScreen = "\x1b[H\x1b[2J"
+
+// topSnapshot is what top reads from a daemon's debug server at once.
+type topSnapshot struct {
+	at     time.Time
+	totals pubsub.Stats
+	rates  map[string]ratetrack.Snapshot
+	queues map[string]int
+	subs   []pubsub.SubscriberStats
+}
+
+// top shows a running daemon's broker as a dashboard, redrawn in place
+// like redis-cli --stat: the totals and how fast they grow, the busiest
+// topics, and the subscribers falling behind. It reads the daemon's
+// debug server, so the daemon must run with -debug.
+func top(args []string) error {
+	fs := flag.NewFlagSet("top", flag.ExitOnError)
+	debugAddr := fs.String("debug", "localhost:6060", "the daemon's -debug address")
+	interval := fs.Duration("interval", time.Second, "how often to refresh")
+	rows := fs.Int("rows", 10, "most topics and subscribers to list")
+	once := fs.Bool("once", false, "print one snapshot, without clearing the screen, and exit")
+	prof := profiling.Register(fs)
+	fs.Parse(args)
+	stop, err := startProfiles(prof)
+	if err != nil {
+		return err
+	}
+	defer stop()
+
+	client := &http.Client{Timeout: max(*interval, time.Second)}
+	base := "http://" + *debugAddr + "/debug/stats/"
+	prev, err := fetchTop(client, base)
+	if err != nil {
+		return err
+	}
+	if *once {
+		return renderTop(os.Stdout, *debugAddr, prev, nil, *rows)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+	t := time.NewTicker(*interval)
+	defer t.Stop()
+	var buf bytes.Buffer
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-t.C:
+		}
+		buf.Reset()
+		buf.WriteString(clearScreen)
+		cur, err := fetchTop(client, base)
+		if err != nil {
+			// the daemon may be restarting: keep trying
+			fmt.Fprintf(&buf, "pubsub top %s: %v\n", *debugAddr, err)
+		} else {
+			renderTop(&buf, *debugAddr, cur, prev, *rows)
+			prev = cur
+		}
+		os.Stdout.Write(buf.Bytes())
+	}
+}
+
+// fetchTop reads the broker's stats from the debug server at base.
+func fetchTop(client *http.Client, base string) (*topSnapshot, error) {
+	s := &topSnapshot{at: time.Now()}
+	for name, v := range map[string]any{
+		"broker_totals":      &s.totals,
+		"broker":             &s.rates,
+		"broker_queues":      &s.queues,
+		"broker_subscribers": &s.subs,
+	} {
+		resp, err := client.Get(base + name)
+		if err != nil {
+			return nil, err
+		}
+		err = json.NewDecoder(resp.Body).Decode(v)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("%s: %s (is the daemon running with -debug?)", base+name, resp.Status)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", base+name, err)
+		}
+	}
+	return s, nil
+}
+
+// topicRow is one line of the topics table. A pattern has subscribers
+// but is never published to.
+type topicRow struct {
+	name    string
+	rate    float64
+	total   int64
+	queued  int
+	subs    int
+	dropped uint64
+}
+
+// renderTop writes the dashboard for cur. With prev, the snapshot
+// before it, the totals come with their rates since.
+func renderTop(w io.Writer, addr string, cur, prev *topSnapshot, rows int) error {
+	rows = max(rows, 1)
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	st := cur.totals
+	fmt.Fprintf(w, "pubsub top  %s  %s\n\n", addr, cur.at.Format("15:04:05"))
+	fmt.Fprintf(w, "topics %d  subscribers %d  queued %d  retention errors %d\n",
+		st.Topics, st.Subscribers, st.Queued, st.RetentionErrors)
+	rate := func(now, before uint64) string {
+		if prev == nil || now < before { // the daemon restarted
+			return ""
+		}
+		secs := cur.at.Sub(prev.at).Seconds()
+		return fmt.Sprintf(" (%.0f/s)", float64(now-before)/secs)
+	}
+	var was pubsub.Stats
+	if prev != nil {
+		was = prev.totals
+	}
+	fmt.Fprintf(w, "published %d%s  delivered %d%s  dropped %d%s  dead-lettered %d%s\n\n",
+		st.Published, rate(st.Published, was.Published),
+		st.Delivered, rate(st.Delivered, was.Delivered),
+		st.Dropped, rate(st.Dropped, was.Dropped),
+		st.DeadLettered, rate(st.DeadLettered, was.DeadLettered))
+
+	topics := map[string]*topicRow{}
+	row := func(name string) *topicRow {
+		r, ok := topics[name]
+		if !ok {
+			r = &topicRow{name: name}
+			topics[name] = r
+		}
+		return r
+	}
+	for name, r := range cur.rates {
+		row(name).rate, row(name).total = r.WindowRate, r.Total
+	}
+	for name, n := range cur.queues {
+		row(name).queued = n
+	}
+	for _, s := range cur.subs {
+		r := row(s.Topic)
+		r.subs++
+		r.dropped += s.Dropped
+	}
+	sorted := make([]*topicRow, 0, len(topics))
+	for _, r := range topics {
+		sorted = append(sorted, r)
+	}
+	slices.SortFunc(sorted, func(a, b *topicRow) int {
+		return cmp.Or(cmp.Compare(b.rate, a.rate), cmp.Compare(b.queued, a.queued), cmp.Compare(a.name, b.name))
+	})
+	fmt.Fprintln(tw, "TOPIC\tMSG/S\tTOTAL\tQUEUED\tSUBS\tDROPPED")
+	for _, r := range sorted[:min(rows, len(sorted))] {
+		fmt.Fprintf(tw, "%s\t%.1f\t%d\t%d\t%d\t%d\n", r.name, r.rate, r.total, r.queued, r.subs, r.dropped)
+	}
+	if len(sorted) > rows {
+		fmt.Fprintf(tw, "(%d more)\n", len(sorted)-rows)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	// a subscriber is slow once it has dropped messages or has let its
+	// buffer fill halfway
+	var slow []pubsub.SubscriberStats
+	for _, s := range cur.subs {
+		if s.Dropped > 0 || s.Pending > 0 && 2*s.Pending >= s.Cap {
+			slow = append(slow, s)
+		}
+	}
+	fmt.Fprintf(w, "\nslow subscribers: %d\n", len(slow))
+	if len(slow) == 0 {
+		return nil
+	}
+	slices.SortFunc(slow, func(a, b pubsub.SubscriberStats) int {
+		return cmp.Or(cmp.Compare(b.Dropped, a.Dropped), cmp.Compare(b.Pending, a.Pending), cmp.Compare(a.ID, b.ID))
+	})
+	fmt.Fprintln(tw, "ID\tTOPIC\tGROUP\tPENDING\tDROPPED\tLAST DELIVERY")
+	for _, s := range slow[:min(rows, len(slow))] {
+		last := "never"
+		if !s.LastDelivered.IsZero() {
+			last = cur.at.Sub(s.LastDelivered).Round(time.Millisecond).String() + " ago"
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%d/%d\t%d\t%s\n", s.ID, s.Topic, cmp.Or(s.Group, "-"), s.Pending, s.Cap, s.Dropped, last)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,85 @@
+package pubsub
+
+import (
+	"strings"
+	"time"
+)
+
+// DeadLetter is published on the dead-letter topic (see WithDeadLetter)
+// for a message that did not reach a subscriber, so it can be audited
+// or published again.
+type DeadLetter struct {
+	// Message is the message as published, with its original Topic,
+	// Seq and Time.
+	Message Message
+	// Reason says why it was given up on: the text of
+	// ErrSubscriberSlow, or of ErrNoSubscribers if nobody was
+	// subscribed to its topic.
+	Reason string
+	// Tags are the tags of the subscriber that dropped it, if any.
+	Tags []string
+	// Time is when it was given up on.
+	Time time.Time
+}
+
+// WithDeadLetter publishes a DeadLetter on topic for each message a
+// subscriber's Policy drops, and for each message published to a topic
+// nobody is subscribed to, except requests (see Request) and reserved
+// topics starting with "$". Messages on topic itself are never
+// dead-lettered.
+//
+// Dead letters are published without waiting: while topic's publish
+// queue is full, or once the broker is stopping, they are discarded.
+// Stats counts those published. The topic counts as used from the
+// start, so OnTopicCreate hooks do not fire for it.
+func WithDeadLetter(topic string) BrokerOption {
+	return func(b *Broker) {
+		b.deadLetterTopic = topic
+		b.metrics.deadLetter = b.deadLetter
+		b.hooks.known.Store(topic, struct{}{})
+	}
+}
+
+// deadLetter publishes a DeadLetter for m, dropped by sub, or by the
+// broker if sub is nil, because of err. It does not wait, so it may be
+// called from the run loop.
+func (b *Broker) deadLetter(m Message, sub *Subscriber, err error) {
+	if m.Topic == b.deadLetterTopic {
+		return
+	}
+	m.req = nil // only the subscribers it reached may answer it
+	d := DeadLetter{Message: m, Reason: err.Error(), Time: time.Now()}
+	if sub != nil {
+		d.Tags = sub.Tags()
+	}
+	if b.tryEnqueue(Message{Topic: b.deadLetterTopic, Payload: d}) {
+		b.metrics.deadLettered.Add(1)
+	}
+}
+
+// unroutable reports whether a message published to topic with no
+// subscribers is dead-lettered.
+func (b *Broker) unroutable(m Message) bool {
+	return b.deadLetterTopic != "" && m.req == nil && !strings.HasPrefix(m.Topic, "$")
+}
+
+// tryEnqueue is enqueue without waiting: it reports false if m's topic
+// queue is full or the broker is stopping. It skips the creation hooks,
+// which may call the broker, so m's topic must already be in use.
+func (b *Broker) tryEnqueue(m Message) bool {
+	b.publishing.Add(1)
+	defer b.publishing.Add(-1)
+	select {
+	case <-b.stopCh:
+		return false
+	default:
+	}
+	q := b.inbound.queue(m.Topic)
+	select {
+	case q.slots <- struct{}{}:
+	default:
+		return false
+	}
+	b.inbound.add(q, m)
+	return true
+}
@@ -0,0 +1,72 @@
+package pubsub
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+)
+
+// TestWithDeadLetter tests which undelivered messages are dead-lettered,
+// and with what
+func TestWithDeadLetter(t *testing.T) {
+	b := NewBroker(WithDeadLetter("dead"))
+	defer b.Stop()
+	dead := b.Subscribe("dead")
+	b.Subscribe("t", WithPolicy(DropNewest), WithBuffer(1), WithTag("slow"))
+
+	b.Publish("nobody", "lost")
+	b.Publish("$internal", "ignored")
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if _, err := b.Request(ctx, "nobody.answers", "ping"); err == nil {
+		t.Error("Request() with no responders succeeded")
+	}
+	b.Publish("t", 1)
+	b.Publish("t", 2) // dropped
+
+	tests := []struct {
+		topic  string
+		seq    uint64
+		reason error
+		tags   []string
+	}{
+		{"nobody", 1, ErrNoSubscribers, nil},
+		{"t", 2, ErrSubscriberSlow, []string{"slow"}},
+	}
+	for i, m := range recv(t, dead.C, len(tests)) {
+		d, ok := m.Payload.(DeadLetter)
+		if !ok {
+			t.Fatalf("dead letter payload %T, want DeadLetter", m.Payload)
+		}
+		tt := tests[i]
+		if d.Message.Topic != tt.topic || d.Message.Seq != tt.seq || d.Reason != tt.reason.Error() || !slices.Equal(d.Tags, tt.tags) {
+			t.Errorf("dead letter %d = %+v, want %s #%d, %v, tags %v", i, d, tt.topic, tt.seq, tt.reason, tt.tags)
+		}
+		if d.Time.Before(d.Message.Time) {
+			t.Errorf("dead letter %d given up on at %v, before it was published at %v", i, d.Time, d.Message.Time)
+		}
+	}
+	if st := b.Stats(); st.DeadLettered != 2 {
+		t.Errorf("Stats().DeadLettered = %d, want 2", st.DeadLettered)
+	}
+}
+
+// TestWithDeadLetter_NoLoop tests that dead letters that cannot be
+// delivered themselves are not dead-lettered again
+func TestWithDeadLetter_NoLoop(t *testing.T) {
+	b := NewBroker(WithDeadLetter("dead"))
+	defer b.Stop()
+	stuck := b.Subscribe("#", WithPolicy(DropNewest), WithBuffer(0))
+
+	for i := range 5 {
+		b.Publish("t", i) // dropped by stuck, then dead-lettered and dropped again
+	}
+	for deadline := time.Now().Add(time.Second); stuck.Dropped() < 10 && time.Now().Before(deadline); {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if st := b.Stats(); st.DeadLettered != 5 || st.Dropped != 10 {
+		t.Errorf("Stats() = %+v, want 5 dead-lettered, 10 dropped", st)
+	}
+}
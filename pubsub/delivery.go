@@ -0,0 +1,270 @@
+package pubsub
+
+import (
+	"fmt"
+	"time"
+)
+
+// Policy says what the broker does with a message for a subscriber whose
+// buffer is full.
+type Policy int
+
+const (
+	// DropAfterTimeout queues the message and drops it if the subscriber
+	// does not take it within the delivery timeout (see
+	// WithDeliveryTimeout). The broker moves on meanwhile. This is the
+	// default.
+	DropAfterTimeout Policy = iota
+
+	// Block waits for the subscriber to take the message, holding up the
+	// broker: nothing else is delivered, on any topic, until it does,
+	// and publishers block once their queues fill up. Nothing is
+	// dropped, but a Block subscriber that stops reading stalls the
+	// broker until it is unsubscribed with Unsubscribe (or the function
+	// SubscribeFunc returns).
+	Block
+
+	// DropOldest discards the oldest message in the buffer to make room,
+	// so the subscriber always sees the most recent ones.
+	DropOldest
+
+	// DropNewest discards the message, keeping what is in the buffer.
+	DropNewest
+)
+
+var policyNames = [...]string{"drop-after-timeout", "block", "drop-oldest", "drop-newest"}
+
+func (p Policy) String() string {
+	if p < 0 || int(p) >= len(policyNames) {
+		return fmt.Sprintf("Policy(%d)", int(p))
+	}
+	return policyNames[p]
+}
+
+// ParsePolicy returns the policy with name, as printed by String.
+func ParsePolicy(name string) (Policy, error) {
+	for p, n := range policyNames {
+		if n == name {
+			return Policy(p), nil
+		}
+	}
+	return 0, fmt.Errorf("pubsub: unknown delivery policy %q", name)
+}
+
+// defaultBuffer is the capacity of a subscriber's channel.
+const defaultBuffer = 10
+
+// WithPolicy sets what happens to messages the subscriber has no room
+// for (default the broker's, see WithDefaultPolicy). Whatever the
+// policy, a subscriber gets its messages in the order they were
+// published; dropped ones are counted by Dropped.
+func WithPolicy(p Policy) SubscribeOption {
+	return func(s *Subscriber) {
+		s.policy = p
+	}
+}
+
+// WithBuffer sets the capacity of the subscriber's channel (default 10).
+// A larger buffer absorbs longer bursts before the policy applies.
+func WithBuffer(n int) SubscribeOption {
+	return func(s *Subscriber) {
+		s.buffer = max(n, 0)
+	}
+}
+
+// WithOnDrop calls fn for every message the subscriber's Policy
+// discards, with why, as in DeliveryError. Like an Observer's functions,
+// fn is called on the broker's own goroutines, so it must be quick and
+// must not call the broker.
+func WithOnDrop(fn func(m Message, err error)) SubscribeOption {
+	return func(s *Subscriber) {
+		s.onDrop = fn
+	}
+}
+
+// WithDefaultPolicy sets the policy of subscriptions made without
+// WithPolicy (default DropAfterTimeout).
+func WithDefaultPolicy(p Policy) BrokerOption {
+	return func(b *Broker) {
+		b.policy = p
+	}
+}
+
+// WithDeliveryTimeout sets how long DropAfterTimeout waits for a slow
+// subscriber before dropping a message (default 1s).
+func WithDeliveryTimeout(d time.Duration) BrokerOption {
+	return func(b *Broker) {
+		b.deliveryTimeout = d
+	}
+}
+
+// queued is a message waiting for room in a DropAfterTimeout
+// subscriber's buffer.
+type queued struct {
+	msg      Message
+	deadline time.Time
+}
+
+// offer hands m to the subscriber according to its policy. It reports
+// true if the caller must have a dispatcher run pump, for messages that
+// have to wait. stop gives up a Block delivery when the broker
+// stops.
+func (s *Subscriber) offer(m Message, timeout time.Duration, stop <-chan struct{}) (pump bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.closed {
+		return false
+	}
+
+	switch s.policy {
+	case Block:
+		if s.trySend(m) {
+			return false
+		}
+		select {
+		case s.ch <- m:
+			s.delivered(m)
+		case <-s.quit:
+		case <-stop:
+			s.drop(m, ErrBrokerClosed)
+		}
+	case DropNewest:
+		if !s.trySend(m) {
+			s.drop(m, ErrSubscriberSlow)
+		}
+	case DropOldest:
+		for !s.trySend(m) {
+			if cap(s.ch) == 0 {
+				s.drop(m, ErrSubscriberSlow) // nothing older to make room
+				break
+			}
+			select {
+			case old := <-s.ch:
+				s.drop(old, ErrSubscriberSlow)
+			default: // the consumer just made room
+			}
+		}
+	default:
+		s.qmu.Lock()
+		defer s.qmu.Unlock()
+		// messages already waiting go first
+		if len(s.queue) == 0 && s.trySend(m) {
+			return false
+		}
+		s.queue = append(s.queue, queued{msg: m, deadline: time.Now().Add(timeout)})
+		if s.pumping {
+			return false
+		}
+		s.pumping = true
+		return true
+	}
+	return false
+}
+
+// pump delivers the subscriber's queued messages in order, dropping
+// those whose deadline passes first, until the queue is empty or the
+// subscription closes. yield, if not nil, is called before each. It
+// reports true, leaving the rest queued, once it has waited slice for
+// room and the message waiting is not due to be dropped yet, for the
+// dispatcher to call it again later.
+func (s *Subscriber) pump(yield func(), slice time.Duration) (again bool) {
+	until := time.Now().Add(slice)
+	for {
+		s.qmu.Lock()
+		if len(s.queue) == 0 {
+			s.pumping = false
+			s.qmu.Unlock()
+			return false
+		}
+		q := s.queue[0]
+		s.qmu.Unlock()
+
+		if yield != nil {
+			yield()
+		}
+		done, ok := s.sendBy(q, until)
+		if !ok {
+			// closed: what is left will never be delivered
+			s.qmu.Lock()
+			s.queue, s.pumping = nil, false
+			s.qmu.Unlock()
+			return false
+		}
+		if !done {
+			return true
+		}
+		s.qmu.Lock()
+		s.queue[0] = queued{}
+		s.queue = s.queue[1:]
+		s.qmu.Unlock()
+	}
+}
+
+// sendBy sends q.msg, or drops it once its deadline passes, waiting
+// until then at the latest. It reports whether it did either, and
+// false for ok if the subscription is closed.
+func (s *Subscriber) sendBy(q queued, until time.Time) (done, ok bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.closed {
+		return false, false
+	}
+	if s.trySend(q.msg) {
+		return true, true
+	}
+
+	expires := !until.Before(q.deadline)
+	if !expires {
+		q.deadline = until
+	}
+	t := time.NewTimer(time.Until(q.deadline))
+	defer t.Stop()
+	select {
+	case s.ch <- q.msg:
+		s.delivered(q.msg)
+	case <-s.quit:
+		return false, false
+	case <-t.C:
+		if !expires {
+			return false, true
+		}
+		s.drop(q.msg, ErrSubscriberSlow)
+	}
+	return true, true
+}
+
+// trySend hands m over if C has room, without blocking. Caller holds mu
+// for reading, and has checked the subscriber is not closed.
+func (s *Subscriber) trySend(m Message) bool {
+	select {
+	case s.ch <- m:
+		s.delivered(m)
+		return true
+	default:
+		return false
+	}
+}
+
+// delivered records that m was handed to C.
+func (s *Subscriber) delivered(m Message) {
+	s.lastDelivered.Store(time.Now().UnixNano())
+	s.metrics.delivered.Add(1)
+	s.trace.record(TraceDeliver, m.Topic, m.Seq, s.id)
+}
+
+// drop records that m was discarded, and why.
+func (s *Subscriber) drop(m Message, err error) {
+	s.lastErr.Store(&DeliveryError{Topic: m.Topic, Seq: m.Seq, Err: err})
+	s.dropped.Add(1)
+	s.metrics.dropped.Add(1)
+	if fn := s.metrics.observer.OnDrop; fn != nil {
+		fn(m, s, err)
+	}
+	if s.onDrop != nil {
+		s.onDrop(m, err)
+	}
+	if fn := s.metrics.deadLetter; fn != nil {
+		fn(m, s, err)
+	}
+	s.trace.record(TraceDrop, m.Topic, m.Seq, s.id)
+}
@@ -0,0 +1,130 @@
+package pubsub
+
+import (
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// received reads what sub has buffered.
+func received(sub *Subscriber) []int {
+	var got []int
+	for range sub.Pending() {
+		got = append(got, (<-sub.C).Payload.(int))
+	}
+	return got
+}
+
+// TestPolicy tests what each policy does with messages a subscriber has
+// no room for, and that WithOnDrop hears of those it discards
+func TestPolicy(t *testing.T) {
+	tests := []struct {
+		policy      Policy
+		want        []int
+		wantDropped []int
+	}{
+		{DropAfterTimeout, []int{1, 2}, []int{3, 4, 5}},
+		{DropNewest, []int{1, 2}, []int{3, 4, 5}},
+		{DropOldest, []int{4, 5}, []int{1, 2, 3}},
+	}
+	for _, tt := range tests {
+		b := NewBroker(WithDeliveryTimeout(10 * time.Millisecond))
+		var mu sync.Mutex
+		var dropped []int
+		onDrop := func(m Message, err error) {
+			mu.Lock()
+			dropped = append(dropped, m.Payload.(int))
+			mu.Unlock()
+		}
+		sub := b.Subscribe("t", WithPolicy(tt.policy), WithBuffer(2), WithOnDrop(onDrop))
+		for i := 1; i <= 5; i++ {
+			b.Publish("t", i)
+		}
+		for deadline := time.Now().Add(time.Second); sub.Dropped() < 3 && time.Now().Before(deadline); {
+			time.Sleep(time.Millisecond)
+		}
+
+		if got := received(sub); !slices.Equal(got, tt.want) {
+			t.Errorf("%v: received %v, want %v", tt.policy, got, tt.want)
+		}
+		if got := sub.Dropped(); got != 3 {
+			t.Errorf("%v: Dropped() = %d, want 3", tt.policy, got)
+		}
+		mu.Lock()
+		if !slices.Equal(dropped, tt.wantDropped) {
+			t.Errorf("%v: WithOnDrop heard of %v, want %v", tt.policy, dropped, tt.wantDropped)
+		}
+		mu.Unlock()
+		st, _ := b.TopicStats("t")
+		if st.Dropped != 3 {
+			t.Errorf("%v: TopicStats().Dropped = %d, want 3", tt.policy, st.Dropped)
+		}
+		b.Stop()
+	}
+}
+
+// TestPolicy_Order tests that a slow subscriber gets every message in
+// order under the policies that wait for it
+func TestPolicy_Order(t *testing.T) {
+	for _, policy := range []Policy{DropAfterTimeout, Block} {
+		b := NewBroker()
+		sub := b.Subscribe("t", WithPolicy(policy), WithBuffer(1))
+		go func() {
+			for i := range 50 {
+				b.Publish("t", i)
+			}
+		}()
+		for want := range 50 {
+			if got := (<-sub.C).Payload.(int); got != want {
+				t.Fatalf("%v: received %d, want %d", policy, got, want)
+			}
+			if want%10 == 0 {
+				time.Sleep(time.Millisecond) // fall behind
+			}
+		}
+		if got := sub.Dropped(); got != 0 {
+			t.Errorf("%v: Dropped() = %d, want 0", policy, got)
+		}
+		b.Stop()
+	}
+}
+
+// TestPolicy_BlockUnsubscribe tests that a Block subscriber that stopped
+// reading can still be unsubscribed, freeing the broker
+func TestPolicy_BlockUnsubscribe(t *testing.T) {
+	b := NewBroker()
+	defer b.Stop()
+	stuck := b.Subscribe("t", WithPolicy(Block), WithBuffer(1))
+	other := b.Subscribe("u")
+	for i := range 3 {
+		b.Publish("t", i)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		b.Unsubscribe("t", stuck)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(3 * time.Second):
+		t.Fatal("Unsubscribe blocked")
+	}
+	b.Publish("u", "x")
+	if m := <-other.C; m.Payload != "x" {
+		t.Errorf("received %v, want x", m.Payload)
+	}
+}
+
+// TestParsePolicy tests that policy names round-trip
+func TestParsePolicy(t *testing.T) {
+	for _, p := range []Policy{DropAfterTimeout, Block, DropOldest, DropNewest} {
+		if got, err := ParsePolicy(p.String()); got != p || err != nil {
+			t.Errorf("ParsePolicy(%q) = %v, %v, want %v", p.String(), got, err, p)
+		}
+	}
+	if _, err := ParsePolicy("drop-random"); err == nil {
+		t.Error("ParsePolicy(drop-random) succeeded")
+	}
+}
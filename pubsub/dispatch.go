@@ -0,0 +1,208 @@
+package pubsub
+
+import (
+	"context"
+	"runtime/pprof"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// dispatchSlice is how long a dispatcher waits for a slow subscriber to
+// make room before it moves on to other work, and comes back to the
+// subscriber later. It keeps a few stalled subscribers from holding up
+// the deliveries to all the others.
+const dispatchSlice = 5 * time.Millisecond
+
+// WithDispatchers sets how many goroutines at most deliver what the run
+// loop does not (default 4 × GOMAXPROCS): messages queued for
+// DropAfterTimeout subscribers with no room for them, and fan-outs to
+// more subscribers than the run loop serves itself. They are started as
+// they are needed, and however fast messages are published or many
+// subscribers fall behind, the broker runs no more of them.
+//
+// A subscriber keeps its messages in a queue of its own, in order,
+// while it waits for a dispatcher, and the wait counts towards the
+// delivery timeout: with many stalled subscribers and few dispatchers,
+// a subscriber that is merely slow may have messages dropped sooner.
+func WithDispatchers(n int) BrokerOption {
+	return func(b *Broker) {
+		b.dispatch.size = max(n, 1)
+	}
+}
+
+// dispatch is the broker's pool of dispatchers: a bounded number of
+// goroutines taking jobs from a queue.
+type dispatch struct {
+	size int
+
+	mu      sync.Mutex
+	cond    sync.Cond // signalled when there are jobs, a fan-out ends, or on stop
+	jobs    ring[job]
+	running int // dispatchers started
+	idle    int // dispatchers waiting for a job
+	stopped bool
+	wg      sync.WaitGroup
+
+	// Fan-outs go one at a time, so every subscriber still gets its
+	// messages in order: the one being delivered, then those waiting.
+	fanning *fanoutBatch
+	pending ring[*fanoutBatch]
+	batches atomic.Int32 // fanning and pending, for the run loop to check
+}
+
+// job is delivering sub's queued messages, or chunk of batch.
+type job struct {
+	sub   *Subscriber
+	batch *fanoutBatch
+	chunk int
+}
+
+// fanoutBatch is a message to deliver to subscribers in chunks, by
+// several dispatchers at once.
+type fanoutBatch struct {
+	subs    []*Subscriber
+	msg     Message
+	deliver DeliverFunc // the delivery middleware when it was published
+	left    int         // chunks not yet delivered; guarded by dispatch.mu
+}
+
+// push queues j, starting a dispatcher if none is idle and the pool is
+// not full. Caller holds d.mu.
+func (b *Broker) push(j job) {
+	d := &b.dispatch
+	d.jobs.push(j)
+	if d.idle > 0 {
+		d.cond.Signal()
+		return
+	}
+	if d.running < d.size {
+		d.running++
+		d.wg.Add(1)
+		n := strconv.Itoa(d.running)
+		go pprof.Do(context.Background(), pprof.Labels("component", "pubsub.dispatch", "dispatcher", n), func(context.Context) {
+			defer d.wg.Done()
+			b.dispatcher()
+		})
+	}
+}
+
+// pumpLater queues sub, which has messages waiting for room, for a
+// dispatcher.
+func (b *Broker) pumpLater(sub *Subscriber) {
+	b.dispatch.mu.Lock()
+	defer b.dispatch.mu.Unlock()
+	b.push(job{sub: sub})
+}
+
+// fanoutLater delivers msg to subs through deliver (see fanout) on the
+// dispatchers, in chunks, so the run loop can move on. subs must not be
+// modified afterwards. Each fan-out starts once the previous one is
+// done. Only the run loop may call it.
+//
+// It is kept out of publish so that only this slow path moves msg to
+// the heap for the dispatchers.
+func (b *Broker) fanoutLater(subs []*Subscriber, msg Message, deliver DeliverFunc) {
+	d := &b.dispatch
+	batch := &fanoutBatch{subs: subs, msg: msg, deliver: deliver, left: (len(subs) + fanoutChunk - 1) / fanoutChunk}
+	d.batches.Add(1)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.fanning != nil {
+		d.pending.push(batch)
+		return
+	}
+	b.startFanout(batch)
+}
+
+// startFanout queues the chunks of batch. Caller holds dispatch.mu.
+func (b *Broker) startFanout(batch *fanoutBatch) {
+	b.dispatch.fanning = batch
+	for i := range batch.left {
+		b.push(job{batch: batch, chunk: i})
+	}
+}
+
+// fanoutIdle reports whether no fan-out is being delivered or waiting
+// for the dispatchers.
+func (b *Broker) fanoutIdle() bool {
+	return b.dispatch.batches.Load() == 0
+}
+
+// waitFanouts waits for every fan-out handed to the dispatchers to be
+// delivered.
+func (b *Broker) waitFanouts() {
+	d := &b.dispatch
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for d.fanning != nil {
+		d.cond.Wait()
+	}
+}
+
+// stopDispatchers makes the dispatchers exit once they are done with
+// their jobs, and waits for them. The subscribers must be closed, so
+// none has any more to deliver.
+func (b *Broker) stopDispatchers() {
+	d := &b.dispatch
+	d.mu.Lock()
+	d.stopped = true
+	d.cond.Broadcast()
+	d.mu.Unlock()
+	d.wg.Wait()
+}
+
+// dispatcher runs jobs until the broker stops.
+func (b *Broker) dispatcher() {
+	d := &b.dispatch
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for {
+		for d.jobs.len() == 0 {
+			if d.stopped {
+				return
+			}
+			d.idle++
+			d.cond.Wait()
+			d.idle--
+		}
+		j := d.jobs.pop()
+		d.mu.Unlock()
+		again := b.runJob(j)
+		d.mu.Lock()
+
+		switch {
+		case again:
+			d.jobs.push(j) // to the back of the queue
+		case j.batch != nil:
+			if j.batch.left--; j.batch.left > 0 {
+				break
+			}
+			d.batches.Add(-1)
+			d.fanning = nil
+			if d.pending.len() > 0 {
+				b.startFanout(d.pending.pop())
+			}
+			d.cond.Broadcast() // for waitFanouts
+		}
+	}
+}
+
+// runJob runs j, reporting whether the subscriber still has messages
+// waiting for room, so j must be run again.
+func (b *Broker) runJob(j job) (again bool) {
+	if batch := j.batch; batch != nil {
+		defer endRegion(b.startRegion("pubsub.fanout", batch.msg.Topic))
+		b.fanout(batch.subs[j.chunk*fanoutChunk:min((j.chunk+1)*fanoutChunk, len(batch.subs))], batch.msg, batch.deliver)
+		return false
+	}
+	sub := j.sub
+	defer endRegion(b.startRegion("pubsub.deliver", sub.topic))
+	var yield func()
+	if b.yield != nil {
+		point := "deliver/" + sub.topic + "/" + strconv.FormatUint(sub.id, 10)
+		yield = func() { b.yield(point) }
+	}
+	return sub.pump(yield, dispatchSlice)
+}
@@ -0,0 +1,69 @@
+package pubsub
+
+import (
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestWithDispatchers tests that however many DropAfterTimeout
+// subscribers fall behind, the broker delivers to them on no more
+// goroutines than it is allowed, and each still gets its messages in
+// order, or has them dropped once they time out
+func TestWithDispatchers(t *testing.T) {
+	tests := []struct {
+		name    string
+		timeout time.Duration
+		read    bool
+	}{
+		{"delivered", 5 * time.Second, true},
+		{"dropped", 20 * time.Millisecond, false},
+	}
+	for _, tt := range tests {
+		before := runtime.NumGoroutine()
+		b := NewBroker(WithDispatchers(2), WithDeliveryTimeout(tt.timeout))
+		subs := make([]*Subscriber, 50)
+		for i := range subs {
+			subs[i] = b.Subscribe("t", WithPolicy(DropAfterTimeout), WithBuffer(1))
+		}
+		for i := range 5 {
+			b.Publish("t", i)
+		}
+		b.exec(func() {}) // the run loop has taken them all
+		// the broker's run loop, and at most two dispatchers
+		if got := runtime.NumGoroutine() - before; got > 3 {
+			t.Errorf("%s: %d goroutines while subscribers are stalled, want at most 3", tt.name, got)
+		}
+
+		if tt.read {
+			var wg sync.WaitGroup
+			for _, sub := range subs {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for want := range 5 {
+						if got := (<-sub.C).Payload.(int); got != want {
+							t.Errorf("%s: subscriber %d received %d, want %d", tt.name, sub.id, got, want)
+						}
+					}
+				}()
+			}
+			wg.Wait()
+		} else {
+			for deadline := time.Now().Add(5 * time.Second); b.Stats().Dropped < 4*uint64(len(subs)) && time.Now().Before(deadline); {
+				time.Sleep(time.Millisecond)
+			}
+		}
+		for _, sub := range subs {
+			want := uint64(0)
+			if !tt.read {
+				want = 4 // all but the one its buffer took
+			}
+			if got := sub.Dropped(); got != want {
+				t.Errorf("%s: subscriber %d Dropped() = %d, want %d", tt.name, sub.id, got, want)
+			}
+		}
+		b.Stop()
+	}
+}
@@ -0,0 +1,91 @@
+package pubsub
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Errors returned by the broker. Callers should test for them with
+// errors.Is, since they may be wrapped with more detail.
+var (
+	// ErrBrokerClosed is returned by operations on a broker that has been
+	// stopped.
+	ErrBrokerClosed = errors.New("pubsub: broker closed")
+
+	// ErrTopicNotFound is returned for a topic with no subscribers and no
+	// published messages.
+	ErrTopicNotFound = errors.New("pubsub: topic not found")
+
+	// ErrSubscriberSlow means a message was dropped because the
+	// subscriber did not take it in time.
+	ErrSubscriberSlow = errors.New("pubsub: subscriber too slow")
+
+	// ErrPublishTimeout is returned when the broker does not accept a
+	// message in time.
+	ErrPublishTimeout = errors.New("pubsub: publish timed out")
+
+	// ErrNoRetention is returned by Resume on a broker without retention.
+	ErrNoRetention = errors.New("pubsub: no retention configured")
+
+	// ErrInvalidToken is returned by Resume for a malformed token.
+	ErrInvalidToken = errors.New("pubsub: invalid resume token")
+
+	// ErrTokenExpired is returned by Resume when messages after the token
+	// are no longer retained, so resuming would leave a gap, or when the
+	// token is past the last message published, e.g. after a restart.
+	ErrTokenExpired = errors.New("pubsub: resume token expired")
+
+	// ErrTraceDisabled is returned by Trace and DumpTrace on a broker
+	// created without WithTrace.
+	ErrTraceDisabled = errors.New("pubsub: tracing not enabled")
+
+	// ErrTooManySubscribers means a topic is at Limits.MaxSubscribers.
+	ErrTooManySubscribers = errors.New("pubsub: too many subscribers")
+
+	// ErrTooManyTopics means the broker is at Limits.MaxTopics.
+	ErrTooManyTopics = errors.New("pubsub: too many topics")
+
+	// ErrPatternTopic is returned by Resume for a pattern: sequence
+	// numbers, and so resume tokens, belong to one topic.
+	ErrPatternTopic = errors.New("pubsub: cannot resume a pattern")
+
+	// ErrNoResponders is returned by Request when nobody was subscribed
+	// to the topic to answer.
+	ErrNoResponders = errors.New("pubsub: no responders")
+
+	// ErrNoSubscribers is the reason a message published to a topic
+	// nobody is subscribed to is dead-lettered; see WithDeadLetter.
+	ErrNoSubscribers = errors.New("pubsub: no subscribers")
+)
+
+// DeliveryError reports a message that could not be delivered to a
+// subscriber. Err says why, e.g. ErrSubscriberSlow.
+type DeliveryError struct {
+	Topic string
+	Seq   uint64
+	Err   error
+}
+
+func (e *DeliveryError) Error() string {
+	return fmt.Sprintf("pubsub: delivering %s #%d: %v", e.Topic, e.Seq, e.Err)
+}
+
+func (e *DeliveryError) Unwrap() error {
+	return e.Err
+}
+
+// LimitError reports a subscription refused by the broker's Limits. Err
+// is ErrTooManySubscribers or ErrTooManyTopics.
+type LimitError struct {
+	Topic string
+	Limit int
+	Err   error
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("pubsub: subscribing to %s: %v (limit %d)", e.Topic, e.Err, e.Limit)
+}
+
+func (e *LimitError) Unwrap() error {
+	return e.Err
+}
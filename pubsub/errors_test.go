@@ -0,0 +1,80 @@
+package pubsub
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// TestBroker_TopicStats tests ErrTopicNotFound and ErrBrokerClosed
+func TestBroker_TopicStats(t *testing.T) {
+	b := NewBroker()
+
+	if _, err := b.TopicStats("none"); !errors.Is(err, ErrTopicNotFound) {
+		t.Errorf("TopicStats(none) error = %v, want ErrTopicNotFound", err)
+	}
+
+	b.Subscribe("t")
+	b.Publish("t", 1)
+	b.Publish("t", 2)
+	st, err := b.TopicStats("t")
+	if err != nil || st.Subscribers != 1 || st.LastSeq != 2 {
+		t.Errorf("TopicStats(t) = %+v, %v, want 1 subscriber, LastSeq 2", st, err)
+	}
+
+	b.Stop()
+	if _, err := b.TopicStats("t"); !errors.Is(err, ErrBrokerClosed) {
+		t.Errorf("TopicStats() after Stop error = %v, want ErrBrokerClosed", err)
+	}
+}
+
+// TestBroker_PublishTimeout tests the errors of PublishTimeout
+func TestBroker_PublishTimeout(t *testing.T) {
+	b := NewBroker(WithPublishQueue(1))
+	if err := b.PublishTimeout("t", 1, time.Second); err != nil {
+		t.Errorf("PublishTimeout() error = %v", err)
+	}
+
+	// hold the run loop and fill the topic's queue
+	release := make(chan struct{})
+	go b.exec(func() { <-release })
+	time.Sleep(10 * time.Millisecond)
+	if err := b.PublishTimeout("t", 2, time.Second); err != nil {
+		t.Errorf("PublishTimeout() into an empty queue error = %v", err)
+	}
+	if err := b.PublishTimeout("t", 3, 10*time.Millisecond); !errors.Is(err, ErrPublishTimeout) {
+		t.Errorf("PublishTimeout() on a busy broker error = %v, want ErrPublishTimeout", err)
+	}
+	close(release)
+
+	b.Stop()
+	if err := b.PublishTimeout("t", 4, time.Second); !errors.Is(err, ErrBrokerClosed) {
+		t.Errorf("PublishTimeout() after Stop error = %v, want ErrBrokerClosed", err)
+	}
+}
+
+// TestSubscriber_Err tests that a drop is reported as a DeliveryError
+func TestSubscriber_Err(t *testing.T) {
+	b := NewBroker()
+	b.deliveryTimeout = time.Millisecond
+	defer b.Stop()
+
+	sub := b.Subscribe("t")
+	if err := sub.Err(); err != nil {
+		t.Errorf("Err() = %v, want nil", err)
+	}
+	for range sub.Cap() + 1 {
+		b.Publish("t", nil)
+	}
+	for sub.Dropped() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	var de *DeliveryError
+	if err := sub.Err(); !errors.As(err, &de) || !errors.Is(err, ErrSubscriberSlow) {
+		t.Fatalf("Err() = %v, want a DeliveryError wrapping ErrSubscriberSlow", err)
+	}
+	if de.Topic != "t" || de.Seq != uint64(sub.Cap()+1) {
+		t.Errorf("DeliveryError = %+v, want topic t, Seq %d", de, sub.Cap()+1)
+	}
+}
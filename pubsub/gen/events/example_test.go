@@ -0,0 +1,33 @@
+package events_test
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/arifmahmudrana/go-snippets/pubsub"
+	"github.com/arifmahmudrana/go-snippets/pubsub/gen/events"
+)
+
+func ExampleSubscribeOrderCreated() {
+	b := pubsub.NewBroker()
+	defer b.Stop()
+
+	done := make(chan struct{})
+	unsubscribe := events.SubscribeOrderCreated(b, func(o events.OrderCreated) {
+		fmt.Println(o.OrderID, o.Items, o.Total)
+		done <- struct{}{}
+	}, nil)
+	defer unsubscribe()
+
+	// typed, in-process
+	events.PublishOrderCreated(b, events.OrderCreated{OrderID: "A-1", Items: []string{"book"}, Total: 12.5})
+	<-done
+
+	// raw JSON, e.g. from a remote client
+	b.Publish(events.TopicOrderCreated, json.RawMessage(`{"order_id":"A-2","items":["pen"],"total":2}`))
+	<-done
+
+	// Output:
+	// A-1 [book] 12.5
+	// A-2 [pen] 2
+}
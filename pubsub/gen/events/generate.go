@@ -0,0 +1,3 @@
+package events
+
+//go:generate go run github.com/arifmahmudrana/go-snippets/pubsub/gen -in topics.yaml -out topics_gen.go
@@ -0,0 +1,128 @@
+// Code generated by pubsub/gen from topics.yaml; DO NOT EDIT.
+
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/arifmahmudrana/go-snippets/pubsub"
+)
+
+// Topic names.
+const (
+	TopicOrderCreated = "order.created"
+	TopicOrderShipped = "order.shipped"
+)
+
+// OrderCreated is published on order.created. A customer placed an order.
+type OrderCreated struct {
+	OrderID string   `json:"order_id"`
+	Items   []string `json:"items"`
+	// Total in the shop currency.
+	Total    float64   `json:"total"`
+	PlacedAt time.Time `json:"placed"`
+}
+
+// PublishOrderCreated publishes v on TopicOrderCreated.
+func PublishOrderCreated(b *pubsub.Broker, v OrderCreated) {
+	b.Publish(TopicOrderCreated, v)
+}
+
+// SubscribeOrderCreated calls fn for every OrderCreated published on
+// TopicOrderCreated, as pubsub.Broker.SubscribeFunc does. Messages that do
+// not decode are passed to onError if it is not nil.
+func SubscribeOrderCreated(b *pubsub.Broker, fn func(OrderCreated), onError func(pubsub.Message, error)) (unsubscribe func()) {
+	return b.SubscribeFunc(TopicOrderCreated, func(m pubsub.Message) {
+		v, err := DecodeOrderCreated(m)
+		if err != nil {
+			if onError != nil {
+				onError(m, err)
+			}
+			return
+		}
+		fn(v)
+	})
+}
+
+// DecodeOrderCreated decodes m's payload into a OrderCreated value.
+// Besides values published in-process it accepts JSON, as received from
+// the transport or read back from retention.
+func DecodeOrderCreated(m pubsub.Message) (OrderCreated, error) {
+	var v OrderCreated
+	var data []byte
+	switch p := m.Payload.(type) {
+	case OrderCreated:
+		return p, nil
+	case *OrderCreated:
+		return *p, nil
+	case json.RawMessage:
+		data = p
+	case []byte:
+		data = p
+	default:
+		var err error
+		if data, err = json.Marshal(p); err != nil {
+			return v, fmt.Errorf("decoding %s: %w", TopicOrderCreated, err)
+		}
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return v, fmt.Errorf("decoding %s: %w", TopicOrderCreated, err)
+	}
+	return v, nil
+}
+
+// OrderShipped is published on order.shipped.
+type OrderShipped struct {
+	OrderID string `json:"order_id"`
+	Carrier string `json:"carrier"`
+}
+
+// PublishOrderShipped publishes v on TopicOrderShipped.
+func PublishOrderShipped(b *pubsub.Broker, v OrderShipped) {
+	b.Publish(TopicOrderShipped, v)
+}
+
+// SubscribeOrderShipped calls fn for every OrderShipped published on
+// TopicOrderShipped, as pubsub.Broker.SubscribeFunc does. Messages that do
+// not decode are passed to onError if it is not nil.
+func SubscribeOrderShipped(b *pubsub.Broker, fn func(OrderShipped), onError func(pubsub.Message, error)) (unsubscribe func()) {
+	return b.SubscribeFunc(TopicOrderShipped, func(m pubsub.Message) {
+		v, err := DecodeOrderShipped(m)
+		if err != nil {
+			if onError != nil {
+				onError(m, err)
+			}
+			return
+		}
+		fn(v)
+	})
+}
+
+// DecodeOrderShipped decodes m's payload into a OrderShipped value.
+// Besides values published in-process it accepts JSON, as received from
+// the transport or read back from retention.
+func DecodeOrderShipped(m pubsub.Message) (OrderShipped, error) {
+	var v OrderShipped
+	var data []byte
+	switch p := m.Payload.(type) {
+	case OrderShipped:
+		return p, nil
+	case *OrderShipped:
+		return *p, nil
+	case json.RawMessage:
+		data = p
+	case []byte:
+		data = p
+	default:
+		var err error
+		if data, err = json.Marshal(p); err != nil {
+			return v, fmt.Errorf("decoding %s: %w", TopicOrderShipped, err)
+		}
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return v, fmt.Errorf("decoding %s: %w", TopicOrderShipped, err)
+	}
+	return v, nil
+}
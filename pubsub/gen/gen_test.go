@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestSnakeCase tests default JSON field names
+func TestSnakeCase(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"Total", "total"},
+		{"PlacedAt", "placed_at"},
+		{"OrderID", "order_id"},
+		{"IDCard", "id_card"},
+		{"URL", "url"},
+	}
+	for _, tt := range tests {
+		if got := snakeCase(tt.in); got != tt.want {
+			t.Errorf("snakeCase(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+// TestParseSchema_Errors tests schema validation
+func TestParseSchema_Errors(t *testing.T) {
+	tests := []struct {
+		name, schema, want string
+	}{
+		{"bad package", "package: my-events\ntopics: [{name: a, type: A}]", "package"},
+		{"no topics", "package: events", "no topics"},
+		{"unexported type", "package: e\ntopics: [{name: a, type: order}]", "exported"},
+		{"duplicate topic", "package: e\ntopics: [{name: a, type: A}, {name: a, type: B}]", "declared twice"},
+		{"duplicate type", "package: e\ntopics: [{name: a, type: A}, {name: b, type: A}]", "another topic"},
+		{"field without type", "package: e\ntopics: [{name: a, type: A, fields: [{name: X}]}]", "no type"},
+		{"unknown key", "package: e\ntopic: []", "topic"},
+	}
+	for _, tt := range tests {
+		_, err := parseSchema([]byte(tt.schema))
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: parseSchema() error = %v, want mention of %q", tt.name, err, tt.want)
+		}
+	}
+}
+
+// TestGenerate_UpToDate tests that the checked-in example matches the
+// generator, so a template change cannot go unnoticed
+func TestGenerate_UpToDate(t *testing.T) {
+	dir := "events"
+	data, err := os.ReadFile(filepath.Join(dir, "topics.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := parseSchema(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := generate(s, "topics.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want, err := os.ReadFile(filepath.Join(dir, "topics_gen.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Error("events/topics_gen.go is stale; run go generate ./pubsub/gen/events")
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"strings"
+	"text/template"
+)
+
+// generate renders Go source for s. source names the schema file in the
+// generated header.
+func generate(s *Schema, source string) ([]byte, error) {
+	var buf bytes.Buffer
+	err := tmpl.Execute(&buf, struct {
+		*Schema
+		Source string
+		Time   bool
+	}{s, source, s.usesTime()})
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("formatting generated code: %w\n%s", err, buf.Bytes())
+	}
+	return out, nil
+}
+
+// comment turns text into // comment lines.
+func comment(text string) string {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return ""
+	}
+	return "// " + strings.ReplaceAll(text, "\n", "\n// ") + "\n"
+}
+
+var tmpl = template.Must(template.New("").Funcs(template.FuncMap{"comment": comment}).Parse(`
+// Code generated by pubsub/gen from {{.Source}}; DO NOT EDIT.
+
+package {{.Package}}
+
+import (
+	"encoding/json"
+	"fmt"
+{{- if .Time}}
+	"time"
+{{- end}}
+
+	"github.com/arifmahmudrana/go-snippets/pubsub"
+)
+
+// Topic names.
+const (
+{{- range .Topics}}
+	Topic{{.Type}} = {{printf "%q" .Name}}
+{{- end}}
+)
+{{range .Topics}}
+{{if .Doc}}{{comment (printf "%s is published on %s. %s" .Type .Name .Doc)}}{{else}}// {{.Type}} is published on {{.Name}}.
+{{end -}}
+type {{.Type}} struct {
+{{- range .Fields}}
+	{{if .Doc}}{{comment .Doc}}{{end}}{{.Name}} {{.Type}} ` + "`" + `json:"{{.JSON}}"` + "`" + `
+{{- end}}
+}
+
+// Publish{{.Type}} publishes v on Topic{{.Type}}.
+func Publish{{.Type}}(b *pubsub.Broker, v {{.Type}}) {
+	b.Publish(Topic{{.Type}}, v)
+}
+
+// Subscribe{{.Type}} calls fn for every {{.Type}} published on
+// Topic{{.Type}}, as pubsub.Broker.SubscribeFunc does. Messages that do
+// not decode are passed to onError if it is not nil.
+func Subscribe{{.Type}}(b *pubsub.Broker, fn func({{.Type}}), onError func(pubsub.Message, error)) (unsubscribe func()) {
+	return b.SubscribeFunc(Topic{{.Type}}, func(m pubsub.Message) {
+		v, err := Decode{{.Type}}(m)
+		if err != nil {
+			if onError != nil {
+				onError(m, err)
+			}
+			return
+		}
+		fn(v)
+	})
+}
+
+// Decode{{.Type}} decodes m's payload into a {{.Type}} value.
+// Besides values published in-process it accepts JSON, as received from
+// the transport or read back from retention.
+func Decode{{.Type}}(m pubsub.Message) ({{.Type}}, error) {
+	var v {{.Type}}
+	var data []byte
+	switch p := m.Payload.(type) {
+	case {{.Type}}:
+		return p, nil
+	case *{{.Type}}:
+		return *p, nil
+	case json.RawMessage:
+		data = p
+	case []byte:
+		data = p
+	default:
+		var err error
+		if data, err = json.Marshal(p); err != nil {
+			return v, fmt.Errorf("decoding %s: %w", Topic{{.Type}}, err)
+		}
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return v, fmt.Errorf("decoding %s: %w", Topic{{.Type}}, err)
+	}
+	return v, nil
+}
+{{end}}`[1:]))
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Command gen generates typed topic wrappers from a YAML schema, usually
+// from a go:generate line next to the schema:
+//
+//	//go:generate go run github.com/arifmahmudrana/go-snippets/pubsub/gen -in topics.yaml -out topics_gen.go
+func main() {
+	in := flag.String("in", "topics.yaml", "schema file")
+	out := flag.String("out", "", "output file (default: stdout)")
+	flag.Parse()
+
+	if err := run(*in, *out); err != nil {
+		fmt.Fprintln(os.Stderr, "gen:", err)
+		os.Exit(1)
+	}
+}
+
+func run(in, out string) error {
+	data, err := os.ReadFile(in)
+	if err != nil {
+		return err
+	}
+	s, err := parseSchema(data)
+	if err != nil {
+		return fmt.Errorf("%s: %w", in, err)
+	}
+	src, err := generate(s, filepath.Base(in))
+	if err != nil {
+		return err
+	}
+
+	if out == "" {
+		_, err = os.Stdout.Write(src)
+		return err
+	}
+	return os.WriteFile(out, src, 0o644)
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/token"
+	"strings"
+	"unicode"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Schema is the topic schema file:
+//
+//	package: events
+//	topics:
+//	  - name: order.created
+//	    type: OrderCreated
+//	    doc: An order was placed.
+//	    fields:
+//	      - {name: ID, type: string}
+//	      - {name: PlacedAt, type: time.Time, json: placed}
+type Schema struct {
+	Package string  `yaml:"package"`
+	Topics  []Topic `yaml:"topics"`
+}
+
+// Topic describes one topic and the payload type published on it.
+type Topic struct {
+	Name   string  `yaml:"name"`
+	Type   string  `yaml:"type"`
+	Doc    string  `yaml:"doc"`
+	Fields []Field `yaml:"fields"`
+}
+
+// Field is one field of a payload struct. Type is any Go type
+// expression; JSON defaults to the snake_case of Name.
+type Field struct {
+	Name string `yaml:"name"`
+	Type string `yaml:"type"`
+	JSON string `yaml:"json"`
+	Doc  string `yaml:"doc"`
+}
+
+// parseSchema decodes and validates a schema, filling in defaults.
+func parseSchema(data []byte) (*Schema, error) {
+	var s Schema
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&s); err != nil {
+		return nil, fmt.Errorf("parsing schema: %w", err)
+	}
+	if err := s.validate(); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (s *Schema) validate() error {
+	if !token.IsIdentifier(s.Package) {
+		return fmt.Errorf("package %q is not a Go identifier", s.Package)
+	}
+	if len(s.Topics) == 0 {
+		return fmt.Errorf("no topics")
+	}
+
+	topics, types := map[string]bool{}, map[string]bool{}
+	for i := range s.Topics {
+		t := &s.Topics[i]
+		switch {
+		case t.Name == "":
+			return fmt.Errorf("topic %d has no name", i+1)
+		case topics[t.Name]:
+			return fmt.Errorf("topic %q declared twice", t.Name)
+		case !token.IsExported(t.Type) || !token.IsIdentifier(t.Type):
+			return fmt.Errorf("topic %q: type %q is not an exported Go identifier", t.Name, t.Type)
+		case types[t.Type]:
+			return fmt.Errorf("topic %q: type %q used by another topic", t.Name, t.Type)
+		}
+		topics[t.Name], types[t.Type] = true, true
+
+		fields := map[string]bool{}
+		for j := range t.Fields {
+			f := &t.Fields[j]
+			switch {
+			case !token.IsExported(f.Name) || !token.IsIdentifier(f.Name):
+				return fmt.Errorf("topic %q: field %q is not an exported Go identifier", t.Name, f.Name)
+			case fields[f.Name]:
+				return fmt.Errorf("topic %q: field %q declared twice", t.Name, f.Name)
+			case f.Type == "":
+				return fmt.Errorf("topic %q: field %q has no type", t.Name, f.Name)
+			}
+			fields[f.Name] = true
+			if f.JSON == "" {
+				f.JSON = snakeCase(f.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// usesTime reports whether any field refers to package time.
+func (s *Schema) usesTime() bool {
+	for _, t := range s.Topics {
+		for _, f := range t.Fields {
+			if strings.Contains(f.Type, "time.") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// snakeCase turns "OrderID" into "order_id" and "PlacedAt" into
+// "placed_at".
+func snakeCase(s string) string {
+	rs := []rune(s)
+	var b strings.Builder
+	for i, r := range rs {
+		if unicode.IsUpper(r) {
+			// a new word starts at an upper-case letter that follows a
+			// lower-case one, or that ends a run like "ID" in "IDCard"
+			prevLower := i > 0 && unicode.IsLower(rs[i-1])
+			endOfRun := i > 0 && i+1 < len(rs) && unicode.IsUpper(rs[i-1]) && unicode.IsLower(rs[i+1])
+			if prevLower || endOfRun {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,89 @@
+package pubsub
+
+import "slices"
+
+// SubscribeGroup subscribes to topic as a member of group, to share its
+// messages with the other members instead of each getting every one:
+// each message goes to one member of the group, in turn, while
+// subscribers outside it, and other groups, still get it too. Adding
+// members is how a topic's consumers scale out.
+//
+// Members share a group if they subscribe to the same topic or pattern
+// with the same group name; a group on "orders.*" is not one on
+// "orders.created". A message handed to a member stays with it, so a
+// member that does not keep up drops its share as its Policy says. An
+// empty group is the same as Subscribe.
+func (b *Broker) SubscribeGroup(topic, group string, opts ...SubscribeOption) *Subscriber {
+	return b.subscribe(nil, topic, append(opts, func(s *Subscriber) {
+		s.group = group
+	}))
+}
+
+// Group returns the group the subscriber joined with SubscribeGroup, or
+// "" if none.
+func (s *Subscriber) Group() string {
+	return s.group
+}
+
+// groupKey names a group: its members subscribed to topic.
+type groupKey struct {
+	topic, name string
+}
+
+// group is the members of one group, in the order they joined, and
+// whose turn it is. Owned by the run loop.
+type group struct {
+	members []*Subscriber
+	next    int
+	round   uint64 // the last pickGroups that took a member
+}
+
+// joinGroup adds sub to its group, if it has one. Only the run loop may
+// call it.
+func (b *Broker) joinGroup(sub *Subscriber) {
+	if sub.group == "" {
+		return
+	}
+	key := groupKey{sub.topic, sub.group}
+	g, ok := b.groups[key]
+	if !ok {
+		g = &group{}
+		b.groups[key] = g
+	}
+	g.members = append(g.members, sub)
+	sub.grp = g
+}
+
+// leaveGroup removes sub from its group, if it has one. Only the run
+// loop may call it.
+func (b *Broker) leaveGroup(sub *Subscriber) {
+	g := sub.grp
+	if g == nil {
+		return
+	}
+	sub.grp = nil
+	if i := slices.Index(g.members, sub); i >= 0 {
+		g.members = slices.Delete(g.members, i, i+1)
+	}
+	if len(g.members) == 0 {
+		delete(b.groups, groupKey{sub.topic, sub.group})
+	}
+}
+
+// pickGroups appends to dst the subscribers in subs that are in no
+// group, and in place of each group's members the member whose turn it
+// is, and returns the result. Only the run loop may call it.
+func (b *Broker) pickGroups(dst, subs []*Subscriber) []*Subscriber {
+	b.groupRound++
+	for _, s := range subs {
+		switch g := s.grp; {
+		case g == nil:
+			dst = append(dst, s)
+		case g.round != b.groupRound:
+			g.round = b.groupRound
+			dst = append(dst, g.members[g.next%len(g.members)])
+			g.next++
+		}
+	}
+	return dst
+}
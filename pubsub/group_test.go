@@ -0,0 +1,99 @@
+package pubsub
+
+import (
+	"slices"
+	"testing"
+)
+
+// payloads returns the payloads of msgs.
+func payloads(msgs []Message) []any {
+	var ps []any
+	for _, m := range msgs {
+		ps = append(ps, m.Payload)
+	}
+	return ps
+}
+
+// TestBroker_SubscribeGroup tests that each group gets every message
+// once, its members taking turns, next to a plain subscriber
+func TestBroker_SubscribeGroup(t *testing.T) {
+	b := NewBroker()
+	defer b.Stop()
+
+	plain := b.Subscribe("jobs")
+	workers := []*Subscriber{b.SubscribeGroup("jobs", "workers"), b.SubscribeGroup("jobs", "workers")}
+	audit := []*Subscriber{
+		b.SubscribeGroup("jobs", "audit"),
+		b.SubscribeGroup("jobs", "audit"),
+		b.SubscribeGroup("jobs", "audit"),
+	}
+	// not in the same group: subscribed to a pattern
+	pattern := b.SubscribeGroup("jobs.#", "workers")
+	for i := 1; i <= 6; i++ {
+		b.Publish("jobs", i)
+	}
+
+	tests := []struct {
+		name string
+		sub  *Subscriber
+		want []any
+	}{
+		{"plain", plain, []any{1, 2, 3, 4, 5, 6}},
+		{"workers[0]", workers[0], []any{1, 3, 5}},
+		{"workers[1]", workers[1], []any{2, 4, 6}},
+		{"audit[0]", audit[0], []any{1, 4}},
+		{"audit[1]", audit[1], []any{2, 5}},
+		{"audit[2]", audit[2], []any{3, 6}},
+		{"pattern", pattern, []any{1, 2, 3, 4, 5, 6}},
+	}
+	for _, tt := range tests {
+		if got := payloads(recv(t, tt.sub.C, len(tt.want))); !slices.Equal(got, tt.want) {
+			t.Errorf("%s got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+	b.Stats() // let the broker deliver anything extra
+	for _, tt := range tests {
+		if n := tt.sub.Pending(); n != 0 {
+			t.Errorf("%s got %d messages too many", tt.name, n)
+		}
+	}
+	if got := workers[0].Group(); got != "workers" {
+		t.Errorf("Group() = %q, want %q", got, "workers")
+	}
+}
+
+// TestBroker_SubscribeGroup_Leave tests that the members left share the
+// messages once one unsubscribes, and that a group's last member gets
+// them all
+func TestBroker_SubscribeGroup_Leave(t *testing.T) {
+	b := NewBroker()
+	defer b.Stop()
+
+	a := b.SubscribeGroup("jobs", "workers")
+	c := b.SubscribeGroup("jobs", "workers")
+	d := b.SubscribeGroup("jobs", "workers")
+	b.Unsubscribe("jobs", c)
+	for i := 1; i <= 4; i++ {
+		b.Publish("jobs", i)
+	}
+	if got, want := payloads(recv(t, a.C, 2)), []any{1, 3}; !slices.Equal(got, want) {
+		t.Errorf("first member got %v, want %v", got, want)
+	}
+	if got, want := payloads(recv(t, d.C, 2)), []any{2, 4}; !slices.Equal(got, want) {
+		t.Errorf("last member got %v, want %v", got, want)
+	}
+
+	b.Unsubscribe("jobs", a)
+	b.Publish("jobs", 5)
+	b.Publish("jobs", 6)
+	if got, want := payloads(recv(t, d.C, 2)), []any{5, 6}; !slices.Equal(got, want) {
+		t.Errorf("only member got %v, want %v", got, want)
+	}
+
+	b.Unsubscribe("jobs", d)
+	b.exec(func() {
+		if n := len(b.groups); n != 0 {
+			t.Errorf("%d groups left after every member left", n)
+		}
+	})
+}
@@ -0,0 +1,96 @@
+package pubsub
+
+import (
+	"strings"
+	"sync"
+)
+
+// MatchTopic reports whether topic matches pattern. Topics are split
+// into dot-separated tokens; in a pattern, "*" matches exactly one token,
+// a final ">" matches one or more, and a final "#" zero or more, as in
+// MQTT and AMQP. So "device.*" matches "device.42" but not
+// "device.42.temp", which "device.>" matches; "device.#" matches both,
+// and "device" itself.
+func MatchTopic(pattern, topic string) bool {
+	pt, tt := strings.Split(pattern, "."), strings.Split(topic, ".")
+	for i, p := range pt {
+		if i == len(pt)-1 {
+			switch p {
+			case ">":
+				return len(tt) > i
+			case "#":
+				return len(tt) >= i
+			}
+		}
+		if i >= len(tt) || (p != "*" && p != tt[i]) {
+			return false
+		}
+	}
+	return len(pt) == len(tt)
+}
+
+// topicHook is one OnTopicCreate registration.
+type topicHook struct {
+	pattern string
+	fn      func(topic string)
+}
+
+// hooks holds topic creation hooks and the topics seen so far.
+type hooks struct {
+	known sync.Map // topic -> struct{}
+
+	mu    sync.RWMutex
+	hooks []*topicHook
+}
+
+// OnTopicCreate calls fn the first time a topic matching pattern (see
+// MatchTopic) is subscribed to or published to, before that operation
+// proceeds. Use it to set up dynamically named topics lazily, e.g. to
+// attach a sink or a bridge to every "device.*" topic.
+//
+// fn runs on the goroutine that first used the topic and may call the
+// broker, including for the new topic itself. Operations on the topic
+// from other goroutines are not held back while fn runs. Topics used
+// before the hook was registered do not trigger it. The returned
+// function removes the hook.
+func (b *Broker) OnTopicCreate(pattern string, fn func(topic string)) (remove func()) {
+	h := &topicHook{pattern: pattern, fn: fn}
+	b.hooks.mu.Lock()
+	b.hooks.hooks = append(b.hooks.hooks, h)
+	b.hooks.mu.Unlock()
+
+	return func() {
+		b.hooks.mu.Lock()
+		defer b.hooks.mu.Unlock()
+		for i, r := range b.hooks.hooks {
+			if r == h {
+				b.hooks.hooks = append(b.hooks.hooks[:i:i], b.hooks.hooks[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// touch marks topic as in use, running the creation hooks if this is
+// its first use.
+func (b *Broker) touch(topic string) {
+	if _, ok := b.hooks.known.Load(topic); ok {
+		return
+	}
+	if _, loaded := b.hooks.known.LoadOrStore(topic, struct{}{}); loaded {
+		return
+	}
+
+	b.hooks.mu.RLock()
+	matched := make([]*topicHook, 0, len(b.hooks.hooks))
+	for _, h := range b.hooks.hooks {
+		if MatchTopic(h.pattern, topic) {
+			matched = append(matched, h)
+		}
+	}
+	b.hooks.mu.RUnlock()
+
+	for _, h := range matched {
+		h.fn(topic)
+	}
+}
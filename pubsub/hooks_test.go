@@ -0,0 +1,70 @@
+package pubsub
+
+import (
+	"slices"
+	"testing"
+)
+
+// TestMatchTopic tests the topic pattern syntax
+func TestMatchTopic(t *testing.T) {
+	tests := []struct {
+		pattern, topic string
+		want           bool
+	}{
+		{"device.*", "device.42", true},
+		{"device.*", "device.42.temp", false},
+		{"device.*", "device", false},
+		{"device.*.temp", "device.42.temp", true},
+		{"device.>", "device.42.temp", true},
+		{"device.>", "device", false},
+		{"news", "news", true},
+		{"news", "sports", false},
+		{"*", "news", true},
+		{"a.>.b", "a.>.b", true}, // ">" is literal unless last
+		{"device.#", "device.42.temp", true},
+		{"device.#", "device", true},
+		{"device.#", "sensor.42", false},
+		{"#", "news", true},
+	}
+	for _, tt := range tests {
+		if got := MatchTopic(tt.pattern, tt.topic); got != tt.want {
+			t.Errorf("MatchTopic(%q, %q) = %v, want %v", tt.pattern, tt.topic, got, tt.want)
+		}
+	}
+}
+
+// TestBroker_OnTopicCreate tests that hooks run once per new matching topic
+func TestBroker_OnTopicCreate(t *testing.T) {
+	b := NewBroker()
+	defer b.Stop()
+
+	b.Publish("device.old", nil) // used before the hook: never reported
+
+	var created []string
+	var bridged []*Subscriber
+	remove := b.OnTopicCreate("device.*", func(topic string) {
+		created = append(created, topic)
+		// a hook may use the broker, even for the new topic
+		bridged = append(bridged, b.Subscribe(topic))
+	})
+
+	b.Publish("device.1", "on")
+	b.Subscribe("device.1")
+	b.Subscribe("device.2")
+	b.Publish("device.old", nil)
+	b.Publish("weather", nil)
+
+	if want := []string{"device.1", "device.2"}; !slices.Equal(created, want) {
+		t.Errorf("created = %v, want %v", created, want)
+	}
+	// the bridge was in place before the first publish
+	if m := <-bridged[0].C; m.Payload != "on" {
+		t.Errorf("bridge got %v, want on", m.Payload)
+	}
+
+	remove()
+	b.Publish("device.3", nil)
+	if len(created) != 2 {
+		t.Errorf("hook ran after remove: %v", created)
+	}
+}
@@ -0,0 +1,174 @@
+package pubsub
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// errCanceled is returned by enqueue when its cancel channel closes;
+// callers replace it with the reason, such as ctx.Err().
+var errCanceled = errors.New("pubsub: publish canceled")
+
+// defaultPublishQueue is how many messages a topic may have waiting for
+// the run loop before its publishers block.
+const defaultPublishQueue = 256
+
+// inbound holds published messages waiting for the run loop, in one FIFO
+// queue per topic. The run loop takes one message from each busy topic
+// in turn, so a chatty topic cannot delay the others by more than one
+// message per round, and a full queue only blocks that topic's
+// publishers.
+type inbound struct {
+	limit  int
+	notify chan struct{} // holds a token while messages are queued
+
+	mu     sync.Mutex
+	queues map[string]*topicQueue
+	ready  ring[*topicQueue] // busy topics, in service order
+	total  int
+}
+
+type topicQueue struct {
+	topic string
+	msgs  ring[Message]
+	slots chan struct{} // a token per queued message; full means wait
+}
+
+func newInbound(limit int) *inbound {
+	return &inbound{
+		limit:  max(limit, 1),
+		notify: make(chan struct{}, 1),
+		queues: make(map[string]*topicQueue),
+	}
+}
+
+// queue returns topic's queue, creating it on first use.
+func (in *inbound) queue(topic string) *topicQueue {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	q, ok := in.queues[topic]
+	if !ok {
+		q = &topicQueue{topic: topic, slots: make(chan struct{}, in.limit)}
+		in.queues[topic] = q
+	}
+	return q
+}
+
+// add appends m to q, whose slot the caller holds, and wakes the run
+// loop.
+func (in *inbound) add(q *topicQueue, m Message) {
+	in.mu.Lock()
+	if q.msgs.len() == 0 {
+		in.ready.push(q)
+	}
+	q.msgs.push(m)
+	in.total++
+	in.mu.Unlock()
+	in.signal()
+}
+
+// signal makes sure the run loop will look at the queues.
+func (in *inbound) signal() {
+	select {
+	case in.notify <- struct{}{}:
+	default:
+	}
+}
+
+// pop takes the next message round-robin, reporting false if none is
+// queued. Only the run loop may call it.
+func (in *inbound) pop() (Message, bool) {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	if in.ready.len() == 0 {
+		return Message{}, false
+	}
+
+	q := in.ready.pop()
+	m := q.msgs.pop()
+	if q.msgs.len() > 0 {
+		in.ready.push(q) // back of the line
+	}
+	in.total--
+	<-q.slots
+	return m, true
+}
+
+// len returns the number of queued messages.
+func (in *inbound) len() int {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	return in.total
+}
+
+// depths returns the queued messages per busy topic.
+func (in *inbound) depths() map[string]int {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	out := make(map[string]int, in.ready.len())
+	in.ready.each(func(q *topicQueue) {
+		out[q.topic] = q.msgs.len()
+	})
+	return out
+}
+
+// WithPublishQueue sets how many messages each topic may have waiting
+// for the broker before Publish blocks (default 256). Topics are served
+// round-robin, so the limit also bounds how far a busy topic can get
+// ahead of the others.
+func WithPublishQueue(n int) BrokerOption {
+	return func(b *Broker) {
+		b.inbound = newInbound(n)
+	}
+}
+
+// enqueue waits for room in m's topic queue and adds m. It fails with
+// ErrBrokerClosed after Stop, errCanceled when cancel is closed, or
+// ErrPublishTimeout when timeout fires; nil channels never fire.
+func (b *Broker) enqueue(m Message, cancel <-chan struct{}, timeout <-chan time.Time) error {
+	b.publishing.Add(1)
+	defer b.publishing.Add(-1)
+	select {
+	case <-b.stopCh:
+		return ErrBrokerClosed // even if a slot is free
+	default:
+	}
+	if b.yield != nil {
+		b.yield("publish/" + m.Topic)
+	}
+	b.touch(m.Topic)
+	q := b.inbound.queue(m.Topic)
+	select {
+	case q.slots <- struct{}{}:
+	case <-b.stopCh:
+		return ErrBrokerClosed
+	case <-cancel:
+		return errCanceled
+	case <-timeout:
+		return ErrPublishTimeout
+	}
+	b.inbound.add(q, m)
+	return nil
+}
+
+// flush hands every message queued so far to the middleware and
+// publish, so requests that follow a Publish from the same goroutine
+// see its effect. Messages queued meanwhile wait for the next round.
+// Only the run loop may call it.
+func (b *Broker) flush() {
+	for n := b.inbound.len(); n > 0; n-- {
+		m, ok := b.inbound.pop()
+		if !ok {
+			return
+		}
+		b.chain(m)
+	}
+}
+
+// QueueDepths returns how many published messages each topic has waiting
+// for the broker; topics with none are left out. Sustained depth means
+// the broker cannot keep up with that topic.
+func (b *Broker) QueueDepths() map[string]int {
+	return b.inbound.depths()
+}
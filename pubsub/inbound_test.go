@@ -0,0 +1,66 @@
+package pubsub
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// TestBroker_RoundRobin tests that a quiet topic is not stuck behind a
+// chatty one's backlog
+func TestBroker_RoundRobin(t *testing.T) {
+	b := NewBroker(WithTrace(200))
+	defer b.Stop()
+
+	// hold the run loop while both topics queue up
+	release := make(chan struct{})
+	go b.exec(func() { <-release })
+	time.Sleep(10 * time.Millisecond)
+
+	for range 100 {
+		b.Publish("chatty", nil)
+	}
+	b.Publish("quiet", nil)
+
+	depths := b.QueueDepths()
+	if depths["chatty"] != 100 || depths["quiet"] != 1 {
+		t.Errorf("QueueDepths() = %v, want chatty:100 quiet:1", depths)
+	}
+	close(release)
+
+	events, err := b.Trace()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, e := range events {
+		if e.Topic == "quiet" {
+			if i > 1 {
+				t.Errorf("quiet published after %d chatty messages, want at most 1", i)
+			}
+			return
+		}
+	}
+	t.Error("quiet was never published")
+}
+
+// TestBroker_PublishQueuePerTopic tests that a full queue only blocks
+// its own topic
+func TestBroker_PublishQueuePerTopic(t *testing.T) {
+	b := NewBroker(WithPublishQueue(2))
+	defer b.Stop()
+
+	release := make(chan struct{})
+	go b.exec(func() { <-release })
+	time.Sleep(10 * time.Millisecond)
+	defer close(release)
+
+	for range 2 {
+		b.Publish("full", nil)
+	}
+	if err := b.PublishTimeout("full", nil, 10*time.Millisecond); !errors.Is(err, ErrPublishTimeout) {
+		t.Errorf("PublishTimeout() on a full topic error = %v, want ErrPublishTimeout", err)
+	}
+	if err := b.PublishTimeout("other", nil, 10*time.Millisecond); err != nil {
+		t.Errorf("PublishTimeout() on another topic error = %v, want nil", err)
+	}
+}
@@ -0,0 +1,71 @@
+package pubsub
+
+import "fmt"
+
+// LimitTopic is where the broker publishes a *LimitError each time it
+// refuses a subscription, so operators can watch for clients stuck in a
+// subscribe loop.
+const LimitTopic = "$broker.limit"
+
+// Limits caps the broker's subscriptions; zero means unlimited. They
+// protect the broker from runaway clients, not from load: lowering a
+// limit refuses new subscriptions but keeps existing ones.
+type Limits struct {
+	// MaxSubscribers is the most subscribers a single topic may have.
+	MaxSubscribers int `json:"max_subscribers"`
+	// MaxTopics is the most topics that may have subscribers at once.
+	MaxTopics int `json:"max_topics"`
+}
+
+// checkLimits returns why a new subscriber to topic is refused, or nil.
+// Only the run loop may call it.
+func (b *Broker) checkLimits(topic string) error {
+	subs := b.subscriptions[topic]
+	if l := b.limits.MaxSubscribers; l > 0 && len(subs) >= l {
+		return &LimitError{Topic: topic, Limit: l, Err: ErrTooManySubscribers}
+	}
+	if l := b.limits.MaxTopics; l > 0 && len(subs) == 0 && len(b.subscriptions) >= l {
+		return &LimitError{Topic: topic, Limit: l, Err: ErrTooManyTopics}
+	}
+	return nil
+}
+
+// WithLimits sets the broker's initial limits.
+func WithLimits(l Limits) BrokerOption {
+	return func(b *Broker) {
+		b.limits = l
+	}
+}
+
+// Limits returns the limits in force.
+func (b *Broker) Limits() Limits {
+	var l Limits
+	if !b.exec(func() { l = b.limits }) {
+		<-b.doneCh // stopped: wait until the loop cannot change them
+		return b.limits
+	}
+	return l
+}
+
+// SetLimits replaces the limits; it fails with ErrBrokerClosed after
+// Stop, or if a limit is negative.
+func (b *Broker) SetLimits(l Limits) error {
+	if l.MaxSubscribers < 0 || l.MaxTopics < 0 {
+		return fmt.Errorf("pubsub: limits must be >= 0, got %+v", l)
+	}
+	if !b.exec(func() { b.limits = l }) {
+		return ErrBrokerClosed
+	}
+	return nil
+}
+
+// TrySubscribe is like Subscribe but reports a refused subscription as a
+// *LimitError, and a stopped broker as ErrBrokerClosed, instead of
+// returning a closed subscriber.
+func (b *Broker) TrySubscribe(topic string, opts ...SubscribeOption) (*Subscriber, error) {
+	sub := b.Subscribe(topic, opts...)
+	if err := sub.refused; err != nil {
+		return nil, err
+	}
+	return sub, nil
+}
@@ -0,0 +1,76 @@
+package pubsub
+
+import (
+	"errors"
+	"testing"
+)
+
+// TestBroker_Limits tests refusing subscriptions over the limits
+func TestBroker_Limits(t *testing.T) {
+	b := NewBroker(WithLimits(Limits{MaxSubscribers: 2, MaxTopics: 2}))
+	defer b.Stop()
+	events := b.Subscribe(LimitTopic) // the first topic
+
+	for range 2 {
+		if _, err := b.TrySubscribe("a"); err != nil {
+			t.Fatalf("TrySubscribe(a) error = %v", err)
+		}
+	}
+
+	tests := []struct {
+		topic string
+		want  error
+	}{
+		{"a", ErrTooManySubscribers},
+		{"b", ErrTooManyTopics},
+	}
+	for _, tt := range tests {
+		_, err := b.TrySubscribe(tt.topic)
+		var le *LimitError
+		if !errors.As(err, &le) || !errors.Is(err, tt.want) || le.Topic != tt.topic {
+			t.Errorf("TrySubscribe(%s) error = %v, want a LimitError for %v", tt.topic, err, tt.want)
+		}
+		m := <-events.C
+		if le, ok := m.Payload.(*LimitError); !ok || !errors.Is(le, tt.want) {
+			t.Errorf("limit event = %v, want a LimitError for %v", m.Payload, tt.want)
+		}
+	}
+
+	sub := b.Subscribe("a")
+	if _, ok := <-sub.C; ok || !errors.Is(sub.Err(), ErrTooManySubscribers) {
+		t.Errorf("refused Subscribe() Err() = %v, want ErrTooManySubscribers and a closed channel", sub.Err())
+	}
+	<-events.C
+}
+
+// TestBroker_SetLimits tests changing the limits at runtime
+func TestBroker_SetLimits(t *testing.T) {
+	b := NewBroker()
+	b.Subscribe("a")
+	b.Subscribe("a")
+
+	if err := b.SetLimits(Limits{MaxSubscribers: 1}); err != nil {
+		t.Fatalf("SetLimits() error = %v", err)
+	}
+	if got := b.Limits(); got.MaxSubscribers != 1 {
+		t.Errorf("Limits() = %+v, want MaxSubscribers 1", got)
+	}
+	// existing subscribers stay, new ones are refused
+	if st, _ := b.TopicStats("a"); st.Subscribers != 2 {
+		t.Errorf("Subscribers = %d, want 2", st.Subscribers)
+	}
+	if _, err := b.TrySubscribe("a"); !errors.Is(err, ErrTooManySubscribers) {
+		t.Errorf("TrySubscribe() error = %v, want ErrTooManySubscribers", err)
+	}
+
+	if err := b.SetLimits(Limits{MaxTopics: -1}); err == nil {
+		t.Error("SetLimits() with a negative limit succeeded")
+	}
+	b.Stop()
+	if err := b.SetLimits(Limits{}); !errors.Is(err, ErrBrokerClosed) {
+		t.Errorf("SetLimits() after Stop error = %v, want ErrBrokerClosed", err)
+	}
+	if _, err := b.TrySubscribe("a"); !errors.Is(err, ErrBrokerClosed) {
+		t.Errorf("TrySubscribe() after Stop error = %v, want ErrBrokerClosed", err)
+	}
+}
@@ -0,0 +1,80 @@
+package pubsub
+
+// PublishFunc takes a published message. The broker's own numbers it,
+// retains it and delivers it to the topic's subscribers.
+type PublishFunc func(m Message)
+
+// Middleware wraps a PublishFunc with a concern of its own, such as
+// logging, validating or enriching messages, or filtering them out. It
+// passes each message on by calling next, changed or not, or drops it
+// by not calling it; it may also call next more than once, to publish
+// messages of its own. Code after next runs once the message has been
+// handed to the subscribers that had room for it.
+type Middleware func(next PublishFunc) PublishFunc
+
+// Use adds middleware that every published message goes through before
+// the broker takes it, the broker's own messages (such as dead letters)
+// included. Middleware added first runs first. It fails with
+// ErrBrokerClosed once the broker is stopped.
+//
+// The chain runs on the broker's run loop, one message at a time in
+// publish order, so it may keep state without locking and sees each
+// topic's messages in order. For the same reason it must be quick, and
+// must not call the broker: to publish, call next. Seq and Time are not
+// set yet; the broker numbers and stamps what reaches it. A Request
+// that is dropped gets no response, and fails when its context ends.
+func (b *Broker) Use(mw ...Middleware) error {
+	if !b.exec(func() {
+		b.middleware = append(b.middleware, mw...)
+		b.chain = b.publish
+		for i := len(b.middleware) - 1; i >= 0; i-- {
+			b.chain = b.middleware[i](b.chain)
+		}
+	}) {
+		return ErrBrokerClosed
+	}
+	return nil
+}
+
+// DeliverFunc hands a message to one subscriber. The broker's own
+// offers it to the subscriber's channel as the subscriber's Policy says.
+type DeliverFunc func(sub *Subscriber, m Message)
+
+// DeliveryMiddleware wraps a DeliverFunc, for concerns that depend on
+// the subscriber: filtering what a subscriber may see, by its Tags or
+// Group, changing a message for it, or counting deliveries. It passes
+// the message on by calling next, or drops it for that subscriber
+// alone by not calling it.
+type DeliveryMiddleware func(next DeliverFunc) DeliverFunc
+
+// UseDelivery adds middleware that runs for each subscriber a message is
+// delivered to, after the message is numbered and retained and before
+// it is offered to the subscriber. Middleware added first runs first.
+// It fails with ErrBrokerClosed once the broker is stopped.
+//
+// A subscriber's messages go through the chain in order. Deliveries to
+// different subscribers may go through it at once, though: a message
+// for many subscribers is delivered by several of the broker's
+// dispatchers together (see WithDispatchers), so the chain must be safe
+// for concurrent use. Like Use's, it must be quick and must not call
+// the broker. A message published before UseDelivery returns may still
+// be delivered without the new middleware.
+func (b *Broker) UseDelivery(mw ...DeliveryMiddleware) error {
+	if !b.exec(func() {
+		b.deliveryMiddleware = append(b.deliveryMiddleware, mw...)
+		b.deliver = b.offer
+		for i := len(b.deliveryMiddleware) - 1; i >= 0; i-- {
+			b.deliver = b.deliveryMiddleware[i](b.deliver)
+		}
+	}) {
+		return ErrBrokerClosed
+	}
+	return nil
+}
+
+// offer is the DeliverFunc at the end of the delivery middleware.
+func (b *Broker) offer(sub *Subscriber, m Message) {
+	if sub.offer(m, b.deliveryTimeout, b.stopCh) {
+		b.pumpLater(sub)
+	}
+}
@@ -0,0 +1,148 @@
+package pubsub
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sync/atomic"
+	"testing"
+)
+
+// TestBroker_Use tests that middleware runs in the order added, can
+// drop and change messages, and that the broker numbers only those it
+// gets
+func TestBroker_Use(t *testing.T) {
+	b := NewBroker()
+	defer b.Stop()
+
+	var seen []any // only touched on the run loop
+	b.Use(func(next PublishFunc) PublishFunc {
+		return func(m Message) {
+			seen = append(seen, m.Payload)
+			next(m)
+		}
+	}, func(next PublishFunc) PublishFunc {
+		return func(m Message) {
+			if m.Payload == "spam" {
+				return
+			}
+			m.Payload = fmt.Sprint("<", m.Payload, ">")
+			next(m)
+		}
+	})
+	sub := b.Subscribe("chat")
+	for _, p := range []string{"hi", "spam", "bye"} {
+		b.Publish("chat", p)
+	}
+
+	msgs := recv(t, sub.C, 2)
+	if got, want := payloads(msgs), []any{"<hi>", "<bye>"}; !slices.Equal(got, want) {
+		t.Errorf("delivered %v, want %v", got, want)
+	}
+	if got := []uint64{msgs[0].Seq, msgs[1].Seq}; !slices.Equal(got, []uint64{1, 2}) {
+		t.Errorf("delivered seqs %v, want [1 2]", got)
+	}
+	b.exec(func() {
+		if want := []any{"hi", "spam", "bye"}; !slices.Equal(seen, want) {
+			t.Errorf("first middleware saw %v, want %v", seen, want)
+		}
+	})
+	if st := b.Stats(); st.Published != 2 {
+		t.Errorf("Stats().Published = %d, want 2", st.Published)
+	}
+}
+
+// TestBroker_Use_Fork tests middleware publishing messages of its own
+// by calling next again
+func TestBroker_Use_Fork(t *testing.T) {
+	b := NewBroker()
+	defer b.Stop()
+	b.Use(func(next PublishFunc) PublishFunc {
+		return func(m Message) {
+			next(m)
+			if m.Topic != "audit" {
+				next(Message{Topic: "audit", Payload: m.Topic})
+			}
+		}
+	})
+	audit := b.Subscribe("audit")
+	orders := b.Subscribe("orders")
+	b.Publish("orders", 1)
+	b.Publish("users", 2)
+
+	if got, want := payloads(recv(t, orders.C, 1)), []any{1}; !slices.Equal(got, want) {
+		t.Errorf("orders got %v, want %v", got, want)
+	}
+	if got, want := payloads(recv(t, audit.C, 2)), []any{"orders", "users"}; !slices.Equal(got, want) {
+		t.Errorf("audit got %v, want %v", got, want)
+	}
+}
+
+// TestBroker_UseDelivery tests middleware run per subscriber: dropping
+// a message for some subscribers only and changing it for others, on
+// the run loop and on the dispatchers of a large fan-out alike
+func TestBroker_UseDelivery(t *testing.T) {
+	b := NewBroker()
+	defer b.Stop()
+	var calls atomic.Int64
+	err := b.UseDelivery(func(next DeliverFunc) DeliverFunc {
+		return func(sub *Subscriber, m Message) {
+			calls.Add(1)
+			if slices.Contains(sub.Tags(), "muted") {
+				return
+			}
+			next(sub, m)
+		}
+	}, func(next DeliverFunc) DeliverFunc {
+		return func(sub *Subscriber, m Message) {
+			if slices.Contains(sub.Tags(), "loud") {
+				m.Payload = fmt.Sprint(m.Payload, "!")
+			}
+			next(sub, m)
+		}
+	})
+	if err != nil {
+		t.Fatalf("UseDelivery() = %v", err)
+	}
+
+	for _, n := range []int{1, 2*fanoutChunk + 1} {
+		calls.Store(0)
+		subs := make([]*Subscriber, n)
+		for i := range subs {
+			subs[i] = b.Subscribe("news", WithTag("loud"))
+		}
+		muted := b.Subscribe("news", WithTag("muted"))
+		b.Publish("news", "hi")
+
+		for _, sub := range subs {
+			if got := payloads(recv(t, sub.C, 1)); !slices.Equal(got, []any{"hi!"}) {
+				t.Fatalf("%d subscribers: delivered %v, want [hi!]", n, got)
+			}
+		}
+		b.exec(func() {}) // the publish is done
+		b.waitFanouts()
+		if got := muted.Pending(); got != 0 {
+			t.Errorf("%d subscribers: muted subscriber has %d pending, want 0", n, got)
+		}
+		if got, want := calls.Load(), int64(n+1); got != want {
+			t.Errorf("%d subscribers: middleware ran %d times, want %d", n, got, want)
+		}
+		b.Unsubscribe("news", muted)
+		for _, sub := range subs {
+			b.Unsubscribe("news", sub)
+		}
+	}
+}
+
+// TestBroker_Use_Stopped tests that adding middleware to a stopped
+// broker fails
+func TestBroker_Use_Stopped(t *testing.T) {
+	b := NewBroker()
+	b.Stop()
+	if err := b.Use(func(next PublishFunc) PublishFunc { return next }); !errors.Is(err, ErrBrokerClosed) {
+		t.Errorf("Use() after Stop = %v, want %v", err, ErrBrokerClosed)
+	}
+	if err := b.UseDelivery(func(next DeliverFunc) DeliverFunc { return next }); !errors.Is(err, ErrBrokerClosed) {
+		t.Errorf("UseDelivery() after Stop = %v, want %v", err, ErrBrokerClosed)
+	}
+}
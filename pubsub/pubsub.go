@@ -2,25 +2,161 @@ package pubsub
 
 import (
 	"context"
+	"fmt"
+	"runtime"
+	"runtime/pprof"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
 	"time"
+
+	"github.com/arifmahmudrana/go-snippets/clock"
+	"github.com/arifmahmudrana/go-snippets/ratetrack"
+)
+
+const (
+	// rateWindow is the sliding window used for per-topic publish rates.
+	rateWindow = 10 * time.Second
+
+	// rateTau is the time constant of the smoothed per-topic publish rate.
+	rateTau = time.Minute
 )
 
 // Message holds the content being published.
 type Message struct {
 	Topic   string
 	Payload interface{}
+
+	// Seq numbers the messages of one topic 1, 2, 3, ... in publish
+	// order. A consumer that sees a jump has missed messages, which it
+	// can detect with a SeqTracker.
+	Seq uint64
+
+	// Time is when the broker accepted the message.
+	Time time.Time
+
+	// Clock is the broker's Lamport time when it accepted the message,
+	// if it has a clock (see WithClock), and 0 otherwise. Unlike Seq it
+	// orders messages across topics, and across brokers that pass
+	// messages on with Forward: a message published after another was
+	// seen has a greater Clock.
+	Clock uint64
+
+	// Set on a message published with Request; see Respond.
+	req *request
 }
 
-// Subscriber is a channel that receives messages.
-// A subscriber client will read from this channel.
-type Subscriber chan Message
+// Subscriber is one subscription to a topic. A subscriber client reads
+// messages from C, which the broker closes on Unsubscribe or Stop.
+//
+// The accessor methods are safe to call from any goroutine and let a
+// consumer or a monitor notice it is falling behind before messages are
+// dropped.
+type Subscriber struct {
+	// C delivers the topic's messages.
+	C <-chan Message
+
+	id      uint64 // numbers subscribers in traces
+	topic   string
+	group   string // see SubscribeGroup
+	grp     *group // owned by the run loop
+	tags    []string
+	trace   *tracer
+	metrics *metrics
+	ch      chan Message
+
+	policy Policy
+	buffer int
+	onDrop func(m Message, err error) // see WithOnDrop
+
+	// closed first, to make blocked sends give up
+	quit     chan struct{}
+	quitOnce sync.Once
+
+	// Deliveries hold mu for reading while they send; closing takes it
+	// for writing, so ch is never closed during a send.
+	mu     sync.RWMutex
+	closed bool
+
+	// Messages waiting for room in C under DropAfterTimeout, and
+	// whether they are queued for a dispatcher.
+	qmu     sync.Mutex
+	queue   []queued
+	pumping bool
+
+	dropped       atomic.Uint64
+	lastDelivered atomic.Int64 // unix nanoseconds, 0 if never
+	lastErr       atomic.Pointer[DeliveryError]
+	refused       error // set before C is closed if never subscribed
+}
+
+// Topic returns the topic the subscriber is subscribed to.
+func (s *Subscriber) Topic() string {
+	return s.topic
+}
+
+// Tags returns the tags given with WithTag.
+func (s *Subscriber) Tags() []string {
+	return slices.Clone(s.tags)
+}
+
+// hasTag reports whether the subscriber carries tag.
+func (s *Subscriber) hasTag(tag string) bool {
+	return slices.Contains(s.tags, tag)
+}
+
+// Pending returns the number of messages delivered to C but not yet read.
+func (s *Subscriber) Pending() int {
+	return len(s.ch)
+}
+
+// Cap returns the capacity of C. Pending close to Cap means the consumer
+// is falling behind.
+func (s *Subscriber) Cap() int {
+	return cap(s.ch)
+}
+
+// Dropped returns how many messages were discarded because the
+// subscriber did not take them in time, as its Policy decided.
+func (s *Subscriber) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
+// Err returns the most recent delivery failure, a *DeliveryError, or nil
+// if every message so far was delivered. For a subscription the broker
+// refused, it returns why: a *LimitError or ErrBrokerClosed.
+func (s *Subscriber) Err() error {
+	if s.refused != nil {
+		return s.refused
+	}
+	if err := s.lastErr.Load(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// LastDeliveredAt returns when a message was last handed to C, or the
+// zero time if none has been.
+func (s *Subscriber) LastDeliveredAt() time.Time {
+	ns := s.lastDelivered.Load()
+	if ns == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, ns)
+}
 
 // Broker is the central hub that manages topics, subscribers,
 // and the broadcasting of messages.
 type Broker struct {
-	// A map of topics to a map of subscribers.
-	// map[topic]map[subscriber]bool
-	subscriptions map[string]map[Subscriber]bool
+	// A map of topics to their subscribers. The slices are copy-on-write:
+	// the run loop replaces a topic's slice instead of modifying it, so a
+	// publish can fan out over a snapshot from other goroutines.
+	subscriptions map[string][]*Subscriber
+
+	// The subscribed patterns, also keys of subscriptions, indexed to
+	// find those matching a published topic.
+	patterns topicTrie
 
 	// Channel for receiving new subscription requests.
 	subCh chan subRequest
@@ -28,37 +164,203 @@ type Broker struct {
 	// Channel for receiving unsubscription requests.
 	unsubCh chan unsubRequest
 
-	// Channel for receiving messages to be published.
-	pubCh chan Message
+	// Published messages waiting for the run loop, per topic, and the
+	// number of publishers in enqueue, so Stop can wait for them.
+	inbound    *inbound
+	publishing atomic.Int64
+
+	// Last sequence number stamped per topic. Owned by the run loop.
+	seqs map[string]uint64
+
+	clock *clock.Lamport // see WithClock
+
+	// Optional store of published messages, for Resume.
+	limits          Limits
+	retention       Retention
+	retained        []string // patterns of the retained topics; all if empty
+	retentionErrors atomic.Uint64
+	resumeGaps      atomic.Uint64 // Resume channels closed over expired messages
 
 	// Channel to signal the broker to stop.
 	stopCh chan struct{}
+
+	// Closed once the run loop has exited and every subscriber is closed.
+	doneCh   chan struct{}
+	stopOnce sync.Once
+
+	// Channel for liveness probes; the run loop replies on the given channel.
+	pingCh chan chan struct{}
+
+	// Functions to run on the run loop, with access to subscriptions.
+	execCh chan func()
+
+	// Per-topic publish rates. Safe to read from any goroutine.
+	rates *ratetrack.Set
+
+	// The policy of subscribers made without WithPolicy, and how long
+	// DropAfterTimeout waits for a slow subscriber before dropping.
+	policy          Policy
+	deliveryTimeout time.Duration
+
+	// The goroutines delivering what the run loop does not; see
+	// WithDispatchers.
+	dispatch dispatch
+
+	// Buffers publish reuses, so delivering a message does not
+	// allocate. Owned by the run loop.
+	matched []string
+	subsBuf []*Subscriber
+
+	// Consumer groups, and a count of publishes that had groups to
+	// pick a member of; see SubscribeGroup. Owned by the run loop.
+	groups     map[groupKey]*group
+	groupRound uint64
+
+	// Optional record of recent events; nil unless WithTrace.
+	trace   *tracer
+	lastSub atomic.Uint64
+
+	// Topic creation hooks; see OnTopicCreate.
+	hooks hooks
+
+	// Counters for Stats, and the Observer; see WithObserver.
+	metrics metrics
+
+	// Where undelivered messages go; "" unless WithDeadLetter.
+	deadLetterTopic string
+
+	// Whether to mark execution trace regions; see WithTraceRegions.
+	regions bool
+
+	// The middleware added with Use, and the PublishFunc they make up
+	// around publish, which messages are taken with. Owned by the run
+	// loop.
+	middleware []Middleware
+	chain      PublishFunc
+
+	// The middleware added with UseDelivery, and the DeliverFunc they
+	// make up around offer; nil without any. Owned by the run loop,
+	// which hands it to each fan-out.
+	deliveryMiddleware []DeliveryMiddleware
+	deliver            DeliverFunc
+
+	// Called at interaction points for a test scheduler; nil unless
+	// WithYield.
+	yield func(point string)
 }
 
-// subRequest wraps a subscription request.
+// abort makes deliveries waiting for the subscriber give up.
+func (s *Subscriber) abort() {
+	s.quitOnce.Do(func() { close(s.quit) })
+}
+
+// close stops pending deliveries, waits for them to return and closes
+// the subscriber channel.
+func (s *Subscriber) close() {
+	s.trace.record(TraceUnsubscribe, s.topic, 0, s.id)
+	s.abort()
+	s.mu.Lock()
+	s.closed = true
+	close(s.ch)
+	s.mu.Unlock()
+	if fn := s.metrics.observer.OnUnsubscribe; fn != nil && s.refused == nil {
+		fn(s)
+	}
+}
+
+// subRequest wraps a subscription request. The run loop closes done
+// once the subscriber is registered, so messages published after
+// Subscribe returns reach it.
 type subRequest struct {
-	topic string
-	sub   Subscriber
+	sub  *Subscriber
+	done chan struct{}
 }
 
-// unsubRequest wraps an unsubscription request.
+// unsubRequest wraps an unsubscription request. The run loop closes done
+// once the subscriber is removed and its channel closed.
 type unsubRequest struct {
-	topic string
-	sub   Subscriber
+	sub  *Subscriber
+	done chan struct{}
+}
+
+const (
+	// defaultDeliveryTimeout is how long DropAfterTimeout waits for a
+	// slow subscriber.
+	defaultDeliveryTimeout = time.Second
+
+	// fanoutChunk is how many subscribers one dispatcher serves when a
+	// publish fans out. Smaller fan-outs are served by the run loop itself.
+	fanoutChunk = 512
+)
+
+// BrokerOption configures a Broker.
+type BrokerOption func(*Broker)
+
+// WithRetention keeps every published message in r, enabling Resume
+// and SubscribeFrom; WithRetainedTopics narrows it to some topics.
+// With a persistent Retention, sequence numbers continue where they left
+// off after a restart. Publish cannot report a failed Append; such
+// failures are counted by RetentionErrors.
+func WithRetention(r Retention) BrokerOption {
+	return func(b *Broker) {
+		b.retention = r
+	}
+}
+
+// WithClock stamps every published message's Clock from c, ticking it
+// once per message. Brokers that federate, passing messages to each
+// other with Forward, should each have their own clock. c is not
+// persisted: after a restart, Witness the Clock of the last retained
+// message before publishing, or messages may be stamped with times
+// already used.
+func WithClock(c *clock.Lamport) BrokerOption {
+	return func(b *Broker) {
+		b.clock = c
+	}
+}
+
+// WithYield calls fn whenever one of the broker's goroutines is about to
+// interact with another: the run loop before taking each request
+// ("broker"), a publisher before queueing ("publish/{topic}"), a slow
+// delivery ("deliver/{topic}/{subscriber}") and a SubscribeFunc
+// goroutine before each call ("subscriber/{topic}/{subscriber}"). It is
+// meant for a test scheduler such as sched.Scheduler's Point, which
+// blocks there to choose the order the goroutines go in.
+func WithYield(fn func(point string)) BrokerOption {
+	return func(b *Broker) {
+		b.yield = fn
+	}
 }
 
 // NewBroker creates and starts a new Broker.
-func NewBroker() *Broker {
+func NewBroker(opts ...BrokerOption) *Broker {
 	b := &Broker{
-		subscriptions: make(map[string]map[Subscriber]bool),
+		subscriptions: make(map[string][]*Subscriber),
 		subCh:         make(chan subRequest),
 		unsubCh:       make(chan unsubRequest),
-		pubCh:         make(chan Message),
+		inbound:       newInbound(defaultPublishQueue),
+		seqs:          make(map[string]uint64),
+		groups:        make(map[groupKey]*group),
 		stopCh:        make(chan struct{}),
+		doneCh:        make(chan struct{}),
+		pingCh:        make(chan chan struct{}),
+		execCh:        make(chan func()),
+		rates:         ratetrack.NewSet(rateWindow, rateTau),
+
+		deliveryTimeout: defaultDeliveryTimeout,
+		dispatch:        dispatch{size: 4 * runtime.GOMAXPROCS(0)},
+	}
+	b.dispatch.cond.L = &b.dispatch.mu
+	b.chain = b.publish
+	for _, opt := range opts {
+		opt(b)
 	}
 
-	// Start the central run loop in a goroutine
-	go b.run()
+	// Start the central run loop in a goroutine, labeled so it can be
+	// found in goroutine profiles
+	go pprof.Do(context.Background(), pprof.Labels("component", "pubsub.broker"), func(context.Context) {
+		b.run()
+	})
 	return b
 }
 
@@ -66,101 +368,542 @@ func NewBroker() *Broker {
 // This is the *only* goroutine allowed to access the subscriptions map,
 // which prevents data races.
 func (b *Broker) run() {
-	defer func() {
-		// On exit, close all channels
-		close(b.subCh)
-		close(b.unsubCh)
-		close(b.pubCh)
-	}()
+	defer close(b.doneCh)
 
 	for {
+		if b.yield != nil {
+			b.yield("broker")
+		}
 		select {
 		case <-b.stopCh:
-			// Signal to stop. Close all active subscriber channels.
+			// Signal to stop. Deliver what was already published, then
+			// close all active subscriber channels.
+			b.drain()
 			for _, topicSubs := range b.subscriptions {
-				for sub := range topicSubs {
-					close(sub)
+				for _, sub := range topicSubs {
+					sub.close()
 				}
 			}
+			b.stopDispatchers()
 			return
 
+		case reply := <-b.pingCh:
+			// Liveness probe: answering proves the loop is not stuck
+			b.flush()
+			close(reply)
+
+		case fn := <-b.execCh:
+			b.flush()
+			fn()
+
 		case req := <-b.subCh:
 			// New subscription
-			if b.subscriptions[req.topic] == nil {
-				b.subscriptions[req.topic] = make(map[Subscriber]bool)
+			b.flush()
+			topic := req.sub.topic
+			if err := b.checkLimits(topic); err != nil {
+				req.sub.refused = err
+				req.sub.close()
+				close(req.done)
+				break
+			}
+			b.subscriptions[topic] = append(slices.Clip(b.subscriptions[topic]), req.sub)
+			b.joinGroup(req.sub)
+			if len(b.subscriptions[topic]) == 1 && IsPattern(topic) {
+				b.patterns.add(topic)
+			}
+			b.trace.record(TraceSubscribe, topic, 0, req.sub.id)
+			if fn := b.metrics.observer.OnSubscribe; fn != nil {
+				fn(req.sub)
 			}
-			b.subscriptions[req.topic][req.sub] = true
+			close(req.done)
 
 		case req := <-b.unsubCh:
 			// Unsubscription
-			if topicSubs, ok := b.subscriptions[req.topic]; ok {
-				if _, subOk := topicSubs[req.sub]; subOk {
-					// Delete the subscriber
-					delete(topicSubs, req.sub)
-					// Close its channel to signal it's been unsubscribed
-					close(req.sub)
-				}
+			b.flush()
+			if b.removeSubscribers(req.sub.topic, func(s *Subscriber) bool { return s == req.sub }) > 0 {
+				// Close its channel to signal it's been unsubscribed
+				req.sub.close()
 			}
+			close(req.done)
 
-		case msg := <-b.pubCh:
-			// New message published
-			if topicSubs, ok := b.subscriptions[msg.Topic]; ok {
-				// Broadcast to all subscribers of this topic
-				for sub := range topicSubs {
-					// Send the message in a new goroutine to prevent a slow
-					// subscriber from blocking the entire broker.
-					go func(s Subscriber, m Message) {
-						// We can use a context with timeout to prevent
-						// a non-reading goroutine from leaking forever.
-						ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
-						defer cancel()
-
-						select {
-						case s <- m:
-						case <-ctx.Done():
-							// Subscriber was too slow, message dropped.
-						}
-					}(sub, msg)
-				}
+		case <-b.inbound.notify:
+			// New messages published: take one, round-robin by topic,
+			// and come back for more after any other pending requests.
+			if msg, ok := b.inbound.pop(); ok {
+				b.chain(msg)
+			}
+			if b.inbound.len() > 0 {
+				b.inbound.signal()
 			}
 		}
 	}
 }
 
-// Subscribe adds a new subscriber to a topic and returns the channel.
-// We add a small buffer to the subscriber channel to reduce blocking.
-func (b *Broker) Subscribe(topic string) Subscriber {
-	sub := make(Subscriber, 10) // Buffered channel
+// drain delivers every message published before Stop, including those
+// of publishers still in enqueue, and waits for fan-outs handed to the
+// dispatchers. Only the run loop may call it, once stopCh is
+// closed.
+func (b *Broker) drain() {
+	for {
+		b.flush()
+		// a publisher still in enqueue may yet add a message; any
+		// arriving now will see stopCh closed and give up
+		if b.publishing.Load() == 0 && b.inbound.len() == 0 {
+			break
+		}
+		runtime.Gosched()
+	}
+	b.waitFanouts()
+}
+
+// publish numbers, retains and delivers msg. Only the run loop may call
+// it.
+func (b *Broker) publish(msg Message) {
+	defer endRegion(b.startRegion("pubsub.publish", msg.Topic))
+	msg.Seq = b.nextSeq(msg.Topic)
+	msg.Time = time.Now()
+	switch {
+	case b.clock == nil:
+		msg.Clock = 0
+	case msg.Clock > 0: // forwarded
+		msg.Clock = b.clock.Witness(msg.Clock)
+	default:
+		msg.Clock = b.clock.Tick()
+	}
+	if b.retains(msg.Topic) {
+		// Retain before fanning out, so a resuming consumer that
+		// sees msg live can find everything before it. A replayed
+		// request cannot be answered.
+		retained := msg
+		retained.req = nil
+		if err := b.retention.Append(retained); err != nil {
+			b.retentionErrors.Add(1)
+		}
+	}
+	b.rates.Add(msg.Topic, 1)
+	b.metrics.published.Add(1)
+	if fn := b.metrics.observer.OnPublish; fn != nil {
+		fn(msg)
+	}
+	b.trace.record(TracePublish, msg.Topic, msg.Seq, 0)
+	var topicSubs []*Subscriber
+	if !IsPattern(msg.Topic) {
+		topicSubs = b.subscriptions[msg.Topic]
+	}
+	scratch := false
+	if b.matched = b.patterns.match(msg.Topic, b.matched[:0]); len(b.matched) > 0 {
+		// gather the pattern subscribers in a buffer reused across
+		// publishes
+		subs := append(b.subsBuf[:0], topicSubs...)
+		for _, p := range b.matched {
+			subs = append(subs, b.subscriptions[p]...)
+		}
+		b.subsBuf, topicSubs, scratch = subs, subs, true
+	}
+	if len(b.groups) > 0 {
+		// deliver to one member of each group; subsBuf may hold
+		// topicSubs, which pickGroups reads ahead of where it writes
+		picked := b.pickGroups(b.subsBuf[:0], topicSubs)
+		if scratch {
+			clear(topicSubs[len(picked):])
+		}
+		b.subsBuf, topicSubs, scratch = picked, picked, true
+	}
+	if len(topicSubs) == 0 {
+		if msg.req != nil {
+			msg.req.respond(nil, fmt.Errorf("%w: %q", ErrNoResponders, msg.Topic))
+		} else if b.unroutable(msg) {
+			b.deadLetter(msg, nil, ErrNoSubscribers)
+		}
+	}
+	if len(topicSubs) == 0 || len(topicSubs) <= fanoutChunk && b.fanoutIdle() {
+		b.fanout(topicSubs, msg, b.deliver)
+		if scratch {
+			clear(topicSubs) // hold no subscriber after it leaves
+		}
+		return
+	}
+	if scratch {
+		topicSubs = slices.Clone(topicSubs)
+		clear(b.subsBuf)
+	}
+	b.fanoutLater(topicSubs, msg, b.deliver)
+}
+
+// nextSeq returns the next sequence number for topic. A topic's first
+// publish continues from the retention, if any. Only the run loop may
+// call it.
+func (b *Broker) nextSeq(topic string) uint64 {
+	seq, ok := b.seqs[topic]
+	if !ok && b.retains(topic) {
+		var err error
+		if seq, err = b.retention.Last(topic); err != nil {
+			b.retentionErrors.Add(1)
+		}
+	}
+	seq++
+	b.seqs[topic] = seq
+	return seq
+}
+
+// fanout delivers msg to subs. Subscribers with room in their buffer
+// get it straight away, and the others as their Policy says. Under
+// DropAfterTimeout, a subscriber with messages to wait for is handed to
+// the dispatchers, which deliver them in order, so it cannot hold up
+// the broker. With delivery middleware, msg goes through deliver.
+func (b *Broker) fanout(subs []*Subscriber, msg Message, deliver DeliverFunc) {
+	if deliver != nil {
+		for _, sub := range subs {
+			deliver(sub, msg)
+		}
+		return
+	}
+	for _, sub := range subs {
+		b.offer(sub, msg)
+	}
+}
+
+// removeSubscribers drops the subscribers of topic matching del and
+// returns how many there were. It builds a new slice, leaving any
+// snapshot being fanned out intact. Only the run loop may call it.
+func (b *Broker) removeSubscribers(topic string, del func(*Subscriber) bool) int {
+	old := b.subscriptions[topic]
+	kept := slices.DeleteFunc(slices.Clone(old), func(s *Subscriber) bool {
+		if !del(s) {
+			return false
+		}
+		b.leaveGroup(s)
+		return true
+	})
+	if len(kept) == 0 {
+		delete(b.subscriptions, topic)
+		if len(old) > 0 && IsPattern(topic) {
+			b.patterns.remove(topic)
+		}
+	} else {
+		b.subscriptions[topic] = kept
+	}
+	return len(old) - len(kept)
+}
+
+// exec runs fn on the run loop and waits for it. It reports false,
+// without running fn, if the broker is stopped.
+func (b *Broker) exec(fn func()) bool {
+	done := make(chan struct{})
+	select {
+	case b.execCh <- func() { fn(); close(done) }:
+		<-done
+		return true
+	case <-b.stopCh:
+		return false
+	}
+}
+
+// SubscribeOption configures a single subscription.
+type SubscribeOption func(*Subscriber)
+
+// WithTag labels the subscription so it can be managed as part of a
+// group, e.g. every subscription opened by one dashboard. It may be
+// given more than once.
+func WithTag(tag string) SubscribeOption {
+	return func(s *Subscriber) {
+		s.tags = append(s.tags, tag)
+	}
+}
+
+// Subscribe adds a new subscriber to a topic and returns it.
+// Its channel has a small buffer to absorb bursts (see WithBuffer), and
+// what happens once that is full depends on its Policy.
+// After Stop, or when the broker's Limits refuse it, the returned
+// subscriber's channel is already closed and Err says why; see
+// TrySubscribe.
+//
+// The topic may be a pattern (see MatchTopic), such as "sports.*" or
+// "news.#", to receive the messages of every topic it matches; each
+// Message carries the topic it was published on. A pattern counts as
+// one topic towards the Limits, and unsubscribing takes the pattern as
+// the topic.
+//
+// Every subscriber gets every message; to share them out among
+// consumers instead, see SubscribeGroup.
+func (b *Broker) Subscribe(topic string, opts ...SubscribeOption) *Subscriber {
+	return b.subscribe(nil, topic, opts)
+}
+
+// SubscribeCtx is like Subscribe but reports a subscription that did not
+// happen as an error instead of returning a closed subscriber: ctx.Err()
+// if ctx is done before the broker gets to it, ErrBrokerClosed after
+// Stop, or a *LimitError.
+func (b *Broker) SubscribeCtx(ctx context.Context, topic string, opts ...SubscribeOption) (*Subscriber, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	sub := b.subscribe(ctx.Done(), topic, opts)
+	if err := sub.refused; err == errCanceled {
+		return nil, ctx.Err()
+	} else if err != nil {
+		return nil, err
+	}
+	return sub, nil
+}
+
+// subscribe registers a new subscriber, giving up with errCanceled if
+// cancel is closed first.
+func (b *Broker) subscribe(cancel <-chan struct{}, topic string, opts []SubscribeOption) *Subscriber {
+	if !IsPattern(topic) {
+		b.touch(topic)
+	}
+	sub := &Subscriber{
+		id:      b.lastSub.Add(1),
+		topic:   topic,
+		trace:   b.trace,
+		metrics: &b.metrics,
+		policy:  b.policy,
+		buffer:  defaultBuffer,
+		quit:    make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(sub)
+	}
+	sub.ch = make(chan Message, sub.buffer)
+	sub.C = sub.ch
 	req := subRequest{
-		topic: topic,
-		sub:   sub,
+		sub:  sub,
+		done: make(chan struct{}),
 	}
 
-	b.subCh <- req
+	select {
+	case b.subCh <- req:
+		<-req.done
+	case <-b.stopCh:
+		sub.refused = ErrBrokerClosed
+		sub.close()
+	case <-cancel:
+		sub.refused = errCanceled
+		sub.close()
+	}
+	if err, ok := sub.refused.(*LimitError); ok {
+		b.Publish(LimitTopic, err)
+	}
 	return sub
 }
 
-// Unsubscribe removes a subscriber from a topic.
-func (b *Broker) Unsubscribe(topic string, sub Subscriber) {
+// Unsubscribe removes a subscriber from a topic. It returns once the
+// broker has removed the subscriber and closed its channel, so no
+// message can be delivered to it afterwards. After Stop it is a no-op.
+func (b *Broker) Unsubscribe(topic string, sub *Subscriber) {
+	if sub.topic != topic {
+		return
+	}
+	if sub.policy == Block {
+		// the broker may be waiting for it, and must get round to this
+		sub.abort()
+	}
 	req := unsubRequest{
-		topic: topic,
-		sub:   sub,
+		sub:  sub,
+		done: make(chan struct{}),
+	}
+
+	select {
+	case b.unsubCh <- req:
+		<-req.done
+	case <-b.stopCh:
+	}
+}
+
+// UnsubscribeTag removes every subscription carrying tag, on any topic,
+// and returns how many were removed. Like Unsubscribe, it returns once
+// their channels are closed.
+func (b *Broker) UnsubscribeTag(tag string) int {
+	n := 0
+	b.exec(func() {
+		for topic := range b.subscriptions {
+			n += b.removeSubscribers(topic, func(s *Subscriber) bool {
+				if s.hasTag(tag) {
+					s.close()
+					return true
+				}
+				return false
+			})
+		}
+	})
+	return n
+}
+
+// TagStats summarises the subscriptions carrying one tag.
+type TagStats struct {
+	Subscribers int
+	Pending     int
+	Dropped     uint64
+}
+
+// TagStats returns statistics for every tag in use.
+func (b *Broker) TagStats() map[string]TagStats {
+	stats := make(map[string]TagStats)
+	b.exec(func() {
+		for _, topicSubs := range b.subscriptions {
+			for _, sub := range topicSubs {
+				for _, tag := range sub.tags {
+					st := stats[tag]
+					st.Subscribers++
+					st.Pending += sub.Pending()
+					st.Dropped += sub.Dropped()
+					stats[tag] = st
+				}
+			}
+		}
+	})
+	return stats
+}
+
+// SubscribeFunc subscribes to a topic and calls fn for every message, one
+// call at a time, on a goroutine owned by the broker. The returned
+// function unsubscribes and waits for any running call to fn to return;
+// it must not be called from inside fn.
+func (b *Broker) SubscribeFunc(topic string, fn func(Message), opts ...SubscribeOption) (unsubscribe func()) {
+	sub := b.Subscribe(topic, opts...)
+	done := make(chan struct{})
+	go pprof.Do(context.Background(), pprof.Labels("component", "pubsub.subscriber", "topic", topic), func(context.Context) {
+		defer close(done)
+		for msg := range sub.C {
+			if b.yield != nil {
+				b.yield("subscriber/" + topic + "/" + strconv.FormatUint(sub.id, 10))
+			}
+			r := b.startRegion("pubsub.handle", msg.Topic)
+			fn(msg)
+			endRegion(r)
+		}
+	})
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			b.Unsubscribe(topic, sub)
+			<-done
+		})
+	}
+}
+
+// PublishTimeout is like Publish but reports whether the broker took the
+// message: it fails with ErrPublishTimeout if the topic's publish queue
+// stays full for timeout, or ErrBrokerClosed after Stop.
+func (b *Broker) PublishTimeout(topic string, payload interface{}, timeout time.Duration) error {
+	t := time.NewTimer(timeout)
+	defer t.Stop()
+	return b.enqueue(Message{Topic: topic, Payload: payload}, nil, t.C)
+}
+
+// PublishCtx is like Publish but reports whether the broker took the
+// message: it fails with ctx.Err() if ctx is done while the topic's
+// publish queue is full, or ErrBrokerClosed after Stop. A message it
+// took is delivered, even if Stop follows at once.
+func (b *Broker) PublishCtx(ctx context.Context, topic string, payload interface{}) error {
+	err := b.enqueue(Message{Topic: topic, Payload: payload}, ctx.Done(), nil)
+	if err == errCanceled {
+		return ctx.Err()
 	}
+	return err
+}
 
-	b.unsubCh <- req
+// TopicStats describes one topic.
+type TopicStats struct {
+	Subscribers int
+	LastSeq     uint64 // 0 if nothing was published
+	Queued      int    // published, waiting for the broker
+	Dropped     uint64 // by the current subscribers, see Subscriber.Dropped
+}
+
+// TopicStats returns statistics for topic. It fails with
+// ErrTopicNotFound if the topic has neither subscribers nor messages, or
+// ErrBrokerClosed after Stop.
+func (b *Broker) TopicStats(topic string) (TopicStats, error) {
+	var st TopicStats
+	var found bool
+	if !b.exec(func() {
+		subs, subscribed := b.subscriptions[topic]
+		seq, published := b.seqs[topic]
+		st = TopicStats{Subscribers: len(subs), LastSeq: seq, Queued: b.QueueDepths()[topic]}
+		for _, sub := range subs {
+			st.Dropped += sub.Dropped()
+		}
+		found = subscribed || published || st.Queued > 0
+	}) {
+		return TopicStats{}, ErrBrokerClosed
+	}
+	if !found {
+		return TopicStats{}, fmt.Errorf("%w: %q", ErrTopicNotFound, topic)
+	}
+	return st, nil
 }
 
 // Publish broadcasts a message to all subscribers of a topic.
+// It returns once the message is queued for the broker, blocking only
+// while the topic's publish queue is full (see WithPublishQueue).
+// Anything this goroutine does with the broker afterwards, such as
+// unsubscribing, takes effect after the message is delivered.
+// Messages published after Stop are discarded.
+//
+// Once a topic is in use, publishing to subscribers with room allocates
+// nothing, save for storing payload in an interface: pointers, and
+// values such as small integers, are stored without allocating; other
+// values are copied to the heap.
 func (b *Broker) Publish(topic string, payload interface{}) {
-	msg := Message{
-		Topic:   topic,
-		Payload: payload,
-	}
+	b.enqueue(Message{Topic: topic, Payload: payload}, nil, nil)
+}
 
-	b.pubCh <- msg
+// Forward publishes m's payload on m.Topic, like PublishCtx, for a
+// message received from another broker, e.g. over transport. With
+// WithClock, the broker first witnesses m.Clock, so m and everything the
+// broker publishes after it are stamped later than m was by the other
+// broker. m's Seq and Time are replaced, as for any publish.
+func (b *Broker) Forward(ctx context.Context, m Message) error {
+	err := b.enqueue(Message{Topic: m.Topic, Payload: m.Payload, Clock: m.Clock}, ctx.Done(), nil)
+	if err == errCanceled {
+		return ctx.Err()
+	}
+	return err
 }
 
-// Stop shuts down the broker and closes all subscriber channels.
+// Stop shuts down the broker and closes all subscriber channels. It
+// first delivers every message a publish took, so a subscriber reading
+// until C closes sees them all, except those a slow DropAfterTimeout
+// subscriber is still being waited for. It returns once the channels
+// are closed and may be called more than once.
 func (b *Broker) Stop() {
-	close(b.stopCh)
+	b.stopOnce.Do(func() { close(b.stopCh) })
+	<-b.doneCh
+}
+
+// PublishRates returns publish statistics for every topic that has seen
+// at least one message: the total count, the count and rate over the
+// last 10 seconds, and a rate smoothed over about a minute.
+func (b *Broker) PublishRates() map[string]ratetrack.Snapshot {
+	return b.rates.Snapshot()
+}
+
+// RetentionErrors returns how many published messages could not be
+// retained.
+func (b *Broker) RetentionErrors() uint64 {
+	return b.retentionErrors.Load()
+}
+
+// Ping round-trips through the broker's run loop. It fails with
+// ErrBrokerClosed after Stop, or with ctx.Err() if the loop does not
+// answer in time — which makes it a natural liveness check.
+func (b *Broker) Ping(ctx context.Context) error {
+	reply := make(chan struct{})
+	select {
+	case <-b.stopCh:
+		return ErrBrokerClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	case b.pingCh <- reply:
+	}
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-reply:
+		return nil
+	}
 }
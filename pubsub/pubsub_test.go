@@ -0,0 +1,415 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/arifmahmudrana/go-snippets/clock"
+	"github.com/arifmahmudrana/go-snippets/deadlock"
+	"github.com/arifmahmudrana/go-snippets/store"
+)
+
+// TestBroker_PublishSubscribe tests delivery to every subscriber of a topic
+func TestBroker_PublishSubscribe(t *testing.T) {
+	d := deadlock.ForTest(t, deadlock.WithTimeout(2*time.Second))
+	b := NewBroker()
+
+	s1, s2 := b.Subscribe("news"), b.Subscribe("news")
+	other := b.Subscribe("sports")
+	b.Publish("news", "hello")
+
+	for _, s := range []*Subscriber{s1, s2} {
+		msg, ok := deadlock.Recv(d, s.C)
+		if !ok || msg.Payload != "hello" {
+			t.Errorf("received %v, %v, want hello, true", msg, ok)
+		}
+	}
+	select {
+	case msg := <-other.C:
+		t.Errorf("sports subscriber received %v", msg)
+	default:
+	}
+
+	d.Within("shutdown", func() {
+		b.Unsubscribe("news", s1)
+		b.Unsubscribe("news", s2)
+		b.Unsubscribe("sports", other)
+		b.Stop()
+	})
+}
+
+// TestBroker_StopClosesSubscribers tests that Stop closes open subscriptions
+// and does not hang
+func TestBroker_StopClosesSubscribers(t *testing.T) {
+	d := deadlock.ForTest(t, deadlock.WithTimeout(2*time.Second))
+	b := NewBroker()
+	sub := b.Subscribe("news")
+
+	d.Within("Stop", b.Stop)
+
+	if _, ok := deadlock.Recv(d, sub.C); ok {
+		t.Error("subscriber still open after Stop")
+	}
+}
+
+// TestBroker_Ping tests liveness before and after Stop
+func TestBroker_Ping(t *testing.T) {
+	b := NewBroker()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	if err := b.Ping(ctx); err != nil {
+		t.Errorf("Ping() = %v, want nil", err)
+	}
+	b.Stop()
+	if err := b.Ping(ctx); !errors.Is(err, ErrBrokerClosed) {
+		t.Errorf("Ping() after Stop = %v, want %v", err, ErrBrokerClosed)
+	}
+}
+
+// TestBroker_UnsubscribeConfirmed tests that Unsubscribe returns with the
+// channel closed while publishes are in flight, without racing sends
+func TestBroker_UnsubscribeConfirmed(t *testing.T) {
+	b := NewBroker()
+	defer b.Stop()
+
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				b.Publish("news", "tick")
+			}
+		}
+	}()
+
+	for range 50 {
+		sub := b.Subscribe("news")
+		b.Unsubscribe("news", sub)
+		for range sub.C {
+			// drain whatever was delivered before the close
+		}
+	}
+	close(stop)
+	wg.Wait()
+}
+
+// TestBroker_AfterStop tests that every operation after Stop is safe
+func TestBroker_AfterStop(t *testing.T) {
+	b := NewBroker()
+	sub := b.Subscribe("news")
+	b.Publish("news", "in flight")
+	b.Stop()
+	b.Stop()
+
+	b.Unsubscribe("news", sub)
+	b.Publish("news", "dropped")
+	if _, ok := <-b.Subscribe("news").C; ok {
+		t.Error("Subscribe() after Stop returned an open channel")
+	}
+	for range sub.C {
+	}
+}
+
+// TestBroker_Ctx tests the errors of the context-aware variants
+func TestBroker_Ctx(t *testing.T) {
+	b := NewBroker(WithPublishQueue(1))
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := b.SubscribeCtx(canceled, "news"); !errors.Is(err, context.Canceled) {
+		t.Errorf("SubscribeCtx(canceled) = %v, want %v", err, context.Canceled)
+	}
+	sub, err := b.SubscribeCtx(context.Background(), "news", WithPolicy(Block), WithBuffer(0))
+	if err != nil {
+		t.Fatalf("SubscribeCtx() = %v", err)
+	}
+	// nobody reads: the broker holds the first message, the queue the second
+	for range 2 {
+		if err := b.PublishCtx(context.Background(), "news", "x"); err != nil {
+			t.Fatalf("PublishCtx() = %v", err)
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := b.PublishCtx(ctx, "news", "x"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("PublishCtx(full queue) = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	b.Unsubscribe("news", sub)
+	b.Stop()
+	if err := b.PublishCtx(context.Background(), "news", "x"); !errors.Is(err, ErrBrokerClosed) {
+		t.Errorf("PublishCtx() after Stop = %v, want %v", err, ErrBrokerClosed)
+	}
+	if _, err := b.SubscribeCtx(context.Background(), "news"); !errors.Is(err, ErrBrokerClosed) {
+		t.Errorf("SubscribeCtx() after Stop = %v, want %v", err, ErrBrokerClosed)
+	}
+}
+
+// TestBroker_StopDrains tests that every message a publish took reaches
+// the subscribers before Stop closes them
+func TestBroker_StopDrains(t *testing.T) {
+	b := NewBroker(WithPublishQueue(4))
+	subs := []*Subscriber{b.Subscribe("news", WithBuffer(1<<16)), b.Subscribe("news.*", WithBuffer(1<<16))}
+	subs = append(subs, b.Subscribe("news.>", WithBuffer(1<<16)))
+
+	var taken [2]atomic.Int64 // news, news.local
+	var wg sync.WaitGroup
+	for g := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			topic := []string{"news", "news.local"}[g%2]
+			for b.PublishCtx(context.Background(), topic, g) == nil {
+				taken[g%2].Add(1)
+			}
+		}()
+	}
+	time.Sleep(20 * time.Millisecond)
+	b.Stop()
+	wg.Wait()
+
+	received := 0
+	for _, sub := range subs {
+		for range sub.C {
+			received++
+		}
+	}
+	// "news" messages reach one subscriber, "news.local" ones two
+	if want := int(taken[0].Load() + 2*taken[1].Load()); received != want {
+		t.Errorf("received %d messages, want %d", received, want)
+	}
+	for _, sub := range subs {
+		if sub.Dropped() != 0 {
+			t.Errorf("%s: Dropped() = %d, want 0", sub.Topic(), sub.Dropped())
+		}
+	}
+}
+
+// TestBroker_SubscribeFunc tests serial callbacks and a waiting unsubscribe
+func TestBroker_SubscribeFunc(t *testing.T) {
+	b := NewBroker()
+	defer b.Stop()
+
+	var mu sync.Mutex
+	var running, peak, calls int
+	got := make(chan struct{}, 5)
+	unsubscribe := b.SubscribeFunc("news", func(msg Message) {
+		mu.Lock()
+		running++
+		peak = max(peak, running)
+		mu.Unlock()
+
+		time.Sleep(time.Millisecond)
+
+		mu.Lock()
+		running--
+		calls++
+		mu.Unlock()
+		got <- struct{}{}
+	})
+
+	for range 5 {
+		b.Publish("news", "hello")
+	}
+	for range 5 {
+		<-got
+	}
+	unsubscribe()
+	unsubscribe()
+
+	b.Publish("news", "after unsubscribe")
+	time.Sleep(10 * time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if calls != 5 || peak != 1 {
+		t.Errorf("calls = %d, peak concurrency = %d, want 5 and 1", calls, peak)
+	}
+}
+
+// TestSubscriber_Lag tests the pending, dropped and last-delivery accessors
+func TestSubscriber_Lag(t *testing.T) {
+	b := NewBroker()
+	b.deliveryTimeout = 10 * time.Millisecond
+	defer b.Stop()
+
+	sub := b.Subscribe("news")
+	if !sub.LastDeliveredAt().IsZero() || sub.Topic() != "news" {
+		t.Errorf("fresh subscriber: LastDeliveredAt = %v, Topic = %q", sub.LastDeliveredAt(), sub.Topic())
+	}
+
+	// nobody reads: the buffer fills and the rest are dropped
+	for range sub.Cap() + 3 {
+		b.Publish("news", "x")
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	if got := sub.Pending(); got != sub.Cap() {
+		t.Errorf("Pending() = %d, want %d", got, sub.Cap())
+	}
+	if got := sub.Dropped(); got != 3 {
+		t.Errorf("Dropped() = %d, want 3", got)
+	}
+	if time.Since(sub.LastDeliveredAt()) > time.Second {
+		t.Errorf("LastDeliveredAt() = %v, want recent", sub.LastDeliveredAt())
+	}
+}
+
+// TestBroker_Tags tests stats and group unsubscribe by tag
+func TestBroker_Tags(t *testing.T) {
+	b := NewBroker()
+	defer b.Stop()
+
+	news := b.Subscribe("news", WithTag("dashboard"))
+	sports := b.Subscribe("sports", WithTag("dashboard"), WithTag("mobile"))
+	other := b.Subscribe("news")
+
+	b.Publish("news", "x")
+	time.Sleep(20 * time.Millisecond)
+
+	stats := b.TagStats()
+	if got := stats["dashboard"]; got.Subscribers != 2 || got.Pending != 1 {
+		t.Errorf("TagStats()[dashboard] = %+v, want 2 subscribers, 1 pending", got)
+	}
+	if got := stats["mobile"]; got.Subscribers != 1 {
+		t.Errorf("TagStats()[mobile] = %+v, want 1 subscriber", got)
+	}
+
+	if n := b.UnsubscribeTag("dashboard"); n != 2 {
+		t.Errorf("UnsubscribeTag() = %d, want 2", n)
+	}
+	for _, sub := range []*Subscriber{news, sports} {
+		for range sub.C {
+		}
+	}
+	if _, ok := b.TagStats()["dashboard"]; ok {
+		t.Error("dashboard tag still present after UnsubscribeTag")
+	}
+
+	b.Publish("news", "y")
+	if msg, ok := <-other.C; !ok || msg.Payload != "x" {
+		t.Errorf("untagged subscriber got %v, %v", msg, ok)
+	}
+	if got := sports.Tags(); len(got) != 2 {
+		t.Errorf("Tags() = %v, want 2 tags", got)
+	}
+}
+
+// TestBroker_WideFanOut tests delivery to more subscribers than one
+// fan-out chunk, with the list changing between publishes
+func TestBroker_WideFanOut(t *testing.T) {
+	b := NewBroker()
+	defer b.Stop()
+
+	subs := make([]*Subscriber, 3*fanoutChunk+1)
+	for i := range subs {
+		subs[i] = b.Subscribe("wide")
+	}
+
+	b.Publish("wide", 1)
+	b.Unsubscribe("wide", subs[0])
+	b.Publish("wide", 2)
+
+	for i, sub := range subs[1:] {
+		got := map[interface{}]bool{}
+		for len(got) < 2 {
+			got[(<-sub.C).Payload] = true
+		}
+		if !got[1] || !got[2] {
+			t.Fatalf("subscriber %d got %v, want 1 and 2", i+1, got)
+		}
+	}
+	// the first publish may or may not have reached subs[0] in time,
+	// but the second must not
+	for msg := range subs[0].C {
+		if msg.Payload == 2 {
+			t.Error("unsubscribed subscriber received a later message")
+		}
+	}
+}
+
+// TestPublish_Allocs tests that publishing a pointer payload allocates
+// nothing on the way to subscribers with room, exact or by pattern
+func TestPublish_Allocs(t *testing.T) {
+	for _, patterns := range []bool{false, true} {
+		b, stop := publishAllocs(patterns)
+		payload := &order{ID: 1}
+		// the count is process-wide: take the best of a few runs, in
+		// case a goroutine left over from another test allocated
+		allocs := math.Inf(1)
+		for range 3 {
+			allocs = min(allocs, testing.AllocsPerRun(1000, func() {
+				b.Publish("orders.eu", payload)
+				b.PublishCtx(context.Background(), "orders.eu", payload)
+			}))
+		}
+		stop()
+		if allocs > 0 {
+			t.Errorf("patterns=%v: %v allocs per publish, want 0", patterns, allocs/2)
+		}
+	}
+}
+
+// TestBroker_Clock tests that messages are stamped in publish order
+// across topics, and that a forwarded message's clock is witnessed and
+// retained
+func TestBroker_Clock(t *testing.T) {
+	var ca, cb clock.Lamport
+	a := NewBroker(WithClock(&ca))
+	defer a.Stop()
+	b := NewBroker(WithClock(&cb), WithRetention(NewStoreRetention(store.NewMemory())))
+	defer b.Stop()
+
+	sa := a.Subscribe("#")
+	sb := b.Subscribe("#")
+	for _, topic := range []string{"x", "y", "x"} {
+		a.Publish(topic, topic)
+	}
+	var last Message
+	for i, m := range recv(t, sa.C, 3) {
+		if want := uint64(i + 1); m.Clock != want {
+			t.Errorf("message %d on %s has Clock %d, want %d", i, m.Topic, m.Clock, want)
+		}
+		last = m
+	}
+
+	b.Publish("z", "before")
+	if err := b.Forward(context.Background(), last); err != nil {
+		t.Fatalf("Forward() error = %v", err)
+	}
+	b.Publish("z", "after")
+	// 1, then max(1, 3)+1 for the forwarded message, then one more
+	for i, m := range recv(t, sb.C, 3) {
+		if want := []uint64{1, 4, 5}[i]; m.Clock != want {
+			t.Errorf("message %d on %s has Clock %d, want %d", i, m.Topic, m.Clock, want)
+		}
+	}
+
+	c, cancel, err := b.SubscribeFrom("x", 0)
+	if err != nil {
+		t.Fatalf("SubscribeFrom() error = %v", err)
+	}
+	defer cancel()
+	if m := recv(t, c, 1)[0]; m.Clock != 4 {
+		t.Errorf("retained message has Clock %d, want 4", m.Clock)
+	}
+
+	plain := NewBroker()
+	defer plain.Stop()
+	sp := plain.Subscribe("x")
+	plain.Forward(context.Background(), last)
+	if m := recv(t, sp.C, 1)[0]; m.Clock != 0 {
+		t.Errorf("message forwarded to a broker without a clock has Clock %d, want 0", m.Clock)
+	}
+}
@@ -0,0 +1,47 @@
+package pubsub
+
+import (
+	"context"
+	"runtime/trace"
+)
+
+// WithTraceRegions marks what the broker does in execution traces, so
+// that `go tool trace` shows it as named regions, each logging its
+// topic:
+//
+//	pubsub.publish  the run loop numbering, retaining and delivering a
+//	                message, including waits for Block subscribers
+//	pubsub.fanout   a dispatcher delivering a message to a chunk of a
+//	                large fan-out
+//	pubsub.deliver  a dispatcher delivering messages a DropAfterTimeout
+//	                subscriber had no room for
+//	pubsub.handle   a call to a SubscribeFunc function
+//
+// Regions are only recorded while a trace is running (see
+// runtime/trace.Start), and cost a check per message otherwise.
+func WithTraceRegions() BrokerOption {
+	return func(b *Broker) {
+		b.regions = true
+	}
+}
+
+// startRegion starts an execution trace region of type name on the
+// calling goroutine, logging topic, if the broker was created
+// WithTraceRegions and a trace is running. Otherwise it returns nil,
+// which endRegion ignores.
+func (b *Broker) startRegion(name, topic string) *trace.Region {
+	if !b.regions || !trace.IsEnabled() {
+		return nil
+	}
+	ctx := context.Background()
+	r := trace.StartRegion(ctx, name)
+	trace.Log(ctx, "topic", topic)
+	return r
+}
+
+// endRegion ends r, if not nil.
+func endRegion(r *trace.Region) {
+	if r != nil {
+		r.End()
+	}
+}
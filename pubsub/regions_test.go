@@ -0,0 +1,42 @@
+package pubsub
+
+import (
+	"bytes"
+	"runtime/trace"
+	"testing"
+)
+
+// TestWithTraceRegions tests that publishes and SubscribeFunc calls show
+// up in an execution trace, only when asked for
+func TestWithTraceRegions(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []BrokerOption
+		want bool
+	}{
+		{"on", []BrokerOption{WithTraceRegions()}, true},
+		{"off", nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := trace.Start(&buf); err != nil {
+				t.Skip("a trace is already running:", err)
+			}
+			b := NewBroker(tt.opts...)
+			got := make(chan Message)
+			unsubscribe := b.SubscribeFunc("orders.created", func(m Message) { got <- m })
+			b.Publish("orders.created", 1)
+			<-got
+			unsubscribe()
+			b.Stop()
+			trace.Stop()
+
+			for _, s := range []string{"pubsub.publish", "pubsub.handle", "orders.created"} {
+				if found := bytes.Contains(buf.Bytes(), []byte(s)); found != tt.want {
+					t.Errorf("trace contains %q = %v, want %v", s, found, tt.want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package pubsub
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// request is carried by a Message published with Request, for whoever
+// answers first.
+type request struct {
+	answered atomic.Bool
+	replies  chan reply // buffered for the one reply
+}
+
+type reply struct {
+	payload interface{}
+	err     error
+}
+
+// respond records the first reply; later ones are ignored.
+func (r *request) respond(payload interface{}, err error) bool {
+	if !r.answered.CompareAndSwap(false, true) {
+		return false
+	}
+	r.replies <- reply{payload, err}
+	return true
+}
+
+// IsRequest reports whether m was published with Request and expects a
+// reply through Respond.
+func (m Message) IsRequest() bool {
+	return m.req != nil
+}
+
+// Respond answers a message published with Request: the requester gets
+// payload and err. Only the first answer to a request counts, so several
+// responders can race for it; Respond reports whether this one did, and
+// false for a message that is not a request.
+func (m Message) Respond(payload interface{}, err error) bool {
+	if m.req == nil {
+		return false
+	}
+	return m.req.respond(payload, err)
+}
+
+// Request publishes payload on topic and waits for a reply, which
+// subscribers send with Message.Respond or RespondTo. It returns the
+// reply's payload and error, or fails with ErrNoResponders if the topic
+// had no subscribers when the message was delivered, ctx.Err() if ctx is
+// done first, or ErrBrokerClosed after Stop.
+//
+// The request reaches every subscriber of topic, like any message; the
+// first to answer wins.
+func (b *Broker) Request(ctx context.Context, topic string, payload interface{}) (interface{}, error) {
+	req := &request{replies: make(chan reply, 1)}
+	err := b.enqueue(Message{Topic: topic, Payload: payload, req: req}, ctx.Done(), nil)
+	if err == errCanceled {
+		return nil, ctx.Err()
+	} else if err != nil {
+		return nil, err
+	}
+
+	select {
+	case r := <-req.replies:
+		return r.payload, r.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-b.doneCh:
+		// Stop delivered the request before closing; a responder may
+		// still have answered
+		select {
+		case r := <-req.replies:
+			return r.payload, r.err
+		default:
+			return nil, ErrBrokerClosed
+		}
+	}
+}
+
+// RespondTo subscribes to topic and answers every request published
+// there with what fn returns, one call at a time, like SubscribeFunc.
+// Messages that are not requests are ignored. The context fn gets is
+// canceled by unsubscribe, which then waits for fn to return.
+func (b *Broker) RespondTo(topic string, fn func(ctx context.Context, m Message) (interface{}, error), opts ...SubscribeOption) (unsubscribe func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	unsub := b.SubscribeFunc(topic, func(m Message) {
+		if !m.IsRequest() {
+			return
+		}
+		payload, err := fn(ctx, m)
+		m.Respond(payload, err)
+	}, opts...)
+	return func() {
+		cancel()
+		unsub()
+	}
+}
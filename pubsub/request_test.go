@@ -0,0 +1,80 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestBroker_Request tests request/reply through responders
+func TestBroker_Request(t *testing.T) {
+	b := NewBroker()
+	defer b.Stop()
+	errOdd := errors.New("odd")
+	unsubscribe := b.RespondTo("double", func(ctx context.Context, m Message) (interface{}, error) {
+		n := m.Payload.(int)
+		if n%2 == 1 {
+			return nil, errOdd
+		}
+		return 2 * n, nil
+	})
+	defer unsubscribe()
+	unsubUpper := b.RespondTo("upper", func(ctx context.Context, m Message) (interface{}, error) {
+		return strings.ToUpper(m.Payload.(string)), nil
+	})
+	defer unsubUpper()
+	silent := b.Subscribe("silent") // never answers
+
+	tests := []struct {
+		topic   string
+		payload interface{}
+		want    interface{}
+		wantErr error
+	}{
+		{"double", 4, 8, nil},
+		{"upper", "hi", "HI", nil},
+		{"double", 3, nil, errOdd},
+		{"nobody", 1, nil, ErrNoResponders},
+		{"silent", 1, nil, context.DeadlineExceeded},
+	}
+	for _, tt := range tests {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		got, err := b.Request(ctx, tt.topic, tt.payload)
+		cancel()
+		if got != tt.want || !errors.Is(err, tt.wantErr) {
+			t.Errorf("Request(%s, %v) = %v, %v, want %v, %v", tt.topic, tt.payload, got, err, tt.want, tt.wantErr)
+		}
+	}
+	if m := <-silent.C; !m.IsRequest() {
+		t.Error("IsRequest() = false for a request")
+	}
+}
+
+// TestMessage_Respond tests that only the first answer counts
+func TestMessage_Respond(t *testing.T) {
+	b := NewBroker()
+	defer b.Stop()
+	first, second := b.Subscribe("q"), b.Subscribe("q")
+
+	replies := make(chan interface{})
+	go func() {
+		r, _ := b.Request(context.Background(), "q", nil)
+		replies <- r
+	}()
+	if !(<-first.C).Respond("first", nil) {
+		t.Error("first Respond() = false")
+	}
+	if (<-second.C).Respond("second", nil) {
+		t.Error("second Respond() = true")
+	}
+	if r := <-replies; r != "first" {
+		t.Errorf("reply = %v, want first", r)
+	}
+
+	b.Publish("q", "not a request")
+	if m := <-first.C; m.IsRequest() || m.Respond("x", nil) {
+		t.Error("a published message took a reply")
+	}
+}
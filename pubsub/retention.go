@@ -0,0 +1,406 @@
+package pubsub
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/arifmahmudrana/go-snippets/store"
+)
+
+// Retention keeps published messages so consumers can catch up on what
+// they missed. The broker calls Append and Last from its run loop only;
+// Since may be called from any goroutine.
+type Retention interface {
+	// Append stores m, whose Seq is one more than the topic's last.
+	Append(m Message) error
+	// Since returns the retained messages of topic with Seq > after, in
+	// order.
+	Since(topic string, after uint64) ([]Message, error)
+	// Last returns the highest stored Seq of topic, or 0.
+	Last(topic string) (uint64, error)
+}
+
+// Token returns a resume token for m: passing it to Resume delivers the
+// messages published after m.
+func (m Message) Token() string {
+	return strconv.FormatUint(m.Seq, 10)
+}
+
+// parseToken returns the Seq a token names. The empty token means
+// "from the beginning".
+func parseToken(token string) (uint64, error) {
+	if token == "" {
+		return 0, nil
+	}
+	seq, err := strconv.ParseUint(token, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidToken, token)
+	}
+	return seq, nil
+}
+
+// MemoryRetention keeps the last few messages of every topic in memory,
+// in a ring buffer per topic.
+type MemoryRetention struct {
+	limit  int
+	maxAge time.Duration
+	now    func() time.Time
+
+	mu     sync.RWMutex
+	topics map[string]*memoryTopic
+}
+
+type memoryTopic struct {
+	msgs ring[Message]
+	last uint64 // kept when every message has expired
+}
+
+// MemoryOption configures a MemoryRetention.
+type MemoryOption func(*MemoryRetention)
+
+// WithMaxAge also evicts messages published more than d ago, however
+// few a topic has.
+func WithMaxAge(d time.Duration) MemoryOption {
+	return func(r *MemoryRetention) {
+		r.maxAge = d
+	}
+}
+
+// NewMemoryRetention returns a retention keeping up to limit messages
+// per topic, evicting the oldest first.
+func NewMemoryRetention(limit int, opts ...MemoryOption) *MemoryRetention {
+	r := &MemoryRetention{limit: max(limit, 1), now: time.Now, topics: make(map[string]*memoryTopic)}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+func (r *MemoryRetention) Append(m Message) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	t, ok := r.topics[m.Topic]
+	if !ok {
+		t = &memoryTopic{}
+		r.topics[m.Topic] = t
+	}
+	if t.msgs.len() == r.limit {
+		t.msgs.pop()
+	}
+	t.msgs.push(m)
+	t.last = m.Seq
+	if r.maxAge > 0 {
+		for cutoff := r.now().Add(-r.maxAge); t.msgs.len() > 0 && t.msgs.peek().Time.Before(cutoff); {
+			t.msgs.pop()
+		}
+	}
+	return nil
+}
+
+func (r *MemoryRetention) Since(topic string, after uint64) ([]Message, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	t, ok := r.topics[topic]
+	if !ok {
+		return nil, nil
+	}
+	var cutoff time.Time
+	if r.maxAge > 0 {
+		cutoff = r.now().Add(-r.maxAge)
+	}
+	var msgs []Message
+	t.msgs.each(func(m Message) {
+		if m.Seq > after && !m.Time.Before(cutoff) {
+			msgs = append(msgs, m)
+		}
+	})
+	return msgs, nil
+}
+
+func (r *MemoryRetention) Last(topic string) (uint64, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if t, ok := r.topics[topic]; ok {
+		return t.last, nil
+	}
+	return 0, nil
+}
+
+// StoreRetention persists messages in a store.Store, one key per
+// message, so they survive a restart along with the topic's sequence
+// numbers. Payloads are stored as JSON and come back as the generic
+// values encoding/json decodes into (map[string]any, float64, ...).
+type StoreRetention struct {
+	s     store.Store
+	limit uint64
+}
+
+// StoreOption configures a StoreRetention.
+type StoreOption func(*StoreRetention)
+
+// WithStoreLimit keeps the last n messages of each topic, deleting the
+// one n before as each is appended. By default every message is kept.
+func WithStoreLimit(n int) StoreOption {
+	return func(r *StoreRetention) {
+		r.limit = uint64(max(n, 0))
+	}
+}
+
+// NewStoreRetention returns a retention backed by s, such as a
+// store.File or, for messages arriving quickly, a wal.Store.
+func NewStoreRetention(s store.Store, opts ...StoreOption) *StoreRetention {
+	r := &StoreRetention{s: s}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// storedMessage is the JSON form of a retained message.
+type storedMessage struct {
+	Seq     uint64    `json:"seq"`
+	Time    time.Time `json:"time"`
+	Clock   uint64    `json:"clock,omitempty"`
+	Payload any       `json:"payload"`
+}
+
+// prefix returns the key prefix of topic. Escaping keeps topic "a" from
+// matching the keys of topic "a/b".
+func (r *StoreRetention) prefix(topic string) string {
+	return "pubsub/" + url.PathEscape(topic) + "/"
+}
+
+func (r *StoreRetention) key(topic string, seq uint64) string {
+	return fmt.Sprintf("%s%020d", r.prefix(topic), seq)
+}
+
+func (r *StoreRetention) Append(m Message) error {
+	data, err := json.Marshal(storedMessage{Seq: m.Seq, Time: m.Time, Clock: m.Clock, Payload: m.Payload})
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	if err := r.s.Put(ctx, r.key(m.Topic, m.Seq), data); err != nil {
+		return err
+	}
+	if r.limit > 0 && m.Seq > r.limit {
+		return r.s.Delete(ctx, r.key(m.Topic, m.Seq-r.limit))
+	}
+	return nil
+}
+
+func (r *StoreRetention) Since(topic string, after uint64) ([]Message, error) {
+	ctx := context.Background()
+	keys, err := r.s.List(ctx, r.prefix(topic))
+	if err != nil {
+		return nil, err
+	}
+
+	from := r.key(topic, after+1)
+	var msgs []Message
+	for _, k := range keys {
+		if k < from {
+			continue // zero-padded keys sort by Seq
+		}
+		data, err := r.s.Get(ctx, k)
+		if err != nil {
+			return nil, err
+		}
+		var sm storedMessage
+		if err := json.Unmarshal(data, &sm); err != nil {
+			return nil, fmt.Errorf("pubsub: decoding %s: %w", k, err)
+		}
+		msgs = append(msgs, Message{Topic: topic, Payload: sm.Payload, Seq: sm.Seq, Time: sm.Time, Clock: sm.Clock})
+	}
+	return msgs, nil
+}
+
+func (r *StoreRetention) Last(topic string) (uint64, error) {
+	keys, err := r.s.List(context.Background(), r.prefix(topic))
+	if err != nil || len(keys) == 0 {
+		return 0, err
+	}
+	last := keys[len(keys)-1]
+	return strconv.ParseUint(strings.TrimPrefix(last, r.prefix(topic)), 10, 64)
+}
+
+// WithRetainedTopics limits WithRetention to the topics matching one of
+// patterns (see MatchTopic), such as "orders" or "audit.#"; the others
+// are delivered live only, and cannot be resumed. By default every
+// topic is retained.
+func WithRetainedTopics(patterns ...string) BrokerOption {
+	return func(b *Broker) {
+		b.retained = append(b.retained, patterns...)
+	}
+}
+
+// retains reports whether topic's messages are retained.
+func (b *Broker) retains(topic string) bool {
+	if b.retention == nil {
+		return false
+	}
+	if len(b.retained) == 0 {
+		return true
+	}
+	for _, p := range b.retained {
+		if MatchTopic(p, topic) {
+			return true
+		}
+	}
+	return false
+}
+
+// Resume subscribes to topic starting right after the message token was
+// taken from, so a consumer that saves m.Token() as it goes can crash,
+// restart and continue without gaps or duplicates. The empty token
+// replays everything retained.
+//
+// Retained messages are delivered first, then live ones. Messages the
+// live subscription misses, e.g. because the consumer was slow, are
+// fetched from retention, and anything seen twice is skipped. If a
+// token's successors are no longer retained, or the token is later than
+// the last message published, as a token from before a restart that lost
+// retention can be, Resume fails with ErrTokenExpired.
+//
+// The returned channel is closed after cancel is called or the broker
+// stops; cancel must be called to release the subscription. It is also
+// closed early if messages the live subscription missed have expired
+// from retention too, or cannot be read from it, so a gap is never
+// skipped silently: resuming from the last token received then reports
+// why. Stats counts these as ResumeGaps.
+func (b *Broker) Resume(topic, token string) (c <-chan Message, cancel func(), err error) {
+	after, err := parseToken(token)
+	if err != nil {
+		return nil, nil, err
+	}
+	return b.resume(topic, after, token != "")
+}
+
+// SubscribeFrom subscribes to topic starting with the message numbered
+// seq, replaying retained messages before live ones as Resume does. Seq
+// 0 starts from the oldest message retained; a seq no longer retained,
+// or past the next to be published, fails with ErrTokenExpired.
+func (b *Broker) SubscribeFrom(topic string, seq uint64) (c <-chan Message, cancel func(), err error) {
+	if seq == 0 {
+		return b.resume(topic, 0, false)
+	}
+	return b.resume(topic, seq-1, true)
+}
+
+// resume implements Resume from the message after seq after. Unless
+// exact, it starts from the oldest retained message if that is later.
+func (b *Broker) resume(topic string, after uint64, exact bool) (c <-chan Message, cancel func(), err error) {
+	if IsPattern(topic) {
+		return nil, nil, fmt.Errorf("%w: %q", ErrPatternTopic, topic)
+	}
+	if !b.retains(topic) {
+		return nil, nil, fmt.Errorf("%w for %q", ErrNoRetention, topic)
+	}
+
+	// Subscribe before reading the backlog, so nothing published in
+	// between is missed; the overlap is removed below.
+	live, err := b.TrySubscribe(topic)
+	if err != nil {
+		return nil, nil, err
+	}
+	// The last seq, read before the backlog, tells whether an empty
+	// backlog means nothing was published after, or all of it expired.
+	var last uint64
+	if !b.exec(func() { last, err = b.retention.Last(topic) }) {
+		return nil, nil, ErrBrokerClosed
+	}
+	if err == nil && after > last {
+		// e.g. a token from before a restart that lost the retained
+		// messages, and their numbering with them
+		err = fmt.Errorf("%w: seq %d is past the last published, %d", ErrTokenExpired, after, last)
+	}
+	var backlog []Message
+	if err == nil {
+		backlog, err = b.retention.Since(topic, after)
+	}
+	if err == nil {
+		oldest := last + 1 // once every message up to last has expired
+		if len(backlog) > 0 {
+			oldest = backlog[0].Seq
+		}
+		if oldest > after+1 {
+			if exact {
+				err = fmt.Errorf("%w: want seq %d, oldest retained is %d", ErrTokenExpired, after+1, oldest)
+			} else {
+				after = oldest - 1 // the older ones were evicted
+			}
+		}
+	}
+	if err != nil {
+		b.Unsubscribe(topic, live)
+		return nil, nil, err
+	}
+
+	out := make(chan Message)
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		defer close(out)
+
+		next := after + 1
+		emit := func(m Message) bool {
+			select {
+			case out <- m:
+				next = m.Seq + 1
+				return true
+			case <-stop:
+				return false
+			}
+		}
+
+		for _, m := range backlog {
+			if !emit(m) {
+				return
+			}
+		}
+		for m := range live.C {
+			if m.Seq < next {
+				continue // duplicate of a retained or late message
+			}
+			if m.Seq > next {
+				// fill the gap from retention, up to m itself; if that
+				// fails or the gap has expired, stop rather than skip it
+				missed, err := b.retention.Since(topic, next-1)
+				if err != nil || len(missed) == 0 || missed[0].Seq != next {
+					b.resumeGaps.Add(1)
+					return
+				}
+				for _, r := range missed {
+					if r.Seq >= m.Seq {
+						break
+					}
+					if !emit(r) {
+						return
+					}
+				}
+			}
+			if !emit(m) {
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return out, func() {
+		once.Do(func() {
+			b.Unsubscribe(topic, live)
+			close(stop)
+			<-done
+		})
+	}, nil
+}
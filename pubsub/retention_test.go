@@ -0,0 +1,359 @@
+package pubsub
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/arifmahmudrana/go-snippets/store"
+	"github.com/arifmahmudrana/go-snippets/wal"
+)
+
+// recv reads n messages from c, failing the test if they take too long.
+func recv(t *testing.T, c <-chan Message, n int) []Message {
+	t.Helper()
+	var msgs []Message
+	for range n {
+		select {
+		case m, ok := <-c:
+			if !ok {
+				t.Fatalf("channel closed after %d messages, want %d", len(msgs), n)
+			}
+			msgs = append(msgs, m)
+		case <-time.After(2 * time.Second):
+			t.Fatalf("got %d messages, want %d", len(msgs), n)
+		}
+	}
+	return msgs
+}
+
+// checkSeqs fails unless msgs carry exactly the sequence numbers want.
+func checkSeqs(t *testing.T, msgs []Message, want ...uint64) {
+	t.Helper()
+	for i, m := range msgs {
+		if i >= len(want) || m.Seq != want[i] {
+			t.Fatalf("message %d has Seq %d, want sequence %v", i, m.Seq, want)
+		}
+	}
+}
+
+// TestBroker_Resume tests that a consumer continues after its token
+// without gaps or duplicates
+func TestBroker_Resume(t *testing.T) {
+	b := NewBroker(WithRetention(NewMemoryRetention(100)))
+	defer b.Stop()
+
+	for i := range 5 {
+		b.Publish("orders", i)
+	}
+
+	c, cancel, err := b.Resume("orders", "")
+	if err != nil {
+		t.Fatalf("Resume() error = %v", err)
+	}
+	msgs := recv(t, c, 3)
+	checkSeqs(t, msgs, 1, 2, 3)
+	token := msgs[2].Token()
+	cancel() // the consumer "crashes"
+
+	b.Publish("orders", 5)
+	b.Publish("orders", 6)
+
+	c, cancel, err = b.Resume("orders", token)
+	if err != nil {
+		t.Fatalf("Resume(%q) error = %v", token, err)
+	}
+	defer cancel()
+	checkSeqs(t, recv(t, c, 4), 4, 5, 6, 7)
+
+	b.Publish("orders", 7) // live
+	checkSeqs(t, recv(t, c, 1), 8)
+}
+
+// TestBroker_ResumeSlowConsumer tests that live messages dropped for a
+// slow consumer are filled in from retention
+func TestBroker_ResumeSlowConsumer(t *testing.T) {
+	b := NewBroker(WithRetention(NewMemoryRetention(100)))
+	b.deliveryTimeout = time.Millisecond
+	defer b.Stop()
+
+	c, cancel, err := b.Resume("t", "")
+	if err != nil {
+		t.Fatalf("Resume() error = %v", err)
+	}
+	defer cancel()
+
+	for i := range 40 {
+		b.Publish("t", i)
+	}
+	want := make([]uint64, 40)
+	for i := range want {
+		want[i] = uint64(i + 1)
+	}
+	checkSeqs(t, recv(t, c, 40), want...)
+}
+
+// TestBroker_ResumeAfterRestart tests persistence of messages and
+// sequence numbers across broker restarts
+func TestBroker_ResumeAfterRestart(t *testing.T) {
+	s := store.NewMemory()
+
+	b := NewBroker(WithRetention(NewStoreRetention(s)))
+	b.Publish("a", "one")
+	b.Publish("a/b", "other topic")
+	b.Publish("a", "two")
+	b.Stop()
+
+	b = NewBroker(WithRetention(NewStoreRetention(s)))
+	defer b.Stop()
+	b.Publish("a", "three")
+
+	c, cancel, err := b.Resume("a", "1")
+	if err != nil {
+		t.Fatalf("Resume() error = %v", err)
+	}
+	defer cancel()
+	msgs := recv(t, c, 2)
+	checkSeqs(t, msgs, 2, 3)
+	if msgs[0].Payload != "two" || msgs[1].Payload != "three" {
+		t.Errorf("payloads = %v, %v, want two, three", msgs[0].Payload, msgs[1].Payload)
+	}
+	if n := b.RetentionErrors(); n != 0 {
+		t.Errorf("RetentionErrors() = %d, want 0", n)
+	}
+}
+
+// TestStoreRetention_WAL tests retention in a write-ahead log, limited
+// per topic, across a broker restart
+func TestStoreRetention_WAL(t *testing.T) {
+	dir := t.TempDir()
+	open := func() (*Broker, func()) {
+		s, err := wal.OpenStore(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b := NewBroker(WithRetention(NewStoreRetention(s, WithStoreLimit(3))))
+		return b, func() {
+			b.Stop()
+			s.Close()
+		}
+	}
+
+	b, stop := open()
+	for i := range 5 {
+		b.Publish("t", i)
+	}
+	b.exec(func() {}) // retained
+	stop()
+
+	b, stop = open()
+	defer stop()
+	if _, _, err := b.Resume("t", "1"); !errors.Is(err, ErrTokenExpired) {
+		t.Errorf("Resume(%q) error = %v, want ErrTokenExpired", "1", err)
+	}
+	c, cancel, err := b.Resume("t", "")
+	if err != nil {
+		t.Fatalf("Resume() error = %v", err)
+	}
+	defer cancel()
+	b.Publish("t", 5)
+	checkSeqs(t, recv(t, c, 4), 3, 4, 5, 6)
+}
+
+// TestBroker_ResumeErrors tests the failure modes of Resume
+func TestBroker_ResumeErrors(t *testing.T) {
+	plain := NewBroker()
+	defer plain.Stop()
+	if _, _, err := plain.Resume("t", ""); !errors.Is(err, ErrNoRetention) {
+		t.Errorf("Resume() without retention error = %v, want ErrNoRetention", err)
+	}
+
+	b := NewBroker(WithRetention(NewMemoryRetention(2)))
+	defer b.Stop()
+	for i := range 5 {
+		b.Publish("t", i)
+	}
+
+	tests := []struct {
+		token string
+		want  error
+	}{
+		{token: "x", want: ErrInvalidToken},
+		{token: "1", want: ErrTokenExpired}, // seq 2 is gone
+		{token: "3", want: nil},
+	}
+	for _, tt := range tests {
+		_, cancel, err := b.Resume("t", tt.token)
+		if !errors.Is(err, tt.want) {
+			t.Errorf("Resume(%q) error = %v, want %v", tt.token, err, tt.want)
+		}
+		if cancel != nil {
+			cancel()
+		}
+	}
+}
+
+// TestBroker_ResumeAfterRetentionLost tests that a token from before a
+// restart that lost retention is expired, rather than skipping every
+// message until the new numbering catches up with it
+func TestBroker_ResumeAfterRetentionLost(t *testing.T) {
+	b := NewBroker(WithRetention(NewMemoryRetention(100)))
+	defer b.Stop()
+	for i := range 3 {
+		b.Publish("t", i)
+	}
+
+	if _, _, err := b.Resume("t", "500"); !errors.Is(err, ErrTokenExpired) {
+		t.Errorf("Resume(%q) error = %v, want ErrTokenExpired", "500", err)
+	}
+	if _, _, err := b.SubscribeFrom("t", 5); !errors.Is(err, ErrTokenExpired) {
+		t.Errorf("SubscribeFrom(5) error = %v, want ErrTokenExpired", err)
+	}
+	c, cancel, err := b.SubscribeFrom("t", 4) // the next to be published
+	if err != nil {
+		t.Fatalf("SubscribeFrom(4) error = %v", err)
+	}
+	defer cancel()
+	b.Publish("t", 3)
+	checkSeqs(t, recv(t, c, 1), 4)
+}
+
+// TestBroker_ResumeMaxAge tests that a token whose successors have all
+// aged out of retention is expired, rather than resumed past the gap
+func TestBroker_ResumeMaxAge(t *testing.T) {
+	var skew atomic.Int64 // how far the retention's clock is ahead
+	r := NewMemoryRetention(100, WithMaxAge(time.Minute))
+	r.now = func() time.Time { return time.Now().Add(time.Duration(skew.Load())) }
+	b := NewBroker(WithRetention(r))
+	defer b.Stop()
+	for i := range 5 {
+		b.Publish("orders", i)
+	}
+	skew.Store(int64(time.Hour)) // every message is older than maxAge
+
+	tests := []struct {
+		token string
+		want  error
+	}{
+		{token: "2", want: ErrTokenExpired},
+		{token: "5", want: nil}, // nothing was published after it
+		{token: "", want: nil},  // from the oldest retained: none
+	}
+	for _, tt := range tests {
+		_, cancel, err := b.Resume("orders", tt.token)
+		if !errors.Is(err, tt.want) {
+			t.Errorf("Resume(%q) error = %v, want %v", tt.token, err, tt.want)
+		}
+		if cancel != nil {
+			cancel()
+		}
+	}
+
+	c, cancel, err := b.Resume("orders", "")
+	if err != nil {
+		t.Fatalf("Resume() error = %v", err)
+	}
+	defer cancel()
+	skew.Store(0)
+	b.Publish("orders", 5)
+	checkSeqs(t, recv(t, c, 1), 6)
+}
+
+// TestBroker_ResumeExpiredGap tests that a Resume channel whose live
+// subscription missed messages retention no longer has is closed,
+// rather than skipping them
+func TestBroker_ResumeExpiredGap(t *testing.T) {
+	b := NewBroker(WithRetention(NewMemoryRetention(2)))
+	b.deliveryTimeout = time.Millisecond
+	defer b.Stop()
+
+	c, cancel, err := b.Resume("t", "")
+	if err != nil {
+		t.Fatalf("Resume() error = %v", err)
+	}
+	defer cancel()
+	// too many for the consumer, which is not reading, and retention
+	for i := range 40 {
+		b.Publish("t", i)
+	}
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		if st := b.Stats(); st.Delivered+st.Dropped == 40 {
+			break
+		}
+	}
+
+	// one message waiting to be sent on c, and a buffer full
+	checkSeqs(t, recv(t, c, defaultBuffer+1), 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11)
+	b.Publish("t", 40) // shows the live subscription the gap
+	select {
+	case m, ok := <-c:
+		if ok {
+			t.Fatalf("received Seq %d after the gap, want c closed", m.Seq)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("c not closed after the gap")
+	}
+	if n := b.Stats().ResumeGaps; n != 1 {
+		t.Errorf("Stats().ResumeGaps = %d, want 1", n)
+	}
+	if _, _, err := b.Resume("t", "11"); !errors.Is(err, ErrTokenExpired) {
+		t.Errorf("Resume(%q) after the gap error = %v, want ErrTokenExpired", "11", err)
+	}
+}
+
+// TestBroker_SubscribeFrom tests replay from a sequence number on a
+// retained topic, and topics left out of retention
+func TestBroker_SubscribeFrom(t *testing.T) {
+	b := NewBroker(WithRetention(NewMemoryRetention(3)), WithRetainedTopics("orders.#"))
+	defer b.Stop()
+	for i := range 5 {
+		b.Publish("orders.eu", i)
+		b.Publish("chatter", i)
+	}
+
+	tests := []struct {
+		seq     uint64
+		want    []uint64
+		wantErr error
+	}{
+		{0, []uint64{3, 4, 5}, nil}, // from the oldest retained
+		{4, []uint64{4, 5}, nil},
+		{2, nil, ErrTokenExpired},
+	}
+	for _, tt := range tests {
+		c, cancel, err := b.SubscribeFrom("orders.eu", tt.seq)
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("SubscribeFrom(%d) error = %v, want %v", tt.seq, err, tt.wantErr)
+			continue
+		}
+		if err == nil {
+			checkSeqs(t, recv(t, c, len(tt.want)), tt.want...)
+			cancel()
+		}
+	}
+
+	if _, _, err := b.SubscribeFrom("chatter", 0); !errors.Is(err, ErrNoRetention) {
+		t.Errorf("SubscribeFrom(chatter) error = %v, want ErrNoRetention", err)
+	}
+}
+
+// TestMemoryRetention_MaxAge tests eviction by age as well as count
+func TestMemoryRetention_MaxAge(t *testing.T) {
+	r := NewMemoryRetention(10, WithMaxAge(time.Minute))
+	now := time.Unix(1000, 0)
+	r.now = func() time.Time { return now }
+	for seq := uint64(1); seq <= 4; seq++ {
+		r.Append(Message{Topic: "t", Seq: seq, Time: now})
+		now = now.Add(30 * time.Second)
+	}
+
+	msgs, _ := r.Since("t", 0)
+	checkSeqs(t, msgs, 3, 4) // 1 and 2 are over a minute old
+	if len(msgs) != 2 {
+		t.Errorf("Since() = %d messages, want 2", len(msgs))
+	}
+	if last, _ := r.Last("t"); last != 4 {
+		t.Errorf("Last() = %d, want 4", last)
+	}
+}
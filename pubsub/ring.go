@@ -0,0 +1,55 @@
+package pubsub
+
+// ring is a FIFO queue in a circular buffer that doubles when full.
+// Once it has grown to fit the flow through it, pushing and popping do
+// not allocate, unlike appending to a slice and reslicing its front.
+type ring[T any] struct {
+	buf  []T
+	head int // index of the oldest element
+	n    int
+}
+
+// len returns the number of queued elements.
+func (r *ring[T]) len() int {
+	return r.n
+}
+
+// push appends v.
+func (r *ring[T]) push(v T) {
+	if r.n == len(r.buf) {
+		buf := make([]T, max(2*len(r.buf), 4))
+		copy(buf, r.buf[r.head:])
+		copy(buf[len(r.buf)-r.head:], r.buf[:r.head])
+		r.buf, r.head = buf, 0
+	}
+	r.buf[(r.head+r.n)%len(r.buf)] = v
+	r.n++
+}
+
+// pop removes and returns the oldest element; the ring must not be
+// empty. Its slot is cleared so the ring holds no reference to it.
+func (r *ring[T]) pop() T {
+	var zero T
+	v := r.buf[r.head]
+	r.buf[r.head] = zero
+	r.head = (r.head + 1) % len(r.buf)
+	r.n--
+	return v
+}
+
+// peek returns the oldest element; the ring must not be empty.
+func (r *ring[T]) peek() T {
+	return r.buf[r.head]
+}
+
+// each calls fn for every element, oldest first.
+func (r *ring[T]) each(fn func(T)) {
+	for i := range r.n {
+		fn(r.buf[(r.head+i)%len(r.buf)])
+	}
+}
+
+// at returns the i-th element, oldest first.
+func (r *ring[T]) at(i int) T {
+	return r.buf[(r.head+i)%len(r.buf)]
+}
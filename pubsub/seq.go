@@ -0,0 +1,52 @@
+package pubsub
+
+// Gap is a run of sequence numbers a consumer never received, From to To
+// inclusive.
+type Gap struct {
+	Topic    string
+	From, To uint64
+}
+
+// Len returns the number of missed messages.
+func (g Gap) Len() uint64 {
+	return g.To - g.From + 1
+}
+
+// SeqTracker follows the sequence numbers a consumer has seen, per
+// topic, and reports the gaps. It is not safe for concurrent use; give
+// each consumer its own.
+//
+// Delivery to one subscriber is not strictly ordered: a message that had
+// to wait for room in a slow subscriber's buffer can arrive after a
+// later one. Such a message is first reported as part of a gap and then
+// accepted when it turns up, so a gap means "not received yet" rather
+// than "lost".
+type SeqTracker struct {
+	last map[string]uint64
+}
+
+// NewSeqTracker returns a tracker that has seen nothing. The first
+// message of each topic sets its starting point, so a subscription
+// opened mid-stream is not reported as one large gap.
+func NewSeqTracker() *SeqTracker {
+	return &SeqTracker{last: make(map[string]uint64)}
+}
+
+// Observe records m and returns the gap directly before it, if any.
+// Late and duplicate messages report no gap.
+func (t *SeqTracker) Observe(m Message) (Gap, bool) {
+	last, seen := t.last[m.Topic]
+	if seen && m.Seq <= last {
+		return Gap{}, false
+	}
+	t.last[m.Topic] = m.Seq
+	if !seen || m.Seq == last+1 {
+		return Gap{}, false
+	}
+	return Gap{Topic: m.Topic, From: last + 1, To: m.Seq - 1}, true
+}
+
+// Last returns the highest sequence number seen on topic, or 0.
+func (t *SeqTracker) Last(topic string) uint64 {
+	return t.last[topic]
+}
@@ -0,0 +1,86 @@
+package pubsub
+
+import (
+	"testing"
+	"time"
+)
+
+// TestBroker_SeqNumbers tests per-topic numbering of published messages
+func TestBroker_SeqNumbers(t *testing.T) {
+	b := NewBroker()
+	defer b.Stop()
+	a, other := b.Subscribe("a"), b.Subscribe("b")
+
+	b.Publish("a", "x")
+	b.Publish("b", "y")
+	b.Publish("a", "z")
+
+	for _, want := range []uint64{1, 2} {
+		if m := <-a.C; m.Seq != want || m.Time.IsZero() {
+			t.Errorf("topic a got Seq %d, Time %v, want Seq %d", m.Seq, m.Time, want)
+		}
+	}
+	if m := <-other.C; m.Seq != 1 {
+		t.Errorf("topic b got Seq %d, want 1", m.Seq)
+	}
+}
+
+// TestSeqTracker_Observe tests gap reporting
+func TestSeqTracker_Observe(t *testing.T) {
+	tests := []struct {
+		seq     uint64
+		wantGap bool
+		from    uint64
+		to      uint64
+	}{
+		{seq: 5},                                // first message sets the start
+		{seq: 6},                                // in order
+		{seq: 9, wantGap: true, from: 7, to: 8}, // two missed
+		{seq: 7},                                // late arrival
+		{seq: 9},                                // duplicate
+		{seq: 10},                               // in order again
+		{seq: 12, wantGap: true, from: 11, to: 11},
+	}
+
+	tr := NewSeqTracker()
+	for _, tt := range tests {
+		gap, ok := tr.Observe(Message{Topic: "t", Seq: tt.seq, Time: time.Now()})
+		if ok != tt.wantGap || (ok && (gap.From != tt.from || gap.To != tt.to)) {
+			t.Errorf("Observe(%d) = %+v, %v, want gap %v [%d, %d]", tt.seq, gap, ok, tt.wantGap, tt.from, tt.to)
+		}
+	}
+	if got := tr.Last("t"); got != 12 {
+		t.Errorf("Last() = %d, want 12", got)
+	}
+}
+
+// TestSeqTracker_Drops tests that drops by a slow subscriber show as gaps
+func TestSeqTracker_Drops(t *testing.T) {
+	b := NewBroker()
+	b.deliveryTimeout = time.Millisecond
+	defer b.Stop()
+
+	sub := b.Subscribe("t")
+	for range sub.Cap() + 5 {
+		b.Publish("t", nil)
+	}
+	for sub.Dropped() < 5 {
+		time.Sleep(time.Millisecond)
+	}
+
+	tr := NewSeqTracker()
+	var missed uint64
+	observe := func() {
+		if gap, ok := tr.Observe(<-sub.C); ok {
+			missed += gap.Len()
+		}
+	}
+	for range sub.Cap() {
+		observe()
+	}
+	b.Publish("t", nil) // lands after the drops
+	observe()
+	if missed != 5 {
+		t.Errorf("missed = %d, want 5", missed)
+	}
+}
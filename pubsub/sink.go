@@ -0,0 +1,87 @@
+package pubsub
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/arifmahmudrana/go-snippets/rotate"
+)
+
+// Codec turns a message into the bytes a sink writes, typically one
+// line.
+type Codec func(Message) ([]byte, error)
+
+// JSONLines encodes a message as one JSON object per line, with topic,
+// seq, time and payload fields.
+func JSONLines(m Message) ([]byte, error) {
+	b, err := json.Marshal(struct {
+		Topic   string    `json:"topic"`
+		Seq     uint64    `json:"seq"`
+		Time    time.Time `json:"time"`
+		Payload any       `json:"payload"`
+	}{m.Topic, m.Seq, m.Time, m.Payload})
+	if err != nil {
+		return nil, err
+	}
+	return append(b, '\n'), nil
+}
+
+// TextLines encodes a message as a human-readable line.
+func TextLines(m Message) ([]byte, error) {
+	return fmt.Appendf(nil, "%s %s #%d %v\n", m.Time.Format(time.RFC3339Nano), m.Topic, m.Seq, m.Payload), nil
+}
+
+// SinkWriter writes every message published to topic to w, encoded with
+// codec, until stop is called. Writing never holds up the broker beyond
+// the subscriber's own buffer; if encoding or writing fails, later
+// messages are skipped and stop returns the first error.
+func SinkWriter(b *Broker, topic string, w io.Writer, codec Codec) (stop func() error) {
+	var err error
+	unsubscribe := b.SubscribeFunc(topic, func(m Message) {
+		if err != nil {
+			return
+		}
+		var data []byte
+		if data, err = codec(m); err == nil {
+			_, err = w.Write(data)
+		}
+	})
+
+	var once sync.Once
+	return func() error {
+		once.Do(unsubscribe)
+		return err
+	}
+}
+
+// SinkStdout prints every message published to topic as TextLines.
+func SinkStdout(b *Broker, topic string) (stop func() error) {
+	return SinkWriter(b, topic, os.Stdout, TextLines)
+}
+
+// SinkFile appends every message published to topic to path, rotating
+// the file once it reaches maxSize bytes and keeping the given number of
+// rotated backups. stop also closes the file.
+func SinkFile(b *Broker, topic, path string, maxSize int64, backups int, codec Codec) (stop func() error, err error) {
+	f, err := rotate.Open(path, maxSize, backups)
+	if err != nil {
+		return nil, err
+	}
+	stopSink := SinkWriter(b, topic, f, codec)
+
+	var once sync.Once
+	var stopErr error
+	return func() error {
+		once.Do(func() {
+			stopErr = stopSink()
+			if err := f.Close(); stopErr == nil {
+				stopErr = err
+			}
+		})
+		return stopErr
+	}, nil
+}
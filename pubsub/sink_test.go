@@ -0,0 +1,105 @@
+package pubsub
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestSinkWriter tests JSON lines written for a topic
+func TestSinkWriter(t *testing.T) {
+	b := NewBroker()
+	defer b.Stop()
+
+	var buf bytes.Buffer
+	stop := SinkWriter(b, "orders", &buf, JSONLines)
+	b.Publish("orders", map[string]int{"id": 1})
+	b.Publish("other", "ignored")
+	b.Publish("orders", map[string]int{"id": 2})
+	if err := stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want 2:\n%s", len(lines), buf.String())
+	}
+	var got struct {
+		Topic   string
+		Seq     uint64
+		Payload map[string]int
+	}
+	if err := json.Unmarshal([]byte(lines[1]), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Topic != "orders" || got.Seq != 2 || got.Payload["id"] != 2 {
+		t.Errorf("second line = %+v", got)
+	}
+}
+
+// errWriter fails every write.
+type errWriter struct{}
+
+func (errWriter) Write([]byte) (int, error) { return 0, errors.New("disk full") }
+
+// TestSinkWriter_Error tests that the first write error is reported
+func TestSinkWriter_Error(t *testing.T) {
+	b := NewBroker()
+	defer b.Stop()
+
+	stop := SinkWriter(b, "t", errWriter{}, TextLines)
+	b.Publish("t", 1)
+	b.Publish("t", 2)
+	if err := stop(); err == nil || err.Error() != "disk full" {
+		t.Errorf("stop() = %v, want disk full", err)
+	}
+}
+
+// TestSinkFile tests a rotating file sink
+func TestSinkFile(t *testing.T) {
+	b := NewBroker()
+	defer b.Stop()
+
+	path := filepath.Join(t.TempDir(), "orders.log")
+	stop, err := SinkFile(b, "orders", path, 100, 3, TextLines)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range 10 {
+		b.Publish("orders", i)
+	}
+	if err := stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	var lines int
+	for _, name := range []string{path, path + ".1", path + ".2", path + ".3"} {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatalf("reading %s: %v", filepath.Base(name), err)
+		}
+		if len(data) > 100 {
+			t.Errorf("%s is %d bytes, want at most 100", filepath.Base(name), len(data))
+		}
+		lines += strings.Count(string(data), "\n")
+	}
+	if lines == 0 || lines > 10 {
+		t.Errorf("%d lines across the files, want 1..10", lines)
+	}
+	if !strings.Contains(mustRead(t, path), "orders #10 9") {
+		t.Errorf("current file lacks the last message:\n%s", mustRead(t, path))
+	}
+}
+
+func mustRead(t *testing.T, path string) string {
+	t.Helper()
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
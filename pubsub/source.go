@@ -0,0 +1,90 @@
+package pubsub
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+
+	"github.com/arifmahmudrana/go-snippets/ratelimit"
+)
+
+// maxSourceLine is the longest line SourceLines accepts.
+const maxSourceLine = 1 << 20
+
+// sourceConfig holds SourceLines settings.
+type sourceConfig struct {
+	limiter *ratelimit.Limiter
+	json    bool
+}
+
+// SourceOption configures SourceLines.
+type SourceOption func(*sourceConfig)
+
+// WithRate publishes at most perSecond messages per second on average,
+// with bursts of up to burst.
+func WithRate(perSecond float64, burst int) SourceOption {
+	return func(c *sourceConfig) {
+		c.limiter = ratelimit.NewLimiter(perSecond, burst)
+	}
+}
+
+// WithJSON reads a stream of JSON documents, which may span lines,
+// instead of lines, and publishes each as a json.RawMessage.
+func WithJSON() SourceOption {
+	return func(c *sourceConfig) {
+		c.json = true
+	}
+}
+
+// SourceLines publishes each non-empty line of r, without its line
+// ending, as a string payload on topic, until r is exhausted or ctx is
+// done. It returns the number of messages published; reaching the end
+// of r is not an error, but a stopped broker is (ErrBrokerClosed). Combined with WithRate it lets a log file or
+// stdin drive the broker at a realistic pace.
+func SourceLines(ctx context.Context, b *Broker, topic string, r io.Reader, opts ...SourceOption) (int, error) {
+	var cfg sourceConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	publish := func(payload any) error {
+		if cfg.limiter != nil {
+			if err := cfg.limiter.Wait(ctx); err != nil {
+				return err
+			}
+		}
+		return b.PublishCtx(ctx, topic, payload)
+	}
+
+	n := 0
+	if cfg.json {
+		dec := json.NewDecoder(r)
+		for {
+			var doc json.RawMessage
+			if err := dec.Decode(&doc); errors.Is(err, io.EOF) {
+				return n, nil
+			} else if err != nil {
+				return n, err
+			}
+			if err := publish(doc); err != nil {
+				return n, err
+			}
+			n++
+		}
+	}
+
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), maxSourceLine)
+	for sc.Scan() {
+		if len(sc.Bytes()) == 0 {
+			continue
+		}
+		if err := publish(sc.Text()); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, sc.Err()
+}
@@ -0,0 +1,71 @@
+package pubsub
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestSourceLines tests publishing a reader line by line
+func TestSourceLines(t *testing.T) {
+	b := NewBroker()
+	defer b.Stop()
+	sub := b.Subscribe("logs")
+
+	n, err := SourceLines(context.Background(), b, "logs", strings.NewReader("first\n\nsecond\r\nthird"))
+	if err != nil || n != 3 {
+		t.Fatalf("SourceLines() = %d, %v, want 3, nil", n, err)
+	}
+	for _, want := range []string{"first", "second", "third"} {
+		if m := <-sub.C; m.Payload != want {
+			t.Errorf("got %q, want %q", m.Payload, want)
+		}
+	}
+}
+
+// TestSourceLines_JSON tests publishing a stream of JSON documents
+func TestSourceLines_JSON(t *testing.T) {
+	b := NewBroker()
+	defer b.Stop()
+	sub := b.Subscribe("events")
+
+	in := `{"id": 1}
+{
+  "id": 2
+} [3]`
+	n, err := SourceLines(context.Background(), b, "events", strings.NewReader(in), WithJSON())
+	if err != nil || n != 3 {
+		t.Fatalf("SourceLines() = %d, %v, want 3, nil", n, err)
+	}
+	var doc struct{ ID int }
+	if err := json.Unmarshal((<-sub.C).Payload.(json.RawMessage), &doc); err != nil || doc.ID != 1 {
+		t.Errorf("first document = %+v, %v", doc, err)
+	}
+
+	_, err = SourceLines(context.Background(), b, "events", strings.NewReader(`{"broken"`), WithJSON())
+	if err == nil {
+		t.Error("SourceLines() on malformed JSON succeeded")
+	}
+}
+
+// TestSourceLines_Rate tests rate limiting and cancellation
+func TestSourceLines_Rate(t *testing.T) {
+	b := NewBroker()
+	defer b.Stop()
+
+	in := strings.Repeat("x\n", 100)
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	n, err := SourceLines(ctx, b, "t", strings.NewReader(in), WithRate(50, 1))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("SourceLines() error = %v, want DeadlineExceeded", err)
+	}
+	// 1 burst token plus 50/s for 100ms
+	if n < 3 || n > 10 {
+		t.Errorf("published %d lines in 100ms at 50/s, want about 6", n)
+	}
+}
@@ -0,0 +1,165 @@
+package pubsub
+
+import (
+	"cmp"
+	"expvar"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Observer receives the broker's events, e.g. to feed a metrics system
+// or a log. Any of its functions may be nil. They are called on the
+// broker's own goroutines, the run loop and its dispatchers (see
+// WithDispatchers), so they must be quick and must not call the
+// broker.
+type Observer struct {
+	// OnPublish is called for every message the broker takes, once it
+	// is numbered and before it is delivered.
+	OnPublish func(m Message)
+	// OnDrop is called for every message a subscriber's Policy
+	// discards; err says why, as in DeliveryError.
+	OnDrop func(m Message, sub *Subscriber, err error)
+	// OnSubscribe is called once a subscription is registered, and
+	// OnUnsubscribe once it is closed.
+	OnSubscribe   func(sub *Subscriber)
+	OnUnsubscribe func(sub *Subscriber)
+}
+
+// WithObserver calls o's functions as messages are published and
+// dropped and subscriptions come and go.
+func WithObserver(o Observer) BrokerOption {
+	return func(b *Broker) {
+		b.metrics.observer = o
+	}
+}
+
+// metrics counts what the broker does, for Stats, and reports drops to
+// the Observer and the dead-letter topic. Subscribers share their
+// broker's.
+type metrics struct {
+	published, delivered, dropped, deadLettered atomic.Uint64
+
+	observer   Observer
+	deadLetter func(m Message, sub *Subscriber, err error) // nil unless WithDeadLetter
+}
+
+// Stats is a snapshot of the broker as a whole.
+type Stats struct {
+	Topics          int // with subscribers, counting each pattern once
+	Subscribers     int
+	Published       uint64 // messages taken, since the broker started
+	Delivered       uint64 // handed to a subscriber's channel
+	Dropped         uint64 // discarded by subscribers' policies
+	DeadLettered    uint64 // see WithDeadLetter
+	Queued          int    // published, waiting for the broker
+	RetentionErrors uint64
+	ResumeGaps      uint64 // Resume channels closed early, see Resume
+}
+
+// Stats returns the broker's counters and how many subscriptions it has.
+// Message counts include every subscription ever made. After Stop,
+// Topics and Subscribers are 0.
+func (b *Broker) Stats() Stats {
+	st := Stats{
+		Published:       b.metrics.published.Load(),
+		Delivered:       b.metrics.delivered.Load(),
+		Dropped:         b.metrics.dropped.Load(),
+		DeadLettered:    b.metrics.deadLettered.Load(),
+		Queued:          b.inbound.len(),
+		RetentionErrors: b.retentionErrors.Load(),
+		ResumeGaps:      b.resumeGaps.Load(),
+	}
+	b.exec(func() {
+		st.Topics = len(b.subscriptions)
+		for _, subs := range b.subscriptions {
+			st.Subscribers += len(subs)
+		}
+	})
+	return st
+}
+
+// SubscriberStats describes one subscription, e.g. to find the
+// consumers falling behind.
+type SubscriberStats struct {
+	ID            uint64 // numbers subscribers as in the broker's trace
+	Topic         string // the topic or pattern subscribed to
+	Group         string
+	Tags          []string
+	Pending       int // delivered to C but not yet read
+	Cap           int
+	Dropped       uint64
+	LastDelivered time.Time // zero if nothing was
+}
+
+// Subscribers describes every subscription, by topic and then in the
+// order they were made.
+func (b *Broker) Subscribers() []SubscriberStats {
+	var subs []SubscriberStats
+	b.exec(func() {
+		for _, topicSubs := range b.subscriptions {
+			for _, s := range topicSubs {
+				subs = append(subs, SubscriberStats{
+					ID:            s.id,
+					Topic:         s.topic,
+					Group:         s.group,
+					Tags:          s.Tags(),
+					Pending:       s.Pending(),
+					Cap:           s.Cap(),
+					Dropped:       s.Dropped(),
+					LastDelivered: s.LastDeliveredAt(),
+				})
+			}
+		}
+	})
+	slices.SortFunc(subs, func(a, b SubscriberStats) int {
+		if a.Topic != b.Topic {
+			return strings.Compare(a.Topic, b.Topic)
+		}
+		return cmp.Compare(a.ID, b.ID)
+	})
+	return subs
+}
+
+// Var returns the broker's Stats as an expvar.Var, to be published with
+// expvar.Publish and served with the other variables on /debug/vars.
+func (b *Broker) Var() expvar.Var {
+	return expvar.Func(func() any { return b.Stats() })
+}
+
+// WriteMetrics writes the broker's Stats, and the number of messages
+// published to each topic, in the Prometheus text exposition format,
+// for an HTTP handler to serve to a scraper.
+func (b *Broker) WriteMetrics(w io.Writer) error {
+	st := b.Stats()
+	var buf []byte
+	metric := func(name, kind, help string, v uint64) {
+		buf = fmt.Appendf(buf, "# HELP %s %s\n# TYPE %s %s\n%s %d\n", name, help, name, kind, name, v)
+	}
+	metric("pubsub_topics", "gauge", "Topics and patterns with subscribers.", uint64(st.Topics))
+	metric("pubsub_subscribers", "gauge", "Active subscriptions.", uint64(st.Subscribers))
+	metric("pubsub_published_total", "counter", "Messages published.", st.Published)
+	metric("pubsub_delivered_total", "counter", "Messages handed to subscribers.", st.Delivered)
+	metric("pubsub_dropped_total", "counter", "Messages dropped by subscriber policies.", st.Dropped)
+	metric("pubsub_dead_lettered_total", "counter", "Messages published to the dead-letter topic.", st.DeadLettered)
+	metric("pubsub_queued", "gauge", "Messages waiting for the broker.", uint64(st.Queued))
+	metric("pubsub_retention_errors_total", "counter", "Messages that could not be retained.", st.RetentionErrors)
+
+	rates := b.PublishRates()
+	topics := make([]string, 0, len(rates))
+	for topic := range rates {
+		topics = append(topics, topic)
+	}
+	slices.Sort(topics)
+	const name = "pubsub_topic_published_total"
+	buf = fmt.Appendf(buf, "# HELP %s Messages published, per topic.\n# TYPE %s counter\n", name, name)
+	for _, topic := range topics {
+		buf = fmt.Appendf(buf, "%s{topic=%s} %d\n", name, strconv.Quote(topic), rates[topic].Total)
+	}
+	_, err := w.Write(buf)
+	return err
+}
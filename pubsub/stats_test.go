@@ -0,0 +1,100 @@
+package pubsub
+
+import (
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// TestBroker_Stats tests the broker-wide counters and the Observer's
+// calls
+func TestBroker_Stats(t *testing.T) {
+	var mu sync.Mutex
+	events := map[string]int{}
+	count := func(event string) {
+		mu.Lock()
+		events[event]++
+		mu.Unlock()
+	}
+	b := NewBroker(WithObserver(Observer{
+		OnPublish:     func(Message) { count("publish") },
+		OnDrop:        func(Message, *Subscriber, error) { count("drop") },
+		OnSubscribe:   func(*Subscriber) { count("subscribe") },
+		OnUnsubscribe: func(*Subscriber) { count("unsubscribe") },
+	}))
+	defer b.Stop()
+
+	fast := b.Subscribe("t")
+	b.Subscribe("t", WithPolicy(DropNewest), WithBuffer(1)) // drops 2 of 3
+	pattern := b.Subscribe("*")
+	for i := range 3 {
+		b.Publish("t", i)
+	}
+	recv(t, fast.C, 3)
+	recv(t, pattern.C, 3)
+	b.Unsubscribe("*", pattern)
+
+	want := Stats{Topics: 1, Subscribers: 2, Published: 3, Delivered: 7, Dropped: 2}
+	if st := b.Stats(); st != want {
+		t.Errorf("Stats() = %+v, want %+v", st, want)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	wantEvents := map[string]int{"publish": 3, "drop": 2, "subscribe": 3, "unsubscribe": 1}
+	for event, n := range wantEvents {
+		if events[event] != n {
+			t.Errorf("%s observed %d times, want %d", event, events[event], n)
+		}
+	}
+}
+
+// TestBroker_WriteMetrics tests the Prometheus text output
+func TestBroker_WriteMetrics(t *testing.T) {
+	b := NewBroker()
+	defer b.Stop()
+	sub := b.Subscribe(`say."hi"`)
+	b.Publish(`say."hi"`, 1)
+	recv(t, sub.C, 1)
+
+	var out strings.Builder
+	if err := b.WriteMetrics(&out); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"# TYPE pubsub_subscribers gauge\npubsub_subscribers 1\n",
+		"# TYPE pubsub_delivered_total counter\npubsub_delivered_total 1\n",
+		`pubsub_topic_published_total{topic="say.\"hi\""} 1` + "\n",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("WriteMetrics() wrote\n%s\nwant it to contain %q", out.String(), want)
+		}
+	}
+}
+
+// TestBroker_Subscribers tests the per-subscription stats, sorted by
+// topic
+func TestBroker_Subscribers(t *testing.T) {
+	b := NewBroker()
+	defer b.Stop()
+	slow := b.Subscribe("b", WithPolicy(DropNewest), WithBuffer(2), WithTag("slow"))
+	b.SubscribeGroup("a", "workers")
+	for i := range 3 {
+		b.Publish("b", i)
+	}
+
+	got := b.Subscribers()
+	if len(got) != 2 {
+		t.Fatalf("Subscribers() = %+v, want 2", got)
+	}
+	if a := got[0]; a.Topic != "a" || a.Group != "workers" || a.Cap != defaultBuffer || a.Pending != 0 || !a.LastDelivered.IsZero() {
+		t.Errorf("Subscribers()[0] = %+v, want an idle member of workers on a", a)
+	}
+	s := got[1]
+	if s.Topic != "b" || s.Pending != 2 || s.Cap != 2 || s.Dropped != 1 || s.LastDelivered.IsZero() {
+		t.Errorf("Subscribers()[1] = %+v, want 2 of 2 pending and 1 dropped on b", s)
+	}
+	if s.ID != slow.id || !slices.Equal(s.Tags, []string{"slow"}) {
+		t.Errorf("Subscribers()[1] ID, Tags = %d, %v, want %d, [slow]", s.ID, s.Tags, slow.id)
+	}
+}
@@ -0,0 +1,127 @@
+package pubsub
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// TraceKind is the type of a traced broker event.
+type TraceKind uint8
+
+const (
+	TracePublish TraceKind = iota + 1
+	TraceDeliver
+	TraceDrop
+	TraceSubscribe
+	TraceUnsubscribe
+)
+
+func (k TraceKind) String() string {
+	switch k {
+	case TracePublish:
+		return "publish"
+	case TraceDeliver:
+		return "deliver"
+	case TraceDrop:
+		return "drop"
+	case TraceSubscribe:
+		return "subscribe"
+	case TraceUnsubscribe:
+		return "unsubscribe"
+	}
+	return fmt.Sprintf("TraceKind(%d)", uint8(k))
+}
+
+// TraceEvent is one entry of the broker's trace.
+type TraceEvent struct {
+	Time       time.Time
+	Kind       TraceKind
+	Topic      string
+	Seq        uint64 // for publish, deliver and drop
+	Subscriber uint64 // subscriber number, for all but publish
+}
+
+func (e TraceEvent) String() string {
+	s := fmt.Sprintf("%s %-11s %s", e.Time.Format("15:04:05.000000"), e.Kind, e.Topic)
+	if e.Seq != 0 {
+		s += fmt.Sprintf(" #%d", e.Seq)
+	}
+	if e.Subscriber != 0 {
+		s += fmt.Sprintf(" sub=%d", e.Subscriber)
+	}
+	return s
+}
+
+// tracer keeps the last events in a ring buffer. A nil *tracer records
+// nothing, so call sites need no check.
+type tracer struct {
+	mu     sync.Mutex
+	events []TraceEvent
+	next   int
+	full   bool
+}
+
+func newTracer(n int) *tracer {
+	return &tracer{events: make([]TraceEvent, max(n, 1))}
+}
+
+func (t *tracer) record(kind TraceKind, topic string, seq, sub uint64) {
+	if t == nil {
+		return
+	}
+	e := TraceEvent{Time: time.Now(), Kind: kind, Topic: topic, Seq: seq, Subscriber: sub}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.events[t.next] = e
+	t.next = (t.next + 1) % len(t.events)
+	if t.next == 0 {
+		t.full = true
+	}
+}
+
+// snapshot returns the buffered events, oldest first.
+func (t *tracer) snapshot() []TraceEvent {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.full {
+		return append([]TraceEvent(nil), t.events[:t.next]...)
+	}
+	return append(append([]TraceEvent(nil), t.events[t.next:]...), t.events[:t.next]...)
+}
+
+// WithTrace keeps the last n broker events (publishes, deliveries, drops
+// and subscription changes) for Trace and DumpTrace. Tracing costs a
+// lock per event, so it is off by default.
+func WithTrace(n int) BrokerOption {
+	return func(b *Broker) {
+		b.trace = newTracer(n)
+	}
+}
+
+// Trace returns the traced events, oldest first, including messages
+// still queued when it was called. It fails with ErrTraceDisabled unless
+// the broker was created WithTrace.
+func (b *Broker) Trace() ([]TraceEvent, error) {
+	if b.trace == nil {
+		return nil, ErrTraceDisabled
+	}
+	b.exec(func() {}) // let the run loop publish what is queued
+	return b.trace.snapshot(), nil
+}
+
+// DumpTrace writes the traced events to w, one per line, oldest first.
+func (b *Broker) DumpTrace(w io.Writer) error {
+	events, err := b.Trace()
+	if err != nil {
+		return err
+	}
+	for _, e := range events {
+		if _, err := fmt.Fprintln(w, e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
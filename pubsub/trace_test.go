@@ -0,0 +1,73 @@
+package pubsub
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestBroker_Trace tests that a message's path can be followed in the trace
+func TestBroker_Trace(t *testing.T) {
+	b := NewBroker(WithTrace(100))
+	b.deliveryTimeout = time.Millisecond
+	defer b.Stop()
+
+	fast := b.Subscribe("orders")
+	slow := b.Subscribe("orders")
+	for range slow.Cap() {
+		b.Publish("orders", nil)
+		<-fast.C
+	}
+	b.Publish("orders", "where did it go?") // slow's buffer is full
+	<-fast.C
+	for slow.Dropped() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	b.Unsubscribe("orders", fast)
+
+	var buf bytes.Buffer
+	if err := b.DumpTrace(&buf); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, want := range []string{
+		"subscribe   orders sub=1",
+		"publish     orders #11",
+		"deliver     orders #11 sub=1",
+		"drop        orders #11 sub=2",
+		"unsubscribe orders sub=1",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("trace missing %q:\n%s", want, out)
+		}
+	}
+}
+
+// TestBroker_TraceRing tests that only the last events are kept
+func TestBroker_TraceRing(t *testing.T) {
+	b := NewBroker(WithTrace(3))
+	defer b.Stop()
+	for range 5 {
+		b.Publish("t", nil)
+	}
+
+	events, err := b.Trace()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var seqs []uint64
+	for _, e := range events {
+		seqs = append(seqs, e.Seq)
+	}
+	if len(seqs) != 3 || seqs[0] != 3 || seqs[2] != 5 {
+		t.Errorf("traced seqs = %v, want [3 4 5]", seqs)
+	}
+
+	plain := NewBroker()
+	defer plain.Stop()
+	if err := plain.DumpTrace(&bytes.Buffer{}); !errors.Is(err, ErrTraceDisabled) {
+		t.Errorf("DumpTrace() without WithTrace error = %v, want ErrTraceDisabled", err)
+	}
+}
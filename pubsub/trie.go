@@ -0,0 +1,106 @@
+package pubsub
+
+import (
+	"slices"
+	"strings"
+)
+
+// IsPattern reports whether topic is a pattern in the syntax of
+// MatchTopic rather than a plain topic: whether it has a "*" token, or
+// ends in a ">" or "#" token.
+func IsPattern(topic string) bool {
+	if !strings.ContainsAny(topic, "*>#") {
+		return false
+	}
+	for rest := topic; ; {
+		tok, after, more := strings.Cut(rest, ".")
+		if tok == "*" || !more && (tok == ">" || tok == "#") {
+			return true
+		}
+		if !more {
+			return false
+		}
+		rest = after
+	}
+}
+
+// topicTrie indexes subscription patterns by token, so a publish finds
+// the patterns matching its topic by walking the topic's tokens once
+// rather than trying every pattern. Only the run loop may use it.
+type topicTrie struct {
+	children map[string]*topicTrie // by token, wildcards included
+	pattern  string                // the pattern ending here, if any
+}
+
+// add indexes pattern.
+func (t *topicTrie) add(pattern string) {
+	n := t
+	for _, tok := range strings.Split(pattern, ".") {
+		child, ok := n.children[tok]
+		if !ok {
+			if n.children == nil {
+				n.children = make(map[string]*topicTrie)
+			}
+			child = &topicTrie{}
+			n.children[tok] = child
+		}
+		n = child
+	}
+	n.pattern = pattern
+}
+
+// remove forgets pattern, pruning the nodes it no longer needs.
+func (t *topicTrie) remove(pattern string) {
+	t.removeTokens(pattern, strings.Split(pattern, "."))
+}
+
+// removeTokens removes the pattern at the end of tokens and reports
+// whether t is left empty.
+func (t *topicTrie) removeTokens(pattern string, tokens []string) bool {
+	if len(tokens) == 0 {
+		if t.pattern == pattern {
+			t.pattern = ""
+		}
+	} else if child, ok := t.children[tokens[0]]; ok && child.removeTokens(pattern, tokens[1:]) {
+		delete(t.children, tokens[0])
+	}
+	return t.pattern == "" && len(t.children) == 0
+}
+
+// match appends the indexed patterns matching topic, each once, to
+// matched and returns the result. It allocates only to grow matched.
+func (t *topicTrie) match(topic string, matched []string) []string {
+	if len(t.children) == 0 {
+		return matched
+	}
+	t.walk(topic, true, &matched)
+	return matched
+}
+
+// walk matches the tokens of topic, which has none left unless more.
+func (t *topicTrie) walk(topic string, more bool, matched *[]string) {
+	found := func(p string) {
+		// a topic token that is itself "*", ">" or "#" can reach a
+		// pattern both literally and as a wildcard
+		if p != "" && !slices.Contains(*matched, p) {
+			*matched = append(*matched, p)
+		}
+	}
+	if hash, ok := t.children["#"]; ok {
+		found(hash.pattern) // zero or more tokens
+	}
+	if !more {
+		found(t.pattern)
+		return
+	}
+	if rest, ok := t.children[">"]; ok {
+		found(rest.pattern) // one or more tokens
+	}
+	tok, rest, next := strings.Cut(topic, ".")
+	if child, ok := t.children[tok]; ok {
+		child.walk(rest, next, matched)
+	}
+	if star, ok := t.children["*"]; ok && tok != "*" {
+		star.walk(rest, next, matched)
+	}
+}
@@ -0,0 +1,118 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+)
+
+// TestTopicTrie_Match tests that the trie finds exactly the patterns
+// MatchTopic accepts
+func TestTopicTrie_Match(t *testing.T) {
+	patterns := []string{"sports.*", "sports.#", "sports.>", "sports.*.live", "*.football", "#", "a.>.b", "news"}
+	var trie topicTrie
+	for _, p := range patterns {
+		trie.add(p)
+	}
+	topics := []string{"sports", "sports.football", "sports.football.live", "news", "news.football", "a.>.b", "sports.*", ""}
+	for _, topic := range topics {
+		var want []string
+		for _, p := range patterns {
+			if MatchTopic(p, topic) {
+				want = append(want, p)
+			}
+		}
+		got := trie.match(topic, nil)
+		slices.Sort(got)
+		slices.Sort(want)
+		if !slices.Equal(got, want) {
+			t.Errorf("match(%q) = %v, want %v", topic, got, want)
+		}
+	}
+
+	for _, p := range patterns {
+		trie.remove(p)
+	}
+	if len(trie.children) != 0 {
+		t.Errorf("trie not empty after removing every pattern: %v", trie.children)
+	}
+}
+
+// TestIsPattern tests telling patterns from topics
+func TestIsPattern(t *testing.T) {
+	tests := []struct {
+		topic string
+		want  bool
+	}{
+		{"sports", false},
+		{"sports.*", true},
+		{"*.football", true},
+		{"news.#", true},
+		{"news.>", true},
+		{"a.>.b", false},
+		{"c#.news", false},
+		{"$broker.limit", false},
+	}
+	for _, tt := range tests {
+		if got := IsPattern(tt.topic); got != tt.want {
+			t.Errorf("IsPattern(%q) = %v, want %v", tt.topic, got, tt.want)
+		}
+	}
+}
+
+// TestBroker_SubscribePattern tests delivery to overlapping patterns and
+// unsubscribing one of them
+func TestBroker_SubscribePattern(t *testing.T) {
+	b := NewBroker()
+	defer b.Stop()
+	subs := map[string]*Subscriber{}
+	for _, topic := range []string{"sports.*", "sports.#", "#", "sports.football"} {
+		subs[topic] = b.Subscribe(topic)
+	}
+	received := func(topic string) []string {
+		t.Helper()
+		var got []string
+		for {
+			select {
+			case m := <-subs[topic].C:
+				got = append(got, m.Topic)
+			default:
+				return got
+			}
+		}
+	}
+	publish := func() {
+		for _, topic := range []string{"sports.football", "sports", "sports.football.live", "news"} {
+			b.Publish(topic, nil)
+		}
+		b.Ping(context.Background())
+	}
+
+	publish()
+	want := map[string][]string{
+		"sports.*":        {"sports.football"},
+		"sports.#":        {"sports.football", "sports", "sports.football.live"},
+		"#":               {"sports.football", "sports", "sports.football.live", "news"},
+		"sports.football": {"sports.football"},
+	}
+	for topic, w := range want {
+		if got := received(topic); !slices.Equal(got, w) {
+			t.Errorf("%q received %v, want %v", topic, got, w)
+		}
+	}
+
+	b.Unsubscribe("sports.*", subs["sports.*"])
+	if _, ok := <-subs["sports.*"].C; ok {
+		t.Error("pattern subscriber still open after Unsubscribe")
+	}
+	publish()
+	if got := received("sports.#"); len(got) != 3 {
+		t.Errorf("sports.# received %v after another pattern left, want 3 messages", got)
+	}
+	rb := NewBroker(WithRetention(NewMemoryRetention(10)))
+	defer rb.Stop()
+	if _, _, err := rb.Resume("sports.*", ""); !errors.Is(err, ErrPatternTopic) {
+		t.Errorf("Resume(pattern) = %v, want %v", err, ErrPatternTopic)
+	}
+}
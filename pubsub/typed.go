@@ -0,0 +1,163 @@
+package pubsub
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// TypedMessage is a Message whose payload is known to be a T.
+type TypedMessage[T any] struct {
+	Topic   string
+	Payload T
+	Seq     uint64
+	Time    time.Time
+	Clock   uint64
+}
+
+// Payload returns m's payload as a T, reporting false if it is not one.
+// It lets code holding a plain Subscriber convert messages one call site
+// at a time.
+func Payload[T any](m Message) (T, bool) {
+	v, ok := m.Payload.(T)
+	return v, ok
+}
+
+// Typed is a view of a Broker in which every payload is a T. Typed and
+// untyped code can share one broker while callers migrate: both see the
+// same topics and subscribers, and Broker returns the underlying broker
+// for anything the typed view does not cover.
+//
+// Messages whose payload is not a T, published through the untyped API,
+// are skipped by typed subscribers.
+type Typed[T any] struct {
+	b *Broker
+}
+
+// AsTyped returns a typed view of b.
+func AsTyped[T any](b *Broker) Typed[T] {
+	return Typed[T]{b: b}
+}
+
+// Broker returns the underlying untyped broker.
+func (t Typed[T]) Broker() *Broker {
+	return t.b
+}
+
+// Publish broadcasts v to every subscriber of topic, typed or not.
+func (t Typed[T]) Publish(topic string, v T) {
+	t.b.Publish(topic, v)
+}
+
+// Subscribe subscribes to topic and returns a channel of typed messages,
+// closed after unsubscribe is called or the broker stops. Like
+// Broker.Unsubscribe, unsubscribe returns once no more messages will be
+// sent; it must be called to release the subscription.
+func (t Typed[T]) Subscribe(topic string, opts ...SubscribeOption) (c <-chan TypedMessage[T], unsubscribe func()) {
+	return subscribeTyped(t.b, topic, typedMessage[T], opts)
+}
+
+// typedMessage converts m, whose payload is v.
+func typedMessage[T any](m Message, v T) TypedMessage[T] {
+	return TypedMessage[T]{Topic: m.Topic, Payload: v, Seq: m.Seq, Time: m.Time, Clock: m.Clock}
+}
+
+// subscribeTyped subscribes to topic and sends wrap(m, payload) for every
+// message with a T payload on the returned channel, as Typed.Subscribe
+// describes.
+func subscribeTyped[T, R any](b *Broker, topic string, wrap func(Message, T) R, opts []SubscribeOption) (<-chan R, func()) {
+	out := make(chan R)
+	sub := b.Subscribe(topic, opts...)
+	stop := make(chan struct{})
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		defer close(out)
+		for m := range sub.C {
+			v, ok := Payload[T](m)
+			if !ok {
+				continue
+			}
+			select {
+			case out <- wrap(m, v):
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return out, func() {
+		once.Do(func() {
+			b.Unsubscribe(topic, sub)
+			close(stop)
+			<-done
+		})
+	}
+}
+
+// SubscribeFunc calls fn for every T published to topic, as
+// Broker.SubscribeFunc does.
+func (t Typed[T]) SubscribeFunc(topic string, fn func(TypedMessage[T]), opts ...SubscribeOption) (unsubscribe func()) {
+	return t.b.SubscribeFunc(topic, func(m Message) {
+		if v, ok := Payload[T](m); ok {
+			fn(typedMessage(m, v))
+		}
+	}, opts...)
+}
+
+// Topic is one topic of a Broker whose payloads are all T, so publishers
+// and subscribers agree on the type at compile time instead of asserting
+// it in every subscriber. Like Typed, it shares the broker with untyped
+// code; payloads of another type published there are skipped by its
+// subscribers.
+type Topic[T any] struct {
+	b    *Broker
+	name string
+}
+
+// NewTopic returns the topic name of b as a Topic[T]. It panics if name
+// is a pattern: a Topic is published to, and a pattern names several
+// topics. Subscribe to patterns with Typed.
+func NewTopic[T any](b *Broker, name string) Topic[T] {
+	if IsPattern(name) {
+		panic("pubsub: NewTopic with pattern " + name)
+	}
+	return Topic[T]{b: b, name: name}
+}
+
+// Name returns the topic's name.
+func (t Topic[T]) Name() string {
+	return t.name
+}
+
+// Publish broadcasts v to every subscriber of the topic, as
+// Broker.Publish does.
+func (t Topic[T]) Publish(v T) {
+	t.b.Publish(t.name, v)
+}
+
+// PublishCtx is like Publish but reports whether the broker took v, as
+// Broker.PublishCtx does.
+func (t Topic[T]) PublishCtx(ctx context.Context, v T) error {
+	return t.b.PublishCtx(ctx, t.name, v)
+}
+
+// Subscribe returns a channel of the topic's payloads, closed after
+// unsubscribe is called or the broker stops; see Typed.Subscribe.
+func (t Topic[T]) Subscribe(opts ...SubscribeOption) (c <-chan T, unsubscribe func()) {
+	return subscribeTyped(t.b, t.name, func(_ Message, v T) T { return v }, opts)
+}
+
+// SubscribeMessages is like Subscribe but keeps each message's sequence
+// number and time.
+func (t Topic[T]) SubscribeMessages(opts ...SubscribeOption) (c <-chan TypedMessage[T], unsubscribe func()) {
+	return subscribeTyped(t.b, t.name, typedMessage[T], opts)
+}
+
+// SubscribeFunc calls fn for every payload, as Broker.SubscribeFunc
+// does.
+func (t Topic[T]) SubscribeFunc(fn func(T), opts ...SubscribeOption) (unsubscribe func()) {
+	return AsTyped[T](t.b).SubscribeFunc(t.name, func(m TypedMessage[T]) { fn(m.Payload) }, opts...)
+}
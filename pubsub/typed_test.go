@@ -0,0 +1,115 @@
+package pubsub
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// order is a payload type for the typed tests.
+type order struct {
+	ID    int
+	Total float64
+}
+
+// TestTyped_Interop tests typed and untyped code sharing one broker
+func TestTyped_Interop(t *testing.T) {
+	b := NewBroker()
+	defer b.Stop()
+	orders := AsTyped[order](b)
+
+	c, unsubscribe := orders.Subscribe("orders")
+	defer unsubscribe()
+	legacy := b.Subscribe("orders")
+
+	b.Publish("orders", "not an order") // skipped by the typed view
+	orders.Publish("orders", order{ID: 1, Total: 9.5})
+
+	select {
+	case m := <-c:
+		if m.Topic != "orders" || m.Payload.ID != 1 {
+			t.Errorf("typed subscriber got %+v", m)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("typed subscriber got nothing")
+	}
+
+	// the untyped subscriber sees both, and converts with Payload
+	var got []bool
+	for range 2 {
+		_, ok := Payload[order](<-legacy.C)
+		got = append(got, ok)
+	}
+	if got[0] == got[1] {
+		t.Errorf("Payload[order] results = %v, want one of each", got)
+	}
+}
+
+// TestTyped_Unsubscribe tests that the typed channel closes on unsubscribe
+func TestTyped_Unsubscribe(t *testing.T) {
+	b := NewBroker()
+	defer b.Stop()
+	nums := AsTyped[int](b)
+
+	var sum int
+	unsubFunc := nums.SubscribeFunc("n", func(m TypedMessage[int]) { sum += m.Payload })
+	c, unsubscribe := nums.Subscribe("n")
+
+	nums.Publish("n", 2)
+	nums.Publish("n", 3)
+	<-c
+	<-c
+	unsubscribe()
+	unsubscribe()
+	unsubFunc()
+
+	if _, ok := <-c; ok {
+		t.Error("channel open after unsubscribe")
+	}
+	if sum != 5 {
+		t.Errorf("SubscribeFunc sum = %d, want 5", sum)
+	}
+}
+
+// TestTopic tests a topic bound to its payload type
+func TestTopic(t *testing.T) {
+	b := NewBroker()
+	defer b.Stop()
+	orders := NewTopic[order](b, "orders")
+
+	c, unsubscribe := orders.Subscribe()
+	msgs, unsubscribeMsgs := orders.SubscribeMessages()
+	var total float64
+	unsubFunc := orders.SubscribeFunc(func(o order) { total += o.Total })
+
+	b.Publish("orders", "not an order") // skipped
+	orders.Publish(order{ID: 1, Total: 2.5})
+	if err := orders.PublishCtx(context.Background(), order{ID: 2, Total: 4}); err != nil {
+		t.Fatalf("PublishCtx() = %v", err)
+	}
+
+	for want := 1; want <= 2; want++ {
+		if o := <-c; o.ID != want {
+			t.Errorf("Subscribe received order %d, want %d", o.ID, want)
+		}
+		if m := <-msgs; m.Payload.ID != want || m.Seq != uint64(want+1) {
+			t.Errorf("SubscribeMessages received %+v, want order %d as #%d", m, want, want+1)
+		}
+	}
+	unsubscribe()
+	unsubscribeMsgs()
+	unsubFunc()
+	if total != 6.5 {
+		t.Errorf("SubscribeFunc total = %v, want 6.5", total)
+	}
+	if _, ok := <-c; ok {
+		t.Error("channel open after unsubscribe")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("NewTopic with a pattern did not panic")
+		}
+	}()
+	NewTopic[order](b, "orders.*")
+}
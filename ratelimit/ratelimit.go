@@ -0,0 +1,182 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrExhausted is returned by Wait when the bucket is empty and, its
+// rate being 0 or less, will never refill.
+var ErrExhausted = errors.New("ratelimit: no tokens left and none to come")
+
+// Limiter is a token bucket: it refills at rate tokens per second up to
+// burst tokens. It is safe for concurrent use.
+type Limiter struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+	now    func() time.Time
+}
+
+// NewLimiter returns a limiter that starts with a full bucket. With a
+// rate of 0 or less the bucket never refills: burst events are allowed
+// in all, after which Allow reports false and Wait fails with
+// ErrExhausted.
+func NewLimiter(rate float64, burst int) *Limiter {
+	burst = max(burst, 1)
+	return &Limiter{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+		now:    time.Now,
+	}
+}
+
+// refill adds the tokens accumulated since the last call. Caller holds mu.
+func (l *Limiter) refill(now time.Time) {
+	if elapsed := now.Sub(l.last).Seconds(); elapsed > 0 && l.rate > 0 {
+		l.tokens = min(l.burst, l.tokens+elapsed*l.rate)
+	}
+	l.last = now
+}
+
+// Allow takes a token if one is available and reports whether it did.
+func (l *Limiter) Allow() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.refill(l.now())
+	if l.tokens >= 1 {
+		l.tokens--
+		return true
+	}
+	return false
+}
+
+// reserve takes a token, possibly going into debt, and returns how long
+// the caller must wait before using it. It reports false, taking
+// nothing, if there is no token and the rate will never make one.
+func (l *Limiter) reserve() (time.Duration, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.refill(l.now())
+	if l.tokens < 1 && l.rate <= 0 {
+		return 0, false
+	}
+	l.tokens--
+	if l.tokens >= 0 {
+		return 0, true
+	}
+	return time.Duration(-l.tokens / l.rate * float64(time.Second)), true
+}
+
+// cancel returns a reserved token that was not used.
+func (l *Limiter) cancel() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.tokens = min(l.burst, l.tokens+1)
+}
+
+// Wait blocks until a token is available or ctx is done.
+func (l *Limiter) Wait(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	delay, ok := l.reserve()
+	if !ok {
+		return ErrExhausted
+	}
+	if delay == 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		l.cancel()
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// Keyed keeps an independent Limiter per key (host, user, tenant...).
+// Limiters idle for longer than the idle timeout are forgotten so the
+// map does not grow without bound.
+type Keyed struct {
+	mu       sync.Mutex
+	rate     float64
+	burst    int
+	idle     time.Duration
+	limiters map[string]*keyedEntry
+	lastGC   time.Time
+}
+
+type keyedEntry struct {
+	lim      *Limiter
+	lastUsed time.Time
+}
+
+// NewKeyed returns a keyed limiter with the given per-key rate and burst.
+// A zero idle duration defaults to one minute.
+func NewKeyed(rate float64, burst int, idle time.Duration) *Keyed {
+	if idle <= 0 {
+		idle = time.Minute
+	}
+	return &Keyed{
+		rate:     rate,
+		burst:    burst,
+		idle:     idle,
+		limiters: make(map[string]*keyedEntry),
+		lastGC:   time.Now(),
+	}
+}
+
+// get returns the limiter for key, creating it on first use.
+func (k *Keyed) get(key string) *Limiter {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(k.lastGC) > k.idle {
+		for key, e := range k.limiters {
+			if now.Sub(e.lastUsed) > k.idle {
+				delete(k.limiters, key)
+			}
+		}
+		k.lastGC = now
+	}
+
+	e, ok := k.limiters[key]
+	if !ok {
+		e = &keyedEntry{lim: NewLimiter(k.rate, k.burst)}
+		k.limiters[key] = e
+	}
+	e.lastUsed = now
+	return e.lim
+}
+
+// Allow reports whether an event for key may happen now.
+func (k *Keyed) Allow(key string) bool {
+	return k.get(key).Allow()
+}
+
+// Wait blocks until an event for key may happen or ctx is done.
+func (k *Keyed) Wait(ctx context.Context, key string) error {
+	return k.get(key).Wait(ctx)
+}
+
+// Len returns the number of keys currently tracked.
+func (k *Keyed) Len() int {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return len(k.limiters)
+}
@@ -0,0 +1,99 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// TestLimiter_Burst tests that a fresh limiter allows exactly burst events
+func TestLimiter_Burst(t *testing.T) {
+	now := time.Now()
+	l := NewLimiter(1, 3)
+	l.now = func() time.Time { return now }
+	l.last = now
+
+	for i := range 3 {
+		if !l.Allow() {
+			t.Fatalf("Allow() #%d = false, want true", i)
+		}
+	}
+	if l.Allow() {
+		t.Error("Allow() after burst = true, want false")
+	}
+
+	// half a second later only half a token has accrued
+	now = now.Add(500 * time.Millisecond)
+	if l.Allow() {
+		t.Error("Allow() after 0.5s = true, want false")
+	}
+	now = now.Add(500 * time.Millisecond)
+	if !l.Allow() {
+		t.Error("Allow() after 1s = false, want true")
+	}
+}
+
+// TestLimiter_Wait tests that Wait paces events at the configured rate
+func TestLimiter_Wait(t *testing.T) {
+	l := NewLimiter(100, 1)
+	start := time.Now()
+	for range 6 {
+		if err := l.Wait(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// first is free, the next five need ~10ms each
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond {
+		t.Errorf("6 events at 100/s took %v, want >= 40ms", elapsed)
+	}
+}
+
+// TestLimiter_ZeroRate tests that a limiter that never refills allows
+// its burst and then refuses, whether asked with Allow or Wait
+func TestLimiter_ZeroRate(t *testing.T) {
+	for _, rate := range []float64{0, -1} {
+		allow := NewLimiter(rate, 2)
+		wait := NewLimiter(rate, 2)
+		for i := range 3 {
+			want := i < 2
+			if got := allow.Allow(); got != want {
+				t.Errorf("rate %v: Allow() #%d = %v, want %v", rate, i, got, want)
+			}
+			var wantErr error
+			if !want {
+				wantErr = ErrExhausted
+			}
+			if err := wait.Wait(context.Background()); !errors.Is(err, wantErr) {
+				t.Errorf("rate %v: Wait() #%d = %v, want %v", rate, i, err, wantErr)
+			}
+		}
+	}
+}
+
+// TestLimiter_WaitCancelled tests that Wait honours the context
+func TestLimiter_WaitCancelled(t *testing.T) {
+	l := NewLimiter(0.1, 1)
+	l.Allow()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := l.Wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Wait() = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+// TestKeyed_Independent tests that keys do not share buckets
+func TestKeyed_Independent(t *testing.T) {
+	k := NewKeyed(1, 1, time.Minute)
+
+	if !k.Allow("a") || !k.Allow("b") {
+		t.Fatal("first event per key should be allowed")
+	}
+	if k.Allow("a") {
+		t.Error("second event for a should be limited")
+	}
+	if got := k.Len(); got != 2 {
+		t.Errorf("Len() = %d, want 2", got)
+	}
+}
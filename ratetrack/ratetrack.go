@@ -0,0 +1,231 @@
+package ratetrack
+
+import (
+	"math"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Window counts events over a sliding window of whole seconds, using one
+// bucket per second. It is safe for concurrent use.
+type Window struct {
+	mu      sync.Mutex
+	buckets []bucket
+	now     func() time.Time
+}
+
+type bucket struct {
+	sec   int64 // unix second this bucket currently holds
+	count int64
+}
+
+// NewWindow returns a counter over the last size (rounded up to a whole
+// number of seconds, at least one).
+func NewWindow(size time.Duration) *Window {
+	n := max(int((size+time.Second-1)/time.Second), 1)
+	return &Window{buckets: make([]bucket, n), now: time.Now}
+}
+
+// Add records n events now.
+func (w *Window) Add(n int64) {
+	sec := w.now().Unix()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	b := &w.buckets[sec%int64(len(w.buckets))]
+	if b.sec != sec {
+		b.sec, b.count = sec, 0 // recycle a bucket from an older lap
+	}
+	b.count += n
+}
+
+// Sum returns the number of events within the window.
+func (w *Window) Sum() int64 {
+	sec := w.now().Unix()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var sum int64
+	for _, b := range w.buckets {
+		if sec-b.sec < int64(len(w.buckets)) {
+			sum += b.count
+		}
+	}
+	return sum
+}
+
+// Rate returns the average events per second over the window.
+func (w *Window) Rate() float64 {
+	return float64(w.Sum()) / float64(len(w.buckets))
+}
+
+// Size returns the window length.
+func (w *Window) Size() time.Duration {
+	return time.Duration(len(w.buckets)) * time.Second
+}
+
+// EWMA is an exponentially weighted moving average of an event rate,
+// like the Unix load average. It needs no background ticker: the decay
+// is applied lazily from the time elapsed between calls.
+type EWMA struct {
+	mu   sync.Mutex
+	tau  float64 // time constant in seconds
+	rate float64 // events per second as of last
+	last time.Time
+	now  func() time.Time
+}
+
+// NewEWMA returns a rate tracker with time constant tau: a burst's
+// contribution decays to 1/e after tau. Common choices are 1, 5 and 15
+// minutes.
+func NewEWMA(tau time.Duration) *EWMA {
+	return &EWMA{tau: max(tau.Seconds(), 1e-9), now: time.Now}
+}
+
+// decay ages the rate to t. Caller holds mu.
+func (e *EWMA) decay(t time.Time) {
+	if !e.last.IsZero() {
+		if dt := t.Sub(e.last).Seconds(); dt > 0 {
+			e.rate *= math.Exp(-dt / e.tau)
+		}
+	}
+	e.last = t
+}
+
+// Add records n events now.
+func (e *EWMA) Add(n int64) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.decay(e.now())
+	// each event contributes an exponential kernel with unit area
+	e.rate += float64(n) / e.tau
+}
+
+// Rate returns the smoothed events per second.
+func (e *EWMA) Rate() float64 {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.decay(e.now())
+	return e.rate
+}
+
+// Snapshot is a point-in-time view of a Tracker, suitable for export to
+// metrics systems.
+type Snapshot struct {
+	Total      int64   // events since creation
+	Window     int64   // events within the sliding window
+	WindowRate float64 // Window per second
+	EWMARate   float64 // smoothed events per second
+}
+
+// Tracker combines a total count, a sliding window and an EWMA.
+type Tracker struct {
+	mu     sync.Mutex
+	total  int64
+	window *Window
+	ewma   *EWMA
+}
+
+// NewTracker returns a tracker with the given window and EWMA constant.
+func NewTracker(window, tau time.Duration) *Tracker {
+	return &Tracker{window: NewWindow(window), ewma: NewEWMA(tau)}
+}
+
+// Add records n events now.
+func (t *Tracker) Add(n int64) {
+	t.mu.Lock()
+	t.total += n
+	t.mu.Unlock()
+
+	t.window.Add(n)
+	t.ewma.Add(n)
+}
+
+// Snapshot returns the current statistics.
+func (t *Tracker) Snapshot() Snapshot {
+	t.mu.Lock()
+	total := t.total
+	t.mu.Unlock()
+
+	sum := t.window.Sum()
+	return Snapshot{
+		Total:      total,
+		Window:     sum,
+		WindowRate: float64(sum) / t.window.Size().Seconds(),
+		EWMARate:   t.ewma.Rate(),
+	}
+}
+
+// Set keeps one Tracker per key, e.g. per topic or per subscriber.
+type Set struct {
+	window, tau time.Duration
+
+	mu       sync.RWMutex
+	trackers map[string]*Tracker
+}
+
+// NewSet returns an empty set whose trackers use window and tau.
+func NewSet(window, tau time.Duration) *Set {
+	return &Set{window: window, tau: tau, trackers: make(map[string]*Tracker)}
+}
+
+// Get returns the tracker for key, creating it on first use.
+func (s *Set) Get(key string) *Tracker {
+	s.mu.RLock()
+	t, ok := s.trackers[key]
+	s.mu.RUnlock()
+	if ok {
+		return t
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if t, ok := s.trackers[key]; ok {
+		return t
+	}
+	t = NewTracker(s.window, s.tau)
+	s.trackers[key] = t
+	return t
+}
+
+// Add records n events for key.
+func (s *Set) Add(key string, n int64) {
+	s.Get(key).Add(n)
+}
+
+// Delete stops tracking key.
+func (s *Set) Delete(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.trackers, key)
+}
+
+// Keys returns the tracked keys in sorted order.
+func (s *Set) Keys() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	keys := make([]string, 0, len(s.trackers))
+	for k := range s.trackers {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// Snapshot returns statistics for every key.
+func (s *Set) Snapshot() map[string]Snapshot {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	out := make(map[string]Snapshot, len(s.trackers))
+	for k, t := range s.trackers {
+		out[k] = t.Snapshot()
+	}
+	return out
+}
@@ -0,0 +1,103 @@
+package ratetrack
+
+import (
+	"math"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock is a manually advanced clock for deterministic tests.
+type fakeClock struct {
+	mu sync.Mutex
+	t  time.Time
+}
+
+func (c *fakeClock) now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.t
+}
+
+func (c *fakeClock) advance(d time.Duration) {
+	c.mu.Lock()
+	c.t = c.t.Add(d)
+	c.mu.Unlock()
+}
+
+// TestWindow_Slides tests that old buckets fall out of the window
+func TestWindow_Slides(t *testing.T) {
+	clock := &fakeClock{t: time.Unix(1000, 0)}
+	w := NewWindow(3 * time.Second)
+	w.now = clock.now
+
+	w.Add(5) // t=1000
+	clock.advance(time.Second)
+	w.Add(3) // t=1001
+	clock.advance(time.Second)
+	w.Add(2) // t=1002
+
+	if got := w.Sum(); got != 10 {
+		t.Errorf("Sum() = %d, want 10", got)
+	}
+
+	clock.advance(time.Second) // t=1003: bucket 1000 leaves
+	if got := w.Sum(); got != 5 {
+		t.Errorf("Sum() after 1s = %d, want 5", got)
+	}
+	w.Add(1) // recycles the bucket that held 1000
+	if got := w.Sum(); got != 6 {
+		t.Errorf("Sum() after recycle = %d, want 6", got)
+	}
+
+	clock.advance(10 * time.Second)
+	if got := w.Sum(); got != 0 {
+		t.Errorf("Sum() after idle = %d, want 0", got)
+	}
+}
+
+// TestEWMA_ConvergesToSteadyRate tests that a constant rate is tracked
+func TestEWMA_ConvergesToSteadyRate(t *testing.T) {
+	clock := &fakeClock{t: time.Unix(0, 0)}
+	e := NewEWMA(5 * time.Second)
+	e.now = clock.now
+
+	// 100 events/s for a minute, delivered every 10ms
+	for range 6000 {
+		clock.advance(10 * time.Millisecond)
+		e.Add(1)
+	}
+	if got := e.Rate(); math.Abs(got-100) > 2 {
+		t.Errorf("Rate() = %.2f, want ~100", got)
+	}
+
+	// after one time constant of silence it decays to 1/e
+	clock.advance(5 * time.Second)
+	if got := e.Rate(); math.Abs(got-100/math.E) > 2 {
+		t.Errorf("Rate() after tau = %.2f, want ~%.2f", got, 100/math.E)
+	}
+}
+
+// TestSet_Snapshot tests per-key snapshots
+func TestSet_Snapshot(t *testing.T) {
+	s := NewSet(10*time.Second, time.Minute)
+	s.Add("news", 3)
+	s.Add("sports", 1)
+	s.Add("news", 2)
+
+	snap := s.Snapshot()
+	if got := snap["news"]; got.Total != 5 || got.Window != 5 {
+		t.Errorf("news = %+v, want Total 5, Window 5", got)
+	}
+	if got := snap["sports"].Total; got != 1 {
+		t.Errorf("sports total = %d, want 1", got)
+	}
+	if keys := s.Keys(); len(keys) != 2 || keys[0] != "news" {
+		t.Errorf("Keys() = %v", keys)
+	}
+
+	s.Delete("news")
+	if _, ok := s.Snapshot()["news"]; ok {
+		t.Error("news still tracked after Delete")
+	}
+}
@@ -0,0 +1,119 @@
+package rotate
+
+import (
+	"fmt"
+	"os"
+	"sync"
+)
+
+// File is an io.WriteCloser appending to path that rotates once the file
+// would grow past a size limit: path becomes path.1, path.1 becomes
+// path.2 and so on, and the oldest backup beyond the limit is removed.
+// A single Write is never split across files. It is safe for
+// concurrent use.
+type File struct {
+	path    string
+	maxSize int64
+	backups int
+
+	mu   sync.Mutex
+	f    *os.File
+	size int64
+}
+
+// Open opens path for appending, keeping at most backups rotated files
+// of about maxSize bytes each.
+func Open(path string, maxSize int64, backups int) (*File, error) {
+	r := &File{path: path, maxSize: maxSize, backups: max(backups, 0)}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// open opens the current file. Caller holds mu, or is Open.
+func (r *File) open() error {
+	f, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	r.f, r.size = f, info.Size()
+	return nil
+}
+
+// Write appends p, rotating first if p would take the file past the
+// size limit. A write larger than the limit gets a file of its own.
+func (r *File) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.f == nil {
+		return 0, os.ErrClosed
+	}
+	if r.size > 0 && r.size+int64(len(p)) > r.maxSize {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := r.f.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// Rotate starts a new file now.
+func (r *File) Rotate() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.f == nil {
+		return os.ErrClosed
+	}
+	return r.rotate()
+}
+
+// backup returns the name of the i'th rotated file.
+func (r *File) backup(i int) string {
+	return fmt.Sprintf("%s.%d", r.path, i)
+}
+
+// rotate shifts the backups and reopens path. Caller holds mu.
+func (r *File) rotate() error {
+	if err := r.f.Close(); err != nil {
+		return err
+	}
+	r.f = nil
+
+	if r.backups == 0 {
+		if err := os.Remove(r.path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return r.open()
+	}
+
+	os.Remove(r.backup(r.backups))
+	for i := r.backups - 1; i >= 1; i-- {
+		if err := os.Rename(r.backup(i), r.backup(i+1)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	if err := os.Rename(r.path, r.backup(1)); err != nil {
+		return err
+	}
+	return r.open()
+}
+
+// Close closes the current file.
+func (r *File) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.f == nil {
+		return os.ErrClosed
+	}
+	err := r.f.Close()
+	r.f = nil
+	return err
+}
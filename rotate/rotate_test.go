@@ -0,0 +1,70 @@
+package rotate
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func read(t *testing.T, path string) string {
+	t.Helper()
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+// TestFile_Rotate tests size-based rotation and backup limits
+func TestFile_Rotate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.log")
+	f, err := Open(path, 10, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, line := range []string{"aaaa\n", "bbbb\n", "cccc\n", "dddd\n", "eeee\n", "ffff\n", "gggg\n"} {
+		if _, err := f.Write([]byte(line)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name, want string
+	}{
+		{path, "gggg\n"},
+		{path + ".1", "eeee\nffff\n"},
+		{path + ".2", "cccc\ndddd\n"},
+	}
+	for _, tt := range tests {
+		if got := read(t, tt.name); got != tt.want {
+			t.Errorf("%s = %q, want %q", filepath.Base(tt.name), got, tt.want)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("backup .3 exists, want at most 2 backups")
+	}
+}
+
+// TestFile_Reopen tests that an existing file counts toward the limit
+func TestFile_Reopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.log")
+	os.WriteFile(path, []byte("12345678\n"), 0o644)
+
+	f, err := Open(path, 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	f.Write([]byte("next\n"))
+
+	if got := read(t, path); got != "next\n" {
+		t.Errorf("file = %q, want the old content rotated away", got)
+	}
+	if _, err := os.Stat(path + ".1"); !os.IsNotExist(err) {
+		t.Error("backup kept with backups = 0")
+	}
+}
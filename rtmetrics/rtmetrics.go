@@ -0,0 +1,142 @@
+package rtmetrics
+
+import (
+	"fmt"
+	"math"
+	"runtime/metrics"
+	"time"
+)
+
+// The runtime/metrics read by Take.
+const (
+	gcCycles     = "/gc/cycles/total:gc-cycles"
+	gcPauses     = "/sched/pauses/total/gc:seconds"
+	schedLatency = "/sched/latencies:seconds"
+	goroutines   = "/sched/goroutines:goroutines"
+	heapAllocs   = "/gc/heap/allocs:bytes"
+)
+
+// Snapshot is the state of the runtime at one moment. Take one before
+// and after a benchmark run and subtract them with Since, to see what
+// the garbage collector and the scheduler did meanwhile: a speedup that
+// comes with fewer GC cycles may be owed to allocation, not to the
+// algorithm.
+type Snapshot struct {
+	GCCycles   uint64
+	Goroutines uint64
+	Allocated  uint64 // heap bytes allocated since the program started
+
+	// Cumulative distributions of GC stop-the-world pauses and of how
+	// long runnable goroutines waited to run.
+	pauses, latencies *metrics.Float64Histogram
+}
+
+// Take reads the runtime's metrics.
+func Take() Snapshot {
+	samples := []metrics.Sample{{Name: gcCycles}, {Name: gcPauses}, {Name: schedLatency}, {Name: goroutines}, {Name: heapAllocs}}
+	metrics.Read(samples)
+	var s Snapshot
+	for _, sample := range samples {
+		switch v := sample.Value; sample.Name {
+		case gcCycles:
+			s.GCCycles = v.Uint64()
+		case goroutines:
+			s.Goroutines = v.Uint64()
+		case heapAllocs:
+			s.Allocated = v.Uint64()
+		case gcPauses:
+			s.pauses = v.Float64Histogram()
+		case schedLatency:
+			s.latencies = v.Float64Histogram()
+		}
+	}
+	return s
+}
+
+// Delta is what happened between two snapshots.
+type Delta struct {
+	GCCycles   uint64
+	GCPause    time.Duration // total, estimated from a histogram
+	SchedP50   time.Duration // scheduling latency percentiles
+	SchedP99   time.Duration
+	Goroutines [2]uint64 // before and after
+	Allocated  uint64    // heap bytes
+}
+
+// Since returns what happened between before and s.
+func (s Snapshot) Since(before Snapshot) Delta {
+	pauses := sub(s.pauses, before.pauses)
+	latencies := sub(s.latencies, before.latencies)
+	return Delta{
+		GCCycles:   s.GCCycles - before.GCCycles,
+		GCPause:    seconds(sum(pauses)),
+		SchedP50:   seconds(quantile(latencies, 0.50)),
+		SchedP99:   seconds(quantile(latencies, 0.99)),
+		Goroutines: [2]uint64{before.Goroutines, s.Goroutines},
+		Allocated:  s.Allocated - before.Allocated,
+	}
+}
+
+// String formats d on one line, for a benchmark report.
+func (d Delta) String() string {
+	return fmt.Sprintf("gc=%d pause=%v sched p50=%v p99=%v goroutines=%d→%d alloc=%.1fMiB",
+		d.GCCycles, d.GCPause, d.SchedP50, d.SchedP99, d.Goroutines[0], d.Goroutines[1], float64(d.Allocated)/(1<<20))
+}
+
+// sub returns the histogram of the events counted in a but not in b,
+// which has the same buckets.
+func sub(a, b *metrics.Float64Histogram) *metrics.Float64Histogram {
+	d := &metrics.Float64Histogram{Counts: make([]uint64, len(a.Counts)), Buckets: a.Buckets}
+	for i := range a.Counts {
+		d.Counts[i] = a.Counts[i] - b.Counts[i]
+	}
+	return d
+}
+
+// mid returns a representative value of bucket i: its midpoint, or its
+// finite bound if the other is infinite.
+func mid(h *metrics.Float64Histogram, i int) float64 {
+	lo, hi := h.Buckets[i], h.Buckets[i+1]
+	switch {
+	case math.IsInf(lo, -1):
+		return hi
+	case math.IsInf(hi, 1):
+		return lo
+	}
+	return (lo + hi) / 2
+}
+
+// sum estimates the total of the values counted in h.
+func sum(h *metrics.Float64Histogram) float64 {
+	var total float64
+	for i, n := range h.Counts {
+		if n > 0 {
+			total += float64(n) * mid(h, i)
+		}
+	}
+	return total
+}
+
+// quantile estimates the q-th quantile of the values counted in h, or
+// returns 0 if there are none.
+func quantile(h *metrics.Float64Histogram, q float64) float64 {
+	var total uint64
+	for _, n := range h.Counts {
+		total += n
+	}
+	if total == 0 {
+		return 0
+	}
+	rank := uint64(math.Ceil(q * float64(total)))
+	var seen uint64
+	for i, n := range h.Counts {
+		if seen += n; seen >= max(rank, 1) {
+			return mid(h, i)
+		}
+	}
+	return mid(h, len(h.Counts)-1)
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
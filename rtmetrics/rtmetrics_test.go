@@ -0,0 +1,82 @@
+package rtmetrics
+
+import (
+	"math"
+	"runtime"
+	"runtime/metrics"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+var sink []byte
+
+// TestSince tests that a run's garbage collections, allocations and
+// goroutines show up in the delta
+func TestSince(t *testing.T) {
+	before := Take()
+	for range 100 {
+		sink = make([]byte, 64<<10)
+	}
+	runtime.GC()
+	release := make(chan struct{})
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-release
+		}()
+	}
+	d := Take().Since(before)
+	close(release)
+	wg.Wait()
+
+	if d.GCCycles < 1 {
+		t.Errorf("GCCycles = %d, want at least 1", d.GCCycles)
+	}
+	if d.GCPause <= 0 {
+		t.Errorf("GCPause = %v, want some", d.GCPause)
+	}
+	if d.Allocated < 100*64<<10 {
+		t.Errorf("Allocated = %d, want at least %d", d.Allocated, 100*64<<10)
+	}
+	if d.Goroutines[1] < d.Goroutines[0]+10 {
+		t.Errorf("Goroutines = %v, want 10 more after", d.Goroutines)
+	}
+	if s := d.String(); !strings.HasPrefix(s, "gc=") {
+		t.Errorf("String() = %q", s)
+	}
+}
+
+// TestQuantile tests estimating percentiles and totals from histogram
+// buckets
+func TestQuantile(t *testing.T) {
+	h := &metrics.Float64Histogram{
+		Buckets: []float64{math.Inf(-1), 1, 2, 4, math.Inf(1)},
+		Counts:  []uint64{0, 98, 1, 1},
+	}
+	tests := []struct {
+		q    float64
+		want float64
+	}{
+		{0.50, 1.5},
+		{0.99, 3},
+		{1, 4},
+	}
+	for _, tt := range tests {
+		if got := quantile(h, tt.q); got != tt.want {
+			t.Errorf("quantile(%v) = %v, want %v", tt.q, got, tt.want)
+		}
+	}
+	if got, want := sum(h), 98*1.5+3+4; got != want {
+		t.Errorf("sum() = %v, want %v", got, want)
+	}
+	if got := quantile(&metrics.Float64Histogram{Buckets: h.Buckets, Counts: make([]uint64, 4)}, 0.5); got != 0 {
+		t.Errorf("quantile of nothing = %v, want 0", got)
+	}
+	if d := seconds(1.5); d != 1500*time.Millisecond {
+		t.Errorf("seconds(1.5) = %v", d)
+	}
+}
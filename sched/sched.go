@@ -0,0 +1,231 @@
+package sched
+
+import (
+	"math/rand/v2"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Scheduler is an experimental test scheduler that serializes the
+// goroutines of instrumented components, so a race-dependent failure
+// can be replayed. Components call Point before each interaction with
+// other goroutines (see pubsub.WithYield and workerpool.WithYield); the
+// goroutine then waits there until the scheduler lets it through. The
+// scheduler lets one through at a time, once the others have settled,
+// picking among those waiting with a seeded random source. The same
+// seed therefore gives the same order of points, and the order it
+// chose, Trace, can be replayed exactly with WithReplay.
+//
+// Goroutines waiting are told apart only by point name, so names should
+// say which goroutine reached them, e.g. "worker/3" rather than
+// "worker". Code that blocks between points on anything but another
+// instrumented goroutine (timers, I/O) makes runs less reproducible.
+type Scheduler struct {
+	seed   uint64
+	settle time.Duration
+	replay []string
+
+	mu       sync.Mutex
+	rng      *rand.Rand
+	waiting  []waiter
+	arrived  chan struct{} // signalled on every arrival
+	trace    []string
+	diverged int
+	stopped  bool
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+type waiter struct {
+	point   string
+	release chan struct{} // closed to let it through
+}
+
+// Option configures a Scheduler.
+type Option func(*Scheduler)
+
+// WithSettle sets how long no new goroutine may arrive at a point before
+// the scheduler picks which one goes next (default 2ms). Raise it if
+// the instrumented code does real work between points.
+func WithSettle(d time.Duration) Option {
+	return func(s *Scheduler) {
+		s.settle = d
+	}
+}
+
+// WithReplay makes the scheduler follow a recorded Trace instead of its
+// seed, for as long as the run matches it.
+func WithReplay(trace []string) Option {
+	return func(s *Scheduler) {
+		s.replay = slices.Clone(trace)
+	}
+}
+
+// New starts a scheduler whose choices derive from seed. Stop it when
+// the test ends.
+func New(seed uint64, opts ...Option) *Scheduler {
+	s := &Scheduler{
+		seed:    seed,
+		settle:  2 * time.Millisecond,
+		rng:     rand.New(rand.NewPCG(seed, seed^0x9e3779b97f4a7c15)),
+		arrived: make(chan struct{}, 1),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	go s.run()
+	return s
+}
+
+// Seed returns the scheduler's seed.
+func (s *Scheduler) Seed() uint64 {
+	return s.seed
+}
+
+// Point marks an interaction point named point and waits for the
+// scheduler to let the calling goroutine through. After Stop, and on a
+// nil Scheduler, it returns at once.
+func (s *Scheduler) Point(point string) {
+	if s == nil {
+		return
+	}
+	w := waiter{point: point, release: make(chan struct{})}
+	s.mu.Lock()
+	if s.stopped {
+		s.mu.Unlock()
+		return
+	}
+	s.waiting = append(s.waiting, w)
+	s.mu.Unlock()
+	select {
+	case s.arrived <- struct{}{}:
+	default:
+	}
+	<-w.release
+}
+
+// run lets goroutines through one at a time until Stop.
+func (s *Scheduler) run() {
+	defer close(s.done)
+	settle := time.NewTimer(s.settle)
+	for {
+		// wait for an arrival, then for arrivals to stop
+		select {
+		case <-s.stop:
+			return
+		case <-s.arrived:
+		}
+		for quiet := false; !quiet; {
+			if !settle.Stop() {
+				select {
+				case <-settle.C:
+				default:
+				}
+			}
+			settle.Reset(s.settle)
+			select {
+			case <-s.stop:
+				return
+			case <-s.arrived:
+			case <-settle.C:
+				quiet = true
+			}
+		}
+
+		s.mu.Lock()
+		if len(s.waiting) > 0 {
+			w := s.pick()
+			s.trace = append(s.trace, w.point)
+			close(w.release)
+		}
+		if len(s.waiting) > 0 {
+			// more to let through: go round without waiting for an arrival
+			select {
+			case s.arrived <- struct{}{}:
+			default:
+			}
+		}
+		s.mu.Unlock()
+	}
+}
+
+// pick removes and returns the next goroutine to let through: the one
+// the replayed trace names if it is waiting, and otherwise a random one.
+// Caller holds mu.
+func (s *Scheduler) pick() waiter {
+	// order by name, so the choice depends on who is waiting and not on
+	// the order they arrived in
+	slices.SortStableFunc(s.waiting, func(a, b waiter) int {
+		return strings.Compare(a.point, b.point)
+	})
+	i := -1
+	if n := len(s.trace); n < len(s.replay) {
+		i = slices.IndexFunc(s.waiting, func(w waiter) bool { return w.point == s.replay[n] })
+		if i < 0 {
+			s.diverged++
+		}
+	}
+	if i < 0 {
+		i = s.rng.IntN(len(s.waiting))
+	}
+	w := s.waiting[i]
+	s.waiting = slices.Delete(s.waiting, i, i+1)
+	return w
+}
+
+// Trace returns the points let through so far, in order.
+func (s *Scheduler) Trace() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.trace)
+}
+
+// Diverged returns how many times a replay found the goroutine the trace
+// names not waiting and picked another instead.
+func (s *Scheduler) Diverged() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.diverged
+}
+
+// Stop stops scheduling and lets every goroutine through, now and at
+// every later point.
+func (s *Scheduler) Stop() {
+	s.mu.Lock()
+	if s.stopped {
+		s.mu.Unlock()
+		return
+	}
+	s.stopped = true
+	for _, w := range s.waiting {
+		close(w.release)
+	}
+	s.waiting = nil
+	s.mu.Unlock()
+	close(s.stop)
+	<-s.done
+}
+
+// TestSeed returns the seed to run a test with: the SCHED_SEED
+// environment variable if set, and otherwise a random one. It logs the
+// seed, so a failing run can be repeated with SCHED_SEED.
+func TestSeed(t testing.TB) uint64 {
+	t.Helper()
+	seed := rand.Uint64()
+	if env := os.Getenv("SCHED_SEED"); env != "" {
+		var err error
+		if seed, err = strconv.ParseUint(env, 10, 64); err != nil {
+			t.Fatalf("SCHED_SEED: %v", err)
+		}
+	}
+	t.Logf("sched: seed %d (rerun with SCHED_SEED=%d)", seed, seed)
+	return seed
+}
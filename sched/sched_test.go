@@ -0,0 +1,141 @@
+package sched
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/arifmahmudrana/go-snippets/pubsub"
+	"github.com/arifmahmudrana/go-snippets/workerpool"
+)
+
+// lostUpdate runs three goroutines that each increment a counter with a
+// read and a write the scheduler may interleave, and returns the final
+// count and the trace.
+func lostUpdate(s *Scheduler) (int, []string) {
+	defer s.Stop()
+	var wg sync.WaitGroup
+	count := 0
+	for g := range 3 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.Point(fmt.Sprintf("read/%d", g))
+			v := count
+			s.Point(fmt.Sprintf("write/%d", g))
+			count = v + 1
+			s.Point(fmt.Sprintf("done/%d", g))
+		}()
+	}
+	wg.Wait()
+	return count, s.Trace()
+}
+
+// TestScheduler_Seed tests that a seed names one interleaving
+func TestScheduler_Seed(t *testing.T) {
+	counts := map[int]bool{}
+	for seed := range uint64(8) {
+		count, trace := lostUpdate(New(seed))
+		again, retrace := lostUpdate(New(seed))
+		if count != again || !slices.Equal(trace, retrace) {
+			t.Errorf("seed %d gave %d %v, then %d %v", seed, count, trace, again, retrace)
+		}
+		if len(trace) != 9 {
+			t.Errorf("seed %d: %d points let through, want 9", seed, len(trace))
+		}
+		counts[count] = true
+	}
+	if len(counts) < 2 {
+		t.Errorf("8 seeds gave counts %v, want several", counts)
+	}
+}
+
+// TestScheduler_Replay tests replaying a recorded trace under another
+// seed
+func TestScheduler_Replay(t *testing.T) {
+	seed := TestSeed(t)
+	count, trace := lostUpdate(New(seed))
+
+	s := New(seed+1, WithReplay(trace))
+	got, retrace := lostUpdate(s)
+	if got != count || !slices.Equal(retrace, trace) {
+		t.Errorf("replay gave %d %v, want %d %v", got, retrace, count, trace)
+	}
+	if s.Diverged() != 0 {
+		t.Errorf("Diverged() = %d, want 0", s.Diverged())
+	}
+}
+
+// TestScheduler_Pool tests scheduling an instrumented worker pool: where
+// an observer sees the pool's progress depends only on the seed
+func TestScheduler_Pool(t *testing.T) {
+	run := func(seed uint64) (int64, []string) {
+		s := New(seed, WithSettle(5*time.Millisecond))
+		defer s.Stop()
+		p := workerpool.New(context.Background(), 1, workerpool.WithYield(s.Point))
+		var done atomic.Int64
+		var seen int64
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.Point("observe")
+			seen = done.Load()
+		}()
+		for range 4 {
+			p.Submit(context.Background(), func(ctx context.Context) { done.Add(1) })
+		}
+		wg.Wait()
+		p.Close()
+		return seen, s.Trace()
+	}
+
+	seen := map[int64]bool{}
+	for seed := range uint64(6) {
+		got, trace := run(seed)
+		again, retrace := run(seed)
+		if got != again || !slices.Equal(trace, retrace) {
+			t.Errorf("seed %d gave %d %v, then %d %v", seed, got, trace, again, retrace)
+		}
+		seen[got] = true
+	}
+	if len(seen) < 2 {
+		t.Errorf("6 seeds all observed %v, want different progress", seen)
+	}
+}
+
+// TestScheduler_Broker tests that an instrumented broker still delivers
+// everything, in order, with its goroutines serialized
+func TestScheduler_Broker(t *testing.T) {
+	s := New(TestSeed(t))
+	defer s.Stop()
+	b := pubsub.NewBroker(pubsub.WithYield(s.Point))
+	defer b.Stop()
+
+	got := make(chan int, 5)
+	unsubscribe := b.SubscribeFunc("orders", func(m pubsub.Message) { got <- m.Payload.(int) })
+	defer unsubscribe()
+	for i := range 5 {
+		b.Publish("orders", i)
+	}
+	for want := range 5 {
+		select {
+		case v := <-got:
+			if v != want {
+				t.Errorf("received %d, want %d", v, want)
+			}
+		case <-time.After(3 * time.Second):
+			t.Fatalf("received %d messages, want 5", want)
+		}
+	}
+	trace := s.Trace()
+	for _, point := range []string{"broker", "publish/orders", "subscriber/orders/1"} {
+		if !slices.Contains(trace, point) {
+			t.Errorf("trace %v lacks %q", trace, point)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package scope
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"sync"
+)
+
+// Scope owns the goroutines started with Go inside one call to Run. See
+// Run.
+type Scope struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu     sync.Mutex
+	err    error  // first error
+	panic  *Panic // first panic
+	closed bool   // Run has returned
+}
+
+// Panic is what Run panics with when a goroutine in its scope panicked:
+// the original value and the goroutine's stack.
+type Panic struct {
+	Value any
+	Stack []byte
+}
+
+func (p *Panic) Error() string {
+	return fmt.Sprintf("scope: goroutine panicked: %v\n\n%s", p.Value, p.Stack)
+}
+
+// Run calls fn with a new scope and returns only once every goroutine
+// fn started with s.Go has returned, so none outlives the call. The
+// first error from fn or any of them cancels the scope's context,
+// telling the rest to give up, and is what Run returns. A panic in one
+// of them cancels the scope too and, once all have returned, is raised
+// again from Run as a *Panic.
+//
+// Scopes nest: a goroutine can call Run with its own context, and its
+// children end before it does.
+func Run(ctx context.Context, fn func(s *Scope) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	s := &Scope{ctx: ctx, cancel: cancel}
+	defer cancel()
+
+	func() {
+		defer func() {
+			if v := recover(); v != nil {
+				s.fail(nil, &Panic{Value: v, Stack: debug.Stack()})
+			}
+		}()
+		if err := fn(s); err != nil {
+			s.fail(err, nil)
+		}
+	}()
+	s.wg.Wait()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	if s.panic != nil {
+		panic(s.panic)
+	}
+	return s.err
+}
+
+// Go runs fn in a new goroutine owned by the scope, passing it the
+// scope's context. It panics if called after Run returned.
+func (s *Scope) Go(fn func(ctx context.Context) error) {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		panic("scope: Go called after Run returned")
+	}
+	s.wg.Add(1)
+	s.mu.Unlock()
+
+	go func() {
+		defer s.wg.Done()
+		defer func() {
+			if v := recover(); v != nil {
+				s.fail(nil, &Panic{Value: v, Stack: debug.Stack()})
+			}
+		}()
+		if err := fn(s.ctx); err != nil {
+			s.fail(err, nil)
+		}
+	}()
+}
+
+// Context returns the scope's context, which is cancelled on the first
+// error and when Run returns.
+func (s *Scope) Context() context.Context {
+	return s.ctx
+}
+
+// fail records the first error or panic and cancels the scope.
+func (s *Scope) fail(err error, p *Panic) {
+	s.mu.Lock()
+	if s.err == nil && s.panic == nil {
+		s.err, s.panic = err, p
+	} else if p != nil && s.panic == nil {
+		s.panic = p // a panic outranks an earlier error
+	}
+	s.mu.Unlock()
+	s.cancel()
+}
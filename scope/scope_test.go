@@ -0,0 +1,121 @@
+package scope
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestRun tests that Run waits for every goroutine and returns the
+// first error
+func TestRun(t *testing.T) {
+	boom := errors.New("boom")
+	tests := []struct {
+		name    string
+		errs    []error // one goroutine per entry
+		fnErr   error
+		want    error
+		wantRan int32
+	}{
+		{"none", nil, nil, nil, 0},
+		{"all ok", []error{nil, nil, nil}, nil, nil, 3},
+		{"one fails", []error{nil, boom, nil}, nil, boom, 3},
+		{"fn fails", []error{nil, nil}, boom, boom, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ran atomic.Int32
+			err := Run(context.Background(), func(s *Scope) error {
+				for _, e := range tt.errs {
+					s.Go(func(ctx context.Context) error {
+						time.Sleep(5 * time.Millisecond)
+						ran.Add(1)
+						return e
+					})
+				}
+				return tt.fnErr
+			})
+			if !errors.Is(err, tt.want) {
+				t.Errorf("Run() = %v, want %v", err, tt.want)
+			}
+			if got := ran.Load(); got != tt.wantRan {
+				t.Errorf("%d goroutines finished before Run returned, want %d", got, tt.wantRan)
+			}
+		})
+	}
+}
+
+// TestRun_Cancel tests that the first error cancels the other goroutines
+// and nested scopes
+func TestRun_Cancel(t *testing.T) {
+	boom := errors.New("boom")
+	var cancelled atomic.Int32
+	wait := func(ctx context.Context) error {
+		select {
+		case <-ctx.Done():
+			cancelled.Add(1)
+			return ctx.Err()
+		case <-time.After(3 * time.Second):
+			return nil
+		}
+	}
+	err := Run(context.Background(), func(s *Scope) error {
+		s.Go(wait)
+		s.Go(func(ctx context.Context) error {
+			return Run(ctx, func(s *Scope) error {
+				s.Go(wait)
+				return nil
+			})
+		})
+		s.Go(func(ctx context.Context) error { return boom })
+		return nil
+	})
+	if err != boom {
+		t.Errorf("Run() = %v, want %v", err, boom)
+	}
+	if got := cancelled.Load(); got != 2 {
+		t.Errorf("%d goroutines cancelled, want 2", got)
+	}
+}
+
+// TestRun_Panic tests that a panicking goroutine cancels the scope and
+// the panic is raised from Run
+func TestRun_Panic(t *testing.T) {
+	var cancelled atomic.Bool
+	defer func() {
+		p, ok := recover().(*Panic)
+		if !ok || p.Value != "boom" {
+			t.Errorf("Run() panicked with %v, want a *Panic of boom", p)
+		}
+		if !cancelled.Load() {
+			t.Error("sibling not cancelled")
+		}
+	}()
+	Run(context.Background(), func(s *Scope) error {
+		s.Go(func(ctx context.Context) error {
+			<-ctx.Done()
+			cancelled.Store(true)
+			return nil
+		})
+		s.Go(func(ctx context.Context) error { panic("boom") })
+		return nil
+	})
+	t.Error("Run() returned, want panic")
+}
+
+// TestScope_GoAfterRun tests that Go panics once Run has returned
+func TestScope_GoAfterRun(t *testing.T) {
+	var leaked *Scope
+	Run(context.Background(), func(s *Scope) error {
+		leaked = s
+		return nil
+	})
+	defer func() {
+		if recover() == nil {
+			t.Error("Go() after Run did not panic")
+		}
+	}()
+	leaked.Go(func(ctx context.Context) error { return nil })
+}
@@ -0,0 +1,128 @@
+package semaphore
+
+import (
+	"container/list"
+	"context"
+	"sync"
+)
+
+// Weighted is a semaphore where each acquisition takes an arbitrary
+// weight, such as a number of bytes. Waiters are served in FIFO order so
+// a large request cannot be starved by a stream of small ones.
+type Weighted struct {
+	size    int64
+	cur     int64
+	mu      sync.Mutex
+	waiters list.List
+}
+
+type waiter struct {
+	n     int64
+	ready chan struct{}
+}
+
+// NewWeighted returns a semaphore with the given total capacity.
+func NewWeighted(size int64) *Weighted {
+	return &Weighted{size: size}
+}
+
+// Acquire takes n units, blocking until they are available or ctx is
+// done. Requests larger than the capacity block until ctx is done.
+func (s *Weighted) Acquire(ctx context.Context, n int64) error {
+	s.mu.Lock()
+	if s.size-s.cur >= n && s.waiters.Len() == 0 {
+		s.cur += n
+		s.mu.Unlock()
+		return nil
+	}
+
+	if n > s.size {
+		// can never succeed; wait for cancellation
+		s.mu.Unlock()
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	w := waiter{n: n, ready: make(chan struct{})}
+	elem := s.waiters.PushBack(w)
+	s.mu.Unlock()
+
+	select {
+	case <-ctx.Done():
+		s.mu.Lock()
+		select {
+		case <-w.ready:
+			// acquired just as we were cancelled; give it back
+			s.cur -= n
+			s.notifyWaiters()
+		default:
+			isFront := s.waiters.Front() == elem
+			s.waiters.Remove(elem)
+			// our departure may unblock the waiters behind us
+			if isFront && s.size > s.cur {
+				s.notifyWaiters()
+			}
+		}
+		s.mu.Unlock()
+		return ctx.Err()
+
+	case <-w.ready:
+		return nil
+	}
+}
+
+// TryAcquire takes n units without blocking and reports success.
+func (s *Weighted) TryAcquire(n int64) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.size-s.cur >= n && s.waiters.Len() == 0 {
+		s.cur += n
+		return true
+	}
+	return false
+}
+
+// Release returns n units to the semaphore.
+func (s *Weighted) Release(n int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.cur -= n
+	if s.cur < 0 {
+		panic("semaphore: released more than held")
+	}
+	s.notifyWaiters()
+}
+
+// InUse returns the number of units currently held.
+func (s *Weighted) InUse() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.cur
+}
+
+// Size returns the total capacity.
+func (s *Weighted) Size() int64 {
+	return s.size
+}
+
+// notifyWaiters wakes waiters in FIFO order while they fit. Caller holds mu.
+func (s *Weighted) notifyWaiters() {
+	for {
+		next := s.waiters.Front()
+		if next == nil {
+			return
+		}
+
+		w := next.Value.(waiter)
+		if s.size-s.cur < w.n {
+			// head of line does not fit; stop to keep FIFO order
+			return
+		}
+
+		s.cur += w.n
+		s.waiters.Remove(next)
+		close(w.ready)
+	}
+}
@@ -0,0 +1,89 @@
+package semaphore
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestWeighted_Limit tests that held weight never exceeds the capacity
+func TestWeighted_Limit(t *testing.T) {
+	const size = 10
+	s := NewWeighted(size)
+
+	var held, peak atomic.Int64
+	var wg sync.WaitGroup
+	for i := range 50 {
+		wg.Add(1)
+		go func(n int64) {
+			defer wg.Done()
+			if err := s.Acquire(context.Background(), n); err != nil {
+				t.Error(err)
+				return
+			}
+			cur := held.Add(n)
+			for {
+				p := peak.Load()
+				if cur <= p || peak.CompareAndSwap(p, cur) {
+					break
+				}
+			}
+			time.Sleep(time.Millisecond)
+			held.Add(-n)
+			s.Release(n)
+		}(int64(i%4 + 1))
+	}
+	wg.Wait()
+
+	if p := peak.Load(); p > size {
+		t.Errorf("peak held = %d, want <= %d", p, size)
+	}
+	if got := s.InUse(); got != 0 {
+		t.Errorf("InUse() after all releases = %d, want 0", got)
+	}
+}
+
+// TestWeighted_FIFO tests that a large waiter is not starved by small ones
+func TestWeighted_FIFO(t *testing.T) {
+	s := NewWeighted(4)
+	s.Acquire(context.Background(), 3)
+
+	bigDone := make(chan struct{})
+	go func() {
+		s.Acquire(context.Background(), 4)
+		close(bigDone)
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	// one unit is free, but the big waiter is ahead in line
+	if s.TryAcquire(1) {
+		t.Fatal("TryAcquire(1) jumped the queue")
+	}
+
+	s.Release(3)
+	select {
+	case <-bigDone:
+	case <-time.After(time.Second):
+		t.Fatal("big waiter not woken")
+	}
+}
+
+// TestWeighted_Cancel tests that a cancelled waiter leaves no trace
+func TestWeighted_Cancel(t *testing.T) {
+	s := NewWeighted(2)
+	s.Acquire(context.Background(), 2)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := s.Acquire(ctx, 1); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Acquire() = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	s.Release(2)
+	if !s.TryAcquire(2) {
+		t.Error("capacity leaked by cancelled waiter")
+	}
+}
@@ -0,0 +1,207 @@
+package shed
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/arifmahmudrana/go-snippets/jobs"
+	"github.com/arifmahmudrana/go-snippets/pubsub"
+)
+
+// ErrShed is returned for work rejected because the system is
+// overloaded.
+var ErrShed = errors.New("shed: overloaded")
+
+// Shedder decides which work to reject when a system falls behind,
+// using CoDel (controlled delay): it watches how long work waited
+// before being picked up. Short spikes are absorbed, but once the wait
+// has stayed above the target for a whole interval, it starts rejecting
+// work, more often the longer the overload lasts, until the wait drops
+// below the target again. Rejecting early keeps the system serving the
+// work it accepts quickly instead of serving everything late.
+type Shedder struct {
+	target      time.Duration
+	interval    time.Duration
+	maxInFlight int
+	now         func() time.Time
+
+	slots chan struct{} // nil without a limit
+
+	mu         sync.Mutex
+	firstAbove time.Time // when the wait may start counting as overload
+	dropping   bool
+	dropNext   time.Time
+	drops      int // in the current dropping period
+
+	admitted, shed atomic.Uint64
+}
+
+// Option configures a Shedder.
+type Option func(*Shedder)
+
+// WithTarget sets the acceptable wait (default 5ms).
+func WithTarget(d time.Duration) Option {
+	return func(s *Shedder) {
+		s.target = d
+	}
+}
+
+// WithInterval sets how long the wait must stay above the target before
+// work is shed, about one worst-case processing time (default 100ms).
+func WithInterval(d time.Duration) Option {
+	return func(s *Shedder) {
+		s.interval = d
+	}
+}
+
+// WithMaxInFlight limits how much work Do and Handler run at once; the
+// rest queues, and its time in the queue is the wait CoDel watches.
+// Without it, they run everything at once and shed only on the waits
+// callers report through Admit.
+func WithMaxInFlight(n int) Option {
+	return func(s *Shedder) {
+		s.maxInFlight = max(n, 1)
+	}
+}
+
+// New returns a Shedder.
+func New(opts ...Option) *Shedder {
+	s := &Shedder{target: 5 * time.Millisecond, interval: 100 * time.Millisecond, now: time.Now}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if s.maxInFlight > 0 {
+		s.slots = make(chan struct{}, s.maxInFlight)
+	}
+	return s
+}
+
+// Admit reports whether work that was queued at enqueued and is being
+// picked up now should run, or fail with ErrShed.
+func (s *Shedder) Admit(enqueued time.Time) error {
+	now := s.now()
+	s.mu.Lock()
+	drop := s.shouldDrop(now, now.Sub(enqueued))
+	s.mu.Unlock()
+	if drop {
+		s.shed.Add(1)
+		return ErrShed
+	}
+	s.admitted.Add(1)
+	return nil
+}
+
+// shouldDrop is the CoDel control law. Caller holds mu.
+func (s *Shedder) shouldDrop(now time.Time, wait time.Duration) bool {
+	if wait < s.target {
+		s.firstAbove, s.dropping = time.Time{}, false
+		return false
+	}
+	if s.firstAbove.IsZero() {
+		s.firstAbove = now.Add(s.interval)
+		return false
+	}
+	if now.Before(s.firstAbove) {
+		return false
+	}
+	if !s.dropping {
+		s.dropping, s.drops = true, 1
+		s.dropNext = s.next(now)
+		return true
+	}
+	if now.Before(s.dropNext) {
+		return false
+	}
+	s.drops++
+	s.dropNext = s.next(s.dropNext)
+	return true
+}
+
+// next returns when to drop again: sooner with every drop, as
+// interval/sqrt(drops).
+func (s *Shedder) next(t time.Time) time.Time {
+	return t.Add(time.Duration(float64(s.interval) / math.Sqrt(float64(s.drops))))
+}
+
+// Do runs fn unless it is shed, in which case it returns ErrShed. With
+// WithMaxInFlight it first waits for a slot, or for ctx.
+func (s *Shedder) Do(ctx context.Context, fn func(ctx context.Context) error) error {
+	enqueued := s.now()
+	if s.slots != nil {
+		select {
+		case s.slots <- struct{}{}:
+			defer func() { <-s.slots }()
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	if err := s.Admit(enqueued); err != nil {
+		return err
+	}
+	return fn(ctx)
+}
+
+// Handler sheds HTTP requests, answering those it rejects with 503
+// Service Unavailable and a Retry-After header.
+func (s *Shedder) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		err := s.Do(r.Context(), func(ctx context.Context) error {
+			next.ServeHTTP(w, r)
+			return nil
+		})
+		if errors.Is(err, ErrShed) {
+			w.Header().Set("Retry-After", strconv.Itoa(max(int(s.interval/time.Second), 1)))
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		}
+	})
+}
+
+// Messages wraps a broker subscriber function, shedding messages that
+// waited too long since the broker accepted them (Message.Time). Shed
+// messages go to onShed, if not nil, e.g. to publish them to a
+// dead-letter topic.
+func (s *Shedder) Messages(fn func(pubsub.Message), onShed func(pubsub.Message)) func(pubsub.Message) {
+	return func(m pubsub.Message) {
+		if err := s.Admit(m.Time); err != nil {
+			if onShed != nil {
+				onShed(m)
+			}
+			return
+		}
+		fn(m)
+	}
+}
+
+// Jobs wraps a jobs handler, shedding attempts that waited too long for
+// a worker (see jobs.QueuedAt). A shed attempt fails with ErrShed and
+// counts against the job's attempts like any failure.
+func (s *Shedder) Jobs(h jobs.Handler) jobs.Handler {
+	return func(ctx context.Context, payload []byte) ([]byte, error) {
+		if queued, ok := jobs.QueuedAt(ctx); ok {
+			if err := s.Admit(queued); err != nil {
+				return nil, err
+			}
+		}
+		return h(ctx, payload)
+	}
+}
+
+// Stats counts a Shedder's decisions.
+type Stats struct {
+	Admitted, Shed uint64
+	Dropping       bool // currently shedding
+}
+
+// Stats returns the shedder's counters.
+func (s *Shedder) Stats() Stats {
+	s.mu.Lock()
+	dropping := s.dropping
+	s.mu.Unlock()
+	return Stats{Admitted: s.admitted.Load(), Shed: s.shed.Load(), Dropping: dropping}
+}
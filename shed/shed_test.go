@@ -0,0 +1,113 @@
+package shed
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/arifmahmudrana/go-snippets/jobs"
+)
+
+// TestShedder_Admit tests the CoDel control law
+func TestShedder_Admit(t *testing.T) {
+	s := New(WithTarget(5*time.Millisecond), WithInterval(100*time.Millisecond))
+	var now time.Time
+	s.now = func() time.Time { return now }
+
+	ms := time.Millisecond
+	steps := []struct {
+		at, wait time.Duration
+		want     bool // shed
+	}{
+		{0, 1 * ms, false},
+		{10 * ms, 10 * ms, false}, // above target: the interval starts
+		{50 * ms, 10 * ms, false},
+		{110 * ms, 10 * ms, true}, // above for a whole interval
+		{150 * ms, 10 * ms, false},
+		{210 * ms, 10 * ms, true}, // next drop after interval/sqrt(1)
+		{270 * ms, 10 * ms, false},
+		{281 * ms, 10 * ms, true}, // then interval/sqrt(2)
+		{290 * ms, 1 * ms, false}, // below target: back to normal
+		{300 * ms, 10 * ms, false},
+	}
+	for _, st := range steps {
+		now = time.Unix(0, 0).Add(st.at)
+		err := s.Admit(now.Add(-st.wait))
+		if got := errors.Is(err, ErrShed); got != st.want {
+			t.Errorf("at %v, wait %v: shed = %v, want %v", st.at, st.wait, got, st.want)
+		}
+	}
+	if got := s.Stats(); got.Shed != 3 || got.Admitted != 7 {
+		t.Errorf("Stats() = %+v, want 3 shed, 7 admitted", got)
+	}
+}
+
+// TestShedder_Handler tests that an overloaded handler sheds some
+// requests and serves the rest
+func TestShedder_Handler(t *testing.T) {
+	s := New(WithTarget(time.Millisecond), WithInterval(10*time.Millisecond), WithMaxInFlight(1))
+	srv := httptest.NewServer(s.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(5 * time.Millisecond)
+	})))
+	defer srv.Close()
+
+	var mu sync.Mutex
+	codes := make(map[int]int)
+	var wg sync.WaitGroup
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := http.Get(srv.URL)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			resp.Body.Close()
+			mu.Lock()
+			codes[resp.StatusCode]++
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	if codes[http.StatusOK] == 0 || codes[http.StatusServiceUnavailable] == 0 {
+		t.Errorf("status codes = %v, want some served and some shed", codes)
+	}
+}
+
+// TestShedder_Jobs tests shedding jobs that waited too long for a worker
+func TestShedder_Jobs(t *testing.T) {
+	s := New(WithTarget(time.Millisecond), WithInterval(10*time.Millisecond))
+	q := jobs.New(s.Jobs(func(ctx context.Context, payload []byte) ([]byte, error) {
+		time.Sleep(5 * time.Millisecond)
+		return nil, nil
+	}), jobs.WithWorkers(1), jobs.WithMaxAttempts(1))
+	defer q.Close()
+
+	var handles []*jobs.Handle
+	for range 20 {
+		h, err := q.Enqueue(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		handles = append(handles, h)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	shed := 0
+	for _, h := range handles {
+		if _, err := h.Wait(ctx); err != nil {
+			if h.Job().Err != ErrShed.Error() {
+				t.Fatalf("job failed with %v", err)
+			}
+			shed++
+		}
+	}
+	if shed == 0 || shed == len(handles) {
+		t.Errorf("%d of %d jobs shed, want some", shed, len(handles))
+	}
+}
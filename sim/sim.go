@@ -0,0 +1,128 @@
+package sim
+
+import (
+	"container/heap"
+	"math/rand/v2"
+	"time"
+)
+
+// Epoch is the virtual time at which every simulation starts.
+var Epoch = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// Sim is a discrete-event simulator with a virtual clock. Events run one
+// at a time on the caller's goroutine, in time order; events due at the
+// same instant run in an order chosen by the seeded random source, so a
+// seed names one interleaving and replaying it gives the same run.
+//
+// A Sim is not safe for concurrent use.
+type Sim struct {
+	now    time.Time
+	rng    *rand.Rand
+	events eventHeap
+	steps  int
+}
+
+type event struct {
+	at  time.Time
+	key uint64 // random tie-breaker for events due at the same time
+	fn  func()
+}
+
+// New returns a simulator at Epoch whose interleavings derive from seed.
+func New(seed uint64) *Sim {
+	return &Sim{now: Epoch, rng: rand.New(rand.NewPCG(seed, seed^0x9e3779b97f4a7c15))}
+}
+
+// Now returns the current virtual time. It can be injected anywhere a
+// func() time.Time clock is expected.
+func (s *Sim) Now() time.Time {
+	return s.now
+}
+
+// Rand returns the simulation's random source. Drawing from it, rather
+// than from a global source, keeps runs reproducible.
+func (s *Sim) Rand() *rand.Rand {
+	return s.rng
+}
+
+// At schedules fn to run at t, or immediately after the current event if
+// t is in the past.
+func (s *Sim) At(t time.Time, fn func()) {
+	if t.Before(s.now) {
+		t = s.now
+	}
+	heap.Push(&s.events, event{at: t, key: s.rng.Uint64(), fn: fn})
+}
+
+// After schedules fn to run d after the current virtual time.
+func (s *Sim) After(d time.Duration, fn func()) {
+	s.At(s.now.Add(d), fn)
+}
+
+// Every schedules fn to run every d, starting d from now, for as long as
+// fn returns true.
+func (s *Sim) Every(d time.Duration, fn func() bool) {
+	var tick func()
+	tick = func() {
+		if fn() {
+			s.After(d, tick)
+		}
+	}
+	s.After(d, tick)
+}
+
+// Step runs the next event, advancing the clock to its time. It reports
+// false if no events are left.
+func (s *Sim) Step() bool {
+	if len(s.events) == 0 {
+		return false
+	}
+	e := heap.Pop(&s.events).(event)
+	s.now = e.at
+	s.steps++
+	e.fn()
+	return true
+}
+
+// Run runs events until none are left and returns the number run.
+func (s *Sim) Run() int {
+	start := s.steps
+	for s.Step() {
+	}
+	return s.steps - start
+}
+
+// RunFor runs events due within d of the current time, then advances
+// the clock to exactly that point. It returns the number of events run.
+func (s *Sim) RunFor(d time.Duration) int {
+	start, end := s.steps, s.now.Add(d)
+	for len(s.events) > 0 && !s.events[0].at.After(end) {
+		s.Step()
+	}
+	s.now = end
+	return s.steps - start
+}
+
+// Pending returns the number of scheduled events.
+func (s *Sim) Pending() int {
+	return len(s.events)
+}
+
+// eventHeap orders events by time, then by their random key.
+type eventHeap []event
+
+func (h eventHeap) Len() int { return len(h) }
+func (h eventHeap) Less(i, j int) bool {
+	if !h[i].at.Equal(h[j].at) {
+		return h[i].at.Before(h[j].at)
+	}
+	return h[i].key < h[j].key
+}
+func (h eventHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+func (h *eventHeap) Push(x any)   { *h = append(*h, x.(event)) }
+func (h *eventHeap) Pop() any {
+	old := *h
+	e := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return e
+}
@@ -0,0 +1,68 @@
+package sim
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+)
+
+// TestSim_Order tests that events run in time order on the virtual clock
+func TestSim_Order(t *testing.T) {
+	s := New(1)
+	var got []string
+	s.After(3*time.Second, func() { got = append(got, fmt.Sprint("c@", s.Now().Sub(Epoch))) })
+	s.After(time.Second, func() {
+		got = append(got, fmt.Sprint("a@", s.Now().Sub(Epoch)))
+		s.After(time.Second, func() { got = append(got, fmt.Sprint("b@", s.Now().Sub(Epoch))) })
+	})
+
+	if n := s.Run(); n != 3 {
+		t.Errorf("Run() = %d, want 3", n)
+	}
+	want := []string{"a@1s", "b@2s", "c@3s"}
+	if !slices.Equal(got, want) {
+		t.Errorf("order = %v, want %v", got, want)
+	}
+}
+
+// TestSim_Interleavings tests that ties follow the seed reproducibly
+func TestSim_Interleavings(t *testing.T) {
+	run := func(seed uint64) string {
+		s := New(seed)
+		var out []byte
+		for _, c := range "abcdefgh" {
+			s.After(time.Second, func() { out = append(out, byte(c)) })
+		}
+		s.Run()
+		return string(out)
+	}
+
+	if a, b := run(7), run(7); a != b {
+		t.Errorf("seed 7 gave %q then %q", a, b)
+	}
+	seen := map[string]bool{}
+	for seed := range uint64(20) {
+		seen[run(seed)] = true
+	}
+	if len(seen) < 2 {
+		t.Errorf("20 seeds gave %d interleavings, want several", len(seen))
+	}
+}
+
+// TestSim_RunFor tests running up to a virtual deadline
+func TestSim_RunFor(t *testing.T) {
+	s := New(1)
+	ticks := 0
+	s.Every(time.Second, func() bool { ticks++; return true })
+
+	if n := s.RunFor(5 * time.Second); n != 5 {
+		t.Errorf("RunFor() = %d, want 5", n)
+	}
+	if got := s.Now().Sub(Epoch); got != 5*time.Second {
+		t.Errorf("Now() = Epoch+%v, want Epoch+5s", got)
+	}
+	if s.Pending() != 1 {
+		t.Errorf("Pending() = %d, want 1", s.Pending())
+	}
+}
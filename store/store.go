@@ -0,0 +1,219 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+)
+
+var (
+	// ErrNotFound is returned by Get when the key does not exist.
+	ErrNotFound = errors.New("store: key not found")
+	// ErrExists is returned by Create when the key already exists.
+	ErrExists = errors.New("store: key exists")
+)
+
+// Store is a minimal key/value persistence interface. Implementations
+// must be safe for concurrent use. Values returned by Get are owned by
+// the caller.
+type Store interface {
+	Get(ctx context.Context, key string) ([]byte, error)
+	Put(ctx context.Context, key string, value []byte) error
+	Delete(ctx context.Context, key string) error
+	// List returns the keys starting with prefix, in lexical order.
+	List(ctx context.Context, prefix string) ([]string, error)
+}
+
+// Creator is a Store that can also create a key only if it does not
+// exist yet. Create is atomic: of concurrent Creates of one key, from
+// any number of processes sharing the store, exactly one succeeds and
+// the others fail with ErrExists. That is enough to claim work so only
+// one process does it.
+type Creator interface {
+	Store
+	Create(ctx context.Context, key string, value []byte) error
+}
+
+// Memory is an in-memory Store, handy for tests and demos.
+type Memory struct {
+	mu   sync.RWMutex
+	data map[string][]byte
+}
+
+// NewMemory returns an empty in-memory store.
+func NewMemory() *Memory {
+	return &Memory{data: make(map[string][]byte)}
+}
+
+func (m *Memory) Get(ctx context.Context, key string) ([]byte, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	v, ok := m.data[key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return slices.Clone(v), nil
+}
+
+func (m *Memory) Put(ctx context.Context, key string, value []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.data[key] = slices.Clone(value)
+	return nil
+}
+
+func (m *Memory) Create(ctx context.Context, key string, value []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.data[key]; ok {
+		return ErrExists
+	}
+	m.data[key] = slices.Clone(value)
+	return nil
+}
+
+func (m *Memory) Delete(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.data, key)
+	return nil
+}
+
+func (m *Memory) List(ctx context.Context, prefix string) ([]string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var keys []string
+	for k := range m.data {
+		if strings.HasPrefix(k, prefix) {
+			keys = append(keys, k)
+		}
+	}
+	slices.Sort(keys)
+	return keys, nil
+}
+
+// File is a Store keeping one file per key inside a directory. Writes go
+// to a temporary file that is renamed into place, so a crash never leaves
+// a half-written value behind.
+type File struct {
+	dir string
+	mu  sync.RWMutex // orders Put/Delete against List
+}
+
+// NewFile returns a store rooted at dir, creating it if needed.
+func NewFile(dir string) (*File, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &File{dir: dir}, nil
+}
+
+// path maps a key to a file name; keys may contain any characters.
+func (f *File) path(key string) string {
+	return filepath.Join(f.dir, url.PathEscape(key))
+}
+
+func (f *File) Get(ctx context.Context, key string) ([]byte, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	b, err := os.ReadFile(f.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return b, err
+}
+
+func (f *File) Put(ctx context.Context, key string, value []byte) error {
+	tmp, err := f.writeTemp(value)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp) // no-op after a successful rename
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return os.Rename(tmp, f.path(key))
+}
+
+// Create links a temporary file into place, which unlike a rename fails
+// if the key's file exists, so it is atomic across processes too.
+func (f *File) Create(ctx context.Context, key string, value []byte) error {
+	tmp, err := f.writeTemp(value)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	err = os.Link(tmp, f.path(key))
+	if errors.Is(err, os.ErrExist) {
+		return ErrExists
+	}
+	return err
+}
+
+// writeTemp writes value to a new temporary file in the directory, and
+// returns its name.
+func (f *File) writeTemp(value []byte) (string, error) {
+	tmp, err := os.CreateTemp(f.dir, ".tmp-*")
+	if err != nil {
+		return "", err
+	}
+	if _, err := tmp.Write(value); err == nil {
+		err = tmp.Sync()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	return tmp.Name(), nil
+}
+
+func (f *File) Delete(ctx context.Context, key string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	err := os.Remove(f.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+func (f *File) List(ctx context.Context, prefix string) ([]string, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	entries, err := os.ReadDir(f.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []string
+	for _, e := range entries {
+		if e.IsDir() || strings.HasPrefix(e.Name(), ".tmp-") {
+			continue
+		}
+		key, err := url.PathUnescape(e.Name())
+		if err != nil {
+			continue
+		}
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	slices.Sort(keys)
+	return keys, nil
+}
@@ -0,0 +1,123 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// testStore runs the behaviour every Store implementation must share.
+func testStore(t *testing.T, s Store) {
+	ctx := context.Background()
+
+	if _, err := s.Get(ctx, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get(missing) = %v, want %v", err, ErrNotFound)
+	}
+
+	keys := []string{"job/1", "job/2", "other", "with/slashes and spaces?"}
+	for _, k := range keys {
+		if err := s.Put(ctx, k, []byte("v:"+k)); err != nil {
+			t.Fatalf("Put(%q) = %v", k, err)
+		}
+	}
+
+	got, err := s.Get(ctx, "with/slashes and spaces?")
+	if err != nil || string(got) != "v:with/slashes and spaces?" {
+		t.Errorf("Get() = %q, %v", got, err)
+	}
+
+	// values are copied, not aliased
+	got[0] = 'X'
+	if again, _ := s.Get(ctx, "with/slashes and spaces?"); again[0] != 'v' {
+		t.Error("mutating a returned value changed the stored value")
+	}
+
+	if err := s.Put(ctx, "job/1", []byte("new")); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := s.Get(ctx, "job/1"); string(got) != "new" {
+		t.Errorf("overwrite: Get() = %q, want %q", got, "new")
+	}
+
+	list, err := s.List(ctx, "job/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"job/1", "job/2"}; !reflect.DeepEqual(list, want) {
+		t.Errorf("List(job/) = %v, want %v", list, want)
+	}
+
+	if err := s.Delete(ctx, "job/1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Delete(ctx, "job/1"); err != nil {
+		t.Errorf("deleting a missing key = %v, want nil", err)
+	}
+	if _, err := s.Get(ctx, "job/1"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get() after Delete = %v, want %v", err, ErrNotFound)
+	}
+}
+
+// TestMemory tests the in-memory store
+func TestMemory(t *testing.T) {
+	testStore(t, NewMemory())
+}
+
+// TestFile tests the file-backed store, including reopening it
+func TestFile(t *testing.T) {
+	dir := t.TempDir()
+	s, err := NewFile(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testStore(t, s)
+
+	reopened, err := NewFile(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := reopened.Get(context.Background(), "other"); err != nil || string(got) != "v:other" {
+		t.Errorf("after reopen Get() = %q, %v", got, err)
+	}
+}
+
+// TestCreator_Create tests that of concurrent Creates of one key, from
+// stores sharing the data as processes would, exactly one succeeds
+func TestCreator_Create(t *testing.T) {
+	dir := t.TempDir()
+	files := make([]Creator, 4)
+	for i := range files {
+		f, err := NewFile(dir) // one per "process"
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[i] = f
+	}
+	mem := NewMemory()
+	for name, stores := range map[string][]Creator{"memory": {mem, mem, mem, mem}, "file": files} {
+		var won atomic.Int32
+		var wg sync.WaitGroup
+		for i := range 16 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				switch err := stores[i%len(stores)].Create(context.Background(), "claim", []byte{byte(i)}); {
+				case err == nil:
+					won.Add(1)
+				case !errors.Is(err, ErrExists):
+					t.Errorf("%s: Create() = %v, want nil or %v", name, err, ErrExists)
+				}
+			}()
+		}
+		wg.Wait()
+		if n := won.Load(); n != 1 {
+			t.Errorf("%s: %d Creates succeeded, want 1", name, n)
+		}
+		if keys, _ := stores[0].List(context.Background(), ""); !reflect.DeepEqual(keys, []string{"claim"}) {
+			t.Errorf("%s: List() = %v, want [claim]", name, keys)
+		}
+	}
+}
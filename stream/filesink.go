@@ -0,0 +1,122 @@
+package stream
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/arifmahmudrana/go-snippets/pubsub"
+)
+
+// FileSink is a Sink appending messages to one file, encoded with a
+// pubsub.Codec. Next to it, path.offset records the last committed
+// token and how long the file was then; anything past that length was
+// written by a batch that never committed and is cut off.
+type FileSink struct {
+	path  string
+	codec pubsub.Codec
+	f     *os.File
+
+	committed fileOffset
+}
+
+// fileOffset is the JSON form of path.offset.
+type fileOffset struct {
+	Token string `json:"token"`
+	Size  int64  `json:"size"`
+}
+
+// OpenFileSink opens or creates the file sink at path.
+func OpenFileSink(path string, codec pubsub.Codec) (*FileSink, error) {
+	s := &FileSink{path: path, codec: codec}
+	data, err := os.ReadFile(s.offsetPath())
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return nil, err
+	default:
+		if err := json.Unmarshal(data, &s.committed); err != nil {
+			return nil, err
+		}
+	}
+	if s.f, err = os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644); err != nil {
+		return nil, err
+	}
+	if err := s.BeginBatch(); err != nil {
+		s.f.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *FileSink) offsetPath() string {
+	return s.path + ".offset"
+}
+
+func (s *FileSink) Offset() (string, error) {
+	return s.committed.Token, nil
+}
+
+func (s *FileSink) BeginBatch() error {
+	if err := s.f.Truncate(s.committed.Size); err != nil {
+		return err
+	}
+	_, err := s.f.Seek(s.committed.Size, io.SeekStart)
+	return err
+}
+
+func (s *FileSink) Write(m pubsub.Message) error {
+	data, err := s.codec(m)
+	if err != nil {
+		return err
+	}
+	_, err = s.f.Write(data)
+	return err
+}
+
+// CommitWithOffset syncs the file, then replaces path.offset with a
+// rename, which is the commit point.
+func (s *FileSink) CommitWithOffset(token string) error {
+	if err := s.f.Sync(); err != nil {
+		return err
+	}
+	size, err := s.f.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	next := fileOffset{Token: token, Size: size}
+	data, err := json.Marshal(next)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // no-op after a successful rename
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), s.offsetPath()); err != nil {
+		return err
+	}
+	s.committed = next
+	return nil
+}
+
+// Close closes the file. Writes not yet committed are discarded the
+// next time the sink is opened.
+func (s *FileSink) Close() error {
+	return s.f.Close()
+}
@@ -0,0 +1,278 @@
+package stream
+
+import (
+	"container/heap"
+	"sync"
+	"time"
+
+	"github.com/arifmahmudrana/go-snippets/pubsub"
+)
+
+// KeyFunc returns the key a message joins on, or false to skip the
+// message.
+type KeyFunc func(m pubsub.Message) (string, bool)
+
+// Joined is a pair of messages, one from each topic, with the same key
+// and times no further apart than the window.
+type Joined struct {
+	Key         string
+	Left, Right pubsub.Message
+}
+
+// JoinStats counts what a Joiner has seen.
+type JoinStats struct {
+	Left, Right uint64 // messages taken from each topic
+	Joined      uint64 // pairs emitted
+	Late        uint64 // messages too old to join anything still buffered
+	Unmatched   uint64 // buffered messages that expired without a partner
+}
+
+// JoinOption configures a Joiner.
+type JoinOption func(*Joiner)
+
+// TimeFunc returns the time a message's event happened.
+type TimeFunc func(m pubsub.Message) time.Time
+
+// BrokerTime is the default TimeFunc: the time the broker accepted the
+// message.
+func BrokerTime(m pubsub.Message) time.Time {
+	return m.Time
+}
+
+// EventTime makes the joiner window messages by the time fn gives, e.g.
+// a timestamp carried in the payload, instead of when the broker
+// accepted them. Messages may then arrive out of order, and some late.
+func EventTime(fn TimeFunc) JoinOption {
+	return func(j *Joiner) {
+		j.timeOf = fn
+	}
+}
+
+// OnLate calls fn for each late message: one older than the window
+// allows, given the newest message seen so far, so that its partners may
+// already have been dropped. By default late messages are only counted.
+// fn runs on the joiner's goroutine and must not block.
+func OnLate(fn func(pubsub.Message)) JoinOption {
+	return func(j *Joiner) {
+		j.onLate = fn
+	}
+}
+
+// OnUnmatched calls fn for each message that leaves the window without
+// having been joined, e.g. an order that was never paid. fn runs on the
+// joiner's goroutine and must not block.
+func OnUnmatched(fn func(pubsub.Message)) JoinOption {
+	return func(j *Joiner) {
+		j.onUnmatched = fn
+	}
+}
+
+// Joiner co-groups two topics by key within a time window. See Join.
+type Joiner struct {
+	// C receives the joined pairs. It is closed after Stop.
+	C <-chan Joined
+
+	broker      *pubsub.Broker
+	left, right *pubsub.Subscriber
+	key         KeyFunc
+	window      time.Duration
+	timeOf      TimeFunc
+	onLate      func(pubsub.Message)
+	onUnmatched func(pubsub.Message)
+
+	out  chan Joined
+	stop chan struct{}
+	done chan struct{}
+	once sync.Once
+
+	// owned by run
+	sides [2]side
+	now   time.Time // newest message time seen
+
+	mu    sync.Mutex
+	stats JoinStats
+}
+
+// side buffers the messages of one topic that may still be joined.
+type side struct {
+	byKey map[string][]*entry
+	order entryHeap // oldest first, for expiry
+}
+
+type entry struct {
+	key     string
+	m       pubsub.Message
+	t       time.Time
+	matched bool
+}
+
+// Join subscribes to the left and right topics and emits on C every pair
+// of messages, one from each, whose keys are equal and whose times (see
+// EventTime) are at most window apart, e.g. each order with the
+// payments made for it within a minute. Every message is buffered for
+// window after the newest time seen, so it can meet partners that
+// arrive later. C must be read promptly: while it is full, the
+// subscriptions fill up and the broker drops messages.
+func Join(b *pubsub.Broker, left, right string, key KeyFunc, window time.Duration, opts ...JoinOption) *Joiner {
+	j := &Joiner{
+		broker: b,
+		key:    key,
+		window: window,
+		timeOf: BrokerTime,
+		out:    make(chan Joined, 64),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	j.C = j.out
+	for _, opt := range opts {
+		opt(j)
+	}
+	for i := range j.sides {
+		j.sides[i].byKey = make(map[string][]*entry)
+	}
+	j.left = b.Subscribe(left)
+	j.right = b.Subscribe(right)
+	go j.run()
+	return j
+}
+
+func (j *Joiner) run() {
+	defer close(j.done)
+	defer close(j.out)
+	lc, rc := j.left.C, j.right.C
+	for lc != nil || rc != nil {
+		select {
+		case <-j.stop:
+			return
+		case m, ok := <-lc:
+			if !ok {
+				lc = nil
+				continue
+			}
+			j.count(func(s *JoinStats) { s.Left++ })
+			if !j.add(0, m) {
+				return
+			}
+		case m, ok := <-rc:
+			if !ok {
+				rc = nil
+				continue
+			}
+			j.count(func(s *JoinStats) { s.Right++ })
+			if !j.add(1, m) {
+				return
+			}
+		}
+	}
+}
+
+func (j *Joiner) count(fn func(*JoinStats)) {
+	j.mu.Lock()
+	fn(&j.stats)
+	j.mu.Unlock()
+}
+
+// add joins m, from side i, with the other side's buffered messages and
+// buffers it. It reports false if the joiner was stopped meanwhile.
+func (j *Joiner) add(i int, m pubsub.Message) bool {
+	k, ok := j.key(m)
+	if !ok {
+		return true
+	}
+	t := j.timeOf(m)
+	if t.After(j.now) {
+		j.now = t
+		j.expire()
+	}
+	if t.Before(j.now.Add(-j.window)) {
+		j.count(func(s *JoinStats) { s.Late++ })
+		if j.onLate != nil {
+			j.onLate(m)
+		}
+		return true
+	}
+
+	e := &entry{key: k, m: m, t: t}
+	for _, other := range j.sides[1-i].byKey[k] {
+		if d := t.Sub(other.t); d > j.window || d < -j.window {
+			continue
+		}
+		pair := Joined{Key: k, Left: m, Right: other.m}
+		if i == 1 {
+			pair.Left, pair.Right = other.m, m
+		}
+		e.matched, other.matched = true, true
+		select {
+		case j.out <- pair:
+			j.count(func(s *JoinStats) { s.Joined++ })
+		case <-j.stop:
+			return false
+		}
+	}
+	s := &j.sides[i]
+	s.byKey[k] = append(s.byKey[k], e)
+	heap.Push(&s.order, e)
+	return true
+}
+
+// expire drops buffered messages that nothing arriving from now on can
+// join.
+func (j *Joiner) expire() {
+	cutoff := j.now.Add(-j.window)
+	for i := range j.sides {
+		s := &j.sides[i]
+		for s.order.Len() > 0 && s.order[0].t.Before(cutoff) {
+			e := heap.Pop(&s.order).(*entry)
+			list := s.byKey[e.key]
+			for n, x := range list {
+				if x == e {
+					list = append(list[:n], list[n+1:]...)
+					break
+				}
+			}
+			if len(list) == 0 {
+				delete(s.byKey, e.key)
+			} else {
+				s.byKey[e.key] = list
+			}
+			if !e.matched {
+				j.count(func(s *JoinStats) { s.Unmatched++ })
+				if j.onUnmatched != nil {
+					j.onUnmatched(e.m)
+				}
+			}
+		}
+	}
+}
+
+// Stats returns the joiner's counters.
+func (j *Joiner) Stats() JoinStats {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.stats
+}
+
+// Stop unsubscribes from both topics and closes C. Messages still
+// buffered are discarded.
+func (j *Joiner) Stop() {
+	j.once.Do(func() {
+		close(j.stop)
+		<-j.done
+		j.broker.Unsubscribe(j.left.Topic(), j.left)
+		j.broker.Unsubscribe(j.right.Topic(), j.right)
+	})
+}
+
+// entryHeap orders entries by message time.
+type entryHeap []*entry
+
+func (h entryHeap) Len() int           { return len(h) }
+func (h entryHeap) Less(i, k int) bool { return h[i].t.Before(h[k].t) }
+func (h entryHeap) Swap(i, k int)      { h[i], h[k] = h[k], h[i] }
+func (h *entryHeap) Push(x any)        { *h = append(*h, x.(*entry)) }
+func (h *entryHeap) Pop() any {
+	old := *h
+	e := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return e
+}
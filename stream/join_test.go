@@ -0,0 +1,139 @@
+package stream
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/arifmahmudrana/go-snippets/pubsub"
+)
+
+// event is a payload carrying its own key and event time.
+type event struct {
+	Key string
+	At  time.Duration // since epoch
+}
+
+var epoch = time.Unix(1_700_000_000, 0)
+
+func eventKey(m pubsub.Message) (string, bool) {
+	e, ok := m.Payload.(event)
+	return e.Key, ok
+}
+
+func eventTime(m pubsub.Message) time.Time {
+	return epoch.Add(m.Payload.(event).At)
+}
+
+// eventually polls cond until it holds or the deadline passes.
+func eventually(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// TestJoin tests pairing, lateness and expiry with out-of-order event
+// times
+func TestJoin(t *testing.T) {
+	type send struct {
+		topic string
+		event
+	}
+	tests := []struct {
+		name          string
+		sends         []send
+		wantJoined    []string
+		wantLate      []string
+		wantUnmatched []string
+	}{
+		{"pairs within window", []send{
+			{"orders", event{"a", 0}},
+			{"payments", event{"a", 5 * time.Second}},
+			{"payments", event{"b", 6 * time.Second}},
+			{"orders", event{"b", 3 * time.Second}},
+		}, []string{"a 0s/5s", "b 3s/6s"}, nil, nil},
+		{"one order, two payments", []send{
+			{"orders", event{"a", 0}},
+			{"payments", event{"a", time.Second}},
+			{"payments", event{"a", 2 * time.Second}},
+		}, []string{"a 0s/1s", "a 0s/2s"}, nil, nil},
+		{"too far apart", []send{
+			{"orders", event{"a", 0}},
+			{"payments", event{"a", 11 * time.Second}},
+			{"orders", event{"c", 30 * time.Second}},
+		}, nil, nil, []string{"a", "a"}},
+		{"late", []send{
+			{"orders", event{"a", 0}},
+			{"orders", event{"x", 30 * time.Second}},
+			{"payments", event{"a", 5 * time.Second}},
+		}, nil, []string{"a"}, []string{"a"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := pubsub.NewBroker()
+			defer b.Stop()
+
+			var late, unmatched []string
+			j := Join(b, "orders", "payments", eventKey, 10*time.Second,
+				EventTime(eventTime),
+				OnLate(func(m pubsub.Message) { late = append(late, m.Payload.(event).Key) }),
+				OnUnmatched(func(m pubsub.Message) { unmatched = append(unmatched, m.Payload.(event).Key) }))
+
+			// one at a time, so the joiner sees them in this order
+			for i, s := range tt.sends {
+				b.Publish(s.topic, s.event)
+				eventually(t, "the joiner to read "+s.topic, func() bool {
+					st := j.Stats()
+					return st.Left+st.Right == uint64(i+1)
+				})
+			}
+			j.Stop()
+
+			var got []string
+			for p := range j.C {
+				l, r := p.Left.Payload.(event), p.Right.Payload.(event)
+				if p.Left.Topic != "orders" || p.Right.Topic != "payments" {
+					t.Errorf("pair %+v has the topics mixed up", p)
+				}
+				got = append(got, fmt.Sprintf("%s %v/%v", p.Key, l.At, r.At))
+			}
+			slices.Sort(got)
+			if fmt.Sprint(got) != fmt.Sprint(tt.wantJoined) {
+				t.Errorf("joined = %v, want %v", got, tt.wantJoined)
+			}
+			if fmt.Sprint(late) != fmt.Sprint(tt.wantLate) {
+				t.Errorf("late = %v, want %v", late, tt.wantLate)
+			}
+			if fmt.Sprint(unmatched) != fmt.Sprint(tt.wantUnmatched) {
+				t.Errorf("unmatched = %v, want %v", unmatched, tt.wantUnmatched)
+			}
+			if st := j.Stats(); st.Joined != uint64(len(tt.wantJoined)) || st.Late != uint64(len(tt.wantLate)) {
+				t.Errorf("Stats() = %+v", st)
+			}
+		})
+	}
+}
+
+// TestJoin_Stop tests that Stop unsubscribes and closes C
+func TestJoin_Stop(t *testing.T) {
+	b := pubsub.NewBroker()
+	defer b.Stop()
+
+	j := Join(b, "orders", "payments", eventKey, time.Second)
+	j.Stop()
+	j.Stop()
+	if _, ok := <-j.C; ok {
+		t.Error("C is still open after Stop")
+	}
+	for _, topic := range []string{"orders", "payments"} {
+		if st, err := b.TopicStats(topic); err == nil && st.Subscribers != 0 {
+			t.Errorf("%s has %d subscribers after Stop", topic, st.Subscribers)
+		}
+	}
+}
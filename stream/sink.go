@@ -0,0 +1,126 @@
+package stream
+
+import (
+	"context"
+	"time"
+
+	"github.com/arifmahmudrana/go-snippets/pubsub"
+)
+
+// Sink is where Deliver writes a topic's messages. A sink makes each
+// batch take effect atomically with the offset it ends at: after a
+// crash, either the whole batch and its offset are there or neither is.
+// Resuming from the offset then writes every message exactly once.
+type Sink interface {
+	// Offset returns the resume token (pubsub.Message.Token) of the
+	// last message committed, or "" if nothing has been.
+	Offset() (token string, err error)
+	// BeginBatch starts a batch, discarding anything written since the
+	// last commit.
+	BeginBatch() error
+	// Write adds m to the batch.
+	Write(m pubsub.Message) error
+	// CommitWithOffset makes the batch's writes and token, the offset
+	// of its last message, durable together.
+	CommitWithOffset(token string) error
+}
+
+// DeliverOption configures Deliver.
+type DeliverOption func(*deliverConfig)
+
+type deliverConfig struct {
+	size     int
+	interval time.Duration
+}
+
+// BatchSize commits after at most n messages (default 100).
+func BatchSize(n int) DeliverOption {
+	return func(c *deliverConfig) {
+		c.size = max(n, 1)
+	}
+}
+
+// BatchInterval commits a batch that is not full at most d after its
+// first message (default 1s).
+func BatchInterval(d time.Duration) DeliverOption {
+	return func(c *deliverConfig) {
+		c.interval = d
+	}
+}
+
+// Deliver copies topic to sink, in batches, until ctx is done or
+// writing fails. It resumes from the sink's offset, so it needs a broker
+// with retention (see pubsub.Broker.Resume); after a crash, calling it
+// again with the same sink carries on where the last commit left off.
+// On ctx being done it commits the batch in progress and returns
+// ctx.Err().
+func Deliver(ctx context.Context, b *pubsub.Broker, topic string, sink Sink, opts ...DeliverOption) error {
+	cfg := deliverConfig{size: 100, interval: time.Second}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	token, err := sink.Offset()
+	if err != nil {
+		return err
+	}
+	c, cancel, err := b.Resume(topic, token)
+	if err != nil {
+		return err
+	}
+	defer cancel()
+
+	var (
+		n     int    // messages in the batch
+		last  string // token of the batch's last message
+		timer *time.Timer
+		due   <-chan time.Time
+	)
+	commit := func() error {
+		if timer != nil {
+			timer.Stop()
+			timer, due = nil, nil
+		}
+		if n == 0 {
+			return nil
+		}
+		n = 0
+		return sink.CommitWithOffset(last)
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			if err := commit(); err != nil {
+				return err
+			}
+			return ctx.Err()
+		case <-due:
+			if err := commit(); err != nil {
+				return err
+			}
+		case m, ok := <-c:
+			if !ok {
+				if err := commit(); err != nil {
+					return err
+				}
+				return pubsub.ErrBrokerClosed
+			}
+			if n == 0 {
+				if err := sink.BeginBatch(); err != nil {
+					return err
+				}
+				timer = time.NewTimer(cfg.interval)
+				due = timer.C
+			}
+			if err := sink.Write(m); err != nil {
+				return err
+			}
+			n++
+			last = m.Token()
+			if n >= cfg.size {
+				if err := commit(); err != nil {
+					return err
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package stream
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/arifmahmudrana/go-snippets/pubsub"
+	"github.com/arifmahmudrana/go-snippets/store"
+)
+
+var errCrash = errors.New("crash")
+
+// crashingSink fails the commit of the batch ending at token, after its
+// messages were written.
+type crashingSink struct {
+	*FileSink
+	token string
+}
+
+func (s crashingSink) CommitWithOffset(token string) error {
+	if token == s.token {
+		return errCrash
+	}
+	return s.FileSink.CommitWithOffset(token)
+}
+
+// TestDeliver_Crash tests that a batch lost in a crash is written again,
+// and nothing else is, after a restart
+func TestDeliver_Crash(t *testing.T) {
+	st := store.NewMemory()
+	path := filepath.Join(t.TempDir(), "out.jsonl")
+
+	// first run: the batch 3-4 is written but never committed
+	b := pubsub.NewBroker(pubsub.WithRetention(pubsub.NewStoreRetention(st)))
+	for i := 1; i <= 6; i++ {
+		b.Publish("t", i)
+	}
+	sink, err := OpenFileSink(path, pubsub.JSONLines)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := Deliver(ctx, b, "t", crashingSink{sink, "4"}, BatchSize(2)); !errors.Is(err, errCrash) {
+		t.Fatalf("Deliver() = %v, want %v", err, errCrash)
+	}
+	sink.Close()
+	b.Stop()
+
+	// restart on the same store
+	b = pubsub.NewBroker(pubsub.WithRetention(pubsub.NewStoreRetention(st)))
+	defer b.Stop()
+	b.Publish("t", 7)
+	if sink, err = OpenFileSink(path, pubsub.JSONLines); err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+	if got, _ := sink.Offset(); got != "2" {
+		t.Errorf("Offset() after restart = %q, want 2", got)
+	}
+
+	ctx, stop := context.WithCancel(ctx)
+	done := make(chan error)
+	go func() { done <- Deliver(ctx, b, "t", sink, BatchSize(2), BatchInterval(10*time.Millisecond)) }()
+	eventually(t, "offset 7", func() bool {
+		data, _ := os.ReadFile(path + ".offset")
+		return bytes.Contains(data, []byte(`"token":"7"`))
+	})
+	stop()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("Deliver() = %v, want %v", err, context.Canceled)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var seqs []uint64
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for sc.Scan() {
+		var line struct{ Seq uint64 }
+		if err := json.Unmarshal(sc.Bytes(), &line); err != nil {
+			t.Fatalf("bad line %q: %v", sc.Text(), err)
+		}
+		seqs = append(seqs, line.Seq)
+	}
+	if got, want := fmt.Sprint(seqs), "[1 2 3 4 5 6 7]"; got != want {
+		t.Errorf("sink holds seqs %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,287 @@
+package stream
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/arifmahmudrana/go-snippets/pubsub"
+)
+
+// Window is the aggregate of one key's messages in one window.
+type Window[A any] struct {
+	Key        string
+	Start, End time.Time
+	Value      A
+	Count      int // messages folded into Value
+
+	// Update is set when a late message changed a window that had
+	// already been emitted; this Window replaces the earlier one.
+	Update bool
+}
+
+// WindowStats counts what an Aggregator has seen.
+type WindowStats struct {
+	In      uint64 // messages taken from the topic
+	Emitted uint64 // windows emitted when the watermark passed them
+	Updates uint64 // windows emitted again because of late messages
+	Late    uint64 // messages too late for any open window
+}
+
+// WindowOption configures an Aggregator.
+type WindowOption func(*windowConfig)
+
+type windowConfig struct {
+	timeOf   TimeFunc
+	delay    time.Duration
+	lateness time.Duration
+	dead     string
+}
+
+// WindowTime makes the aggregator window messages by the time fn gives,
+// e.g. a timestamp carried in the payload, instead of when the broker
+// accepted them.
+func WindowTime(fn TimeFunc) WindowOption {
+	return func(c *windowConfig) {
+		c.timeOf = fn
+	}
+}
+
+// Watermark sets how far out of order messages are expected to arrive.
+// The watermark, the time up to which the aggregator assumes it has
+// seen everything, trails the newest event time by d; a window is
+// emitted once the watermark passes its end. A larger d emits later but
+// needs fewer updates. The default is 0.
+func Watermark(d time.Duration) WindowOption {
+	return func(c *windowConfig) {
+		c.delay = max(d, 0)
+	}
+}
+
+// AllowedLateness keeps windows open for d after the watermark passes
+// them. A message arriving in that time is still folded in and its
+// window emitted again as an Update; after it the window is dropped and
+// its messages are late. The default is 0.
+func AllowedLateness(d time.Duration) WindowOption {
+	return func(c *windowConfig) {
+		c.lateness = max(d, 0)
+	}
+}
+
+// DeadLetter publishes late messages to topic, each as the original
+// pubsub.Message, so they can be inspected or reprocessed. By default
+// late messages are only counted.
+func DeadLetter(topic string) WindowOption {
+	return func(c *windowConfig) {
+		c.dead = topic
+	}
+}
+
+// Aggregator folds a topic's messages into tumbling event-time windows
+// per key. See Tumbling.
+type Aggregator[A any] struct {
+	// C receives the windows as they are emitted. It is closed after
+	// Stop.
+	C <-chan Window[A]
+
+	broker *pubsub.Broker
+	sub    *pubsub.Subscriber
+	key    KeyFunc
+	size   time.Duration
+	fold   func(acc A, m pubsub.Message) A
+	cfg    windowConfig
+
+	out  chan Window[A]
+	stop chan struct{}
+	done chan struct{}
+	once sync.Once
+
+	// owned by run
+	open    map[windowID]*Window[A]
+	emitted map[windowID]bool
+	newest  time.Time
+
+	mu        sync.Mutex
+	watermark time.Time
+	stats     WindowStats
+}
+
+type windowID struct {
+	key   string
+	start time.Time
+}
+
+// Tumbling subscribes to topic and folds its messages, grouped by key,
+// into back-to-back windows of the given size, starting from the zero
+// value of A. Each window is emitted on C when the watermark passes its
+// end, so the result is the same however the messages were reordered on
+// the way, as long as none is later than Watermark and AllowedLateness
+// allow. C must be read promptly: while it is full, the subscription
+// fills up and the broker drops messages.
+func Tumbling[A any](b *pubsub.Broker, topic string, key KeyFunc, size time.Duration, fold func(acc A, m pubsub.Message) A, opts ...WindowOption) *Aggregator[A] {
+	a := &Aggregator[A]{
+		broker:  b,
+		key:     key,
+		size:    size,
+		fold:    fold,
+		cfg:     windowConfig{timeOf: BrokerTime},
+		out:     make(chan Window[A], 64),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+		open:    make(map[windowID]*Window[A]),
+		emitted: make(map[windowID]bool),
+	}
+	a.C = a.out
+	for _, opt := range opts {
+		opt(&a.cfg)
+	}
+	a.sub = b.Subscribe(topic)
+	go a.run()
+	return a
+}
+
+func (a *Aggregator[A]) run() {
+	defer close(a.done)
+	defer close(a.out)
+	for {
+		select {
+		case <-a.stop:
+			return
+		case m, ok := <-a.sub.C:
+			if !ok {
+				return
+			}
+			a.count(func(s *WindowStats) { s.In++ })
+			if !a.add(m) {
+				return
+			}
+		}
+	}
+}
+
+func (a *Aggregator[A]) count(fn func(*WindowStats)) {
+	a.mu.Lock()
+	fn(&a.stats)
+	a.mu.Unlock()
+}
+
+// add folds m into its window, then advances the watermark. It reports
+// false if the aggregator was stopped meanwhile.
+func (a *Aggregator[A]) add(m pubsub.Message) bool {
+	k, ok := a.key(m)
+	if !ok {
+		return true
+	}
+	t := a.cfg.timeOf(m)
+	id := windowID{k, t.Truncate(a.size)}
+	end := id.start.Add(a.size)
+	wm := a.Watermark()
+
+	w, ok := a.open[id]
+	if !ok {
+		if !end.Add(a.cfg.lateness).After(wm) {
+			a.count(func(s *WindowStats) { s.Late++ })
+			if a.cfg.dead != "" {
+				a.broker.Publish(a.cfg.dead, m)
+			}
+			return true
+		}
+		w = &Window[A]{Key: k, Start: id.start, End: end}
+		a.open[id] = w
+	}
+	w.Value = a.fold(w.Value, m)
+	w.Count++
+	switch {
+	case a.emitted[id]:
+		a.count(func(s *WindowStats) { s.Updates++ })
+		up := *w
+		up.Update = true
+		if !a.send(up) {
+			return false
+		}
+	case !end.After(wm):
+		// a late window's first message: the watermark will not
+		// come back for it
+		a.emitted[id] = true
+		a.count(func(s *WindowStats) { s.Emitted++ })
+		if !a.send(*w) {
+			return false
+		}
+	}
+
+	if t.After(a.newest) {
+		a.newest = t
+	}
+	if next := a.newest.Add(-a.cfg.delay); next.After(wm) {
+		a.mu.Lock()
+		a.watermark = next
+		a.mu.Unlock()
+		return a.advance(next)
+	}
+	return true
+}
+
+// advance emits the windows the watermark wm has passed, oldest first,
+// and drops those past their allowed lateness.
+func (a *Aggregator[A]) advance(wm time.Time) bool {
+	var due []windowID
+	for id, w := range a.open {
+		if !a.emitted[id] && !w.End.After(wm) {
+			due = append(due, id)
+		}
+	}
+	sort.Slice(due, func(i, k int) bool {
+		if !due[i].start.Equal(due[k].start) {
+			return due[i].start.Before(due[k].start)
+		}
+		return due[i].key < due[k].key
+	})
+	for _, id := range due {
+		a.emitted[id] = true
+		a.count(func(s *WindowStats) { s.Emitted++ })
+		if !a.send(*a.open[id]) {
+			return false
+		}
+	}
+	for id, w := range a.open {
+		if !w.End.Add(a.cfg.lateness).After(wm) {
+			delete(a.open, id)
+			delete(a.emitted, id)
+		}
+	}
+	return true
+}
+
+func (a *Aggregator[A]) send(w Window[A]) bool {
+	select {
+	case a.out <- w:
+		return true
+	case <-a.stop:
+		return false
+	}
+}
+
+// Watermark returns the aggregator's current watermark: windows ending
+// at or before it have been emitted.
+func (a *Aggregator[A]) Watermark() time.Time {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.watermark
+}
+
+// Stats returns the aggregator's counters.
+func (a *Aggregator[A]) Stats() WindowStats {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.stats
+}
+
+// Stop unsubscribes from the topic and closes C. Windows not yet
+// emitted are discarded.
+func (a *Aggregator[A]) Stop() {
+	a.once.Do(func() {
+		close(a.stop)
+		<-a.done
+		a.broker.Unsubscribe(a.sub.Topic(), a.sub)
+	})
+}
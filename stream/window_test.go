@@ -0,0 +1,77 @@
+package stream
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/arifmahmudrana/go-snippets/pubsub"
+)
+
+// sumSeconds adds up the events' times in seconds.
+func sumSeconds(acc int, m pubsub.Message) int {
+	return acc + int(m.Payload.(event).At/time.Second)
+}
+
+// TestTumbling tests emitting windows by watermark, updating them within
+// the allowed lateness and dead-lettering what comes after
+func TestTumbling(t *testing.T) {
+	b := pubsub.NewBroker()
+	defer b.Stop()
+	dead := b.Subscribe("late")
+
+	a := Tumbling(b, "events", eventKey, 10*time.Second, sumSeconds,
+		WindowTime(eventTime), Watermark(2*time.Second), AllowedLateness(5*time.Second), DeadLetter("late"))
+
+	sends := []event{
+		{"a", 1 * time.Second},
+		{"a", 4 * time.Second},
+		{"a", 12 * time.Second}, // watermark 10s: [0s,10s) is done
+		{"a", 8 * time.Second},  // late, but allowed: an update
+		{"b", 5 * time.Second},  // late, allowed, and a window of its own
+		{"a", 19 * time.Second}, // watermark 17s: [0s,10s) is dropped
+		{"a", 3 * time.Second},  // too late
+		{"a", 22 * time.Second}, // watermark 20s: [10s,20s) is done
+	}
+	for _, e := range sends {
+		b.Publish("events", e)
+	}
+	eventually(t, "the aggregator to read everything", func() bool {
+		return a.Stats().In == uint64(len(sends))
+	})
+	a.Stop()
+
+	want := []string{
+		"a [0s,10s) 2 5",
+		"a [0s,10s) 3 13 update",
+		"b [0s,10s) 1 5",
+		"a [10s,20s) 2 31",
+	}
+	var got []string
+	for w := range a.C {
+		s := fmt.Sprintf("%s [%v,%v) %d %d", w.Key, w.Start.Sub(epoch), w.End.Sub(epoch), w.Count, w.Value)
+		if w.Update {
+			s += " update"
+		}
+		got = append(got, s)
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("windows =\n%q\nwant\n%q", got, want)
+	}
+
+	select {
+	case m := <-dead.C:
+		if late, ok := m.Payload.(pubsub.Message); !ok || late.Payload != (event{"a", 3 * time.Second}) {
+			t.Errorf("dead letter = %+v, want the event at 3s", m.Payload)
+		}
+	case <-time.After(time.Second):
+		t.Error("no dead letter")
+	}
+	st := a.Stats()
+	if st.Emitted != 3 || st.Updates != 1 || st.Late != 1 {
+		t.Errorf("Stats() = %+v, want 3 emitted, 1 update, 1 late", st)
+	}
+	if got, want := a.Watermark(), epoch.Add(20*time.Second); !got.Equal(want) {
+		t.Errorf("Watermark() = %v, want %v", got, want)
+	}
+}
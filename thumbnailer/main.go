@@ -0,0 +1,50 @@
+// thumbnailer/main.go
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"runtime"
+	"time"
+)
+
+func main() {
+	src := flag.String("src", ".", "directory to scan for images")
+	out := flag.String("out", "thumbs", "output directory")
+	size := flag.Int("size", 128, "thumbnail bounding box in pixels")
+	workers := flag.Int("workers", max(runtime.GOMAXPROCS(0), 1), "concurrent images")
+	memMB := flag.Int64("mem", 64, "max MiB of decoded pixels held at once")
+	flag.Parse()
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	paths, err := findImages(*src)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "scan:", err)
+		os.Exit(1)
+	}
+	if err := os.MkdirAll(*out, 0o755); err != nil {
+		fmt.Fprintln(os.Stderr, "mkdir:", err)
+		os.Exit(1)
+	}
+
+	opts := Options{Workers: *workers, MemLimit: *memMB << 20, Size: *size, OutDir: *out}
+
+	start := time.Now()
+	failed := 0
+	peak := run(ctx, *src, paths, opts, func(r Result) {
+		if r.Err != nil {
+			failed++
+			fmt.Printf("[ERR] %s: %v\n", r.Src, r.Err)
+			return
+		}
+		fmt.Printf("[OK]  %s -> %s (%dx%d, %v)\n", r.Src, r.Dst, r.Width, r.Height, r.Duration.Round(time.Millisecond))
+	})
+
+	fmt.Printf("[MAIN] %d images, %d failed, peak decoded memory %.1f MiB of %d MiB, took %v\n",
+		len(paths), failed, float64(peak)/(1<<20), *memMB, time.Since(start).Round(time.Millisecond))
+}
@@ -0,0 +1,58 @@
+// thumbnailer/resize.go
+package main
+
+import (
+	"image"
+	"image/color"
+)
+
+// fitWithin returns the largest size with the aspect ratio of w×h that
+// fits in maxW×maxH. Images that already fit are left unchanged.
+func fitWithin(w, h, maxW, maxH int) (int, int) {
+	if w <= maxW && h <= maxH {
+		return w, h
+	}
+	if w*maxH > h*maxW {
+		return maxW, max(h*maxW/w, 1)
+	}
+	return max(w*maxH/h, 1), maxH
+}
+
+// resize scales src to fit within maxW×maxH using a box filter: each
+// destination pixel is the average of the source pixels it covers. This
+// is slower than nearest-neighbour but avoids aliasing when shrinking a
+// lot, which is the common case for thumbnails.
+func resize(src image.Image, maxW, maxH int) *image.RGBA {
+	b := src.Bounds()
+	sw, sh := b.Dx(), b.Dy()
+	dw, dh := fitWithin(sw, sh, maxW, maxH)
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+
+	for dy := range dh {
+		y0 := b.Min.Y + dy*sh/dh
+		y1 := max(b.Min.Y+(dy+1)*sh/dh, y0+1)
+		for dx := range dw {
+			x0 := b.Min.X + dx*sw/dw
+			x1 := max(b.Min.X+(dx+1)*sw/dw, x0+1)
+
+			var r, g, bl, a, n uint64
+			for y := y0; y < y1; y++ {
+				for x := x0; x < x1; x++ {
+					cr, cg, cb, ca := src.At(x, y).RGBA()
+					r += uint64(cr)
+					g += uint64(cg)
+					bl += uint64(cb)
+					a += uint64(ca)
+					n++
+				}
+			}
+			dst.SetRGBA(dx, dy, color.RGBA{
+				R: uint8(r / n >> 8),
+				G: uint8(g / n >> 8),
+				B: uint8(bl / n >> 8),
+				A: uint8(a / n >> 8),
+			})
+		}
+	}
+	return dst
+}
@@ -0,0 +1,206 @@
+// thumbnailer/thumbnailer.go
+package main
+
+import (
+	"context"
+	"image"
+	_ "image/gif" // register decoders
+	"image/jpeg"
+	_ "image/png"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/arifmahmudrana/go-snippets/semaphore"
+	"github.com/arifmahmudrana/go-snippets/workerpool"
+)
+
+// Options controls a thumbnailing run.
+type Options struct {
+	Workers  int   // concurrent decode/resize/encode tasks
+	MemLimit int64 // upper bound on decoded pixel bytes held at once
+	Size     int   // thumbnails fit within Size×Size
+	OutDir   string
+}
+
+// Result describes one processed image.
+type Result struct {
+	Index    int
+	Src, Dst string
+	Width    int
+	Height   int
+	Decoded  int64 // bytes of decoded pixels charged to the semaphore
+	Duration time.Duration
+	Err      error
+}
+
+// findImages returns the image files under root in lexical order.
+func findImages(root string) ([]string, error) {
+	var paths []string
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".jpg", ".jpeg", ".png", ".gif":
+			if !d.IsDir() {
+				paths = append(paths, path)
+			}
+		}
+		return nil
+	})
+	return paths, err
+}
+
+// thumbnailer processes images on a worker pool while a weighted
+// semaphore bounds the memory held by decoded images.
+type thumbnailer struct {
+	opts Options
+	root string
+	sem  *semaphore.Weighted
+	peak atomic.Int64
+}
+
+// run thumbnails every path and calls emit with results in input order,
+// regardless of the order in which workers finish. It returns the peak
+// number of decoded bytes held at once.
+func run(ctx context.Context, root string, paths []string, opts Options, emit func(Result)) int64 {
+	th := &thumbnailer{
+		opts: opts,
+		root: root,
+		sem:  semaphore.NewWeighted(opts.MemLimit),
+	}
+
+	// The pool itself is not cancelled: every queued task must run so that
+	// each path yields exactly one result. Tasks observe ctx instead.
+	pool := workerpool.New(context.Background(), opts.Workers)
+	results := make(chan Result, opts.Workers)
+
+	// producer: submitting blocks while workers are busy (backpressure)
+	go func() {
+		defer pool.Close()
+		for i, path := range paths {
+			err := pool.Submit(ctx, func(context.Context) {
+				results <- th.process(ctx, i, path)
+			})
+			if err != nil {
+				// report the remaining paths as cancelled
+				for j := i; j < len(paths); j++ {
+					results <- Result{Index: j, Src: paths[j], Err: err}
+				}
+				return
+			}
+		}
+	}()
+
+	// reorder buffer: hold early finishers until their turn
+	pending := make(map[int]Result)
+	next := 0
+	for next < len(paths) {
+		r := <-results
+		pending[r.Index] = r
+		for {
+			r, ok := pending[next]
+			if !ok {
+				break
+			}
+			delete(pending, next)
+			emit(r)
+			next++
+		}
+	}
+	return th.peak.Load()
+}
+
+// process decodes, resizes and encodes one image.
+func (th *thumbnailer) process(ctx context.Context, index int, path string) Result {
+	r := Result{Index: index, Src: path}
+	start := time.Now()
+	defer func() { r.Duration = time.Since(start) }()
+
+	if err := ctx.Err(); err != nil {
+		r.Err = err
+		return r
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		r.Err = err
+		return r
+	}
+	defer f.Close()
+
+	// The header tells us how big the decoded image will be before we
+	// pay for decoding it. Oversized images are capped at the limit so
+	// they still run, just alone.
+	cfg, _, err := image.DecodeConfig(f)
+	if err != nil {
+		r.Err = err
+		return r
+	}
+	r.Decoded = min(int64(cfg.Width)*int64(cfg.Height)*4, th.sem.Size())
+
+	if err := th.sem.Acquire(ctx, r.Decoded); err != nil {
+		r.Err = err
+		return r
+	}
+	th.notePeak()
+
+	thumb, err := func() (*image.RGBA, error) {
+		defer th.sem.Release(r.Decoded)
+		if _, err := f.Seek(0, 0); err != nil {
+			return nil, err
+		}
+		img, _, err := image.Decode(f)
+		if err != nil {
+			return nil, err
+		}
+		return resize(img, th.opts.Size, th.opts.Size), nil
+	}()
+	if err != nil {
+		r.Err = err
+		return r
+	}
+	r.Width, r.Height = thumb.Bounds().Dx(), thumb.Bounds().Dy()
+
+	r.Dst = th.outputPath(path)
+	out, err := os.Create(r.Dst)
+	if err != nil {
+		r.Err = err
+		return r
+	}
+	if err := jpeg.Encode(out, thumb, &jpeg.Options{Quality: 85}); err != nil {
+		out.Close()
+		r.Err = err
+		return r
+	}
+	r.Err = out.Close()
+	return r
+}
+
+// notePeak records the current semaphore usage if it is a new maximum.
+func (th *thumbnailer) notePeak() {
+	cur := th.sem.InUse()
+	for {
+		p := th.peak.Load()
+		if cur <= p || th.peak.CompareAndSwap(p, cur) {
+			return
+		}
+	}
+}
+
+// outputPath flattens the source path relative to root into a file name
+// inside OutDir, so images with the same name in different folders do
+// not collide.
+func (th *thumbnailer) outputPath(src string) string {
+	rel, err := filepath.Rel(th.root, src)
+	if err != nil {
+		rel = filepath.Base(src)
+	}
+	name := strings.TrimSuffix(rel, filepath.Ext(rel))
+	name = strings.ReplaceAll(name, string(filepath.Separator), "_")
+	return filepath.Join(th.opts.OutDir, name+".jpg")
+}
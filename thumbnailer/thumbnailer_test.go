@@ -0,0 +1,133 @@
+// thumbnailer/thumbnailer_test.go
+package main
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writePNG creates a w×h solid-colour PNG at path.
+func writePNG(t *testing.T, path string, w, h int) {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := range h {
+		for x := range w {
+			img.Set(x, y, color.RGBA{R: 200, G: 100, B: 50, A: 255})
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := png.Encode(f, img); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestFitWithin tests aspect-ratio preserving sizing
+func TestFitWithin(t *testing.T) {
+	tests := []struct {
+		w, h, maxW, maxH, wantW, wantH int
+	}{
+		{w: 400, h: 200, maxW: 100, maxH: 100, wantW: 100, wantH: 50},
+		{w: 200, h: 400, maxW: 100, maxH: 100, wantW: 50, wantH: 100},
+		{w: 50, h: 40, maxW: 100, maxH: 100, wantW: 50, wantH: 40},
+		{w: 1000, h: 1, maxW: 10, maxH: 10, wantW: 10, wantH: 1},
+	}
+	for _, tt := range tests {
+		gotW, gotH := fitWithin(tt.w, tt.h, tt.maxW, tt.maxH)
+		if gotW != tt.wantW || gotH != tt.wantH {
+			t.Errorf("fitWithin(%d, %d, %d, %d) = %d, %d, want %d, %d",
+				tt.w, tt.h, tt.maxW, tt.maxH, gotW, gotH, tt.wantW, tt.wantH)
+		}
+	}
+}
+
+// TestRun tests ordering, output files and the memory bound
+func TestRun(t *testing.T) {
+	src, out := t.TempDir(), t.TempDir()
+	writePNG(t, filepath.Join(src, "a.png"), 300, 200)
+	writePNG(t, filepath.Join(src, "b.png"), 64, 64)
+	writePNG(t, filepath.Join(src, "nested", "a.png"), 100, 400)
+	writePNG(t, filepath.Join(src, "z.png"), 250, 250)
+	os.WriteFile(filepath.Join(src, "broken.png"), []byte("not a png"), 0o644)
+	os.WriteFile(filepath.Join(src, "notes.txt"), []byte("ignored"), 0o644)
+
+	paths, err := findImages(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) != 5 {
+		t.Fatalf("found %d images, want 5", len(paths))
+	}
+
+	// budget fits one 300×200 image (240 000 bytes) but not two
+	const limit = 300_000
+	var got []Result
+	peak := run(context.Background(), src, paths, Options{Workers: 4, MemLimit: limit, Size: 32, OutDir: out},
+		func(r Result) { got = append(got, r) })
+
+	if peak > limit {
+		t.Errorf("peak decoded bytes = %d, want <= %d", peak, limit)
+	}
+
+	for i, r := range got {
+		if r.Index != i || r.Src != paths[i] {
+			t.Errorf("result %d = %s (index %d), want %s", i, r.Src, r.Index, paths[i])
+		}
+		if filepath.Base(r.Src) == "broken.png" {
+			if r.Err == nil {
+				t.Error("broken.png: expected an error")
+			}
+			continue
+		}
+		if r.Err != nil {
+			t.Errorf("%s: %v", r.Src, r.Err)
+			continue
+		}
+		if r.Width > 32 || r.Height > 32 {
+			t.Errorf("%s: thumbnail %dx%d exceeds 32x32", r.Src, r.Width, r.Height)
+		}
+		if _, err := os.Stat(r.Dst); err != nil {
+			t.Errorf("%s: output missing: %v", r.Src, err)
+		}
+	}
+
+	// same base name in different folders must not collide
+	if _, err := os.Stat(filepath.Join(out, "nested_a.jpg")); err != nil {
+		t.Errorf("nested output missing: %v", err)
+	}
+}
+
+// TestRun_Cancelled tests that every path still yields a result when cancelled
+func TestRun_Cancelled(t *testing.T) {
+	src := t.TempDir()
+	for _, name := range []string{"a.png", "b.png", "c.png", "d.png"} {
+		writePNG(t, filepath.Join(src, name), 10, 10)
+	}
+	paths, _ := findImages(src)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	n := 0
+	run(ctx, src, paths, Options{Workers: 1, MemLimit: 1 << 20, Size: 8, OutDir: t.TempDir()}, func(r Result) {
+		n++
+		if r.Err == nil {
+			t.Errorf("%s: expected cancellation error", r.Src)
+		}
+	})
+	if n != len(paths) {
+		t.Errorf("got %d results, want %d", n, len(paths))
+	}
+}
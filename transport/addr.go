@@ -0,0 +1,52 @@
+package transport
+
+import (
+	"errors"
+	"io/fs"
+	"net"
+	"os"
+	"strings"
+)
+
+// ParseAddr splits an address given on a command line into a network and
+// an address for Listen and Dial: "unix:/run/pubsub.sock" is a Unix
+// domain socket, "tcp:host:port" or plain "host:port" is TCP.
+//
+// Unix sockets suit sidecars on the same host: no port is opened and
+// the kernel skips the TCP stack. Go supports them on Windows 10 and
+// later too, which stands in for named pipes there.
+func ParseAddr(addr string) (network, address string) {
+	if network, address, ok := strings.Cut(addr, ":"); ok && (network == "unix" || network == "tcp") {
+		return network, address
+	}
+	return "tcp", addr
+}
+
+// listen is net.Listen, plus socket file housekeeping for "unix": a
+// stale socket left by a crashed daemon is removed first, and the new
+// one is only accessible to its owner.
+func listen(network, addr string) (net.Listener, error) {
+	if network != "unix" {
+		return net.Listen(network, addr)
+	}
+
+	if _, err := os.Stat(addr); err == nil {
+		if c, err := net.Dial("unix", addr); err == nil {
+			c.Close()
+			return nil, &net.OpError{Op: "listen", Net: network, Err: errors.New("socket in use")}
+		}
+		os.Remove(addr) // nobody is listening: stale
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	ln, err := net.Listen(network, addr)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(addr, 0o600); err != nil {
+		ln.Close()
+		return nil, err
+	}
+	return ln, nil // closing the listener removes the socket file
+}
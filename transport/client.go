@@ -0,0 +1,208 @@
+package transport
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+
+	"github.com/arifmahmudrana/go-snippets/pubsub"
+)
+
+// ErrClosed is returned by operations on a closed client.
+var ErrClosed = errors.New("transport: client closed")
+
+// Client is a connection to a remote broker. Received payloads are
+// json.RawMessage. It is safe for concurrent use.
+type Client struct {
+	conn net.Conn
+
+	wmu sync.Mutex
+	enc *json.Encoder
+
+	mu     sync.Mutex
+	nextID uint64
+	subs   map[uint64]*Subscription
+	err    error // why the connection ended
+	done   chan struct{}
+}
+
+// Dial connects to a server, e.g. Dial("tcp", "localhost:7070").
+func Dial(network, addr string) (*Client, error) {
+	conn, err := net.Dial(network, addr)
+	if err != nil {
+		return nil, err
+	}
+	c := &Client{
+		conn: conn,
+		enc:  json.NewEncoder(conn),
+		subs: make(map[uint64]*Subscription),
+		done: make(chan struct{}),
+	}
+	go c.read()
+	return c, nil
+}
+
+// Subscription is one remote subscription. C is closed when the server
+// ends the subscription, after Close, or when the connection is lost.
+type Subscription struct {
+	// C delivers the topic's messages.
+	C <-chan pubsub.Message
+
+	id    uint64
+	c     *Client
+	ch    chan pubsub.Message
+	ready chan error // the server's verdict: nil once live
+	quit  chan struct{}
+	once  sync.Once
+}
+
+// Close ends the subscription. C is closed once the server confirms.
+func (s *Subscription) Close() error {
+	s.once.Do(func() { close(s.quit) })
+	return s.c.send(frame{Op: opUnsub, ID: s.id})
+}
+
+// SubscribeOption configures a remote subscription.
+type SubscribeOption func(*frame)
+
+// Since replays the retained messages published after token (a
+// Message.Token, or "0" for everything retained) before live ones. The
+// server's broker must have retention.
+func Since(token string) SubscribeOption {
+	return func(f *frame) {
+		f.Since = &token
+	}
+}
+
+// Publish sends payload, which must be JSON-encodable, to topic. It does
+// not wait for the server.
+func (c *Client) Publish(topic string, payload any) error {
+	raw, err := encodePayload(payload)
+	if err != nil {
+		return err
+	}
+	return c.send(frame{Op: opPub, Topic: topic, Payload: raw})
+}
+
+// Subscribe subscribes to topic on the server. Messages are read on one
+// goroutine per client, so a subscription whose C is not drained holds
+// up the others.
+func (c *Client) Subscribe(topic string, opts ...SubscribeOption) (*Subscription, error) {
+	c.mu.Lock()
+	if c.subs == nil {
+		c.mu.Unlock()
+		return nil, ErrClosed
+	}
+	c.nextID++
+	ch := make(chan pubsub.Message, 64)
+	s := &Subscription{
+		C:     ch,
+		id:    c.nextID,
+		c:     c,
+		ch:    ch,
+		ready: make(chan error, 1),
+		quit:  make(chan struct{}),
+	}
+	c.subs[s.id] = s
+	c.mu.Unlock()
+
+	f := frame{Op: opSub, ID: s.id, Topic: topic}
+	for _, opt := range opts {
+		opt(&f)
+	}
+	if err := c.send(f); err != nil {
+		return nil, err
+	}
+
+	select {
+	case err := <-s.ready:
+		if err != nil {
+			return nil, err
+		}
+		return s, nil
+	case <-c.done:
+		return nil, ErrClosed
+	}
+}
+
+// send writes one frame.
+func (c *Client) send(f frame) error {
+	select {
+	case <-c.done:
+		return ErrClosed
+	default:
+	}
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	return c.enc.Encode(f)
+}
+
+// read dispatches frames from the server until the connection ends.
+func (c *Client) read() {
+	sc := bufio.NewScanner(c.conn)
+	sc.Buffer(make([]byte, 64*1024), maxFrame)
+	for sc.Scan() {
+		var f frame
+		if err := json.Unmarshal(sc.Bytes(), &f); err != nil {
+			continue
+		}
+
+		c.mu.Lock()
+		s := c.subs[f.ID]
+		if f.Op == opEnd || f.Op == opErr {
+			delete(c.subs, f.ID)
+		}
+		c.mu.Unlock()
+		if s == nil {
+			continue
+		}
+
+		switch f.Op {
+		case opOK:
+			s.ready <- nil
+		case opMsg:
+			m := pubsub.Message{Topic: f.Topic, Payload: f.Payload, Seq: f.Seq, Clock: f.Clock}
+			if f.Time != nil {
+				m.Time = *f.Time
+			}
+			select {
+			case s.ch <- m:
+			case <-s.quit:
+			}
+		case opErr:
+			s.ready <- fmt.Errorf("transport: subscribe %d: %s", f.ID, f.Error)
+			close(s.ch)
+		case opEnd:
+			close(s.ch)
+		}
+	}
+
+	c.mu.Lock()
+	c.err = sc.Err()
+	subs := c.subs
+	c.subs = nil
+	c.mu.Unlock()
+	for _, s := range subs {
+		close(s.ch)
+	}
+	close(c.done)
+}
+
+// Err returns why the connection ended, or nil while it is open or if it
+// was closed cleanly.
+func (c *Client) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+// Close disconnects. The server releases every subscription, and their
+// channels are closed.
+func (c *Client) Close() error {
+	err := c.conn.Close()
+	<-c.done
+	return err
+}
@@ -0,0 +1,44 @@
+package transport
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// The wire protocol is one JSON frame per line, in both directions.
+//
+// Client to server:
+//
+//	{"op":"pub","topic":"t","payload":...}
+//	{"op":"sub","id":1,"topic":"t"}              live messages only
+//	{"op":"sub","id":1,"topic":"t","since":"42"} retained after seq 42, then live
+//	{"op":"unsub","id":1}
+//
+// Server to client:
+//
+//	{"op":"ok","id":1}                           subscription live
+//	{"op":"msg","id":1,"topic":"t","seq":43,"time":...,"clock":7,"payload":...}
+//	{"op":"end","id":1}                          subscription closed
+//	{"op":"err","id":1,"error":"..."}            subscription refused
+const (
+	opPub   = "pub"
+	opSub   = "sub"
+	opUnsub = "unsub"
+	opOK    = "ok"
+	opMsg   = "msg"
+	opEnd   = "end"
+	opErr   = "err"
+)
+
+// frame is one line of the wire protocol.
+type frame struct {
+	Op      string          `json:"op"`
+	ID      uint64          `json:"id,omitempty"`
+	Topic   string          `json:"topic,omitempty"`
+	Since   *string         `json:"since,omitempty"`
+	Seq     uint64          `json:"seq,omitempty"`
+	Time    *time.Time      `json:"time,omitempty"`
+	Clock   uint64          `json:"clock,omitempty"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+	Error   string          `json:"error,omitempty"`
+}
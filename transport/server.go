@@ -0,0 +1,218 @@
+package transport
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"sync"
+
+	"github.com/arifmahmudrana/go-snippets/pubsub"
+)
+
+// maxFrame is the longest line a peer may send.
+const maxFrame = 1 << 20
+
+// Server exposes a broker to remote clients. Payloads published by a
+// client reach local subscribers as json.RawMessage; local payloads are
+// JSON-encoded on their way to remote subscribers.
+type Server struct {
+	broker *pubsub.Broker
+	ln     net.Listener
+	wg     sync.WaitGroup
+
+	mu     sync.Mutex
+	conns  map[net.Conn]struct{}
+	closed bool
+}
+
+// Listen listens on network and addr ("tcp", ":7070" or "unix",
+// "/tmp/pubsub.sock") and serves b in the background until Close. A
+// Unix socket file is created owner-only and removed again by Close.
+func Listen(network, addr string, b *pubsub.Broker) (*Server, error) {
+	ln, err := listen(network, addr)
+	if err != nil {
+		return nil, err
+	}
+	return Serve(ln, b), nil
+}
+
+// Serve serves b on connections accepted from ln, in the background,
+// until Close.
+func Serve(ln net.Listener, b *pubsub.Broker) *Server {
+	s := &Server{broker: b, ln: ln, conns: make(map[net.Conn]struct{})}
+	s.wg.Add(1)
+	go s.accept()
+	return s
+}
+
+// Addr returns the address the server listens on.
+func (s *Server) Addr() net.Addr {
+	return s.ln.Addr()
+}
+
+// Close stops accepting, disconnects every client and waits for their
+// subscriptions to be released. It leaves the broker running.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	for c := range s.conns {
+		c.Close()
+	}
+	s.mu.Unlock()
+
+	err := s.ln.Close()
+	s.wg.Wait()
+	return err
+}
+
+func (s *Server) accept() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return // listener closed
+		}
+
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			conn.Close()
+			return
+		}
+		s.conns[conn] = struct{}{}
+		s.mu.Unlock()
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.handle(conn)
+
+			s.mu.Lock()
+			delete(s.conns, conn)
+			s.mu.Unlock()
+		}()
+	}
+}
+
+// session is the state of one client connection.
+type session struct {
+	s    *Server
+	conn net.Conn
+
+	wmu sync.Mutex // serialises frames written by forwarders
+	enc *json.Encoder
+
+	mu   sync.Mutex
+	subs map[uint64]func() // subscription id to cancel
+	wg   sync.WaitGroup    // forwarders
+}
+
+// handle serves one connection until the client goes away.
+func (s *Server) handle(conn net.Conn) {
+	defer conn.Close()
+	ss := &session{s: s, conn: conn, enc: json.NewEncoder(conn), subs: make(map[uint64]func())}
+
+	sc := bufio.NewScanner(conn)
+	sc.Buffer(make([]byte, 64*1024), maxFrame)
+	for sc.Scan() {
+		var f frame
+		if err := json.Unmarshal(sc.Bytes(), &f); err != nil {
+			ss.write(frame{Op: opErr, Error: "bad frame: " + err.Error()})
+			continue
+		}
+
+		switch f.Op {
+		case opPub:
+			s.broker.Publish(f.Topic, f.Payload)
+		case opSub:
+			ss.subscribe(f)
+		case opUnsub:
+			ss.unsubscribe(f.ID)
+		default:
+			ss.write(frame{Op: opErr, ID: f.ID, Error: "unknown op " + f.Op})
+		}
+	}
+
+	// client gone: release every subscription
+	ss.mu.Lock()
+	cancels := ss.subs
+	ss.subs = nil
+	ss.mu.Unlock()
+	for _, cancel := range cancels {
+		cancel()
+	}
+	ss.wg.Wait()
+}
+
+// write sends one frame. Errors surface to the reader as a broken
+// connection, so they are not reported here.
+func (ss *session) write(f frame) {
+	ss.wmu.Lock()
+	defer ss.wmu.Unlock()
+	if err := ss.enc.Encode(f); err != nil {
+		ss.conn.Close()
+	}
+}
+
+func (ss *session) subscribe(f frame) {
+	ss.mu.Lock()
+	_, dup := ss.subs[f.ID]
+	ss.mu.Unlock()
+	if dup {
+		ss.write(frame{Op: opErr, ID: f.ID, Error: "subscription id in use"})
+		return
+	}
+
+	var c <-chan pubsub.Message
+	var cancel func()
+	if f.Since != nil {
+		var err error
+		if c, cancel, err = ss.s.broker.Resume(f.Topic, *f.Since); err != nil {
+			ss.write(frame{Op: opErr, ID: f.ID, Error: err.Error()})
+			return
+		}
+	} else {
+		sub, err := ss.s.broker.TrySubscribe(f.Topic)
+		if err != nil {
+			ss.write(frame{Op: opErr, ID: f.ID, Error: err.Error()})
+			return
+		}
+		c, cancel = sub.C, func() { ss.s.broker.Unsubscribe(f.Topic, sub) }
+	}
+
+	ss.mu.Lock()
+	ss.subs[f.ID] = cancel
+	ss.mu.Unlock()
+
+	ss.write(frame{Op: opOK, ID: f.ID})
+	ss.wg.Add(1)
+	go func() {
+		defer ss.wg.Done()
+		for m := range c {
+			payload, err := encodePayload(m.Payload)
+			if err != nil {
+				continue // not representable as JSON
+			}
+			ss.write(frame{Op: opMsg, ID: f.ID, Topic: m.Topic, Seq: m.Seq, Time: &m.Time, Clock: m.Clock, Payload: payload})
+		}
+		ss.write(frame{Op: opEnd, ID: f.ID})
+	}()
+}
+
+func (ss *session) unsubscribe(id uint64) {
+	ss.mu.Lock()
+	cancel, ok := ss.subs[id]
+	delete(ss.subs, id)
+	ss.mu.Unlock()
+	if ok {
+		cancel() // the forwarder then sends "end"
+	}
+}
+
+// encodePayload returns p as JSON, passing raw JSON through untouched.
+func encodePayload(p any) (json.RawMessage, error) {
+	if raw, ok := p.(json.RawMessage); ok {
+		return raw, nil
+	}
+	return json.Marshal(p)
+}
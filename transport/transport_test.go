@@ -0,0 +1,264 @@
+package transport
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/arifmahmudrana/go-snippets/clock"
+	"github.com/arifmahmudrana/go-snippets/pubsub"
+)
+
+// startServer serves a fresh broker on a free local port.
+func startServer(t *testing.T, opts ...pubsub.BrokerOption) (*pubsub.Broker, *Server) {
+	t.Helper()
+	b := pubsub.NewBroker(opts...)
+	s, err := Listen("tcp", "127.0.0.1:0", b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		s.Close()
+		b.Stop()
+	})
+	return b, s
+}
+
+func dial(t *testing.T, s *Server) *Client {
+	t.Helper()
+	c, err := Dial(s.Addr().Network(), s.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+// next reads one message or fails after a timeout.
+func next(t *testing.T, c <-chan pubsub.Message) pubsub.Message {
+	t.Helper()
+	select {
+	case m, ok := <-c:
+		if !ok {
+			t.Fatal("subscription closed")
+		}
+		return m
+	case <-time.After(2 * time.Second):
+		t.Fatal("no message")
+	}
+	return pubsub.Message{}
+}
+
+// TestTransport_RemoteToLocal tests that publishes cross the wire both ways
+func TestTransport_RemoteToLocal(t *testing.T) {
+	b, s := startServer(t)
+	c := dial(t, s)
+
+	local := b.Subscribe("orders")
+	remote, err := c.Subscribe("orders")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.Publish("orders", map[string]int{"id": 1}); err != nil {
+		t.Fatal(err)
+	}
+	if m := next(t, local.C); string(m.Payload.(json.RawMessage)) != `{"id":1}` {
+		t.Errorf("local got %s", m.Payload)
+	}
+
+	b.Publish("orders", "from the server")
+	var got []string
+	for range 2 {
+		m := next(t, remote.C)
+		if m.Seq == 0 || m.Time.IsZero() {
+			t.Errorf("remote message missing Seq or Time: %+v", m)
+		}
+		got = append(got, string(m.Payload.(json.RawMessage)))
+	}
+	if want := `{"id":1} "from the server"`; strings.Join(got, " ") != want {
+		t.Errorf("remote got %v, want %s", got, want)
+	}
+
+	if err := remote.Close(); err != nil {
+		t.Fatal(err)
+	}
+	for range remote.C {
+	}
+}
+
+// TestTransport_Forward tests that a message forwarded from a remote
+// broker is stamped after the remote clock
+func TestTransport_Forward(t *testing.T) {
+	var remoteClock, localClock clock.Lamport
+	b, s := startServer(t, pubsub.WithClock(&remoteClock))
+	c := dial(t, s)
+	remote, err := c.Subscribe("orders")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	local := pubsub.NewBroker(pubsub.WithClock(&localClock))
+	defer local.Stop()
+	sub := local.Subscribe("orders")
+
+	for range 3 {
+		b.Publish("orders", "remote")
+	}
+	var m pubsub.Message
+	for range 3 {
+		m = next(t, remote.C)
+	}
+	if m.Clock != 3 {
+		t.Fatalf("Clock over the wire = %d, want 3", m.Clock)
+	}
+	if err := local.Forward(context.Background(), m); err != nil {
+		t.Fatal(err)
+	}
+	if got := next(t, sub.C); got.Clock <= m.Clock {
+		t.Errorf("forwarded Clock = %d, want more than %d", got.Clock, m.Clock)
+	}
+}
+
+// TestTransport_Since tests replaying retained history over the wire
+func TestTransport_Since(t *testing.T) {
+	b, s := startServer(t, pubsub.WithRetention(pubsub.NewMemoryRetention(10)))
+	for i := range 3 {
+		b.Publish("t", i)
+	}
+
+	c := dial(t, s)
+	sub, err := c.Subscribe("t", Since("1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []uint64{2, 3} {
+		if m := next(t, sub.C); m.Seq != want {
+			t.Errorf("Seq = %d, want %d", m.Seq, want)
+		}
+	}
+
+	if _, err := c.Subscribe("t", Since("nope")); err == nil {
+		t.Error("Subscribe with a bad token succeeded")
+	}
+}
+
+// TestTransport_Disconnect tests that closing either side ends subscriptions
+func TestTransport_Disconnect(t *testing.T) {
+	_, s := startServer(t)
+	c := dial(t, s)
+	sub, err := c.Subscribe("t")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s.Close() // releases the server side of every subscription
+	select {
+	case _, ok := <-sub.C:
+		if ok {
+			t.Error("message after server close")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("subscription not closed after server close")
+	}
+	if _, err := c.Subscribe("t"); err == nil {
+		t.Error("Subscribe on a dead connection succeeded")
+	}
+}
+
+// TestParseAddr tests command-line address parsing
+func TestParseAddr(t *testing.T) {
+	tests := []struct {
+		in, network, addr string
+	}{
+		{"unix:/run/pubsub.sock", "unix", "/run/pubsub.sock"},
+		{"tcp:localhost:7070", "tcp", "localhost:7070"},
+		{"localhost:7070", "tcp", "localhost:7070"},
+		{":7070", "tcp", ":7070"},
+	}
+	for _, tt := range tests {
+		if network, addr := ParseAddr(tt.in); network != tt.network || addr != tt.addr {
+			t.Errorf("ParseAddr(%q) = %q, %q, want %q, %q", tt.in, network, addr, tt.network, tt.addr)
+		}
+	}
+}
+
+// TestTransport_Unix tests serving over a Unix socket, including
+// replacing a stale socket file
+func TestTransport_Unix(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pubsub.sock")
+	if err := os.WriteFile(path, nil, 0o600); err != nil { // stale leftover
+		t.Fatal(err)
+	}
+
+	b := pubsub.NewBroker()
+	defer b.Stop()
+	s, err := Listen("unix", path, b)
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0o600 {
+		t.Errorf("socket mode = %v, %v, want 0600", info.Mode().Perm(), err)
+	}
+	if _, err := Listen("unix", path, b); err == nil {
+		t.Error("second Listen on a live socket succeeded")
+	}
+
+	c, err := Dial("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub, err := c.Subscribe("t")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Publish("t", "over the socket")
+	if m := next(t, sub.C); string(m.Payload.(json.RawMessage)) != `"over the socket"` {
+		t.Errorf("got %s", m.Payload)
+	}
+	c.Close()
+
+	s.Close()
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("socket file left after Close: %v", err)
+	}
+}
+
+// BenchmarkTransport_RoundTrip measures publish to remote delivery on one
+// connection, over TCP loopback and a Unix socket.
+func BenchmarkTransport_RoundTrip(b *testing.B) {
+	for _, network := range []string{"tcp", "unix"} {
+		b.Run(network, func(b *testing.B) {
+			addr := "127.0.0.1:0"
+			if network == "unix" {
+				addr = filepath.Join(b.TempDir(), "bench.sock")
+			}
+			broker := pubsub.NewBroker()
+			defer broker.Stop()
+			s, err := Listen(network, addr, broker)
+			if err != nil {
+				b.Fatal(err)
+			}
+			defer s.Close()
+			c, err := Dial(network, s.Addr().String())
+			if err != nil {
+				b.Fatal(err)
+			}
+			defer c.Close()
+			sub, err := c.Subscribe("t")
+			if err != nil {
+				b.Fatal(err)
+			}
+
+			b.ResetTimer()
+			for i := range b.N {
+				c.Publish("t", i)
+				<-sub.C
+			}
+		})
+	}
+}
@@ -0,0 +1,164 @@
+package wal
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"os"
+)
+
+// reader decodes records from a segment file.
+type reader struct {
+	r      *bufio.Reader
+	header [headerSize]byte
+}
+
+func newReader(r io.Reader) *reader {
+	return &reader{r: bufio.NewReader(r)}
+}
+
+// next returns the next record's data and its encoded size. It returns
+// io.EOF at a clean end and ErrCorrupt for a partial or damaged record.
+func (r *reader) next() ([]byte, int64, error) {
+	if _, err := io.ReadFull(r.r, r.header[:]); err != nil {
+		if err == io.EOF {
+			return nil, 0, io.EOF
+		}
+		return nil, 0, ErrCorrupt // partial header
+	}
+	size := binary.LittleEndian.Uint32(r.header[0:4])
+	sum := binary.LittleEndian.Uint32(r.header[4:8])
+	if size > 1<<30 {
+		return nil, 0, ErrCorrupt
+	}
+
+	data := make([]byte, size)
+	if _, err := io.ReadFull(r.r, data); err != nil {
+		return nil, 0, ErrCorrupt
+	}
+	if crc32.Checksum(data, crcTable) != sum {
+		return nil, 0, ErrCorrupt
+	}
+	return data, int64(headerSize) + int64(size), nil
+}
+
+// Iterator walks records in offset order. It sees records appended up
+// to the moment it reaches the end of the log.
+type Iterator struct {
+	segments []segment
+	idx      int
+	pos      uint64 // offset of the next record in the open segment
+	file     *os.File
+	r        *reader
+	done     bool
+
+	offset uint64
+	data   []byte
+	err    error
+}
+
+// Iterator returns an iterator positioned before the record at from.
+func (l *Log) Iterator(from uint64) (*Iterator, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return nil, ErrClosed
+	}
+	if from < l.segments[0].base {
+		return nil, ErrOutOfRange
+	}
+
+	it := &Iterator{offset: from}
+	for _, s := range l.segments {
+		if s.base+s.count > from || s == l.segments[len(l.segments)-1] {
+			it.segments = append(it.segments, *s)
+		}
+	}
+	return it, nil
+}
+
+// Next advances to the next record and reports whether there is one.
+// Once it returns false the iterator is finished; create a new one to
+// pick up records appended later.
+func (it *Iterator) Next() bool {
+	for it.err == nil && !it.done {
+		if it.r == nil && !it.open() {
+			return false
+		}
+
+		data, _, err := it.r.next()
+		last := it.idx == len(it.segments)-1
+		if err == io.EOF || (err != nil && last) {
+			// the active segment may end mid-write: treat it as the end
+			if last {
+				it.done = true
+				return false
+			}
+			it.closeFile()
+			it.idx++
+			continue
+		}
+		if err != nil {
+			it.err = err
+			return false
+		}
+
+		off := it.pos
+		it.pos++
+		if off < it.offset {
+			continue // skip to the requested start
+		}
+		it.offset, it.data = off+1, data
+		return true
+	}
+	return false
+}
+
+func (it *Iterator) open() bool {
+	if it.idx >= len(it.segments) {
+		return false
+	}
+	s := it.segments[it.idx]
+	f, err := os.Open(s.path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			it.err = ErrOutOfRange // truncated away under us
+		} else {
+			it.err = err
+		}
+		return false
+	}
+	it.pos = s.base
+	it.file, it.r = f, newReader(f)
+	return true
+}
+
+func (it *Iterator) closeFile() {
+	if it.file != nil {
+		it.file.Close()
+		it.file, it.r = nil, nil
+	}
+}
+
+// Offset returns the offset of the current record.
+func (it *Iterator) Offset() uint64 {
+	return it.offset - 1
+}
+
+// Data returns the current record. The slice is not reused.
+func (it *Iterator) Data() []byte {
+	return it.data
+}
+
+// Err returns the error that stopped iteration, if any.
+func (it *Iterator) Err() error {
+	return it.err
+}
+
+// Close releases the iterator's file.
+func (it *Iterator) Close() error {
+	it.closeFile()
+	return nil
+}
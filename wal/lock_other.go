@@ -0,0 +1,28 @@
+//go:build !unix
+
+package wal
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// lockDir takes an exclusive lock on dir by creating its lock file,
+// failing with ErrLocked if the file exists. The returned function
+// removes it. Unlike on Unix, a crash leaves the file behind, and it
+// must be removed by hand once no process has the log open.
+func lockDir(dir string) (unlock func() error, err error) {
+	path := filepath.Join(dir, lockName)
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+	if errors.Is(err, os.ErrExist) {
+		return nil, ErrLocked
+	}
+	if err != nil {
+		return nil, err
+	}
+	return func() error {
+		f.Close()
+		return os.Remove(path)
+	}, nil
+}
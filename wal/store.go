@@ -0,0 +1,203 @@
+package wal
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/arifmahmudrana/go-snippets/store"
+)
+
+// Record kinds of a Store's log.
+const (
+	opPut byte = iota + 1
+	opDelete
+)
+
+// compactMin is how many records a Store's log holds at least before it
+// is compacted, so small stores are not rewritten on every change.
+const compactMin = 1024
+
+// Store is a store.Store kept in a write-ahead log: every Put, Create and
+// Delete appends a record, and Get and List are served from memory,
+// rebuilt from the log on open. It suits small values that change
+// often, such as jobs moving between states, better than a file per
+// key. It implements store.Creator: as Open locks the log's directory,
+// only one process at a time can open the store, and Creates through it
+// are atomic.
+//
+// Once the log holds more than twice as many records as there are keys,
+// the live values are appended again and the segments before them
+// removed. The log's options, such as its SyncPolicy, apply.
+type Store struct {
+	log *Log
+
+	mu      sync.RWMutex
+	data    map[string][]byte
+	records uint64 // in the log, as of the last compaction
+}
+
+var _ store.Creator = (*Store)(nil)
+
+// OpenStore opens or creates a store in dir, replaying its log.
+func OpenStore(dir string, opts ...Option) (*Store, error) {
+	l, err := Open(dir, opts...)
+	if err != nil {
+		return nil, err
+	}
+	s := &Store{log: l, data: make(map[string][]byte)}
+	if err := s.load(); err != nil {
+		l.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// load replays the log into s.data.
+func (s *Store) load() error {
+	it, err := s.log.Iterator(s.log.FirstOffset())
+	if err != nil {
+		return err
+	}
+	defer it.Close()
+	for it.Next() {
+		op, key, value, err := decodeOp(it.Data())
+		if err != nil {
+			return err
+		}
+		s.apply(op, key, value)
+		s.records++
+	}
+	return it.Err()
+}
+
+// encodeOp returns the record of an operation: its kind, the key's
+// length as a uvarint, the key, then the value.
+func encodeOp(op byte, key string, value []byte) []byte {
+	buf := make([]byte, 0, 1+binary.MaxVarintLen64+len(key)+len(value))
+	buf = append(buf, op)
+	buf = binary.AppendUvarint(buf, uint64(len(key)))
+	buf = append(buf, key...)
+	return append(buf, value...)
+}
+
+func decodeOp(rec []byte) (op byte, key string, value []byte, err error) {
+	if len(rec) > 0 {
+		n, w := binary.Uvarint(rec[1:])
+		if w > 0 && uint64(len(rec)-1-w) >= n && (rec[0] == opPut || rec[0] == opDelete) {
+			rest := rec[1+w:]
+			return rec[0], string(rest[:n]), rest[n:], nil
+		}
+	}
+	return 0, "", nil, fmt.Errorf("%w: bad store record", ErrCorrupt)
+}
+
+// apply makes an operation's change to s.data. Caller holds mu, or has
+// s to itself.
+func (s *Store) apply(op byte, key string, value []byte) {
+	if op == opDelete {
+		delete(s.data, key)
+		return
+	}
+	s.data[key] = value
+}
+
+// write appends an operation to the log and applies it. Caller holds
+// mu.
+func (s *Store) write(op byte, key string, value []byte) error {
+	if _, err := s.log.Append(encodeOp(op, key, value)); err != nil {
+		return err
+	}
+	s.apply(op, key, slices.Clone(value))
+	s.records++
+	if s.records > max(2*uint64(len(s.data)), compactMin) {
+		// a failed compaction leaves the log as it was, and is tried
+		// again on a later write
+		s.compact()
+	}
+	return nil
+}
+
+// compact appends every live value again, in a new segment, then
+// removes the segments before it. A crash part way leaves the older
+// records in place, and replaying them first gives the same values.
+// Caller holds mu.
+func (s *Store) compact() error {
+	from, err := s.log.seal()
+	if err != nil {
+		return err
+	}
+	keys := make([]string, 0, len(s.data))
+	for k := range s.data {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	for _, k := range keys {
+		if _, err := s.log.Append(encodeOp(opPut, k, s.data[k])); err != nil {
+			return err
+		}
+	}
+	if err := s.log.Sync(); err != nil {
+		return err
+	}
+	if err := s.log.TruncateFront(from); err != nil {
+		return err
+	}
+	s.records = s.log.NextOffset() - s.log.FirstOffset()
+	return nil
+}
+
+func (s *Store) Get(ctx context.Context, key string) ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	v, ok := s.data[key]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+	return slices.Clone(v), nil
+}
+
+func (s *Store) Put(ctx context.Context, key string, value []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.write(opPut, key, value)
+}
+
+func (s *Store) Create(ctx context.Context, key string, value []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.data[key]; ok {
+		return store.ErrExists
+	}
+	return s.write(opPut, key, value)
+}
+
+func (s *Store) Delete(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.data[key]; !ok {
+		return nil
+	}
+	return s.write(opDelete, key, nil)
+}
+
+func (s *Store) List(ctx context.Context, prefix string) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var keys []string
+	for k := range s.data {
+		if strings.HasPrefix(k, prefix) {
+			keys = append(keys, k)
+		}
+	}
+	slices.Sort(keys)
+	return keys, nil
+}
+
+// Close closes the store's log.
+func (s *Store) Close() error {
+	return s.log.Close()
+}
@@ -0,0 +1,119 @@
+package wal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/arifmahmudrana/go-snippets/store"
+)
+
+// openStore opens a store in dir, failing the test on error.
+func openStore(t *testing.T, dir string, opts ...Option) *Store {
+	t.Helper()
+	s, err := OpenStore(dir, opts...)
+	if err != nil {
+		t.Fatalf("OpenStore() error = %v", err)
+	}
+	return s
+}
+
+// TestStore tests the store.Store operations and that they survive a
+// reopen
+func TestStore(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	s := openStore(t, dir)
+	for _, k := range []string{"job/1", "job/2", "other"} {
+		if err := s.Put(ctx, k, []byte("v:"+k)); err != nil {
+			t.Fatalf("Put(%q) error = %v", k, err)
+		}
+	}
+	s.Put(ctx, "job/1", []byte("new"))
+	s.Delete(ctx, "other")
+	if err := s.Create(ctx, "job/2", nil); !errors.Is(err, store.ErrExists) {
+		t.Errorf("Create(existing) = %v, want %v", err, store.ErrExists)
+	}
+	if err := s.Create(ctx, "claim", []byte("mine")); err != nil {
+		t.Errorf("Create(new) = %v", err)
+	}
+	s.Close()
+
+	s = openStore(t, dir)
+	defer s.Close()
+	want := map[string]string{"job/1": "new", "job/2": "v:job/2", "claim": "mine"}
+	for k, v := range want {
+		if got, err := s.Get(ctx, k); err != nil || string(got) != v {
+			t.Errorf("Get(%q) after reopen = %q, %v, want %q", k, got, err, v)
+		}
+	}
+	if _, err := s.Get(ctx, "other"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("Get(deleted) = %v, want %v", err, store.ErrNotFound)
+	}
+	if got, _ := s.List(ctx, "job/"); !slices.Equal(got, []string{"job/1", "job/2"}) {
+		t.Errorf("List(job/) = %v, want [job/1 job/2]", got)
+	}
+}
+
+// TestStore_Compact tests that rewriting keys keeps the log bounded, and
+// that compaction loses neither values nor deletions
+func TestStore_Compact(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	opts := []Option{WithSegmentSize(4 << 10), WithSyncPolicy(SyncNever)}
+	s := openStore(t, dir, opts...)
+	s.Put(ctx, "gone", []byte("x"))
+	s.Delete(ctx, "gone")
+	for i := range 10 * compactMin {
+		s.Put(ctx, fmt.Sprintf("job/%d", i%10), []byte(fmt.Sprint(i)))
+	}
+	if n := s.log.NextOffset() - s.log.FirstOffset(); n > 2*compactMin {
+		t.Errorf("log holds %d records for 10 keys, want at most %d", n, 2*compactMin)
+	}
+	s.Close()
+
+	s = openStore(t, dir, opts...)
+	defer s.Close()
+	for i := range 10 {
+		k, v := fmt.Sprintf("job/%d", i), fmt.Sprint(10*compactMin-10+i)
+		if got, err := s.Get(ctx, k); err != nil || string(got) != v {
+			t.Errorf("Get(%q) = %q, %v, want %q", k, got, err, v)
+		}
+	}
+	if _, err := s.Get(ctx, "gone"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("Get(deleted) = %v, want %v", err, store.ErrNotFound)
+	}
+}
+
+// TestStore_CrashAtEveryByte tests that cutting the log anywhere in its
+// last record reopens the store as it was before that write
+func TestStore_CrashAtEveryByte(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	s := openStore(t, dir)
+	s.Put(ctx, "a", []byte("1"))
+	before := s.log.segments[0].size
+	s.Put(ctx, "a", []byte("2"))
+	after := s.log.segments[0].size
+	path := s.log.segments[0].path
+	s.Close()
+	full, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for cut := before; cut < after; cut++ {
+		crashed := filepath.Join(t.TempDir(), "crashed")
+		os.MkdirAll(crashed, 0o755)
+		os.WriteFile(filepath.Join(crashed, filepath.Base(path)), full[:cut], 0o644)
+		s := openStore(t, crashed)
+		if got, err := s.Get(ctx, "a"); err != nil || string(got) != "1" {
+			t.Errorf("cut at %d: Get(a) = %q, %v, want %q", cut, got, err, "1")
+		}
+		s.Close()
+	}
+}
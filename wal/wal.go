@@ -0,0 +1,424 @@
+package wal
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrClosed is returned by operations on a closed log.
+	ErrClosed = errors.New("wal: closed")
+	// ErrCorrupt is returned when a record in a sealed segment fails its
+	// checksum. Damage at the tail of the last segment is repaired on
+	// Open instead, since that is what a crash mid-write leaves behind.
+	ErrCorrupt = errors.New("wal: corrupt record")
+	// ErrOutOfRange is returned by Iterator for offsets before the first
+	// retained record.
+	ErrOutOfRange = errors.New("wal: offset out of range")
+	// ErrLocked is returned by Open for a directory another Log, in this
+	// process or another, has open.
+	ErrLocked = errors.New("wal: directory in use by another log")
+)
+
+const (
+	headerSize = 8 // length uint32 + crc32c uint32
+	segmentExt = ".wal"
+	lockName   = "LOCK"
+)
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// SyncPolicy controls when appended data is fsynced.
+type SyncPolicy int
+
+const (
+	// SyncAlways fsyncs after every Append: nothing acknowledged is lost.
+	SyncAlways SyncPolicy = iota
+	// SyncInterval fsyncs in the background every SyncEvery.
+	SyncInterval
+	// SyncNever leaves flushing to the OS (and to explicit Sync calls).
+	SyncNever
+)
+
+type config struct {
+	segmentSize int64
+	policy      SyncPolicy
+	syncEvery   time.Duration
+}
+
+// Option configures a Log.
+type Option func(*config)
+
+// WithSegmentSize sets the size at which a new segment file is started.
+// The default is 64 MiB.
+func WithSegmentSize(n int64) Option {
+	return func(c *config) {
+		c.segmentSize = n
+	}
+}
+
+// WithSyncPolicy sets the fsync policy. The default is SyncAlways.
+func WithSyncPolicy(p SyncPolicy) Option {
+	return func(c *config) {
+		c.policy = p
+	}
+}
+
+// WithSyncEvery sets the interval for SyncInterval. The default is 100ms.
+func WithSyncEvery(d time.Duration) Option {
+	return func(c *config) {
+		c.syncEvery = d
+	}
+}
+
+// segment is one file holding records base, base+1, ...
+type segment struct {
+	base  uint64
+	count uint64
+	size  int64
+	path  string
+}
+
+// segmentFile is the active segment's file; tests substitute one that
+// fails.
+type segmentFile interface {
+	Write(b []byte) (int, error)
+	Truncate(size int64) error
+	Sync() error
+	Close() error
+}
+
+// Log is a segmented, append-only write-ahead log. Every record gets a
+// sequential offset starting at 0. It is safe for concurrent use.
+type Log struct {
+	dir    string
+	cfg    config
+	unlock func() error // releases the lock on dir
+
+	mu       sync.Mutex
+	segments []*segment // oldest first; the last one is active
+	active   segmentFile
+	dirty    bool
+	closed   bool
+	failed   error // why Append can no longer be trusted to append
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// Open opens or creates a log in dir. A partial or corrupt record at the
+// end of the last segment, as left by a crash, is truncated away.
+//
+// Only one Log may have dir open at a time, as two appending to the same
+// segment would corrupt it: Open locks dir until Close, and fails with
+// ErrLocked while another Log holds it.
+func Open(dir string, opts ...Option) (*Log, error) {
+	cfg := config{segmentSize: 64 << 20, syncEvery: 100 * time.Millisecond}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	unlock, err := lockDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	l := &Log{dir: dir, cfg: cfg, unlock: unlock}
+	if err := l.load(); err != nil {
+		unlock()
+		return nil, err
+	}
+
+	if cfg.policy == SyncInterval {
+		l.stop, l.done = make(chan struct{}), make(chan struct{})
+		go l.syncLoop()
+	}
+	return l, nil
+}
+
+func segmentName(base uint64) string {
+	return fmt.Sprintf("%020d%s", base, segmentExt)
+}
+
+// load scans existing segments, repairs the tail and opens the active one.
+func (l *Log) load() error {
+	entries, err := os.ReadDir(l.dir)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		name := e.Name()
+		if !strings.HasSuffix(name, segmentExt) {
+			continue
+		}
+		base, err := strconv.ParseUint(strings.TrimSuffix(name, segmentExt), 10, 64)
+		if err != nil {
+			continue
+		}
+		l.segments = append(l.segments, &segment{base: base, path: filepath.Join(l.dir, name)})
+	}
+	sort.Slice(l.segments, func(i, j int) bool { return l.segments[i].base < l.segments[j].base })
+
+	if len(l.segments) == 0 {
+		return l.roll(0)
+	}
+
+	for i, s := range l.segments {
+		last := i == len(l.segments)-1
+		count, good, err := scan(s.path)
+		if err != nil && !(last && errors.Is(err, ErrCorrupt)) {
+			return fmt.Errorf("%s: %w", s.path, err)
+		}
+		if err != nil {
+			// torn write at the tail: drop it
+			if err := os.Truncate(s.path, good); err != nil {
+				return err
+			}
+		}
+		s.count, s.size = count, good
+	}
+
+	s := l.segments[len(l.segments)-1]
+	f, err := os.OpenFile(s.path, os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	l.active = f
+	return nil
+}
+
+// scan counts the valid records in a segment file and returns the size
+// of the valid prefix. A bad or partial record yields ErrCorrupt.
+func scan(path string) (count uint64, good int64, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
+
+	r := newReader(f)
+	for {
+		_, n, err := r.next()
+		if err == io.EOF {
+			return count, good, nil
+		}
+		if err != nil {
+			return count, good, err
+		}
+		count++
+		good += n
+	}
+}
+
+// roll seals the active segment and starts a new one at base.
+func (l *Log) roll(base uint64) error {
+	if l.active != nil {
+		if err := l.active.Sync(); err != nil {
+			return err
+		}
+		if err := l.active.Close(); err != nil {
+			return err
+		}
+	}
+
+	path := filepath.Join(l.dir, segmentName(base))
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	l.active = f
+	l.segments = append(l.segments, &segment{base: base, path: path})
+	return syncDir(l.dir)
+}
+
+// seal starts a new segment unless the active one is empty, so that
+// TruncateFront can remove every record appended so far. It returns the
+// offset the next Append will get.
+func (l *Log) seal() (uint64, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return 0, ErrClosed
+	}
+	s := l.segments[len(l.segments)-1]
+	next := s.base + s.count
+	if s.count == 0 {
+		return next, nil
+	}
+	if err := l.roll(next); err != nil {
+		return 0, err
+	}
+	l.dirty = false // roll synced the sealed segment
+	return next, nil
+}
+
+// syncDir makes file creations and removals in dir durable.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+// Append writes data as one record and returns its offset. Under
+// SyncAlways the record is on disk when Append returns.
+func (l *Log) Append(data []byte) (uint64, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return 0, ErrClosed
+	}
+	if l.failed != nil {
+		return 0, l.failed
+	}
+
+	s := l.segments[len(l.segments)-1]
+	recSize := int64(headerSize + len(data))
+	if s.count > 0 && s.size+recSize > l.cfg.segmentSize {
+		if err := l.roll(s.base + s.count); err != nil {
+			return 0, err
+		}
+		s = l.segments[len(l.segments)-1]
+	}
+
+	buf := make([]byte, recSize)
+	binary.LittleEndian.PutUint32(buf[0:4], uint32(len(data)))
+	binary.LittleEndian.PutUint32(buf[4:8], crc32.Checksum(data, crcTable))
+	copy(buf[headerSize:], data)
+
+	// one write per record keeps concurrent readers from seeing half a
+	// header; a crash can still tear it, which Open repairs
+	if _, err := l.active.Write(buf); err != nil {
+		// Cut off whatever part of the record was written, or the
+		// next record would follow it, and Open would take the torn
+		// one for the tail and drop everything after it.
+		if terr := l.active.Truncate(s.size); terr != nil {
+			l.failed = fmt.Errorf("wal: segment left with a torn record: %w", terr)
+		}
+		return 0, err
+	}
+	off := s.base + s.count
+	s.count++
+	s.size += recSize
+	l.dirty = true
+
+	if l.cfg.policy == SyncAlways {
+		if err := l.active.Sync(); err != nil {
+			return 0, err
+		}
+		l.dirty = false
+	}
+	return off, nil
+}
+
+// Sync fsyncs the active segment.
+func (l *Log) Sync() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return ErrClosed
+	}
+	return l.syncLocked()
+}
+
+func (l *Log) syncLocked() error {
+	if !l.dirty {
+		return nil
+	}
+	if err := l.active.Sync(); err != nil {
+		return err
+	}
+	l.dirty = false
+	return nil
+}
+
+func (l *Log) syncLoop() {
+	defer close(l.done)
+	t := time.NewTicker(l.cfg.syncEvery)
+	defer t.Stop()
+	for {
+		select {
+		case <-l.stop:
+			return
+		case <-t.C:
+			l.Sync()
+		}
+	}
+}
+
+// FirstOffset returns the offset of the oldest retained record.
+func (l *Log) FirstOffset() uint64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.segments[0].base
+}
+
+// NextOffset returns the offset the next Append will get.
+func (l *Log) NextOffset() uint64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	s := l.segments[len(l.segments)-1]
+	return s.base + s.count
+}
+
+// TruncateFront removes segments whose records all lie before offset.
+// Removal is per segment, so some records before offset may remain; the
+// active segment is never removed.
+func (l *Log) TruncateFront(offset uint64) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return ErrClosed
+	}
+
+	n := 0
+	for n < len(l.segments)-1 && l.segments[n+1].base <= offset {
+		if err := os.Remove(l.segments[n].path); err != nil {
+			return err
+		}
+		n++
+	}
+	if n == 0 {
+		return nil
+	}
+	l.segments = append([]*segment(nil), l.segments[n:]...)
+	return syncDir(l.dir)
+}
+
+// Close syncs and closes the log.
+func (l *Log) Close() error {
+	l.mu.Lock()
+	if l.closed {
+		l.mu.Unlock()
+		return nil
+	}
+	l.closed = true
+	err := l.syncLocked()
+	if cerr := l.active.Close(); err == nil {
+		err = cerr
+	}
+	if uerr := l.unlock(); err == nil {
+		err = uerr
+	}
+	l.mu.Unlock()
+
+	if l.stop != nil {
+		close(l.stop)
+		<-l.done
+	}
+	return err
+}
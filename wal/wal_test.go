@@ -0,0 +1,325 @@
+package wal
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// readAll returns every record from offset from.
+func readAll(t *testing.T, l *Log, from uint64) []string {
+	t.Helper()
+	it, err := l.Iterator(from)
+	if err != nil {
+		t.Fatalf("Iterator(%d) error = %v", from, err)
+	}
+	defer it.Close()
+
+	var out []string
+	for it.Next() {
+		if want := from + uint64(len(out)); it.Offset() != want {
+			t.Fatalf("Offset() = %d, want %d", it.Offset(), want)
+		}
+		out = append(out, string(it.Data()))
+	}
+	if err := it.Err(); err != nil {
+		t.Fatalf("iteration error = %v", err)
+	}
+	return out
+}
+
+func appendN(t *testing.T, l *Log, n int) {
+	t.Helper()
+	for i := range n {
+		if _, err := l.Append([]byte(fmt.Sprintf("record-%03d", i))); err != nil {
+			t.Fatalf("Append() error = %v", err)
+		}
+	}
+}
+
+// TestLog_AppendIterateReopen tests offsets, iteration and persistence
+// across segment boundaries
+func TestLog_AppendIterateReopen(t *testing.T) {
+	dir := t.TempDir()
+	l, err := Open(dir, WithSegmentSize(64))
+	if err != nil {
+		t.Fatal(err)
+	}
+	appendN(t, l, 20)
+	l.Close()
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*"+segmentExt))
+	if len(files) < 5 {
+		t.Errorf("got %d segments, want several", len(files))
+	}
+
+	l, err = Open(dir, WithSegmentSize(64))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	if got := l.NextOffset(); got != 20 {
+		t.Errorf("NextOffset() = %d, want 20", got)
+	}
+	if off, _ := l.Append([]byte("after reopen")); off != 20 {
+		t.Errorf("Append() offset = %d, want 20", off)
+	}
+
+	got := readAll(t, l, 7)
+	if len(got) != 14 || got[0] != "record-007" || got[13] != "after reopen" {
+		t.Errorf("records from 7 = %v", got)
+	}
+}
+
+// TestLog_TruncateFront tests segment removal and out-of-range reads
+func TestLog_TruncateFront(t *testing.T) {
+	l, err := Open(t.TempDir(), WithSegmentSize(64))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	appendN(t, l, 20)
+
+	if err := l.TruncateFront(10); err != nil {
+		t.Fatalf("TruncateFront() error = %v", err)
+	}
+	first := l.FirstOffset()
+	if first == 0 || first > 10 {
+		t.Errorf("FirstOffset() = %d, want in (0, 10]", first)
+	}
+	if _, err := l.Iterator(0); !errors.Is(err, ErrOutOfRange) {
+		t.Errorf("Iterator(0) error = %v, want %v", err, ErrOutOfRange)
+	}
+	if got := readAll(t, l, first); len(got) != int(20-first) {
+		t.Errorf("got %d records after truncation, want %d", len(got), 20-first)
+	}
+
+	// truncating everything keeps the active segment
+	l.TruncateFront(1000)
+	if got := l.NextOffset(); got != 20 {
+		t.Errorf("NextOffset() = %d, want 20", got)
+	}
+}
+
+// TestOpen_Locked tests that a directory can only be opened by one log
+// at a time
+func TestOpen_Locked(t *testing.T) {
+	dir := t.TempDir()
+	l, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open(dir); !errors.Is(err, ErrLocked) {
+		t.Errorf("second Open() error = %v, want ErrLocked", err)
+	}
+	if _, err := OpenStore(dir); !errors.Is(err, ErrLocked) {
+		t.Errorf("OpenStore() error = %v, want ErrLocked", err)
+	}
+	l.Close()
+
+	l, err = Open(dir)
+	if err != nil {
+		t.Fatalf("Open() after Close error = %v", err)
+	}
+	l.Close()
+}
+
+// TestLog_CrashAtEveryByte simulates a crash during the last Append by
+// cutting the segment at every byte of the final record
+func TestLog_CrashAtEveryByte(t *testing.T) {
+	src := t.TempDir()
+	l, err := Open(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	appendN(t, l, 3)
+	l.Close()
+
+	path := filepath.Join(src, segmentName(0))
+	full, _ := os.ReadFile(path)
+	lastLen := headerSize + len("record-002")
+
+	for cut := len(full) - lastLen; cut < len(full); cut++ {
+		dir := t.TempDir()
+		os.WriteFile(filepath.Join(dir, segmentName(0)), full[:cut], 0o644)
+
+		l, err := Open(dir)
+		if err != nil {
+			t.Fatalf("cut=%d: Open() error = %v", cut, err)
+		}
+		if got := readAll(t, l, 0); len(got) != 2 {
+			t.Errorf("cut=%d: recovered %d records, want 2", cut, len(got))
+		}
+		if off, err := l.Append([]byte("new")); err != nil || off != 2 {
+			t.Errorf("cut=%d: Append() = %d, %v, want 2, nil", cut, off, err)
+		}
+		l.Close()
+	}
+}
+
+// tornFile writes only the first half of a record, then fails, while
+// torn is set; failTruncate makes truncating it fail too.
+type tornFile struct {
+	segmentFile
+	torn, failTruncate bool
+}
+
+func (f *tornFile) Write(b []byte) (int, error) {
+	if !f.torn {
+		return f.segmentFile.Write(b)
+	}
+	n, _ := f.segmentFile.Write(b[:len(b)/2])
+	return n, errors.New("disk full")
+}
+
+func (f *tornFile) Truncate(size int64) error {
+	if f.failTruncate {
+		return errors.New("io error")
+	}
+	return f.segmentFile.Truncate(size)
+}
+
+// TestLog_TornAppend tests that a failed Append leaves no torn record for
+// later ones to follow, or, if it cannot be removed, no later ones
+func TestLog_TornAppend(t *testing.T) {
+	for _, failTruncate := range []bool{false, true} {
+		dir := t.TempDir()
+		l, err := Open(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		appendN(t, l, 2)
+		f := &tornFile{segmentFile: l.active, torn: true, failTruncate: failTruncate}
+		l.active = f
+		if _, err := l.Append([]byte("torn")); err == nil {
+			t.Errorf("failTruncate=%v: Append() while writes fail error = nil", failTruncate)
+		}
+		f.torn = false
+		off, err := l.Append([]byte("after"))
+		if failTruncate {
+			if err == nil {
+				t.Errorf("Append() after a torn record was left error = nil, want an error")
+			}
+		} else if err != nil || off != 2 {
+			t.Errorf("Append() after a failed one = %d, %v, want 2, nil", off, err)
+		}
+		l.Close()
+
+		l, err = Open(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []string{"record-000", "record-001", "after"}
+		if failTruncate {
+			want = want[:2]
+		}
+		if got := readAll(t, l, 0); fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Errorf("failTruncate=%v: reopened log has %v, want %v", failTruncate, got, want)
+		}
+		l.Close()
+	}
+}
+
+// TestLog_Corruption tests bit flips in the tail and in sealed segments
+func TestLog_Corruption(t *testing.T) {
+	t.Run("tail is repaired", func(t *testing.T) {
+		dir := t.TempDir()
+		l, _ := Open(dir)
+		appendN(t, l, 3)
+		l.Close()
+
+		path := filepath.Join(dir, segmentName(0))
+		data, _ := os.ReadFile(path)
+		data[len(data)-1] ^= 0xff
+		os.WriteFile(path, data, 0o644)
+
+		l, err := Open(dir)
+		if err != nil {
+			t.Fatalf("Open() error = %v", err)
+		}
+		defer l.Close()
+		if got := l.NextOffset(); got != 2 {
+			t.Errorf("NextOffset() = %d, want 2", got)
+		}
+	})
+
+	t.Run("sealed segment fails", func(t *testing.T) {
+		dir := t.TempDir()
+		l, _ := Open(dir, WithSegmentSize(32))
+		appendN(t, l, 4)
+		l.Close()
+
+		path := filepath.Join(dir, segmentName(0))
+		data, _ := os.ReadFile(path)
+		data[headerSize] ^= 0xff
+		os.WriteFile(path, data, 0o644)
+
+		if _, err := Open(dir, WithSegmentSize(32)); !errors.Is(err, ErrCorrupt) {
+			t.Errorf("Open() error = %v, want %v", err, ErrCorrupt)
+		}
+	})
+}
+
+// TestLog_SyncPolicies tests that every policy persists on Close
+func TestLog_SyncPolicies(t *testing.T) {
+	for _, p := range []SyncPolicy{SyncAlways, SyncInterval, SyncNever} {
+		dir := t.TempDir()
+		l, err := Open(dir, WithSyncPolicy(p), WithSyncEvery(time.Millisecond))
+		if err != nil {
+			t.Fatal(err)
+		}
+		appendN(t, l, 5)
+		time.Sleep(5 * time.Millisecond)
+		if err := l.Close(); err != nil {
+			t.Errorf("policy %d: Close() error = %v", p, err)
+		}
+		if _, err := l.Append(nil); !errors.Is(err, ErrClosed) {
+			t.Errorf("policy %d: Append() after Close = %v, want %v", p, err, ErrClosed)
+		}
+
+		l, _ = Open(dir)
+		if got := l.NextOffset(); got != 5 {
+			t.Errorf("policy %d: NextOffset() = %d, want 5", p, got)
+		}
+		l.Close()
+	}
+}
+
+// TestLog_ConcurrentReaders tests iterating while appending
+func TestLog_ConcurrentReaders(t *testing.T) {
+	l, err := Open(t.TempDir(), WithSegmentSize(256), WithSyncPolicy(SyncNever))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		appendN(t, l, 500)
+	}()
+
+	for range 20 {
+		it, err := l.Iterator(0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var n uint64
+		for it.Next() {
+			if it.Offset() != n {
+				t.Fatalf("Offset() = %d, want %d", it.Offset(), n)
+			}
+			n++
+		}
+		if err := it.Err(); err != nil {
+			t.Fatalf("iteration error = %v", err)
+		}
+		it.Close()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,47 @@
+package watchdog_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/arifmahmudrana/go-snippets/pubsub"
+	"github.com/arifmahmudrana/go-snippets/watchdog"
+)
+
+// Example watches a broker's run loop from the outside: a prober pings
+// the broker every interval and beats only when the loop answers, so a
+// wedged run loop shows up as a stall.
+func Example() {
+	broker := pubsub.NewBroker()
+	w := watchdog.New(20*time.Millisecond, 3, func(s watchdog.Stall) {
+		fmt.Printf("%s stalled\n", s.Name)
+	})
+	defer w.Stop()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		h := w.Register("broker")
+		defer h.Stop()
+		for {
+			ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+			err := broker.Ping(ctx)
+			cancel()
+			if errors.Is(err, pubsub.ErrBrokerClosed) {
+				return
+			}
+			if err == nil {
+				h.Beat()
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}()
+
+	time.Sleep(100 * time.Millisecond)
+	broker.Stop()
+	<-done
+	fmt.Println("no stalls")
+	// Output: no stalls
+}
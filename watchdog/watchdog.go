@@ -0,0 +1,171 @@
+package watchdog
+
+import (
+	"bytes"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Stall describes a participant that stopped checking in.
+type Stall struct {
+	Name     string
+	LastBeat time.Time
+	Missed   int    // whole intervals since the last beat
+	Stack    string // stack of the registering goroutine, if still alive
+}
+
+// Watchdog checks every interval that its participants have called Beat.
+// A participant that misses the configured number of beats is reported
+// once through the callback; it is re-armed by its next Beat.
+type Watchdog struct {
+	interval time.Duration
+	misses   int
+	onStall  func(Stall)
+	now      func() time.Time
+
+	mu    sync.Mutex
+	beats map[*Heartbeat]struct{}
+
+	stop chan struct{}
+	done chan struct{}
+	once sync.Once
+}
+
+// Heartbeat is one participant's handle.
+type Heartbeat struct {
+	w    *Watchdog
+	name string
+	goid int64
+
+	mu       sync.Mutex
+	last     time.Time
+	reported bool
+}
+
+// New starts a watchdog that calls onStall when a participant misses
+// misses consecutive intervals. onStall runs on the watchdog goroutine.
+func New(interval time.Duration, misses int, onStall func(Stall)) *Watchdog {
+	w := &Watchdog{
+		interval: interval,
+		misses:   max(misses, 1),
+		onStall:  onStall,
+		now:      time.Now,
+		beats:    make(map[*Heartbeat]struct{}),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go w.run()
+	return w
+}
+
+// Register adds a participant. Call it from the goroutine being watched:
+// that goroutine's stack is the one dumped on a stall.
+func (w *Watchdog) Register(name string) *Heartbeat {
+	h := &Heartbeat{w: w, name: name, goid: goid(), last: w.now()}
+	w.mu.Lock()
+	w.beats[h] = struct{}{}
+	w.mu.Unlock()
+	return h
+}
+
+// Beat records that the participant is making progress.
+func (h *Heartbeat) Beat() {
+	now := h.w.now()
+	h.mu.Lock()
+	h.last = now
+	h.reported = false
+	h.mu.Unlock()
+}
+
+// Stop removes the participant, e.g. when its loop exits normally.
+func (h *Heartbeat) Stop() {
+	h.w.mu.Lock()
+	delete(h.w.beats, h)
+	h.w.mu.Unlock()
+}
+
+// Stop stops the watchdog and waits for any running callback.
+func (w *Watchdog) Stop() {
+	w.once.Do(func() { close(w.stop) })
+	<-w.done
+}
+
+func (w *Watchdog) run() {
+	defer close(w.done)
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-ticker.C:
+			w.check()
+		}
+	}
+}
+
+// check reports every participant that has newly crossed the threshold.
+func (w *Watchdog) check() {
+	now := w.now()
+	w.mu.Lock()
+	var stalled []Stall
+	var goids []int64
+	for h := range w.beats {
+		h.mu.Lock()
+		missed := int(now.Sub(h.last) / w.interval)
+		if missed >= w.misses && !h.reported {
+			h.reported = true
+			stalled = append(stalled, Stall{Name: h.name, LastBeat: h.last, Missed: missed})
+			goids = append(goids, h.goid)
+		}
+		h.mu.Unlock()
+	}
+	w.mu.Unlock()
+
+	if len(stalled) == 0 {
+		return
+	}
+	all := allStacks()
+	for i := range stalled {
+		stalled[i].Stack = stackOf(all, goids[i])
+		w.onStall(stalled[i])
+	}
+}
+
+// goid returns the current goroutine's id, parsed from its stack header.
+func goid() int64 {
+	var buf [64]byte
+	b := buf[:runtime.Stack(buf[:], false)]
+	b = bytes.TrimPrefix(b, []byte("goroutine "))
+	if i := bytes.IndexByte(b, ' '); i > 0 {
+		b = b[:i]
+	}
+	id, _ := strconv.ParseInt(string(b), 10, 64)
+	return id
+}
+
+func allStacks() string {
+	buf := make([]byte, 1<<16)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			return string(buf[:n])
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+}
+
+// stackOf extracts one goroutine's trace from a runtime.Stack(all) dump.
+func stackOf(all string, id int64) string {
+	prefix := "goroutine " + strconv.FormatInt(id, 10) + " "
+	for _, block := range strings.Split(all, "\n\n") {
+		if strings.HasPrefix(block, prefix) {
+			return block
+		}
+	}
+	return ""
+}
@@ -0,0 +1,75 @@
+package watchdog
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// stuckLoop beats a few times and then blocks forever on never.
+func stuckLoop(w *Watchdog, registered chan<- *Heartbeat, never <-chan struct{}) {
+	h := w.Register("stuck-loop")
+	registered <- h
+	for range 3 {
+		h.Beat()
+		time.Sleep(5 * time.Millisecond)
+	}
+	<-never
+}
+
+// TestWatchdog_ReportsStall tests that a silent participant is reported
+// once, with its own stack
+func TestWatchdog_ReportsStall(t *testing.T) {
+	stalls := make(chan Stall, 10)
+	w := New(10*time.Millisecond, 3, func(s Stall) { stalls <- s })
+	defer w.Stop()
+
+	never := make(chan struct{})
+	defer close(never)
+	registered := make(chan *Heartbeat, 1)
+	go stuckLoop(w, registered, never)
+	<-registered
+
+	select {
+	case s := <-stalls:
+		if s.Name != "stuck-loop" {
+			t.Errorf("Name = %q, want %q", s.Name, "stuck-loop")
+		}
+		if s.Missed < 3 {
+			t.Errorf("Missed = %d, want >= 3", s.Missed)
+		}
+		if !strings.Contains(s.Stack, "stuckLoop") {
+			t.Errorf("Stack does not show stuckLoop:\n%s", s.Stack)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("stall not reported")
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	if n := len(stalls); n != 0 {
+		t.Errorf("stall reported %d more times, want once", n)
+	}
+}
+
+// TestWatchdog_HealthyAndStopped tests that beating and stopped
+// participants are not reported
+func TestWatchdog_HealthyAndStopped(t *testing.T) {
+	stalls := make(chan Stall, 10)
+	w := New(10*time.Millisecond, 2, func(s Stall) { stalls <- s })
+	defer w.Stop()
+
+	healthy := w.Register("healthy")
+	w.Register("finished").Stop()
+
+	for range 10 {
+		healthy.Beat()
+		time.Sleep(5 * time.Millisecond)
+	}
+	healthy.Stop()
+
+	select {
+	case s := <-stalls:
+		t.Errorf("unexpected stall: %s", s.Name)
+	default:
+	}
+}
@@ -0,0 +1,344 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/arifmahmudrana/go-snippets/pubsub"
+)
+
+// Headers set on every delivery.
+const (
+	HeaderSignature = "X-Webhook-Signature" // "sha256=" and the hex HMAC of timestamp "." body
+	HeaderTimestamp = "X-Webhook-Timestamp" // unix seconds when the request was signed
+	HeaderTopic     = "X-Webhook-Topic"
+	HeaderID        = "X-Webhook-ID" // topic#seq, the same on every retry, for deduplication
+)
+
+// ErrSignature is returned by Verify for a request that was not signed
+// with the secret, or was signed too long ago.
+var ErrSignature = errors.New("webhook: invalid signature")
+
+// Failure is published on the dead-letter topic for a message that
+// could not be delivered to an endpoint.
+type Failure struct {
+	URL      string
+	Message  pubsub.Message
+	Attempts int
+	Err      string
+}
+
+// StatusError is a delivery the endpoint answered with a non-2xx
+// status.
+type StatusError struct {
+	Code int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("webhook: endpoint answered %d %s", e.Code, http.StatusText(e.Code))
+}
+
+// defaultBuffer is how many messages wait for an endpoint, by default,
+// before newer ones are given up on.
+const defaultBuffer = 1000
+
+// retryable reports whether the endpoint may accept the same request
+// later: server errors and rate limiting, but not other client errors.
+func (e *StatusError) retryable() bool {
+	return e.Code >= 500 || e.Code == http.StatusTooManyRequests || e.Code == http.StatusRequestTimeout
+}
+
+// Sink POSTs the messages of a topic to webhook endpoints. Each
+// endpoint has its own subscription and gets the messages in order, one
+// at a time, so a slow or failing endpoint holds up only itself.
+//
+// Messages for an endpoint that is behind, e.g. while a delivery to it is
+// being retried, wait in its subscription's buffer, of 1000 messages
+// unless WithSubscribeOptions says otherwise. Once that is full, newer
+// messages are not queued: like a delivery that failed for good, each is
+// counted as Failed and dead-lettered, with no attempts made.
+type Sink struct {
+	secret     []byte
+	client     *http.Client
+	codec      pubsub.Codec
+	attempts   int
+	backoff    time.Duration
+	maxBackoff time.Duration
+	deadLetter string
+	subOpts    []pubsub.SubscribeOption
+
+	broker      *pubsub.Broker
+	ctx         context.Context
+	cancel      context.CancelFunc
+	unsubscribe []func()
+	once        sync.Once
+
+	// Failures of messages dropped by a subscription, waiting to be
+	// dead-lettered by sendDeadLetters, as the broker's goroutines that
+	// drop them may not publish.
+	dmu     sync.Mutex
+	dropped []Failure
+	wake    chan struct{}
+	stop    chan struct{}
+	done    chan struct{}
+
+	delivered, retries, failed atomic.Uint64
+}
+
+// Option configures a Sink.
+type Option func(*Sink)
+
+// WithClient sets the HTTP client (default one with a 10s timeout).
+func WithClient(c *http.Client) Option {
+	return func(s *Sink) {
+		s.client = c
+	}
+}
+
+// WithCodec sets how a message becomes the request body (default
+// pubsub.JSONLines).
+func WithCodec(c pubsub.Codec) Option {
+	return func(s *Sink) {
+		s.codec = c
+	}
+}
+
+// WithRetries makes up to attempts tries per message (default 5),
+// sleeping backoff before the second and doubling it after each failure,
+// up to maxBackoff (default 500ms and 30s).
+func WithRetries(attempts int, backoff, maxBackoff time.Duration) Option {
+	return func(s *Sink) {
+		s.attempts, s.backoff, s.maxBackoff = max(attempts, 1), backoff, maxBackoff
+	}
+}
+
+// WithDeadLetter publishes a Failure on topic for every message an
+// endpoint rejected for good, or that ran out of attempts. Without it
+// such messages are only counted.
+func WithDeadLetter(topic string) Option {
+	return func(s *Sink) {
+		s.deadLetter = topic
+	}
+}
+
+// WithSubscribeOptions configures each endpoint's subscription, e.g.
+// with pubsub.WithBuffer to let more messages wait for an endpoint, or
+// pubsub.WithPolicy. The default is pubsub.DropNewest with a buffer of
+// 1000.
+func WithSubscribeOptions(opts ...pubsub.SubscribeOption) Option {
+	return func(s *Sink) {
+		s.subOpts = append(s.subOpts, opts...)
+	}
+}
+
+// New starts delivering the messages published on topic to every URL,
+// signed with secret, until Close.
+func New(b *pubsub.Broker, topic string, urls []string, secret []byte, opts ...Option) *Sink {
+	s := &Sink{
+		secret:     secret,
+		client:     &http.Client{Timeout: 10 * time.Second},
+		codec:      pubsub.JSONLines,
+		attempts:   5,
+		backoff:    500 * time.Millisecond,
+		maxBackoff: 30 * time.Second,
+		broker:     b,
+		wake:       make(chan struct{}, 1),
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	go s.sendDeadLetters()
+	for _, url := range urls {
+		subOpts := slices.Concat(
+			[]pubsub.SubscribeOption{pubsub.WithPolicy(pubsub.DropNewest), pubsub.WithBuffer(defaultBuffer)},
+			s.subOpts,
+			[]pubsub.SubscribeOption{pubsub.WithOnDrop(func(m pubsub.Message, err error) {
+				s.drop(url, m, err)
+			})},
+		)
+		s.unsubscribe = append(s.unsubscribe, b.SubscribeFunc(topic, func(m pubsub.Message) {
+			s.deliver(url, m)
+		}, subOpts...))
+	}
+	return s
+}
+
+// deliver sends m to url, retrying as configured, and dead-letters it if
+// that fails.
+func (s *Sink) deliver(url string, m pubsub.Message) {
+	body, err := s.codec(m)
+	if err != nil {
+		s.fail(url, m, 0, err)
+		return
+	}
+	backoff := s.backoff
+	for attempt := 1; ; attempt++ {
+		wait, err := s.post(url, m, body)
+		if err == nil {
+			s.delivered.Add(1)
+			return
+		}
+		if s.ctx.Err() != nil {
+			return // closing: the message is abandoned
+		}
+		var status *StatusError
+		if errors.As(err, &status) && !status.retryable() || attempt == s.attempts {
+			s.fail(url, m, attempt, err)
+			return
+		}
+		s.retries.Add(1)
+		t := time.NewTimer(max(backoff, wait))
+		select {
+		case <-s.ctx.Done():
+			t.Stop()
+			return // closing: the message is abandoned
+		case <-t.C:
+		}
+		backoff = min(backoff*2, s.maxBackoff)
+	}
+}
+
+// post makes one delivery attempt. On a 429 or 503 it also returns how
+// long the endpoint asked to wait, from Retry-After.
+func (s *Sink) post(url string, m pubsub.Message, body []byte) (retryAfter time.Duration, err error) {
+	req, err := http.NewRequestWithContext(s.ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderTimestamp, ts)
+	req.Header.Set(HeaderSignature, Sign(s.secret, ts, body))
+	req.Header.Set(HeaderTopic, m.Topic)
+	req.Header.Set(HeaderID, m.Topic+"#"+strconv.FormatUint(m.Seq, 10))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	io.Copy(io.Discard, resp.Body) // so the connection can be reused
+	resp.Body.Close()
+	if resp.StatusCode/100 == 2 {
+		return 0, nil
+	}
+	if secs, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+		retryAfter = time.Duration(secs) * time.Second
+	}
+	return retryAfter, &StatusError{Code: resp.StatusCode}
+}
+
+// fail counts a message that was not delivered and dead-letters it.
+func (s *Sink) fail(url string, m pubsub.Message, attempts int, err error) {
+	s.failed.Add(1)
+	if s.deadLetter != "" {
+		s.broker.Publish(s.deadLetter, Failure{URL: url, Message: m, Attempts: attempts, Err: err.Error()})
+	}
+}
+
+// drop counts a message url's subscription had no room for and queues it
+// to be dead-lettered. It is called on the broker's goroutines.
+func (s *Sink) drop(url string, m pubsub.Message, err error) {
+	s.failed.Add(1)
+	if s.deadLetter == "" {
+		return
+	}
+	s.dmu.Lock()
+	s.dropped = append(s.dropped, Failure{URL: url, Message: m, Err: err.Error()})
+	s.dmu.Unlock()
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// sendDeadLetters publishes the failures drop queues, until Close.
+func (s *Sink) sendDeadLetters() {
+	defer close(s.done)
+	for {
+		select {
+		case <-s.wake:
+		case <-s.stop:
+		}
+		s.dmu.Lock()
+		dropped := s.dropped
+		s.dropped = nil
+		s.dmu.Unlock()
+		for _, f := range dropped {
+			s.broker.Publish(s.deadLetter, f)
+		}
+		select {
+		case <-s.stop:
+			return
+		default:
+		}
+	}
+}
+
+// Stats counts a Sink's deliveries, over all endpoints.
+type Stats struct {
+	Delivered uint64
+	Retries   uint64 // attempts that failed and were retried
+	Failed    uint64 // messages given up on, and dead-lettered if configured
+}
+
+// Stats returns the sink's counters.
+func (s *Sink) Stats() Stats {
+	return Stats{Delivered: s.delivered.Load(), Retries: s.retries.Load(), Failed: s.failed.Load()}
+}
+
+// Close stops delivering. A request in flight is cancelled and a
+// message waiting to be retried is abandoned, neither being
+// dead-lettered.
+func (s *Sink) Close() {
+	s.once.Do(func() {
+		s.cancel()
+		for _, unsubscribe := range s.unsubscribe {
+			unsubscribe()
+		}
+		close(s.stop)
+		<-s.done
+	})
+}
+
+// Sign returns the signature header value for body sent at timestamp
+// (unix seconds, as in HeaderTimestamp).
+func Sign(secret []byte, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks, on the receiving end, that a request with header and
+// body was signed with secret no more than tolerance ago, which stops a
+// captured request from being replayed later.
+func Verify(secret []byte, header http.Header, body []byte, tolerance time.Duration) error {
+	ts := header.Get(HeaderTimestamp)
+	sec, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return fmt.Errorf("%w: bad timestamp %q", ErrSignature, ts)
+	}
+	if age := time.Since(time.Unix(sec, 0)); age > tolerance || age < -tolerance {
+		return fmt.Errorf("%w: signed %v ago", ErrSignature, age.Round(time.Second))
+	}
+	if !hmac.Equal([]byte(header.Get(HeaderSignature)), []byte(Sign(secret, ts, body))) {
+		return ErrSignature
+	}
+	return nil
+}
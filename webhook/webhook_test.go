@@ -0,0 +1,213 @@
+package webhook
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/arifmahmudrana/go-snippets/pubsub"
+)
+
+var secret = []byte("s3cret")
+
+// endpoint is a webhook receiver answering with the given statuses in
+// turn, then 200, and recording the IDs it accepted.
+type endpoint struct {
+	*httptest.Server
+	t        *testing.T
+	statuses []int
+	calls    atomic.Int32
+
+	mu  sync.Mutex
+	ids []string
+}
+
+func newEndpoint(t *testing.T, statuses ...int) *endpoint {
+	e := &endpoint{t: t, statuses: statuses}
+	e.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if err := Verify(secret, r.Header, body, time.Minute); err != nil {
+			t.Errorf("Verify() = %v", err)
+		}
+		if i := int(e.calls.Add(1)) - 1; i < len(e.statuses) {
+			w.WriteHeader(e.statuses[i])
+			return
+		}
+		e.mu.Lock()
+		e.ids = append(e.ids, r.Header.Get(HeaderID))
+		e.mu.Unlock()
+	}))
+	t.Cleanup(e.Close)
+	return e
+}
+
+func (e *endpoint) accepted() []string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]string(nil), e.ids...)
+}
+
+// waitFor polls cond until it holds.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// TestSink tests signed, in-order delivery to every endpoint
+func TestSink(t *testing.T) {
+	b := pubsub.NewBroker()
+	defer b.Stop()
+	a, c := newEndpoint(t), newEndpoint(t, http.StatusServiceUnavailable)
+	s := New(b, "orders", []string{a.URL, c.URL}, secret, WithRetries(3, time.Millisecond, time.Millisecond))
+	defer s.Close()
+
+	for range 3 {
+		b.Publish("orders", "order")
+	}
+	want := "[orders#1 orders#2 orders#3]"
+	for _, e := range []*endpoint{a, c} {
+		waitFor(t, "deliveries", func() bool { return len(e.accepted()) == 3 })
+		if got := e.accepted(); fmtIDs(got) != want {
+			t.Errorf("accepted %v, want %v", got, want)
+		}
+	}
+	// an endpoint records a delivery before the sink sees its response
+	waitFor(t, "stats", func() bool { return s.Stats().Delivered == 6 })
+	if st := s.Stats(); st != (Stats{Delivered: 6, Retries: 1}) {
+		t.Errorf("Stats() = %+v, want 6 delivered, 1 retry", st)
+	}
+}
+
+func fmtIDs(ids []string) string {
+	s := "["
+	for i, id := range ids {
+		if i > 0 {
+			s += " "
+		}
+		s += id
+	}
+	return s + "]"
+}
+
+// TestSink_DeadLetter tests which failures are retried and that the
+// ones given up on are dead-lettered
+func TestSink_DeadLetter(t *testing.T) {
+	tests := []struct {
+		name         string
+		statuses     []int
+		wantAttempts int
+	}{
+		{"client error", []int{http.StatusBadRequest}, 1},
+		{"server errors", []int{500, 502, 503}, 3},
+		{"rate limited", []int{429, 429, 429}, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := pubsub.NewBroker()
+			defer b.Stop()
+			dead := b.Subscribe("orders.dead")
+			e := newEndpoint(t, tt.statuses...)
+			s := New(b, "orders", []string{e.URL}, secret,
+				WithRetries(3, time.Millisecond, time.Millisecond), WithDeadLetter("orders.dead"))
+			defer s.Close()
+
+			b.Publish("orders", "order")
+			select {
+			case m := <-dead.C:
+				f := m.Payload.(Failure)
+				if f.URL != e.URL || f.Attempts != tt.wantAttempts || f.Message.Seq != 1 {
+					t.Errorf("Failure = %+v, want %d attempts at %s", f, tt.wantAttempts, e.URL)
+				}
+				want := &StatusError{Code: tt.statuses[len(tt.statuses)-1]}
+				if f.Err != want.Error() {
+					t.Errorf("Failure.Err = %q, want %q", f.Err, want.Error())
+				}
+			case <-time.After(3 * time.Second):
+				t.Fatal("nothing dead-lettered")
+			}
+			if st := s.Stats(); st.Failed != 1 || st.Delivered != 0 {
+				t.Errorf("Stats() = %+v, want 1 failed", st)
+			}
+		})
+	}
+}
+
+// TestSink_Overflow tests that a burst for a failing endpoint, more than
+// its subscription can hold, is dead-lettered in full
+func TestSink_Overflow(t *testing.T) {
+	b := pubsub.NewBroker()
+	defer b.Stop()
+	const n = 30
+	dead := b.Subscribe("orders.dead", pubsub.WithBuffer(n))
+	statuses := make([]int, 10*n)
+	for i := range statuses {
+		statuses[i] = http.StatusServiceUnavailable
+	}
+	e := newEndpoint(t, statuses...)
+	s := New(b, "orders", []string{e.URL}, secret,
+		WithRetries(2, 5*time.Millisecond, 5*time.Millisecond), WithDeadLetter("orders.dead"),
+		WithSubscribeOptions(pubsub.WithBuffer(2)))
+	defer s.Close()
+
+	for i := range n {
+		b.Publish("orders", i)
+	}
+	seen := make(map[uint64]bool)
+	for range n {
+		select {
+		case m := <-dead.C:
+			f := m.Payload.(Failure)
+			if seen[f.Message.Seq] {
+				t.Errorf("Seq %d dead-lettered twice", f.Message.Seq)
+			}
+			seen[f.Message.Seq] = true
+		case <-time.After(3 * time.Second):
+			t.Fatalf("%d of %d messages dead-lettered", len(seen), n)
+		}
+	}
+	if st := s.Stats(); st.Failed != n || st.Delivered != 0 {
+		t.Errorf("Stats() = %+v, want %d failed", st, n)
+	}
+}
+
+// TestVerify tests rejecting tampered, stale and wrongly signed requests
+func TestVerify(t *testing.T) {
+	body := []byte(`{"id":1}`)
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	old := strconv.FormatInt(time.Now().Add(-time.Hour).Unix(), 10)
+	header := func(ts, sig string) http.Header {
+		h := http.Header{}
+		h.Set(HeaderTimestamp, ts)
+		h.Set(HeaderSignature, sig)
+		return h
+	}
+	tests := []struct {
+		name   string
+		header http.Header
+		body   []byte
+		want   error
+	}{
+		{"valid", header(now, Sign(secret, now, body)), body, nil},
+		{"tampered", header(now, Sign(secret, now, body)), []byte(`{"id":2}`), ErrSignature},
+		{"stale", header(old, Sign(secret, old, body)), body, ErrSignature},
+		{"wrong secret", header(now, Sign([]byte("other"), now, body)), body, ErrSignature},
+		{"no timestamp", header("", Sign(secret, "", body)), body, ErrSignature},
+	}
+	for _, tt := range tests {
+		if err := Verify(secret, tt.header, tt.body, time.Minute); !errors.Is(err, tt.want) {
+			t.Errorf("%s: Verify() = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package workerpool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrBudgetExhausted is returned by Retry when the task's deadline leaves
+// no time for another attempt.
+var ErrBudgetExhausted = errors.New("workerpool: time budget exhausted")
+
+// Budget is a task's time allowance: the time from submission to the
+// deadline inherited from the submitter, shortened by any budget given
+// to SubmitBudget. Time spent queued counts against it, and so does
+// every attempt of a Retry chain.
+type Budget struct {
+	start    time.Time
+	deadline time.Time // zero if unlimited
+}
+
+type budgetKey struct{}
+
+// BudgetFrom returns the budget of the task running with ctx. Outside a
+// task it returns a budget that started now and ends at ctx's deadline,
+// if any.
+func BudgetFrom(ctx context.Context) *Budget {
+	if b, ok := ctx.Value(budgetKey{}).(*Budget); ok {
+		return b
+	}
+	d, _ := ctx.Deadline()
+	return &Budget{start: time.Now(), deadline: d}
+}
+
+// Elapsed returns the time since the task was submitted.
+func (b *Budget) Elapsed() time.Duration {
+	return time.Since(b.start)
+}
+
+// Remaining returns the time left before the deadline, which is negative
+// once it has passed. ok is false if there is no deadline.
+func (b *Budget) Remaining() (d time.Duration, ok bool) {
+	if b.deadline.IsZero() {
+		return 0, false
+	}
+	return time.Until(b.deadline), true
+}
+
+// Deadline returns the task's deadline, if it has one.
+func (b *Budget) Deadline() (time.Time, bool) {
+	return b.deadline, !b.deadline.IsZero()
+}
+
+// SubmitBudget is Submit with a time budget for t, counted from now:
+// t's context expires when the budget or ctx's deadline runs out,
+// whichever is first.
+func (p *Pool) SubmitBudget(ctx context.Context, budget time.Duration, t Task) error {
+	return p.submit(ctx, budgeted(ctx, budget, t))
+}
+
+// budgeted wraps t so it runs with a context derived from the pool's
+// (not ctx, which may end as soon as Submit returns), carrying a Budget that ends at ctx's deadline or after budget (if
+// positive), whichever is first.
+func budgeted(ctx context.Context, budget time.Duration, t Task) Task {
+	b := &Budget{start: time.Now()}
+	b.deadline, _ = ctx.Deadline()
+	if budget > 0 {
+		if d := b.start.Add(budget); b.deadline.IsZero() || d.Before(b.deadline) {
+			b.deadline = d
+		}
+	}
+
+	return func(poolCtx context.Context) {
+		tctx := context.WithValue(poolCtx, budgetKey{}, b)
+		if !b.deadline.IsZero() {
+			var cancel context.CancelFunc
+			tctx, cancel = context.WithDeadline(tctx, b.deadline)
+			defer cancel()
+		}
+		t(tctx)
+	}
+}
+
+// Retry calls fn until it succeeds, up to attempts times (at least
+// once), sleeping backoff before the second attempt and doubling it
+// after each failure. It never sleeps past ctx's deadline: when the
+// remaining budget cannot cover the next backoff it gives up early with
+// an error wrapping both ErrBudgetExhausted and fn's last error. It
+// fails with ctx.Err() if ctx is done while waiting.
+func Retry(ctx context.Context, attempts int, backoff time.Duration, fn func(ctx context.Context) error) error {
+	b := BudgetFrom(ctx)
+	var err error
+	for i := range max(attempts, 1) {
+		if i > 0 {
+			if left, ok := b.Remaining(); ok && left <= backoff {
+				return fmt.Errorf("%w after %d attempts in %v: %w", ErrBudgetExhausted, i, b.Elapsed().Round(time.Millisecond), err)
+			}
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+		}
+		if err = fn(ctx); err == nil {
+			return nil
+		}
+	}
+	return err
+}
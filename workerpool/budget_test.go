@@ -0,0 +1,142 @@
+package workerpool
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// TestPool_TaskInheritsDeadline tests that a task's context keeps the
+// submitter's deadline but not its cancellation
+func TestPool_TaskInheritsDeadline(t *testing.T) {
+	p := New(context.Background(), 1)
+	defer p.Close()
+
+	deadline := time.Now().Add(time.Hour)
+	ctx, cancel := context.WithDeadline(context.Background(), deadline)
+	got := make(chan context.Context, 1)
+	release := make(chan struct{})
+	if err := p.Submit(ctx, func(ctx context.Context) {
+		<-release
+		got <- ctx
+	}); err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	close(release)
+
+	tctx := <-got
+	if d, ok := tctx.Deadline(); !ok || !d.Equal(deadline) {
+		t.Errorf("task Deadline() = %v, %v, want %v", d, ok, deadline)
+	}
+	if err := tctx.Err(); err != context.Canceled {
+		t.Errorf("task ctx.Err() after it returned = %v, want %v", err, context.Canceled)
+	}
+}
+
+// TestPool_SubmitBudget tests that the budget counts from submission,
+// including time spent queued, and never outlasts the caller's deadline
+func TestPool_SubmitBudget(t *testing.T) {
+	tests := []struct {
+		name     string
+		caller   time.Duration // 0 for no deadline
+		budget   time.Duration
+		queued   time.Duration
+		wantLeft time.Duration // upper bound on the budget left when the task starts
+	}{
+		{"budget only", 0, 200 * time.Millisecond, 50 * time.Millisecond, 150 * time.Millisecond},
+		{"caller first", 100 * time.Millisecond, time.Hour, 0, 100 * time.Millisecond},
+		{"budget first", time.Hour, 100 * time.Millisecond, 0, 100 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := New(context.Background(), 1)
+			defer p.Close()
+
+			ctx := context.Background()
+			if tt.caller > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tt.caller)
+				defer cancel()
+			}
+
+			// occupy the only worker so the task waits in the queue
+			block := make(chan struct{})
+			p.Submit(context.Background(), func(context.Context) { <-block })
+
+			left := make(chan time.Duration, 1)
+			if err := p.SubmitBudget(ctx, tt.budget, func(ctx context.Context) {
+				d, _ := BudgetFrom(ctx).Remaining()
+				left <- d
+			}); err != nil {
+				t.Fatal(err)
+			}
+			time.Sleep(tt.queued)
+			close(block)
+
+			if d := <-left; d > tt.wantLeft || d <= 0 {
+				t.Errorf("Remaining() = %v, want in (0, %v]", d, tt.wantLeft)
+			}
+		})
+	}
+}
+
+// TestRetry_Budget tests that Retry stops retrying once the next backoff
+// would run past the deadline
+func TestRetry_Budget(t *testing.T) {
+	errFlaky := errors.New("flaky")
+
+	tests := []struct {
+		name         string
+		timeout      time.Duration // 0 for no deadline
+		attempts     int
+		wantAttempts int
+		wantErr      error
+	}{
+		{"no deadline", 0, 4, 4, errFlaky},
+		// backoffs of 10, 20, 40ms: the third would end past 50ms
+		{"deadline", 50 * time.Millisecond, 10, 3, ErrBudgetExhausted},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.timeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tt.timeout)
+				defer cancel()
+			}
+
+			calls := 0
+			start := time.Now()
+			err := Retry(ctx, tt.attempts, 10*time.Millisecond, func(context.Context) error {
+				calls++
+				return errFlaky
+			})
+			if !errors.Is(err, tt.wantErr) || !errors.Is(err, errFlaky) {
+				t.Errorf("Retry() = %v, want %v wrapping %v", err, tt.wantErr, errFlaky)
+			}
+			if calls != tt.wantAttempts {
+				t.Errorf("attempts = %d, want %d", calls, tt.wantAttempts)
+			}
+			if tt.timeout > 0 && time.Since(start) > tt.timeout {
+				t.Errorf("Retry() took %v, past the %v deadline", time.Since(start), tt.timeout)
+			}
+		})
+	}
+}
+
+// TestRetry_Success tests that Retry returns as soon as an attempt succeeds
+func TestRetry_Success(t *testing.T) {
+	calls := 0
+	err := Retry(context.Background(), 5, time.Millisecond, func(context.Context) error {
+		calls++
+		if calls < 3 {
+			return errors.New("not yet")
+		}
+		return nil
+	})
+	if err != nil || calls != 3 {
+		t.Errorf("Retry() = %v after %d attempts, want nil after 3", err, calls)
+	}
+}
@@ -0,0 +1,35 @@
+package workerpool_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/arifmahmudrana/go-snippets/pubsub"
+	"github.com/arifmahmudrana/go-snippets/workerpool"
+)
+
+// ExampleFromTopic runs tasks published on a topic and reports their
+// outcome from the ack topic.
+func ExampleFromTopic() {
+	broker := pubsub.NewBroker()
+	defer broker.Stop()
+	acks := broker.Subscribe(workerpool.AckTopic("resize"))
+
+	c := workerpool.FromTopic(broker, "resize", func(ctx context.Context, m pubsub.Message) error {
+		if name := m.Payload.(string); !strings.HasSuffix(name, ".png") {
+			return fmt.Errorf("%s: not a PNG", name)
+		}
+		return nil
+	}, workerpool.WithWorkers(1), workerpool.WithMaxAttempts(1))
+	defer c.Close()
+
+	broker.Publish("resize", "cat.png")
+	broker.Publish("resize", "notes.txt")
+	for range 2 {
+		fmt.Printf("%+v\n", (<-acks.C).Payload)
+	}
+	// Output:
+	// {Seq:1 Attempts:1 Err:}
+	// {Seq:2 Attempts:1 Err:notes.txt: not a PNG}
+}
@@ -0,0 +1,224 @@
+package workerpool
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/arifmahmudrana/go-snippets/ackqueue"
+	"github.com/arifmahmudrana/go-snippets/pubsub"
+)
+
+// Handler processes a task taken from a topic. Returning nil
+// acknowledges the task; an error makes it eligible for another attempt.
+type Handler func(ctx context.Context, m pubsub.Message) error
+
+// Ack reports how a task ended. A Consumer publishes one on
+// AckTopic(topic) for every task it acknowledges or gives up on, so the
+// publisher can learn the outcome.
+type Ack struct {
+	Seq      uint64 // the task message's sequence number
+	Attempts int
+	Err      string // the last error if the task was given up, else ""
+}
+
+// AckTopic returns the topic a Consumer of topic publishes its Acks on.
+func AckTopic(topic string) string {
+	return topic + ".ack"
+}
+
+// ConsumerOption configures a Consumer.
+type ConsumerOption func(*Consumer)
+
+// WithWorkers sets how many tasks run at once (default GOMAXPROCS).
+func WithWorkers(n int) ConsumerOption {
+	return func(c *Consumer) {
+		c.workers = n
+	}
+}
+
+// WithLease sets how long a task may run before it is considered lost
+// and handed out again (default one minute). Tasks that outlive their
+// lease may run twice, so handlers should be idempotent.
+func WithLease(d time.Duration) ConsumerOption {
+	return func(c *Consumer) {
+		c.lease = d
+	}
+}
+
+// WithMaxAttempts gives up on a task after n failed or lost attempts
+// (default 3; 0 retries forever).
+func WithMaxAttempts(n int) ConsumerOption {
+	return func(c *Consumer) {
+		c.maxAttempts = n
+	}
+}
+
+// Consumer runs a Handler on a worker pool for each message published on
+// a topic. Messages are queued as they arrive, so the broker never sees
+// a slow subscriber, and each is retried until its handler succeeds or
+// it runs out of attempts.
+type Consumer struct {
+	broker      *pubsub.Broker
+	topic       string
+	handler     Handler
+	workers     int
+	lease       time.Duration
+	maxAttempts int
+
+	sub     *pubsub.Subscriber
+	queue   *ackqueue.Queue
+	pool    *Pool
+	wake    chan struct{} // holds a token when the queue may have work
+	drained chan struct{} // closed by the receiver once sub is closed
+	closing atomic.Bool   // set by Close: return once the queue is empty
+	quit    chan struct{} // closed by Stop: return now
+	done    chan struct{} // closed when the dispatcher has returned
+	once    sync.Once
+}
+
+// FromTopic subscribes to topic and runs h for each message on a pool of
+// workers, publishing an Ack on AckTopic(topic) when each task ends.
+// Call Close or Stop to release it.
+func FromTopic(b *pubsub.Broker, topic string, h Handler, opts ...ConsumerOption) *Consumer {
+	c := &Consumer{
+		broker:      b,
+		topic:       topic,
+		handler:     h,
+		workers:     runtime.GOMAXPROCS(0),
+		lease:       time.Minute,
+		maxAttempts: 3,
+		wake:        make(chan struct{}, 1),
+		drained:     make(chan struct{}),
+		quit:        make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	c.queue = ackqueue.New(c.lease, ackqueue.WithMaxAttempts(c.maxAttempts))
+	c.pool = New(context.Background(), c.workers)
+	c.sub = b.Subscribe(topic)
+
+	go c.receive()
+	go c.dispatch()
+	return c
+}
+
+// signal wakes the dispatcher.
+func (c *Consumer) signal() {
+	select {
+	case c.wake <- struct{}{}:
+	default:
+	}
+}
+
+// receive moves messages from the subscription into the queue.
+func (c *Consumer) receive() {
+	defer close(c.drained)
+	for m := range c.sub.C {
+		c.queue.Push(m)
+		c.signal()
+	}
+}
+
+// dispatch hands queued tasks to the pool until stopped.
+func (c *Consumer) dispatch() {
+	defer close(c.done)
+
+	// look for expired leases a few times per lease
+	ticker := time.NewTicker(max(c.lease/4, time.Millisecond))
+	defer ticker.Stop()
+
+	for {
+		d, ok := c.queue.Pull()
+		if !ok {
+			if c.closing.Load() && c.queue.Len() == 0 {
+				return
+			}
+			select {
+			case <-c.quit:
+				return
+			case <-c.wake:
+			case <-ticker.C:
+			}
+			continue
+		}
+		if err := c.pool.Submit(context.Background(), func(ctx context.Context) { c.run(ctx, d) }); err != nil {
+			return // pool stopped
+		}
+	}
+}
+
+// run handles one delivery and settles it.
+func (c *Consumer) run(ctx context.Context, d ackqueue.Delivery) {
+	defer c.signal()
+	m := d.Payload.(pubsub.Message)
+	err := c.handler(ctx, m)
+
+	if err == nil {
+		if c.queue.Ack(d.ID) == nil {
+			c.broker.Publish(AckTopic(c.topic), Ack{Seq: m.Seq, Attempts: d.Attempt})
+		}
+		return
+	}
+	if c.queue.Nack(d.ID) != nil {
+		return // the lease expired meanwhile and the queue moved on
+	}
+	if c.maxAttempts > 0 && d.Attempt >= c.maxAttempts {
+		c.broker.Publish(AckTopic(c.topic), Ack{Seq: m.Seq, Attempts: d.Attempt, Err: err.Error()})
+	}
+}
+
+// Pending returns the number of tasks received but not yet settled.
+func (c *Consumer) Pending() int {
+	return c.queue.Len()
+}
+
+// Stats returns the task queue's counters.
+func (c *Consumer) Stats() ackqueue.Stats {
+	return c.queue.Stats()
+}
+
+// Failed returns the tasks given up on, oldest first: those whose
+// handler failed or outlived its lease on every attempt.
+func (c *Consumer) Failed() []pubsub.Message {
+	dead := c.queue.DeadLetters()
+	out := make([]pubsub.Message, len(dead))
+	for i, d := range dead {
+		out[i] = d.Payload.(pubsub.Message)
+	}
+	return out
+}
+
+// Close stops taking messages from the topic, waits until every task
+// received so far is settled, and shuts the pool down.
+func (c *Consumer) Close() {
+	c.once.Do(func() {
+		c.unsubscribe()
+		c.closing.Store(true)
+		c.signal()
+		<-c.done
+		c.pool.Close()
+	})
+}
+
+// Stop stops taking messages, cancels running handlers and discards the
+// tasks not yet settled.
+func (c *Consumer) Stop() {
+	c.once.Do(func() {
+		c.unsubscribe()
+		close(c.quit)
+		c.pool.Stop() // unblocks a dispatcher waiting in Submit
+		<-c.done
+	})
+}
+
+// unsubscribe ends the subscription and waits until every message it
+// delivered is queued.
+func (c *Consumer) unsubscribe() {
+	c.broker.Unsubscribe(c.topic, c.sub)
+	<-c.drained
+}
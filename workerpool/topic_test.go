@@ -0,0 +1,128 @@
+package workerpool
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/arifmahmudrana/go-snippets/pubsub"
+)
+
+// TestFromTopic_Acks tests that every task runs, failures are retried
+// and each outcome is acknowledged on the ack topic
+func TestFromTopic_Acks(t *testing.T) {
+	b := pubsub.NewBroker()
+	defer b.Stop()
+	acks := b.Subscribe(AckTopic("tasks"))
+
+	var mu sync.Mutex
+	attempts := map[int]int{}
+	c := FromTopic(b, "tasks", func(ctx context.Context, m pubsub.Message) error {
+		n := m.Payload.(int)
+		mu.Lock()
+		attempts[n]++
+		a := attempts[n]
+		mu.Unlock()
+		switch {
+		case n == 7:
+			return errors.New("always fails")
+		case n%2 == 0 && a == 1:
+			return errors.New("fails once")
+		}
+		return nil
+	}, WithWorkers(3), WithMaxAttempts(2))
+
+	for i := range 10 {
+		b.Publish("tasks", i)
+	}
+
+	got := map[uint64]Ack{}
+	for len(got) < 10 {
+		select {
+		case m := <-acks.C:
+			ack := m.Payload.(Ack)
+			got[ack.Seq] = ack
+		case <-time.After(2 * time.Second):
+			t.Fatalf("got %d acks, want 10", len(got))
+		}
+	}
+	c.Close()
+
+	for seq, ack := range got {
+		n := int(seq) - 1 // payload i has seq i+1
+		wantAttempts, wantErr := 1, ""
+		if n%2 == 0 || n == 7 {
+			wantAttempts = 2
+		}
+		if n == 7 {
+			wantErr = "always fails"
+		}
+		if ack.Attempts != wantAttempts || ack.Err != wantErr {
+			t.Errorf("ack for task %d = %+v, want %d attempts and error %q", n, ack, wantAttempts, wantErr)
+		}
+	}
+	if failed := c.Failed(); len(failed) != 1 || failed[0].Payload != 7 {
+		t.Errorf("Failed() = %v, want task 7", failed)
+	}
+	if st := c.Stats(); st.Pushed != 10 || st.Acked != 9 || st.Dead != 1 {
+		t.Errorf("Stats() = %+v, want 10 pushed, 9 acked, 1 dead", st)
+	}
+}
+
+// TestFromTopic_LostLease tests that a task whose handler hangs past its
+// lease is handed out again
+func TestFromTopic_LostLease(t *testing.T) {
+	b := pubsub.NewBroker()
+	defer b.Stop()
+
+	var calls atomic.Int32
+	c := FromTopic(b, "tasks", func(ctx context.Context, m pubsub.Message) error {
+		if calls.Add(1) == 1 {
+			<-ctx.Done() // stuck until Stop
+		}
+		return nil
+	}, WithWorkers(2), WithLease(20*time.Millisecond))
+	defer c.Stop()
+
+	b.Publish("tasks", "job")
+	deadline := time.Now().Add(2 * time.Second)
+	for c.Stats().Acked == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("task was not redelivered after its lease expired")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if st := c.Stats(); st.Redelivered != 1 {
+		t.Errorf("Redelivered = %d, want 1", st.Redelivered)
+	}
+}
+
+// TestFromTopic_CloseDrains tests that Close waits for queued tasks
+func TestFromTopic_CloseDrains(t *testing.T) {
+	b := pubsub.NewBroker()
+	defer b.Stop()
+
+	var done atomic.Int32
+	c := FromTopic(b, "tasks", func(ctx context.Context, m pubsub.Message) error {
+		time.Sleep(time.Millisecond)
+		done.Add(1)
+		return nil
+	}, WithWorkers(2))
+	for range 20 {
+		b.Publish("tasks", nil)
+	}
+	for c.Stats().Pushed < 20 {
+		time.Sleep(time.Millisecond) // until every task is received
+	}
+	c.Close()
+
+	if n := done.Load(); n != 20 {
+		t.Errorf("Close() returned after %d tasks, want 20", n)
+	}
+	if n := c.Pending(); n != 0 {
+		t.Errorf("Pending() = %d after Close, want 0", n)
+	}
+}
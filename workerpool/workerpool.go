@@ -0,0 +1,172 @@
+package workerpool
+
+import (
+	"context"
+	"errors"
+	"runtime/pprof"
+	"runtime/trace"
+	"strconv"
+	"sync"
+)
+
+// ErrClosed is returned by Submit after the pool has been closed.
+var ErrClosed = errors.New("workerpool: pool closed")
+
+// Task is a unit of work. Its context is derived from the pool's and is
+// cancelled when the pool is stopped, so long-running tasks should watch
+// it. It also expires at the deadline of the context passed to Submit:
+// see BudgetFrom for how much of that time is left.
+type Task func(ctx context.Context)
+
+// Pool runs submitted tasks on a fixed number of worker goroutines.
+//
+// The task queue is bounded (one slot per worker), so Submit blocks when
+// workers are busy — natural backpressure for producers.
+type Pool struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	tasks  chan Task
+	wg     sync.WaitGroup
+
+	mu     sync.RWMutex
+	closed bool
+
+	yield  func(point string) // nil unless WithYield
+	traced bool               // see WithTraceRegions
+}
+
+// Option configures a Pool.
+type Option func(*Pool)
+
+// WithYield calls fn before a submitter queues a task ("submit") and
+// before a worker runs one ("worker/{n}"). It is meant for a test
+// scheduler such as sched.Scheduler's Point, which blocks there to
+// choose the order the goroutines go in.
+func WithYield(fn func(point string)) Option {
+	return func(p *Pool) {
+		p.yield = fn
+	}
+}
+
+// WithTraceRegions marks the pool's work in execution traces, for `go
+// tool trace`: each task runs as a trace task named "workerpool.task",
+// logging its worker's number, and a Submit that waits for a free slot
+// is a "workerpool.submit" region of the submitter's task, if any. A
+// Task can nest regions of its own in its context's trace task. Nothing
+// is recorded unless a trace is running (see runtime/trace.Start).
+func WithTraceRegions() Option {
+	return func(p *Pool) {
+		p.traced = true
+	}
+}
+
+// New starts a pool with the given number of workers (at least 1).
+// Cancelling ctx stops the pool like Stop does.
+func New(ctx context.Context, workers int, opts ...Option) *Pool {
+	workers = max(workers, 1)
+	ctx, cancel := context.WithCancel(ctx)
+	p := &Pool{
+		ctx:    ctx,
+		cancel: cancel,
+		tasks:  make(chan Task, workers),
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+
+	p.wg.Add(workers)
+	for i := range workers {
+		// labeled so a stuck worker can be found in goroutine profiles
+		go pprof.Do(ctx, pprof.Labels("component", "workerpool", "worker", strconv.Itoa(i)), func(context.Context) {
+			p.worker(i)
+		})
+	}
+	return p
+}
+
+// worker runs tasks until the queue is closed or the pool is stopped.
+func (p *Pool) worker(n int) {
+	defer p.wg.Done()
+
+	id := strconv.Itoa(n)
+	point := "worker/" + id
+	for {
+		select {
+		case <-p.ctx.Done():
+			return
+		case t, ok := <-p.tasks:
+			if !ok {
+				return
+			}
+			if p.yield != nil {
+				p.yield(point)
+			}
+			p.run(t, id)
+		}
+	}
+}
+
+// run runs t, as a trace task if the pool was created WithTraceRegions
+// and a trace is running.
+func (p *Pool) run(t Task, worker string) {
+	if !p.traced || !trace.IsEnabled() {
+		t(p.ctx)
+		return
+	}
+	ctx, task := trace.NewTask(p.ctx, "workerpool.task")
+	defer task.End()
+	trace.Log(ctx, "worker", worker)
+	t(ctx)
+}
+
+// Submit queues t, blocking until a slot is free. It fails with ctx.Err()
+// if ctx is done first, or ErrClosed if the pool is closed or stopped.
+// Cancelling ctx after Submit returns does not cancel t, but t's context
+// keeps ctx's deadline.
+func (p *Pool) Submit(ctx context.Context, t Task) error {
+	return p.submit(ctx, budgeted(ctx, 0, t))
+}
+
+func (p *Pool) submit(ctx context.Context, t Task) error {
+	if p.yield != nil {
+		p.yield("submit")
+	}
+	// the read lock keeps Close from closing tasks while we send
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.closed {
+		return ErrClosed
+	}
+	if p.traced && trace.IsEnabled() && len(p.tasks) == cap(p.tasks) {
+		defer trace.StartRegion(ctx, "workerpool.submit").End()
+	}
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-p.ctx.Done():
+		return ErrClosed
+	case p.tasks <- t:
+		return nil
+	}
+}
+
+// Close stops accepting tasks and waits for queued ones to finish.
+func (p *Pool) Close() {
+	p.mu.Lock()
+	if !p.closed {
+		p.closed = true
+		close(p.tasks)
+	}
+	p.mu.Unlock()
+
+	p.wg.Wait()
+	p.cancel()
+}
+
+// Stop cancels running tasks, discards queued ones, and waits for the
+// workers to exit.
+func (p *Pool) Stop() {
+	p.cancel()
+	p.Close()
+}
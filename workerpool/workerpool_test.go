@@ -0,0 +1,135 @@
+package workerpool
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"runtime/trace"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/arifmahmudrana/go-snippets/deadlock"
+)
+
+// TestPool_RunsAllTasks tests that Close waits for every submitted task
+func TestPool_RunsAllTasks(t *testing.T) {
+	p := New(context.Background(), 4)
+
+	var n atomic.Int32
+	for range 100 {
+		if err := p.Submit(context.Background(), func(ctx context.Context) { n.Add(1) }); err != nil {
+			t.Fatal(err)
+		}
+	}
+	p.Close()
+
+	if got := n.Load(); got != 100 {
+		t.Errorf("ran %d tasks, want 100", got)
+	}
+}
+
+// TestPool_SubmitAfterClose tests that a closed pool rejects work
+func TestPool_SubmitAfterClose(t *testing.T) {
+	p := New(context.Background(), 1)
+	p.Close()
+
+	err := p.Submit(context.Background(), func(ctx context.Context) {})
+	if !errors.Is(err, ErrClosed) {
+		t.Errorf("Submit() after Close = %v, want %v", err, ErrClosed)
+	}
+}
+
+// TestPool_StopCancelsTasks tests that Stop cancels the task context
+func TestPool_StopCancelsTasks(t *testing.T) {
+	p := New(context.Background(), 1)
+
+	started := make(chan struct{})
+	p.Submit(context.Background(), func(ctx context.Context) {
+		close(started)
+		<-ctx.Done()
+	})
+	<-started
+
+	d := deadlock.ForTest(t, deadlock.WithTimeout(time.Second))
+	d.Within("Stop", p.Stop)
+}
+
+// TestPool_CloseWhileSubmitting tests that Close does not deadlock with
+// producers blocked on a full queue
+func TestPool_CloseWhileSubmitting(t *testing.T) {
+	d := deadlock.ForTest(t, deadlock.WithTimeout(2*time.Second))
+	p := New(context.Background(), 2)
+
+	producers := make(chan struct{})
+	go func() {
+		defer close(producers)
+		for range 50 {
+			if p.Submit(context.Background(), func(ctx context.Context) {
+				time.Sleep(time.Millisecond)
+			}) != nil {
+				return
+			}
+		}
+	}()
+
+	time.Sleep(5 * time.Millisecond)
+	d.Within("Close", p.Close)
+	deadlock.Recv(d, producers)
+}
+
+// TestPool_SubmitRespectsContext tests backpressure with a cancelled context
+func TestPool_SubmitRespectsContext(t *testing.T) {
+	p := New(context.Background(), 1)
+	defer p.Stop()
+
+	block := func(ctx context.Context) { <-ctx.Done() }
+	p.Submit(context.Background(), block) // running
+	p.Submit(context.Background(), block) // queued
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := p.Submit(ctx, block); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Submit() on full pool = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+// TestPool_WithTraceRegions tests that tasks run as trace tasks their
+// own regions nest in, only when asked for
+func TestPool_WithTraceRegions(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []Option
+		want bool
+	}{
+		{"on", []Option{WithTraceRegions()}, true},
+		{"off", nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := trace.Start(&buf); err != nil {
+				t.Skip("a trace is already running:", err)
+			}
+			p := New(context.Background(), 2, tt.opts...)
+			for range 4 {
+				p.Submit(context.Background(), func(ctx context.Context) {
+					_, inTask := ctx.Value(budgetKey{}).(*Budget)
+					if !inTask {
+						t.Error("task context lost its budget")
+					}
+					trace.WithRegion(ctx, "resize", func() {})
+				})
+			}
+			p.Close()
+			trace.Stop()
+
+			if found := bytes.Contains(buf.Bytes(), []byte("workerpool.task")); found != tt.want {
+				t.Errorf("trace contains workerpool.task = %v, want %v", found, tt.want)
+			}
+			if !bytes.Contains(buf.Bytes(), []byte("resize")) {
+				t.Error("trace lacks the task's own region")
+			}
+		})
+	}
+}